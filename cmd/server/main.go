@@ -9,10 +9,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	// Embedded zone data lets maintenance-windows name time zones on hosts without it.
+	_ "time/tzdata"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -36,6 +39,7 @@ func init() {
 // service based on the provided flags (login, codex-login, or server mode).
 func main() {
 	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", Version, Commit, BuildDate)
+	misc.ProxyVersion = Version
 
 	// Command-line flags to control the application's behavior.
 	var login bool
@@ -43,9 +47,13 @@ func main() {
 	var claudeLogin bool
 	var qwenLogin bool
 	var geminiWebAuth bool
+	var migrateOnly bool
+	var preflight bool
+	var replayDir string
 	var noBrowser bool
 	var projectID string
 	var configPath string
+	var overridePaths stringListFlag
 	var password string
 
 	// Define command-line flags for different operation modes.
@@ -54,9 +62,13 @@ func main() {
 	flag.BoolVar(&claudeLogin, "claude-login", false, "Login to Claude using OAuth")
 	flag.BoolVar(&qwenLogin, "qwen-login", false, "Login to Qwen using OAuth")
 	flag.BoolVar(&geminiWebAuth, "gemini-web-auth", false, "Auth Gemini Web using cookies")
+	flag.BoolVar(&migrateOnly, "migrate", false, "Migrate legacy v5 auth files and conversation stores, then exit")
+	flag.BoolVar(&preflight, "preflight", false, "Validate accounts and translators without starting the server, then exit")
+	flag.StringVar(&replayDir, "replay", "", "Replay the captured exchanges in this directory through the translators, then exit")
 	flag.BoolVar(&noBrowser, "no-browser", false, "Don't open browser automatically for OAuth")
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", "", "Configure File Path")
+	flag.Var(&overridePaths, "override", "Override file applied on top of the config file (repeatable)")
 	flag.StringVar(&password, "password", "", "")
 
 	flag.CommandLine.Usage = func() {
@@ -100,21 +112,21 @@ func main() {
 	var configFilePath string
 	if configPath != "" {
 		configFilePath = configPath
-		cfg, err = config.LoadConfig(configPath)
+		cfg, err = config.LoadConfigWithOverrides(configPath, overridePaths)
 	} else {
 		wd, err = os.Getwd()
 		if err != nil {
 			log.Fatalf("failed to get working directory: %v", err)
 		}
 		configFilePath = filepath.Join(wd, "config.yaml")
-		cfg, err = config.LoadConfig(configFilePath)
+		cfg, err = config.LoadConfigWithOverrides(configFilePath, overridePaths)
 	}
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 
-	if err = logging.ConfigureLogOutput(cfg.LoggingToFile); err != nil {
+	if err = logging.ConfigureLogOutput(cfg.LoggingToFile, logging.Rotation(cfg.LogRotation)); err != nil {
 		log.Fatalf("failed to configure log output: %v", err)
 	}
 
@@ -122,6 +134,7 @@ func main() {
 
 	// Set the log level based on the configuration.
 	util.SetLogLevel(cfg)
+	util.ConfigureUpstreamTransports(cfg)
 
 	// Expand the tilde (~) in the auth directory path to the user's home directory.
 	if strings.HasPrefix(cfg.AuthDir, "~") {
@@ -164,8 +177,28 @@ func main() {
 		cmd.DoQwenLogin(cfg, options)
 	} else if geminiWebAuth {
 		cmd.DoGeminiWebAuth(cfg)
+	} else if migrateOnly {
+		cmd.DoMigrate(cfg)
+	} else if replayDir != "" {
+		if !cmd.DoReplay(replayDir) {
+			os.Exit(1)
+		}
+	} else if preflight {
+		if !cmd.DoPreflight(cfg, configFilePath) {
+			os.Exit(1)
+		}
 	} else {
 		// Start the main proxy service
 		cmd.StartService(cfg, configFilePath, password)
 	}
 }
+
+// stringListFlag collects the values of a flag given more than once.
+type stringListFlag []string
+
+func (f *stringListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
@@ -68,6 +68,11 @@ type MyExecutor struct{}
 // Identifier returns the unique identifier for this executor.
 func (MyExecutor) Identifier() string { return providerKey }
 
+// Features declares what the provider supports; it is listed by /v0/management/capabilities.
+func (MyExecutor) Features() cliproxy.ProviderFeatures {
+	return cliproxy.ProviderFeatures{Format: string(fMyProv), Streaming: true}
+}
+
 // PrepareRequest optionally injects credentials to raw HTTP requests.
 // This method is called before each request to allow the executor to modify
 // the HTTP request with authentication headers or other necessary modifications.
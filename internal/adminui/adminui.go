@@ -0,0 +1,36 @@
+// Package adminui embeds the static management web UI. The UI is a thin client over the
+// existing /v0/management endpoints; this package only serves its files.
+package adminui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+//go:embed static
+var assets embed.FS
+
+// DefaultPath is the URL prefix used when admin-ui.path is not configured.
+const DefaultPath = "/admin"
+
+// NormalizePath returns path as a rooted prefix without a trailing slash, falling back to
+// DefaultPath when empty.
+func NormalizePath(path string) string {
+	path = strings.TrimSpace(path)
+	path = "/" + strings.Trim(path, "/")
+	if path == "/" {
+		return DefaultPath
+	}
+	return path
+}
+
+// Handler serves the embedded UI files with prefix stripped from request paths.
+func Handler(prefix string) http.Handler {
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix(prefix, http.FileServer(http.FS(static)))
+}
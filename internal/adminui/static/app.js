@@ -0,0 +1,162 @@
+(function () {
+  "use strict";
+
+  const API = "/v0/management";
+  const KEY_STORAGE = "cliproxy-management-key";
+
+  const views = {
+    auths: { endpoint: "/quota-status", render: renderAuths },
+    "auth-files": { endpoint: "/auth-files", render: renderAuthFiles },
+    usage: { endpoint: "/usage", render: renderJSON },
+    config: { endpoint: "/config", render: renderJSON },
+  };
+
+  const statusEl = document.getElementById("status");
+  const outputEl = document.getElementById("output");
+  const loginEl = document.getElementById("login");
+  const contentEl = document.getElementById("content");
+
+  function key() {
+    return sessionStorage.getItem(KEY_STORAGE) || "";
+  }
+
+  function showLogin(message) {
+    loginEl.hidden = false;
+    contentEl.hidden = true;
+    setStatus(message || "", true);
+  }
+
+  function setStatus(message, isError) {
+    statusEl.textContent = message;
+    statusEl.className = isError ? "error" : "";
+  }
+
+  async function request(endpoint) {
+    const resp = await fetch(API + endpoint, {
+      headers: { Authorization: "Bearer " + key() },
+    });
+    const body = await resp.json().catch(() => ({}));
+    if (resp.status === 401) {
+      sessionStorage.removeItem(KEY_STORAGE);
+      throw Object.assign(new Error(body.error || "unauthorized"), { auth: true });
+    }
+    if (!resp.ok) {
+      throw new Error(body.error || "request failed with status " + resp.status);
+    }
+    return body;
+  }
+
+  function clear() {
+    outputEl.textContent = "";
+    contentEl.querySelectorAll("table").forEach((table) => table.remove());
+  }
+
+  function renderJSON(data) {
+    outputEl.textContent = JSON.stringify(data, null, 2);
+  }
+
+  function renderTable(columns, rows) {
+    const table = document.createElement("table");
+    const head = table.createTHead().insertRow();
+    columns.forEach((column) => {
+      const th = document.createElement("th");
+      th.textContent = column.title;
+      head.appendChild(th);
+    });
+    const body = table.createTBody();
+    rows.forEach((row) => {
+      const tr = body.insertRow();
+      columns.forEach((column) => {
+        const value = column.value(row);
+        tr.insertCell().textContent = value === undefined || value === null ? "" : String(value);
+      });
+    });
+    contentEl.insertBefore(table, outputEl);
+  }
+
+  function renderAuths(data) {
+    renderTable(
+      [
+        { title: "ID", value: (a) => a.id },
+        { title: "Provider", value: (a) => a.provider },
+        { title: "Label", value: (a) => a.label },
+        { title: "Status", value: (a) => (a.disabled ? "disabled" : a.status) },
+        { title: "Quota exceeded", value: (a) => (a.quota_exceeded ? "yes" : "") },
+        { title: "Retry after", value: (a) => a.next_retry_after },
+      ],
+      data.auths || []
+    );
+    if ((data["gemini-web-pool"] || []).length > 0) {
+      renderTable(
+        [
+          { title: "Gemini Web account", value: (p) => p.label || p.auth_id },
+          { title: "Pool state", value: (p) => p.state },
+          { title: "Blocked until", value: (p) => p.blocked_until },
+          { title: "Last error", value: (p) => p.last_error },
+        ],
+        data["gemini-web-pool"]
+      );
+    }
+  }
+
+  function renderAuthFiles(data) {
+    renderTable(
+      [
+        { title: "Name", value: (f) => f.name },
+        { title: "Type", value: (f) => f.type },
+        { title: "Email", value: (f) => f.email },
+        { title: "Size", value: (f) => f.size },
+        { title: "Modified", value: (f) => f.modtime },
+      ],
+      data.files || []
+    );
+  }
+
+  async function show(name) {
+    const view = views[name];
+    if (!view) {
+      return;
+    }
+    if (!key()) {
+      showLogin();
+      return;
+    }
+    loginEl.hidden = true;
+    contentEl.hidden = false;
+    document.querySelectorAll("nav button[data-view]").forEach((button) => {
+      button.classList.toggle("active", button.dataset.view === name);
+    });
+    clear();
+    setStatus("Loading…", false);
+    try {
+      view.render(await request(view.endpoint));
+      setStatus("", false);
+    } catch (err) {
+      if (err.auth) {
+        showLogin(err.message);
+        return;
+      }
+      setStatus(err.message, true);
+    }
+  }
+
+  document.querySelectorAll("nav button[data-view]").forEach((button) => {
+    button.addEventListener("click", () => show(button.dataset.view));
+  });
+
+  document.getElementById("logout").addEventListener("click", () => {
+    sessionStorage.removeItem(KEY_STORAGE);
+    clear();
+    showLogin();
+  });
+
+  document.getElementById("login-form").addEventListener("submit", (event) => {
+    event.preventDefault();
+    const input = document.getElementById("key");
+    sessionStorage.setItem(KEY_STORAGE, input.value);
+    input.value = "";
+    show("auths");
+  });
+
+  show("auths");
+})();
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// accountHeader names the label of the auth that served the request, for the client API
+// keys listed in account-header-keys.
+const accountHeader = "X-CLIProxy-Account"
+
+// reportAccount notes the label of the auth that served the request of ctx in the request log
+// and, when the client API key opted in, in the account header. It must run before the
+// response is written.
+func (h *BaseAPIHandler) reportAccount(ctx context.Context) {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	label := logging.RequestAccount(ginCtx)
+	if label == "" {
+		return
+	}
+	logging.RecordAccountNote(ctx, label)
+	if h.Cfg != nil && slices.Contains(h.Cfg.AccountHeaderKeys, ginCtx.GetString("apiKey")) {
+		ginCtx.Header(accountHeader, label)
+	}
+}
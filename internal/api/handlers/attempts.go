@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// attemptsHeader tells the client how many upstream attempts its request took.
+const attemptsHeader = "X-CLIProxy-Attempts"
+
+// withAttemptLog prepares ctx to collect the upstream attempts of one request, for the
+// attempts header, the request log and dead letters.
+func withAttemptLog(ctx context.Context) context.Context {
+	return coreauth.WithAttemptLog(ctx)
+}
+
+// reportAttempts sets the attempts header of the response to the request of ctx and lists
+// each attempt with its auth, status and duration in the request log. It must run before the
+// response is written.
+func reportAttempts(ctx context.Context) {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	attempts := coreauth.Attempts(ctx)
+	ginCtx.Header(attemptsHeader, strconv.Itoa(len(attempts)))
+	for i, attempt := range attempts {
+		status := "-"
+		if attempt.Status != 0 {
+			status = strconv.Itoa(attempt.Status)
+		}
+		logging.RecordAttemptNote(ctx, fmt.Sprintf("%d. %s/%s status=%s duration=%s", i+1, attempt.Provider, attempt.AuthID, status, attempt.Duration.Round(time.Millisecond)))
+	}
+}
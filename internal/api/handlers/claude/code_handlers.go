@@ -72,6 +72,14 @@ func (h *ClaudeCodeAPIHandler) ClaudeMessages(c *gin.Context) {
 		})
 		return
 	}
+	rawJSON = h.ApplyDefaultModel(c, rawJSON)
+
+	if !h.ValidateRequest(c, handlers.EndpointMessages, rawJSON) {
+		return
+	}
+	if h.ServePassthrough(h, c, gjson.GetBytes(rawJSON, "model").String(), rawJSON, gjson.GetBytes(rawJSON, "stream").Bool()) {
+		return
+	}
 
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
@@ -101,6 +109,11 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 		})
 		return
 	}
+	rawJSON = h.ApplyDefaultModel(c, rawJSON)
+
+	if !h.ValidateRequest(c, handlers.EndpointCountTokens, rawJSON) {
+		return
+	}
 
 	c.Header("Content-Type", "application/json")
 
@@ -126,7 +139,7 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 //   - c: The Gin context for the request.
 func (h *ClaudeCodeAPIHandler) ClaudeModels(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"data": h.Models(),
+		"data": h.FilterModels(c, h.Models()),
 	})
 }
 
@@ -146,13 +159,14 @@ func (h *ClaudeCodeAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSO
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 
+	tagger := h.NewResponseTagger(c, h.HandlerType(), modelName, rawJSON)
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
-	_, _ = c.Writer.Write(resp)
+	_, _ = c.Writer.Write(tagger.TagResponse(resp))
 	cliCancel()
 }
 
@@ -204,10 +218,20 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 			return
 		case chunk, ok := <-data:
 			if !ok {
+				if errMsg := handlers.PendingStreamError(errs); errMsg != nil {
+					h.WriteStreamError(c, h.HandlerType(), errMsg)
+					flusher.Flush()
+					cancel(errMsg.Error)
+					return
+				}
 				flusher.Flush()
 				cancel(nil)
 				return
 			}
+			if handlers.IsHeartbeat(chunk) {
+				handlers.WriteHeartbeat(c, flusher)
+				continue
+			}
 
 			if bytes.HasPrefix(chunk, []byte("event:")) {
 				_, _ = c.Writer.Write([]byte("\n"))
@@ -222,7 +246,7 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, h.HandlerType(), errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// countingExecutor answers every request and counts the calls.
+type countingExecutor struct{ calls atomic.Int32 }
+
+func (e *countingExecutor) Identifier() string { return "claude" }
+
+func (e *countingExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	return coreexecutor.Response{Payload: []byte(`{"ok":true}`)}, nil
+}
+
+func (e *countingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.calls.Add(1)
+	ch := make(chan coreexecutor.StreamChunk)
+	close(ch)
+	return ch, nil
+}
+
+func (e *countingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *countingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func TestPassthroughMessagesAreValidated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry.GetGlobalRegistry().RegisterClient("passthrough-limit-test", "claude", []*registry.ModelInfo{{ID: "passthrough-limit-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("passthrough-limit-test") })
+
+	exec := &countingExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(exec)
+	auth := &coreauth.Auth{ID: "limit-claude", Provider: "claude", Status: coreauth.StatusActive, Attributes: map[string]string{"api_key": "key"}}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{MaxMessages: 1}
+	cfg.Passthrough.Models = []string{"passthrough-limit-model"}
+	h := NewClaudeCodeAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+
+	engine := gin.New()
+	engine.POST("/v1/messages", h.ClaudeMessages)
+	send := func(body string) int {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	if code := send(`{"model":"passthrough-limit-model","messages":[{"role":"user","content":"a"},{"role":"assistant","content":"b"}]}`); code != http.StatusBadRequest {
+		t.Fatalf("status over max-messages = %d, want 400", code)
+	}
+	if exec.calls.Load() != 0 {
+		t.Fatal("request over max-messages reached the upstream")
+	}
+	if code := send(`{"model":"passthrough-limit-model","messages":[{"role":"user","content":"a"}]}`); code != http.StatusOK {
+		t.Fatalf("status within max-messages = %d, want 200", code)
+	}
+	if exec.calls.Load() != 1 {
+		t.Fatalf("upstream calls = %d, want 1", exec.calls.Load())
+	}
+}
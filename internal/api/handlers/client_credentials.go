@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// ProviderKeyHeader carries an upstream API key the client brings for the provider it names
+// in X-Provider.
+const ProviderKeyHeader = "X-Provider-Key"
+
+// apiKeyProviders are the built-in providers whose executors accept a plain API key.
+var apiKeyProviders = []string{"claude", "gemini", "codex"}
+
+// clientCredential returns the key the client sent in X-Provider-Key, or "".
+func clientCredential(ctx context.Context) string {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return ""
+	}
+	return strings.TrimSpace(ginCtx.GetHeader(ProviderKeyHeader))
+}
+
+// withClientCredential routes a request carrying X-Provider-Key through an auth built from
+// that key alone: providers, already narrowed to the one X-Provider names, are returned with
+// a context the auth manager reads the auth from. The key is neither stored nor logged and
+// the stored accounts are never used as a fallback. Requests without the header are returned
+// unchanged.
+func (h *BaseAPIHandler) withClientCredential(ctx context.Context, providers []string) (context.Context, []string, *interfaces.ErrorMessage) {
+	key := clientCredential(ctx)
+	if key == "" {
+		return ctx, providers, nil
+	}
+	reject := func(status int, format string, args ...any) (context.Context, []string, *interfaces.ErrorMessage) {
+		return ctx, nil, &interfaces.ErrorMessage{StatusCode: status, Error: fmt.Errorf(format, args...), Kind: coreexecutor.ErrorKindInvalid}
+	}
+	if h.Cfg == nil || !h.Cfg.ClientCredentials.Enable {
+		return reject(http.StatusForbidden, "%s is not accepted by this server", ProviderKeyHeader)
+	}
+	settings := h.Cfg.ClientCredentials
+	if len(settings.APIKeys) > 0 {
+		ginCtx, _ := ctx.Value("gin").(*gin.Context)
+		if ginCtx == nil || !slices.Contains(settings.APIKeys, ginCtx.GetString("apiKey")) {
+			return reject(http.StatusForbidden, "%s is not accepted for this API key", ProviderKeyHeader)
+		}
+	}
+	if providerOverride(ctx) == "" || len(providers) != 1 {
+		return reject(http.StatusBadRequest, "%s requires an X-Provider header naming the provider the key is for", ProviderKeyHeader)
+	}
+	provider := providers[0]
+	if len(settings.Providers) > 0 && !slices.ContainsFunc(settings.Providers, func(p string) bool { return strings.EqualFold(strings.TrimSpace(p), provider) }) {
+		return reject(http.StatusForbidden, "%s is not accepted for provider %s", ProviderKeyHeader, provider)
+	}
+
+	attrs := map[string]string{"source": coreauth.SourceRequest, "api_key": key}
+	if !slices.Contains(apiKeyProviders, provider) {
+		compat := h.compatibilityProvider(provider)
+		if compat == nil {
+			return reject(http.StatusBadRequest, "provider %s does not take an API key in %s", provider, ProviderKeyHeader)
+		}
+		// The key goes to the configured base URL; clients cannot point it elsewhere.
+		attrs["base_url"] = compat.BaseURL
+		attrs["compat_name"] = compat.Name
+		attrs["provider_key"] = provider
+	}
+	auth := &coreauth.Auth{
+		ID:         "request:" + provider,
+		Provider:   provider,
+		Label:      "client-supplied",
+		Status:     coreauth.StatusActive,
+		Attributes: attrs,
+	}
+	return coreauth.WithEphemeralAuth(ctx, auth), []string{provider}, nil
+}
+
+// compatibilityProvider returns the openai-compatibility entry serving as provider, or nil.
+func (h *BaseAPIHandler) compatibilityProvider(provider string) *config.OpenAICompatibility {
+	for i := range h.Cfg.OpenAICompatibility {
+		if compat := &h.Cfg.OpenAICompatibility[i]; strings.EqualFold(strings.TrimSpace(compat.Name), provider) {
+			return compat
+		}
+	}
+	return nil
+}
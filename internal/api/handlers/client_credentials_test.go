@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+const testProviderKey = "sk-client-supplied-secret"
+
+// keyRecordingExecutor answers every request and records the API key of the auth it got.
+type keyRecordingExecutor struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (e *keyRecordingExecutor) Identifier() string { return "claude" }
+
+func (e *keyRecordingExecutor) Execute(_ context.Context, auth *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.keys = append(e.keys, auth.Attributes["api_key"])
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"ok":true}`)}, nil
+}
+
+func (e *keyRecordingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk)
+	close(ch)
+	return ch, nil
+}
+
+func (e *keyRecordingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *keyRecordingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+type testAPIHandler struct{}
+
+func (testAPIHandler) HandlerType() string      { return "claude" }
+func (testAPIHandler) Models() []map[string]any { return nil }
+
+func newCredentialTestHandler(t *testing.T) (*BaseAPIHandler, *keyRecordingExecutor) {
+	t.Helper()
+	exec := &keyRecordingExecutor{}
+	cfg := &config.Config{}
+	cfg.ClientCredentials.Enable = true
+	cfg.Passthrough.Models = []string{"byo-key-model"}
+	cfg.Mirroring.Rules = []config.MirrorRule{{Provider: "claude", Model: "byo-key-model", Percent: 100}}
+	h := newTestHandler(t, cfg, exec, "byo-key-model")
+	stored := &coreauth.Auth{ID: "claude", Provider: "claude", Status: coreauth.StatusActive, Attributes: map[string]string{"api_key": "stored-key"}}
+	if _, err := h.AuthManager.Update(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	return h, exec
+}
+
+func TestPassthroughUsesClientSuppliedKey(t *testing.T) {
+	h, exec := newCredentialTestHandler(t)
+	var logs bytes.Buffer
+	previous := log.StandardLogger().Out
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	engine := gin.New()
+	engine.POST("/v1/messages", func(c *gin.Context) {
+		if !h.ServePassthrough(testAPIHandler{}, c, "byo-key-model", []byte(`{"model":"byo-key-model"}`), false) {
+			t.Error("request was not passed through")
+		}
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`))
+	req.Header.Set("X-Provider", "claude")
+	req.Header.Set(ProviderKeyHeader, testProviderKey)
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if len(exec.keys) != 1 || exec.keys[0] != testProviderKey {
+		t.Fatalf("upstream keys = %q, want only the supplied key", exec.keys)
+	}
+	for _, auth := range h.AuthManager.List() {
+		if auth.Attributes["api_key"] == testProviderKey {
+			t.Fatalf("supplied key stored as auth %s", auth.ID)
+		}
+	}
+	if strings.Contains(logs.String(), testProviderKey) {
+		t.Fatalf("supplied key logged:\n%s", logs.String())
+	}
+	if got := logging.RedactHeaderValue(ProviderKeyHeader, testProviderKey); got == testProviderKey {
+		t.Fatal("request log would record the supplied key")
+	}
+}
+
+func TestClientSuppliedKeyRequestsAreNotMirrored(t *testing.T) {
+	h, _ := newCredentialTestHandler(t)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	c.Request.Header.Set(ProviderKeyHeader, testProviderKey)
+	ctx, cancel := h.GetContextWithCancel(testAPIHandler{}, c, context.Background())
+	noteMirrorCandidate(ctx, "claude", "byo-key-model", []byte(`{}`))
+
+	before := mirrorsRunning.Load()
+	cancel()
+	if mirrorsRunning.Load() != before {
+		t.Fatal("request carrying X-Provider-Key was mirrored")
+	}
+}
+
+func TestPassthroughChecksSafetySettings(t *testing.T) {
+	h, exec := newCredentialTestHandler(t)
+	engine := gin.New()
+	engine.POST("/v1/messages", func(c *gin.Context) {
+		body := []byte(`{"model":"byo-key-model","safety_settings":[{"category":"HARM_CATEGORY_BOGUS","threshold":"BLOCK_NONE"}]}`)
+		if !h.ServePassthrough(testAPIHandler{}, c, "byo-key-model", body, false) {
+			t.Error("request was not passed through")
+		}
+	})
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body %s", rec.Code, rec.Body.String())
+	}
+	if len(exec.keys) != 0 {
+		t.Fatalf("request with invalid safety settings reached the upstream")
+	}
+}
+
+func TestPassthroughAppliesToolGuard(t *testing.T) {
+	h, exec := newCredentialTestHandler(t)
+	registry.GetGlobalRegistry().RegisterClient("web-only-test", "gemini-web", []*registry.ModelInfo{{ID: "web-only-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("web-only-test") })
+	h.Cfg.Passthrough.Models = append(h.Cfg.Passthrough.Models, "web-only-model")
+
+	engine := gin.New()
+	engine.POST("/v1/messages", func(c *gin.Context) {
+		body := []byte(`{"model":"web-only-model","tools":[{"name":"lookup"}]}`)
+		if !h.ServePassthrough(testAPIHandler{}, c, "web-only-model", body, false) {
+			t.Error("request was not passed through")
+		}
+	})
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body %s", rec.Code, rec.Body.String())
+	}
+	if len(exec.keys) != 0 {
+		t.Fatalf("request declaring tools reached the upstream")
+	}
+}
+
+// credentialContext returns a request context carrying the given headers.
+func credentialContext(headers map[string]string) context.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	return context.WithValue(context.Background(), "gin", c)
+}
+
+func TestExecuteUsesClientSuppliedKeyInsteadOfStoredAccounts(t *testing.T) {
+	h, exec := newCredentialTestHandler(t)
+	h.Cfg.Mirroring.Rules = nil
+	body := []byte(`{"model":"byo-key-model","messages":[]}`)
+
+	ctx := credentialContext(map[string]string{"X-Provider": "claude", ProviderKeyHeader: testProviderKey})
+	if _, errMsg := h.ExecuteWithAuthManager(ctx, "claude", "byo-key-model", body, ""); errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	if _, errMsg := h.ExecuteWithAuthManager(credentialContext(nil), "claude", "byo-key-model", body, ""); errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	if len(exec.keys) != 2 || exec.keys[0] != testProviderKey || exec.keys[1] != "stored-key" {
+		t.Fatalf("upstream keys = %q, want the supplied key and then the stored one", exec.keys)
+	}
+	if auths := h.AuthManager.List(); len(auths) != 1 || auths[0].ID != "claude" {
+		t.Fatalf("auths after a supplied-key request = %d, want only the stored account", len(auths))
+	}
+}
+
+func TestClientSuppliedKeyRejections(t *testing.T) {
+	cases := []struct {
+		name    string
+		disable bool
+		headers map[string]string
+		want    int
+	}{
+		{"disabled", true, map[string]string{"X-Provider": "claude", ProviderKeyHeader: testProviderKey}, http.StatusForbidden},
+		{"without X-Provider", false, map[string]string{ProviderKeyHeader: testProviderKey}, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, exec := newCredentialTestHandler(t)
+			h.Cfg.Mirroring.Rules = nil
+			h.Cfg.ClientCredentials.Enable = !tc.disable
+			_, errMsg := h.ExecuteWithAuthManager(credentialContext(tc.headers), "claude", "byo-key-model", []byte(`{"model":"byo-key-model"}`), "")
+			if errMsg == nil || errMsg.StatusCode != tc.want {
+				t.Fatalf("error = %+v, want status %d", errMsg, tc.want)
+			}
+			if strings.Contains(errMsg.Error.Error(), testProviderKey) {
+				t.Fatal("error message repeats the supplied key")
+			}
+			if len(exec.keys) != 0 {
+				t.Fatalf("rejected request reached upstream with keys %q", exec.keys)
+			}
+		})
+	}
+}
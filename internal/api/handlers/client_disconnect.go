@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// StatusClientClosedRequest is the non-standard status used for requests abandoned by their
+// client. Nobody is left to read it; it only shows up in logs.
+const StatusClientClosedRequest = 499
+
+// cancelOnDisconnect cancels the handler context as soon as the HTTP request context of c
+// ends, which net/http does when the client connection goes away. The returned function
+// stops watching and must be called once the handler is done.
+func cancelOnDisconnect(c *gin.Context, cancel context.CancelFunc) func() bool {
+	if c == nil || c.Request == nil {
+		return func() bool { return false }
+	}
+	return context.AfterFunc(c.Request.Context(), cancel)
+}
+
+// ClientDisconnected reports whether the client of the request carried by ctx hung up. A
+// request-timeout ends the request context with DeadlineExceeded instead, so it is not
+// mistaken for a disconnect.
+func ClientDisconnected(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return false
+	}
+	return errors.Is(ginCtx.Request.Context().Err(), context.Canceled)
+}
+
+// recordClientDisconnect publishes a usage record marking the request as abandoned, so it is
+// counted apart from upstream failures.
+func recordClientDisconnect(ctx context.Context, modelName string) {
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	provider, model := logging.RequestTarget(ginCtx)
+	if provider == "-" {
+		provider = ""
+	}
+	if model == "-" {
+		model = modelName
+	}
+	apiKey := ""
+	if ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+	}
+	log.Debugf("client disconnected, cancelled upstream request for model %s", model)
+	coreusage.PublishRecord(ctx, coreusage.Record{
+		Provider:    provider,
+		Model:       model,
+		APIKey:      apiKey,
+		RequestedAt: time.Now(),
+		Status:      coreusage.StatusClientDisconnected,
+	})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescedHeader marks a response shared by identical requests that were in flight at the
+// same time.
+const coalescedHeader = "X-CLIProxy-Coalesced"
+
+// coalescedRequestHeaders are the client headers besides forward-headers that change what
+// the upstream is asked, so requests only share a call when they agree on them.
+var coalescedRequestHeaders = []string{"Anthropic-Beta", ragHeader, ScheduleOverrideHeader}
+
+// inflight joins identical concurrent non-streaming requests into one upstream call.
+var inflight singleflight.Group
+
+// coalescedResult is the outcome of one upstream call shared by the requests it served,
+// with the backend that served it.
+type coalescedResult struct {
+	resp     coreexecutor.Response
+	errMsg   *interfaces.ErrorMessage
+	body     *sharedBody
+	provider string
+	model    string
+	account  string
+}
+
+// sharedBody keeps a copy of the response body the first request relays to its client, so
+// the requests that joined its call get the same bytes once it has been read to the end.
+type sharedBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	done     chan struct{}
+	once     sync.Once
+	complete bool
+}
+
+func (b *sharedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	switch {
+	case err == io.EOF:
+		b.finish(true)
+	case err != nil:
+		b.finish(false)
+	}
+	return n, err
+}
+
+func (b *sharedBody) Close() error {
+	b.finish(false)
+	return b.ReadCloser.Close()
+}
+
+func (b *sharedBody) finish(complete bool) {
+	b.once.Do(func() {
+		b.complete = complete
+		close(b.done)
+	})
+}
+
+// coalesceKey returns the key under which a non-streaming request is joined with identical
+// ones in flight, or false when coalesce-requests is off or the request may not share a
+// call. Requests only match when they come with the same API key, provider override and
+// forwarded headers, so per-key settings never leak into another client's response.
+func (h *BaseAPIHandler) coalesceKey(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (string, bool) {
+	if h.Cfg == nil || !h.Cfg.CoalesceRequests || clientCredential(ctx) != "" {
+		// Requests bringing their own key are billed to it and never share a call.
+		return "", false
+	}
+	if !coalescible(handlerType, rawJSON) {
+		return "", false
+	}
+	body := rawJSON
+	// Decoding and encoding again sorts object keys and drops insignificant whitespace, so
+	// bodies differing only in formatting share a key.
+	var decoded any
+	if err := json.Unmarshal(rawJSON, &decoded); err == nil {
+		if normalized, errMarshal := json.Marshal(decoded); errMarshal == nil {
+			body = normalized
+		}
+	}
+	apiKey := ""
+	var header http.Header
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+		if ginCtx.Request != nil {
+			header = ginCtx.Request.Header
+		}
+	}
+	sum := sha256.New()
+	for _, part := range []string{handlerType, modelName, alt, apiKey, providerOverride(ctx)} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+	for _, name := range append(append([]string(nil), h.Cfg.ForwardHeaders...), coalescedRequestHeaders...) {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		sum.Write([]byte(name + ":" + strings.Join(header.Values(name), ",")))
+		sum.Write([]byte{0})
+	}
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil)), true
+}
+
+// coalescible reports whether a request is idempotent enough to share an upstream call:
+// it must not continue or store server-side state, call tools or ask for several choices.
+func coalescible(handlerType string, rawJSON []byte) bool {
+	root := gjson.ParseBytes(rawJSON)
+	for _, field := range []string{"tools", "functions", "tool_choice", "previous_response_id", "conversation"} {
+		if root.Get(field).Exists() {
+			return false
+		}
+	}
+	if root.Get("n").Int() > 1 || root.Get("generationConfig.candidateCount").Int() > 1 {
+		return false
+	}
+	// The Responses API stores responses unless told not to, and a stored response must
+	// belong to one client.
+	if handlerType == constant.OpenaiResponse && root.Get("store").Type != gjson.False {
+		return false
+	}
+	return true
+}
+
+// coalesce runs execute for the first request under key and hands its result to every
+// identical request arriving before it completes. The first request keeps its response as
+// it is, body included; the others get a copy of the body once the first has read it whole,
+// and have the backend that served it noted on their own request. A request whose client
+// goes away stops waiting; the others carry on. When the shared call failed, or its body
+// was cut short, only because the client that started it went away, a waiting request runs
+// its own call instead.
+func (h *BaseAPIHandler) coalesce(ctx context.Context, key string, execute func() (coreexecutor.Response, *interfaces.ErrorMessage)) (coreexecutor.Response, *interfaces.ErrorMessage) {
+	led := false
+	results := inflight.DoChan(key, func() (any, error) {
+		led = true
+		resp, errMsg := execute()
+		result := coalescedResult{resp: resp, errMsg: errMsg}
+		if resp.Body != nil {
+			body := &sharedBody{ReadCloser: resp.Body, done: make(chan struct{})}
+			// The first request may give up before it got the body; closing it then lets
+			// the others run their own call instead of waiting for it.
+			context.AfterFunc(ctx, func() { _ = body.Close() })
+			result.body = body
+			result.resp.Body = body
+		}
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+			result.provider, result.model = logging.RequestTarget(ginCtx)
+			result.account = logging.RequestAccount(ginCtx)
+		}
+		return result, nil
+	})
+	var result coalescedResult
+	select {
+	case <-ctx.Done():
+		return coreexecutor.Response{}, &interfaces.ErrorMessage{StatusCode: StatusClientClosedRequest, Error: ctx.Err()}
+	case shared := <-results:
+		result = shared.Val.(coalescedResult)
+	}
+	if led {
+		return result.resp, result.errMsg
+	}
+	if result.errMsg != nil && result.errMsg.StatusCode == StatusClientClosedRequest && ctx.Err() == nil {
+		return execute()
+	}
+	payload := result.resp.Payload
+	if result.body != nil {
+		select {
+		case <-ctx.Done():
+			return coreexecutor.Response{}, &interfaces.ErrorMessage{StatusCode: StatusClientClosedRequest, Error: ctx.Err()}
+		case <-result.body.done:
+		}
+		if !result.body.complete {
+			return execute()
+		}
+		payload = result.body.buf.Bytes()
+	}
+	log.Debugf("request %s served by a coalesced upstream call", key[:12])
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(coalescedHeader, "true")
+	}
+	if result.errMsg != nil {
+		return coreexecutor.Response{}, result.errMsg
+	}
+	if result.provider != "-" && result.provider != "" {
+		logging.RecordRequestTarget(ctx, result.provider, result.model)
+	}
+	if result.account != "" {
+		logging.RecordRequestAccount(ctx, result.account)
+		h.reportAccount(ctx)
+	}
+	resp := coreexecutor.Response{Payload: bytes.Clone(payload), Metadata: maps.Clone(result.resp.Metadata)}
+	h.recordCaptureResponse(ctx, resp.Payload)
+	return resp, nil
+}
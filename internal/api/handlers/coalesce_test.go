@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// gatedExecutor answers Execute once release is closed, counting its calls. The response is
+// a body when the caller prefers one.
+type gatedExecutor struct {
+	bodyExecutor
+	release chan struct{}
+	calls   *atomic.Int32
+}
+
+func (e gatedExecutor) Execute(ctx context.Context, _ *coreauth.Auth, _ coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	// Executors note the backend on the request, as newUsageReporter does.
+	logging.RecordRequestTarget(ctx, "gemini", "coalesce-test-model")
+	<-e.release
+	const data = `{"candidates":[{"content":{"parts":[{"text":"shared"}]}}]}`
+	if opts.PreferBody {
+		return coreexecutor.Response{Body: io.NopCloser(strings.NewReader(data))}, nil
+	}
+	return coreexecutor.Response{Payload: []byte(data)}, nil
+}
+
+func newCoalesceTestHandler(t *testing.T) (*BaseAPIHandler, gatedExecutor) {
+	t.Helper()
+	executor := gatedExecutor{release: make(chan struct{}), calls: &atomic.Int32{}}
+	cfg := &config.Config{CoalesceRequests: true, ForwardHeaders: []string{"X-Cache-Hint"}}
+	return newTestHandler(t, cfg, executor, "coalesce-test-model"), executor
+}
+
+func TestCoalesceSharesOneStreamedBody(t *testing.T) {
+	h, executor := newCoalesceTestHandler(t)
+	const body = `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`
+
+	recorders := make([]*httptest.ResponseRecorder, 2)
+	contexts := make([]*gin.Context, 2)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		contexts[i], _ = gin.CreateTestContext(recorders[i])
+		contexts[i].Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/coalesce-test-model:generateContent", nil)
+		ctx := context.WithValue(context.Background(), "gin", contexts[i])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errMsg := h.WriteNonStreamWithAuthManager(contexts[i], ctx, "gemini", "coalesce-test-model", []byte(body), ""); errMsg != nil {
+				t.Errorf("request %d failed: %v", i, errMsg.Error)
+			}
+		}()
+		if i == 0 {
+			for executor.calls.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(executor.release)
+	wg.Wait()
+
+	if calls := executor.calls.Load(); calls != 1 {
+		t.Fatalf("upstream calls = %d, want 1", calls)
+	}
+	for i, rec := range recorders {
+		if !strings.Contains(rec.Body.String(), `"shared"`) {
+			t.Fatalf("response %d = %q", i, rec.Body.String())
+		}
+	}
+	if recorders[0].Header().Get(coalescedHeader) != "" || recorders[1].Header().Get(coalescedHeader) != "true" {
+		t.Fatalf("coalesced headers = %q, %q; want only the joining request marked",
+			recorders[0].Header().Get(coalescedHeader), recorders[1].Header().Get(coalescedHeader))
+	}
+	if provider, _ := logging.RequestTarget(contexts[1]); provider != "gemini" {
+		t.Fatalf("joining request target provider = %q, want gemini", provider)
+	}
+}
+
+func TestCoalesceKeyIncludesForwardedHeaders(t *testing.T) {
+	h, _ := newCoalesceTestHandler(t)
+	key := func(hint string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if hint != "" {
+			c.Request.Header.Set("X-Cache-Hint", hint)
+		}
+		k, ok := h.coalesceKey(context.WithValue(context.Background(), "gin", c), constant.OpenAI, "m", []byte(`{"messages":[]}`), "")
+		if !ok {
+			t.Fatal("request not coalescible")
+		}
+		return k
+	}
+	if key("a") == key("b") || key("a") == key("") {
+		t.Fatal("requests with different forwarded headers share a key")
+	}
+	if key("a") != key("a") {
+		t.Fatal("identical requests got different keys")
+	}
+}
+
+func TestCoalescibleRequests(t *testing.T) {
+	for _, tt := range []struct {
+		handlerType string
+		body        string
+		want        bool
+	}{
+		{constant.OpenAI, `{"messages":[{"role":"user","content":"hi"}]}`, true},
+		{constant.OpenAI, `{"messages":[],"tools":[{"type":"function"}]}`, false},
+		{constant.OpenAI, `{"messages":[],"n":2}`, false},
+		{constant.Gemini, `{"contents":[],"generationConfig":{"candidateCount":3}}`, false},
+		{constant.Claude, `{"messages":[],"tools":[]}`, false},
+		{constant.OpenaiResponse, `{"input":"hi"}`, false},
+		{constant.OpenaiResponse, `{"input":"hi","store":false}`, true},
+		{constant.OpenaiResponse, `{"input":"hi","store":false,"previous_response_id":"resp_1"}`, false},
+	} {
+		if got := coalescible(tt.handlerType, []byte(tt.body)); got != tt.want {
+			t.Errorf("coalescible(%s, %s) = %v, want %v", tt.handlerType, tt.body, got, tt.want)
+		}
+	}
+}
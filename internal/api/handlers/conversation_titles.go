@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const titleInstruction = "Write a short title of at most eight words for the following conversation. Reply with the title only, without quotes."
+
+// GenerateConversationTitle asks the configured gemini-web title model for a title of a
+// stored conversation transcript.
+func (h *BaseAPIHandler) GenerateConversationTitle(ctx context.Context, conversation string) (string, error) {
+	if h.Cfg == nil || strings.TrimSpace(h.Cfg.GeminiWeb.TitleModel) == "" {
+		return "", fmt.Errorf("no title model configured")
+	}
+	model := strings.TrimSpace(h.Cfg.GeminiWeb.TitleModel)
+	body := []byte(`{"messages":[{"role":"system","content":""},{"role":"user","content":""}],"max_tokens":32}`)
+	body, _ = sjson.SetBytes(body, "model", model)
+	body, _ = sjson.SetBytes(body, "messages.0.content", titleInstruction)
+	body, _ = sjson.SetBytes(body, "messages.1.content", conversation)
+
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, constant.OpenAI, model, body, "")
+	if errMsg != nil {
+		return "", errMsg.Error
+	}
+	title := strings.TrimSpace(gjson.GetBytes(resp, "choices.0.message.content").String())
+	if title == "" {
+		return "", fmt.Errorf("title model %s returned no text", model)
+	}
+	return title, nil
+}
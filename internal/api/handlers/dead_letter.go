@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorlog"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// deadLetterEnabled reports whether failed requests are to be recorded at all.
+func (h *BaseAPIHandler) deadLetterEnabled() bool {
+	return h.Cfg != nil && (strings.TrimSpace(h.Cfg.DeadLetter.File) != "" || strings.TrimSpace(h.Cfg.DeadLetter.URL) != "")
+}
+
+// recordDeadLetter writes the dead letter of a request for modelName that the auth manager
+// gave up on with errMsg, or whose stream failed with it after it began. Requests abandoned
+// by their client are not recorded.
+func (h *BaseAPIHandler) recordDeadLetter(ctx context.Context, modelName string, providers []string, stream bool, errMsg *interfaces.ErrorMessage) {
+	if !h.deadLetterEnabled() || errMsg == nil || ClientDisconnected(ctx) {
+		return
+	}
+	entry := errorlog.DeadLetter{
+		Model:      modelName,
+		Providers:  providers,
+		Stream:     stream,
+		StatusCode: errMsg.StatusCode,
+	}
+	if errMsg.Error != nil {
+		entry.Error = errMsg.Error.Error()
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		entry.RequestID = ginCtx.GetHeader("X-Request-ID")
+		entry.Method = ginCtx.Request.Method
+		entry.Path = ginCtx.Request.URL.Path
+		if apiKey := ginCtx.GetString("apiKey"); apiKey != "" {
+			entry.APIKey = util.HideAPIKey(apiKey)
+		}
+	}
+	attempts := coreauth.Attempts(ctx)
+	entry.Attempts = make([]errorlog.DeadLetterAttempt, 0, len(attempts))
+	for i, attempt := range attempts {
+		item := errorlog.DeadLetterAttempt{AuthID: attempt.AuthID, Provider: attempt.Provider, Model: attempt.Model}
+		switch {
+		case attempt.Error != nil:
+			item.StatusCode = attempt.Error.HTTPStatus
+			item.Code = attempt.Error.Code
+			item.Message = attempt.Error.Message
+		case stream && i == len(attempts)-1:
+			// The stream that started and then failed.
+			item.StatusCode = entry.StatusCode
+			item.Message = entry.Error
+		default:
+			continue
+		}
+		entry.Attempts = append(entry.Attempts, item)
+	}
+	errorlog.WriteDeadLetter(h.Cfg, entry)
+}
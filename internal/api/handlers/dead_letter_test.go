@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorlog"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestStreamFailingUpstreamIsDeadLettered(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.DeadLetter.File = filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	h := newTestHandler(t, cfg, streamExecutor{chunks: []coreexecutor.StreamChunk{
+		{Payload: []byte(`{"candidates":[]}`)},
+		{Err: errors.New("upstream connection reset")},
+	}}, "dead-letter-test-model")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/dead-letter-test-model:streamGenerateContent", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	data, errs := h.ExecuteStreamWithAuthManager(ctx, "gemini", "dead-letter-test-model", []byte(`{"contents":[]}`), "")
+	for range data {
+	}
+	for range errs {
+	}
+
+	raw, err := os.ReadFile(cfg.DeadLetter.File)
+	if err != nil {
+		t.Fatalf("no dead letter written: %v", err)
+	}
+	var entry errorlog.DeadLetter
+	if err = json.Unmarshal(raw, &entry); err != nil {
+		t.Fatalf("dead letter %q: %v", raw, err)
+	}
+	if !entry.Stream || !strings.Contains(entry.Error, "upstream connection reset") {
+		t.Fatalf("dead letter = %+v", entry)
+	}
+	if len(entry.Attempts) != 1 || entry.Attempts[0].AuthID != "gemini" || !strings.Contains(entry.Attempts[0].Message, "upstream connection reset") {
+		t.Fatalf("attempts = %+v, want the failed stream", entry.Attempts)
+	}
+}
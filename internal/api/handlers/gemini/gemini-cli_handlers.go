@@ -62,6 +62,12 @@ func (h *GeminiCLIAPIHandler) CLIHandler(c *gin.Context) {
 	rawJSON, _ := c.GetRawData()
 	requestRawURI := c.Request.URL.Path
 
+	if requestRawURI == "/v1internal:generateContent" || requestRawURI == "/v1internal:streamGenerateContent" {
+		if !h.ValidateRequest(c, handlers.EndpointCLIGenerate, rawJSON) {
+			return
+		}
+	}
+
 	if requestRawURI == "/v1internal:generateContent" {
 		h.handleInternalGenerateContent(c, rawJSON)
 	} else if requestRawURI == "/v1internal:streamGenerateContent" {
@@ -164,20 +170,18 @@ func (h *GeminiCLIAPIHandler) handleInternalStreamGenerateContent(c *gin.Context
 }
 
 // handleInternalGenerateContent handles non-streaming content generation requests.
-// It sends a request to the backend client and proxies the entire response back to the client at once.
+// It sends a request to the backend client and relays the response body to the client as it arrives.
 func (h *GeminiCLIAPIHandler) handleInternalGenerateContent(c *gin.Context, rawJSON []byte) {
 	c.Header("Content-Type", "application/json")
 	modelResult := gjson.GetBytes(rawJSON, "model")
 	modelName := modelResult.String()
 
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
-	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
-	if errMsg != nil {
+	if errMsg := h.WriteNonStreamWithAuthManager(c, cliCtx, h.HandlerType(), modelName, rawJSON, ""); errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
-	_, _ = c.Writer.Write(resp)
 	cliCancel()
 }
 
@@ -189,9 +193,19 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 			return
 		case chunk, ok := <-data:
 			if !ok {
+				if errMsg := handlers.PendingStreamError(errs); errMsg != nil {
+					h.WriteStreamError(c, h.HandlerType(), errMsg)
+					flusher.Flush()
+					cancel(errMsg.Error)
+					return
+				}
 				cancel(nil)
 				return
 			}
+			if handlers.IsHeartbeat(chunk) {
+				handlers.WriteHeartbeat(c, flusher)
+				continue
+			}
 			if alt == "" {
 				if bytes.Equal(chunk, []byte("data: [DONE]")) || bytes.Equal(chunk, []byte("[DONE]")) {
 					continue
@@ -212,7 +226,7 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, h.HandlerType(), errMsg)
 				flusher.Flush()
 			}
 			var execErr error
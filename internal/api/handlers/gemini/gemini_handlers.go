@@ -1,6 +1,6 @@
 // Package gemini provides HTTP handlers for Gemini API endpoints.
 // This package implements handlers for managing Gemini model operations including
-// model listing, content generation, streaming content generation, and token counting.
+// model listing, content generation, streaming content generation, token counting and embeddings.
 // It serves as a proxy layer between clients and the Gemini backend service,
 // handling request translation, client management, and response processing.
 package gemini
@@ -17,6 +17,7 @@ import (
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
 )
 
 // GeminiAPIHandler contains the handlers for Gemini API endpoints.
@@ -49,7 +50,7 @@ func (h *GeminiAPIHandler) Models() []map[string]any {
 // It returns a JSON response containing available Gemini models and their specifications.
 func (h *GeminiAPIHandler) GeminiModels(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"models": h.Models(),
+		"models": h.FilterModels(c, h.Models()),
 	})
 }
 
@@ -137,6 +138,14 @@ func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
 	}
 }
 
+// geminiValidationEndpoints maps Gemini methods onto their request validation rules.
+var geminiValidationEndpoints = map[string]string{
+	"generateContent":       handlers.EndpointGenerateContent,
+	"streamGenerateContent": handlers.EndpointGenerateContent,
+	"countTokens":           handlers.EndpointGeminiCount,
+	"embedContent":          handlers.EndpointEmbedContent,
+}
+
 // GeminiHandler handles POST requests for Gemini API operations.
 // It routes requests to appropriate handlers based on the action parameter (model:method format).
 func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
@@ -165,6 +174,20 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 
 	method := action[1]
 	rawJSON, _ := c.GetRawData()
+	if endpoint, ok := geminiValidationEndpoints[method]; ok {
+		if errs := h.RequestErrors(endpoint, rawJSON); len(errs) > 0 {
+			writeGeminiError(c, &interfaces.ErrorMessage{
+				StatusCode: http.StatusBadRequest,
+				Error:      fmt.Errorf("invalid request: %s", handlers.FormatFieldErrors(errs)),
+			})
+			return
+		}
+	}
+	if method == "generateContent" || method == "streamGenerateContent" {
+		if h.ServePassthrough(h, c, action[0], rawJSON, method == "streamGenerateContent") {
+			return
+		}
+	}
 
 	switch method {
 	case "generateContent":
@@ -173,6 +196,13 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	case "embedContent":
+		h.handleEmbedContent(c, action[0], rawJSON)
+	default:
+		writeGeminiError(c, &interfaces.ErrorMessage{
+			StatusCode: http.StatusNotFound,
+			Error:      fmt.Errorf("method %s is not supported for models/%s", method, action[0]),
+		})
 	}
 }
 
@@ -227,7 +257,28 @@ func (h *GeminiAPIHandler) handleCountTokens(c *gin.Context, modelName string, r
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	resp, errMsg := h.ExecuteCountWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
 	if errMsg != nil {
-		h.WriteErrorResponse(c, errMsg)
+		writeGeminiError(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+// handleEmbedContent handles embedding requests for Gemini embedding models.
+// The request and the {"embedding":{"values":[...]}} response are passed through
+// unchanged; only Generative Language API keys can serve them.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The name of the embedding model
+//   - rawJSON: The raw JSON request body containing the content to embed
+func (h *GeminiAPIHandler) handleEmbedContent(c *gin.Context, modelName string, rawJSON []byte) {
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteEmbedWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON)
+	if errMsg != nil {
+		writeGeminiError(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
@@ -235,6 +286,30 @@ func (h *GeminiAPIHandler) handleCountTokens(c *gin.Context, modelName string, r
 	cliCancel()
 }
 
+// writeGeminiError writes msg in the Generative Language API error shape. Upstream bodies
+// that already carry that shape are passed through unchanged.
+func writeGeminiError(c *gin.Context, msg *interfaces.ErrorMessage) {
+	status := http.StatusInternalServerError
+	if msg != nil && msg.StatusCode > 0 {
+		status = msg.StatusCode
+	}
+	message := http.StatusText(status)
+	if msg != nil && msg.Error != nil {
+		message = msg.Error.Error()
+	}
+	if gjson.Valid(message) && gjson.Get(message, "error.status").Exists() {
+		c.Data(status, "application/json", []byte(message))
+		return
+	}
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"code":    status,
+			"message": message,
+			"status":  handlers.GeminiErrorStatus(status),
+		},
+	})
+}
+
 // handleGenerateContent handles non-streaming content generation requests for Gemini models.
 // This function processes the request synchronously and returns the complete generated
 // response in a single API call. It supports various generation parameters and
@@ -248,13 +323,11 @@ func (h *GeminiAPIHandler) handleGenerateContent(c *gin.Context, modelName strin
 	c.Header("Content-Type", "application/json")
 	alt := h.GetAlt(c)
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
-	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
-	if errMsg != nil {
+	if errMsg := h.WriteNonStreamWithAuthManager(c, cliCtx, h.HandlerType(), modelName, rawJSON, alt); errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
-	_, _ = c.Writer.Write(resp)
 	cliCancel()
 }
 
@@ -266,9 +339,19 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 			return
 		case chunk, ok := <-data:
 			if !ok {
+				if errMsg := handlers.PendingStreamError(errs); errMsg != nil {
+					h.WriteStreamError(c, h.HandlerType(), errMsg)
+					flusher.Flush()
+					cancel(errMsg.Error)
+					return
+				}
 				cancel(nil)
 				return
 			}
+			if handlers.IsHeartbeat(chunk) {
+				handlers.WriteHeartbeat(c, flusher)
+				continue
+			}
 			if alt == "" {
 				_, _ = c.Writer.Write([]byte("data: "))
 				_, _ = c.Writer.Write(chunk)
@@ -282,7 +365,7 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, h.HandlerType(), errMsg)
 				flusher.Flush()
 			}
 			var execErr error
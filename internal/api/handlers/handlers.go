@@ -4,19 +4,43 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
 	"golang.org/x/net/context"
 )
 
+const (
+	// defaultStreamBufferSize bounds the number of chunks queued between an upstream
+	// producer and a downstream SSE consumer.
+	defaultStreamBufferSize = 64
+
+	// defaultSlowConsumerTimeout is how long a producer may block on a full buffer
+	// before the request is cancelled.
+	defaultSlowConsumerTimeout = 60 * time.Second
+
+	// nonStreamChunkSize is the write size used when relaying a non-streaming body as it arrives.
+	nonStreamChunkSize = 32 << 10
+)
+
 // ErrorResponse represents a standard error response format for the API.
 // It contains a single ErrorDetail field.
 type ErrorResponse struct {
@@ -46,6 +70,12 @@ type BaseAPIHandler struct {
 
 	// Cfg holds the current application configuration.
 	Cfg *config.Config
+
+	// requestLogger receives the exchanges of shadow requests, which no middleware sees.
+	requestLogger logging.RequestLogger
+
+	// overrides caches the checks of keys sent in ScheduleOverrideHeader.
+	overrides overrideVerdicts
 }
 
 // NewBaseAPIHandlers creates a new API handlers instance.
@@ -106,10 +136,28 @@ func (h *BaseAPIHandler) GetAlt(c *gin.Context) string {
 //   - context.Context: The new context with cancellation and embedded values.
 //   - APIHandlerCancelFunc: A function to cancel the context and log the response.
 func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *gin.Context, ctx context.Context) (context.Context, APIHandlerCancelFunc) {
-	newCtx, cancel := context.WithCancel(ctx)
+	var newCtx context.Context
+	var cancel context.CancelFunc
+	// Carry the request-timeout deadline over, since handlers start from a fresh context.
+	if deadline, ok := c.Request.Context().Deadline(); ok {
+		newCtx, cancel = context.WithDeadline(ctx, deadline)
+	} else {
+		newCtx, cancel = context.WithCancel(ctx)
+	}
+	// A client hanging up cancels the upstream call instead of letting it run to completion.
+	stopWatching := cancelOnDisconnect(c, cancel)
 	newCtx = context.WithValue(newCtx, "gin", c)
 	newCtx = context.WithValue(newCtx, "handler", handler)
+	if h.scheduleOverridden(c) {
+		newCtx = coreauth.WithScheduleOverride(newCtx)
+	}
+	candidate := &mirrorCandidate{}
+	newCtx = context.WithValue(newCtx, mirrorCandidateKey{}, candidate)
+	requestCtx := newCtx
 	return newCtx, func(params ...interface{}) {
+		if handlerSucceeded(params) {
+			h.mirrorRequest(requestCtx, candidate)
+		}
 		if h.Cfg.RequestLog {
 			if len(params) == 1 {
 				data := params[0]
@@ -126,6 +174,7 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			}
 		}
 
+		stopWatching()
 		cancel()
 	}
 }
@@ -133,37 +182,151 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers := util.GetProviderName(modelName, h.Cfg)
-	if len(providers) == 0 {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+	noteMirrorCandidate(ctx, handlerType, modelName, rawJSON)
+	resp, errMsg := h.executeNonStream(ctx, handlerType, modelName, rawJSON, alt, false)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	return h.repairJSONOutput(ctx, handlerType, modelName, rawJSON, h.responseModel(handlerType, modelName, cloneBytes(resp.Payload))), nil
+}
+
+// WriteNonStreamWithAuthManager executes a non-streaming request and writes the response to
+// c. When the upstream body needs no translation it is copied to the client in chunks as it
+// arrives instead of being buffered whole, which keeps memory flat for large responses. An
+// upstream body failing before anything was written is returned as an error; later failures
+// can only end the response early and are noted in the request log.
+// Headers must be set by the caller beforehand.
+func (h *BaseAPIHandler) WriteNonStreamWithAuthManager(c *gin.Context, ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) *interfaces.ErrorMessage {
+	noteMirrorCandidate(ctx, handlerType, modelName, rawJSON)
+	tagger := h.NewResponseTagger(c, handlerType, modelName, rawJSON)
+	preferBody := !h.rewritesResponseModel(modelName) && tagger == nil && !h.repairsJSON(handlerType, rawJSON) && !h.reportsCost() && !h.capturing()
+	resp, errMsg := h.executeNonStream(ctx, handlerType, modelName, rawJSON, alt, preferBody)
+	if errMsg != nil {
+		return errMsg
+	}
+	if resp.Body == nil {
+		payload := h.repairJSONOutput(ctx, handlerType, modelName, rawJSON, h.responseModel(handlerType, modelName, resp.Payload))
+		_, _ = c.Writer.Write(tagger.TagResponse(payload))
+		return nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	buf := make([]byte, nonStreamChunkSize)
+	written := false
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, errWrite := c.Writer.Write(buf[:n]); errWrite != nil {
+				log.Debugf("client went away while relaying response body for model %s: %v", modelName, errWrite)
+				return nil
+			}
+			written = true
+			c.Writer.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			errMsg := &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: fmt.Errorf("failed to read upstream response: %w", err), Kind: coreexecutor.ErrorKindTransient}
+			if !written {
+				return errMsg
+			}
+			log.Warnf("failed to relay response body for model %s: %v", modelName, err)
+			logging.RecordStreamError(c, errMsg.StatusCode, errMsg.Error.Error())
+			return nil
+		}
+	}
+}
+
+// executeNonStream runs a non-streaming request, sharing the upstream call with identical
+// requests in flight when coalesce-requests is set.
+func (h *BaseAPIHandler) executeNonStream(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, preferBody bool) (coreexecutor.Response, *interfaces.ErrorMessage) {
+	if key, ok := h.coalesceKey(ctx, handlerType, modelName, rawJSON, alt); ok {
+		return h.coalesce(ctx, key, func() (coreexecutor.Response, *interfaces.ErrorMessage) {
+			return h.executeNonStreamOnce(ctx, handlerType, modelName, rawJSON, alt, preferBody)
+		})
+	}
+	return h.executeNonStreamOnce(ctx, handlerType, modelName, rawJSON, alt, preferBody)
+}
+
+func (h *BaseAPIHandler) executeNonStreamOnce(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, preferBody bool) (coreexecutor.Response, *interfaces.ErrorMessage) {
+	model, providers, errMsg := h.resolveProviders(ctx, modelName)
+	if errMsg == nil {
+		errMsg = checkSafetySettings(handlerType, rawJSON)
+	}
+	if errMsg == nil {
+		errMsg = checkInputTokens(model, rawJSON)
+	}
+	if errMsg == nil {
+		providers, errMsg = h.toolCapableProviders(modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		providers, errMsg = schemaCompatibleProviders(handlerType, modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		ctx, providers, errMsg = h.withClientCredential(ctx, providers)
+	}
+	if errMsg != nil {
+		return coreexecutor.Response{}, errMsg
+	}
+	payload, errMsg := h.retrievalContext(ctx, handlerType, cloneBytes(rawJSON))
+	if errMsg != nil {
+		return coreexecutor.Response{}, errMsg
+	}
+	payload, errMsg = h.limitToolResults(ctx, handlerType, providers, payload)
+	if errMsg != nil {
+		return coreexecutor.Response{}, errMsg
 	}
 	req := coreexecutor.Request{
-		Model:   modelName,
-		Payload: cloneBytes(rawJSON),
+		Model:   model,
+		Payload: h.responseLanguage(ctx, handlerType, payload),
 	}
 	opts := coreexecutor.Options{
 		Stream:          false,
 		Alt:             alt,
 		OriginalRequest: cloneBytes(rawJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
+		PreferBody:      preferBody,
 	}
+	h.recordCaptureSource(ctx, handlerType, false, rawJSON, alt)
+	ctx = withAttemptLog(ctx)
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	reportAttempts(ctx)
 	if err != nil {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		if ClientDisconnected(ctx) {
+			recordClientDisconnect(ctx, modelName)
+			return coreexecutor.Response{}, &interfaces.ErrorMessage{StatusCode: StatusClientClosedRequest, Error: err}
+		}
+		errMsg = errorMessageFromExecution(err)
+		h.recordDeadLetter(ctx, modelName, providers, false, errMsg)
+		return coreexecutor.Response{}, errMsg
 	}
-	return cloneBytes(resp.Payload), nil
+	reportIgnoredTools(ctx, rawJSON)
+	reportSchemaTransforms(ctx, handlerType, rawJSON)
+	h.reportRequestCost(ctx)
+	h.reportAccount(ctx)
+	if resp.Body == nil {
+		h.recordCaptureResponse(ctx, resp.Payload)
+	}
+	return resp, nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers := util.GetProviderName(modelName, h.Cfg)
-	if len(providers) == 0 {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+	model, providers, errMsg := h.resolveProviders(ctx, modelName)
+	if errMsg == nil {
+		ctx, providers, errMsg = h.withClientCredential(ctx, providers)
+	}
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	payload := cloneBytes(rawJSON)
+	if h.Cfg != nil && h.Cfg.RAG.Enable {
+		payload = stripRetrievalField(payload)
 	}
 	req := coreexecutor.Request{
-		Model:   modelName,
-		Payload: cloneBytes(rawJSON),
+		Model:   model,
+		Payload: payload,
 	}
 	opts := coreexecutor.Options{
 		Stream:          false,
@@ -171,9 +334,58 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		OriginalRequest: cloneBytes(rawJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
+	ctx = withAttemptLog(ctx)
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	reportAttempts(ctx)
+	if err != nil {
+		return nil, errorMessageFromExecution(err)
+	}
+	return cloneBytes(resp.Payload), nil
+}
+
+// embeddingProviders lists the providers able to serve embedContent requests.
+var embeddingProviders = map[string]struct{}{"gemini": {}}
+
+// ExecuteEmbedWithAuthManager executes an embedContent request via the core auth manager.
+// Only providers in embeddingProviders are tried; when none of the providers serving
+// modelName can embed, a 404 is returned as the Generative Language API does.
+func (h *BaseAPIHandler) ExecuteEmbedWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	model, providers, errMsg := h.resolveProviders(ctx, modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	supported := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if _, ok := embeddingProviders[provider]; ok {
+			supported = append(supported, provider)
+		}
+	}
+	if len(supported) == 0 {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusNotFound,
+			Error:      fmt.Errorf("models/%s is not supported for embedContent by the configured backends", modelName),
+			Kind:       coreexecutor.ErrorKindInvalid,
+		}
+	}
+	ctx, supported, errMsg = h.withClientCredential(ctx, supported)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	req := coreexecutor.Request{
+		Model:    model,
+		Payload:  cloneBytes(rawJSON),
+		Metadata: map[string]any{"action": "embedContent"},
+	}
+	opts := coreexecutor.Options{
+		Stream:          false,
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+	}
+	ctx = withAttemptLog(ctx)
+	resp, err := h.AuthManager.Execute(ctx, supported, req, opts)
+	reportAttempts(ctx)
 	if err != nil {
-		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		return nil, errorMessageFromExecution(err)
 	}
 	return cloneBytes(resp.Payload), nil
 }
@@ -181,16 +393,46 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers := util.GetProviderName(modelName, h.Cfg)
-	if len(providers) == 0 {
+	noteMirrorCandidate(ctx, handlerType, modelName, rawJSON)
+	model, providers, errMsg := h.resolveProviders(ctx, modelName)
+	if errMsg == nil {
+		errMsg = checkSafetySettings(handlerType, rawJSON)
+	}
+	if errMsg == nil {
+		errMsg = checkInputTokens(model, rawJSON)
+	}
+	if errMsg == nil {
+		providers, errMsg = h.toolCapableProviders(modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		providers, errMsg = schemaCompatibleProviders(handlerType, modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		ctx, providers, errMsg = h.withClientCredential(ctx, providers)
+	}
+	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
-		errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	payload, errMsg := h.retrievalContext(ctx, handlerType, cloneBytes(rawJSON))
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	payload, errMsg = h.limitToolResults(ctx, handlerType, providers, payload)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
 	req := coreexecutor.Request{
-		Model:   modelName,
-		Payload: cloneBytes(rawJSON),
+		Model:   model,
+		Payload: h.responseLanguage(ctx, handlerType, payload),
 	}
 	opts := coreexecutor.Options{
 		Stream:          true,
@@ -198,31 +440,300 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		OriginalRequest: cloneBytes(rawJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	h.recordCaptureSource(ctx, handlerType, true, rawJSON, alt)
+	// Raw (alt) streams are forwarded as one JSON document, which a tag chunk would break.
+	var tagger *ResponseTagger
+	var moderator *StreamModerator
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && alt == "" {
+		tagger = h.NewResponseTagger(ginCtx, handlerType, modelName, rawJSON)
+		moderator = h.NewStreamModerator(ginCtx, handlerType, modelName)
+	}
+	streamCtx, streamCancel := context.WithCancel(withAttemptLog(ctx))
+	chunks, err := h.AuthManager.ExecuteStream(streamCtx, providers, req, opts)
+	reportAttempts(streamCtx)
 	if err != nil {
+		errMsg = errorMessageFromExecution(err)
+		h.recordDeadLetter(streamCtx, modelName, providers, true, errMsg)
+		streamCancel()
 		errChan := make(chan *interfaces.ErrorMessage, 1)
-		errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
-	dataChan := make(chan []byte)
+	reportIgnoredTools(ctx, rawJSON)
+	reportSchemaTransforms(ctx, handlerType, rawJSON)
+	h.reportAccount(ctx)
+	dataChan := make(chan []byte, h.streamBufferSize())
+	// The error is sent before dataChan closes, so consumers seeing the close find it with
+	// PendingStreamError; the buffer keeps the producer from waiting for them.
 	errChan := make(chan *interfaces.ErrorMessage, 1)
+	slowTimeout := h.slowConsumerTimeout()
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
+		defer streamCancel()
+		// Drain whatever the producer still emits after an early exit so it never blocks forever.
+		defer func() {
+			go func() {
+				for range chunks {
+				}
+			}()
+		}()
+		var timer *time.Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		completed := false
+		defer func() {
+			if !completed && ClientDisconnected(ctx) {
+				recordClientDisconnect(ctx, modelName)
+			}
+		}()
+		// send hands payload to the consumer and reports whether the stream goes on.
+		send := func(payload []byte) bool {
+			select {
+			case dataChan <- payload:
+				return true
+			default:
+			}
+			// Buffer is full: apply backpressure, bounded by the slow consumer timeout.
+			if timer == nil {
+				timer = time.NewTimer(slowTimeout)
+			} else {
+				timer.Reset(slowTimeout)
+			}
+			select {
+			case dataChan <- payload:
+				if !timer.Stop() {
+					<-timer.C
+				}
+				return true
+			case <-streamCtx.Done():
+				return false
+			case <-timer.C:
+				log.Warnf("stream consumer for model %s blocked for %s, cancelling request", modelName, slowTimeout)
+				streamCancel()
+				select {
+				case errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusGatewayTimeout, Error: fmt.Errorf("stream consumer too slow: blocked for %s", slowTimeout), Kind: coreexecutor.ErrorKindTransient}:
+				case <-ctx.Done():
+				}
+				return false
+			}
+		}
 		for chunk := range chunks {
 			if chunk.Err != nil {
-				errChan <- &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: chunk.Err}
+				errMsg := errorMessageFromExecution(chunk.Err)
+				// An upstream failing once the stream began is as lost as one failing
+				// before; recordDeadLetter skips streams the client abandoned.
+				h.recordDeadLetter(streamCtx, modelName, providers, true, errMsg)
+				select {
+				case errChan <- errMsg:
+				case <-ctx.Done():
+				}
+				return
+			}
+			if chunk.Heartbeat {
+				// Heartbeats are the only empty chunks sent on; a full buffer has data
+				// on the way anyway.
+				select {
+				case dataChan <- []byte{}:
+				default:
+				}
+				continue
+			}
+			if len(chunk.Payload) == 0 {
+				continue
+			}
+			h.recordCaptureResponse(ctx, chunk.Payload)
+			payload := h.responseModel(handlerType, modelName, cloneBytes(chunk.Payload))
+			// A chunk breaking a moderation rule is replaced by the event ending the stream,
+			// and the upstream request is cancelled.
+			if final := moderator.Check(ctx, payload); final != nil {
+				streamCancel()
+				select {
+				case dataChan <- final:
+				case <-ctx.Done():
+				}
 				return
 			}
-			if len(chunk.Payload) > 0 {
-				dataChan <- cloneBytes(chunk.Payload)
+			for _, out := range tagger.Stream(payload) {
+				if !send(out) {
+					return
+				}
 			}
 		}
+		if final := moderator.Finish(ctx); final != nil && ctx.Err() == nil {
+			select {
+			case dataChan <- final:
+			case <-ctx.Done():
+			}
+			return
+		}
+		if final := tagger.Finish(); final != nil && ctx.Err() == nil {
+			select {
+			case dataChan <- final:
+			case <-streamCtx.Done():
+				return
+			}
+		}
+		completed = ctx.Err() == nil
 	}()
 	return dataChan, errChan
 }
 
+// resolveProviders returns the registered model ID modelName refers to and the providers able
+// to serve it, once the model policy of the calling key allows it. When the client pins a
+// provider through the X-Provider header, routing is restricted to that provider and the
+// request fails if it is unknown or does not serve the model.
+func (h *BaseAPIHandler) resolveProviders(ctx context.Context, modelName string) (string, []string, *interfaces.ErrorMessage) {
+	model, errMsg := h.canonicalModel(modelName)
+	if errMsg == nil {
+		errMsg = h.checkModelPolicy(ctx, model)
+	}
+	if errMsg != nil {
+		return "", nil, errMsg
+	}
+	providers, err := util.ApplyProviderOverride(util.GetProviderName(model, h.Cfg), providerOverride(ctx))
+	if err != nil {
+		return "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: err, Kind: coreexecutor.ErrorKindInvalid}
+	}
+	if len(providers) == 0 {
+		return "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName), Kind: coreexecutor.ErrorKindInvalid}
+	}
+	return model, providers, nil
+}
+
+// canonicalModel maps the model name a client sent to the registered model ID it matches
+// once case and whitespace are normalized, so "Gemini-2.5-Pro " routes like "gemini-2.5-pro".
+// Names without a match are returned unchanged. With strict-model-names, a name that matches
+// only after normalization is rejected with the exact ID it should have been.
+func (h *BaseAPIHandler) canonicalModel(modelName string) (string, *interfaces.ErrorMessage) {
+	id, ok := registry.GetGlobalRegistry().ResolveModelID(modelName)
+	if !ok || id == modelName {
+		return modelName, nil
+	}
+	if h.Cfg != nil && h.Cfg.StrictModelNames {
+		return "", &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("unknown model %q: the model ID is %q (names must match exactly)", modelName, id),
+			Kind:       coreexecutor.ErrorKindInvalid,
+		}
+	}
+	return id, nil
+}
+
+// providerOverride extracts the explicitly requested provider from the X-Provider header.
+func providerOverride(ctx context.Context) string {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return ""
+	}
+	return ginCtx.GetHeader("X-Provider")
+}
+
+// errorMessageFromExecution classifies an execution failure and picks the client-facing status
+// from its kind, keeping the upstream status when the executor reported one.
+func errorMessageFromExecution(err error) *interfaces.ErrorMessage {
+	kind := coreexecutor.ErrorKindOf(err)
+	status := http.StatusInternalServerError
+	var se coreexecutor.StatusError
+	if errors.As(err, &se) && se != nil && se.StatusCode() > 0 {
+		status = se.StatusCode()
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	} else {
+		switch kind {
+		case coreexecutor.ErrorKindQuota:
+			status = http.StatusTooManyRequests
+		case coreexecutor.ErrorKindAuth:
+			status = http.StatusUnauthorized
+		case coreexecutor.ErrorKindTransient:
+			status = http.StatusServiceUnavailable
+		case coreexecutor.ErrorKindInvalid:
+			status = http.StatusBadRequest
+		}
+	}
+	return &interfaces.ErrorMessage{StatusCode: status, Error: err, Kind: kind}
+}
+
+// responseModel applies the configured response-model-name policy to a response payload.
+func (h *BaseAPIHandler) responseModel(handlerType, modelName string, payload []byte) []byte {
+	if !h.rewritesResponseModel(modelName) {
+		return payload
+	}
+	return util.RewriteResponseModel(handlerType, payload, strings.TrimSpace(modelName))
+}
+
+// rewritesResponseModel reports whether responses must be rewritten to echo the requested
+// model: when configured to, or when modelName was normalized to reach a registered model,
+// so the client still sees the name as it wrote it.
+func (h *BaseAPIHandler) rewritesResponseModel(modelName string) bool {
+	if h.Cfg != nil && strings.EqualFold(strings.TrimSpace(h.Cfg.ResponseModelName), util.ResponseModelRequested) {
+		return true
+	}
+	id, ok := registry.GetGlobalRegistry().ResolveModelID(modelName)
+	return ok && id != modelName
+}
+
+// responseLanguage injects the configured response language instruction for the calling
+// API key. It runs once per client request, before provider rotation, so retries reuse the
+// same payload instead of stacking instructions.
+func (h *BaseAPIHandler) responseLanguage(ctx context.Context, handlerType string, rawJSON []byte) []byte {
+	if h.Cfg == nil {
+		return rawJSON
+	}
+	settings := h.Cfg.ResponseLanguage
+	lang := settings.Default
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		if key := ginCtx.GetString("apiKey"); key != "" {
+			if override, exists := settings.APIKeys[key]; exists {
+				lang = override
+			}
+		}
+	}
+	return util.InjectResponseLanguage(handlerType, rawJSON, lang)
+}
+
+// recordCaptureSource notes the client request for the capture middleware when capturing is
+// on. Raw (alt) responses bypass the translators and are not captured.
+func (h *BaseAPIHandler) recordCaptureSource(ctx context.Context, handlerType string, stream bool, rawJSON []byte, alt string) {
+	if !h.capturing() || alt != "" {
+		return
+	}
+	logging.RecordCaptureSource(ctx, logging.CaptureSource{Format: handlerType, Stream: stream, Request: cloneBytes(rawJSON)})
+}
+
+// recordCaptureResponse notes a response or stream chunk of a captured request as the
+// executor returned it, before the handlers change anything.
+func (h *BaseAPIHandler) recordCaptureResponse(ctx context.Context, data []byte) {
+	if h.capturing() {
+		logging.RecordCaptureResponse(ctx, data)
+	}
+}
+
+// capturing reports whether exchanges are captured for replay testing.
+func (h *BaseAPIHandler) capturing() bool {
+	return h.Cfg != nil && h.Cfg.Capture.Enable
+}
+
+// streamBufferSize returns the number of chunks buffered per stream before backpressure applies.
+func (h *BaseAPIHandler) streamBufferSize() int {
+	if h.Cfg != nil && h.Cfg.Streaming.BufferSize > 0 {
+		return h.Cfg.Streaming.BufferSize
+	}
+	return defaultStreamBufferSize
+}
+
+// slowConsumerTimeout returns how long a producer may block on a full stream buffer.
+func (h *BaseAPIHandler) slowConsumerTimeout() time.Duration {
+	if h.Cfg != nil && h.Cfg.Streaming.SlowConsumerTimeout > 0 {
+		return time.Duration(h.Cfg.Streaming.SlowConsumerTimeout) * time.Second
+	}
+	return defaultSlowConsumerTimeout
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -238,6 +749,9 @@ func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.Erro
 	if msg != nil && msg.StatusCode > 0 {
 		status = msg.StatusCode
 	}
+	if wait := retryAfter(msg); wait > 0 && (status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
 	c.Status(status)
 	if msg != nil && msg.Error != nil {
 		_, _ = c.Writer.Write([]byte(msg.Error.Error()))
@@ -246,6 +760,136 @@ func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.Erro
 	}
 }
 
+// retryAfter returns the wait advised by the error behind msg: the upstream's, or the time
+// until the auths turned away by routing are available again.
+func retryAfter(msg *interfaces.ErrorMessage) time.Duration {
+	if msg == nil || msg.Error == nil {
+		return 0
+	}
+	var authErr *coreauth.Error
+	if errors.As(msg.Error, &authErr) && authErr.RetryAfter > 0 {
+		return authErr.RetryAfter
+	}
+	return coreexecutor.RetryAfterOf(msg.Error)
+}
+
+// IsHeartbeat reports whether chunk, received from ExecuteStreamWithAuthManager, is a
+// heartbeat rather than data. Heartbeats are the only empty chunks sent.
+func IsHeartbeat(chunk []byte) bool { return len(chunk) == 0 }
+
+// PendingStreamError returns the error a stream from ExecuteStreamWithAuthManager ended
+// with, or nil when it completed. It is meant for the moment its data channel closed: the
+// error is sent before that, so it is there to be read even when the close was seen first.
+func PendingStreamError(errs <-chan *interfaces.ErrorMessage) *interfaces.ErrorMessage {
+	select {
+	case errMsg := <-errs:
+		return errMsg
+	default:
+		return nil
+	}
+}
+
+// WriteHeartbeat writes an SSE comment that keeps the client connection open while the
+// upstream is still working.
+func WriteHeartbeat(c *gin.Context, flusher http.Flusher) {
+	_, _ = c.Writer.Write([]byte(": keep-alive\n\n"))
+	flusher.Flush()
+}
+
+// WriteStreamError reports an error that ended a stream. Before anything was written it is a
+// regular error response; once the stream has started the status can no longer change, so
+// the error is sent as the terminal event of the client's dialect, which SDKs raise as an API
+// error: OpenAI gets an error chunk followed by [DONE], Claude an error event, the Responses
+// API an error event and Gemini the error object as the last chunk. The real status is kept
+// for the logs.
+func (h *BaseAPIHandler) WriteStreamError(c *gin.Context, handlerType string, msg *interfaces.ErrorMessage) {
+	if !c.Writer.Written() {
+		h.WriteErrorResponse(c, msg)
+		return
+	}
+	status := http.StatusInternalServerError
+	if msg != nil && msg.StatusCode > 0 {
+		status = msg.StatusCode
+	}
+	message := http.StatusText(status)
+	if msg != nil && msg.Error != nil {
+		message = msg.Error.Error()
+	}
+	logging.RecordStreamError(c, status, message)
+	var event string
+	switch handlerType {
+	case constant.Claude:
+		payload, _ := sjson.Set(`{"type":"error","error":{}}`, "error.type", claudeErrorType(status))
+		payload, _ = sjson.Set(payload, "error.message", message)
+		event = "event: error\ndata: " + payload + "\n\n"
+	case constant.OpenaiResponse:
+		payload, _ := sjson.Set(`{"type":"error","code":"upstream_error"}`, "message", message)
+		event = "event: error\ndata: " + payload + "\n\n"
+	case constant.Gemini, constant.GeminiCLI:
+		payload, _ := sjson.Set(`{"error":{}}`, "error.code", status)
+		payload, _ = sjson.Set(payload, "error.message", message)
+		payload, _ = sjson.Set(payload, "error.status", GeminiErrorStatus(status))
+		if h.GetAlt(c) != "" {
+			// Raw streams carry bare JSON documents rather than SSE events.
+			event = payload
+		} else {
+			event = "data: " + payload + "\n\n"
+		}
+	default:
+		payload, _ := sjson.Set(`{"error":{"type":"upstream_error"}}`, "error.message", message)
+		payload, _ = sjson.Set(payload, "error.code", status)
+		event = "data: " + payload + "\n\ndata: [DONE]\n\n"
+	}
+	_, _ = c.Writer.Write([]byte(event))
+}
+
+// claudeErrorType returns the Anthropic error type matching status.
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// GeminiErrorStatus maps an HTTP status onto the canonical google.rpc status name used in
+// Gemini error bodies.
+func GeminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
+
 func (h *BaseAPIHandler) LoggingAPIResponseError(ctx context.Context, err *interfaces.ErrorMessage) {
 	if h.Cfg.RequestLog {
 		if ginContext, ok := ctx.Value("gin").(*gin.Context); ok {
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// bodyExecutor answers Execute with a body returning data followed by err.
+type bodyExecutor struct {
+	data string
+	err  error
+}
+
+func (e bodyExecutor) Identifier() string { return "gemini" }
+
+func (e bodyExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Body: io.NopCloser(io.MultiReader(strings.NewReader(e.data), failingReader{e.err}))}, nil
+}
+
+func (e bodyExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, errors.New("not supported")
+}
+
+func (e bodyExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e bodyExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+// newTestHandler returns a handler for cfg whose auth manager holds executor and one active
+// auth of its provider, named after the provider. models are registered for that provider
+// until the test ends.
+func newTestHandler(t *testing.T, cfg *config.Config, executor coreauth.ProviderExecutor, models ...string) *BaseAPIHandler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	provider := executor.Identifier()
+	if len(models) > 0 {
+		infos := make([]*registry.ModelInfo, 0, len(models))
+		for _, model := range models {
+			infos = append(infos, &registry.ModelInfo{ID: model})
+		}
+		clientID := t.Name()
+		registry.GetGlobalRegistry().RegisterClient(clientID, provider, infos)
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(clientID) })
+	}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: provider, Provider: provider, Status: coreauth.StatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	return NewBaseAPIHandlers(cfg, manager)
+}
+
+func TestWriteNonStreamReportsBodyFailures(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    string
+		err     error
+		wantErr bool
+	}{
+		{name: "complete", data: `{"candidates":[]}`, err: io.EOF},
+		{name: "fails before data", err: errors.New("connection reset"), wantErr: true},
+		{name: "fails midway", data: `{"candidates":`, err: errors.New("connection reset")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, &config.Config{}, bodyExecutor{data: tt.data, err: tt.err}, "body-test-model")
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/body-test-model:generateContent", nil)
+			ctx := context.WithValue(context.Background(), "gin", c)
+
+			errMsg := h.WriteNonStreamWithAuthManager(c, ctx, "gemini", "body-test-model", []byte(`{"contents":[]}`), "")
+			if (errMsg != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", errMsg, tt.wantErr)
+			}
+			if errMsg != nil && errMsg.StatusCode != http.StatusBadGateway {
+				t.Fatalf("status = %d, want 502", errMsg.StatusCode)
+			}
+			if rec.Body.String() != tt.data {
+				t.Fatalf("body = %q, want %q", rec.Body.String(), tt.data)
+			}
+		})
+	}
+}
+
+// streamExecutor answers ExecuteStream with chunks.
+type streamExecutor struct {
+	bodyExecutor
+	chunks []coreexecutor.StreamChunk
+}
+
+func (e streamExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	out := make(chan coreexecutor.StreamChunk, len(e.chunks))
+	for _, chunk := range e.chunks {
+		out <- chunk
+	}
+	close(out)
+	return out, nil
+}
+
+func TestExecuteStreamRelaysHeartbeats(t *testing.T) {
+	h := newTestHandler(t, &config.Config{}, streamExecutor{chunks: []coreexecutor.StreamChunk{
+		{Heartbeat: true},
+		{Payload: []byte(`{"candidates":[]}`)},
+	}}, "heartbeat-test-model")
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/heartbeat-test-model:streamGenerateContent", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	data, errs := h.ExecuteStreamWithAuthManager(ctx, "gemini", "heartbeat-test-model", []byte(`{"contents":[]}`), "")
+	var got [][]byte
+	for chunk := range data {
+		got = append(got, chunk)
+	}
+	for errMsg := range errs {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if len(got) != 2 || !IsHeartbeat(got[0]) || IsHeartbeat(got[1]) {
+		t.Fatalf("chunks = %q, want a heartbeat followed by data", got)
+	}
+
+	WriteHeartbeat(c, c.Writer)
+	if body := rec.Body.String(); body != ": keep-alive\n\n" {
+		t.Fatalf("heartbeat written as %q", body)
+	}
+}
+
+func TestExecuteStreamErrorIsPendingWhenDataCloses(t *testing.T) {
+	h := newTestHandler(t, &config.Config{}, streamExecutor{chunks: []coreexecutor.StreamChunk{
+		{Payload: []byte(`{"candidates":[]}`)},
+		{Err: errors.New("upstream connection reset")},
+	}}, "stream-error-test-model")
+	for i := 0; i < 20; i++ {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/stream-error-test-model:streamGenerateContent", nil)
+		ctx := context.WithValue(context.Background(), "gin", c)
+
+		data, errs := h.ExecuteStreamWithAuthManager(ctx, "gemini", "stream-error-test-model", []byte(`{"contents":[]}`), "")
+		// Reading nothing but data until it closes, as a forwarder seeing the close first
+		// does, must still find the error.
+		for range data {
+		}
+		if errMsg := PendingStreamError(errs); errMsg == nil {
+			t.Fatalf("run %d: stream error lost when data closed first", i)
+		}
+	}
+}
+
+func TestExecuteStreamCancelsSlowConsumers(t *testing.T) {
+	chunks := make([]coreexecutor.StreamChunk, 4)
+	for i := range chunks {
+		chunks[i] = coreexecutor.StreamChunk{Payload: []byte(`{"candidates":[]}`)}
+	}
+	cfg := &config.Config{Streaming: config.StreamingConfig{BufferSize: 1, SlowConsumerTimeout: 1}}
+	h := newTestHandler(t, cfg, streamExecutor{chunks: chunks}, "slow-consumer-test-model")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/slow-consumer-test-model:streamGenerateContent", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	data, errs := h.ExecuteStreamWithAuthManager(ctx, "gemini", "slow-consumer-test-model", []byte(`{"contents":[]}`), "")
+	// Nothing reads data, so the producer fills the one-chunk buffer and then gives up.
+	select {
+	case errMsg := <-errs:
+		if errMsg == nil || errMsg.StatusCode != http.StatusGatewayTimeout {
+			t.Fatalf("error = %+v, want a 504 for the slow consumer", errMsg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not cancelled while its consumer stalled")
+	}
+	if got := len(data); got != 1 {
+		t.Fatalf("buffered chunks = %d, want the configured buffer size 1", got)
+	}
+}
+
+func TestExecuteStreamEchoesTheRequestedModelWhenConfigured(t *testing.T) {
+	h := newTestHandler(t, &config.Config{}, streamExecutor{chunks: []coreexecutor.StreamChunk{
+		{Payload: []byte(`{"modelVersion":"gemini-backend","candidates":[]}`)},
+	}}, "response-alias")
+	for _, tt := range []struct {
+		setting string
+		want    string
+	}{
+		{setting: "", want: "gemini-backend"},
+		{setting: "upstream", want: "gemini-backend"},
+		{setting: "requested", want: "response-alias"},
+	} {
+		h.UpdateClients(&config.Config{ResponseModelName: tt.setting})
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/response-alias:streamGenerateContent", nil)
+		ctx := context.WithValue(context.Background(), "gin", c)
+
+		data, errs := h.ExecuteStreamWithAuthManager(ctx, "gemini", "response-alias", []byte(`{"contents":[]}`), "")
+		var models []string
+		for chunk := range data {
+			models = append(models, gjson.GetBytes(chunk, "modelVersion").String())
+		}
+		if errMsg := PendingStreamError(errs); errMsg != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.setting, errMsg.Error)
+		}
+		if len(models) != 1 || models[0] != tt.want {
+			t.Fatalf("response-model-name %q: models = %q, want %q", tt.setting, models, tt.want)
+		}
+	}
+}
+
+func TestWriteErrorResponseAdvisesRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	h := NewBaseAPIHandlers(&config.Config{}, coreauth.NewManager(nil, nil, nil))
+	err := &coreauth.Error{Code: "auth_unavailable", Message: "all auths are in maintenance", HTTPStatus: http.StatusServiceUnavailable, RetryAfter: 1500 * time.Millisecond}
+
+	h.WriteErrorResponse(c, errorMessageFromExecution(err))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want 2", got)
+	}
+}
+
+func TestExecuteEmbedRejectsModelsWithoutAnEmbeddingProvider(t *testing.T) {
+	registry.GetGlobalRegistry().RegisterClient("embed-test", "claude", []*registry.ModelInfo{{ID: "embed-test-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("embed-test") })
+
+	h := NewBaseAPIHandlers(&config.Config{}, coreauth.NewManager(nil, nil, nil))
+	_, errMsg := h.ExecuteEmbedWithAuthManager(context.Background(), "gemini", "embed-test-model", []byte(`{}`))
+	if errMsg == nil || errMsg.StatusCode != http.StatusNotFound {
+		t.Fatalf("error = %+v, want 404 when no provider of the model can embed", errMsg)
+	}
+}
+
+func TestGeminiErrorStatus(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusBadRequest:          "INVALID_ARGUMENT",
+		http.StatusUnauthorized:        "UNAUTHENTICATED",
+		http.StatusForbidden:           "PERMISSION_DENIED",
+		http.StatusNotFound:            "NOT_FOUND",
+		http.StatusMethodNotAllowed:    "UNIMPLEMENTED",
+		http.StatusTooManyRequests:     "RESOURCE_EXHAUSTED",
+		http.StatusInternalServerError: "INTERNAL",
+		http.StatusNotImplemented:      "UNIMPLEMENTED",
+		http.StatusBadGateway:          "UNAVAILABLE",
+		http.StatusServiceUnavailable:  "UNAVAILABLE",
+		http.StatusGatewayTimeout:      "DEADLINE_EXCEEDED",
+		http.StatusTeapot:              "INTERNAL",
+	} {
+		if got := GeminiErrorStatus(status); got != want {
+			t.Errorf("GeminiErrorStatus(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// inputTokenSkippedKeys names request fields whose string values are not prompt text:
+// identifiers, roles and inline media, which upstreams bill differently.
+var inputTokenSkippedKeys = map[string]bool{
+	"model":      true,
+	"role":       true,
+	"type":       true,
+	"data":       true,
+	"url":        true,
+	"mimeType":   true,
+	"mime_type":  true,
+	"media_type": true,
+	"fileUri":    true,
+	"file_uri":   true,
+	"detail":     true,
+}
+
+// estimateInputTokens estimates the prompt size of a request in any client format, counting
+// the text of its string values at four characters per token like the usage estimates of
+// the Gemini Web provider.
+func estimateInputTokens(rawJSON []byte) int {
+	chars := 0
+	var walk func(value gjson.Result)
+	walk = func(value gjson.Result) {
+		switch {
+		case value.Type == gjson.String:
+			chars += utf8.RuneCountInString(value.String())
+		case value.IsObject():
+			value.ForEach(func(key, child gjson.Result) bool {
+				if !inputTokenSkippedKeys[key.String()] {
+					walk(child)
+				}
+				return true
+			})
+		case value.IsArray():
+			value.ForEach(func(_, child gjson.Result) bool {
+				walk(child)
+				return true
+			})
+		}
+	}
+	walk(gjson.ParseBytes(rawJSON))
+	return int(math.Ceil(float64(chars) / 4.0))
+}
+
+// checkInputTokens rejects a request whose estimated prompt exceeds the max-input-tokens
+// configured for model, so the client learns the limit instead of getting an upstream error.
+func checkInputTokens(model string, rawJSON []byte) *interfaces.ErrorMessage {
+	caps, ok := registry.GetGlobalRegistry().GetModelCapabilities(model)
+	if !ok || caps.MaxInputTokens <= 0 {
+		return nil
+	}
+	estimated := estimateInputTokens(rawJSON)
+	if estimated <= caps.MaxInputTokens {
+		return nil
+	}
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadRequest,
+		Error:      fmt.Errorf("input too long for model %s: about %d tokens estimated, the limit is %d", model, estimated, caps.MaxInputTokens),
+		Kind:       coreexecutor.ErrorKindInvalid,
+	}
+}
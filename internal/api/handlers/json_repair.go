@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// jsonRepairedHeader marks a response whose JSON output was repaired.
+	jsonRepairedHeader = "X-CLIProxy-JSON-Repaired"
+	// jsonTruncatedHeader marks a response whose JSON output was cut off; it is returned
+	// unchanged rather than closed.
+	jsonTruncatedHeader = "X-CLIProxy-JSON-Truncated"
+)
+
+// repairsJSON reports whether json-repair applies to the non-streaming response to rawJSON.
+func (h *BaseAPIHandler) repairsJSON(handlerType string, rawJSON []byte) bool {
+	return h.Cfg != nil && h.Cfg.JSONRepair && requestsJSONOutput(handlerType, rawJSON)
+}
+
+// repairJSONOutput repairs the answer text of a non-streaming response to a JSON mode request
+// when it does not parse as JSON. Every OpenAI choice is handled on its own; Gemini answers
+// are only repaired when they consist of a single text part.
+func (h *BaseAPIHandler) repairJSONOutput(ctx context.Context, handlerType, modelName string, rawJSON, resp []byte) []byte {
+	if !h.repairsJSON(handlerType, rawJSON) {
+		return resp
+	}
+	var paths []string
+	switch handlerType {
+	case constant.OpenAI:
+		gjson.GetBytes(resp, "choices").ForEach(func(key, choice gjson.Result) bool {
+			if choice.Get("message.content").Type == gjson.String {
+				paths = append(paths, "choices."+key.String()+".message.content")
+			}
+			return true
+		})
+	case constant.Gemini, constant.GeminiCLI:
+		root := ""
+		if gjson.GetBytes(resp, "response.candidates").Exists() {
+			root = "response."
+		}
+		var texts []string
+		gjson.GetBytes(resp, root+"candidates.0.content.parts").ForEach(func(key, part gjson.Result) bool {
+			if part.Get("text").Exists() && !part.Get("thought").Bool() {
+				texts = append(texts, root+"candidates.0.content.parts."+key.String()+".text")
+			}
+			return true
+		})
+		if len(texts) == 1 {
+			paths = texts
+		}
+	}
+	repaired, truncated := false, false
+	for _, path := range paths {
+		fixed, ok, errRepair := util.RepairJSON(gjson.GetBytes(resp, path).String())
+		if errors.Is(errRepair, util.ErrTruncatedJSON) {
+			truncated = true
+		}
+		if !ok {
+			continue
+		}
+		if out, err := sjson.SetBytes(resp, path, fixed); err == nil {
+			resp = out
+			repaired = true
+		}
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	if repaired {
+		log.Debugf("repaired JSON output of model %s", modelName)
+		if ginCtx != nil {
+			ginCtx.Header(jsonRepairedHeader, "true")
+		}
+	}
+	if truncated {
+		log.Warnf("JSON output of model %s is truncated", modelName)
+		if ginCtx != nil {
+			ginCtx.Header(jsonTruncatedHeader, "true")
+		}
+	}
+	return resp
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func TestRepairJSONOutputFlagsTruncatedOutput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &BaseAPIHandler{Cfg: &config.Config{JSONRepair: true}}
+	request := []byte(`{"response_format":{"type":"json_object"}}`)
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		header  string
+	}{
+		{name: "repaired", content: "```json\n{\"a\":1,}\n```", want: `{"a":1}`, header: jsonRepairedHeader},
+		{name: "truncated", content: `{"a":[1,2`, want: `{"a":[1,2`, header: jsonTruncatedHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			ctx := context.WithValue(context.Background(), "gin", c)
+			resp, _ := sjson.SetBytes([]byte(`{"choices":[{"message":{}}]}`), "choices.0.message.content", tt.content)
+
+			out := h.repairJSONOutput(ctx, constant.OpenAI, "test-model", request, resp)
+			if got := gjson.GetBytes(out, "choices.0.message.content").String(); got != tt.want {
+				t.Fatalf("content = %q, want %q", got, tt.want)
+			}
+			if recorder.Header().Get(tt.header) != "true" {
+				t.Fatalf("header %s not set: %v", tt.header, recorder.Header())
+			}
+		})
+	}
+}
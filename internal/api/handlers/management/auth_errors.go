@@ -0,0 +1,64 @@
+package management
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorlog"
+)
+
+// GetAuthFileErrors returns the most recent failed upstream exchanges of one auth file,
+// newest first.
+func (h *Handler) GetAuthFileErrors(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" || strings.Contains(name, string(os.PathSeparator)) {
+		c.JSON(400, gin.H{"error": "invalid name"})
+		return
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".json") {
+		c.JSON(400, gin.H{"error": "name must end with .json"})
+		return
+	}
+	entries := h.authFileErrors(name)
+	c.JSON(200, gin.H{"name": name, "errors": entries})
+}
+
+// authFileErrors collects the failures recorded for an auth file. Auths loaded by the
+// watcher are keyed by their name under the auth dir while those registered through this
+// API use the absolute path, so both IDs are consulted.
+func (h *Handler) authFileErrors(name string) []errorlog.Entry {
+	store := errorlog.Default()
+	entries := store.List(name)
+	full := filepath.Join(h.cfg.AuthDir, name)
+	if abs, errAbs := filepath.Abs(full); errAbs == nil {
+		full = abs
+	}
+	if full != name {
+		entries = append(entries, store.List(full)...)
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	}
+	if len(entries) > errorlog.DefaultCapacity {
+		entries = entries[:errorlog.DefaultCapacity]
+	}
+	return entries
+}
+
+// authFileErrorSummary condenses the failures of an auth file for ListAuthFiles.
+func (h *Handler) authFileErrorSummary(name string, now time.Time) errorlog.Summary {
+	var summary errorlog.Summary
+	cutoff := now.Add(-time.Hour)
+	for i, entry := range h.authFileErrors(name) {
+		if i == 0 {
+			last := entry
+			summary.LastError = &last
+		}
+		if entry.Time.After(cutoff) {
+			summary.LastHour++
+		}
+	}
+	return summary
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
 	geminiAuth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/qwen"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorlog"
 	// legacy client removed
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -31,10 +32,6 @@ import (
 	"golang.org/x/oauth2/google"
 )
 
-var (
-	oauthStatus = make(map[string]string)
-)
-
 var lastRefreshKeys = []string{"last_refresh", "lastRefresh", "last_refreshed_at", "lastRefreshedAt"}
 
 func extractLastRefreshTimestamp(meta map[string]any) (time.Time, bool) {
@@ -98,6 +95,7 @@ func (h *Handler) ListAuthFiles(c *gin.Context) {
 		return
 	}
 	files := make([]gin.H, 0)
+	now := time.Now()
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
@@ -114,6 +112,15 @@ func (h *Handler) ListAuthFiles(c *gin.Context) {
 			if data, errRead := os.ReadFile(full); errRead == nil {
 				typeValue := gjson.GetBytes(data, "type").String()
 				fileData["type"] = typeValue
+				fileData["disabled"] = gjson.GetBytes(data, coreauth.MetadataDisabledKey).Bool()
+				if label := gjson.GetBytes(data, coreauth.MetadataLabelKey).String(); label != "" {
+					fileData["label"] = label
+				}
+				fileData["priority"] = gjson.GetBytes(data, coreauth.MetadataPriorityKey).Int()
+			}
+			if summary := h.authFileErrorSummary(name, now); summary.LastError != nil {
+				fileData["last_error"] = summary.LastError
+				fileData["errors_last_hour"] = summary.LastHour
 			}
 
 			files = append(files, fileData)
@@ -317,6 +324,8 @@ func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []
 	if hasLastRefresh {
 		auth.LastRefreshedAt = lastRefresh
 	}
+	auth.ApplyMetadataRouting()
+	auth.ApplyMetadataEndpoint()
 	if existing, ok := h.authManager.GetByID(path); ok {
 		auth.CreatedAt = existing.CreatedAt
 		if !hasLastRefresh {
@@ -335,6 +344,7 @@ func (h *Handler) disableAuth(ctx context.Context, id string) {
 	if h.authManager == nil || id == "" {
 		return
 	}
+	errorlog.Default().Forget(id)
 	if auth, ok := h.authManager.GetByID(id); ok {
 		auth.Disabled = true
 		auth.Status = coreauth.StatusDisabled
@@ -344,6 +354,114 @@ func (h *Handler) disableAuth(ctx context.Context, id string) {
 	}
 }
 
+// DisableAuthFile takes an auth out of rotation without deleting its file. The flag is
+// written to the auth file, so the auth stays disabled after a restart.
+func (h *Handler) DisableAuthFile(c *gin.Context) { h.setAuthFileDisabled(c, true) }
+
+// EnableAuthFile puts an auth disabled with DisableAuthFile back into rotation.
+func (h *Handler) EnableAuthFile(c *gin.Context) { h.setAuthFileDisabled(c, false) }
+
+func (h *Handler) setAuthFileDisabled(c *gin.Context, disabled bool) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	id := h.authFileID(body.Name)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	auth, ok := h.authManager.GetByID(id)
+	if !ok || auth.Metadata == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth file not found"})
+		return
+	}
+
+	if disabled {
+		auth.Metadata[coreauth.MetadataDisabledKey] = true
+		auth.Disabled = true
+		auth.Status = coreauth.StatusDisabled
+		auth.StatusMessage = "disabled via management API"
+	} else {
+		delete(auth.Metadata, coreauth.MetadataDisabledKey)
+		auth.Disabled = false
+		auth.Status = coreauth.StatusActive
+		auth.StatusMessage = ""
+	}
+	auth.UpdatedAt = time.Now()
+	if _, err := h.authManager.Update(c.Request.Context(), auth); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update auth: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": filepath.Base(body.Name), "disabled": disabled})
+}
+
+// PatchAuthFile sets the label and routing priority of an auth file. Both are saved in the
+// file so they survive a restart; an empty label restores the default one.
+func (h *Handler) PatchAuthFile(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		Name     string  `json:"name"`
+		Label    *string `json:"label"`
+		Priority *int    `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	id := h.authFileID(body.Name)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if body.Label == nil && body.Priority == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label or priority is required"})
+		return
+	}
+	auth, ok := h.authManager.GetByID(id)
+	if !ok || auth.Metadata == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth file not found"})
+		return
+	}
+
+	if body.Label != nil {
+		if label := strings.TrimSpace(*body.Label); label != "" {
+			auth.Metadata[coreauth.MetadataLabelKey] = label
+			auth.Label = label
+		} else {
+			delete(auth.Metadata, coreauth.MetadataLabelKey)
+			auth.Label = auth.Provider
+			if email, _ := auth.Metadata["email"].(string); email != "" {
+				auth.Label = email
+			}
+		}
+	}
+	if body.Priority != nil {
+		if *body.Priority != 0 {
+			auth.Metadata[coreauth.MetadataPriorityKey] = *body.Priority
+		} else {
+			delete(auth.Metadata, coreauth.MetadataPriorityKey)
+		}
+		auth.Priority = *body.Priority
+	}
+	auth.UpdatedAt = time.Now()
+	if _, err := h.authManager.Update(c.Request.Context(), auth); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save auth: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": filepath.Base(body.Name), "label": auth.AssignedLabel(), "priority": auth.Priority})
+}
+
 func (h *Handler) saveTokenRecord(ctx context.Context, record *sdkAuth.TokenRecord) (string, error) {
 	if record == nil {
 		return "", fmt.Errorf("token record is nil")
@@ -357,6 +475,9 @@ func (h *Handler) saveTokenRecord(ctx context.Context, record *sdkAuth.TokenReco
 }
 
 func (h *Handler) RequestAnthropicToken(c *gin.Context) {
+	if !admitOAuthFlow(c, "anthropic") {
+		return
+	}
 	ctx := context.Background()
 
 	fmt.Println("Initializing Claude authentication...")
@@ -386,6 +507,8 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 	}
 	// Override redirect_uri in authorization URL to current server port
 
+	oauthSessions.start(state, "anthropic", managementPrincipal(c), authURL, h.cfg.AuthDir)
+
 	go func() {
 		// Helper: wait for callback file
 		waitFile := filepath.Join(h.cfg.AuthDir, fmt.Sprintf(".oauth-anthropic-%s.oauth", state))
@@ -393,7 +516,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 			deadline := time.Now().Add(timeout)
 			for {
 				if time.Now().After(deadline) {
-					oauthStatus[state] = "Timeout waiting for OAuth callback"
+					oauthSessions.fail(state, "Timeout waiting for OAuth callback")
 					return nil, fmt.Errorf("timeout waiting for OAuth callback")
 				}
 				data, errRead := os.ReadFile(path)
@@ -408,8 +531,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 		}
 
 		fmt.Println("Waiting for authentication callback...")
-		// Wait up to 5 minutes
-		resultMap, errWait := waitForFile(waitFile, 5*time.Minute)
+		resultMap, errWait := waitForFile(waitFile, oauthFlowTimeout)
 		if errWait != nil {
 			authErr := claude.NewAuthenticationError(claude.ErrCallbackTimeout, errWait)
 			log.Error(claude.GetUserFriendlyMessage(authErr))
@@ -418,13 +540,13 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 		if errStr := resultMap["error"]; errStr != "" {
 			oauthErr := claude.NewOAuthError(errStr, "", http.StatusBadRequest)
 			log.Error(claude.GetUserFriendlyMessage(oauthErr))
-			oauthStatus[state] = "Bad request"
+			oauthSessions.fail(state, "Bad request")
 			return
 		}
 		if resultMap["state"] != state {
 			authErr := claude.NewAuthenticationError(claude.ErrInvalidState, fmt.Errorf("expected %s, got %s", state, resultMap["state"]))
 			log.Error(claude.GetUserFriendlyMessage(authErr))
-			oauthStatus[state] = "State code error"
+			oauthSessions.fail(state, "State code error")
 			return
 		}
 
@@ -457,7 +579,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 		if errDo != nil {
 			authErr := claude.NewAuthenticationError(claude.ErrCodeExchangeFailed, errDo)
 			log.Errorf("Failed to exchange authorization code for tokens: %v", authErr)
-			oauthStatus[state] = "Failed to exchange authorization code for tokens"
+			oauthSessions.fail(state, "Failed to exchange authorization code for tokens")
 			return
 		}
 		defer func() {
@@ -468,7 +590,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 		respBody, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode != http.StatusOK {
 			log.Errorf("token exchange failed with status %d: %s", resp.StatusCode, string(respBody))
-			oauthStatus[state] = fmt.Sprintf("token exchange failed with status %d", resp.StatusCode)
+			oauthSessions.fail(state, fmt.Sprintf("token exchange failed with status %d", resp.StatusCode))
 			return
 		}
 		var tResp struct {
@@ -481,7 +603,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 		}
 		if errU := json.Unmarshal(respBody, &tResp); errU != nil {
 			log.Errorf("failed to parse token response: %v", errU)
-			oauthStatus[state] = "Failed to parse token response"
+			oauthSessions.fail(state, "Failed to parse token response")
 			return
 		}
 		bundle := &claude.ClaudeAuthBundle{
@@ -505,7 +627,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 		savedPath, errSave := h.saveTokenRecord(ctx, record)
 		if errSave != nil {
 			log.Fatalf("Failed to save authentication tokens: %v", errSave)
-			oauthStatus[state] = "Failed to save authentication tokens"
+			oauthSessions.fail(state, "Failed to save authentication tokens")
 			return
 		}
 
@@ -514,14 +636,16 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 			fmt.Println("API key obtained and saved")
 		}
 		fmt.Println("You can now use Claude services through this CLI")
-		delete(oauthStatus, state)
+		oauthSessions.complete(state)
 	}()
 
-	oauthStatus[state] = ""
 	c.JSON(200, gin.H{"status": "ok", "url": authURL, "state": state})
 }
 
 func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
+	if !admitOAuthFlow(c, "gemini") {
+		return
+	}
 	ctx := context.Background()
 
 	// Optional project ID from query
@@ -546,16 +670,18 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 	state := fmt.Sprintf("gem-%d", time.Now().UnixNano())
 	authURL := conf.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
 
+	oauthSessions.start(state, "gemini", managementPrincipal(c), authURL, h.cfg.AuthDir)
+
 	go func() {
 		// Wait for callback file written by server route
 		waitFile := filepath.Join(h.cfg.AuthDir, fmt.Sprintf(".oauth-gemini-%s.oauth", state))
 		fmt.Println("Waiting for authentication callback...")
-		deadline := time.Now().Add(5 * time.Minute)
+		deadline := time.Now().Add(oauthFlowTimeout)
 		var authCode string
 		for {
 			if time.Now().After(deadline) {
 				log.Error("oauth flow timed out")
-				oauthStatus[state] = "OAuth flow timed out"
+				oauthSessions.fail(state, "OAuth flow timed out")
 				return
 			}
 			if data, errR := os.ReadFile(waitFile); errR == nil {
@@ -564,13 +690,13 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 				_ = os.Remove(waitFile)
 				if errStr := m["error"]; errStr != "" {
 					log.Errorf("Authentication failed: %s", errStr)
-					oauthStatus[state] = "Authentication failed"
+					oauthSessions.fail(state, "Authentication failed")
 					return
 				}
 				authCode = m["code"]
 				if authCode == "" {
 					log.Errorf("Authentication failed: code not found")
-					oauthStatus[state] = "Authentication failed: code not found"
+					oauthSessions.fail(state, "Authentication failed: code not found")
 					return
 				}
 				break
@@ -582,7 +708,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		token, err := conf.Exchange(ctx, authCode)
 		if err != nil {
 			log.Errorf("Failed to exchange token: %v", err)
-			oauthStatus[state] = "Failed to exchange token"
+			oauthSessions.fail(state, "Failed to exchange token")
 			return
 		}
 
@@ -591,7 +717,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		req, errNewRequest := http.NewRequestWithContext(ctx, "GET", "https://www.googleapis.com/oauth2/v1/userinfo?alt=json", nil)
 		if errNewRequest != nil {
 			log.Errorf("Could not get user info: %v", errNewRequest)
-			oauthStatus[state] = "Could not get user info"
+			oauthSessions.fail(state, "Could not get user info")
 			return
 		}
 		req.Header.Set("Content-Type", "application/json")
@@ -600,7 +726,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		resp, errDo := httpClient.Do(req)
 		if errDo != nil {
 			log.Errorf("Failed to execute request: %v", errDo)
-			oauthStatus[state] = "Failed to execute request"
+			oauthSessions.fail(state, "Failed to execute request")
 			return
 		}
 		defer func() {
@@ -612,7 +738,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 			log.Errorf("Get user info request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-			oauthStatus[state] = fmt.Sprintf("Get user info request failed with status %d", resp.StatusCode)
+			oauthSessions.fail(state, fmt.Sprintf("Get user info request failed with status %d", resp.StatusCode))
 			return
 		}
 
@@ -620,8 +746,8 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		if email != "" {
 			fmt.Printf("Authenticated user email: %s\n", email)
 		} else {
-			fmt.Println("Failed to get user email from token")
-			oauthStatus[state] = "Failed to get user email from token"
+			// The token is still saved, so the login does not fail over a missing email.
+			log.Warn("Failed to get user email from token")
 		}
 
 		// Marshal/unmarshal oauth2.Token to generic map and enrich fields
@@ -629,7 +755,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		jsonData, _ := json.Marshal(token)
 		if errUnmarshal := json.Unmarshal(jsonData, &ifToken); errUnmarshal != nil {
 			log.Errorf("Failed to unmarshal token: %v", errUnmarshal)
-			oauthStatus[state] = "Failed to unmarshal token"
+			oauthSessions.fail(state, "Failed to unmarshal token")
 			return
 		}
 
@@ -654,7 +780,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		_, errGetClient := gemAuth.GetAuthenticatedClient(ctx, &ts, h.cfg, true)
 		if errGetClient != nil {
 			log.Fatalf("failed to get authenticated client: %v", errGetClient)
-			oauthStatus[state] = "Failed to get authenticated client"
+			oauthSessions.fail(state, "Failed to get authenticated client")
 			return
 		}
 		fmt.Println("Authentication successful.")
@@ -671,15 +797,14 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 		savedPath, errSave := h.saveTokenRecord(ctx, record)
 		if errSave != nil {
 			log.Fatalf("Failed to save token to file: %v", errSave)
-			oauthStatus[state] = "Failed to save token to file"
+			oauthSessions.fail(state, "Failed to save token to file")
 			return
 		}
 
-		delete(oauthStatus, state)
+		oauthSessions.complete(state)
 		fmt.Printf("You can now use Gemini CLI services through this CLI; token saved to %s\n", savedPath)
 	}()
 
-	oauthStatus[state] = ""
 	c.JSON(200, gin.H{"status": "ok", "url": authURL, "state": state})
 }
 
@@ -742,6 +867,9 @@ func (h *Handler) CreateGeminiWebToken(c *gin.Context) {
 }
 
 func (h *Handler) RequestCodexToken(c *gin.Context) {
+	if !admitOAuthFlow(c, "codex") {
+		return
+	}
 	ctx := context.Background()
 
 	fmt.Println("Initializing Codex authentication...")
@@ -770,16 +898,18 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 		return
 	}
 
+	oauthSessions.start(state, "codex", managementPrincipal(c), authURL, h.cfg.AuthDir)
+
 	go func() {
 		// Wait for callback file
 		waitFile := filepath.Join(h.cfg.AuthDir, fmt.Sprintf(".oauth-codex-%s.oauth", state))
-		deadline := time.Now().Add(5 * time.Minute)
+		deadline := time.Now().Add(oauthFlowTimeout)
 		var code string
 		for {
 			if time.Now().After(deadline) {
 				authErr := codex.NewAuthenticationError(codex.ErrCallbackTimeout, fmt.Errorf("timeout waiting for OAuth callback"))
 				log.Error(codex.GetUserFriendlyMessage(authErr))
-				oauthStatus[state] = "Timeout waiting for OAuth callback"
+				oauthSessions.fail(state, "Timeout waiting for OAuth callback")
 				return
 			}
 			if data, errR := os.ReadFile(waitFile); errR == nil {
@@ -789,12 +919,12 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 				if errStr := m["error"]; errStr != "" {
 					oauthErr := codex.NewOAuthError(errStr, "", http.StatusBadRequest)
 					log.Error(codex.GetUserFriendlyMessage(oauthErr))
-					oauthStatus[state] = "Bad Request"
+					oauthSessions.fail(state, "Bad Request")
 					return
 				}
 				if m["state"] != state {
 					authErr := codex.NewAuthenticationError(codex.ErrInvalidState, fmt.Errorf("expected %s, got %s", state, m["state"]))
-					oauthStatus[state] = "State code error"
+					oauthSessions.fail(state, "State code error")
 					log.Error(codex.GetUserFriendlyMessage(authErr))
 					return
 				}
@@ -825,14 +955,14 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 		resp, errDo := httpClient.Do(req)
 		if errDo != nil {
 			authErr := codex.NewAuthenticationError(codex.ErrCodeExchangeFailed, errDo)
-			oauthStatus[state] = "Failed to exchange authorization code for tokens"
+			oauthSessions.fail(state, "Failed to exchange authorization code for tokens")
 			log.Errorf("Failed to exchange authorization code for tokens: %v", authErr)
 			return
 		}
 		defer func() { _ = resp.Body.Close() }()
 		respBody, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode != http.StatusOK {
-			oauthStatus[state] = fmt.Sprintf("Token exchange failed with status %d", resp.StatusCode)
+			oauthSessions.fail(state, fmt.Sprintf("Token exchange failed with status %d", resp.StatusCode))
 			log.Errorf("token exchange failed with status %d: %s", resp.StatusCode, string(respBody))
 			return
 		}
@@ -843,7 +973,7 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 			ExpiresIn    int    `json:"expires_in"`
 		}
 		if errU := json.Unmarshal(respBody, &tokenResp); errU != nil {
-			oauthStatus[state] = "Failed to parse token response"
+			oauthSessions.fail(state, "Failed to parse token response")
 			log.Errorf("failed to parse token response: %v", errU)
 			return
 		}
@@ -880,7 +1010,7 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 		}
 		savedPath, errSave := h.saveTokenRecord(ctx, record)
 		if errSave != nil {
-			oauthStatus[state] = "Failed to save authentication tokens"
+			oauthSessions.fail(state, "Failed to save authentication tokens")
 			log.Fatalf("Failed to save authentication tokens: %v", errSave)
 			return
 		}
@@ -889,14 +1019,16 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 			fmt.Println("API key obtained and saved")
 		}
 		fmt.Println("You can now use Codex services through this CLI")
-		delete(oauthStatus, state)
+		oauthSessions.complete(state)
 	}()
 
-	oauthStatus[state] = ""
 	c.JSON(200, gin.H{"status": "ok", "url": authURL, "state": state})
 }
 
 func (h *Handler) RequestQwenToken(c *gin.Context) {
+	if !admitOAuthFlow(c, "qwen") {
+		return
+	}
 	ctx := context.Background()
 
 	fmt.Println("Initializing Qwen authentication...")
@@ -913,11 +1045,13 @@ func (h *Handler) RequestQwenToken(c *gin.Context) {
 	}
 	authURL := deviceFlow.VerificationURIComplete
 
+	oauthSessions.start(state, "qwen", managementPrincipal(c), authURL, h.cfg.AuthDir)
+
 	go func() {
 		fmt.Println("Waiting for authentication...")
 		tokenData, errPollForToken := qwenAuth.PollForToken(deviceFlow.DeviceCode, deviceFlow.CodeVerifier)
 		if errPollForToken != nil {
-			oauthStatus[state] = "Authentication failed"
+			oauthSessions.fail(state, "Authentication failed")
 			fmt.Printf("Authentication failed: %v\n", errPollForToken)
 			return
 		}
@@ -935,30 +1069,32 @@ func (h *Handler) RequestQwenToken(c *gin.Context) {
 		savedPath, errSave := h.saveTokenRecord(ctx, record)
 		if errSave != nil {
 			log.Fatalf("Failed to save authentication tokens: %v", errSave)
-			oauthStatus[state] = "Failed to save authentication tokens"
+			oauthSessions.fail(state, "Failed to save authentication tokens")
 			return
 		}
 
 		fmt.Printf("Authentication successful! Token saved to %s\n", savedPath)
 		fmt.Println("You can now use Qwen services through this CLI")
-		delete(oauthStatus, state)
+		oauthSessions.complete(state)
 	}()
 
-	oauthStatus[state] = ""
 	c.JSON(200, gin.H{"status": "ok", "url": authURL, "state": state})
 }
 
 func (h *Handler) GetAuthStatus(c *gin.Context) {
 	state := c.Query("state")
-	if err, ok := oauthStatus[state]; ok {
-		if err != "" {
-			c.JSON(200, gin.H{"status": "error", "error": err})
-		} else {
-			c.JSON(200, gin.H{"status": "wait"})
-			return
-		}
-	} else {
-		c.JSON(200, gin.H{"status": "ok"})
+	session, ok := oauthSessions.get(state)
+	if !ok || session.Principal != managementPrincipal(c) {
+		c.JSON(404, gin.H{"status": oauthStatusError, "error": "unknown or expired state"})
+		return
+	}
+	resp := gin.H{
+		"status":     session.Status,
+		"provider":   session.Provider,
+		"created_at": session.CreatedAt,
+	}
+	if session.Error != "" {
+		resp["error"] = session.Error
 	}
-	delete(oauthStatus, state)
+	c.JSON(200, resp)
 }
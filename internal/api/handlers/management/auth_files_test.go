@@ -0,0 +1,67 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// saveStore is an auth store whose saves fail with err.
+type saveStore struct{ err error }
+
+func (s saveStore) List(context.Context) ([]*coreauth.Auth, error) { return nil, nil }
+func (s saveStore) SaveAuth(context.Context, *coreauth.Auth) error { return s.err }
+func (s saveStore) Delete(context.Context, string) error           { return nil }
+
+func patchAuthFile(t *testing.T, store coreauth.Store, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	manager := coreauth.NewManager(store, nil, nil)
+	auth := &coreauth.Auth{
+		ID:       "acc1.json",
+		Provider: "claude",
+		Label:    "user@example.com",
+		Status:   coreauth.StatusActive,
+		Metadata: map[string]any{"email": "user@example.com"},
+	}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(&config.Config{AuthDir: t.TempDir()}, "", manager)
+	engine := gin.New()
+	engine.PATCH("/auth-files", h.PatchAuthFile)
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/auth-files", strings.NewReader(body)))
+	return rec
+}
+
+func TestPatchAuthFileReportsSaveFailure(t *testing.T) {
+	rec := patchAuthFile(t, saveStore{err: errors.New("disk full")}, `{"name":"acc1.json","priority":5}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500; body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPatchAuthFileClearedLabelIsNotTheEmail(t *testing.T) {
+	rec := patchAuthFile(t, saveStore{}, `{"name":"acc1.json","label":""}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Label string `json:"label"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Label != "" {
+		t.Fatalf("label = %q, want none", resp.Label)
+	}
+}
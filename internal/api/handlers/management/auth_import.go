@@ -0,0 +1,250 @@
+package management
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImportArchiveSize bounds the zip archive accepted by ImportAuthFiles.
+	maxImportArchiveSize = 32 << 20
+	// maxImportEntries bounds the number of files in one archive.
+	maxImportEntries = 500
+	// maxImportEntrySize bounds the uncompressed size of a single auth file.
+	maxImportEntrySize = 1 << 20
+
+	importStatusImported  = "imported"
+	importStatusValid     = "valid"
+	importStatusDuplicate = "skipped-duplicate"
+	importStatusFailed    = "failed"
+)
+
+// authFileRequiredFields lists fields an auth file of a given type must carry.
+var authFileRequiredFields = map[string][]string{
+	"claude":     {"access_token"},
+	"codex":      {"access_token"},
+	"qwen":       {"access_token"},
+	"gemini":     {"token"},
+	"gemini-web": {"secure_1psid", "secure_1psidts"},
+}
+
+// authImportResult reports the outcome for one archive entry.
+type authImportResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ImportAuthFiles imports every auth file contained in a zip archive, sent either as the
+// multipart field "file" or as the raw request body. Each entry is validated and written on
+// its own, so one bad file does not abort the import. With ?dry_run=true nothing is written.
+func (h *Handler) ImportAuthFiles(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true" || c.Query("dry_run") == "1"
+
+	var src io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil && file != nil {
+		f, errOpen := file.Open()
+		if errOpen != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("failed to open upload: %v", errOpen)})
+			return
+		}
+		defer func() { _ = f.Close() }()
+		src = f
+	}
+	data, err := io.ReadAll(io.LimitReader(src, maxImportArchiveSize+1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	if len(data) > maxImportArchiveSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("archive exceeds %d bytes", maxImportArchiveSize)})
+		return
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("invalid zip archive: %v", err)})
+		return
+	}
+	if len(archive.File) > maxImportEntries {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("archive has %d entries, limit is %d", len(archive.File), maxImportEntries)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := make([]authImportResult, 0, len(archive.File))
+	seen := make(map[string]struct{}, len(archive.File))
+	counts := map[string]int{}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		result := h.importAuthEntry(ctx, entry, seen, dryRun)
+		counts[result.Status]++
+		results = append(results, result)
+	}
+	c.JSON(200, gin.H{
+		"dry_run":  dryRun,
+		"imported": counts[importStatusImported] + counts[importStatusValid],
+		"skipped":  counts[importStatusDuplicate],
+		"failed":   counts[importStatusFailed],
+		"results":  results,
+	})
+}
+
+func (h *Handler) importAuthEntry(ctx context.Context, entry *zip.File, seen map[string]struct{}, dryRun bool) authImportResult {
+	result := authImportResult{Name: entry.Name}
+	failed := func(reason string) authImportResult {
+		result.Status = importStatusFailed
+		result.Reason = reason
+		return result
+	}
+
+	name, err := safeArchiveName(entry.Name)
+	if err != nil {
+		return failed(err.Error())
+	}
+	result.Name = name
+	if entry.UncompressedSize64 > maxImportEntrySize {
+		return failed(fmt.Sprintf("file exceeds %d bytes", maxImportEntrySize))
+	}
+	rc, err := entry.Open()
+	if err != nil {
+		return failed(fmt.Sprintf("failed to open entry: %v", err))
+	}
+	content, err := io.ReadAll(io.LimitReader(rc, maxImportEntrySize+1))
+	_ = rc.Close()
+	if err != nil {
+		return failed(fmt.Sprintf("failed to read entry: %v", err))
+	}
+	if len(content) > maxImportEntrySize {
+		return failed(fmt.Sprintf("file exceeds %d bytes", maxImportEntrySize))
+	}
+	if err = validateAuthFile(content); err != nil {
+		return failed(err.Error())
+	}
+
+	if _, dup := seen[name]; dup {
+		result.Status = importStatusDuplicate
+		result.Reason = "duplicate name in archive"
+		return result
+	}
+	seen[name] = struct{}{}
+	dst := filepath.Join(h.cfg.AuthDir, name)
+	if !filepath.IsAbs(dst) {
+		if abs, errAbs := filepath.Abs(dst); errAbs == nil {
+			dst = abs
+		}
+	}
+	if _, errStat := os.Stat(dst); errStat == nil {
+		result.Status = importStatusDuplicate
+		result.Reason = "auth file already exists"
+		return result
+	}
+
+	if dryRun {
+		result.Status = importStatusValid
+		return result
+	}
+	if err = writeFileAtomic(dst, content); err != nil {
+		return failed(fmt.Sprintf("failed to write file: %v", err))
+	}
+	if err = h.registerAuthFromFile(ctx, dst, content); err != nil {
+		_ = os.Remove(dst)
+		return failed(err.Error())
+	}
+	result.Status = importStatusImported
+	return result
+}
+
+// safeArchiveName returns the file name an archive entry is written under, rejecting
+// absolute paths and parent directory traversal (zip-slip).
+func safeArchiveName(name string) (string, error) {
+	normalized := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(normalized, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("absolute paths are not allowed")
+	}
+	for _, part := range strings.Split(normalized, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("path traversal is not allowed")
+		}
+	}
+	base := path.Base(normalized)
+	if base == "." || base == "/" || strings.HasPrefix(base, ".") {
+		return "", fmt.Errorf("invalid file name")
+	}
+	if !strings.HasSuffix(strings.ToLower(base), ".json") {
+		return "", fmt.Errorf("name must end with .json")
+	}
+	return base, nil
+}
+
+// validateAuthFile checks that content is a JSON auth file with a known type and the
+// fields that type requires.
+func validateAuthFile(content []byte) error {
+	metadata := make(map[string]any)
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return fmt.Errorf("invalid auth file: %w", err)
+	}
+	provider, _ := metadata["type"].(string)
+	if strings.TrimSpace(provider) == "" {
+		return fmt.Errorf("missing type field")
+	}
+	required, known := authFileRequiredFields[provider]
+	if !known {
+		return fmt.Errorf("unsupported type %q", provider)
+	}
+	for _, field := range required {
+		value, ok := metadata[field]
+		if !ok || value == nil {
+			return fmt.Errorf("missing required field %q", field)
+		}
+		if s, isString := value.(string); isString && strings.TrimSpace(s) == "" {
+			return fmt.Errorf("missing required field %q", field)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes data next to dst and renames it into place so readers never see
+// a partially written auth file.
+func writeFileAtomic(dst string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".import-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = os.Rename(tmpName, dst); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package management
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// capabilityDialects lists the request formats the API handlers accept.
+var capabilityDialects = []string{
+	constant.OpenAI,
+	constant.OpenaiResponse,
+	constant.Claude,
+	constant.Gemini,
+	constant.GeminiCLI,
+}
+
+// providerCapabilities is one provider of the capabilities matrix with the client dialects
+// that can reach it.
+type providerCapabilities struct {
+	registry.ProviderCapabilities
+	Dialects []string `json:"dialects"`
+}
+
+// publicProviderCapabilities is the reduced view served by /v1/capabilities, without models
+// or account counts.
+type publicProviderCapabilities struct {
+	Provider  string                    `json:"provider"`
+	Available bool                      `json:"available"`
+	Features  registry.ProviderFeatures `json:"features"`
+	Dialects  []string                  `json:"dialects"`
+}
+
+// capabilityMatrix returns the capabilities of every known provider. With an auth manager the
+// account counts are those of the accounts able to serve a request now rather than of every
+// registered one, so a provider whose accounts are all disabled or cooling down counts none.
+func capabilityMatrix(manager *coreauth.Manager) []providerCapabilities {
+	matrix := registry.GetGlobalRegistry().Capabilities()
+	var available map[string]int
+	if manager != nil {
+		available = manager.AvailableAuths()
+	}
+	out := make([]providerCapabilities, 0, len(matrix))
+	for _, entry := range matrix {
+		if available != nil {
+			entry.Auths = available[entry.Provider]
+		}
+		out = append(out, providerCapabilities{ProviderCapabilities: entry, Dialects: reachableDialects(entry.Features)})
+	}
+	return out
+}
+
+// reachableDialects returns the client dialects whose requests and responses can be
+// converted for a provider with features.
+func reachableDialects(features registry.ProviderFeatures) []string {
+	dialects := make([]string, 0, len(capabilityDialects))
+	if features.Format == "" {
+		return dialects
+	}
+	to := sdktranslator.FromString(features.Format)
+	for _, dialect := range capabilityDialects {
+		from := sdktranslator.FromString(dialect)
+		switch {
+		case dialect == features.Format, slices.Contains(features.NativeFormats, dialect):
+		case sdktranslator.HasRequestTransformer(from, to) && sdktranslator.HasResponseTransformer(from, to):
+		default:
+			continue
+		}
+		dialects = append(dialects, dialect)
+	}
+	return dialects
+}
+
+// GetCapabilities returns the feature matrix of every provider and its models, with the
+// number of accounts currently available for each.
+func (h *Handler) GetCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": capabilityMatrix(h.authManager)})
+}
+
+// GetPublicCapabilities serves /v1/capabilities when public-capabilities is enabled: the
+// features and dialects of each provider and whether any account can serve it now.
+func (h *Handler) GetPublicCapabilities(c *gin.Context) {
+	if h.cfg == nil || !h.cfg.PublicCapabilities {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	matrix := capabilityMatrix(h.authManager)
+	out := make([]publicProviderCapabilities, 0, len(matrix))
+	for _, entry := range matrix {
+		out = append(out, publicProviderCapabilities{
+			Provider:  entry.Provider,
+			Available: entry.Auths > 0,
+			Features:  entry.Features,
+			Dialects:  entry.Dialects,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": out})
+}
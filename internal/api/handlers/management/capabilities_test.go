@@ -0,0 +1,77 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestCapabilitiesCountAvailableAuths(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := registry.GetGlobalRegistry()
+	manager := coreauth.NewManager(nil, nil, nil)
+	for _, auth := range []*coreauth.Auth{
+		{ID: "cap-ready", Provider: "cap-test", Status: coreauth.StatusActive},
+		{ID: "cap-disabled", Provider: "cap-test", Status: coreauth.StatusDisabled, Disabled: true},
+		{ID: "cap-off", Provider: "cap-off", Status: coreauth.StatusDisabled, Disabled: true},
+	} {
+		if _, err := manager.Register(context.Background(), auth); err != nil {
+			t.Fatal(err)
+		}
+		reg.RegisterClient(auth.ID, auth.Provider, nil)
+		t.Cleanup(func() { reg.UnregisterClient(auth.ID) })
+	}
+	h := NewHandler(&config.Config{PublicCapabilities: true}, "", manager)
+	engine := gin.New()
+	engine.GET("/capabilities", h.GetCapabilities)
+	engine.GET("/v1/capabilities", h.GetPublicCapabilities)
+
+	var full struct {
+		Providers []struct {
+			Provider string `json:"provider"`
+			Auths    int    `json:"auths"`
+		} `json:"providers"`
+	}
+	serve(t, engine, "/capabilities", &full)
+	auths := map[string]int{}
+	for _, entry := range full.Providers {
+		auths[entry.Provider] = entry.Auths
+	}
+	if auths["cap-test"] != 1 || auths["cap-off"] != 0 {
+		t.Errorf("auths = %v, want cap-test 1 and cap-off 0", auths)
+	}
+
+	var public struct {
+		Providers []struct {
+			Provider  string `json:"provider"`
+			Available bool   `json:"available"`
+		} `json:"providers"`
+	}
+	serve(t, engine, "/v1/capabilities", &public)
+	available := map[string]bool{}
+	for _, entry := range public.Providers {
+		available[entry.Provider] = entry.Available
+	}
+	if !available["cap-test"] || available["cap-off"] {
+		t.Errorf("available = %v, want cap-test only", available)
+	}
+}
+
+func serve(t *testing.T, engine *gin.Engine, path string, out any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s status = %d; body %s", path, rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,165 @@
+package management
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// geminiWebAuth resolves the Gemini Web auth named by the query parameter param, writing an
+// error response and returning nil when there is none.
+func (h *Handler) geminiWebAuth(c *gin.Context, param string) *coreauth.Auth {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return nil
+	}
+	id := h.authFileID(c.Query(param))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": param + " is required"})
+		return nil
+	}
+	auth, ok := h.authManager.GetByID(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth not found"})
+		return nil
+	}
+	if auth.Provider != "gemini-web" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not a gemini-web auth"})
+		return nil
+	}
+	return auth
+}
+
+// RotateGeminiWebCookies rotates the __Secure-1PSIDTS cookie of a Gemini Web account now and
+// saves it to the auth file. The request waits for the account's request in flight.
+func (h *Handler) RotateGeminiWebCookies(c *gin.Context) {
+	auth := h.geminiWebAuth(c, "name")
+	if auth == nil {
+		return
+	}
+	started := time.Now()
+	updated, rotated, err := executor.NewGeminiWebExecutor(h.cfg).RotateCookies(auth)
+	elapsed := time.Since(started)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "elapsed_ms": elapsed.Milliseconds()})
+		return
+	}
+	if rotated {
+		if _, err = h.authManager.Update(c.Request.Context(), updated); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save auth: " + err.Error()})
+			return
+		}
+	}
+	value, _ := updated.Metadata["secure_1psidts"].(string)
+	c.JSON(http.StatusOK, gin.H{
+		"name":           c.Query("name"),
+		"rotated":        rotated,
+		"secure_1psidts": geminiwebapi.MaskToken28(value),
+		"elapsed_ms":     elapsed.Milliseconds(),
+	})
+}
+
+// GetGeminiWebHealth checks whether Google still accepts the cookies of a Gemini Web account
+// without changing them, and reports how long ago they were last refreshed or rotated.
+func (h *Handler) GetGeminiWebHealth(c *gin.Context) {
+	auth := h.geminiWebAuth(c, "name")
+	if auth == nil {
+		return
+	}
+	started := time.Now()
+	lastRefresh, err := executor.NewGeminiWebExecutor(h.cfg).CheckCookies(auth)
+	result := gin.H{
+		"name":       c.Query("name"),
+		"healthy":    err == nil,
+		"elapsed_ms": time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	if !lastRefresh.IsZero() {
+		result["last_refresh"] = lastRefresh
+		result["last_refresh_age_seconds"] = int64(time.Since(lastRefresh).Seconds())
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetGeminiWebConversations lists the stored conversations of the Gemini Web account named by
+// the account query parameter, most recently updated first. limit and offset page the list.
+func (h *Handler) GetGeminiWebConversations(c *gin.Context) {
+	auth := h.geminiWebAuth(c, "account")
+	if auth == nil {
+		return
+	}
+	limit, offset := 50, 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	if raw := c.Query("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		offset = n
+	}
+	list, total, err := geminiwebapi.ListConversations(geminiWebConvPath(auth), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"conversations": list, "total": total})
+}
+
+// GetGeminiWebConversation returns the stored messages of one conversation. With redact=true
+// the content of user messages is replaced.
+func (h *Handler) GetGeminiWebConversation(c *gin.Context) {
+	auth := h.geminiWebAuth(c, "account")
+	if auth == nil {
+		return
+	}
+	id := c.Param("id")
+	rec, ok, err := geminiwebapi.LoadConversation(geminiWebConvPath(auth), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+	messages := make([]geminiwebapi.StoredMessage, len(rec.Messages))
+	copy(messages, rec.Messages)
+	if redact, _ := strconv.ParseBool(c.Query("redact")); redact {
+		for i := range messages {
+			if messages[i].Role == "user" {
+				messages[i].Content = "[redacted]"
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":         id,
+		"title":      rec.Title,
+		"model":      rec.Model,
+		"created_at": rec.CreatedAt,
+		"updated_at": rec.UpdatedAt,
+		"messages":   messages,
+	})
+}
+
+// geminiWebConvPath returns the conversation store of auth, named after its auth file.
+func geminiWebConvPath(auth *coreauth.Auth) string {
+	if path := auth.Attributes["path"]; path != "" {
+		return geminiwebapi.ConvBoltPath(path)
+	}
+	return geminiwebapi.ConvBoltPath(auth.ID)
+}
@@ -3,19 +3,20 @@
 package management
 
 import (
-	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/docstore"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
-	"golang.org/x/crypto/bcrypt"
+	log "github.com/sirupsen/logrus"
 )
 
 type attemptInfo struct {
@@ -34,8 +35,11 @@ type Handler struct {
 	authManager    *coreauth.Manager
 	usageStats     *usage.RequestStatistics
 	tokenStore     sdkAuth.TokenStore
+	embedder       docstore.Embedder
+	requestLogDir  string
 
 	localPassword string
+	keyCache      managementKeyCache
 }
 
 // NewHandler creates a new management handler instance.
@@ -59,12 +63,32 @@ func (h *Handler) SetAuthManager(manager *coreauth.Manager) { h.authManager = ma
 // SetUsageStatistics allows replacing the usage statistics reference.
 func (h *Handler) SetUsageStatistics(stats *usage.RequestStatistics) { h.usageStats = stats }
 
+// SetEmbedder sets the function used to embed uploaded RAG documents.
+func (h *Handler) SetEmbedder(embed docstore.Embedder) { h.embedder = embed }
+
+// SetRequestLogDir sets the directory request logs are read from for export.
+func (h *Handler) SetRequestLogDir(dir string) { h.requestLogDir = dir }
+
 // SetLocalPassword configures the runtime-local password accepted for localhost requests.
 func (h *Handler) SetLocalPassword(password string) { h.localPassword = password }
 
+// Management middleware failure codes, returned as the "error" field so clients can tell
+// the causes apart; "message" carries a readable description.
+const (
+	errManagementDisabled = "management_disabled"
+	errRemoteDisabled     = "remote_management_disabled"
+	errMissingKey         = "missing_key"
+	errInvalidKey         = "invalid_key"
+	errIPBanned           = "ip_banned"
+	errForbidden          = "forbidden"
+)
+
 // Middleware enforces access control for management endpoints.
 // All requests (local and remote) require a valid management key.
 // Additionally, remote access requires allow-remote-management=true.
+// Without a management key, only localhost clients holding the local password get through;
+// everyone else is told management is disabled. Keys listed under remote-management.keys
+// are further limited to their role, and every mutating call is logged with the key's label.
 func (h *Handler) Middleware() gin.HandlerFunc {
 	const maxFailures = 5
 	const banDuration = 30 * time.Minute
@@ -73,6 +97,17 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		clientIP := c.ClientIP()
 		localClient := clientIP == "127.0.0.1" || clientIP == "::1"
 
+		deny := func(status int, code, message string) {
+			log.Warnf("management request from %s to %s denied: %s", clientIP, c.Request.URL.Path, code)
+			c.AbortWithStatusJSON(status, gin.H{"error": code, "message": message})
+		}
+
+		secret := h.cfg.RemoteManagement.SecretKey
+		if secret == "" && len(h.cfg.RemoteManagement.Keys) == 0 && (!localClient || h.localPassword == "") {
+			deny(http.StatusNotFound, errManagementDisabled, "management API is disabled: remote-management.secret-key is not set")
+			return
+		}
+
 		fail := func() {}
 		if !localClient {
 			h.attemptsMu.Lock()
@@ -82,7 +117,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 					if time.Now().Before(ai.blockedUntil) {
 						remaining := time.Until(ai.blockedUntil).Round(time.Second)
 						h.attemptsMu.Unlock()
-						c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("IP banned due to too many failed attempts. Try again in %s", remaining)})
+						deny(http.StatusForbidden, errIPBanned, fmt.Sprintf("IP banned due to too many failed attempts. Try again in %s", remaining))
 						return
 					}
 					// Ban expired, reset state
@@ -93,7 +128,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			h.attemptsMu.Unlock()
 
 			if !h.cfg.RemoteManagement.AllowRemote {
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management disabled"})
+				deny(http.StatusForbidden, errRemoteDisabled, "remote management disabled")
 				return
 			}
 
@@ -112,18 +147,15 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 				h.attemptsMu.Unlock()
 			}
 		}
-		secret := h.cfg.RemoteManagement.SecretKey
-		if secret == "" {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management key not set"})
-			return
-		}
 
-		// Accept either Authorization: Bearer <key> or X-Management-Key
+		// Accept Authorization: Bearer <key>, HTTP Basic (key as password) or X-Management-Key
 		var provided string
 		if ah := c.GetHeader("Authorization"); ah != "" {
 			parts := strings.SplitN(ah, " ", 2)
 			if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
 				provided = parts[1]
+			} else if _, password, ok := c.Request.BasicAuth(); ok {
+				provided = password
 			} else {
 				provided = ah
 			}
@@ -133,27 +165,15 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		}
 
 		if provided == "" {
-			if !localClient {
-				fail()
-			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing management key"})
+			fail()
+			deny(http.StatusUnauthorized, errMissingKey, "missing management key")
 			return
 		}
 
-		if localClient {
-			if lp := h.localPassword; lp != "" {
-				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
-					c.Next()
-					return
-				}
-			}
-		}
-
-		if err := bcrypt.CompareHashAndPassword([]byte(secret), []byte(provided)); err != nil {
-			if !localClient {
-				fail()
-			}
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid management key"})
+		identity, ok := h.identify(provided, localClient)
+		if !ok {
+			fail()
+			deny(http.StatusUnauthorized, errInvalidKey, "invalid management key")
 			return
 		}
 
@@ -166,14 +186,64 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			h.attemptsMu.Unlock()
 		}
 
+		if !identity.permits(c) {
+			deny(http.StatusForbidden, errForbidden, fmt.Sprintf("management key %q may not call %s %s", identity.Label, c.Request.Method, c.Request.URL.Path))
+			return
+		}
+
+		c.Set(managementPrincipalKey, principalFor(provided))
+		c.Set(managementKeyLabelKey, identity.Label)
+		route, _ := managementRoute(c)
+		mutating := mutatingRequest(c, route)
+		actor := fmt.Sprintf("key %q", identity.Label)
+		if forwarded := c.GetHeader(forwardedKeyLabelHeader); forwarded != "" && identity.Secret {
+			// A follower forwarded the call; it names the key its caller used. Only the
+			// secret key followers share is trusted to say so.
+			actor += fmt.Sprintf(" on behalf of key %q", forwarded)
+		}
+		if leader := h.leaderURL(); leader != "" && forwardsToLeader(mutating, route) {
+			h.forwardToLeader(c, leader)
+			log.Infof("management audit: %s %s by %s from %s: forwarded to leader: %d", c.Request.Method, c.Request.URL.Path, actor, clientIP, c.Writer.Status())
+			return
+		}
 		c.Next()
+		if mutating {
+			log.Infof("management audit: %s %s by %s from %s: %d", c.Request.Method, c.Request.URL.Path, actor, clientIP, c.Writer.Status())
+		}
 	}
 }
 
-// persist saves the current in-memory config to disk.
+// AdminUIMiddleware guards the admin web UI. It behaves like Middleware but asks browsers
+// for credentials through HTTP Basic auth, where the management key is the password.
+func (h *Handler) AdminUIMiddleware() gin.HandlerFunc {
+	inner := h.Middleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.GetHeader("X-Management-Key") == "" {
+			c.Header("WWW-Authenticate", `Basic realm="CLIProxyAPI management"`)
+		}
+		inner(c)
+	}
+}
+
+// persist saves the current in-memory config to disk. A change to a field set by an override
+// file is undone and answered with 409 without saving, as the override file would win again
+// on the next load and rewriting the config file would only trigger a reload.
 func (h *Handler) persist(c *gin.Context) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if reverted := h.cfg.RevertOverridden(); len(reverted) > 0 {
+		fields := make([]string, 0, len(reverted))
+		for field, file := range reverted {
+			fields = append(fields, fmt.Sprintf("%s (set by %s)", field, file))
+		}
+		sort.Strings(fields)
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "overridden",
+			"message":   "fields set by an override file take precedence and were left unchanged: " + strings.Join(fields, ", "),
+			"overrides": reverted,
+		})
+		return false
+	}
 	// Preserve comments when writing
 	if err := config.SaveConfigPreserveComments(h.configFilePath, h.cfg); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save config: %v", err)})
@@ -0,0 +1,107 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestPutOverriddenFieldConflicts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	override := filepath.Join(dir, "team.yaml")
+	if err := os.WriteFile(base, []byte("port: 8317\ndebug: false\nrequest-retry: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(override, []byte("debug: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfigWithOverrides(base, []string{override})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(cfg, base, nil)
+	engine := gin.New()
+	engine.PUT("/debug", h.PutDebug)
+	engine.PUT("/request-retry", h.PutRequestRetry)
+	before, _ := os.ReadFile(base)
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/debug", strings.NewReader(`{"value":false}`)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409; body %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), override) {
+		t.Errorf("conflict does not name the override file: %s", rec.Body.String())
+	}
+	if !cfg.Debug {
+		t.Error("overridden field changed in memory")
+	}
+	if after, _ := os.ReadFile(base); string(after) != string(before) {
+		t.Errorf("config file rewritten:\n%s", after)
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/request-retry", strings.NewReader(`{"value":3}`)))
+	if rec.Code != http.StatusOK || cfg.RequestRetry != 3 {
+		t.Fatalf("plain field update: status %d, request-retry %d", rec.Code, cfg.RequestRetry)
+	}
+}
+
+func TestMiddlewareOutcomes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := hashKey(t, "secret-key")
+	tests := []struct {
+		name          string
+		secretKey     string
+		allowRemote   bool
+		localPassword string
+		remoteAddr    string
+		key           string
+		wantStatus    int
+		wantError     string
+	}{
+		{"no secret, remote", "", true, "", "10.0.0.1:1234", "secret-key", http.StatusNotFound, errManagementDisabled},
+		{"no secret, localhost without local password", "", false, "", "127.0.0.1:1234", "anything", http.StatusNotFound, errManagementDisabled},
+		{"missing key", secret, false, "", "127.0.0.1:1234", "", http.StatusUnauthorized, errMissingKey},
+		{"invalid key", secret, false, "", "127.0.0.1:1234", "wrong-key", http.StatusUnauthorized, errInvalidKey},
+		{"remote disabled", secret, false, "", "10.0.0.1:1234", "secret-key", http.StatusForbidden, errRemoteDisabled},
+		{"remote allowed", secret, true, "", "10.0.0.1:1234", "secret-key", http.StatusOK, ""},
+		{"localhost local password without secret", "", false, "local-pass", "127.0.0.1:1234", "local-pass", http.StatusOK, ""},
+		{"local password refused remotely", "", true, "local-pass", "10.0.0.1:1234", "local-pass", http.StatusNotFound, errManagementDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.RemoteManagement.SecretKey = tt.secretKey
+			cfg.RemoteManagement.AllowRemote = tt.allowRemote
+			h := NewHandler(cfg, "", nil)
+			h.SetLocalPassword(tt.localPassword)
+			engine := gin.New()
+			engine.Use(h.Middleware())
+			engine.GET("/v0/management/usage", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+
+			req := httptest.NewRequest(http.MethodGet, "/v0/management/usage", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := gjson.Get(rec.Body.String(), "error").String(); tt.wantError != "" && got != tt.wantError {
+				t.Fatalf("error = %q, want %q", got, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package management
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	errLeaderUnreachable = "leader_unreachable"
+	errLeaderInsecure    = "leader_insecure"
+)
+
+// forwardedKeyLabelHeader carries the label of the key a follower authenticated the caller
+// with, so the leader's audit log names who made the change and not only the follower. The
+// leader ignores it unless the call was made with remote-management.secret-key.
+const forwardedKeyLabelHeader = "X-CLIProxy-Forwarded-Key-Label"
+
+// leaderTimeout bounds a call forwarded to the leader.
+const leaderTimeout = 60 * time.Second
+
+// leaderForwardedHeaders lists the request headers passed on to the leader. Credentials and
+// client addresses are left out: the follower authenticates with its own key.
+var leaderForwardedHeaders = []string{"Content-Type", "Accept", "Accept-Encoding", "User-Agent"}
+
+// leaderURL returns the base URL of the leader this server follows, or "" when it serves
+// every management call itself.
+func (h *Handler) leaderURL() string {
+	return strings.TrimRight(strings.TrimSpace(h.cfg.RemoteManagement.Leader.URL), "/")
+}
+
+// forwardsToLeader reports whether the call to route belongs to the leader. Mutating calls
+// do, and so do polling the status of a login the leader started and listing its logins.
+func forwardsToLeader(mutating bool, route string) bool {
+	return mutating || route == "get-auth-status" || route == "oauth-sessions"
+}
+
+// secureLeader reports whether the management key may be sent to leader: over https, or
+// over plain http only to a loopback address.
+func secureLeader(leader string) bool {
+	u, err := url.Parse(leader)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return true
+	case "http":
+		return util.IsLoopbackHost(u.Hostname())
+	}
+	return false
+}
+
+// forwardToLeader replays the request in c against the management API of leader with the
+// configured shared key and the caller's key label, and copies the answer back. The key is
+// never sent to a leader reached over plain http on another host.
+func (h *Handler) forwardToLeader(c *gin.Context, leader string) {
+	if !secureLeader(leader) {
+		log.Errorf("management request %s %s not forwarded: leader %s is not https", c.Request.Method, c.Request.URL.Path, leader)
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": errLeaderInsecure, "message": "remote-management.leader.url must use https unless the leader is on this host"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, leader+c.Request.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": errLeaderUnreachable, "message": err.Error()})
+		return
+	}
+	for _, name := range leaderForwardedHeaders {
+		if value := c.GetHeader(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+h.cfg.RemoteManagement.Leader.SecretKey)
+	if label := c.GetString(managementKeyLabelKey); label != "" {
+		req.Header.Set(forwardedKeyLabelHeader, label)
+	}
+
+	client := &http.Client{Timeout: leaderTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Warnf("management request %s %s not forwarded to leader %s: %v", c.Request.Method, c.Request.URL.Path, leader, err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": errLeaderUnreachable, "message": fmt.Sprintf("leader %s unreachable", leader)})
+		return
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("leader response body close error: %v", errClose)
+		}
+	}()
+	for name, values := range resp.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Connection", "Transfer-Encoding":
+			continue
+		}
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Writer.Header().Set("X-CLIProxy-Leader", leader)
+	c.Status(resp.StatusCode)
+	if _, errCopy := io.Copy(c.Writer, resp.Body); errCopy != nil {
+		log.Warnf("leader response for %s %s cut short: %v", c.Request.Method, c.Request.URL.Path, errCopy)
+	}
+	c.Abort()
+}
@@ -0,0 +1,119 @@
+package management
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashKey(t *testing.T, key string) string {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hashed)
+}
+
+func TestSecureLeader(t *testing.T) {
+	for leader, want := range map[string]bool{
+		"https://leader.internal:8317": true,
+		"http://127.0.0.1:8317":        true,
+		"http://localhost:8317":        true,
+		"http://[::1]:8317":            true,
+		"http://leader.internal:8317":  false,
+		"http://10.0.0.2:8317":         false,
+		"leader.internal:8317":         false,
+	} {
+		if got := secureLeader(leader); got != want {
+			t.Errorf("secureLeader(%q) = %v, want %v", leader, got, want)
+		}
+	}
+}
+
+func TestForwardToLeaderRefusesPlainHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(&config.Config{}, "", nil)
+	h.cfg.RemoteManagement.Leader = config.ManagementLeader{URL: "http://leader.internal:8317", SecretKey: "leader-key"}
+	engine := gin.New()
+	engine.PUT("/v0/management/debug", func(c *gin.Context) { h.forwardToLeader(c, h.leaderURL()) })
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/v0/management/debug", strings.NewReader(`{"value":true}`)))
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), errLeaderInsecure) {
+		t.Fatalf("status = %d, body %s; want 502 %s", rec.Code, rec.Body.String(), errLeaderInsecure)
+	}
+}
+
+func TestLeaderAuditNamesTheFollowerCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	leaderCfg := &config.Config{}
+	leaderCfg.RemoteManagement.SecretKey = hashKey(t, "leader-key")
+	leader := NewHandler(leaderCfg, "", nil)
+	leaderEngine := gin.New()
+	leaderEngine.Use(leader.Middleware())
+	leaderEngine.PUT("/v0/management/debug", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+	server := httptest.NewServer(leaderEngine)
+	defer server.Close()
+
+	followerCfg := &config.Config{}
+	followerCfg.RemoteManagement.Keys = []config.ManagementKey{{Label: "alice", Key: hashKey(t, "alice-key"), Role: config.ManagementRoleAdmin}}
+	followerCfg.RemoteManagement.Leader = config.ManagementLeader{URL: server.URL, SecretKey: "leader-key"}
+	follower := NewHandler(followerCfg, "", nil)
+	followerEngine := gin.New()
+	followerEngine.Use(follower.Middleware())
+	followerEngine.PUT("/v0/management/debug", func(c *gin.Context) { t.Error("follower served a mutating call itself") })
+
+	req := httptest.NewRequest(http.MethodPut, "/v0/management/debug", strings.NewReader(`{"value":true}`))
+	req.RemoteAddr = "127.0.0.1:40000"
+	req.Header.Set("Authorization", "Bearer alice-key")
+	rec := httptest.NewRecorder()
+	followerEngine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(buf.String(), `by key \"secret-key\" on behalf of key \"alice\"`) {
+		t.Errorf("leader audit does not name the caller:\n%s", buf.String())
+	}
+}
+
+func TestLeaderIgnoresForwardedLabelFromDirectCallers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	cfg := &config.Config{}
+	cfg.RemoteManagement.SecretKey = hashKey(t, "leader-key")
+	cfg.RemoteManagement.Keys = []config.ManagementKey{{Label: "mallory", Key: hashKey(t, "mallory-key"), Role: config.ManagementRoleAdmin}}
+	h := NewHandler(cfg, "", nil)
+	engine := gin.New()
+	engine.Use(h.Middleware())
+	engine.PUT("/v0/management/debug", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+
+	req := httptest.NewRequest(http.MethodPut, "/v0/management/debug", strings.NewReader(`{"value":true}`))
+	req.RemoteAddr = "127.0.0.1:40000"
+	req.Header.Set("Authorization", "Bearer mallory-key")
+	req.Header.Set(forwardedKeyLabelHeader, "alice")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(buf.String(), "on behalf of") || !strings.Contains(buf.String(), `by key \"mallory\"`) {
+		t.Errorf("audit trusted a forwarded label from a direct caller:\n%s", buf.String())
+	}
+}
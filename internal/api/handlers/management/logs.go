@@ -0,0 +1,180 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultRecentLogLines is the number of lines returned by /logs/recent without ?lines.
+	defaultRecentLogLines = 500
+	// defaultLogBackfill is the number of lines sent when /logs/stream opens without ?lines.
+	defaultLogBackfill = 100
+	// logStreamHeartbeat is the interval of the comments keeping an idle log stream open.
+	logStreamHeartbeat = 15 * time.Second
+)
+
+// logFilter selects log lines by minimum level and by text.
+type logFilter struct {
+	level    log.Level
+	contains string
+	pattern  *regexp.Regexp
+}
+
+// parseLogFilter reads the level, contains and regex query parameters.
+func parseLogFilter(c *gin.Context) (logFilter, error) {
+	filter := logFilter{level: log.TraceLevel}
+	if raw := strings.TrimSpace(c.Query("level")); raw != "" {
+		level, err := log.ParseLevel(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid level %q", raw)
+		}
+		filter.level = level
+	}
+	filter.contains = c.Query("contains")
+	if raw := c.Query("regex"); raw != "" {
+		pattern, err := regexp.Compile(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid regex: %v", err)
+		}
+		filter.pattern = pattern
+	}
+	return filter, nil
+}
+
+// match reports whether line passes the filter. Levels are compared by severity, so a
+// minimum of warn keeps warnings, errors and worse.
+func (f logFilter) match(line logging.LogLine) bool {
+	level, err := log.ParseLevel(line.Level)
+	if err == nil && level > f.level {
+		return false
+	}
+	if f.contains != "" && !strings.Contains(line.Line, f.contains) {
+		return false
+	}
+	return f.pattern == nil || f.pattern.MatchString(line.Line)
+}
+
+// apply returns the lines passing the filter.
+func (f logFilter) apply(lines []logging.LogLine) []logging.LogLine {
+	out := make([]logging.LogLine, 0, len(lines))
+	for _, line := range lines {
+		if f.match(line) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// parseLogLines reads the lines query parameter, falling back to def.
+func parseLogLines(c *gin.Context, def int) (int, error) {
+	raw := strings.TrimSpace(c.Query("lines"))
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid lines %q", raw)
+	}
+	return n, nil
+}
+
+// GetRecentLogs returns the latest log lines kept in memory. The lines are selected before
+// filtering, so a filter narrows the last ?lines lines rather than searching further back.
+func (h *Handler) GetRecentLogs(c *gin.Context) {
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	n, err := parseLogLines(c, defaultRecentLogLines)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var lines []logging.LogLine
+	if n > 0 {
+		lines = filter.apply(logging.RecentLogLines(n))
+	}
+	c.JSON(http.StatusOK, gin.H{"lines": lines})
+}
+
+// StreamLogs streams log lines over server-sent events until the client disconnects. It
+// opens with up to ?lines of the latest lines, then sends each new line as a "log" event.
+// When the client reads too slowly the oldest queued lines are dropped and a "dropped"
+// event reports how many.
+func (h *Handler) StreamLogs(c *gin.Context) {
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	backfill, err := parseLogLines(c, defaultLogBackfill)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming not supported"})
+		return
+	}
+
+	sub, lines := logging.SubscribeLogs(backfill)
+	defer logging.UnsubscribeLogs(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	send := func(lines []logging.LogLine, dropped int) bool {
+		if dropped > 0 {
+			if _, errWrite := fmt.Fprintf(c.Writer, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped); errWrite != nil {
+				return false
+			}
+		}
+		for _, line := range filter.apply(lines) {
+			data, errMarshal := json.Marshal(line)
+			if errMarshal != nil {
+				continue
+			}
+			if _, errWrite := fmt.Fprintf(c.Writer, "event: log\ndata: %s\n\n", data); errWrite != nil {
+				return false
+			}
+		}
+		flusher.Flush()
+		return true
+	}
+	if !send(lines, 0) {
+		return
+	}
+
+	heartbeat := time.NewTicker(logStreamHeartbeat)
+	defer heartbeat.Stop()
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, errWrite := fmt.Fprint(c.Writer, ": ping\n\n"); errWrite != nil {
+				return
+			}
+			flusher.Flush()
+		case <-sub.Ready():
+			if !send(sub.Take()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package management
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maintenanceRequest is the body accepted by SetAuthMaintenance.
+type maintenanceRequest struct {
+	// ID is the auth ID as listed by /quota-status.
+	ID string `json:"id"`
+	// Name is an auth file name, an alternative to ID.
+	Name string `json:"name"`
+	// Action is "start" or "stop".
+	Action string `json:"action"`
+	// Duration limits a started period, e.g. "90m". Empty lasts until stopped.
+	Duration string `json:"duration"`
+}
+
+// authFileID returns the auth ID of the auth file name in the auth directory, or "" for an
+// empty name. Auths loaded from the file store are keyed by the path relative to the auth
+// directory, others by the absolute path; the one the auth manager knows is preferred.
+func (h *Handler) authFileID(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	base := filepath.Base(name)
+	id := filepath.Join(h.cfg.AuthDir, base)
+	if abs, err := filepath.Abs(id); err == nil {
+		id = abs
+	}
+	if h.authManager != nil {
+		if _, ok := h.authManager.GetByID(id); !ok {
+			if _, ok = h.authManager.GetByID(base); ok {
+				return base
+			}
+		}
+	}
+	return id
+}
+
+// SetAuthMaintenance starts or stops a manual maintenance period on one auth. Manual periods
+// are kept in memory only and end on restart.
+func (h *Handler) SetAuthMaintenance(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body maintenanceRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	id := strings.TrimSpace(body.ID)
+	if id == "" {
+		id = h.authFileID(body.Name)
+	}
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id or name is required"})
+		return
+	}
+	auth, ok := h.authManager.GetByID(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth not found"})
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(body.Action)) {
+	case "start":
+		var until time.Time
+		if value := strings.TrimSpace(body.Duration); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid duration"})
+				return
+			}
+			until = time.Now().Add(duration)
+		}
+		h.authManager.StartMaintenance(id, until)
+	case "stop":
+		if !h.authManager.StopMaintenance(id) {
+			c.JSON(http.StatusConflict, gin.H{"error": "auth has no manual maintenance period"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be start or stop"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "maintenance": h.authManager.Maintenance(auth)})
+}
@@ -0,0 +1,185 @@
+package management
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// managementKeyLabelKey is the gin context key holding the label of the caller's key.
+	managementKeyLabelKey = "managementKeyLabel"
+
+	// secretKeyLabel names remote-management.secret-key, which is always an admin key.
+	secretKeyLabel = "secret-key"
+	// localPasswordLabel names the runtime-local password accepted from localhost.
+	localPasswordLabel = "local"
+)
+
+// readOnlyPosts lists POST endpoints that only compute an answer from their body.
+var readOnlyPosts = map[string]struct{}{
+	"route-preview": {},
+}
+
+// readOnlyRoutes lists the GET endpoints read-only keys may call: those reporting state
+// without credentials, request content or login flows. Endpoints missing here, including
+// any added later, need an admin key or a key granted their group.
+var readOnlyRoutes = map[string]struct{}{
+	"usage":                               {},
+	"debug":                               {},
+	"logging-to-file":                     {},
+	"usage-statistics-enabled":            {},
+	"quota-exceeded/switch-project":       {},
+	"quota-exceeded/switch-preview-model": {},
+	"request-log":                         {},
+	"request-retry":                       {},
+	"auth-files":                          {},
+	"auth-files/errors":                   {},
+	"gemini-web/health":                   {},
+	"model-versions":                      {},
+	"quota-status":                        {},
+	"capabilities":                        {},
+	"upstream-connections":                {},
+	"management-keys":                     {},
+	"admin-ui":                            {},
+}
+
+// managementIdentity is the management key a request was authenticated with.
+type managementIdentity struct {
+	Label  string
+	Role   string
+	Groups []string
+	// Secret is set for remote-management.secret-key, the key followers call their leader with.
+	Secret bool
+}
+
+// managementKeyCache remembers which labelled key a presented key matched, so bcrypt runs
+// once per key rather than on every request. It is dropped whenever the config changes.
+type managementKeyCache struct {
+	mu      sync.Mutex
+	cfg     *config.Config
+	matches map[string]int
+}
+
+// identify returns the identity of provided, the key presented by a client.
+func (h *Handler) identify(provided string, localClient bool) (managementIdentity, bool) {
+	if localClient && h.localPassword != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(h.localPassword)) == 1 {
+		return managementIdentity{Label: localPasswordLabel, Role: config.ManagementRoleAdmin}, true
+	}
+	if secret := h.cfg.RemoteManagement.SecretKey; secret != "" && bcrypt.CompareHashAndPassword([]byte(secret), []byte(provided)) == nil {
+		return managementIdentity{Label: secretKeyLabel, Role: config.ManagementRoleAdmin, Secret: true}, true
+	}
+	keys := h.cfg.RemoteManagement.Keys
+	principal := principalFor(provided)
+
+	h.keyCache.mu.Lock()
+	if h.keyCache.cfg != h.cfg {
+		h.keyCache.cfg = h.cfg
+		h.keyCache.matches = make(map[string]int)
+	}
+	index, cached := h.keyCache.matches[principal]
+	h.keyCache.mu.Unlock()
+
+	if !cached {
+		index = -1
+		for i, entry := range keys {
+			if entry.Key != "" && bcrypt.CompareHashAndPassword([]byte(entry.Key), []byte(provided)) == nil {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return managementIdentity{}, false
+		}
+		h.keyCache.mu.Lock()
+		if h.keyCache.cfg == h.cfg {
+			h.keyCache.matches[principal] = index
+		}
+		h.keyCache.mu.Unlock()
+	}
+	if index >= len(keys) {
+		return managementIdentity{}, false
+	}
+	entry := keys[index]
+	return managementIdentity{Label: entry.Label, Role: entry.Role, Groups: entry.Groups}, true
+}
+
+// managementRoute returns the route of c relative to /v0/management and its endpoint group.
+// Routes outside the management API, i.e. the admin UI, form the admin-ui group.
+func managementRoute(c *gin.Context) (route, group string) {
+	full := c.FullPath()
+	route, ok := strings.CutPrefix(full, "/v0/management/")
+	if !ok {
+		return "admin-ui", "admin-ui"
+	}
+	group, _, _ = strings.Cut(route, "/")
+	return route, group
+}
+
+// mutatingRequest reports whether the request in c may change state.
+func mutatingRequest(c *gin.Context, route string) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		// Fetching a login URL starts a login flow.
+		return strings.HasSuffix(route, "-auth-url")
+	case http.MethodPost:
+		_, readOnly := readOnlyPosts[route]
+		return !readOnly
+	}
+	return true
+}
+
+// permits reports whether id may make the request in c.
+func (id managementIdentity) permits(c *gin.Context) bool {
+	route, group := managementRoute(c)
+	if len(id.Groups) > 0 {
+		for _, allowed := range id.Groups {
+			if strings.EqualFold(strings.TrimSpace(allowed), group) {
+				return true
+			}
+		}
+		return false
+	}
+	switch id.Role {
+	case config.ManagementRoleAdmin:
+		return true
+	case config.ManagementRoleReadOnly:
+		if c.Request.Method == http.MethodPost {
+			_, readOnly := readOnlyPosts[route]
+			return readOnly
+		}
+		if mutatingRequest(c, route) {
+			return false
+		}
+		_, allowed := readOnlyRoutes[route]
+		return allowed
+	}
+	log.Warnf("management key %q has unknown role %q and no groups; refusing it", id.Label, id.Role)
+	return false
+}
+
+// managementKeyInfo describes one management key in listings, without the key itself.
+type managementKeyInfo struct {
+	Label  string   `json:"label"`
+	Role   string   `json:"role,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// GetManagementKeys lists the configured management keys by label and role. Keys and their
+// hashes are never returned.
+func (h *Handler) GetManagementKeys(c *gin.Context) {
+	keys := make([]managementKeyInfo, 0, len(h.cfg.RemoteManagement.Keys)+1)
+	if h.cfg.RemoteManagement.SecretKey != "" {
+		keys = append(keys, managementKeyInfo{Label: secretKeyLabel, Role: config.ManagementRoleAdmin})
+	}
+	for _, entry := range h.cfg.RemoteManagement.Keys {
+		keys = append(keys, managementKeyInfo{Label: entry.Label, Role: entry.Role, Groups: entry.Groups})
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys, "current": c.GetString(managementKeyLabelKey)})
+}
@@ -0,0 +1,101 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestReadOnlyKeyPermits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	readOnly := managementIdentity{Label: "viewer", Role: config.ManagementRoleReadOnly}
+	admin := managementIdentity{Label: "root", Role: config.ManagementRoleAdmin}
+
+	tests := []struct {
+		method  string
+		route   string
+		path    string
+		allowed bool
+	}{
+		{http.MethodGet, "/v0/management/usage", "/v0/management/usage", true},
+		{http.MethodHead, "/v0/management/quota-status", "/v0/management/quota-status", true},
+		{http.MethodGet, "/v0/management/auth-files", "/v0/management/auth-files", true},
+		{http.MethodPost, "/v0/management/route-preview", "/v0/management/route-preview", true},
+		{http.MethodGet, "/v0/management/config", "/v0/management/config", false},
+		{http.MethodGet, "/v0/management/proxy-url", "/v0/management/proxy-url", false},
+		{http.MethodGet, "/v0/management/request-logs/:id/export", "/v0/management/request-logs/abc/export", false},
+		{http.MethodGet, "/v0/management/gemini-web/conversations", "/v0/management/gemini-web/conversations", false},
+		{http.MethodGet, "/v0/management/logs/stream", "/v0/management/logs/stream", false},
+		{http.MethodGet, "/v0/management/logs/recent", "/v0/management/logs/recent", false},
+		{http.MethodGet, "/v0/management/oauth-sessions", "/v0/management/oauth-sessions", false},
+		{http.MethodGet, "/v0/management/codex-auth-url", "/v0/management/codex-auth-url", false},
+		{http.MethodGet, "/v0/management/not-yet-listed", "/v0/management/not-yet-listed", false},
+		{http.MethodPut, "/v0/management/debug", "/v0/management/debug", false},
+		{http.MethodPost, "/v0/management/api-keys", "/v0/management/api-keys", false},
+	}
+	for _, tt := range tests {
+		var got, gotAdmin bool
+		engine := gin.New()
+		engine.Handle(tt.method, tt.route, func(c *gin.Context) {
+			got = readOnly.permits(c)
+			gotAdmin = admin.permits(c)
+		})
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		if got != tt.allowed {
+			t.Errorf("read-only %s %s: permits = %v, want %v", tt.method, tt.path, got, tt.allowed)
+		}
+		if !gotAdmin {
+			t.Errorf("admin %s %s: refused", tt.method, tt.path)
+		}
+	}
+}
+
+func TestGroupKeyPermits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	id := managementIdentity{Label: "logs", Groups: []string{"logs"}}
+	for path, want := range map[string]bool{
+		"/v0/management/logs/recent": true,
+		"/v0/management/usage":       false,
+	} {
+		var got bool
+		engine := gin.New()
+		engine.GET(path, func(c *gin.Context) { got = id.permits(c) })
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if got != want {
+			t.Errorf("%s: permits = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestReadOnlyKeyThroughMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.RemoteManagement.Keys = []config.ManagementKey{{Label: "viewer", Key: hashKey(t, "viewer-key"), Role: config.ManagementRoleReadOnly}}
+	h := NewHandler(cfg, "", nil)
+	engine := gin.New()
+	engine.Use(h.Middleware())
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) }
+	engine.GET("/v0/management/usage", ok)
+	engine.DELETE("/v0/management/auth-files", ok)
+
+	for _, tt := range []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodDelete, "/v0/management/auth-files", http.StatusForbidden},
+		{http.MethodGet, "/v0/management/usage", http.StatusOK},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("Authorization", "Bearer viewer-key")
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d; body %s", tt.method, tt.path, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
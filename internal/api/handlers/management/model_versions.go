@@ -0,0 +1,18 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelversion"
+)
+
+// GetModelVersions lists, per provider and model, the upstream identity responses last
+// reported and the changes observed before it. Nothing is recorded while model-versions is
+// disabled.
+func (h *Handler) GetModelVersions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.cfg.ModelVersions.Enable,
+		"models":  modelversion.Default().Entries(),
+	})
+}
@@ -0,0 +1,388 @@
+package management
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// oauthSessionTTL bounds how long an OAuth flow record, finished or not, is kept.
+	oauthSessionTTL = 10 * time.Minute
+	// oauthJanitorInterval is how often expired records and callback files are swept.
+	oauthJanitorInterval = time.Minute
+	// oauthFlowTimeout is how long a flow waits for its callback. A flow still running after
+	// it no longer holds its provider's login slot and is superseded by the next request.
+	oauthFlowTimeout = 5 * time.Minute
+	// oauthMaxQueued bounds the login requests waiting per provider for the running flow to
+	// finish; more are rejected with a 429.
+	oauthMaxQueued = 2
+
+	// Errors recorded on a flow that lost its slot without a callback: superseded when a
+	// newer login request took the slot, timed out when the janitor or a listing freed it.
+	oauthSupersededError = "Superseded by a newer login request"
+	oauthTimedOutError   = "Timed out waiting for the OAuth callback"
+
+	oauthStatusWait  = "wait"
+	oauthStatusOK    = "ok"
+	oauthStatusError = "error"
+
+	// managementPrincipalKey is the gin context key holding the caller's management principal.
+	managementPrincipalKey = "managementPrincipal"
+)
+
+// oauthSession tracks one login flow started through the management API.
+type oauthSession struct {
+	Provider  string
+	Principal string
+	URL       string
+	Status    string
+	Error     string
+	CreatedAt time.Time
+}
+
+// oauthQueueWait bounds how long a queued login request is held before it is answered with
+// a 429. It stays below leaderTimeout, so a request a follower forwarded gets that answer
+// instead of the follower's 502.
+var oauthQueueWait = 45 * time.Second
+
+// oauthLoginQueue serializes the login flows of one provider: one flow holds the slot, and
+// a few more requests may wait for it.
+type oauthLoginQueue struct {
+	// held is set from the moment a request is admitted; state is filled in once its flow
+	// has started.
+	held    bool
+	holder  string
+	state   string
+	since   time.Time
+	waiting int
+	// changed is closed and replaced whenever the slot changes hands or its flow starts.
+	changed chan struct{}
+}
+
+// oauthSessionStore keeps OAuth flow state keyed by the OAuth state parameter.
+type oauthSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*oauthSession
+	queues   map[string]*oauthLoginQueue
+	authDir  string
+	once     sync.Once
+}
+
+var oauthSessions = &oauthSessionStore{sessions: make(map[string]*oauthSession), queues: make(map[string]*oauthLoginQueue)}
+
+// errOAuthBusy is returned when a provider already has oauthMaxQueued login requests
+// waiting, or when a queued request waited oauthQueueWait without getting the slot.
+// RetryAfter is when the running flow times out at the latest.
+type errOAuthBusy struct {
+	Provider   string
+	RetryAfter time.Duration
+	// Waited is set when the request was queued and gave up.
+	Waited bool
+}
+
+func (e *errOAuthBusy) Error() string {
+	if e.Waited {
+		return fmt.Sprintf("a %s login is still in progress after waiting %s", e.Provider, oauthQueueWait)
+	}
+	return fmt.Sprintf("a %s login is already in progress and %d more are waiting", e.Provider, oauthMaxQueued)
+}
+
+// principalFor derives a stable, non-reversible principal identifier from a management key.
+func principalFor(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// managementPrincipal returns the principal recorded by the management middleware.
+func managementPrincipal(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(managementPrincipalKey)
+}
+
+// admitOAuthFlow queues the login request in c behind the running flow of provider. It
+// reports true when the caller should start a new flow. Otherwise the response is written:
+// the running flow when the same management key asks again, a 429 when too many requests
+// wait or the wait ran out, or nothing when the client went away.
+func admitOAuthFlow(c *gin.Context, provider string) bool {
+	state, err := oauthSessions.acquire(c.Request.Context(), provider, managementPrincipal(c))
+	if err == nil && state == "" {
+		return true
+	}
+	if err == nil {
+		session, _ := oauthSessions.get(state)
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "url": session.URL, "state": state})
+		return false
+	}
+	var busy *errOAuthBusy
+	if errors.As(err, &busy) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(busy.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"status": oauthStatusError, "error": busy.Error()})
+		return false
+	}
+	c.Abort()
+	return false
+}
+
+// acquire waits for the login slot of provider. It returns "" once the slot is the caller's,
+// who must then start a flow, or the state of the running flow when principal started it.
+// It returns errOAuthBusy when oauthMaxQueued requests already wait or the caller waited
+// oauthQueueWait, and the context error when the caller goes away first.
+func (s *oauthSessionStore) acquire(ctx context.Context, provider, principal string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.queues[provider]
+	if !ok {
+		q = &oauthLoginQueue{changed: make(chan struct{})}
+		s.queues[provider] = q
+	}
+	queued := false
+	giveUp := time.Now().Add(oauthQueueWait)
+	defer func() {
+		if queued {
+			q.waiting--
+		}
+	}()
+	for {
+		now := time.Now()
+		s.expire(provider, q, now, oauthSupersededError)
+		if !q.held {
+			q.held, q.holder, q.state, q.since = true, principal, "", now
+			return "", nil
+		}
+		if q.holder == principal && q.state != "" {
+			return q.state, nil
+		}
+		remaining := q.since.Add(oauthFlowTimeout).Sub(now)
+		if !queued {
+			if q.waiting >= oauthMaxQueued {
+				return "", &errOAuthBusy{Provider: provider, RetryAfter: max(remaining, time.Second)}
+			}
+			q.waiting++
+			queued = true
+		} else if !now.Before(giveUp) {
+			return "", &errOAuthBusy{Provider: provider, RetryAfter: max(remaining, time.Second), Waited: true}
+		}
+		changed := q.changed
+		s.mu.Unlock()
+		timer := time.NewTimer(min(remaining, giveUp.Sub(now)))
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+		s.mu.Lock()
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+}
+
+// expire frees the slot of provider when its flow has run past oauthFlowTimeout, or was
+// admitted and never started. The flow fails with reason and its callback file is removed,
+// so a late callback is not picked up. Callers must hold the mutex.
+func (s *oauthSessionStore) expire(provider string, q *oauthLoginQueue, now time.Time, reason string) {
+	if !q.held || now.Sub(q.since) <= oauthFlowTimeout {
+		return
+	}
+	if session, ok := s.sessions[q.state]; ok && session.Status == oauthStatusWait {
+		session.Status = oauthStatusError
+		session.Error = reason
+		if s.authDir != "" {
+			path := filepath.Join(s.authDir, fmt.Sprintf(".oauth-%s-%s.oauth", provider, q.state))
+			if errRemove := os.Remove(path); errRemove != nil && !os.IsNotExist(errRemove) {
+				log.Debugf("failed to remove oauth callback file %s: %v", path, errRemove)
+			}
+		}
+		log.Infof("%s login %s failed after %s without a callback: %s", provider, q.state, oauthFlowTimeout, reason)
+	}
+	s.releaseSlot(q)
+}
+
+func (s *oauthSessionStore) releaseSlot(q *oauthLoginQueue) {
+	q.held, q.holder, q.state = false, "", ""
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// start records a pending flow, hands it the provider's login slot when its caller holds it,
+// and makes sure the janitor is running.
+func (s *oauthSessionStore) start(state, provider, principal, url, authDir string) {
+	s.mu.Lock()
+	s.sessions[state] = &oauthSession{
+		Provider:  provider,
+		Principal: principal,
+		URL:       url,
+		Status:    oauthStatusWait,
+		CreatedAt: time.Now(),
+	}
+	if authDir != "" {
+		s.authDir = authDir
+	}
+	if q, ok := s.queues[provider]; ok && q.held && q.holder == principal && q.state == "" {
+		q.state = state
+		close(q.changed)
+		q.changed = make(chan struct{})
+	}
+	s.mu.Unlock()
+	s.once.Do(func() { go s.janitor() })
+}
+
+// fail marks a flow as failed; the record stays queryable until it expires.
+func (s *oauthSessionStore) fail(state, message string) {
+	s.finish(state, oauthStatusError, message)
+}
+
+// complete marks a flow as successful; the record stays queryable until it expires.
+func (s *oauthSessionStore) complete(state string) {
+	s.finish(state, oauthStatusOK, "")
+}
+
+// finish records the outcome of a flow and frees its provider's login slot. A superseded flow
+// keeps its status.
+func (s *oauthSessionStore) finish(state, status, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[state]
+	if !ok || session.Status != oauthStatusWait {
+		return
+	}
+	session.Status = status
+	session.Error = message
+	if q, ok := s.queues[session.Provider]; ok && q.held && q.state == state {
+		s.releaseSlot(q)
+	}
+}
+
+// get returns a copy of the flow record for state.
+func (s *oauthSessionStore) get(state string) (oauthSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[state]
+	if !ok {
+		return oauthSession{}, false
+	}
+	return *session, true
+}
+
+func (s *oauthSessionStore) janitor() {
+	ticker := time.NewTicker(oauthJanitorInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.sweep(now)
+	}
+}
+
+// sweep supersedes flows past their timeout and drops expired flow records and leftover
+// callback files in the auth directory.
+func (s *oauthSessionStore) sweep(now time.Time) {
+	s.mu.Lock()
+	for provider, q := range s.queues {
+		s.expire(provider, q, now, oauthTimedOutError)
+	}
+	for state, session := range s.sessions {
+		if now.Sub(session.CreatedAt) > oauthSessionTTL {
+			delete(s.sessions, state)
+		}
+	}
+	authDir := s.authDir
+	s.mu.Unlock()
+
+	if authDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(authDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), ".oauth-") {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil || now.Sub(info.ModTime()) <= oauthSessionTTL {
+			continue
+		}
+		path := filepath.Join(authDir, entry.Name())
+		if errRemove := os.Remove(path); errRemove != nil && !os.IsNotExist(errRemove) {
+			log.Debugf("failed to remove stale oauth callback file %s: %v", path, errRemove)
+		}
+	}
+}
+
+// oauthQueueStatus describes the login queue of one provider.
+type oauthQueueStatus struct {
+	Provider string `json:"provider"`
+	// Active reports whether a flow holds the login slot, and Mine whether the caller started it.
+	Active      bool       `json:"active"`
+	Mine        bool       `json:"mine,omitempty"`
+	ActiveSince *time.Time `json:"active_since,omitempty"`
+	Waiting     int        `json:"waiting"`
+}
+
+// oauthSessionStatus is a flow record as listed to the management key that started it.
+type oauthSessionStatus struct {
+	State     string    `json:"state"`
+	Provider  string    `json:"provider"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// list returns the login queue of every provider and the flows principal started, newest first.
+func (s *oauthSessionStore) list(principal string) ([]oauthQueueStatus, []oauthSessionStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	queues := make([]oauthQueueStatus, 0, len(s.queues))
+	for provider, q := range s.queues {
+		s.expire(provider, q, now, oauthTimedOutError)
+		status := oauthQueueStatus{Provider: provider, Active: q.held, Waiting: q.waiting}
+		if q.held {
+			since := q.since
+			status.ActiveSince = &since
+			status.Mine = q.holder == principal
+		}
+		queues = append(queues, status)
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Provider < queues[j].Provider })
+	sessions := make([]oauthSessionStatus, 0)
+	for state, session := range s.sessions {
+		if session.Principal != principal {
+			continue
+		}
+		sessions = append(sessions, oauthSessionStatus{
+			State:     state,
+			Provider:  session.Provider,
+			Status:    session.Status,
+			Error:     session.Error,
+			CreatedAt: session.CreatedAt,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return queues, sessions
+}
+
+// GetOAuthSessions lists the login queue of each provider, without revealing who holds it,
+// and the flows the calling management key started.
+func (h *Handler) GetOAuthSessions(c *gin.Context) {
+	queues, sessions := oauthSessions.list(managementPrincipal(c))
+	c.JSON(http.StatusOK, gin.H{"queues": queues, "sessions": sessions})
+}
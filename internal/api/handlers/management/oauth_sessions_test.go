@@ -0,0 +1,74 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestOAuthStore() *oauthSessionStore {
+	s := &oauthSessionStore{sessions: make(map[string]*oauthSession), queues: make(map[string]*oauthLoginQueue)}
+	s.once.Do(func() {})
+	return s
+}
+
+// startTestFlow takes the login slot of provider for principal and starts a flow with state.
+func startTestFlow(t *testing.T, s *oauthSessionStore, provider, principal, state string) {
+	t.Helper()
+	got, err := s.acquire(context.Background(), provider, principal)
+	if err != nil || got != "" {
+		t.Fatalf("acquire = %q, %v, want the slot", got, err)
+	}
+	s.start(state, provider, principal, "https://login.example/"+state, "")
+}
+
+func TestOAuthQueueWaitEndsBeforeTheLeaderTimeout(t *testing.T) {
+	if oauthQueueWait >= leaderTimeout {
+		t.Fatalf("oauthQueueWait = %s, want less than leaderTimeout %s", oauthQueueWait, leaderTimeout)
+	}
+}
+
+func TestQueuedOAuthRequestGivesUpAfterTheQueueWait(t *testing.T) {
+	previous := oauthQueueWait
+	oauthQueueWait = 50 * time.Millisecond
+	t.Cleanup(func() { oauthQueueWait = previous })
+
+	s := newTestOAuthStore()
+	startTestFlow(t, s, "codex", "a", "state-a")
+
+	started := time.Now()
+	_, err := s.acquire(context.Background(), "codex", "b")
+	var busy *errOAuthBusy
+	if !errors.As(err, &busy) || !busy.Waited {
+		t.Fatalf("acquire error = %v, want a busy error after waiting", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("acquire waited %s, want about the queue wait", elapsed)
+	}
+	if busy.RetryAfter <= 0 || busy.RetryAfter > oauthFlowTimeout {
+		t.Fatalf("RetryAfter = %s, want the time left of the running flow", busy.RetryAfter)
+	}
+	if waiting := s.queues["codex"].waiting; waiting != 0 {
+		t.Fatalf("waiting = %d after giving up, want 0", waiting)
+	}
+}
+
+func TestExpiredOAuthFlowNamesWhyItFailed(t *testing.T) {
+	s := newTestOAuthStore()
+	past := time.Now().Add(-oauthFlowTimeout - time.Second)
+
+	startTestFlow(t, s, "qwen", "a", "state-1")
+	s.queues["qwen"].since = past
+	s.sweep(time.Now())
+	if session, _ := s.get("state-1"); session.Status != oauthStatusError || session.Error != oauthTimedOutError {
+		t.Fatalf("swept flow = %+v, want it timed out", session)
+	}
+
+	startTestFlow(t, s, "qwen", "a", "state-2")
+	s.queues["qwen"].since = past
+	startTestFlow(t, s, "qwen", "b", "state-3")
+	if session, _ := s.get("state-2"); session.Status != oauthStatusError || session.Error != oauthSupersededError {
+		t.Fatalf("replaced flow = %+v, want it superseded", session)
+	}
+}
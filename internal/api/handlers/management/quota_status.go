@@ -0,0 +1,91 @@
+package management
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// quotaModelStatus reports the cooldown of one model under an auth.
+type quotaModelStatus struct {
+	Model          string     `json:"model"`
+	Status         string     `json:"status"`
+	QuotaExceeded  bool       `json:"quota_exceeded"`
+	NextRetryAfter *time.Time `json:"next_retry_after,omitempty"`
+}
+
+// quotaAuthStatus reports quota and cooldown state for one auth.
+type quotaAuthStatus struct {
+	ID             string             `json:"id"`
+	Provider       string             `json:"provider"`
+	Label          string             `json:"label,omitempty"`
+	Status         string             `json:"status"`
+	Disabled       bool               `json:"disabled"`
+	QuotaExceeded  bool               `json:"quota_exceeded"`
+	NextRecoverAt  *time.Time         `json:"next_recover_at,omitempty"`
+	NextRetryAfter *time.Time         `json:"next_retry_after,omitempty"`
+	Models         []quotaModelStatus `json:"models,omitempty"`
+	// Maintenance is set while the auth is in maintenance or has a scheduled window ahead.
+	Maintenance *coreauth.MaintenanceState `json:"maintenance,omitempty"`
+	// Schedule is set when the auth has active hours.
+	Schedule *coreauth.ScheduleState `json:"schedule,omitempty"`
+}
+
+// GetQuotaStatus returns quota, cooldown, maintenance and schedule state for every auth plus the Gemini
+// Web standby pool.
+func (h *Handler) GetQuotaStatus(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(503, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	now := time.Now()
+	auths := h.authManager.List()
+	out := make([]quotaAuthStatus, 0, len(auths))
+	for _, auth := range auths {
+		entry := quotaAuthStatus{
+			ID:             auth.ID,
+			Provider:       auth.Provider,
+			Label:          auth.Label,
+			Status:         string(auth.Status),
+			Disabled:       auth.Disabled,
+			QuotaExceeded:  auth.Quota.Exceeded,
+			NextRecoverAt:  futureTime(auth.Quota.NextRecoverAt, now),
+			NextRetryAfter: futureTime(auth.NextRetryAfter, now),
+		}
+		if maintenance := h.authManager.Maintenance(auth); maintenance.Active || maintenance.NextStart != nil {
+			entry.Maintenance = &maintenance
+		}
+		if schedule, ok := h.authManager.Schedule(auth); ok {
+			entry.Schedule = &schedule
+		}
+		for model, state := range auth.ModelStates {
+			if state == nil {
+				continue
+			}
+			entry.Models = append(entry.Models, quotaModelStatus{
+				Model:          model,
+				Status:         string(state.Status),
+				QuotaExceeded:  state.Quota.Exceeded,
+				NextRetryAfter: futureTime(state.NextRetryAfter, now),
+			})
+		}
+		sort.Slice(entry.Models, func(i, j int) bool { return entry.Models[i].Model < entry.Models[j].Model })
+		out = append(out, entry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	c.JSON(200, gin.H{
+		"auths":           out,
+		"gemini-web-pool": executor.GeminiWebPoolSnapshot(),
+	})
+}
+
+// futureTime returns t when it lies after now, nil otherwise.
+func futureTime(t, now time.Time) *time.Time {
+	if !t.After(now) {
+		return nil
+	}
+	return &t
+}
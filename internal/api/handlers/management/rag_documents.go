@@ -0,0 +1,119 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/docstore"
+)
+
+// ragDocumentRequest is the body accepted by UploadRAGDocument.
+type ragDocumentRequest struct {
+	// APIKey is the client API key owning the store.
+	APIKey string `json:"api_key"`
+	// Store names the document store, created on first upload.
+	Store string `json:"store"`
+	// Name is a display name shown in retrieval citations; defaults to the document ID.
+	Name string `json:"name"`
+	// Content is the plain text of the document.
+	Content string `json:"content"`
+}
+
+// ListRAGDocuments returns the documents of one store of a client API key.
+func (h *Handler) ListRAGDocuments(c *gin.Context) {
+	if !h.ragEnabled(c) {
+		return
+	}
+	apiKey, store := strings.TrimSpace(c.Query("api_key")), strings.TrimSpace(c.Query("store"))
+	if apiKey == "" || store == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "api_key and store are required"})
+		return
+	}
+	docs, err := docstore.New(h.cfg.AuthDir).List(apiKey, store)
+	if err != nil {
+		writeDocstoreError(c, err)
+		return
+	}
+	if docs == nil {
+		docs = []docstore.Document{}
+	}
+	c.JSON(http.StatusOK, gin.H{"documents": docs})
+}
+
+// UploadRAGDocument chunks, embeds and stores a text document. Embedding runs before the
+// response is written, so large documents take a while.
+func (h *Handler) UploadRAGDocument(c *gin.Context) {
+	if !h.ragEnabled(c) {
+		return
+	}
+	if h.embedder == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "embedding unavailable"})
+		return
+	}
+	var body ragDocumentRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	apiKey, store := strings.TrimSpace(body.APIKey), strings.TrimSpace(body.Store)
+	if apiKey == "" || store == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "api_key and store are required"})
+		return
+	}
+	rag := h.cfg.RAG
+	limits := docstore.Limits{
+		MaxDocumentBytes: rag.MaxDocumentBytes,
+		MaxDocuments:     rag.MaxDocuments,
+		ChunkSize:        rag.ChunkSize,
+		ChunkOverlap:     rag.ChunkOverlap,
+	}
+	doc, err := docstore.New(h.cfg.AuthDir).Add(c.Request.Context(), apiKey, store, body.Name, body.Content, h.embedder, limits)
+	if err != nil {
+		writeDocstoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"document": doc})
+}
+
+// DeleteRAGDocument removes one document and its chunks from a store.
+func (h *Handler) DeleteRAGDocument(c *gin.Context) {
+	if !h.ragEnabled(c) {
+		return
+	}
+	apiKey, store, id := strings.TrimSpace(c.Query("api_key")), strings.TrimSpace(c.Query("store")), strings.TrimSpace(c.Query("id"))
+	if apiKey == "" || store == "" || id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "api_key, store and id are required"})
+		return
+	}
+	if err := docstore.New(h.cfg.AuthDir).Delete(apiKey, store, id); err != nil {
+		writeDocstoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ragEnabled writes a 404 and returns false when the document store is switched off.
+func (h *Handler) ragEnabled(c *gin.Context) bool {
+	if h.cfg == nil || !h.cfg.RAG.Enable {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rag is disabled"})
+		return false
+	}
+	return true
+}
+
+func writeDocstoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, docstore.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, docstore.ErrInvalidName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, docstore.ErrRejected):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, docstore.ErrEmbedding):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,147 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
+)
+
+// maxExportHistory bounds the earlier requests included with include_history.
+const maxExportHistory = 50
+
+// ExportRequestLog rebuilds the conversation of the request logged under the X-Request-ID
+// in the path and returns it as Markdown (default) or, with ?format=json, as a normalized
+// JSON transcript. With ?include_history=true the earlier requests of its conversation, those
+// it continues through previous_response_id and those sent under the same session or
+// conversation ID, are included oldest first as long as their logs are kept.
+func (h *Handler) ExportRequestLog(c *gin.Context) {
+	if h.requestLogDir == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request logs unavailable"})
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "markdown")))
+	if format != "markdown" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be markdown or json"})
+		return
+	}
+	includeHistory := false
+	if raw := c.Query("include_history"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_history"})
+			return
+		}
+		includeHistory = parsed
+	}
+
+	record, err := logging.FindRequestLog(h.requestLogDir, id, func(r *logging.RequestLogRecord) bool {
+		return r.RequestID() == id
+	})
+	if err != nil {
+		writeRequestLogError(c, err)
+		return
+	}
+	current, err := transcript.Build(record)
+	if err != nil {
+		writeRequestLogError(c, err)
+		return
+	}
+	chain := []*transcript.Transcript{current}
+	if includeHistory {
+		chain, err = h.conversationHistory(current)
+		if err != nil {
+			writeRequestLogError(c, err)
+			return
+		}
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"requests": chain})
+		return
+	}
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(transcript.Markdown(chain)))
+}
+
+// conversationHistory returns the transcripts of the requests t continues, oldest first,
+// ending with t: the chain followed through previous_response_id, which stops at the first
+// request whose log is gone, and the earlier requests sent under t's conversation ID. The
+// logs are read once.
+func (h *Handler) conversationHistory(t *transcript.Transcript) ([]*transcript.Transcript, error) {
+	if t.PreviousResponseID == "" && t.ConversationID == "" {
+		return []*transcript.Transcript{t}, nil
+	}
+	var needles []string
+	if t.PreviousResponseID != "" {
+		// Only Responses API logs can hold the requests of the chain.
+		needles = append(needles, "/responses")
+	}
+	if t.ConversationID != "" {
+		needles = append(needles, t.ConversationID)
+	}
+	byResponse := make(map[string]*transcript.Transcript)
+	var sameConversation []*transcript.Transcript
+	err := logging.ScanRequestLogs(h.requestLogDir, needles, func(r *logging.RequestLogRecord) bool {
+		if r.RequestID() == t.RequestID {
+			return true
+		}
+		candidate, errBuild := transcript.Build(r)
+		if errBuild != nil {
+			return true
+		}
+		if candidate.ResponseID != "" {
+			if _, seen := byResponse[candidate.ResponseID]; !seen {
+				byResponse[candidate.ResponseID] = candidate
+			}
+		}
+		if t.ConversationID != "" && candidate.ConversationID == t.ConversationID && candidate.Timestamp.Before(t.Timestamp) {
+			sameConversation = append(sameConversation, candidate)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	included := map[*transcript.Transcript]bool{}
+	var history []*transcript.Transcript
+	seen := map[string]bool{t.ResponseID: true}
+	for previous := t.PreviousResponseID; previous != "" && !seen[previous]; {
+		seen[previous] = true
+		found := byResponse[previous]
+		if found == nil {
+			break
+		}
+		included[found] = true
+		history = append(history, found)
+		previous = found.PreviousResponseID
+	}
+	for _, candidate := range sameConversation {
+		if !included[candidate] {
+			included[candidate] = true
+			history = append(history, candidate)
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp.Before(history[j].Timestamp) })
+	if len(history) > maxExportHistory {
+		history = history[len(history)-maxExportHistory:]
+	}
+	return append(history, t), nil
+}
+
+func writeRequestLogError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, logging.ErrRequestLogNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "no request log with this request id"})
+	case errors.Is(err, transcript.ErrUnsupported):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,69 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// writeRequestLog writes a request log in the layout of the request logger, minute minutes
+// after a fixed time.
+func writeRequestLog(t *testing.T, dir string, minute int, requestID, sessionID, body, response string) {
+	t.Helper()
+	at := time.Date(2025, 9, 1, 12, minute, 0, 0, time.UTC)
+	content := fmt.Sprintf("=== REQUEST INFO ===\nURL: /v1/responses\nMethod: POST\nTimestamp: %s\n\n"+
+		"=== HEADERS ===\nSession_id: %s\n\n=== REQUEST BODY ===\n%s\n\n"+
+		"=== RESPONSE ===\nStatus: 200\nX-Request-Id: %s\n\n%s\n",
+		at.Format(time.RFC3339Nano), sessionID, body, requestID, response)
+	path := filepath.Join(dir, fmt.Sprintf("v1-responses-2025-09-01T12%02d00-000000001.log", minute))
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportIncludesHistoryOfChainAndSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	writeRequestLog(t, dir, 0, "req-1", "sess-a", `{"input":"first"}`, `{"id":"resp_1","output":[]}`)
+	writeRequestLog(t, dir, 1, "req-2", "sess-b", `{"input":"second","previous_response_id":"resp_1"}`, `{"id":"resp_2","output":[]}`)
+	writeRequestLog(t, dir, 2, "req-3", "sess-b", `{"input":"third"}`, `{"id":"resp_3","output":[]}`)
+	writeRequestLog(t, dir, 3, "req-4", "sess-b", `{"input":"fourth","previous_response_id":"resp_2"}`, `{"id":"resp_4","output":[]}`)
+	writeRequestLog(t, dir, 4, "req-5", "sess-c", `{"input":"other"}`, `{"id":"resp_5","output":[]}`)
+
+	h := NewHandler(&config.Config{}, "", nil)
+	h.SetRequestLogDir(dir)
+	engine := gin.New()
+	engine.GET("/request-logs/:id/export", h.ExportRequestLog)
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/request-logs/req-4/export?format=json&include_history=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Requests []struct {
+			RequestID string `json:"request_id"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range resp.Requests {
+		ids = append(ids, r.RequestID)
+	}
+	// req-1 through the chain, req-2 through both, req-3 through the session.
+	if fmt.Sprint(ids) != "[req-1 req-2 req-3 req-4]" {
+		t.Fatalf("requests = %v", ids)
+	}
+}
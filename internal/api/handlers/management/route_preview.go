@@ -0,0 +1,99 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// routePreviewRequest is the body accepted by PostRoutePreview.
+type routePreviewRequest struct {
+	// Dialect is the client API format of Request: openai, openai-response, claude, gemini or gemini-cli.
+	Dialect string `json:"dialect"`
+	// Model overrides the model read from Request; required for Gemini dialects where it is part of the URL.
+	Model string `json:"model"`
+	// Provider mirrors the X-Provider request header.
+	Provider string `json:"provider"`
+	// IgnoreSchedule mirrors the X-CLIProxy-Ignore-Schedule request header.
+	IgnoreSchedule bool `json:"ignore_schedule"`
+	// Request is the request body exactly as the client would send it.
+	Request json.RawMessage `json:"request"`
+}
+
+// PostRoutePreview runs provider and auth selection for a request without dispatching it and
+// returns the decision trace.
+func (h *Handler) PostRoutePreview(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(503, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body routePreviewRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	dialect := strings.ToLower(strings.TrimSpace(body.Dialect))
+	switch dialect {
+	case "openai", "openai-response", "claude", "gemini", "gemini-cli":
+	default:
+		c.JSON(400, gin.H{"error": fmt.Sprintf("unsupported dialect %q", body.Dialect)})
+		return
+	}
+	model := strings.TrimSpace(body.Model)
+	if model == "" {
+		model = gjson.GetBytes(body.Request, "model").String()
+	}
+	if model == "" {
+		c.JSON(400, gin.H{"error": "model is required"})
+		return
+	}
+
+	rules := make([]string, 0, 3)
+	if id, ok := registry.GetGlobalRegistry().ResolveModelID(model); ok && id != model {
+		if h.cfg != nil && h.cfg.StrictModelNames {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("unknown model %q: the model ID is %q (names must match exactly)", model, id)})
+			return
+		}
+		rules = append(rules, fmt.Sprintf("model name normalized: %s", id))
+		model = id
+	}
+	providers := util.GetProviderName(model, h.cfg)
+	if override := strings.TrimSpace(body.Provider); override != "" {
+		restricted, err := util.ApplyProviderOverride(providers, override)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		providers = restricted
+		rules = append(rules, fmt.Sprintf("provider override: %s", providers[0]))
+	}
+	if len(providers) == 0 {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("unknown provider for model %s", model)})
+		return
+	}
+
+	opts := coreexecutor.Options{
+		Stream:          gjson.GetBytes(body.Request, "stream").Bool(),
+		OriginalRequest: body.Request,
+		SourceFormat:    sdktranslator.FromString(dialect),
+	}
+	ctx := c.Request.Context()
+	if body.IgnoreSchedule {
+		ctx = coreauth.WithScheduleOverride(ctx)
+		rules = append(rules, "active hours ignored")
+	}
+	decision := h.authManager.PreviewRoute(ctx, providers, model, opts)
+	c.JSON(200, gin.H{
+		"dialect": dialect,
+		"rules":   rules,
+		"route":   decision,
+	})
+}
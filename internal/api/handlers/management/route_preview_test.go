@@ -0,0 +1,92 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// idleExecutor is registered so previews reach auth selection; it is never called.
+type idleExecutor struct{}
+
+func (idleExecutor) Identifier() string { return "claude" }
+
+func (idleExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not called")
+}
+
+func (idleExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, errors.New("not called")
+}
+
+func (idleExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (idleExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not called")
+}
+
+func TestRoutePreviewCanIgnoreTheSchedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("preview-night", "claude", []*registry.ModelInfo{{ID: "preview-test-model"}})
+	t.Cleanup(func() { reg.UnregisterClient("preview-night") })
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(idleExecutor{})
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "preview-night", Provider: "claude", Status: coreauth.StatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC().Add(2 * time.Hour)
+	h, m, _ := start.Clock()
+	at := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+	manager.SetActiveHours("claude", &coreauth.ActiveHours{Start: at, End: at + time.Hour, Location: time.UTC})
+
+	handler := NewHandler(&config.Config{}, "", manager)
+	engine := gin.New()
+	engine.POST("/route-preview", handler.PostRoutePreview)
+
+	preview := func(body string) (rules []string, selected string, reason string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/route-preview", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var out struct {
+			Rules []string               `json:"rules"`
+			Route coreauth.RouteDecision `json:"route"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Route.Selected != nil {
+			selected = out.Route.Selected.AuthID
+		}
+		if len(out.Route.Candidates) > 0 {
+			reason = out.Route.Candidates[0].Reason
+		}
+		return out.Rules, selected, reason
+	}
+
+	if _, selected, reason := preview(`{"dialect":"claude","request":{"model":"preview-test-model"}}`); selected != "" || reason != coreauth.BlockReasonQuietHours {
+		t.Fatalf("selected %q with reason %q, want the account held back by its hours", selected, reason)
+	}
+	rules, selected, _ := preview(`{"dialect":"claude","ignore_schedule":true,"request":{"model":"preview-test-model"}}`)
+	if selected != "preview-night" || !slices.Contains(rules, "active hours ignored") {
+		t.Fatalf("selected %q with rules %q, want the account picked with the schedule ignored", selected, rules)
+	}
+}
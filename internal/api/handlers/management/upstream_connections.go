@@ -0,0 +1,14 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// GetUpstreamConnections returns, for each shared upstream transport, how many requests
+// opened a new connection and how many reused a pooled one.
+func (h *Handler) GetUpstreamConnections(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"transports": util.UpstreamConnectionStats()})
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MethodNotAllowed answers a request for a known path with a method the path does not
+// serve. Gin has listed the served methods in Allow; OPTIONS, answered for every path, is
+// added. The error body follows the API the path belongs to, so SDKs report it as an API
+// error: Gemini for /v1beta and /v1internal, Claude for /v1/messages and OpenAI for the
+// rest of /v1.
+func MethodNotAllowed(c *gin.Context) {
+	allowed := c.Writer.Header().Get("Allow")
+	if allowed != "" {
+		allowed += ", " + http.MethodOptions
+		c.Header("Allow", allowed)
+	}
+	status := http.StatusMethodNotAllowed
+	path := c.Request.URL.Path
+	message := fmt.Sprintf("method %s is not allowed for %s; allowed methods: %s", c.Request.Method, path, allowed)
+	switch {
+	case strings.HasPrefix(path, "/v1beta/"), strings.HasPrefix(path, "/v1internal"):
+		c.JSON(status, gin.H{"error": gin.H{"code": status, "message": message, "status": GeminiErrorStatus(status)}})
+	case path == "/v1/messages" || strings.HasPrefix(path, "/v1/messages/"):
+		c.JSON(status, gin.H{"type": "error", "error": gin.H{"type": claudeErrorType(status), "message": message}})
+	case strings.HasPrefix(path, "/v1/"):
+		c.JSON(status, ErrorResponse{Error: ErrorDetail{Message: message, Type: "invalid_request_error", Code: "method_not_allowed"}})
+	default:
+		c.JSON(status, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultMirrorConcurrency = 4
+	defaultMirrorTimeout     = 300 * time.Second
+)
+
+// mirrorsRunning counts the shadow requests in progress.
+var mirrorsRunning atomic.Int64
+
+// SetRequestLogger sets the request logger shadow requests are written to.
+func (h *BaseAPIHandler) SetRequestLogger(logger logging.RequestLogger) { h.requestLogger = logger }
+
+// mirrorCandidate is the request a client request may be mirrored as: the first request its
+// handler executed. Internal requests made on the same context later do not replace it.
+type mirrorCandidate struct {
+	mu          sync.Mutex
+	set         bool
+	handlerType string
+	modelName   string
+	rawJSON     []byte
+}
+
+type mirrorCandidateKey struct{}
+
+// noteMirrorCandidate records the request a handler executes on ctx as the one to mirror,
+// unless one was recorded already.
+func noteMirrorCandidate(ctx context.Context, handlerType, modelName string, rawJSON []byte) {
+	candidate, ok := ctx.Value(mirrorCandidateKey{}).(*mirrorCandidate)
+	if !ok {
+		return
+	}
+	candidate.mu.Lock()
+	defer candidate.mu.Unlock()
+	if candidate.set {
+		return
+	}
+	candidate.set = true
+	candidate.handlerType, candidate.modelName, candidate.rawJSON = handlerType, modelName, cloneBytes(rawJSON)
+}
+
+// handlerSucceeded reports whether the parameters a handler passed to its cancel function
+// describe a success, that is carry no error.
+func handlerSucceeded(params []interface{}) bool {
+	for _, param := range params {
+		if err, ok := param.(error); ok && err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// mirrorRequest schedules the shadow of the client request of ctx, once its handler has
+// succeeded, when a mirroring rule covers it and it is sampled. It runs from the handler's
+// cancel function, so only requests clients sent are mirrored. The shadow starts once the
+// primary request is over, so it cannot delay or alter the response, and runs without
+// streaming whatever the primary did. Requests carrying X-Provider-Key are never mirrored:
+// their key was granted for them alone and the shadow would run on a stored account.
+func (h *BaseAPIHandler) mirrorRequest(ctx context.Context, candidate *mirrorCandidate) {
+	if h.Cfg == nil || len(h.Cfg.Mirroring.Rules) == 0 || clientCredential(ctx) != "" {
+		return
+	}
+	candidate.mu.Lock()
+	set, handlerType, modelName, rawJSON := candidate.set, candidate.handlerType, candidate.modelName, candidate.rawJSON
+	candidate.mu.Unlock()
+	if !set {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return
+	}
+	apiKey := ginCtx.GetString("apiKey")
+	rule, ok := h.Cfg.Mirroring.Match(apiKey, modelName)
+	if !ok || strings.TrimSpace(rule.Provider) == "" || strings.TrimSpace(rule.Model) == "" {
+		return
+	}
+	if rand.Float64()*100 >= rule.Percent {
+		return
+	}
+	limit := int64(h.Cfg.Mirroring.MaxConcurrency)
+	if limit <= 0 {
+		limit = defaultMirrorConcurrency
+	}
+	if mirrorsRunning.Add(1) > limit {
+		mirrorsRunning.Add(-1)
+		log.Debugf("mirroring: %d shadow requests running, not mirroring request for model %s", limit, modelName)
+		return
+	}
+	timeout := defaultMirrorTimeout
+	if h.Cfg.Mirroring.Timeout > 0 {
+		timeout = time.Duration(h.Cfg.Mirroring.Timeout) * time.Second
+	}
+
+	shadow := logging.ShadowRequest{PrimaryRequestID: newShadowID(), APIKey: apiKey}
+	logging.RecordMirrorNote(ctx, fmt.Sprintf("shadow %s sent to %s/%s", shadow.PrimaryRequestID, rule.Provider, rule.Model))
+	path := ginCtx.Request.URL.Path
+	primaryDone := ginCtx.Request.Context().Done()
+	payload := shadowPayload(cloneBytes(rawJSON))
+	go func() {
+		defer mirrorsRunning.Add(-1)
+		<-primaryDone
+		shadowCtx, cancel := context.WithTimeout(logging.WithShadowRequest(context.Background(), shadow), timeout)
+		defer cancel()
+		h.runShadow(shadowCtx, shadow, rule, handlerType, path, payload)
+	}()
+}
+
+// newShadowID returns a random ID linking a shadow request to its primary.
+func newShadowID() string {
+	buf := make([]byte, 8)
+	_, _ = crand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// runShadow sends the shadow request and records its outcome. Failures are counted and
+// logged, never reported anywhere a client could see them.
+func (h *BaseAPIHandler) runShadow(ctx context.Context, shadow logging.ShadowRequest, rule config.MirrorRule, handlerType, path string, payload []byte) {
+	req := coreexecutor.Request{Model: rule.Model, Payload: payload}
+	opts := coreexecutor.Options{
+		Stream:          false,
+		OriginalRequest: payload,
+		SourceFormat:    sdktranslator.FromString(handlerType),
+	}
+	status := http.StatusOK
+	var response []byte
+	var errs []*interfaces.ErrorMessage
+	resp, err := h.AuthManager.Execute(ctx, []string{rule.Provider}, req, opts)
+	if err != nil {
+		errMsg := errorMessageFromExecution(err)
+		status = errMsg.StatusCode
+		errs = append(errs, errMsg)
+		usage.GetRequestStatistics().RecordShadowFailure()
+		log.Debugf("mirroring: shadow of request %s on %s/%s failed: %v", shadow.PrimaryRequestID, rule.Provider, rule.Model, err)
+	} else {
+		response = resp.Payload
+	}
+	if h.requestLogger == nil || !h.requestLogger.IsEnabled() {
+		return
+	}
+	headers := map[string][]string{
+		"X-Shadow-Of":     {shadow.PrimaryRequestID},
+		"X-Shadow-Target": {rule.Provider + "/" + rule.Model},
+	}
+	if errLog := h.requestLogger.LogRequest("/shadow"+path, http.MethodPost, headers, payload, status, nil, response, nil, nil, errs); errLog != nil {
+		log.Warnf("mirroring: failed to log shadow of request %s: %v", shadow.PrimaryRequestID, errLog)
+	}
+}
+
+// shadowPayload turns off streaming in a request body of the OpenAI or Claude formats,
+// which ask for it in the body rather than through the endpoint.
+func shadowPayload(rawJSON []byte) []byte {
+	if gjson.GetBytes(rawJSON, "stream").Exists() {
+		rawJSON, _ = sjson.SetBytes(rawJSON, "stream", false)
+	}
+	if gjson.GetBytes(rawJSON, "stream_options").Exists() {
+		rawJSON, _ = sjson.DeleteBytes(rawJSON, "stream_options")
+	}
+	return rawJSON
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// mirrorExecutor answers every request with a body naming its provider and the model asked
+// for.
+type mirrorExecutor struct {
+	bodyExecutor
+	provider string
+}
+
+func (e mirrorExecutor) Identifier() string { return e.provider }
+
+func (e mirrorExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: []byte(`{"provider":"` + e.provider + `","model":"` + req.Model + `"}`)}, nil
+}
+
+// loggedRequest is one request passed to a recordingLogger.
+type loggedRequest struct {
+	url      string
+	headers  map[string][]string
+	body     []byte
+	response []byte
+}
+
+// recordingLogger passes the requests it is asked to log to a channel.
+type recordingLogger struct {
+	logging.RequestLogger
+	logged chan loggedRequest
+}
+
+func (l *recordingLogger) IsEnabled() bool { return true }
+
+func (l *recordingLogger) LogRequest(url, _ string, headers map[string][]string, body []byte, _ int, _ map[string][]string, response, _, _ []byte, _ []*interfaces.ErrorMessage) error {
+	l.logged <- loggedRequest{url: url, headers: headers, body: body, response: response}
+	return nil
+}
+
+func newMirrorTestHandler(t *testing.T, mirror bool) (*BaseAPIHandler, *recordingLogger) {
+	t.Helper()
+	cfg := &config.Config{}
+	if mirror {
+		cfg.Mirroring.Rules = []config.MirrorRule{{Provider: "mirror-shadow", Model: "mirror-shadow-model", Percent: 100}}
+	}
+	h := newTestHandler(t, cfg, mirrorExecutor{provider: "mirror-primary"}, "mirror-primary-model")
+	h.AuthManager.RegisterExecutor(mirrorExecutor{provider: "mirror-shadow"})
+	if _, err := h.AuthManager.Register(context.Background(), &coreauth.Auth{ID: "mirror-shadow", Provider: "mirror-shadow", Status: coreauth.StatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	logger := &recordingLogger{logged: make(chan loggedRequest, 4)}
+	h.SetRequestLogger(logger)
+	return h, logger
+}
+
+// serveMirrorTest runs a client request through h the way the handlers do, with run
+// executing it, and ends it.
+func serveMirrorTest(h *BaseAPIHandler, run func(c *gin.Context, ctx context.Context) error) (*httptest.ResponseRecorder, *gin.Context) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	reqCtx, done := context.WithCancel(context.Background())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(reqCtx)
+	c.Request.Header.Set("X-Request-ID", "client-chosen")
+	ctx, cancel := h.GetContextWithCancel(testAPIHandler{}, c, context.Background())
+	if err := run(c, ctx); err != nil {
+		cancel(err)
+	} else {
+		cancel()
+	}
+	done()
+	return rec, c
+}
+
+func writePrimary(h *BaseAPIHandler) func(*gin.Context, context.Context) error {
+	return func(c *gin.Context, ctx context.Context) error {
+		if errMsg := h.WriteNonStreamWithAuthManager(c, ctx, "openai", "mirror-primary-model", []byte(`{"model":"mirror-primary-model","stream":false}`), ""); errMsg != nil {
+			return errMsg.Error
+		}
+		return nil
+	}
+}
+
+func awaitShadow(t *testing.T, logger *recordingLogger) loggedRequest {
+	t.Helper()
+	select {
+	case logged := <-logger.logged:
+		return logged
+	case <-time.After(5 * time.Second):
+		t.Fatal("shadow request not recorded")
+	}
+	return loggedRequest{}
+}
+
+func TestMirroringLeavesClientResponseUnchanged(t *testing.T) {
+	hOff, _ := newMirrorTestHandler(t, false)
+	off, _ := serveMirrorTest(hOff, writePrimary(hOff))
+	hOn, logger := newMirrorTestHandler(t, true)
+	on, _ := serveMirrorTest(hOn, writePrimary(hOn))
+	awaitShadow(t, logger)
+
+	if off.Code != on.Code || off.Body.String() != on.Body.String() {
+		t.Fatalf("response with mirroring %d %q, without %d %q", on.Code, on.Body.String(), off.Code, off.Body.String())
+	}
+	if len(off.Header()) != len(on.Header()) {
+		t.Fatalf("headers with mirroring %v, without %v", on.Header(), off.Header())
+	}
+	for key := range off.Header() {
+		if off.Header().Get(key) != on.Header().Get(key) {
+			t.Fatalf("header %s with mirroring %q, without %q", key, on.Header().Get(key), off.Header().Get(key))
+		}
+	}
+}
+
+func TestMirroringRecordsShadowLinkedToPrimary(t *testing.T) {
+	h, logger := newMirrorTestHandler(t, true)
+	_, c := serveMirrorTest(h, writePrimary(h))
+	shadow := awaitShadow(t, logger)
+
+	if shadow.url != "/shadow/v1/chat/completions" {
+		t.Fatalf("shadow logged under %q", shadow.url)
+	}
+	if !strings.Contains(string(shadow.response), `"provider":"mirror-shadow","model":"mirror-shadow-model"`) {
+		t.Fatalf("shadow response %s", shadow.response)
+	}
+	if strings.Contains(string(shadow.body), `"stream":true`) {
+		t.Fatalf("shadow request streams: %s", shadow.body)
+	}
+	id := shadow.headers["X-Shadow-Of"][0]
+	if id == "" || id == "client-chosen" {
+		t.Fatalf("shadow linked to %q, want a generated ID", id)
+	}
+	if section := logging.MirrorSection(c); !strings.Contains(section, id) {
+		t.Fatalf("primary request log does not name shadow %s: %q", id, section)
+	}
+}
+
+func TestMirroringOnlyMirrorsTheClientRequest(t *testing.T) {
+	h, logger := newMirrorTestHandler(t, true)
+	serveMirrorTest(h, func(_ *gin.Context, ctx context.Context) error {
+		if _, errMsg := h.ExecuteWithAuthManager(ctx, "openai", "mirror-primary-model", []byte(`{"client":true}`), ""); errMsg != nil {
+			return errMsg.Error
+		}
+		// A request made internally on the client's context, such as a helper call.
+		if _, errMsg := h.ExecuteWithAuthManager(ctx, "openai", "mirror-primary-model", []byte(`{"internal":true}`), ""); errMsg != nil {
+			return errMsg.Error
+		}
+		return nil
+	})
+	if shadow := awaitShadow(t, logger); string(shadow.body) != `{"client":true}` {
+		t.Fatalf("mirrored %s, want the client request", shadow.body)
+	}
+	select {
+	case extra := <-logger.logged:
+		t.Fatalf("second shadow recorded: %s", extra.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMirroringSkipsFailedRequests(t *testing.T) {
+	h, logger := newMirrorTestHandler(t, true)
+	serveMirrorTest(h, func(_ *gin.Context, ctx context.Context) error {
+		noteMirrorCandidate(ctx, "openai", "mirror-primary-model", []byte(`{}`))
+		return errors.New("upstream failed")
+	})
+	select {
+	case shadow := <-logger.logged:
+		t.Fatalf("failed request mirrored: %s", shadow.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// modelPolicy returns the model policy of the client API key c was authenticated with.
+func (h *BaseAPIHandler) modelPolicy(c *gin.Context) (config.ModelPolicy, bool) {
+	if h.Cfg == nil || c == nil || len(h.Cfg.ModelPolicy.APIKeys) == 0 {
+		return config.ModelPolicy{}, false
+	}
+	apiKey := c.GetString("apiKey")
+	if apiKey == "" {
+		return config.ModelPolicy{}, false
+	}
+	return h.Cfg.ModelPolicy.For(apiKey)
+}
+
+// ApplyDefaultModel sets the model of a request body that leaves it out to the default-model
+// of the calling key's policy, so the request is validated and routed as if the client had
+// named it.
+func (h *BaseAPIHandler) ApplyDefaultModel(c *gin.Context, rawJSON []byte) []byte {
+	policy, ok := h.modelPolicy(c)
+	if !ok || strings.TrimSpace(policy.DefaultModel) == "" {
+		return rawJSON
+	}
+	if model := gjson.GetBytes(rawJSON, "model"); model.Exists() && model.String() != "" {
+		return rawJSON
+	}
+	updated, err := sjson.SetBytes(rawJSON, "model", strings.TrimSpace(policy.DefaultModel))
+	if err != nil {
+		return rawJSON
+	}
+	logging.RecordModelPolicyNote(c, "default-model "+strings.TrimSpace(policy.DefaultModel)+" applied")
+	return updated
+}
+
+// checkModelPolicy rejects a request for model, the registered ID the client's model name
+// resolved to, with 403 when the policy of the calling key does not allow it. The decision
+// is noted in the request log.
+func (h *BaseAPIHandler) checkModelPolicy(ctx context.Context, model string) *interfaces.ErrorMessage {
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	policy, ok := h.modelPolicy(ginCtx)
+	if !ok {
+		return nil
+	}
+	denial := policy.Denial(model)
+	if denial == "" {
+		logging.RecordModelPolicyNote(ctx, "model "+model+" allowed")
+		return nil
+	}
+	logging.RecordModelPolicyNote(ctx, "model "+model+" denied by "+denial)
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusForbidden,
+		Error:      fmt.Errorf("model %s is not available to this API key: denied by the model policy (%s)", model, denial),
+		Kind:       coreexecutor.ErrorKindInvalid,
+	}
+}
+
+// FilterModels leaves the models the calling key may not use out of a model listing. Entries
+// are identified by their "id", or by their "name" without the "models/" prefix in the
+// Gemini format.
+func (h *BaseAPIHandler) FilterModels(c *gin.Context, models []map[string]any) []map[string]any {
+	policy, ok := h.modelPolicy(c)
+	if !ok {
+		return models
+	}
+	allowed := make([]map[string]any, 0, len(models))
+	for _, model := range models {
+		id, _ := model["id"].(string)
+		if id == "" {
+			name, _ := model["name"].(string)
+			id = strings.TrimPrefix(name, "models/")
+		}
+		if policy.Denial(id) == "" {
+			allowed = append(allowed, model)
+		}
+	}
+	return allowed
+}
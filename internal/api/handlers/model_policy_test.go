@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyDefaultModelNotesThePolicyOnTheRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &BaseAPIHandler{Cfg: &config.Config{ModelPolicy: config.ModelPolicyConfig{
+		APIKeys: map[string]config.ModelPolicy{"key-a": {DefaultModel: " policy-default "}},
+	}}}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Set("apiKey", "key-a")
+
+	body := h.ApplyDefaultModel(c, []byte(`{"messages":[]}`))
+	if got := gjson.GetBytes(body, "model").String(); got != "policy-default" {
+		t.Fatalf("model = %q, want policy-default", got)
+	}
+	if section := logging.ModelPolicySection(c); !strings.Contains(section, "default-model policy-default applied") {
+		t.Fatalf("model policy section = %q, want the default-model note", section)
+	}
+
+	body = h.ApplyDefaultModel(c, []byte(`{"model":"named"}`))
+	if got := gjson.GetBytes(body, "model").String(); got != "named" {
+		t.Fatalf("model = %q, want the model the client named", got)
+	}
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultModerationWindow    = 256
+	defaultModerationBatchSize = 256
+	defaultModerationBudget    = 200 * time.Millisecond
+)
+
+// moderatedFormats lists the client formats whose streams can be moderated.
+var moderatedFormats = []string{constant.OpenAI, constant.OpenaiResponse, constant.Claude, constant.Gemini, constant.GeminiCLI}
+
+// StreamModerator checks the text of one streamed response as it is relayed and ends the
+// stream with a content filter event in the client's format once a rule is broken. All
+// methods accept a nil moderator, which checks nothing.
+type StreamModerator struct {
+	handlerType string
+	modelName   string
+	stream      *moderation.Stream
+
+	// id, model and created repeat the OpenAI stream identity on the final chunk.
+	id      string
+	model   string
+	created int64
+	// openBlock is the index of the Claude content block left open, -1 for none.
+	openBlock int64
+	// responseID and sequence repeat the Responses API stream identity on the final event.
+	responseID string
+	sequence   int64
+}
+
+// NewStreamModerator returns the moderator for a stream served to c, or nil when moderation
+// is disabled, the client API key is not moderated, the format cannot be or no checker is
+// configured.
+func (h *BaseAPIHandler) NewStreamModerator(c *gin.Context, handlerType, modelName string) *StreamModerator {
+	if h.Cfg == nil || c == nil || !h.Cfg.Moderation.Enable {
+		return nil
+	}
+	settings := h.Cfg.Moderation
+	if len(settings.APIKeys) > 0 && !slices.Contains(settings.APIKeys, c.GetString("apiKey")) {
+		return nil
+	}
+	if !slices.Contains(moderatedFormats, handlerType) {
+		return nil
+	}
+	stream := &moderation.Stream{
+		Window:    settings.Window,
+		BatchSize: settings.External.BatchSize,
+		Budget:    time.Duration(settings.LatencyBudget) * time.Millisecond,
+		OnSkip: func(checker string, err error) {
+			log.Debugf("moderation: %s check skipped: %v", checker, err)
+			usage.GetRequestStatistics().RecordModerationSkipped()
+		},
+	}
+	if stream.Window <= 0 {
+		stream.Window = defaultModerationWindow
+	}
+	if stream.BatchSize <= 0 {
+		stream.BatchSize = defaultModerationBatchSize
+	}
+	if stream.Budget <= 0 {
+		stream.Budget = defaultModerationBudget
+	}
+	rules, err := moderation.NewRuleChecker(settings.Keywords, settings.Patterns)
+	if err != nil {
+		log.Warn(err)
+	}
+	if rules != nil {
+		stream.Rules = rules
+	}
+	if url := strings.TrimSpace(settings.External.URL); url != "" {
+		stream.External = moderation.NewHTTPChecker(url, settings.External.Headers)
+	}
+	if stream.Rules == nil && stream.External == nil {
+		return nil
+	}
+	return &StreamModerator{handlerType: handlerType, modelName: modelName, stream: stream, openBlock: -1}
+}
+
+// Check moderates the text a stream chunk carries. When it breaks a rule, the violation is
+// recorded and the event ending the stream is returned; the chunk itself must not be sent.
+func (m *StreamModerator) Check(ctx context.Context, chunk []byte) []byte {
+	if m == nil {
+		return nil
+	}
+	return m.stop(ctx, m.stream.Write(ctx, m.text(chunk)))
+}
+
+// Finish runs the checks still pending once a stream completed and returns the event ending
+// it when they found a violation.
+func (m *StreamModerator) Finish(ctx context.Context) []byte {
+	if m == nil {
+		return nil
+	}
+	return m.stop(ctx, m.stream.Flush(ctx))
+}
+
+func (m *StreamModerator) stop(ctx context.Context, violation *moderation.Violation) []byte {
+	if violation == nil {
+		return nil
+	}
+	recordContentFiltered(ctx, m.modelName, violation)
+	return m.filterEvent()
+}
+
+// text returns the output text of chunk and notes the stream identity the final event
+// repeats.
+func (m *StreamModerator) text(chunk []byte) string {
+	var b strings.Builder
+	switch m.handlerType {
+	case constant.OpenAI:
+		data := gjson.ParseBytes(chunk)
+		if m.id == "" {
+			m.id = data.Get("id").String()
+			m.model = data.Get("model").String()
+			m.created = data.Get("created").Int()
+		}
+		data.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+			b.WriteString(choice.Get("delta.content").String())
+			return true
+		})
+	case constant.Claude, constant.OpenaiResponse:
+		for _, line := range bytes.Split(chunk, []byte("\n")) {
+			if !bytes.HasPrefix(line, []byte("data:")) {
+				continue
+			}
+			data := gjson.ParseBytes(bytes.TrimSpace(line[len("data:"):]))
+			switch data.Get("type").String() {
+			case "content_block_start":
+				m.openBlock = data.Get("index").Int()
+			case "content_block_stop":
+				m.openBlock = -1
+			case "content_block_delta":
+				b.WriteString(data.Get("delta.text").String())
+			case "response.created":
+				m.responseID = data.Get("response.id").String()
+			case "response.output_text.delta":
+				b.WriteString(data.Get("delta").String())
+			}
+			if seq := data.Get("sequence_number").Int(); seq > m.sequence {
+				m.sequence = seq
+			}
+		}
+	case constant.Gemini, constant.GeminiCLI:
+		data := gjson.ParseBytes(bytes.TrimSpace(bytes.TrimPrefix(chunk, []byte("data:"))))
+		if data.Get("response").Exists() {
+			data = data.Get("response")
+		}
+		data.Get("candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+			if !part.Get("thought").Bool() {
+				b.WriteString(part.Get("text").String())
+			}
+			return true
+		})
+	}
+	return b.String()
+}
+
+// filterEvent returns the event ending a stream stopped by moderation in the client's
+// format: a content_filter finish for OpenAI, a refusal for Claude, a SAFETY finish for
+// Gemini and an incomplete response for the Responses API.
+func (m *StreamModerator) filterEvent() []byte {
+	switch m.handlerType {
+	case constant.OpenAI:
+		out := `{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"content_filter"}]}`
+		out, _ = sjson.Set(out, "id", m.id)
+		out, _ = sjson.Set(out, "created", m.created)
+		out, _ = sjson.Set(out, "model", m.model)
+		return []byte(out)
+	case constant.Claude:
+		var b strings.Builder
+		if m.openBlock >= 0 {
+			stop, _ := sjson.Set(`{"type":"content_block_stop"}`, "index", m.openBlock)
+			b.WriteString("event: content_block_stop\ndata: " + stop + "\n\n")
+		}
+		b.WriteString(`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"refusal","stop_sequence":null},"usage":{"output_tokens":0}}` + "\n\n")
+		b.WriteString(`event: message_stop` + "\n" + `data: {"type":"message_stop"}`)
+		return []byte(b.String())
+	case constant.OpenaiResponse:
+		out := `{"type":"response.incomplete","response":{"object":"response","status":"incomplete","incomplete_details":{"reason":"content_filter"}}}`
+		out, _ = sjson.Set(out, "sequence_number", m.sequence+1)
+		out, _ = sjson.Set(out, "response.id", m.responseID)
+		return []byte("event: response.incomplete\ndata: " + out)
+	case constant.Gemini, constant.GeminiCLI:
+		out := `{"candidates":[{"content":{"role":"model","parts":[]},"finishReason":"SAFETY","index":0}]}`
+		if m.handlerType == constant.GeminiCLI {
+			out, _ = sjson.SetRaw(`{}`, "response", out)
+		}
+		return []byte(out)
+	}
+	return nil
+}
+
+// recordContentFiltered notes the violation in the request log and counts the stream as
+// filtered. No usage record is published here: the executor publishes the one of the
+// request, marked with the rule, so the request is not counted twice.
+func recordContentFiltered(ctx context.Context, modelName string, violation *moderation.Violation) {
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	_, model := logging.RequestTarget(ginCtx)
+	if model == "-" {
+		model = modelName
+	}
+	log.Infof("moderation stopped stream for model %s: %s", model, violation)
+	logging.RecordModerationNote(ctx, fmt.Sprintf("stream stopped by %s", violation))
+	logging.RecordModerationRule(ctx, violation.String())
+	usage.GetRequestStatistics().RecordContentFiltered()
+}
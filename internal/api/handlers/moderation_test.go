@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// modelUsagePlugin collects the usage records published for one model.
+type modelUsagePlugin struct {
+	model string
+	mu    sync.Mutex
+	seen  []coreusage.Record
+	done  chan struct{}
+}
+
+func (p *modelUsagePlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if record.Model == p.model+"-sentinel" {
+		close(p.done)
+		return
+	}
+	if record.Model == p.model {
+		p.mu.Lock()
+		p.seen = append(p.seen, record)
+		p.mu.Unlock()
+	}
+}
+
+func TestModeratedStreamIsNotRecordedByTheHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	usage.SetStatisticsEnabled(true)
+	plugin := &modelUsagePlugin{model: "moderation-test-model", done: make(chan struct{})}
+	coreusage.RegisterPlugin(plugin)
+
+	cfg := &config.Config{Moderation: config.ModerationConfig{Enable: true, Keywords: []string{"codename"}}}
+	h := newTestHandler(t, cfg, streamExecutor{chunks: []coreexecutor.StreamChunk{
+		{Payload: []byte(`{"candidates":[{"content":{"parts":[{"text":"all fine, "}]}}]}`)},
+		{Payload: []byte(`{"candidates":[{"content":{"parts":[{"text":"now the codename"}]}}]}`)},
+		{Payload: []byte(`{"candidates":[{"content":{"parts":[{"text":" never sent"}]}}]}`)},
+	}}, "moderation-test-model")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/moderation-test-model:streamGenerateContent", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+	before := usage.GetRequestStatistics().Snapshot().ContentFilteredCount
+
+	data, errs := h.ExecuteStreamWithAuthManager(ctx, "gemini", "moderation-test-model", []byte(`{"contents":[]}`), "")
+	var got []string
+	for chunk := range data {
+		got = append(got, string(chunk))
+	}
+	for errMsg := range errs {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if len(got) != 2 || !strings.Contains(got[1], `"finishReason":"SAFETY"`) {
+		t.Fatalf("chunks = %q, want the first chunk followed by a SAFETY finish", got)
+	}
+	if rule := logging.ModerationRule(ctx); rule != "rules/keyword:codename" {
+		t.Fatalf("moderation rule = %q", rule)
+	}
+	if after := usage.GetRequestStatistics().Snapshot().ContentFilteredCount; after != before+1 {
+		t.Fatalf("content filtered count = %d, want %d", after, before+1)
+	}
+
+	coreusage.PublishRecord(context.Background(), coreusage.Record{Model: plugin.model + "-sentinel"})
+	select {
+	case <-plugin.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("usage records were not delivered")
+	}
+	plugin.mu.Lock()
+	defer plugin.mu.Unlock()
+	if len(plugin.seen) != 0 {
+		t.Fatalf("handler published %d usage records, want none besides the executor's", len(plugin.seen))
+	}
+}
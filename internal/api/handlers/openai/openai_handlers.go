@@ -18,6 +18,7 @@ import (
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -54,19 +55,23 @@ func (h *OpenAIAPIHandler) Models() []map[string]any {
 	return modelRegistry.GetAvailableModels("openai")
 }
 
+// modelCapabilityFields lists the capability metadata kept in the /v1/models listing.
+var modelCapabilityFields = []string{"context_length", "max_output_tokens", "supports_vision", "supports_tools", "supports_streaming"}
+
 // OpenAIModels handles the /v1/models endpoint.
 // It returns a list of available AI models with their capabilities
 // and specifications in OpenAI-compatible format.
 func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
-	// Get all available models
-	allModels := h.Models()
+	// Get the models the calling key may use
+	allModels := h.FilterModels(c, h.Models())
 
-	// Filter to only include the 4 required fields: id, object, created, owned_by
+	// Filter to the required fields id, object, created and owned_by plus capability metadata.
+	// Strict clients reject entries whose object is anything but "model".
 	filteredModels := make([]map[string]any, len(allModels))
 	for i, model := range allModels {
 		filteredModel := map[string]any{
 			"id":     model["id"],
-			"object": model["object"],
+			"object": "model",
 		}
 
 		// Add created field if it exists
@@ -79,6 +84,12 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 			filteredModel["owned_by"] = ownedBy
 		}
 
+		for _, key := range modelCapabilityFields {
+			if value, exists := model[key]; exists {
+				filteredModel[key] = value
+			}
+		}
+
 		filteredModels[i] = filteredModel
 	}
 
@@ -106,6 +117,18 @@ func (h *OpenAIAPIHandler) ChatCompletions(c *gin.Context) {
 		})
 		return
 	}
+	rawJSON = h.ApplyDefaultModel(c, rawJSON)
+
+	if !h.ValidateRequest(c, handlers.EndpointChatCompletions, rawJSON) {
+		return
+	}
+	if h.ServePassthrough(h, c, gjson.GetBytes(rawJSON, "model").String(), rawJSON, gjson.GetBytes(rawJSON, "stream").Bool()) {
+		return
+	}
+	rawJSON, ok := h.CheckStrictOpenAI(c, handlers.EndpointChatCompletions, rawJSON)
+	if !ok {
+		return
+	}
 
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
@@ -136,6 +159,18 @@ func (h *OpenAIAPIHandler) Completions(c *gin.Context) {
 		})
 		return
 	}
+	rawJSON = h.ApplyDefaultModel(c, rawJSON)
+
+	if !h.ValidateRequest(c, handlers.EndpointCompletions, rawJSON) {
+		return
+	}
+	if h.ServePassthrough(h, c, gjson.GetBytes(rawJSON, "model").String(), rawJSON, gjson.GetBytes(rawJSON, "stream").Bool()) {
+		return
+	}
+	rawJSON, ok := h.CheckStrictOpenAI(c, handlers.EndpointCompletions, rawJSON)
+	if !ok {
+		return
+	}
 
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
@@ -204,7 +239,12 @@ func convertCompletionsRequestToChatCompletions(rawJSON []byte) []byte {
 		out, _ = sjson.Set(out, "stream", stream.Bool())
 	}
 
-	if logprobs := root.Get("logprobs"); logprobs.Exists() {
+	// Completions give the number of alternatives in logprobs, which chat completions
+	// split into a switch and top_logprobs.
+	if logprobs := root.Get("logprobs"); logprobs.Type == gjson.Number {
+		out, _ = sjson.Set(out, "logprobs", true)
+		out, _ = sjson.Set(out, "top_logprobs", logprobs.Int())
+	} else if logprobs.Exists() && logprobs.Type != gjson.Null {
 		out, _ = sjson.Set(out, "logprobs", logprobs.Bool())
 	}
 
@@ -275,9 +315,9 @@ func convertChatCompletionsResponseToCompletions(rawJSON []byte) []byte {
 				completionsChoice["finish_reason"] = finishReason.String()
 			}
 
-			// Copy logprobs if present
-			if logprobs := choice.Get("logprobs"); logprobs.Exists() {
-				completionsChoice["logprobs"] = logprobs.Value()
+			// Convert logprobs if present
+			if logprobs, _ := util.CompletionsLogprobs(choice.Get("logprobs"), 0); logprobs != nil {
+				completionsChoice["logprobs"] = logprobs
 			}
 
 			choices = append(choices, completionsChoice)
@@ -298,10 +338,11 @@ func convertChatCompletionsResponseToCompletions(rawJSON []byte) []byte {
 //
 // Parameters:
 //   - chunkData: The raw JSON bytes of a single chat completions stream chunk
+//   - offsets: The text offset reached so far by each choice index, advanced in place
 //
 // Returns:
 //   - []byte: The converted completions stream chunk, or nil if should be filtered out
-func convertChatCompletionsStreamChunkToCompletions(chunkData []byte) []byte {
+func convertChatCompletionsStreamChunkToCompletions(chunkData []byte, offsets map[int]int) []byte {
 	root := gjson.ParseBytes(chunkData)
 
 	// Check if this chunk has any meaningful content
@@ -369,9 +410,11 @@ func convertChatCompletionsStreamChunkToCompletions(chunkData []byte) []byte {
 				completionsChoice["finish_reason"] = finishReason.String()
 			}
 
-			// Copy logprobs if present
-			if logprobs := choice.Get("logprobs"); logprobs.Exists() {
-				completionsChoice["logprobs"] = logprobs.Value()
+			// Convert logprobs if present, continuing the text offsets of earlier chunks
+			index := int(choice.Get("index").Int())
+			if logprobs, next := util.CompletionsLogprobs(choice.Get("logprobs"), offsets[index]); logprobs != nil {
+				completionsChoice["logprobs"] = logprobs
+				offsets[index] = next
 			}
 
 			choices = append(choices, completionsChoice)
@@ -399,13 +442,15 @@ func (h *OpenAIAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	tagger := h.NewResponseTagger(c, h.HandlerType(), modelName, rawJSON)
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
-	_, _ = c.Writer.Write(resp)
+	reportIgnoredParams(c, rawJSON)
+	_, _ = c.Writer.Write(withSystemFingerprint(c, tagger.TagResponse(resp)))
 	cliCancel()
 }
 
@@ -437,7 +482,7 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
-	h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+	h.handleStreamResult(c, flusher, rawJSON, func(err error) { cliCancel(err) }, dataChan, errChan)
 }
 
 // handleCompletionsNonStreamingResponse handles non-streaming completions responses.
@@ -455,14 +500,16 @@ func (h *OpenAIAPIHandler) handleCompletionsNonStreamingResponse(c *gin.Context,
 
 	modelName := gjson.GetBytes(chatCompletionsJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	tagger := h.NewResponseTagger(c, h.HandlerType(), modelName, chatCompletionsJSON)
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, chatCompletionsJSON, "")
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
-	completionsResp := convertChatCompletionsResponseToCompletions(resp)
-	_, _ = c.Writer.Write(completionsResp)
+	completionsResp := convertChatCompletionsResponseToCompletions(tagger.TagResponse(resp))
+	reportIgnoredParams(c, chatCompletionsJSON)
+	_, _ = c.Writer.Write(withSystemFingerprint(c, completionsResp))
 	cliCancel()
 }
 
@@ -498,6 +545,8 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, chatCompletionsJSON, "")
 
+	first := true
+	offsets := make(map[int]int)
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -505,13 +554,28 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			return
 		case chunk, isOk := <-dataChan:
 			if !isOk {
+				if errMsg := handlers.PendingStreamError(errChan); errMsg != nil {
+					h.WriteStreamError(c, h.HandlerType(), errMsg)
+					flusher.Flush()
+					cliCancel(errMsg.Error)
+					return
+				}
 				_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
 				flusher.Flush()
 				cliCancel()
 				return
 			}
-			converted := convertChatCompletionsStreamChunkToCompletions(chunk)
+			if handlers.IsHeartbeat(chunk) {
+				handlers.WriteHeartbeat(c, flusher)
+				continue
+			}
+			converted := convertChatCompletionsStreamChunkToCompletions(chunk, offsets)
 			if converted != nil {
+				if first {
+					reportIgnoredParams(c, chatCompletionsJSON)
+					converted = withSystemFingerprint(c, converted)
+					first = false
+				}
 				_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(converted))
 				flusher.Flush()
 			}
@@ -520,7 +584,7 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, h.HandlerType(), errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -533,7 +597,8 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 		}
 	}
 }
-func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, rawJSON []byte, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+	first := true
 	for {
 		select {
 		case <-c.Request.Context().Done():
@@ -541,11 +606,26 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 			return
 		case chunk, ok := <-data:
 			if !ok {
+				if errMsg := handlers.PendingStreamError(errs); errMsg != nil {
+					h.WriteStreamError(c, h.HandlerType(), errMsg)
+					flusher.Flush()
+					cancel(errMsg.Error)
+					return
+				}
 				_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
 				flusher.Flush()
 				cancel(nil)
 				return
 			}
+			if handlers.IsHeartbeat(chunk) {
+				handlers.WriteHeartbeat(c, flusher)
+				continue
+			}
+			if first {
+				reportIgnoredParams(c, rawJSON)
+				chunk = withSystemFingerprint(c, chunk)
+				first = false
+			}
 			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
 			flusher.Flush()
 		case errMsg, ok := <-errs:
@@ -553,7 +633,7 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, h.HandlerType(), errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -81,13 +81,30 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 		})
 		return
 	}
+	rawJSON = h.ApplyDefaultModel(c, rawJSON)
+
+	if !h.ValidateRequest(c, handlers.EndpointResponses, rawJSON) {
+		return
+	}
+	if h.ServePassthrough(h, c, gjson.GetBytes(rawJSON, "model").String(), rawJSON, gjson.GetBytes(rawJSON, "stream").Bool()) {
+		return
+	}
+	rawJSON, ok := h.CheckStrictOpenAI(c, handlers.EndpointResponses, rawJSON)
+	if !ok {
+		return
+	}
+
+	rawJSON, stored, ok := h.prepareStoredResponse(c, rawJSON)
+	if !ok {
+		return
+	}
 
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	if streamResult.Type == gjson.True {
-		h.handleStreamingResponse(c, rawJSON)
+		h.handleStreamingResponse(c, rawJSON, stored)
 	} else {
-		h.handleNonStreamingResponse(c, rawJSON)
+		h.handleNonStreamingResponse(c, rawJSON, stored)
 	}
 
 }
@@ -99,7 +116,8 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response
 //   - rawJSON: The raw JSON bytes of the OpenAIResponses-compatible request
-func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []byte) {
+//   - stored: How the response is kept in the response store
+func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []byte, stored storedRequest) {
 	c.Header("Content-Type", "application/json")
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
@@ -108,12 +126,15 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, r
 		cliCancel()
 	}()
 
+	tagger := h.NewResponseTagger(c, h.HandlerType(), modelName, rawJSON)
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		return
 	}
+	resp = tagger.TagResponse(resp)
 	_, _ = c.Writer.Write(resp)
+	h.saveResponse(c, stored, resp)
 	return
 
 	// no legacy fallback
@@ -127,7 +148,8 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, r
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response
 //   - rawJSON: The raw JSON bytes of the OpenAIResponses-compatible request
-func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byte) {
+//   - stored: How the response is kept in the response store
+func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byte, stored storedRequest) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -149,22 +171,36 @@ func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJ
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
-	h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+	completed := h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+	h.saveResponse(c, stored, completed)
 	return
 }
 
-func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+// forwardResponsesStream relays stream chunks to the client and returns the response object
+// of the response.completed event, nil when the stream did not complete.
+func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) []byte {
+	var completed []byte
 	for {
 		select {
 		case <-c.Request.Context().Done():
 			cancel(c.Request.Context().Err())
-			return
+			return nil
 		case chunk, ok := <-data:
 			if !ok {
+				if errMsg := handlers.PendingStreamError(errs); errMsg != nil {
+					h.WriteStreamError(c, h.HandlerType(), errMsg)
+					flusher.Flush()
+					cancel(errMsg.Error)
+					return nil
+				}
 				_, _ = c.Writer.Write([]byte("\n"))
 				flusher.Flush()
 				cancel(nil)
-				return
+				return completed
+			}
+			if handlers.IsHeartbeat(chunk) {
+				handlers.WriteHeartbeat(c, flusher)
+				continue
 			}
 
 			if bytes.HasPrefix(chunk, []byte("event:")) {
@@ -174,12 +210,15 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 			_, _ = c.Writer.Write([]byte("\n"))
 
 			flusher.Flush()
+			if response := completedResponse(chunk); response != nil {
+				completed = response
+			}
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
 			}
 			if errMsg != nil {
-				h.WriteErrorResponse(c, errMsg)
+				h.WriteStreamError(c, h.HandlerType(), errMsg)
 				flusher.Flush()
 			}
 			var execErr error
@@ -187,7 +226,7 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 				execErr = errMsg.Error
 			}
 			cancel(execErr)
-			return
+			return nil
 		case <-time.After(500 * time.Millisecond):
 		}
 	}
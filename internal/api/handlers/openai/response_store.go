@@ -0,0 +1,228 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// defaultResponseStoreMaxAge matches how long OpenAI keeps stored responses.
+const defaultResponseStoreMaxAge = 30 * 24 * time.Hour
+
+// storedRequest carries what a Responses API request needs to be stored once it completes.
+type storedRequest struct {
+	// store reports whether the response should be saved.
+	store bool
+	// input is the request input including that of the responses it continues.
+	input []byte
+	// metadata is the client metadata of the request.
+	metadata map[string]string
+}
+
+// prepareStoredResponse records the request metadata for usage records and, when the
+// response store is enabled, replaces previous_response_id with the conversation it names.
+// Models served only by Codex keep previous_response_id, which Codex resolves itself. It
+// writes an error response and returns false when the previous response is unknown.
+func (h *OpenAIResponsesAPIHandler) prepareStoredResponse(c *gin.Context, rawJSON []byte) ([]byte, storedRequest, bool) {
+	var req storedRequest
+	if metadata := gjson.GetBytes(rawJSON, "metadata"); metadata.IsObject() {
+		req.metadata = make(map[string]string)
+		metadata.ForEach(func(key, value gjson.Result) bool {
+			req.metadata[key.String()] = value.String()
+			return true
+		})
+		logging.RecordRequestMetadata(c, req.metadata)
+	}
+	if !h.responseStoreEnabled() {
+		return rawJSON, req, true
+	}
+	req.store = gjson.GetBytes(rawJSON, "store").Type != gjson.False
+
+	input := inputItems(gjson.GetBytes(rawJSON, "input"))
+	previousID := strings.TrimSpace(gjson.GetBytes(rawJSON, "previous_response_id").String())
+	if previousID != "" && !h.continuesNatively(gjson.GetBytes(rawJSON, "model").String()) {
+		previous, err := responsestore.New(h.Cfg.AuthDir).Get(c.GetString("apiKey"), previousID)
+		if err != nil {
+			if !errors.Is(err, responsestore.ErrNotFound) {
+				log.Warnf("response store: failed to load %s: %v", previousID, err)
+			}
+			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: fmt.Sprintf("Previous response with id '%s' not found.", previousID),
+					Type:    "invalid_request_error",
+					Code:    "previous_response_not_found",
+				},
+			})
+			return nil, req, false
+		}
+		input = joinItems(previous.Input, replayableOutput(previous.Response), input)
+		rawJSON, _ = sjson.DeleteBytes(rawJSON, "previous_response_id")
+		rawJSON, _ = sjson.SetRawBytes(rawJSON, "input", input)
+	}
+	req.input = input
+	return rawJSON, req, true
+}
+
+// saveResponse stores a completed response object when req asks for it. Failures are only
+// logged: the client already has its response.
+func (h *OpenAIResponsesAPIHandler) saveResponse(c *gin.Context, req storedRequest, response []byte) {
+	if !req.store || len(response) == 0 {
+		return
+	}
+	id := gjson.GetBytes(response, "id").String()
+	if id == "" {
+		return
+	}
+	record := responsestore.Record{
+		ID:       id,
+		Input:    json.RawMessage(req.input),
+		Response: json.RawMessage(bytes.Clone(response)),
+		Metadata: req.metadata,
+	}
+	if err := responsestore.New(h.Cfg.AuthDir).Save(c.GetString("apiKey"), record, h.responseStoreMaxAge()); err != nil {
+		log.Warnf("response store: failed to save %s: %v", id, err)
+	}
+}
+
+// GetResponse handles GET /v1/responses/:id, returning a stored response.
+func (h *OpenAIResponsesAPIHandler) GetResponse(c *gin.Context) {
+	id := c.Param("id")
+	record, err := h.storedResponse(c, id)
+	if err != nil {
+		writeResponseNotFound(c, id, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", record.Response)
+}
+
+// DeleteResponse handles DELETE /v1/responses/:id, removing a stored response.
+func (h *OpenAIResponsesAPIHandler) DeleteResponse(c *gin.Context) {
+	id := c.Param("id")
+	err := responsestore.ErrNotFound
+	if h.responseStoreEnabled() {
+		err = responsestore.New(h.Cfg.AuthDir).Delete(c.GetString("apiKey"), id)
+	}
+	if err != nil {
+		writeResponseNotFound(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "response.deleted", "deleted": true})
+}
+
+func (h *OpenAIResponsesAPIHandler) storedResponse(c *gin.Context, id string) (responsestore.Record, error) {
+	if !h.responseStoreEnabled() {
+		return responsestore.Record{}, responsestore.ErrNotFound
+	}
+	return responsestore.New(h.Cfg.AuthDir).Get(c.GetString("apiKey"), id)
+}
+
+// continuesNatively reports whether every provider serving modelName continues a response
+// from previous_response_id on its own, so the proxy must not expand it.
+func (h *OpenAIResponsesAPIHandler) continuesNatively(modelName string) bool {
+	providers := util.GetProviderName(modelName, h.Cfg)
+	if len(providers) == 0 {
+		return false
+	}
+	for _, provider := range providers {
+		if provider != "codex" {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *OpenAIResponsesAPIHandler) responseStoreEnabled() bool {
+	return h.Cfg != nil && h.Cfg.ResponseStore.Enable
+}
+
+func (h *OpenAIResponsesAPIHandler) responseStoreMaxAge() time.Duration {
+	if days := h.Cfg.ResponseStore.MaxAge; days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return defaultResponseStoreMaxAge
+}
+
+func writeResponseNotFound(c *gin.Context, id string, err error) {
+	if !errors.Is(err, responsestore.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{Message: err.Error(), Type: "server_error"},
+		})
+		return
+	}
+	c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: fmt.Sprintf("Response with id '%s' not found.", id),
+			Type:    "invalid_request_error",
+		},
+	})
+}
+
+// completedResponse returns the response object of a response.completed event found in a
+// stream chunk, or nil.
+func completedResponse(chunk []byte) []byte {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if gjson.GetBytes(payload, "type").String() == "response.completed" {
+			if response := gjson.GetBytes(payload, "response"); response.IsObject() {
+				return []byte(response.Raw)
+			}
+		}
+	}
+	return nil
+}
+
+// inputItems returns the request input as a JSON array of items; a plain string becomes one
+// user message.
+func inputItems(input gjson.Result) []byte {
+	if input.IsArray() {
+		return []byte(input.Raw)
+	}
+	if input.Type == gjson.String {
+		item, _ := sjson.Set(`{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`, "content.0.text", input.String())
+		return []byte("[" + item + "]")
+	}
+	return []byte("[]")
+}
+
+// replayableOutput returns the output items of a stored response that can be sent back as
+// input. Reasoning items are dropped since backends other than the one that produced them
+// cannot read them.
+func replayableOutput(response []byte) []byte {
+	items := make([]string, 0)
+	gjson.GetBytes(response, "output").ForEach(func(_, item gjson.Result) bool {
+		if item.Get("type").String() != "reasoning" {
+			items = append(items, item.Raw)
+		}
+		return true
+	})
+	return []byte("[" + strings.Join(items, ",") + "]")
+}
+
+// joinItems concatenates JSON arrays of items.
+func joinItems(lists ...[]byte) []byte {
+	items := make([]string, 0)
+	for _, list := range lists {
+		gjson.ParseBytes(list).ForEach(func(_, item gjson.Result) bool {
+			items = append(items, item.Raw)
+			return true
+		})
+	}
+	return []byte("[" + strings.Join(items, ",") + "]")
+}
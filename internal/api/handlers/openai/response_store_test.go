@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+func TestPreviousResponseIDIsExpandedUnlessCodexServesTheModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry.GetGlobalRegistry().RegisterClient("store-test-codex", "codex", []*registry.ModelInfo{{ID: "store-test-codex-model"}})
+	registry.GetGlobalRegistry().RegisterClient("store-test-gemini", "gemini", []*registry.ModelInfo{{ID: "store-test-gemini-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient("store-test-codex")
+		registry.GetGlobalRegistry().UnregisterClient("store-test-gemini")
+	})
+
+	cfg := &config.Config{AuthDir: t.TempDir()}
+	cfg.ResponseStore.Enable = true
+	store := responsestore.New(cfg.AuthDir)
+	t.Cleanup(func() { _ = store.Close() })
+	previous := responsestore.Record{
+		ID:       "resp_prev",
+		Input:    json.RawMessage(`[{"type":"message","role":"user","content":[{"type":"input_text","text":"first"}]}]`),
+		Response: json.RawMessage(`{"id":"resp_prev","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"answer"}]}]}`),
+	}
+	if err := store.Save("", previous, 0); err != nil {
+		t.Fatal(err)
+	}
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(cfg, coreauth.NewManager(nil, nil, nil)))
+
+	for _, tt := range []struct {
+		model      string
+		wantKept   bool
+		wantInputs int
+	}{
+		{model: "store-test-codex-model", wantKept: true, wantInputs: 1},
+		{model: "store-test-gemini-model", wantKept: false, wantInputs: 3},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+		body := []byte(`{"model":"` + tt.model + `","previous_response_id":"resp_prev","input":"second"}`)
+
+		out, _, ok := h.prepareStoredResponse(c, body)
+		if !ok {
+			t.Fatalf("%s: request rejected", tt.model)
+		}
+		if kept := gjson.GetBytes(out, "previous_response_id").Exists(); kept != tt.wantKept {
+			t.Fatalf("%s: previous_response_id kept = %v, want %v", tt.model, kept, tt.wantKept)
+		}
+		if n := len(gjson.GetBytes(out, "input").Array()); n != tt.wantInputs {
+			t.Fatalf("%s: input items = %d, want %d", tt.model, n, tt.wantInputs)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package openai
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// seedlessProviders are the backends whose upstream requests cannot carry a seed. OpenAI
+// compatible providers and Codex receive it verbatim and Gemini maps it to
+// generationConfig.seed.
+var seedlessProviders = map[string]struct{}{
+	"claude":     {},
+	"gemini-web": {},
+}
+
+// withSystemFingerprint sets system_fingerprint on an OpenAI response object or chunk to
+// the fingerprint of the backend that served it.
+func withSystemFingerprint(c *gin.Context, payload []byte) []byte {
+	fingerprint := logging.SystemFingerprint(c)
+	if fingerprint == "" || !gjson.ValidBytes(payload) {
+		return payload
+	}
+	if updated, err := sjson.SetBytes(payload, "system_fingerprint", fingerprint); err == nil {
+		return updated
+	}
+	return payload
+}
+
+// reportIgnoredParams announces in a response header which of the request's seed, logprobs
+// and prediction the serving backend dropped, instead of failing the request. It must run
+// before the body is written.
+func reportIgnoredParams(c *gin.Context, rawJSON []byte) {
+	provider, _ := logging.RequestTarget(c)
+	var ignored []string
+	if gjson.GetBytes(rawJSON, "seed").Exists() {
+		if _, ok := seedlessProviders[provider]; ok {
+			ignored = append(ignored, "seed")
+		}
+	}
+	if gjson.GetBytes(rawJSON, "logprobs").Bool() || gjson.GetBytes(rawJSON, "top_logprobs").Exists() {
+		if features, ok := registry.GetGlobalRegistry().GetProviderFeatures(provider); ok && !features.Logprobs {
+			ignored = append(ignored, "logprobs")
+		}
+	}
+	if gjson.GetBytes(rawJSON, "prediction").Exists() {
+		if features, ok := registry.GetGlobalRegistry().GetProviderFeatures(provider); ok && !features.Prediction {
+			ignored = append(ignored, "prediction")
+		}
+	}
+	if len(ignored) > 0 {
+		c.Writer.Header().Add(handlers.IgnoredParamsHeader, strings.Join(ignored, ", "))
+	}
+}
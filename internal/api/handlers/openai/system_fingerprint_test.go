@@ -0,0 +1,40 @@
+package openai
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+)
+
+func TestReportIgnoredParamsNamesSeedOnlyForSeedlessProviders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for provider, want := range map[string]string{
+		"claude":     "seed",
+		"gemini-web": "seed",
+		"codex":      "",
+		"gemini":     "",
+	} {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		logging.RecordRequestTarget(context.WithValue(context.Background(), "gin", c), provider, "model")
+		reportIgnoredParams(c, []byte(`{"seed":7}`))
+		if got := rec.Header().Get(handlers.IgnoredParamsHeader); got != want {
+			t.Errorf("%s: ignored params = %q, want %q", provider, got, want)
+		}
+	}
+}
+
+func TestWithSystemFingerprintSetsTheServingBackend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	logging.RecordSystemFingerprint(context.WithValue(context.Background(), "gin", c), "fp_0123456789ab")
+	payload := withSystemFingerprint(c, []byte(`{"id":"chatcmpl-1","system_fingerprint":null}`))
+	if got := gjson.GetBytes(payload, "system_fingerprint").String(); got != "fp_0123456789ab" {
+		t.Fatalf("system_fingerprint = %q in %s", got, payload)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// PassthroughHeader asks for a raw passthrough when passthrough.allow-header is set. Responses
+// served that way carry it too, so clients can tell them from translated ones.
+const PassthroughHeader = "X-Passthrough"
+
+// passthroughRequested reports whether the request for modelName is to be passed through.
+func (h *BaseAPIHandler) passthroughRequested(c *gin.Context, modelName string) bool {
+	if h.Cfg == nil || c == nil {
+		return false
+	}
+	settings := h.Cfg.Passthrough
+	if slices.Contains(settings.Models, modelName) {
+		return true
+	}
+	if !settings.AllowHeader {
+		return false
+	}
+	requested, _ := strconv.ParseBool(c.GetHeader(PassthroughHeader))
+	return requested
+}
+
+// ServePassthrough answers a passthrough request for modelName and reports whether the
+// request was one. rawJSON goes to the provider the auth manager selects without translation
+// and the upstream bytes are written back unchanged, so the body must be in that provider's
+// native format. Routing, credential selection, quotas, usage accounting, the safety settings
+// check and the tool guard still apply, and callers validate the request before; the response
+// rewriting of translated requests does not.
+func (h *BaseAPIHandler) ServePassthrough(handler interfaces.APIHandler, c *gin.Context, modelName string, rawJSON []byte, stream bool) bool {
+	if !h.passthroughRequested(c, modelName) {
+		return false
+	}
+	ctx, cancel := h.GetContextWithCancel(handler, c, context.Background())
+	model, providers, errMsg := h.resolveProviders(ctx, modelName)
+	if errMsg == nil {
+		errMsg = checkSafetySettings(handler.HandlerType(), rawJSON)
+	}
+	if errMsg == nil {
+		providers, errMsg = h.toolCapableProviders(modelName, rawJSON, providers)
+	}
+	if errMsg == nil {
+		ctx, providers, errMsg = h.withClientCredential(ctx, providers)
+	}
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cancel(errMsg.Error)
+		return true
+	}
+	req := coreexecutor.Request{Model: model, Payload: cloneBytes(rawJSON)}
+	opts := coreexecutor.Options{
+		Stream:          stream,
+		OriginalRequest: cloneBytes(rawJSON),
+		SourceFormat:    sdktranslator.FormatRaw,
+		PreferBody:      !stream,
+	}
+	c.Header(PassthroughHeader, "true")
+	if stream {
+		h.streamPassthrough(ctx, c, modelName, providers, req, opts, cancel)
+		return true
+	}
+
+	ctx = withAttemptLog(ctx)
+	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	reportAttempts(ctx)
+	if err != nil {
+		errMsg = errorMessageFromExecution(err)
+		if ClientDisconnected(ctx) {
+			recordClientDisconnect(ctx, modelName)
+			errMsg = &interfaces.ErrorMessage{StatusCode: StatusClientClosedRequest, Error: err}
+		} else {
+			h.recordDeadLetter(ctx, modelName, providers, false, errMsg)
+		}
+		h.WriteErrorResponse(c, errMsg)
+		cancel(errMsg.Error)
+		return true
+	}
+	c.Header("Content-Type", "application/json")
+	if resp.Body == nil {
+		_, _ = c.Writer.Write(resp.Payload)
+		cancel(resp.Payload)
+		return true
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if _, err = io.Copy(c.Writer, resp.Body); err != nil {
+		log.Warnf("failed to relay passthrough body for model %s: %v", modelName, err)
+	}
+	cancel()
+	return true
+}
+
+// streamPassthrough writes the upstream stream of a passthrough request line by line. An
+// error before the first line is a regular error response; later ones end the stream, as the
+// provider's own protocol has no place for a proxy error.
+func (h *BaseAPIHandler) streamPassthrough(ctx context.Context, c *gin.Context, modelName string, providers []string, req coreexecutor.Request, opts coreexecutor.Options, cancel APIHandlerCancelFunc) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		h.WriteErrorResponse(c, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: errors.New("streaming not supported")})
+		cancel()
+		return
+	}
+	ctx = withAttemptLog(ctx)
+	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	reportAttempts(ctx)
+	if err != nil {
+		errMsg := errorMessageFromExecution(err)
+		h.recordDeadLetter(ctx, modelName, providers, true, errMsg)
+		h.WriteErrorResponse(c, errMsg)
+		cancel(errMsg.Error)
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			errMsg := errorMessageFromExecution(chunk.Err)
+			if ClientDisconnected(ctx) {
+				recordClientDisconnect(ctx, modelName)
+			} else if !c.Writer.Written() {
+				h.recordDeadLetter(ctx, modelName, providers, true, errMsg)
+				h.WriteErrorResponse(c, errMsg)
+			} else {
+				h.recordDeadLetter(ctx, modelName, providers, true, errMsg)
+				logging.RecordStreamError(c, errMsg.StatusCode, chunk.Err.Error())
+				log.Warnf("passthrough stream for model %s ended early: %v", modelName, chunk.Err)
+			}
+			cancel(chunk.Err)
+			go func() {
+				for range chunks {
+				}
+			}()
+			return
+		}
+		if chunk.Heartbeat {
+			WriteHeartbeat(c, flusher)
+			continue
+		}
+		_, _ = c.Writer.Write(chunk.Payload)
+		_, _ = c.Writer.Write([]byte("\n"))
+		flusher.Flush()
+	}
+	cancel()
+}
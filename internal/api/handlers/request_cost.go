@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// requestCostHeader carries the estimated cost of a non-streaming response in dollars.
+const requestCostHeader = "X-Request-Cost"
+
+// reportsCost reports whether responses carry the request cost header.
+func (h *BaseAPIHandler) reportsCost() bool {
+	return h.Cfg != nil && h.Cfg.Pricing.Header
+}
+
+// reportRequestCost sets the request cost header from the cost the executor recorded for the
+// request of ctx, if it matched a price. It must run before the response is written.
+func (h *BaseAPIHandler) reportRequestCost(ctx context.Context) {
+	if !h.reportsCost() {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	if cost, ok := logging.RequestCost(ginCtx); ok {
+		ginCtx.Header(requestCostHeader, strconv.FormatFloat(cost, 'f', 6, 64))
+	}
+}
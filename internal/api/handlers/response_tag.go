@@ -0,0 +1,548 @@
+package handlers
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultResponseTagTemplate = "[ref:{request_id}]"
+
+// Zero-width characters encoding a tag: a word joiner marks its start, then every byte is
+// written as eight bits, most significant first.
+const (
+	zeroWidthStart = "\u2060"
+	zeroWidthZero  = "\u200b"
+	zeroWidthOne   = "\u200c"
+)
+
+// responseTagFormats lists the client formats whose responses can carry the tag.
+var responseTagFormats = []string{constant.OpenAI, constant.OpenaiResponse, constant.Claude, constant.Gemini, constant.GeminiCLI}
+
+// ResponseTagger adds the response-footer text and the audit tag of response-tag to the
+// assistant text of one response. Responses without any text, such as those holding only
+// tool calls, are left unchanged. All methods accept a nil tagger, which changes nothing.
+type ResponseTagger struct {
+	handlerType string
+	// prefix goes in front of the first text, tag after the last one.
+	prefix string
+	tag    string
+
+	prefixed bool
+	textSeen bool
+	done     bool
+
+	// nextIndex is the Claude content block index the tag block takes.
+	nextIndex int64
+	// id, model and created repeat the OpenAI stream identity on the tag chunk.
+	id      string
+	model   string
+	created int64
+	// modelVersion repeats the Gemini model version on the tag chunk.
+	modelVersion string
+
+	// item, outputIndex and contentIndex name the Responses API text part the prefix and tag
+	// go to, the first one streamed. tagged is set once its tag delta was sent and
+	// announced once the event line of that delta was written.
+	item         string
+	outputIndex  int64
+	contentIndex int64
+	tagged       bool
+	announced    bool
+}
+
+// NewResponseTagger returns the tagger for the response to rawJSON, or nil when neither a
+// footer nor a tag applies, the format is not supported or the request asks for JSON output,
+// which added text would corrupt. Tagged requests are noted for usage records.
+func (h *BaseAPIHandler) NewResponseTagger(c *gin.Context, handlerType, modelName string, rawJSON []byte) *ResponseTagger {
+	if h.Cfg == nil || c == nil || !slices.Contains(responseTagFormats, handlerType) {
+		return nil
+	}
+	if requestsJSONOutput(handlerType, rawJSON) {
+		return nil
+	}
+	t := &ResponseTagger{handlerType: handlerType}
+	if footer := h.responseFooter(c, modelName); footer != "" {
+		if strings.EqualFold(strings.TrimSpace(h.Cfg.ResponseFooter.Position), config.FooterPrepend) {
+			t.prefix = footer + "\n\n"
+		} else {
+			t.tag = "\n\n" + footer
+		}
+	}
+	if tag, ref := h.auditTag(c, handlerType); tag != "" {
+		t.tag += tag
+		logging.RecordResponseTagged(c, ref)
+	}
+	if t.prefix == "" && t.tag == "" {
+		return nil
+	}
+	return t
+}
+
+// responseFooter returns the response-footer text for the request served to c, or "" when
+// none is set or the client API key or model is skipped.
+func (h *BaseAPIHandler) responseFooter(c *gin.Context, modelName string) string {
+	settings := h.Cfg.ResponseFooter
+	if strings.TrimSpace(settings.Text) == "" {
+		return ""
+	}
+	if slices.Contains(settings.SkipAPIKeys, c.GetString("apiKey")) || slices.Contains(settings.SkipModels, modelName) {
+		return ""
+	}
+	return settings.Text
+}
+
+// auditTag returns the audit tag appended for the request served to c with the reference it
+// carries, or "" when tagging is disabled or the client API key or format is not tagged. The
+// reference is generated by the proxy rather than taken from X-Request-ID, which clients
+// choose, so a tag can only point at the usage record of its own request.
+func (h *BaseAPIHandler) auditTag(c *gin.Context, handlerType string) (tag, ref string) {
+	settings := h.Cfg.ResponseTag
+	if !settings.Enable {
+		return "", ""
+	}
+	label, ok := settings.APIKeys[c.GetString("apiKey")]
+	if !ok {
+		return "", ""
+	}
+	if len(settings.Formats) > 0 && !slices.Contains(settings.Formats, handlerType) {
+		return "", ""
+	}
+	template := settings.Template
+	if strings.TrimSpace(template) == "" {
+		template = defaultResponseTagTemplate
+	}
+	ref = newTagRef()
+	tag = strings.NewReplacer(
+		"{request_id}", ref,
+		"{timestamp}", time.Now().UTC().Format(time.RFC3339),
+		"{key}", label,
+	).Replace(template)
+	if settings.ZeroWidth {
+		return encodeZeroWidth(tag), ref
+	}
+	return "\n\n" + tag, ref
+}
+
+// newTagRef returns a random reference for an audit tag.
+func newTagRef() string {
+	buf := make([]byte, 8)
+	_, _ = crand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// requestsJSONOutput reports whether rawJSON asks for a JSON mode response.
+func requestsJSONOutput(handlerType string, rawJSON []byte) bool {
+	switch handlerType {
+	case constant.OpenAI:
+		format := gjson.GetBytes(rawJSON, "response_format.type").String()
+		return format == "json_object" || format == "json_schema"
+	case constant.OpenaiResponse:
+		format := gjson.GetBytes(rawJSON, "text.format.type").String()
+		return format == "json_object" || format == "json_schema"
+	case constant.Gemini, constant.GeminiCLI:
+		config := gjson.GetBytes(rawJSON, "generationConfig")
+		if !config.Exists() {
+			config = gjson.GetBytes(rawJSON, "request.generationConfig")
+		}
+		return config.Get("responseMimeType").String() == "application/json" ||
+			config.Get("responseSchema").Exists() || config.Get("responseJsonSchema").Exists()
+	}
+	return false
+}
+
+func encodeZeroWidth(s string) string {
+	var b strings.Builder
+	b.WriteString(zeroWidthStart)
+	for i := 0; i < len(s); i++ {
+		for bit := 7; bit >= 0; bit-- {
+			if s[i]&(1<<bit) != 0 {
+				b.WriteString(zeroWidthOne)
+			} else {
+				b.WriteString(zeroWidthZero)
+			}
+		}
+	}
+	return b.String()
+}
+
+// TagResponse adds the prefix to the first and the tag to the last text of a non-streaming
+// response.
+func (t *ResponseTagger) TagResponse(resp []byte) []byte {
+	if t == nil {
+		return resp
+	}
+	first, last := t.textPaths(resp)
+	if last == "" {
+		return resp
+	}
+	out := resp
+	if t.tag != "" {
+		if tagged, err := sjson.SetBytes(out, last, gjson.GetBytes(out, last).String()+t.tag); err == nil {
+			out = tagged
+		}
+	}
+	if t.prefix != "" {
+		if prefixed, err := sjson.SetBytes(out, first, t.prefix+gjson.GetBytes(out, first).String()); err == nil {
+			out = prefixed
+		}
+	}
+	return out
+}
+
+// textPaths returns the paths of the first and the last non-empty answer text of resp, which
+// is a non-streaming response or, for Gemini, a stream chunk.
+func (t *ResponseTagger) textPaths(resp []byte) (first, last string) {
+	note := func(path string) {
+		if first == "" {
+			first = path
+		}
+		last = path
+	}
+	switch t.handlerType {
+	case constant.OpenAI:
+		if content := gjson.GetBytes(resp, "choices.0.message.content"); content.Type == gjson.String && content.String() != "" {
+			note("choices.0.message.content")
+		}
+	case constant.OpenaiResponse:
+		gjson.GetBytes(resp, "output").ForEach(func(i, item gjson.Result) bool {
+			if item.Get("type").String() != "message" {
+				return true
+			}
+			item.Get("content").ForEach(func(j, part gjson.Result) bool {
+				if part.Get("type").String() == "output_text" && part.Get("text").String() != "" {
+					note("output." + i.String() + ".content." + j.String() + ".text")
+				}
+				return true
+			})
+			return true
+		})
+	case constant.Claude:
+		gjson.GetBytes(resp, "content").ForEach(func(key, block gjson.Result) bool {
+			if block.Get("type").String() == "text" && block.Get("text").String() != "" {
+				note("content." + key.String() + ".text")
+			}
+			return true
+		})
+	case constant.Gemini, constant.GeminiCLI:
+		root := ""
+		if gjson.GetBytes(resp, "response.candidates").Exists() {
+			root = "response."
+		}
+		gjson.GetBytes(resp, root+"candidates.0.content.parts").ForEach(func(key, part gjson.Result) bool {
+			if part.Get("text").String() != "" && !part.Get("thought").Bool() {
+				note(root + "candidates.0.content.parts." + key.String() + ".text")
+			}
+			return true
+		})
+	}
+	return first, last
+}
+
+// Stream notes what a stream chunk carries and returns the chunks to send the client in its
+// place, with the prefix put in front of the first text delta. The tag goes in front of the
+// chunk ending the message, since no content may follow it: OpenAI gets a chunk of its own
+// before the one with finish_reason, Claude a text block inserted in the chunk.
+func (t *ResponseTagger) Stream(chunk []byte) [][]byte {
+	if t == nil || t.done {
+		return [][]byte{chunk}
+	}
+	switch t.handlerType {
+	case constant.OpenAI:
+		return t.streamOpenAI(chunk)
+	case constant.OpenaiResponse:
+		return [][]byte{t.streamResponses(chunk)}
+	case constant.Claude:
+		return [][]byte{t.streamClaude(chunk)}
+	case constant.Gemini, constant.GeminiCLI:
+		return [][]byte{t.streamGemini(chunk)}
+	}
+	return [][]byte{chunk}
+}
+
+func (t *ResponseTagger) streamOpenAI(chunk []byte) [][]byte {
+	data := gjson.ParseBytes(chunk)
+	if t.id == "" {
+		t.id = data.Get("id").String()
+		t.model = data.Get("model").String()
+		t.created = data.Get("created").Int()
+	}
+	content := data.Get("choices.0.delta.content").String()
+	if content != "" {
+		t.textSeen = true
+		if t.prefix != "" && !t.prefixed {
+			t.prefixed = true
+			content = t.prefix + content
+			if prefixed, err := sjson.SetBytes(chunk, "choices.0.delta.content", content); err == nil {
+				chunk = prefixed
+			}
+		}
+	}
+	if data.Get("choices.0.finish_reason").String() == "" {
+		return [][]byte{chunk}
+	}
+	// A chunk ending the text it carries gets the tag appended to that text.
+	if content != "" && t.tag != "" {
+		t.done = true
+		if tagged, err := sjson.SetBytes(chunk, "choices.0.delta.content", content+t.tag); err == nil {
+			return [][]byte{tagged}
+		}
+	}
+	if final := t.Finish(); final != nil {
+		return [][]byte{final, chunk}
+	}
+	return [][]byte{chunk}
+}
+
+func (t *ResponseTagger) streamGemini(chunk []byte) []byte {
+	body := bytes.TrimSpace(bytes.TrimPrefix(chunk, []byte("data:")))
+	data := gjson.ParseBytes(body)
+	if data.Get("response").Exists() {
+		data = data.Get("response")
+	}
+	if version := data.Get("modelVersion").String(); version != "" {
+		t.modelVersion = version
+	}
+	first, _ := t.textPaths(body)
+	if first == "" {
+		return chunk
+	}
+	t.textSeen = true
+	if t.prefix != "" && !t.prefixed {
+		t.prefixed = true
+		if prefixed, err := sjson.SetBytes(body, first, t.prefix+gjson.GetBytes(body, first).String()); err == nil {
+			if bytes.HasPrefix(chunk, []byte("data:")) {
+				prefixed = append([]byte("data: "), prefixed...)
+			}
+			return prefixed
+		}
+	}
+	return chunk
+}
+
+func (t *ResponseTagger) streamClaude(chunk []byte) []byte {
+	lines := bytes.Split(chunk, []byte("\n"))
+	changed := false
+	for i, line := range lines {
+		var eventType string
+		switch {
+		case bytes.HasPrefix(line, []byte("event:")):
+			eventType = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			data := gjson.ParseBytes(bytes.TrimSpace(line[len("data:"):]))
+			eventType = data.Get("type").String()
+			if strings.HasPrefix(eventType, "content_block_") {
+				if index := data.Get("index").Int(); index >= t.nextIndex {
+					t.nextIndex = index + 1
+				}
+			}
+			if text := data.Get("delta.text").String(); eventType == "content_block_delta" && text != "" {
+				t.textSeen = true
+				if t.prefix != "" && !t.prefixed {
+					t.prefixed = true
+					if prefixed, err := sjson.Set(data.Raw, "delta.text", t.prefix+text); err == nil {
+						lines[i] = []byte("data: " + prefixed)
+						changed = true
+					}
+				}
+			}
+		}
+		if eventType != "message_delta" && eventType != "message_stop" {
+			continue
+		}
+		t.done = true
+		if !t.textSeen || t.tag == "" {
+			break
+		}
+		out := make([]byte, 0, len(chunk)+512)
+		out = append(out, bytes.Join(lines[:i], []byte("\n"))...)
+		if i > 0 {
+			// Keep the preceding event separated by a blank line.
+			out = append(out, '\n')
+			if len(lines[i-1]) > 0 {
+				out = append(out, '\n')
+			}
+		}
+		out = append(out, t.claudeTagEvents()...)
+		out = append(out, bytes.Join(lines[i:], []byte("\n"))...)
+		return out
+	}
+	if changed {
+		return bytes.Join(lines, []byte("\n"))
+	}
+	return chunk
+}
+
+// streamResponses tags a Responses API stream, whose events may arrive with their event and
+// data lines in one chunk or in separate ones. The prefix goes to the first text delta and
+// the tag is sent as one more delta of the same text part before its output_text.done
+// event; the events repeating the whole text get both as well.
+func (t *ResponseTagger) streamResponses(chunk []byte) []byte {
+	lines := bytes.Split(chunk, []byte("\n"))
+	out := make([][]byte, 0, len(lines)+3)
+	changed := false
+	for _, line := range lines {
+		if event, ok := bytes.CutPrefix(line, []byte("event:")); ok {
+			if string(bytes.TrimSpace(event)) == "response.output_text.done" && t.tagDue() {
+				out = append(out, t.responsesTagEvent(true)...)
+				t.announced = true
+				changed = true
+			}
+			out = append(out, line)
+			continue
+		}
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+		data := gjson.ParseBytes(bytes.TrimSpace(payload))
+		patched := data.Raw
+		switch data.Get("type").String() {
+		case "response.output_text.delta":
+			delta := data.Get("delta").String()
+			if delta == "" {
+				break
+			}
+			if !t.textSeen {
+				t.textSeen = true
+				t.item = data.Get("item_id").String()
+				t.outputIndex = data.Get("output_index").Int()
+				t.contentIndex = data.Get("content_index").Int()
+			}
+			if t.prefix != "" && !t.prefixed && t.isTaggedPart(data.Get("item_id").String(), data.Get("content_index").Int()) {
+				t.prefixed = true
+				patched, _ = sjson.Set(patched, "delta", t.prefix+delta)
+			}
+		case "response.output_text.done":
+			if !t.isTaggedPart(data.Get("item_id").String(), data.Get("content_index").Int()) {
+				break
+			}
+			if t.tagDue() {
+				// A stream without event lines gets the tag delta as data alone.
+				out = append(out, t.responsesTagEvent(false)...)
+			}
+			t.tagged = t.tag != ""
+			patched, _ = sjson.Set(patched, "text", t.taggedText(data.Get("text").String()))
+		case "response.content_part.done":
+			if t.isTaggedPart(data.Get("item_id").String(), data.Get("content_index").Int()) {
+				patched, _ = sjson.Set(patched, "part.text", t.taggedText(data.Get("part.text").String()))
+			}
+		case "response.output_item.done":
+			if t.textSeen && data.Get("item.id").String() == t.item {
+				path := "item.content." + strconv.FormatInt(t.contentIndex, 10) + ".text"
+				patched, _ = sjson.Set(patched, path, t.taggedText(gjson.Get(patched, path).String()))
+			}
+		case "response.completed":
+			t.done = true
+			if !t.textSeen {
+				break
+			}
+			data.Get("response.output").ForEach(func(i, item gjson.Result) bool {
+				if item.Get("id").String() != t.item {
+					return true
+				}
+				path := "response.output." + i.String() + ".content." + strconv.FormatInt(t.contentIndex, 10) + ".text"
+				patched, _ = sjson.Set(patched, path, t.taggedText(gjson.Get(patched, path).String()))
+				return false
+			})
+		}
+		if patched != data.Raw {
+			line = []byte("data: " + patched)
+			changed = true
+		}
+		out = append(out, line)
+	}
+	if !changed {
+		return chunk
+	}
+	return bytes.Join(out, []byte("\n"))
+}
+
+// tagDue reports whether the tag delta of a Responses API stream is still to be sent.
+func (t *ResponseTagger) tagDue() bool {
+	return t.tag != "" && t.textSeen && !t.tagged && !t.announced
+}
+
+// isTaggedPart reports whether the text part of item at contentIndex is the one tagged.
+func (t *ResponseTagger) isTaggedPart(item string, contentIndex int64) bool {
+	return t.textSeen && item == t.item && contentIndex == t.contentIndex
+}
+
+// taggedText returns the whole text of the tagged Responses API part as the client received
+// it.
+func (t *ResponseTagger) taggedText(text string) string {
+	if t.prefixed {
+		text = t.prefix + text
+	}
+	if t.tagged || t.announced {
+		text += t.tag
+	}
+	return text
+}
+
+// responsesTagEvent returns the lines of the output_text.delta event carrying the tag, with
+// its event line when the stream has them.
+func (t *ResponseTagger) responsesTagEvent(withEvent bool) [][]byte {
+	delta := `{"type":"response.output_text.delta"}`
+	delta, _ = sjson.Set(delta, "item_id", t.item)
+	delta, _ = sjson.Set(delta, "output_index", t.outputIndex)
+	delta, _ = sjson.Set(delta, "content_index", t.contentIndex)
+	delta, _ = sjson.Set(delta, "delta", t.tag)
+	if withEvent {
+		return [][]byte{[]byte("event: response.output_text.delta"), []byte("data: " + delta), nil}
+	}
+	return [][]byte{[]byte("data: " + delta), nil}
+}
+
+func (t *ResponseTagger) claudeTagEvents() []byte {
+	start, _ := sjson.Set(`{"type":"content_block_start","content_block":{"type":"text","text":""}}`, "index", t.nextIndex)
+	delta, _ := sjson.Set(`{"type":"content_block_delta","delta":{"type":"text_delta"}}`, "index", t.nextIndex)
+	delta, _ = sjson.Set(delta, "delta.text", t.tag)
+	stop, _ := sjson.Set(`{"type":"content_block_stop"}`, "index", t.nextIndex)
+	return []byte("event: content_block_start\ndata: " + start + "\n\n" +
+		"event: content_block_delta\ndata: " + delta + "\n\n" +
+		"event: content_block_stop\ndata: " + stop + "\n\n")
+}
+
+// Finish returns the chunk carrying the tag as a final text delta once a stream completed,
+// or nil when the stream had no text or there is no tag left to send.
+func (t *ResponseTagger) Finish() []byte {
+	if t == nil || t.done || !t.textSeen || t.tag == "" {
+		return nil
+	}
+	t.done = true
+	switch t.handlerType {
+	case constant.OpenAI:
+		out := `{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":null}]}`
+		out, _ = sjson.Set(out, "id", t.id)
+		out, _ = sjson.Set(out, "created", t.created)
+		out, _ = sjson.Set(out, "model", t.model)
+		out, _ = sjson.Set(out, "choices.0.delta.content", t.tag)
+		return []byte(out)
+	case constant.Claude:
+		return bytes.TrimRight(t.claudeTagEvents(), "\n")
+	case constant.Gemini, constant.GeminiCLI:
+		out := `{"candidates":[{"content":{"role":"model","parts":[{}]},"index":0}]}`
+		out, _ = sjson.Set(out, "candidates.0.content.parts.0.text", t.tag)
+		if t.modelVersion != "" {
+			out, _ = sjson.Set(out, "modelVersion", t.modelVersion)
+		}
+		if t.handlerType == constant.GeminiCLI {
+			out, _ = sjson.SetRaw(`{}`, "response", out)
+		}
+		return []byte(out)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+)
+
+const testFooter = "AI-generated content."
+
+func newFooterTagger(t *testing.T, handlerType string, rawJSON []byte) *ResponseTagger {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	cfg := &config.Config{}
+	cfg.ResponseFooter.Text = testFooter
+	h := NewBaseAPIHandlers(cfg, nil)
+	return h.NewResponseTagger(c, handlerType, "gpt-5", rawJSON)
+}
+
+func TestResponseFooterSkipsToolCallOnlyResponses(t *testing.T) {
+	tagger := newFooterTagger(t, constant.OpenAI, []byte(`{"model":"gpt-5"}`))
+	text := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`)
+	if got := gjson.GetBytes(tagger.TagResponse(text), "choices.0.message.content").String(); got != "Hello\n\n"+testFooter {
+		t.Fatalf("content = %q, want the footer appended", got)
+	}
+	tools := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`)
+	if out := tagger.TagResponse(tools); !bytes.Equal(out, tools) {
+		t.Fatalf("tool-call-only response changed to %s", out)
+	}
+}
+
+func TestResponseFooterTagsResponsesAPIOutput(t *testing.T) {
+	tagger := newFooterTagger(t, constant.OpenaiResponse, []byte(`{"model":"gpt-5"}`))
+	if tagger == nil {
+		t.Fatal("no tagger for the Responses API")
+	}
+	resp := []byte(`{"object":"response","output":[{"type":"reasoning","id":"rs_1"},{"type":"message","id":"msg_1","content":[{"type":"output_text","text":"Hello"}]}]}`)
+	if got := gjson.GetBytes(tagger.TagResponse(resp), "output.1.content.0.text").String(); got != "Hello\n\n"+testFooter {
+		t.Fatalf("text = %q, want the footer appended", got)
+	}
+	if newFooterTagger(t, constant.OpenaiResponse, []byte(`{"text":{"format":{"type":"json_schema"}}}`)) != nil {
+		t.Fatal("JSON output would be tagged")
+	}
+}
+
+// responsesStream is a Responses API stream with event and data lines in separate chunks, as
+// Codex sends them.
+var responsesStream = []string{
+	"event: response.output_text.delta",
+	`data: {"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"Hel"}`,
+	"event: response.output_text.delta",
+	`data: {"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"lo"}`,
+	"event: response.output_text.done",
+	`data: {"type":"response.output_text.done","item_id":"msg_1","output_index":0,"content_index":0,"text":"Hello"}`,
+	"event: response.content_part.done",
+	`data: {"type":"response.content_part.done","item_id":"msg_1","output_index":0,"content_index":0,"part":{"type":"output_text","text":"Hello"}}`,
+	"event: response.output_item.done",
+	`data: {"type":"response.output_item.done","output_index":0,"item":{"id":"msg_1","type":"message","content":[{"type":"output_text","text":"Hello"}]}}`,
+	"event: response.completed",
+	`data: {"type":"response.completed","response":{"output":[{"id":"msg_1","type":"message","content":[{"type":"output_text","text":"Hello"}]}]}}`,
+}
+
+// checkTaggedResponsesStream checks that the deltas of out add up to the tagged text, that
+// each delta follows its own event line when there are event lines, and that the events
+// repeating the whole text carry it.
+func checkTaggedResponsesStream(t *testing.T, out string, eventLines bool) {
+	t.Helper()
+	want := "Hello\n\n" + testFooter
+	var deltas strings.Builder
+	event := ""
+	for _, line := range strings.Split(out, "\n") {
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			continue
+		}
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		data := gjson.Parse(payload)
+		if eventLines && data.Get("type").String() != event {
+			t.Fatalf("data of type %s follows event %s:\n%s", data.Get("type").String(), event, out)
+		}
+		switch data.Get("type").String() {
+		case "response.output_text.delta":
+			deltas.WriteString(data.Get("delta").String())
+		case "response.output_text.done":
+			if got := data.Get("text").String(); got != want {
+				t.Errorf("output_text.done text = %q, want %q", got, want)
+			}
+		case "response.content_part.done":
+			if got := data.Get("part.text").String(); got != want {
+				t.Errorf("content_part.done text = %q, want %q", got, want)
+			}
+		case "response.output_item.done":
+			if got := data.Get("item.content.0.text").String(); got != want {
+				t.Errorf("output_item.done text = %q, want %q", got, want)
+			}
+		case "response.completed":
+			if got := data.Get("response.output.0.content.0.text").String(); got != want {
+				t.Errorf("completed text = %q, want %q", got, want)
+			}
+		}
+	}
+	if deltas.String() != want {
+		t.Errorf("deltas add up to %q, want %q", deltas.String(), want)
+	}
+}
+
+func TestResponseFooterTagsResponsesAPIStreams(t *testing.T) {
+	t.Run("separate event lines", func(t *testing.T) {
+		tagger := newFooterTagger(t, constant.OpenaiResponse, []byte(`{"model":"gpt-5"}`))
+		var out []string
+		for _, chunk := range responsesStream {
+			out = append(out, string(bytes.Join(tagger.Stream([]byte(chunk)), nil)))
+		}
+		if final := tagger.Finish(); final != nil {
+			t.Fatalf("Finish returned %s after the completed event", final)
+		}
+		checkTaggedResponsesStream(t, strings.Join(out, "\n"), true)
+	})
+	t.Run("whole events", func(t *testing.T) {
+		tagger := newFooterTagger(t, constant.OpenaiResponse, []byte(`{"model":"gpt-5"}`))
+		var out []string
+		for i := 0; i < len(responsesStream); i += 2 {
+			out = append(out, string(bytes.Join(tagger.Stream([]byte(responsesStream[i]+"\n"+responsesStream[i+1])), nil)))
+		}
+		checkTaggedResponsesStream(t, strings.Join(out, "\n\n"), true)
+	})
+	t.Run("data lines only", func(t *testing.T) {
+		tagger := newFooterTagger(t, constant.OpenaiResponse, []byte(`{"model":"gpt-5"}`))
+		var out []string
+		for i := 1; i < len(responsesStream); i += 2 {
+			out = append(out, string(bytes.Join(tagger.Stream([]byte(responsesStream[i])), nil)))
+		}
+		checkTaggedResponsesStream(t, strings.Join(out, "\n"), false)
+	})
+}
+
+func TestResponseFooterPrecedesOpenAIFinishChunk(t *testing.T) {
+	tagger := newFooterTagger(t, constant.OpenAI, []byte(`{"model":"gpt-5"}`))
+	var out [][]byte
+	for _, chunk := range []string{
+		`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","choices":[],"usage":{"total_tokens":3}}`,
+	} {
+		out = append(out, tagger.Stream([]byte(chunk))...)
+	}
+	if final := tagger.Finish(); final != nil {
+		t.Fatalf("Finish returned %s after the tag was sent", final)
+	}
+	if len(out) != 4 {
+		t.Fatalf("got %d chunks, want 4", len(out))
+	}
+	if got := gjson.GetBytes(out[1], "choices.0.delta.content").String(); got != "\n\n"+testFooter {
+		t.Fatalf("chunk before finish_reason carries %q", got)
+	}
+	if gjson.GetBytes(out[2], "choices.0.finish_reason").String() != "stop" {
+		t.Fatalf("finish chunk moved: %s", out[2])
+	}
+
+	// Text arriving with finish_reason gets the tag in the same chunk.
+	tagger = newFooterTagger(t, constant.OpenAI, []byte(`{"model":"gpt-5"}`))
+	out = tagger.Stream([]byte(`{"id":"c1","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":"stop"}]}`))
+	if len(out) != 1 || gjson.GetBytes(out[0], "choices.0.delta.content").String() != "Hello\n\n"+testFooter {
+		t.Fatalf("chunks = %q", out)
+	}
+}
+
+func TestAuditTagIgnoresClientRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set("X-Request-ID", "forged-id")
+	c.Set("apiKey", "tagged-key")
+	cfg := &config.Config{}
+	cfg.ResponseTag.Enable = true
+	cfg.ResponseTag.APIKeys = map[string]string{"tagged-key": "team-a"}
+	h := NewBaseAPIHandlers(cfg, nil)
+
+	tagger := h.NewResponseTagger(c, constant.OpenAI, "gpt-5", []byte(`{"model":"gpt-5"}`))
+	resp := tagger.TagResponse([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"Hello"}}]}`))
+	content := gjson.GetBytes(resp, "choices.0.message.content").String()
+	ref := logging.ResponseTagRef(context.WithValue(context.Background(), "gin", c))
+	if ref == "" || strings.Contains(content, "forged-id") {
+		t.Fatalf("tag = %q, recorded reference %q", content, ref)
+	}
+	if content != "Hello\n\n[ref:"+ref+"]" {
+		t.Fatalf("content = %q, want the recorded reference %q", content, ref)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/docstore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// ragHeader names the document store a request should be augmented from.
+	ragHeader = "X-CLIProxy-RAG"
+
+	defaultEmbeddingModel = "gemini-embedding-001"
+	defaultRAGTopK        = 4
+)
+
+// EmbedTexts embeds texts with the configured RAG embedding model through the regular
+// embedContent path, so any configured Gemini credential can serve it.
+func (h *BaseAPIHandler) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	model := defaultEmbeddingModel
+	if h.Cfg != nil && strings.TrimSpace(h.Cfg.RAG.EmbeddingModel) != "" {
+		model = strings.TrimSpace(h.Cfg.RAG.EmbeddingModel)
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		payload, _ := sjson.SetBytes([]byte(`{"content":{"parts":[{"text":""}]}}`), "content.parts.0.text", text)
+		resp, errMsg := h.ExecuteEmbedWithAuthManager(ctx, constant.Gemini, model, payload)
+		if errMsg != nil {
+			return nil, errMsg.Error
+		}
+		values := gjson.GetBytes(resp, "embedding.values").Array()
+		if len(values) == 0 {
+			return nil, fmt.Errorf("embedding response from %s has no values", model)
+		}
+		vector := make([]float32, len(values))
+		for i, v := range values {
+			vector[i] = float32(v.Float())
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+// retrievalContext adds the document store chunks closest to the last user turn to the
+// system instructions when the request names a store. The "rag" body field is always
+// removed while RAG is enabled so it never reaches an upstream.
+func (h *BaseAPIHandler) retrievalContext(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	if h.Cfg == nil || !h.Cfg.RAG.Enable {
+		return rawJSON, nil
+	}
+	var storeName string
+	topK := 0
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	if ginCtx != nil && ginCtx.Request != nil {
+		storeName = strings.TrimSpace(ginCtx.GetHeader(ragHeader))
+	}
+	field := gjson.GetBytes(rawJSON, "rag")
+	if field.Exists() {
+		if storeName == "" {
+			if field.IsObject() {
+				storeName = strings.TrimSpace(field.Get("store").String())
+				topK = int(field.Get("top_k").Int())
+			} else {
+				storeName = strings.TrimSpace(field.String())
+			}
+		}
+		rawJSON = stripRetrievalField(rawJSON)
+	}
+	if storeName == "" {
+		return rawJSON, nil
+	}
+	if topK <= 0 {
+		topK = h.Cfg.RAG.TopK
+	}
+	if topK <= 0 {
+		topK = defaultRAGTopK
+	}
+
+	query := strings.TrimSpace(util.LastUserText(handlerType, rawJSON))
+	if query == "" {
+		return rawJSON, nil
+	}
+	apiKey := ""
+	if ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+	}
+	matches, err := docstore.New(h.Cfg.AuthDir).Search(ctx, apiKey, storeName, query, topK, h.EmbedTexts)
+	if err != nil {
+		return nil, retrievalError(storeName, err)
+	}
+	if len(matches) == 0 {
+		return rawJSON, nil
+	}
+	ids := make([]string, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, fmt.Sprintf("%s (%.3f)", match.ID, match.Score))
+	}
+	logging.RecordRetrievalNote(ctx, fmt.Sprintf("store %s: %s", storeName, strings.Join(ids, ", ")))
+	return util.InjectSystemText(handlerType, rawJSON, docstore.FormatContext(storeName, matches)), nil
+}
+
+// stripRetrievalField removes the "rag" body field, which only this proxy understands.
+func stripRetrievalField(rawJSON []byte) []byte {
+	if !gjson.GetBytes(rawJSON, "rag").Exists() {
+		return rawJSON
+	}
+	if updated, err := sjson.DeleteBytes(rawJSON, "rag"); err == nil {
+		return updated
+	}
+	return rawJSON
+}
+
+func retrievalError(store string, err error) *interfaces.ErrorMessage {
+	switch {
+	case errors.Is(err, docstore.ErrNotFound):
+		return &interfaces.ErrorMessage{
+			StatusCode: http.StatusNotFound,
+			Error:      fmt.Errorf("document store %q not found", store),
+			Kind:       coreexecutor.ErrorKindInvalid,
+		}
+	case errors.Is(err, docstore.ErrInvalidName):
+		return &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      err,
+			Kind:       coreexecutor.ErrorKindInvalid,
+		}
+	case errors.Is(err, docstore.ErrEmbedding):
+		return &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadGateway,
+			Error:      fmt.Errorf("document retrieval failed: %w", err),
+		}
+	default:
+		return &interfaces.ErrorMessage{
+			StatusCode: http.StatusInternalServerError,
+			Error:      fmt.Errorf("document retrieval failed: %w", err),
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// checkSafetySettings rejects a request whose Gemini safety settings name an unknown harm
+// category or threshold. Gemini requests carry them in safetySettings, other formats in the
+// safety_settings extension field.
+func checkSafetySettings(handlerType string, rawJSON []byte) *interfaces.ErrorMessage {
+	field := "safety_settings"
+	switch handlerType {
+	case constant.Gemini:
+		field = "safetySettings"
+	case constant.GeminiCLI:
+		field = "request.safetySettings"
+	}
+	if _, err := util.ParseGeminiSafetySettings(gjson.GetBytes(rawJSON, field)); err != nil {
+		return &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("invalid %s: %w", field, err),
+			Kind:       coreexecutor.ErrorKindInvalid,
+		}
+	}
+	return nil
+}
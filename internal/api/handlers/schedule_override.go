@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ScheduleOverrideHeader carries an admin management key on requests that may use accounts
+// outside their active hours, for emergencies when every account is resting.
+const ScheduleOverrideHeader = "X-CLIProxy-Ignore-Schedule"
+
+// maxOverrideVerdicts bounds the keys whose verdict is remembered; the cache starts over
+// once it is full.
+const maxOverrideVerdicts = 1024
+
+// overrideVerdicts remembers which keys sent in ScheduleOverrideHeader were admin keys of
+// cfg, so bcrypt runs once per key rather than once per request.
+type overrideVerdicts struct {
+	mu       sync.Mutex
+	cfg      *config.Config
+	verdicts map[string]bool
+}
+
+// scheduleOverridden reports whether the request in c holds an admin management key in
+// ScheduleOverrideHeader: remote-management.secret-key or a key of remote-management.keys
+// with the admin role. Without such a key configured the header is ignored.
+func (h *BaseAPIHandler) scheduleOverridden(c *gin.Context) bool {
+	cfg := h.Cfg
+	if cfg == nil || c == nil || c.Request == nil {
+		return false
+	}
+	provided := strings.TrimSpace(c.GetHeader(ScheduleOverrideHeader))
+	if provided == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(provided))
+	principal := hex.EncodeToString(sum[:])
+
+	h.overrides.mu.Lock()
+	if h.overrides.cfg != cfg || len(h.overrides.verdicts) >= maxOverrideVerdicts {
+		h.overrides.cfg = cfg
+		h.overrides.verdicts = make(map[string]bool)
+	}
+	valid, known := h.overrides.verdicts[principal]
+	h.overrides.mu.Unlock()
+
+	if !known {
+		valid = isAdminManagementKey(cfg.RemoteManagement, provided)
+		h.overrides.mu.Lock()
+		if h.overrides.cfg == cfg {
+			h.overrides.verdicts[principal] = valid
+		}
+		h.overrides.mu.Unlock()
+	}
+	if !valid {
+		log.Warnf("ignoring %s from %s: invalid management key", ScheduleOverrideHeader, c.ClientIP())
+	}
+	return valid
+}
+
+// isAdminManagementKey reports whether provided matches the secret key or an admin key of
+// settings. Keys limited to endpoint groups are not admin keys.
+func isAdminManagementKey(settings config.RemoteManagement, provided string) bool {
+	if settings.SecretKey != "" && bcrypt.CompareHashAndPassword([]byte(settings.SecretKey), []byte(provided)) == nil {
+		return true
+	}
+	for _, entry := range settings.Keys {
+		if entry.Key == "" || entry.Role != config.ManagementRoleAdmin || len(entry.Groups) > 0 {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(entry.Key), []byte(provided)) == nil {
+			return true
+		}
+	}
+	return false
+}
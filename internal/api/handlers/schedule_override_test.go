@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestScheduleOverrideAcceptsAdminKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash := func(key string) string {
+		sum, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(sum)
+	}
+	cfg := &config.Config{}
+	cfg.RemoteManagement.SecretKey = hash("secret")
+	cfg.RemoteManagement.Keys = []config.ManagementKey{
+		{Label: "ops", Key: hash("ops-key"), Role: config.ManagementRoleAdmin},
+		{Label: "viewer", Key: hash("viewer-key"), Role: config.ManagementRoleReadOnly},
+		{Label: "usage", Key: hash("usage-key"), Role: config.ManagementRoleAdmin, Groups: []string{"usage"}},
+	}
+	h := NewBaseAPIHandlers(cfg, nil)
+	overridden := func(key string) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		c.Request.Header.Set(ScheduleOverrideHeader, key)
+		return h.scheduleOverridden(c)
+	}
+
+	for key, want := range map[string]bool{"secret": true, "ops-key": true, "viewer-key": false, "usage-key": false, "wrong": false} {
+		if got := overridden(key); got != want {
+			t.Errorf("override with %q = %t, want %t", key, got, want)
+		}
+	}
+
+	// Verdicts are remembered, so bcrypt runs once per key.
+	if len(h.overrides.verdicts) != 5 {
+		t.Fatalf("remembered %d verdicts, want 5", len(h.overrides.verdicts))
+	}
+	cfg.RemoteManagement.SecretKey = ""
+	if !overridden("secret") {
+		t.Fatal("remembered verdict not used")
+	}
+	// A new config starts over.
+	h.UpdateClients(&config.Config{})
+	if overridden("secret") {
+		t.Fatal("verdict of the previous config used")
+	}
+}
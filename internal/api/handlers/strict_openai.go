@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// DeprecatedParamsHeader lists the deprecated request fields of a strictly checked request,
+// each with the field to use instead.
+const DeprecatedParamsHeader = "X-CLIProxy-Deprecated-Params"
+
+// proxyFields are request fields the proxy itself understands on top of the OpenAI API.
+var proxyFields = []string{"rag", "safety_settings"}
+
+// openAIFields lists the top-level request fields of each OpenAI endpoint accepted by
+// strict-openai. Fields are taken from the OpenAI API reference; deprecated fields stay
+// listed so they can be reported instead of rejected.
+var openAIFields = map[string][]string{
+	EndpointChatCompletions: {
+		"model", "messages", "audio", "frequency_penalty", "function_call", "functions",
+		"logit_bias", "logprobs", "max_completion_tokens", "max_tokens", "metadata", "modalities",
+		"n", "parallel_tool_calls", "prediction", "presence_penalty", "prompt_cache_key",
+		"reasoning_effort", "response_format", "safety_identifier", "seed", "service_tier", "stop",
+		"store", "stream", "stream_options", "temperature", "tool_choice", "tools", "top_logprobs",
+		"top_p", "user", "verbosity", "web_search_options",
+	},
+	EndpointCompletions: {
+		"model", "prompt", "best_of", "echo", "frequency_penalty", "logit_bias", "logprobs",
+		"max_tokens", "n", "presence_penalty", "seed", "stop", "stream", "stream_options",
+		"suffix", "temperature", "top_p", "user",
+	},
+	EndpointResponses: {
+		"model", "input", "background", "conversation", "include", "instructions",
+		"max_output_tokens", "max_tool_calls", "metadata", "parallel_tool_calls",
+		"previous_response_id", "prompt", "prompt_cache_key", "reasoning", "safety_identifier",
+		"service_tier", "store", "stream", "stream_options", "temperature", "text", "tool_choice",
+		"tools", "top_logprobs", "top_p", "truncation", "user",
+	},
+}
+
+// deprecation describes a deprecated request field and what replaces it.
+type deprecation struct {
+	field       string
+	replacement string
+	// migrate rewrites the body to use the replacement, or is nil when the field is only
+	// reported.
+	migrate func(rawJSON []byte) []byte
+}
+
+// openAIDeprecations lists the deprecated fields of each OpenAI endpoint.
+var openAIDeprecations = map[string][]deprecation{
+	EndpointChatCompletions: {
+		{field: "functions", replacement: "tools", migrate: migrateFunctions},
+		{field: "function_call", replacement: "tool_choice", migrate: migrateFunctionCall},
+		{field: "max_tokens", replacement: "max_completion_tokens"},
+		{field: "user", replacement: "safety_identifier"},
+	},
+	EndpointCompletions: {
+		{field: "user", replacement: "safety_identifier"},
+	},
+	EndpointResponses: {
+		{field: "user", replacement: "safety_identifier"},
+	},
+}
+
+// UnknownOpenAIFields returns the top-level fields of rawJSON that endpoint does not
+// accept, sorted. Endpoints without a field list accept everything.
+func UnknownOpenAIFields(endpoint string, rawJSON []byte) []string {
+	known, ok := openAIFields[endpoint]
+	if !ok {
+		return nil
+	}
+	var unknown []string
+	gjson.ParseBytes(rawJSON).ForEach(func(key, _ gjson.Result) bool {
+		name := key.String()
+		if !slices.Contains(known, name) && !slices.Contains(proxyFields, name) {
+			unknown = append(unknown, name)
+		}
+		return true
+	})
+	sort.Strings(unknown)
+	return unknown
+}
+
+// MigrateDeprecatedOpenAIFields rewrites the deprecated fields of rawJSON that have a
+// direct replacement and returns the body with the deprecated fields found, each as
+// "field (use replacement)".
+func MigrateDeprecatedOpenAIFields(endpoint string, rawJSON []byte) ([]byte, []string) {
+	var found []string
+	for _, d := range openAIDeprecations[endpoint] {
+		if !gjson.GetBytes(rawJSON, d.field).Exists() {
+			continue
+		}
+		found = append(found, d.field+" (use "+d.replacement+")")
+		if d.migrate != nil {
+			rawJSON = d.migrate(rawJSON)
+		}
+	}
+	return rawJSON, found
+}
+
+// migrateFunctions turns the legacy functions list into function tools, unless the request
+// already declares tools.
+func migrateFunctions(rawJSON []byte) []byte {
+	functions := gjson.GetBytes(rawJSON, "functions")
+	if functions.IsArray() && !gjson.GetBytes(rawJSON, "tools").Exists() {
+		tools := "[]"
+		functions.ForEach(func(_, function gjson.Result) bool {
+			tool, _ := sjson.SetRaw(`{"type":"function"}`, "function", function.Raw)
+			tools, _ = sjson.SetRaw(tools, "-1", tool)
+			return true
+		})
+		if out, err := sjson.SetRawBytes(rawJSON, "tools", []byte(tools)); err == nil {
+			rawJSON = out
+		}
+	}
+	if out, err := sjson.DeleteBytes(rawJSON, "functions"); err == nil {
+		rawJSON = out
+	}
+	return rawJSON
+}
+
+// migrateFunctionCall turns the legacy function_call choice into tool_choice, unless the
+// request already sets one: "none" and "auto" keep their meaning and {"name": ...} becomes
+// a forced function tool.
+func migrateFunctionCall(rawJSON []byte) []byte {
+	call := gjson.GetBytes(rawJSON, "function_call")
+	if !gjson.GetBytes(rawJSON, "tool_choice").Exists() {
+		choice := ""
+		switch {
+		case call.Type == gjson.String:
+			choice = call.Raw
+		case call.Get("name").Exists():
+			choice, _ = sjson.Set(`{"type":"function"}`, "function.name", call.Get("name").String())
+		}
+		if choice != "" {
+			if out, err := sjson.SetRawBytes(rawJSON, "tool_choice", []byte(choice)); err == nil {
+				rawJSON = out
+			}
+		}
+	}
+	if out, err := sjson.DeleteBytes(rawJSON, "function_call"); err == nil {
+		rawJSON = out
+	}
+	return rawJSON
+}
+
+// strictOpenAI reports whether strict-openai applies to the request served to c.
+func (h *BaseAPIHandler) strictOpenAI(c *gin.Context) bool {
+	if h.Cfg == nil || !h.Cfg.StrictOpenAI.Enable {
+		return false
+	}
+	keys := h.Cfg.StrictOpenAI.APIKeys
+	return len(keys) == 0 || slices.Contains(keys, c.GetString("apiKey"))
+}
+
+// CheckStrictOpenAI applies strict-openai to an OpenAI request for endpoint. Requests with
+// unknown top-level fields get a 400 listing them; deprecated fields are reported in the
+// deprecated params header and the log, and migrated where a direct replacement exists.
+// It returns the body to serve and whether the request may proceed; without strict-openai
+// the body is returned unchanged.
+func (h *BaseAPIHandler) CheckStrictOpenAI(c *gin.Context, endpoint string, rawJSON []byte) ([]byte, bool) {
+	if !h.strictOpenAI(c) {
+		return rawJSON, true
+	}
+	if unknown := UnknownOpenAIFields(endpoint, rawJSON); len(unknown) > 0 {
+		errs := make([]FieldError, 0, len(unknown))
+		for _, field := range unknown {
+			errs = append(errs, FieldError{Field: field, Message: "unknown field"})
+		}
+		c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+			Error: ValidationErrorDetail{
+				ErrorDetail: ErrorDetail{
+					Message: "Invalid request: " + FormatFieldErrors(errs),
+					Type:    "invalid_request_error",
+				},
+				Param:  errs[0].Field,
+				Fields: errs,
+			},
+		})
+		return rawJSON, false
+	}
+	rawJSON, deprecated := MigrateDeprecatedOpenAIFields(endpoint, rawJSON)
+	for _, field := range deprecated {
+		c.Writer.Header().Add(DeprecatedParamsHeader, field)
+	}
+	if len(deprecated) > 0 {
+		log.Warnf("strict-openai: %s request from %s uses deprecated fields: %v", endpoint, c.ClientIP(), deprecated)
+	}
+	return rawJSON, true
+}
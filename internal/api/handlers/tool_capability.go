@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// IgnoredParamsHeader lists the request parameters the backend that served the request
+// could not honour. Values are added, so each check reports its own parameters.
+const IgnoredParamsHeader = "X-CLIProxy-Ignored-Params"
+
+// toolFields are the body fields declaring tools across the client dialects.
+var toolFields = []string{"tools", "functions", "request.tools"}
+
+// declaresTools reports whether a request body declares tools or functions the model may
+// call.
+func declaresTools(rawJSON []byte) bool {
+	for _, field := range toolFields {
+		if tools := gjson.GetBytes(rawJSON, field); tools.IsArray() && len(tools.Array()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// toolCapableProviders leaves gemini-web out of providers for a request declaring tools,
+// since the web app has no function calling and would answer with prose, unless
+// gemini-web.allow-tools is set. When gemini-web is the only provider of modelName the
+// request is rejected with 400.
+func (h *BaseAPIHandler) toolCapableProviders(modelName string, rawJSON []byte, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if !slices.Contains(providers, "gemini-web") || !declaresTools(rawJSON) || (h.Cfg != nil && h.Cfg.GeminiWeb.AllowTools) {
+		return providers, nil
+	}
+	capable := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if provider != "gemini-web" {
+			capable = append(capable, provider)
+		}
+	}
+	if len(capable) == 0 {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("model %s is only available through gemini-web, which does not support tool calling; remove the tools or use another model", modelName),
+			Kind:       coreexecutor.ErrorKindInvalid,
+		}
+	}
+	return capable, nil
+}
+
+// reportIgnoredTools adds tools to the ignored parameters header when gemini-web served a
+// request declaring them, which gemini-web.allow-tools lets through. It must run before the
+// response is written.
+func reportIgnoredTools(ctx context.Context, rawJSON []byte) {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	if provider, _ := logging.RequestTarget(ginCtx); provider == "gemini-web" && declaresTools(rawJSON) {
+		ginCtx.Writer.Header().Add(IgnoredParamsHeader, "tools")
+	}
+}
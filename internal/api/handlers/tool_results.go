@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// toolResultLimitHeader reports how oversized tool results of the request were handled.
+	toolResultLimitHeader = "X-CLIProxy-Tool-Result-Limit"
+
+	defaultSummaryModel = "gemini-2.5-flash-lite"
+	// summaryInputBytes caps the tool result text sent to the summary model, which has a
+	// context limit of its own.
+	summaryInputBytes = 256 << 10
+	// maxConcurrentSummaries bounds the summary requests one request runs at a time.
+	maxConcurrentSummaries = 4
+	// summaryTimeout bounds each summary request.
+	summaryTimeout = 60 * time.Second
+)
+
+// toolResultPart is one tool result text of a request and the body path it lives at.
+type toolResultPart struct {
+	path string
+	text string
+}
+
+// limitToolResults applies the tool result size guard to a request in the client's dialect.
+// Limits are the strictest of those configured for providers, since any of them may end up
+// serving the request. Results over a limit are truncated, summarized, or fail the request
+// with 413, depending on the configured strategy.
+func (h *BaseAPIHandler) limitToolResults(ctx context.Context, handlerType string, providers []string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	if h.Cfg == nil {
+		return rawJSON, nil
+	}
+	guard := h.Cfg.ToolResultLimit
+	limits := guard.Limits(providers)
+	if limits.MaxBytes <= 0 && limits.MaxRequestBytes <= 0 {
+		return rawJSON, nil
+	}
+	parts := toolResultParts(handlerType, rawJSON)
+	if len(parts) == 0 {
+		return rawJSON, nil
+	}
+
+	targets := toolResultTargets(parts, limits)
+	oversized := make([]int, 0)
+	total := 0
+	for i, part := range parts {
+		total += len(part.text)
+		if targets[i] < len(part.text) {
+			oversized = append(oversized, i)
+		}
+	}
+	if len(oversized) == 0 {
+		return rawJSON, nil
+	}
+
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	strategy := strings.ToLower(strings.TrimSpace(guard.Strategy))
+	if strategy == config.ToolResultReject {
+		var reason string
+		if first := parts[oversized[0]]; limits.MaxBytes > 0 && len(first.text) > limits.MaxBytes {
+			reason = fmt.Sprintf("tool result of %d bytes exceeds the limit of %d bytes", len(first.text), limits.MaxBytes)
+		} else {
+			reason = fmt.Sprintf("tool results of %d bytes exceed the request limit of %d bytes", total, limits.MaxRequestBytes)
+		}
+		logging.RecordToolResultNote(ctx, "rejected: "+reason)
+		if ginCtx != nil {
+			ginCtx.Header(toolResultLimitHeader, "rejected")
+		}
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusRequestEntityTooLarge, Error: fmt.Errorf("%s", reason), Kind: coreexecutor.ErrorKindInvalid}
+	}
+
+	var summaries []string
+	var summaryErrs []error
+	if strategy == config.ToolResultSummarize {
+		summaries, summaryErrs = h.summarizeToolResults(ctx, parts, oversized, targets)
+	}
+	action := "truncated"
+	removed := 0
+	for k, i := range oversized {
+		part := parts[i]
+		replacement := ""
+		if strategy == config.ToolResultSummarize {
+			summary, err := summaries[k], summaryErrs[k]
+			if err != nil {
+				log.Warnf("tool result limit: summary failed, truncating instead: %v", err)
+				logging.RecordToolResultNote(ctx, fmt.Sprintf("%s: summary failed (%v), truncated", part.path, err))
+			} else {
+				replacement = summary
+				action = "summarized"
+				logging.RecordToolResultNote(ctx, fmt.Sprintf("%s: %d bytes summarized to %d", part.path, len(part.text), len(summary)))
+			}
+		}
+		if replacement == "" {
+			replacement = truncateMiddle(part.text, targets[i])
+			if strategy != config.ToolResultSummarize {
+				logging.RecordToolResultNote(ctx, fmt.Sprintf("%s: %d bytes truncated to %d", part.path, len(part.text), targets[i]))
+			}
+		}
+		removed += len(part.text) - len(replacement)
+		if updated, err := sjson.SetBytes(rawJSON, part.path, replacement); err == nil {
+			rawJSON = updated
+		}
+	}
+	if ginCtx != nil {
+		ginCtx.Header(toolResultLimitHeader, fmt.Sprintf("%s; results=%d; removed-bytes=%d", action, len(oversized), removed))
+	}
+	return rawJSON, nil
+}
+
+// toolResultTargets returns the size each part is cut down to: at most MaxBytes each, and
+// when the parts together still exceed MaxRequestBytes, the largest ones shrink to an equal
+// share of what the smaller ones leave.
+func toolResultTargets(parts []toolResultPart, limits config.ToolResultLimits) []int {
+	targets := make([]int, len(parts))
+	total := 0
+	for i, part := range parts {
+		targets[i] = len(part.text)
+		if limits.MaxBytes > 0 && targets[i] > limits.MaxBytes {
+			targets[i] = limits.MaxBytes
+		}
+		total += targets[i]
+	}
+	if limits.MaxRequestBytes <= 0 || total <= limits.MaxRequestBytes {
+		return targets
+	}
+	sizes := append([]int(nil), targets...)
+	sort.Ints(sizes)
+	remaining := limits.MaxRequestBytes
+	share := 0
+	for i, size := range sizes {
+		share = remaining / (len(sizes) - i)
+		if size > share {
+			break
+		}
+		remaining -= size
+	}
+	for i := range targets {
+		if targets[i] > share {
+			targets[i] = share
+		}
+	}
+	return targets
+}
+
+// truncateMiddle keeps the head and tail of text, limit bytes together, around a marker
+// saying how much was removed. Cuts fall on UTF-8 boundaries.
+func truncateMiddle(text string, limit int) string {
+	if limit < 0 {
+		limit = 0
+	}
+	if len(text) <= limit {
+		return text
+	}
+	head := limit / 2
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	tail := len(text) - (limit - head)
+	for tail < len(text) && !utf8.RuneStart(text[tail]) {
+		tail++
+	}
+	return text[:head] + fmt.Sprintf("\n\n[... %d bytes truncated ...]\n\n", tail-head) + text[tail:]
+}
+
+// summarizeToolResults summarizes the oversized parts, at most maxConcurrentSummaries at a
+// time, and returns the summaries and errors in the order of oversized.
+func (h *BaseAPIHandler) summarizeToolResults(ctx context.Context, parts []toolResultPart, oversized, targets []int) ([]string, []error) {
+	summaryCtx, cancel := summaryContext(ctx)
+	defer cancel()
+	summaries := make([]string, len(oversized))
+	errs := make([]error, len(oversized))
+	sem := make(chan struct{}, maxConcurrentSummaries)
+	var wg sync.WaitGroup
+	for k, i := range oversized {
+		wg.Add(1)
+		go func(k, i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			summaries[k], errs[k] = h.summarizeToolResult(summaryCtx, parts[i].text, targets[i])
+		}(k, i)
+	}
+	wg.Wait()
+	return summaries, errs
+}
+
+// summaryContext returns the context of summary requests. It ends with ctx or after
+// summaryTimeout but carries none of its values: summaries are the proxy's own requests and
+// must not touch the client's response, request log or credentials.
+func summaryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	summaryCtx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	stop := context.AfterFunc(ctx, cancel)
+	return summaryCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// summarizeToolResult asks the configured summary model for a summary of text that fits in
+// limit bytes. It goes through the regular chat completions path, so any credential able to
+// serve the model can be used.
+func (h *BaseAPIHandler) summarizeToolResult(ctx context.Context, text string, limit int) (string, error) {
+	model := defaultSummaryModel
+	if name := strings.TrimSpace(h.Cfg.ToolResultLimit.SummaryModel); name != "" {
+		model = name
+	}
+	instruction := fmt.Sprintf("Summarize the following tool output in at most %d bytes. Keep errors, file paths, identifiers and numbers that a reader acting on the output would need. Reply with the summary only.", limit)
+	body := []byte(`{"messages":[{"role":"system","content":""},{"role":"user","content":""}]}`)
+	body, _ = sjson.SetBytes(body, "model", model)
+	body, _ = sjson.SetBytes(body, "messages.0.content", instruction)
+	body, _ = sjson.SetBytes(body, "messages.1.content", truncateMiddle(text, summaryInputBytes))
+	body, _ = sjson.SetBytes(body, "max_tokens", max(limit/4, 64))
+
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, constant.OpenAI, model, body, "")
+	if errMsg != nil {
+		return "", errMsg.Error
+	}
+	summary := strings.TrimSpace(gjson.GetBytes(resp, "choices.0.message.content").String())
+	if summary == "" {
+		return "", fmt.Errorf("summary model %s returned no text", model)
+	}
+	prefix := fmt.Sprintf("[Summary of %d bytes of tool output]\n", len(text))
+	return truncateMiddle(prefix+summary, limit), nil
+}
+
+// toolResultParts returns the tool result texts of a request in the client's dialect.
+// Multi-part results yield one entry per text part.
+func toolResultParts(handlerType string, rawJSON []byte) []toolResultPart {
+	root := gjson.ParseBytes(rawJSON)
+	var parts []toolResultPart
+	switch handlerType {
+	case constant.OpenAI:
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			if message.Get("role").String() == "tool" {
+				parts = appendTextParts(parts, "messages."+i.String()+".content", message.Get("content"))
+			}
+			return true
+		})
+	case constant.OpenaiResponse:
+		root.Get("input").ForEach(func(i, item gjson.Result) bool {
+			switch item.Get("type").String() {
+			case "function_call_output", "custom_tool_call_output":
+				parts = appendTextParts(parts, "input."+i.String()+".output", item.Get("output"))
+			}
+			return true
+		})
+	case constant.Claude:
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			message.Get("content").ForEach(func(j, block gjson.Result) bool {
+				if block.Get("type").String() == "tool_result" {
+					parts = appendTextParts(parts, "messages."+i.String()+".content."+j.String()+".content", block.Get("content"))
+				}
+				return true
+			})
+			return true
+		})
+	case constant.Gemini, constant.GeminiCLI:
+		prefix := ""
+		if handlerType == constant.GeminiCLI {
+			prefix = "request."
+		}
+		root.Get(prefix + "contents").ForEach(func(i, content gjson.Result) bool {
+			content.Get("parts").ForEach(func(j, part gjson.Result) bool {
+				path := prefix + "contents." + i.String() + ".parts." + j.String() + ".functionResponse.response"
+				parts = appendStringLeaves(parts, path, part.Get("functionResponse.response"))
+				return true
+			})
+			return true
+		})
+	}
+	return parts
+}
+
+// appendTextParts adds a tool result content that is either a string or a list of parts, of
+// which only those carrying text are considered.
+func appendTextParts(parts []toolResultPart, path string, content gjson.Result) []toolResultPart {
+	if content.Type == gjson.String {
+		return append(parts, toolResultPart{path: path, text: content.String()})
+	}
+	content.ForEach(func(j, part gjson.Result) bool {
+		if text := part.Get("text"); text.Type == gjson.String {
+			parts = append(parts, toolResultPart{path: path + "." + j.String() + ".text", text: text.String()})
+		}
+		return true
+	})
+	return parts
+}
+
+// appendStringLeaves adds every string inside a Gemini function response, which is free-form
+// JSON.
+func appendStringLeaves(parts []toolResultPart, path string, value gjson.Result) []toolResultPart {
+	switch {
+	case value.Type == gjson.String:
+		parts = append(parts, toolResultPart{path: path, text: value.String()})
+	case value.IsArray():
+		value.ForEach(func(i, item gjson.Result) bool {
+			parts = appendStringLeaves(parts, path+"."+strconv.FormatInt(i.Int(), 10), item)
+			return true
+		})
+	case value.IsObject():
+		value.ForEach(func(key, item gjson.Result) bool {
+			parts = appendStringLeaves(parts, path+"."+escapePathKey(key.String()), item)
+			return true
+		})
+	}
+	return parts
+}
+
+// escapePathKey escapes the characters gjson and sjson treat specially in a path component.
+func escapePathKey(key string) string {
+	var sb strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '!', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// summaryExecutor answers every request with a short chat completion, recording how many
+// ran at once and whether any saw the client's gin context.
+type summaryExecutor struct {
+	bodyExecutor
+	mu      sync.Mutex
+	active  int
+	peak    int
+	sawGin  atomic.Bool
+	calls   atomic.Int32
+	latency time.Duration
+}
+
+func (e *summaryExecutor) Execute(ctx context.Context, _ *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	if ctx.Value("gin") != nil {
+		e.sawGin.Store(true)
+	}
+	e.mu.Lock()
+	e.active++
+	e.peak = max(e.peak, e.active)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.active--
+		e.mu.Unlock()
+	}()
+	select {
+	case <-time.After(e.latency):
+	case <-ctx.Done():
+		return coreexecutor.Response{}, ctx.Err()
+	}
+	return coreexecutor.Response{Payload: []byte(`{"choices":[{"message":{"role":"assistant","content":"short"}}]}`)}, nil
+}
+
+func newSummaryTestHandler(t *testing.T, latency time.Duration) (*BaseAPIHandler, *summaryExecutor) {
+	t.Helper()
+	executor := &summaryExecutor{latency: latency}
+	cfg := &config.Config{ToolResultLimit: config.ToolResultLimitConfig{
+		MaxBytes:     100,
+		Strategy:     config.ToolResultSummarize,
+		SummaryModel: "summary-test-model",
+	}}
+	return newTestHandler(t, cfg, executor, "summary-test-model"), executor
+}
+
+func toolResultRequest(results int) []byte {
+	body := []byte(`{"messages":[]}`)
+	for i := 0; i < results; i++ {
+		body, _ = sjson.SetBytes(body, "messages.-1", map[string]any{"role": "tool", "tool_call_id": "call", "content": strings.Repeat("x", 1000)})
+	}
+	return body
+}
+
+func TestSummarizeToolResultsConcurrentlyWithoutClientContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, executor := newSummaryTestHandler(t, 20*time.Millisecond)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	out, errMsg := h.limitToolResults(ctx, "openai", []string{"gemini"}, toolResultRequest(10))
+	if errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	if executor.sawGin.Load() {
+		t.Fatal("summary request carried the client's gin context")
+	}
+	if executor.calls.Load() != 10 {
+		t.Fatalf("%d summary requests, want 10", executor.calls.Load())
+	}
+	if executor.peak < 2 || executor.peak > maxConcurrentSummaries {
+		t.Fatalf("peak concurrency %d, want between 2 and %d", executor.peak, maxConcurrentSummaries)
+	}
+	gjson.GetBytes(out, "messages").ForEach(func(_, message gjson.Result) bool {
+		if content := message.Get("content").String(); !strings.HasSuffix(content, "short") {
+			t.Fatalf("tool result not summarized: %q", content)
+		}
+		return true
+	})
+	if header := c.Writer.Header().Get(toolResultLimitHeader); !strings.HasPrefix(header, "summarized; results=10") {
+		t.Fatalf("%s = %q", toolResultLimitHeader, header)
+	}
+}
+
+func TestSummariesEndWithTheClientRequest(t *testing.T) {
+	h, _ := newSummaryTestHandler(t, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	out, errMsg := h.limitToolResults(ctx, "openai", []string{"gemini"}, toolResultRequest(2))
+	if errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("summaries outlived the cancelled request by %s", elapsed)
+	}
+	if content := gjson.GetBytes(out, "messages.0.content").String(); !strings.Contains(content, "bytes truncated") {
+		t.Fatalf("failed summary not truncated instead: %q", content)
+	}
+}
+
+func newToolResultTestContext() (context.Context, *gin.Context) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	return context.WithValue(context.Background(), "gin", c), c
+}
+
+func TestTruncateToolResultsKeepsHeadAndTail(t *testing.T) {
+	h := NewBaseAPIHandlers(&config.Config{ToolResultLimit: config.ToolResultLimitConfig{MaxBytes: 100}}, nil)
+	ctx, c := newToolResultTestContext()
+	body := []byte(`{"messages":[{"role":"tool","tool_call_id":"call","content":""}]}`)
+	body, _ = sjson.SetBytes(body, "messages.0.content", "HEAD"+strings.Repeat("x", 1000)+"TAIL")
+
+	out, errMsg := h.limitToolResults(ctx, "openai", nil, body)
+	if errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	content := gjson.GetBytes(out, "messages.0.content").String()
+	if !strings.HasPrefix(content, "HEAD") || !strings.HasSuffix(content, "TAIL") || !strings.Contains(content, "[... 908 bytes truncated ...]") {
+		t.Fatalf("truncated content = %q", content)
+	}
+	if header := c.Writer.Header().Get(toolResultLimitHeader); header != "truncated; results=1; removed-bytes=875" {
+		t.Fatalf("%s = %q", toolResultLimitHeader, header)
+	}
+}
+
+func TestRejectOversizedToolResultsWith413(t *testing.T) {
+	h := NewBaseAPIHandlers(&config.Config{ToolResultLimit: config.ToolResultLimitConfig{MaxBytes: 100, Strategy: config.ToolResultReject}}, nil)
+	ctx, c := newToolResultTestContext()
+
+	out, errMsg := h.limitToolResults(ctx, "openai", nil, toolResultRequest(1))
+	if errMsg == nil || errMsg.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("error = %+v, want 413", errMsg)
+	}
+	if out != nil || !strings.Contains(errMsg.Error.Error(), "1000 bytes exceeds the limit of 100") {
+		t.Fatalf("error = %v", errMsg.Error)
+	}
+	if header := c.Writer.Header().Get(toolResultLimitHeader); header != "rejected" {
+		t.Fatalf("%s = %q", toolResultLimitHeader, header)
+	}
+}
+
+func TestOnlyTheOversizedPartOfAMultiPartToolResultIsCut(t *testing.T) {
+	h := NewBaseAPIHandlers(&config.Config{ToolResultLimit: config.ToolResultLimitConfig{MaxBytes: 100}}, nil)
+	ctx, _ := newToolResultTestContext()
+	body := []byte(`{"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"t","content":[{"type":"text","text":"small"},{"type":"image","source":{}},{"type":"text","text":""}]}]}]}`)
+	body, _ = sjson.SetBytes(body, "messages.0.content.0.content.2.text", strings.Repeat("y", 500))
+
+	out, errMsg := h.limitToolResults(ctx, "claude", nil, body)
+	if errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	parts := gjson.GetBytes(out, "messages.0.content.0.content")
+	if parts.Get("0.text").String() != "small" || parts.Get("1.type").String() != "image" {
+		t.Fatalf("untouched parts changed: %s", parts.Raw)
+	}
+	if text := parts.Get("2.text").String(); len(text) >= 500 || !strings.Contains(text, "bytes truncated") {
+		t.Fatalf("oversized part not truncated: %q", text)
+	}
+}
+
+func TestProviderToolResultLimitsApplyTheStrictest(t *testing.T) {
+	h := NewBaseAPIHandlers(&config.Config{ToolResultLimit: config.ToolResultLimitConfig{
+		MaxBytes:  2000,
+		Providers: map[string]config.ToolResultLimits{"gemini": {MaxBytes: 100}},
+	}}, nil)
+	ctx, _ := newToolResultTestContext()
+
+	out, _ := h.limitToolResults(ctx, "openai", []string{"claude"}, toolResultRequest(1))
+	if content := gjson.GetBytes(out, "messages.0.content").String(); len(content) != 1000 {
+		t.Fatalf("claude-only request cut to %d bytes, want the default limit to leave it alone", len(content))
+	}
+	out, _ = h.limitToolResults(ctx, "openai", []string{"claude", "gemini"}, toolResultRequest(1))
+	if content := gjson.GetBytes(out, "messages.0.content").String(); !strings.Contains(content, "bytes truncated") {
+		t.Fatalf("request servable by gemini not cut to its limit: %q", content)
+	}
+}
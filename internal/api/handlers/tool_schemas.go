@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// SchemaTransformsHeader lists, per function, how the parameter schemas of an OpenAI
+// request were changed for a Gemini backend.
+const SchemaTransformsHeader = "X-CLIProxy-Schema-Transforms"
+
+// geminiSchemaProviders are the providers whose function declarations take Gemini schemas.
+var geminiSchemaProviders = map[string]bool{constant.Gemini: true, constant.GeminiCLI: true}
+
+// openAIFunction is a function tool of an OpenAI request with the location of its schema.
+type openAIFunction struct {
+	name   string
+	path   string
+	params gjson.Result
+	strict bool
+}
+
+// openAIFunctions lists the function tools of a Chat Completions or Responses request.
+func openAIFunctions(handlerType string, rawJSON []byte) []openAIFunction {
+	var functions []openAIFunction
+	for i, tool := range gjson.GetBytes(rawJSON, "tools").Array() {
+		if tool.Get("type").String() != "function" {
+			continue
+		}
+		fn, prefix := tool, fmt.Sprintf("tools[%d]", i)
+		if handlerType == constant.OpenAI {
+			fn, prefix = tool.Get("function"), prefix+".function"
+		}
+		if params := fn.Get("parameters"); params.IsObject() {
+			functions = append(functions, openAIFunction{
+				name:   fn.Get("name").String(),
+				path:   prefix + ".parameters",
+				params: params,
+				strict: fn.Get("strict").Bool(),
+			})
+		}
+	}
+	return functions
+}
+
+// schemaCompatibleProviders leaves the Gemini providers out of providers when a function
+// the client marked strict has a schema Gemini cannot represent, since Gemini would not
+// hold the model to it. When no other provider serves modelName the request is rejected
+// with 400 naming the offending part of the schema.
+func schemaCompatibleProviders(handlerType, modelName string, rawJSON []byte, providers []string) ([]string, *interfaces.ErrorMessage) {
+	if handlerType != constant.OpenAI && handlerType != constant.OpenaiResponse {
+		return providers, nil
+	}
+	served := false
+	for _, provider := range providers {
+		served = served || geminiSchemaProviders[provider]
+	}
+	if !served {
+		return providers, nil
+	}
+	var schemaErr error
+	for _, fn := range openAIFunctions(handlerType, rawJSON) {
+		if !fn.strict {
+			continue
+		}
+		if _, _, err := util.GeminiFunctionSchema(fn.params, fn.path, true); err != nil {
+			schemaErr = err
+			break
+		}
+	}
+	if schemaErr == nil {
+		return providers, nil
+	}
+	capable := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if !geminiSchemaProviders[provider] {
+			capable = append(capable, provider)
+		}
+	}
+	if len(capable) == 0 {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("strict function schema not supported by model %s: %w", modelName, schemaErr),
+			Kind:       coreexecutor.ErrorKindInvalid,
+		}
+	}
+	return capable, nil
+}
+
+// reportSchemaTransforms adds a SchemaTransformsHeader value per function whose schema was
+// changed for the Gemini provider that served the request. It must run before the response
+// is written.
+func reportSchemaTransforms(ctx context.Context, handlerType string, rawJSON []byte) {
+	if handlerType != constant.OpenAI && handlerType != constant.OpenaiResponse {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	if provider, _ := logging.RequestTarget(ginCtx); !geminiSchemaProviders[provider] {
+		return
+	}
+	for _, fn := range openAIFunctions(handlerType, rawJSON) {
+		if _, notes, _ := util.GeminiFunctionSchema(fn.params, fn.path, false); len(notes) > 0 {
+			ginCtx.Writer.Header().Add(SchemaTransformsHeader, fn.name+": "+strings.Join(notes, "; "))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+const unionToolRequest = `{"tools":[{"type":"function","function":{"name":"lookup","strict":true,"parameters":{"type":"object","properties":{"v":{"oneOf":[{"type":"string"},{"type":"integer"}]}},"required":["v"],"additionalProperties":false}}}]}`
+
+func TestStrictUnrepresentableSchemaLeavesGeminiOut(t *testing.T) {
+	providers, errMsg := schemaCompatibleProviders("openai", "gemini-2.5-pro", []byte(unionToolRequest), []string{"gemini", "claude"})
+	if errMsg != nil || !reflect.DeepEqual(providers, []string{"claude"}) {
+		t.Fatalf("providers = %v, error %+v, want only claude", providers, errMsg)
+	}
+
+	_, errMsg = schemaCompatibleProviders("openai", "gemini-2.5-pro", []byte(unionToolRequest), []string{"gemini"})
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("error = %+v, want 400", errMsg)
+	}
+	if !strings.Contains(errMsg.Error.Error(), "tools[0].function.parameters.properties.v.oneOf") {
+		t.Fatalf("error %q does not name the offending path", errMsg.Error)
+	}
+
+	loose := strings.Replace(unionToolRequest, `"strict":true`, `"strict":false`, 1)
+	if providers, errMsg = schemaCompatibleProviders("openai", "gemini-2.5-pro", []byte(loose), []string{"gemini"}); errMsg != nil || len(providers) != 1 {
+		t.Fatalf("non-strict request: providers = %v, error %+v, want gemini kept", providers, errMsg)
+	}
+}
+
+func TestSchemaTransformsAreReportedForGemini(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"tools":[{"type":"function","name":"refund","parameters":{"type":"object","properties":{"kind":{"const":"refund"},"amount":{"type":"number","exclusiveMinimum":0}}}},{"type":"function","name":"plain","parameters":{"type":"object","properties":{"q":{"type":"string"}}}}]}`)
+	for _, provider := range []string{"gemini", "claude"} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx := context.WithValue(context.Background(), "gin", c)
+		logging.RecordRequestTarget(ctx, provider, "model")
+		reportSchemaTransforms(ctx, "openai-response", body)
+
+		values := c.Writer.Header().Values(SchemaTransformsHeader)
+		if provider == "claude" {
+			if len(values) != 0 {
+				t.Fatalf("transforms reported for claude: %q", values)
+			}
+			continue
+		}
+		if len(values) != 1 || !strings.HasPrefix(values[0], "refund: ") || !strings.Contains(values[0], "const") || !strings.Contains(values[0], "exclusiveMinimum") {
+			t.Fatalf("%s = %q, want the const and exclusiveMinimum changes of refund only", SchemaTransformsHeader, values)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+)
+
+// Endpoints whose request bodies can be validated with ValidateRequest.
+const (
+	EndpointChatCompletions = "chat.completions"
+	EndpointCompletions     = "completions"
+	EndpointResponses       = "responses"
+	EndpointMessages        = "messages"
+	EndpointCountTokens     = "messages.count_tokens"
+	EndpointGenerateContent = "generateContent"
+	EndpointGeminiCount     = "countTokens"
+	EndpointEmbedContent    = "embedContent"
+	EndpointCLIGenerate     = "v1internal.generateContent"
+)
+
+// JSON kinds accepted by a fieldRule.
+const (
+	kindString  = "string"
+	kindNumber  = "number"
+	kindBoolean = "boolean"
+	kindArray   = "array"
+	kindObject  = "object"
+)
+
+// fieldRule describes one required top-level field of an endpoint's request body.
+type fieldRule struct {
+	field string
+	kinds []string
+}
+
+// FieldError reports why one field of a request body was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// requestRules lists the required fields per endpoint. Only their presence and type are
+// checked; optional fields are never inspected, so any body a backend could make sense of
+// still passes.
+var requestRules = map[string][]fieldRule{
+	EndpointChatCompletions: {
+		{field: "model", kinds: []string{kindString}},
+		{field: "messages", kinds: []string{kindArray}},
+	},
+	EndpointCompletions: {
+		{field: "model", kinds: []string{kindString}},
+		{field: "prompt", kinds: []string{kindString, kindArray}},
+	},
+	EndpointResponses: {
+		{field: "model", kinds: []string{kindString}},
+		{field: "input", kinds: []string{kindString, kindArray}},
+	},
+	EndpointMessages: {
+		{field: "model", kinds: []string{kindString}},
+		{field: "messages", kinds: []string{kindArray}},
+	},
+	EndpointCountTokens: {
+		{field: "model", kinds: []string{kindString}},
+		{field: "messages", kinds: []string{kindArray}},
+	},
+	EndpointGenerateContent: {
+		{field: "contents", kinds: []string{kindArray}},
+	},
+	EndpointEmbedContent: {
+		{field: "content", kinds: []string{kindObject}},
+	},
+	EndpointCLIGenerate: {
+		{field: "request", kinds: []string{kindObject}},
+	},
+}
+
+// ValidateRequestBody checks rawJSON against the rules of endpoint and returns one entry per
+// rejected field. Unknown endpoints and fields without a rule are not checked.
+func ValidateRequestBody(endpoint string, rawJSON []byte) []FieldError {
+	if !gjson.ValidBytes(rawJSON) || !gjson.ParseBytes(rawJSON).IsObject() {
+		return []FieldError{{Field: "body", Message: "request body must be a JSON object"}}
+	}
+	var errs []FieldError
+	for _, rule := range requestRules[endpoint] {
+		value := gjson.GetBytes(rawJSON, rule.field)
+		if !value.Exists() || value.Type == gjson.Null {
+			errs = append(errs, FieldError{Field: rule.field, Message: "required field is missing"})
+			continue
+		}
+		kind := jsonKind(value)
+		if !containsKind(rule.kinds, kind) {
+			errs = append(errs, FieldError{
+				Field:   rule.field,
+				Message: fmt.Sprintf("expected %s, got %s", strings.Join(rule.kinds, " or "), kind),
+			})
+		}
+	}
+	return errs
+}
+
+// messageFields names the message list of each endpoint limited by max-messages.
+var messageFields = map[string]string{
+	EndpointChatCompletions: "messages",
+	EndpointResponses:       "input",
+	EndpointMessages:        "messages",
+	EndpointCountTokens:     "messages",
+	EndpointGenerateContent: "contents",
+	EndpointGeminiCount:     "contents",
+	EndpointCLIGenerate:     "request.contents",
+}
+
+// outputLimitFields names the output token limits of each endpoint.
+var outputLimitFields = map[string][]string{
+	EndpointChatCompletions: {"max_tokens", "max_completion_tokens"},
+	EndpointCompletions:     {"max_tokens"},
+	EndpointResponses:       {"max_output_tokens"},
+	EndpointMessages:        {"max_tokens"},
+	EndpointGenerateContent: {"generationConfig.maxOutputTokens"},
+	EndpointCLIGenerate:     {"request.generationConfig.maxOutputTokens"},
+}
+
+// choiceCountEndpoints are the endpoints taking the number of choices to generate in n.
+var choiceCountEndpoints = map[string]bool{EndpointChatCompletions: true, EndpointCompletions: true}
+
+// ParameterErrors rejects parameter values the backends disagree on, so every provider
+// answers them with the same 400: an output token limit below 1, which some would treat
+// as no limit and others reject, a choice count n below 1, and n above 1 on a stream when
+// one of providers, those serving the requested model, cannot fill more than one choice,
+// as the translated backends cannot. Values that are not numbers are left to the backends.
+func ParameterErrors(endpoint string, rawJSON []byte, providers []string) []FieldError {
+	var errs []FieldError
+	for _, field := range outputLimitFields[endpoint] {
+		if msg := positiveIntegerError(gjson.GetBytes(rawJSON, field)); msg != "" {
+			errs = append(errs, FieldError{Field: field, Message: msg})
+		}
+	}
+	if !choiceCountEndpoints[endpoint] {
+		return errs
+	}
+	n := gjson.GetBytes(rawJSON, "n")
+	if msg := positiveIntegerError(n); msg != "" {
+		errs = append(errs, FieldError{Field: "n", Message: msg})
+	} else if n.Exists() && n.Int() > 1 && gjson.GetBytes(rawJSON, "stream").Bool() && !streamsChoices(providers) {
+		errs = append(errs, FieldError{Field: "n", Message: fmt.Sprintf("streamed responses carry a single choice, got n=%d with stream: true", n.Int())})
+	}
+	return errs
+}
+
+// streamsChoices reports whether every provider can stream several choices. Providers that
+// declare no features are given the benefit of the doubt.
+func streamsChoices(providers []string) bool {
+	for _, provider := range providers {
+		if features, ok := registry.GetGlobalRegistry().GetProviderFeatures(provider); ok && !features.StreamChoices {
+			return false
+		}
+	}
+	return true
+}
+
+// positiveIntegerError describes why a numeric value is not a positive integer, or returns
+// "" when it is one or is not a number at all.
+func positiveIntegerError(value gjson.Result) string {
+	if value.Type != gjson.Number {
+		return ""
+	}
+	if value.Num != float64(int64(value.Num)) {
+		return "must be an integer, got " + value.Raw
+	}
+	if value.Num < 1 {
+		return "must be at least 1, got " + value.Raw
+	}
+	return ""
+}
+
+// RequestErrors returns the rejected fields of rawJSON for endpoint: when request validation
+// is enabled, the validation errors and the parameter values rejected by ParameterErrors for
+// the providers of the requested model, and the message list when it exceeds max-messages.
+func (h *BaseAPIHandler) RequestErrors(endpoint string, rawJSON []byte) []FieldError {
+	var errs []FieldError
+	if h.ValidatesRequests() {
+		errs = ValidateRequestBody(endpoint, rawJSON)
+		if gjson.ValidBytes(rawJSON) {
+			providers := util.GetProviderName(gjson.GetBytes(rawJSON, "model").String(), h.Cfg)
+			errs = append(errs, ParameterErrors(endpoint, rawJSON, providers)...)
+		}
+	}
+	if h.Cfg == nil || h.Cfg.MaxMessages <= 0 {
+		return errs
+	}
+	field, ok := messageFields[endpoint]
+	if !ok {
+		return errs
+	}
+	messages := gjson.GetBytes(rawJSON, field)
+	if !messages.IsArray() {
+		return errs
+	}
+	if count := len(messages.Array()); count > h.Cfg.MaxMessages {
+		errs = append(errs, FieldError{
+			Field:   field,
+			Message: fmt.Sprintf("%d messages exceed the limit of %d", count, h.Cfg.MaxMessages),
+		})
+	}
+	return errs
+}
+
+// ValidateRequest checks rawJSON for endpoint with RequestErrors and writes a 400 listing
+// every rejected field. It reports whether the request may proceed.
+func (h *BaseAPIHandler) ValidateRequest(c *gin.Context, endpoint string, rawJSON []byte) bool {
+	errs := h.RequestErrors(endpoint, rawJSON)
+	if len(errs) == 0 {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+		Error: ValidationErrorDetail{
+			ErrorDetail: ErrorDetail{
+				Message: "Invalid request: " + FormatFieldErrors(errs),
+				Type:    "invalid_request_error",
+			},
+			Param:  errs[0].Field,
+			Fields: errs,
+		},
+	})
+	return false
+}
+
+// ValidatesRequests reports whether inbound request bodies are validated.
+func (h *BaseAPIHandler) ValidatesRequests() bool {
+	return h.Cfg != nil && h.Cfg.RequestValidation
+}
+
+// ValidationErrorResponse is the 400 body written for requests that fail validation.
+type ValidationErrorResponse struct {
+	Error ValidationErrorDetail `json:"error"`
+}
+
+// ValidationErrorDetail extends ErrorDetail with the rejected fields.
+type ValidationErrorDetail struct {
+	ErrorDetail
+	// Param names the first rejected field.
+	Param string `json:"param,omitempty"`
+	// Fields lists every rejected field with its reason.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FormatFieldErrors joins errs into a single human-readable message.
+func FormatFieldErrors(errs []FieldError) string {
+	parts := make([]string, 0, len(errs))
+	for _, e := range errs {
+		parts = append(parts, e.Field+": "+e.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func jsonKind(value gjson.Result) string {
+	switch value.Type {
+	case gjson.String:
+		return kindString
+	case gjson.Number:
+		return kindNumber
+	case gjson.True, gjson.False:
+		return kindBoolean
+	case gjson.Null:
+		return "null"
+	}
+	if value.IsArray() {
+		return kindArray
+	}
+	return kindObject
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+func TestRequestErrorsParameters(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	reg.SetProviderFeatures("validation-translated", registry.ProviderFeatures{Streaming: true})
+	reg.SetProviderFeatures("validation-compat", registry.ProviderFeatures{Streaming: true, StreamChoices: true})
+	reg.RegisterClient("validation-translated-client", "validation-translated", []*registry.ModelInfo{{ID: "validation-translated-model"}})
+	reg.RegisterClient("validation-compat-client", "validation-compat", []*registry.ModelInfo{{ID: "validation-compat-model"}})
+	t.Cleanup(func() {
+		reg.UnregisterClient("validation-translated-client")
+		reg.UnregisterClient("validation-compat-client")
+	})
+
+	tests := []struct {
+		name     string
+		endpoint string
+		body     string
+		field    string
+	}{
+		{name: "max_tokens zero", endpoint: EndpointChatCompletions, body: `{"model":"validation-translated-model","messages":[],"max_tokens":0}`, field: "max_tokens"},
+		{name: "max_tokens negative", endpoint: EndpointMessages, body: `{"model":"validation-translated-model","messages":[],"max_tokens":-5}`, field: "max_tokens"},
+		{name: "max_output_tokens fractional", endpoint: EndpointResponses, body: `{"model":"validation-translated-model","input":"hi","max_output_tokens":1.5}`, field: "max_output_tokens"},
+		{name: "gemini maxOutputTokens zero", endpoint: EndpointGenerateContent, body: `{"contents":[],"generationConfig":{"maxOutputTokens":0}}`, field: "generationConfig.maxOutputTokens"},
+		{name: "n zero", endpoint: EndpointChatCompletions, body: `{"model":"validation-translated-model","messages":[],"n":0}`, field: "n"},
+		{name: "stream n>1 translated", endpoint: EndpointChatCompletions, body: `{"model":"validation-translated-model","messages":[],"n":2,"stream":true}`, field: "n"},
+		{name: "stream n>1 compat", endpoint: EndpointChatCompletions, body: `{"model":"validation-compat-model","messages":[],"n":2,"stream":true}`},
+		{name: "n>1 without stream", endpoint: EndpointChatCompletions, body: `{"model":"validation-translated-model","messages":[],"n":2}`},
+		{name: "valid limit", endpoint: EndpointChatCompletions, body: `{"model":"validation-translated-model","messages":[],"max_tokens":16}`},
+	}
+	h := &BaseAPIHandler{Cfg: &config.Config{RequestValidation: true}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := h.RequestErrors(tt.endpoint, []byte(tt.body))
+			if tt.field == "" {
+				if len(errs) != 0 {
+					t.Fatalf("errors = %+v, want none", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || errs[0].Field != tt.field {
+				t.Fatalf("errors = %+v, want one for %s", errs, tt.field)
+			}
+		})
+	}
+}
+
+func TestRequestErrorsSkipParametersWithoutValidation(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.Config{}}
+	if errs := h.RequestErrors(EndpointChatCompletions, []byte(`{"messages":[],"max_tokens":0,"n":2,"stream":true}`)); len(errs) != 0 {
+		t.Fatalf("errors = %+v, want none with request-validation off", errs)
+	}
+}
+
+func TestValidateRequestBodyChecksRequiredFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		body     string
+		want     []FieldError
+	}{
+		{name: "missing model", endpoint: EndpointChatCompletions, body: `{"messages":[]}`,
+			want: []FieldError{{Field: "model", Message: "required field is missing"}}},
+		{name: "messages not a list", endpoint: EndpointMessages, body: `{"model":"m","messages":"hi"}`,
+			want: []FieldError{{Field: "messages", Message: "expected array, got string"}}},
+		{name: "null contents", endpoint: EndpointGenerateContent, body: `{"contents":null}`,
+			want: []FieldError{{Field: "contents", Message: "required field is missing"}}},
+		{name: "not an object", endpoint: EndpointResponses, body: `["model"]`,
+			want: []FieldError{{Field: "body", Message: "request body must be a JSON object"}}},
+		{name: "input as string", endpoint: EndpointResponses, body: `{"model":"m","input":"hi"}`},
+		{name: "unknown endpoint", endpoint: "unknown", body: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateRequestBody(tt.endpoint, []byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("errors = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRequestBodyLeavesOptionalFieldsAlone(t *testing.T) {
+	for endpoint, body := range map[string]string{
+		EndpointChatCompletions: `{"model":"m","messages":[],"stream":"true","tools":{},"max_tokens":"16","temperature":null}`,
+		EndpointMessages:        `{"model":"m","messages":[],"system":{"text":"x"},"stream":1}`,
+		EndpointGenerateContent: `{"contents":[],"systemInstruction":"be brief","tools":{}}`,
+		EndpointCLIGenerate:     `{"request":{},"model":1}`,
+	} {
+		if errs := ValidateRequestBody(endpoint, []byte(body)); len(errs) != 0 {
+			t.Errorf("%s: errors = %+v, want optional fields unchecked", endpoint, errs)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware capturing exchanges for replay testing.
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// CaptureMiddleware writes every successful API request, with the upstream exchange it
+// caused and the response the translators made of it, as a file into the capture directory.
+// Handler additions such as footers and tags are left out, as replays do not apply them. settings is
+// called once per request and returns whether capturing is on and the directory to write to.
+//
+// Requests that reached no translator are skipped: raw passthrough, raw (alt) streams and
+// Gemini Web, whose answers are converted before translation.
+func CaptureMiddleware(settings func() (enabled bool, dir string)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, dir := settings()
+		if !enabled {
+			c.Next()
+			return
+		}
+		started := time.Now()
+		c.Next()
+
+		if c.Writer.Status() != http.StatusOK || c.Writer.Header().Get("X-Passthrough") == "true" {
+			return
+		}
+		source, ok := logging.RecordedCaptureSource(c)
+		if !ok {
+			return
+		}
+		provider, model := logging.RequestTarget(c)
+		if provider == constant.GeminiWeb {
+			return
+		}
+		upstreamRequest, _ := c.Get("API_REQUEST")
+		upstreamResponse, _ := c.Get("API_RESPONSE")
+		request, _ := upstreamRequest.([]byte)
+		response, _ := upstreamResponse.([]byte)
+		if offset := c.GetInt("API_RESPONSE_OFFSET"); offset <= len(response) {
+			response = response[offset:]
+		}
+		translated := logging.RecordedCaptureResponse(c)
+		if len(response) == 0 || len(translated) == 0 {
+			return
+		}
+		exchange := logging.CapturedExchange{
+			Time:             started,
+			Path:             c.Request.URL.Path,
+			SourceFormat:     source.Format,
+			Provider:         provider,
+			Model:            model,
+			Stream:           source.Stream,
+			Request:          string(source.Request),
+			UpstreamRequest:  string(request),
+			UpstreamResponse: string(response),
+			Response:         string(translated),
+		}
+		if _, err := logging.WriteCapture(dir, exchange); err != nil {
+			log.Warnf("failed to capture request: %v", err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware limiting concurrent API requests and queueing the
+// overflow fairly between clients.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultQueueWait applies when request-queue sets no max-wait.
+const defaultQueueWait = 30 * time.Second
+
+// priorityHeader lets a client mark a request as low priority.
+const priorityHeader = "X-CLIProxy-Priority"
+
+var (
+	errQueueFull    = errors.New("request queue is full")
+	errQueueTimeout = errors.New("timed out waiting in the request queue")
+)
+
+// RequestQueue admits up to a configured number of requests at once. Requests beyond it wait
+// in a bounded queue served by start-time fair queuing: each request is tagged with the
+// virtual time at which its client's previous requests will have had their share, so a client
+// sending a burst waits behind the requests of quieter clients rather than ahead of them.
+// A client's weight scales its share. Low-priority requests are further held back from the
+// slots reserved for high-priority ones.
+type RequestQueue struct {
+	mu      sync.Mutex
+	active  int
+	waiters []*queueWaiter
+	// virtual is the start tag of the request admitted last.
+	virtual float64
+	// finish holds the finish tag of each client's latest request.
+	finish map[string]float64
+}
+
+type queueWaiter struct {
+	start float64
+	low   bool
+	ready chan struct{}
+}
+
+// queueLimits are the slots the queue serves: maxConcurrent in all, of which reserved are
+// kept for high-priority requests, and the places to wait for them: maxQueued in all, of which
+// reservedQueued are kept for high-priority requests in the same proportion.
+type queueLimits struct {
+	maxConcurrent  int
+	reserved       int
+	maxQueued      int
+	reservedQueued int
+}
+
+// admits reports whether a request of the given priority may take a slot while active are
+// taken.
+func (l queueLimits) admits(active int, low bool) bool {
+	if l.maxConcurrent <= 0 {
+		return true
+	}
+	if low {
+		return active < l.maxConcurrent-l.reserved
+	}
+	return active < l.maxConcurrent
+}
+
+// queues reports whether a request of the given priority may wait while waiting requests
+// already do.
+func (l queueLimits) queues(waiting int, low bool) bool {
+	if low {
+		return waiting < l.maxQueued-l.reservedQueued
+	}
+	return waiting < l.maxQueued
+}
+
+// NewRequestQueue returns an empty request queue.
+func NewRequestQueue() *RequestQueue {
+	return &RequestQueue{finish: make(map[string]float64)}
+}
+
+// acquire waits for a slot for a request of principal. It returns errQueueFull when the
+// limits leave the request no place in the queue, errQueueTimeout after maxWait, and the
+// context error when the client goes away first. On success the caller must call release.
+func (q *RequestQueue) acquire(ctx context.Context, principal string, weight float64, low bool, limits queueLimits, maxWait time.Duration) error {
+	q.mu.Lock()
+	previous, seen := q.finish[principal]
+	start := max(q.virtual, previous)
+	q.finish[principal] = start + 1/weight
+	if limits.admits(q.active, low) && !q.waiting(low) {
+		q.active++
+		q.virtual = start
+		q.mu.Unlock()
+		return nil
+	}
+	if !limits.queues(len(q.waiters), low) {
+		if seen {
+			q.finish[principal] = previous
+		} else {
+			delete(q.finish, principal)
+		}
+		q.mu.Unlock()
+		return errQueueFull
+	}
+	waiter := &queueWaiter{start: start, low: low, ready: make(chan struct{})}
+	q.waiters = append(q.waiters, waiter)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	q.mu.Lock()
+	for i, w := range q.waiters {
+		if w == waiter {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			q.mu.Unlock()
+			return err
+		}
+	}
+	q.mu.Unlock()
+	// The slot was granted while giving up; keep it unless the client is gone.
+	if ctx.Err() != nil {
+		q.release(limits)
+		return err
+	}
+	return nil
+}
+
+// waiting reports whether a request queued ahead would be passed by admitting a new one: any
+// waiting request for a low-priority one, a high-priority waiting request for a high-priority
+// one, since low-priority requests may be waiting only for the reserved slots.
+func (q *RequestQueue) waiting(low bool) bool {
+	for _, w := range q.waiters {
+		if low || !w.low {
+			return true
+		}
+	}
+	return false
+}
+
+// release frees the slot of a finished request and admits waiting requests, lowest start tag
+// first among those the limits admit. A maxConcurrent of zero, after the limit was turned
+// off, admits every waiting request.
+func (q *RequestQueue) release(limits queueLimits) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active > 0 {
+		q.active--
+	}
+	for {
+		next := -1
+		for i, w := range q.waiters {
+			if limits.admits(q.active, w.low) && (next < 0 || w.start < q.waiters[next].start) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		waiter := q.waiters[next]
+		q.waiters = append(q.waiters[:next], q.waiters[next+1:]...)
+		q.active++
+		q.virtual = max(q.virtual, waiter.start)
+		close(waiter.ready)
+	}
+	if len(q.waiters) == 0 {
+		// Clients whose share is used up have nothing to catch up on; forget them.
+		for principal, finish := range q.finish {
+			if finish <= q.virtual {
+				delete(q.finish, principal)
+			}
+		}
+	}
+}
+
+// RequestQueueMiddleware limits the API requests served at once to request-queue's
+// max-concurrent, queueing the rest fairly between clients. Requests that find the queue full
+// or wait longer than max-wait get a 503. settings is called once per request so
+// configuration reloads apply to the next request; a max-concurrent of zero disables the
+// limit. Clients are told apart by their API key, or by address when access is open.
+// Requests of low-priority-keys, or sent with "X-CLIProxy-Priority: low", leave the
+// reserved-fraction of the slots, and of the queue, to the others.
+func RequestQueueMiddleware(queue *RequestQueue, settings func() config.RequestQueueConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := settings()
+		if cfg.MaxConcurrent <= 0 {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if principal == "" {
+			principal = c.ClientIP()
+		}
+		weight := 1.0
+		if w, ok := cfg.Weights[principal]; ok && w > 0 {
+			weight = float64(w)
+		}
+		maxWait := time.Duration(cfg.MaxWait) * time.Second
+		if maxWait <= 0 {
+			maxWait = defaultQueueWait
+		}
+
+		low := slices.Contains(cfg.LowPriorityKeys, c.GetString("apiKey")) ||
+			strings.EqualFold(strings.TrimSpace(c.GetHeader(priorityHeader)), "low")
+
+		queued := time.Now()
+		if err := queue.acquire(c.Request.Context(), principal, weight, low, limitsOf(cfg), maxWait); err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				c.Abort()
+				return
+			}
+			log.WithField("path", c.Request.URL.Path).Warnf("request rejected: %v", err)
+			c.Header("Retry-After", strconv.Itoa(int(maxWait/time.Second)))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": gin.H{
+					"message": "server is at capacity: " + err.Error(),
+					"type":    "overloaded_error",
+				},
+			})
+			return
+		}
+		defer func() {
+			// Read again so a lowered limit takes effect as requests finish.
+			queue.release(limitsOf(settings()))
+		}()
+		if waited := time.Since(queued); waited >= time.Millisecond {
+			log.Debugf("request queued for %s before being served (low priority: %t)", waited.Truncate(time.Millisecond), low)
+		}
+		c.Next()
+	}
+}
+
+// limitsOf returns the slots and queue places of cfg, rounding the reserved fraction of each
+// to whole ones and leaving at least one to low-priority requests.
+func limitsOf(cfg config.RequestQueueConfig) queueLimits {
+	return queueLimits{
+		maxConcurrent:  cfg.MaxConcurrent,
+		reserved:       reservedOf(cfg.ReservedFraction, cfg.MaxConcurrent),
+		maxQueued:      cfg.MaxQueued,
+		reservedQueued: reservedOf(cfg.ReservedFraction, cfg.MaxQueued),
+	}
+}
+
+// reservedOf returns fraction of total, rounded, leaving at least one of total unreserved.
+func reservedOf(fraction float64, total int) int {
+	if total <= 0 || fraction <= 0 {
+		return 0
+	}
+	return min(int(math.Round(fraction*float64(total))), total-1)
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// queued waits until n requests are waiting in q.
+func queued(t *testing.T, q *RequestQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		waiting := len(q.waiters)
+		q.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d requests never queued", n)
+}
+
+// acquireAsync starts acquire in the background and returns its result channel.
+func acquireAsync(q *RequestQueue, principal string, low bool, limits queueLimits) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- q.acquire(context.Background(), principal, 1, low, limits, 5*time.Second)
+	}()
+	return done
+}
+
+func TestRequestQueueServesQuietClientsFirst(t *testing.T) {
+	q := NewRequestQueue()
+	limits := queueLimits{maxConcurrent: 1, maxQueued: 10}
+	if err := q.acquire(context.Background(), "busy", 1, false, limits, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	burst := []<-chan error{acquireAsync(q, "busy", false, limits)}
+	queued(t, q, 1)
+	burst = append(burst, acquireAsync(q, "busy", false, limits))
+	queued(t, q, 2)
+	quiet := acquireAsync(q, "quiet", false, limits)
+	queued(t, q, 3)
+
+	q.release(limits)
+	select {
+	case err := <-quiet:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-burst[0]:
+		t.Fatal("busy client's queued request was served before the quiet client")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no request admitted")
+	}
+	q.release(limits)
+	<-burst[0]
+	q.release(limits)
+	<-burst[1]
+}
+
+func TestRequestQueueReservesSlotsForHighPriority(t *testing.T) {
+	q := NewRequestQueue()
+	limits := limitsOf(config.RequestQueueConfig{MaxConcurrent: 4, MaxQueued: 4, ReservedFraction: 0.5})
+	for i := 0; i < 2; i++ {
+		if err := q.acquire(context.Background(), "batch", 1, true, limits, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	low := acquireAsync(q, "batch", true, limits)
+	queued(t, q, 1)
+	if err := q.acquire(context.Background(), "user", 1, false, limits, time.Second); err != nil {
+		t.Fatalf("high priority request not admitted to a reserved slot: %v", err)
+	}
+	select {
+	case <-low:
+		t.Fatal("low priority request took a reserved slot")
+	default:
+	}
+	q.release(limits)
+	q.release(limits)
+	if err := <-low; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestQueueReservesQueuePlacesForHighPriority(t *testing.T) {
+	q := NewRequestQueue()
+	limits := limitsOf(config.RequestQueueConfig{MaxConcurrent: 2, MaxQueued: 4, ReservedFraction: 0.5})
+	if limits.reservedQueued != 2 {
+		t.Fatalf("reservedQueued = %d, want 2", limits.reservedQueued)
+	}
+	for i := 0; i < 2; i++ {
+		if err := q.acquire(context.Background(), "batch", 1, false, limits, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var waiting []<-chan error
+	for i := 0; i < 2; i++ {
+		waiting = append(waiting, acquireAsync(q, "batch", true, limits))
+		queued(t, q, i+1)
+	}
+	if err := q.acquire(context.Background(), "batch", 1, true, limits, time.Second); !errors.Is(err, errQueueFull) {
+		t.Fatalf("third low priority waiter: err = %v, want errQueueFull", err)
+	}
+	high := acquireAsync(q, "user", false, limits)
+	queued(t, q, 3)
+
+	// The high-priority request is served first even though it queued last.
+	q.release(limits)
+	if err := <-high; err != nil {
+		t.Fatal(err)
+	}
+	// Both batch requests, then the high-priority one, finish; the low-priority waiters never
+	// take the reserved slot, so each is admitted only once the other slots are free.
+	for i := 0; i < 3; i++ {
+		q.release(limits)
+	}
+	for _, done := range waiting {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRequestQueueTimesOut(t *testing.T) {
+	q := NewRequestQueue()
+	limits := queueLimits{maxConcurrent: 1, maxQueued: 1}
+	if err := q.acquire(context.Background(), "a", 1, false, limits, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.acquire(context.Background(), "b", 1, false, limits, 10*time.Millisecond); !errors.Is(err, errQueueTimeout) {
+		t.Fatalf("err = %v, want errQueueTimeout", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) != 0 {
+		t.Fatalf("timed out request still queued")
+	}
+}
+
+func TestRequestQueueMiddlewareRejectsWithRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	q := NewRequestQueue()
+	settings := func() config.RequestQueueConfig {
+		return config.RequestQueueConfig{MaxConcurrent: 1, MaxWait: 7}
+	}
+	release := make(chan struct{})
+	engine := gin.New()
+	engine.Use(RequestQueueMiddleware(q, settings))
+	engine.GET("/", func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	first := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		first <- rec.Code
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		q.mu.Lock()
+		active := q.active
+		q.mu.Unlock()
+		if active == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first request never admitted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "7" {
+		t.Fatalf("status = %d, Retry-After = %q; want 503 and 7", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Fatalf("first request status = %d", code)
+	}
+}
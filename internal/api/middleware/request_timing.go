@@ -0,0 +1,84 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware enforcing the hard request timeout and reporting
+// slow requests.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestTimingMiddleware cancels requests running longer than the hard timeout and logs a
+// warning for every request that took at least the slow threshold. settings is called once
+// per request so configuration reloads apply to the next request; a zero duration disables
+// the corresponding check.
+//
+// Every request gets an X-Request-ID: the client's own when it sent one, a generated one
+// otherwise. It is echoed in the response and included in the warnings.
+func RequestTimingMiddleware(settings func() (slowThreshold, timeout time.Duration)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slowThreshold, timeout := settings()
+		requestID := ensureRequestID(c)
+
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+
+		start := time.Now()
+		c.Next()
+		elapsed := time.Since(start)
+
+		provider, model := logging.RequestTarget(c)
+		fields := log.Fields{
+			"request_id": requestID,
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"model":      model,
+			"provider":   provider,
+			"elapsed":    elapsed.Truncate(time.Millisecond).String(),
+		}
+		if timeout > 0 && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+			log.WithFields(fields).Warnf("request cancelled after hard timeout of %s", timeout)
+			if !c.Writer.Written() {
+				c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+					"error": gin.H{
+						"message": "request exceeded the configured request-timeout",
+						"type":    "timeout_error",
+					},
+				})
+			}
+			return
+		}
+		if slowThreshold > 0 && elapsed >= slowThreshold {
+			log.WithFields(fields).Warnf("slow request: took longer than %s", slowThreshold)
+		}
+	}
+}
+
+// ensureRequestID returns the X-Request-ID of the request, generating one when the client
+// sent none, and echoes it in the response headers.
+func ensureRequestID(c *gin.Context) string {
+	requestID := strings.TrimSpace(c.GetHeader("X-Request-ID"))
+	if requestID == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err == nil {
+			requestID = hex.EncodeToString(buf)
+			c.Request.Header.Set("X-Request-ID", requestID)
+		}
+	}
+	if requestID != "" {
+		c.Header("X-Request-ID", requestID)
+	}
+	return requestID
+}
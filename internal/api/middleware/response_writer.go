@@ -5,6 +5,7 @@ package middleware
 
 import (
 	"bytes"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -209,6 +210,9 @@ func (w *ResponseWriterWrapper) Finalize(c *gin.Context) error {
 		}
 
 		if w.streamWriter != nil {
+			if section := logging.ToolResultSection(c) + logging.RetrievalSection(c) + logging.OutputCapSection(c) + logging.UpstreamHeaderSection(c) + logging.ModerationSection(c) + logging.SafetySection(c) + logging.AttemptsSection(c) + logging.StreamErrorSection(c) + logging.AccountSection(c) + logging.ModelPolicySection(c) + logging.MirrorSection(c); section != "" {
+				w.streamWriter.WriteChunkAsync([]byte("\n" + section))
+			}
 			err := w.streamWriter.Close()
 			w.streamWriter = nil
 			return err
@@ -257,6 +261,11 @@ func (w *ResponseWriterWrapper) Finalize(c *gin.Context) error {
 			}
 		}
 
+		// Tool result, retrieval and output cap notes follow the upstream response they describe.
+		if section := logging.ToolResultSection(c) + logging.RetrievalSection(c) + logging.OutputCapSection(c) + logging.UpstreamHeaderSection(c) + logging.ModerationSection(c) + logging.SafetySection(c) + logging.AttemptsSection(c) + logging.StreamErrorSection(c) + logging.AccountSection(c) + logging.ModelPolicySection(c) + logging.MirrorSection(c); section != "" {
+			apiResponseBody = append(append(bytes.Clone(apiResponseBody), "\n\n"...), section...)
+		}
+
 		var slicesAPIResponseError []*interfaces.ErrorMessage
 		apiResponseError, isExist := c.Get("API_RESPONSE_ERROR")
 		if isExist {
@@ -307,3 +316,8 @@ func (w *ResponseWriterWrapper) Size() int {
 func (w *ResponseWriterWrapper) Written() bool {
 	return w.statusCode != 0
 }
+
+// Unwrap returns the wrapped writer, so http.ResponseController reaches the connection.
+func (w *ResponseWriterWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
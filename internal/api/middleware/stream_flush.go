@@ -0,0 +1,154 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the middleware watching for responses the client side fails to flush.
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxHeldBytes bounds the response held back after a failed flush. Past it the held part
+// is written out and the rest of the response relayed as usual.
+const maxHeldBytes = 8 << 20
+
+// flushWatchWriter reports the first failed flush or write of a response and, when
+// buffering is allowed, holds the rest of the response back after a failed flush to
+// deliver it in one piece. A failed write means the connection is gone, so later writes
+// fail with the same error without reaching it.
+type flushWatchWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	buffer   bool
+	failed   bool
+	dead     error
+	held     bytes.Buffer
+	relayed  int
+	failedOp string
+}
+
+func (w *flushWatchWriter) Write(data []byte) (int, error) {
+	if w.dead != nil {
+		return 0, w.dead
+	}
+	if w.holding() {
+		if w.held.Len()+len(data) <= maxHeldBytes {
+			return w.held.Write(data)
+		}
+		log.Warnf("held response for %s %s exceeds %d bytes, relaying the rest unbuffered", w.c.Request.Method, w.c.Request.URL.Path, maxHeldBytes)
+		w.buffer = false
+		if err := w.writeHeld(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.relayed += n
+	if err != nil {
+		w.fail("write", err)
+		w.dead = err
+	}
+	return n, err
+}
+
+func (w *flushWatchWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush flushes the response, reporting a failure of the connection below, which
+// http.Flusher does not.
+func (w *flushWatchWriter) Flush() {
+	if w.holding() || w.dead != nil {
+		return
+	}
+	if err := w.flush(); err != nil {
+		w.fail("flush", err)
+	}
+}
+
+// flush commits the headers and flushes the connection through the wrapped writers. The
+// first writer able to report a flush error is flushed directly, since the wrappers above
+// it would swallow the error; without one the wrapped writer is flushed as usual.
+func (w *flushWatchWriter) flush() error {
+	w.ResponseWriter.WriteHeaderNow()
+	var rw http.ResponseWriter = w.ResponseWriter
+	for {
+		if flusher, ok := rw.(interface{ FlushError() error }); ok {
+			return flusher.FlushError()
+		}
+		unwrapper, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		rw = unwrapper.Unwrap()
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
+
+// holding reports whether writes are held back instead of relayed.
+func (w *flushWatchWriter) holding() bool {
+	return w.failed && w.buffer && w.dead == nil
+}
+
+// writeHeld writes the held part of the response to the connection and empties it.
+func (w *flushWatchWriter) writeHeld() error {
+	defer w.held.Reset()
+	n, err := w.ResponseWriter.Write(w.held.Bytes())
+	w.relayed += n
+	if err != nil {
+		w.dead = err
+	}
+	return err
+}
+
+func (w *flushWatchWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *flushWatchWriter) fail(op string, err error) {
+	if w.failed {
+		return
+	}
+	w.failed = true
+	w.failedOp = op
+	mode := "continuing unbuffered"
+	if op == "write" {
+		mode = "dropping the rest"
+	} else if w.buffer {
+		mode = "buffering the rest"
+	}
+	log.Warnf("downstream %s failed for %s %s after %d bytes, %s: %v (a proxy in front of the client may be buffering the response)", op, w.c.Request.Method, w.c.Request.URL.Path, w.relayed, mode, err)
+}
+
+// deliver writes the response held back after a failed flush as its final part.
+func (w *flushWatchWriter) deliver() {
+	if w.held.Len() == 0 || w.dead != nil {
+		return
+	}
+	held := w.held.Len()
+	if err := w.writeHeld(); err != nil {
+		log.Warnf("buffered remainder of %d bytes for %s %s not delivered: %v", held, w.c.Request.Method, w.c.Request.URL.Path, err)
+		return
+	}
+	if err := w.flush(); err != nil {
+		log.Warnf("buffered remainder of %d bytes for %s %s not delivered: %v", held, w.c.Request.Method, w.c.Request.URL.Path, err)
+		return
+	}
+	log.Infof("delivered buffered remainder of %d bytes for %s %s after the downstream %s failed", held, w.c.Request.Method, w.c.Request.URL.Path, w.failedOp)
+}
+
+// StreamFlushMiddleware detects responses the client side stops accepting mid-way, as
+// happens behind proxies that buffer event streams, and logs the first failed flush or
+// write. When buffer returns true the rest of the response is held back instead of being
+// pushed chunk by chunk, and written as one final piece once the handler is done.
+func StreamFlushMiddleware(buffer func() bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writer := &flushWatchWriter{ResponseWriter: c.Writer, c: c, buffer: buffer()}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		writer.deliver()
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errBroken = errors.New("broken pipe")
+
+// faultyWriter records what reaches the connection and fails flushes or writes on demand.
+type faultyWriter struct {
+	*httptest.ResponseRecorder
+	failFlush bool
+	failWrite bool
+	writes    int
+}
+
+func (w *faultyWriter) Write(data []byte) (int, error) {
+	w.writes++
+	if w.failWrite {
+		return 0, errBroken
+	}
+	return w.ResponseRecorder.Write(data)
+}
+
+func (w *faultyWriter) FlushError() error {
+	if w.failFlush {
+		return errBroken
+	}
+	w.ResponseRecorder.Flush()
+	return nil
+}
+
+func serveFlushTest(t *testing.T, conn *faultyWriter, handler gin.HandlerFunc) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(StreamFlushMiddleware(func() bool { return true }))
+	engine.GET("/stream", handler)
+	engine.ServeHTTP(conn, httptest.NewRequest(http.MethodGet, "/stream", nil))
+}
+
+func TestStreamFlushHoldsTheRestAfterAFailedFlush(t *testing.T) {
+	conn := &faultyWriter{ResponseRecorder: httptest.NewRecorder(), failFlush: true}
+	serveFlushTest(t, conn, func(c *gin.Context) {
+		_, _ = c.Writer.WriteString("a")
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString("b")
+		_, _ = c.Writer.WriteString("c")
+		if conn.Body.String() != "a" {
+			t.Errorf("relayed %q before the end, want the rest held back", conn.Body.String())
+		}
+	})
+	if got := conn.Body.String(); got != "abc" {
+		t.Fatalf("body = %q, want %q", got, "abc")
+	}
+}
+
+func TestStreamFlushCapsTheHeldResponse(t *testing.T) {
+	conn := &faultyWriter{ResponseRecorder: httptest.NewRecorder(), failFlush: true}
+	chunk := strings.Repeat("x", maxHeldBytes/2+1)
+	serveFlushTest(t, conn, func(c *gin.Context) {
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString(chunk)
+		_, _ = c.Writer.WriteString(chunk)
+		if conn.Body.Len() != 2*len(chunk) {
+			t.Errorf("relayed %d bytes past the cap, want %d", conn.Body.Len(), 2*len(chunk))
+		}
+		_, _ = c.Writer.WriteString("tail")
+	})
+	if got := conn.Body.Len(); got != 2*len(chunk)+len("tail") {
+		t.Fatalf("body has %d bytes, want %d", got, 2*len(chunk)+len("tail"))
+	}
+}
+
+func TestStreamFlushDropsTheRestOnADeadConnection(t *testing.T) {
+	conn := &faultyWriter{ResponseRecorder: httptest.NewRecorder(), failWrite: true}
+	serveFlushTest(t, conn, func(c *gin.Context) {
+		if _, err := c.Writer.WriteString("a"); err == nil {
+			t.Error("write to a dead connection reported no error")
+		}
+		if _, err := c.Writer.WriteString("b"); err == nil {
+			t.Error("second write to a dead connection reported no error")
+		}
+	})
+	// Only the first write reaches the connection; nothing is retried when the response
+	// completes.
+	if conn.writes != 1 {
+		t.Fatalf("connection saw %d writes, want 1", conn.writes)
+	}
+}
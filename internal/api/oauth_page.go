@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// defaultOAuthSuccessBody is shown by the OAuth callbacks when no page is configured.
+const defaultOAuthSuccessBody = "<h1>Authentication successful!</h1><p>You can close this window.</p>"
+
+// writeOAuthSuccess answers an OAuth callback with the configured success page.
+func (s *Server) writeOAuthSuccess(c *gin.Context) {
+	var page config.OAuthSuccessPageConfig
+	if s.cfg != nil {
+		page = s.cfg.OAuthSuccessPage
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, oauthSuccessPage(page))
+}
+
+// oauthSuccessPage renders the success page of page, redirecting to its URL or closing the
+// window after its delay when configured.
+func oauthSuccessPage(page config.OAuthSuccessPageConfig) string {
+	body := page.HTML
+	if strings.TrimSpace(body) == "" {
+		body = defaultOAuthSuccessBody
+	}
+	delay := page.Delay
+	if delay < 0 {
+		delay = 0
+	}
+
+	var head, script string
+	if target := strings.TrimSpace(page.RedirectURL); target != "" {
+		head = fmt.Sprintf(`<meta http-equiv="refresh" content="%d;url=%s">`, delay, html.EscapeString(target))
+	} else if page.AutoClose {
+		script = fmt.Sprintf("<script>setTimeout(function(){window.close();},%d);</script>", delay*1000)
+	}
+	return "<html><head>" + head + "</head><body>" + body + script + "</body></html>"
+}
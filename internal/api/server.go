@@ -13,9 +13,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/adminui"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/gemini"
@@ -24,6 +26,9 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelversion"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -46,7 +51,9 @@ type serverOptionConfig struct {
 type ServerOption func(*serverOptionConfig)
 
 func defaultRequestLoggerFactory(cfg *config.Config, configPath string) logging.RequestLogger {
-	return logging.NewFileRequestLogger(cfg.RequestLog, "logs", filepath.Dir(configPath))
+	requestLogger := logging.NewFileRequestLogger(cfg.RequestLog, "logs", filepath.Dir(configPath))
+	requestLogger.SetRotation(logging.Rotation(cfg.RequestLogRotation))
+	return requestLogger
 }
 
 // WithMiddleware appends additional Gin middleware during server construction.
@@ -111,6 +118,10 @@ type Server struct {
 	// cfg holds the current server configuration.
 	cfg *config.Config
 
+	// liveCfg is cfg for the middleware, which reads it on every request while UpdateClients
+	// replaces it.
+	liveCfg atomic.Pointer[config.Config]
+
 	// accessManager handles request authentication providers.
 	accessManager *sdkaccess.Manager
 
@@ -126,6 +137,9 @@ type Server struct {
 
 	localPassword string
 
+	// requestQueue limits the API requests served at once.
+	requestQueue *middleware.RequestQueue
+
 	keepAliveEnabled   bool
 	keepAliveTimeout   time.Duration
 	keepAliveOnTimeout func()
@@ -157,6 +171,10 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 
 	// Create gin engine
 	engine := gin.New()
+	// Known paths requested with another method get a 405 listing the allowed methods in
+	// Allow, rather than a 404 suggesting the route does not exist.
+	engine.HandleMethodNotAllowed = true
+	engine.NoMethod(handlers.MethodNotAllowed)
 	if optionState.engineConfigurator != nil {
 		optionState.engineConfigurator(engine)
 	}
@@ -193,10 +211,23 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		requestLogger:  requestLogger,
 		loggerToggle:   toggle,
 		configFilePath: configFilePath,
+		requestQueue:   middleware.NewRequestQueue(),
 	}
+	s.liveCfg.Store(cfg)
+	engine.Use(middleware.RequestTimingMiddleware(s.requestTimings))
 	s.applyAccessConfig(cfg)
+	registry.GetGlobalRegistry().SetCapabilityOverrides(cfg.ModelCapabilities)
+	util.ConfigureUpstreamTransports(cfg)
+	usage.SetModelPrices(cfg.Pricing.Models)
+	modelversion.Default().Configure(cfg.ModelVersions.Enable, cfg.AuthDir, cfg.ModelVersions.WebhookURL)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
+	s.mgmt.SetEmbedder(s.handlers.EmbedTexts)
+	if dirLogger, ok := requestLogger.(interface{ Dir() string }); ok {
+		s.mgmt.SetRequestLogDir(dirLogger.Dir())
+	}
+	s.handlers.SetRequestLogger(requestLogger)
+	geminiwebapi.SetTitleGenerator(s.handlers.GenerateConversationTitle)
 	if optionState.localPassword != "" {
 		s.mgmt.SetLocalPassword(optionState.localPassword)
 	}
@@ -221,6 +252,60 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	return s
 }
 
+// requestTimings returns the slow request threshold and hard request timeout of the current
+// configuration.
+func (s *Server) requestTimings() (time.Duration, time.Duration) {
+	cfg := s.liveCfg.Load()
+	if cfg == nil {
+		return 0, 0
+	}
+	return time.Duration(cfg.SlowRequestThreshold) * time.Second, time.Duration(cfg.RequestTimeout) * time.Second
+}
+
+// requestQueueSettings returns the request queue options of the current configuration.
+func (s *Server) requestQueueSettings() config.RequestQueueConfig {
+	cfg := s.liveCfg.Load()
+	if cfg == nil {
+		return config.RequestQueueConfig{}
+	}
+	return cfg.RequestQueue
+}
+
+// bufferOnFlushError reports whether responses the client fails to flush are held back and
+// delivered whole.
+func (s *Server) bufferOnFlushError() bool {
+	cfg := s.liveCfg.Load()
+	return cfg != nil && cfg.Streaming.BufferOnFlushError
+}
+
+// requiresAuth reports whether requests with method to path need valid credentials under
+// the configured auth.routes.
+func (s *Server) requiresAuth(method, path string) bool {
+	cfg := s.liveCfg.Load()
+	return cfg == nil || cfg.Access.RequiresAuth(method, path)
+}
+
+// captureSettings reports whether exchanges are captured and the directory they go to,
+// resolved against the configuration file directory.
+func (s *Server) captureSettings() (bool, string) {
+	cfg := s.liveCfg.Load()
+	if cfg == nil || !cfg.Capture.Enable {
+		return false, ""
+	}
+	dir := strings.TrimSpace(cfg.Capture.Dir)
+	if dir == "" {
+		dir = "captures"
+	}
+	if !filepath.IsAbs(dir) && s.configFilePath != "" {
+		dir = filepath.Join(filepath.Dir(s.configFilePath), dir)
+	}
+	return true, dir
+}
+
+// getAndHead registers a read-only API route for HEAD as well, which probes and load
+// balancer health checks send; net/http drops the body of HEAD responses.
+var getAndHead = []string{http.MethodGet, http.MethodHead}
+
 // setupRoutes configures the API routes for the server.
 // It defines the endpoints and associates them with their respective handlers.
 func (s *Server) setupRoutes() {
@@ -232,27 +317,29 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(AuthMiddleware(s.accessManager, s.requiresAuth), middleware.RequestQueueMiddleware(s.requestQueue, s.requestQueueSettings), middleware.StreamFlushMiddleware(s.bufferOnFlushError), middleware.CaptureMiddleware(s.captureSettings))
 	{
-		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
+		v1.Match(getAndHead, "/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
+		v1.Match(getAndHead, "/responses/:id", openaiResponsesHandlers.GetResponse)
+		v1.DELETE("/responses/:id", openaiResponsesHandlers.DeleteResponse)
 	}
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(AuthMiddleware(s.accessManager, s.requiresAuth), middleware.RequestQueueMiddleware(s.requestQueue, s.requestQueueSettings), middleware.StreamFlushMiddleware(s.bufferOnFlushError), middleware.CaptureMiddleware(s.captureSettings))
 	{
-		v1beta.GET("/models", geminiHandlers.GeminiModels)
+		v1beta.Match(getAndHead, "/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/:action", geminiHandlers.GeminiHandler)
-		v1beta.GET("/models/:action", geminiHandlers.GeminiGetHandler)
+		v1beta.Match(getAndHead, "/models/:action", geminiHandlers.GeminiGetHandler)
 	}
 
 	// Root endpoint
-	s.engine.GET("/", func(c *gin.Context) {
+	s.engine.Match(getAndHead, "/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "CLI Proxy API Server",
 			"version": "1.0.0",
@@ -264,6 +351,8 @@ func (s *Server) setupRoutes() {
 		})
 	})
 	s.engine.POST("/v1internal:method", geminiCLIHandlers.CLIHandler)
+	// Unauthenticated on purpose; the handler answers 404 unless public-capabilities is set.
+	s.engine.Match(getAndHead, "/v1/capabilities", s.mgmt.GetPublicCapabilities)
 
 	// OAuth callback endpoints (reuse main server port)
 	// These endpoints receive provider redirects and persist
@@ -277,8 +366,7 @@ func (s *Server) setupRoutes() {
 			file := fmt.Sprintf("%s/.oauth-anthropic-%s.oauth", s.cfg.AuthDir, state)
 			_ = os.WriteFile(file, []byte(fmt.Sprintf(`{"code":"%s","state":"%s","error":"%s"}`, code, state, errStr)), 0o600)
 		}
-		c.Header("Content-Type", "text/html; charset=utf-8")
-		c.String(http.StatusOK, "<html><body><h1>Authentication successful!</h1><p>You can close this window.</p></body></html>")
+		s.writeOAuthSuccess(c)
 	})
 
 	s.engine.GET("/codex/callback", func(c *gin.Context) {
@@ -289,8 +377,7 @@ func (s *Server) setupRoutes() {
 			file := fmt.Sprintf("%s/.oauth-codex-%s.oauth", s.cfg.AuthDir, state)
 			_ = os.WriteFile(file, []byte(fmt.Sprintf(`{"code":"%s","state":"%s","error":"%s"}`, code, state, errStr)), 0o600)
 		}
-		c.Header("Content-Type", "text/html; charset=utf-8")
-		c.String(http.StatusOK, "<html><body><h1>Authentication successful!</h1><p>You can close this window.</p></body></html>")
+		s.writeOAuthSuccess(c)
 	})
 
 	s.engine.GET("/google/callback", func(c *gin.Context) {
@@ -301,89 +388,118 @@ func (s *Server) setupRoutes() {
 			file := fmt.Sprintf("%s/.oauth-gemini-%s.oauth", s.cfg.AuthDir, state)
 			_ = os.WriteFile(file, []byte(fmt.Sprintf(`{"code":"%s","state":"%s","error":"%s"}`, code, state, errStr)), 0o600)
 		}
-		c.Header("Content-Type", "text/html; charset=utf-8")
-		c.String(http.StatusOK, "<html><body><h1>Authentication successful!</h1><p>You can close this window.</p></body></html>")
+		s.writeOAuthSuccess(c)
 	})
 
 	// Management API routes (delegated to management handlers)
-	// New logic: if remote-management-key is empty, do not expose any management endpoint (404).
-	if s.cfg.RemoteManagement.SecretKey != "" {
-		mgmt := s.engine.Group("/v0/management")
-		mgmt.Use(s.mgmt.Middleware())
-		{
-			mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
-			mgmt.GET("/config", s.mgmt.GetConfig)
-
-			mgmt.GET("/debug", s.mgmt.GetDebug)
-			mgmt.PUT("/debug", s.mgmt.PutDebug)
-			mgmt.PATCH("/debug", s.mgmt.PutDebug)
-
-			mgmt.GET("/logging-to-file", s.mgmt.GetLoggingToFile)
-			mgmt.PUT("/logging-to-file", s.mgmt.PutLoggingToFile)
-			mgmt.PATCH("/logging-to-file", s.mgmt.PutLoggingToFile)
-
-			mgmt.GET("/usage-statistics-enabled", s.mgmt.GetUsageStatisticsEnabled)
-			mgmt.PUT("/usage-statistics-enabled", s.mgmt.PutUsageStatisticsEnabled)
-			mgmt.PATCH("/usage-statistics-enabled", s.mgmt.PutUsageStatisticsEnabled)
-
-			mgmt.GET("/proxy-url", s.mgmt.GetProxyURL)
-			mgmt.PUT("/proxy-url", s.mgmt.PutProxyURL)
-			mgmt.PATCH("/proxy-url", s.mgmt.PutProxyURL)
-			mgmt.DELETE("/proxy-url", s.mgmt.DeleteProxyURL)
-
-			mgmt.GET("/quota-exceeded/switch-project", s.mgmt.GetSwitchProject)
-			mgmt.PUT("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
-			mgmt.PATCH("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
-
-			mgmt.GET("/quota-exceeded/switch-preview-model", s.mgmt.GetSwitchPreviewModel)
-			mgmt.PUT("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
-			mgmt.PATCH("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
-
-			mgmt.GET("/api-keys", s.mgmt.GetAPIKeys)
-			mgmt.PUT("/api-keys", s.mgmt.PutAPIKeys)
-			mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
-			mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
-
-			mgmt.GET("/generative-language-api-key", s.mgmt.GetGlKeys)
-			mgmt.PUT("/generative-language-api-key", s.mgmt.PutGlKeys)
-			mgmt.PATCH("/generative-language-api-key", s.mgmt.PatchGlKeys)
-			mgmt.DELETE("/generative-language-api-key", s.mgmt.DeleteGlKeys)
-
-			mgmt.GET("/request-log", s.mgmt.GetRequestLog)
-			mgmt.PUT("/request-log", s.mgmt.PutRequestLog)
-			mgmt.PATCH("/request-log", s.mgmt.PutRequestLog)
-
-			mgmt.GET("/request-retry", s.mgmt.GetRequestRetry)
-			mgmt.PUT("/request-retry", s.mgmt.PutRequestRetry)
-			mgmt.PATCH("/request-retry", s.mgmt.PutRequestRetry)
-
-			mgmt.GET("/claude-api-key", s.mgmt.GetClaudeKeys)
-			mgmt.PUT("/claude-api-key", s.mgmt.PutClaudeKeys)
-			mgmt.PATCH("/claude-api-key", s.mgmt.PatchClaudeKey)
-			mgmt.DELETE("/claude-api-key", s.mgmt.DeleteClaudeKey)
-
-			mgmt.GET("/codex-api-key", s.mgmt.GetCodexKeys)
-			mgmt.PUT("/codex-api-key", s.mgmt.PutCodexKeys)
-			mgmt.PATCH("/codex-api-key", s.mgmt.PatchCodexKey)
-			mgmt.DELETE("/codex-api-key", s.mgmt.DeleteCodexKey)
-
-			mgmt.GET("/openai-compatibility", s.mgmt.GetOpenAICompat)
-			mgmt.PUT("/openai-compatibility", s.mgmt.PutOpenAICompat)
-			mgmt.PATCH("/openai-compatibility", s.mgmt.PatchOpenAICompat)
-			mgmt.DELETE("/openai-compatibility", s.mgmt.DeleteOpenAICompat)
-
-			mgmt.GET("/auth-files", s.mgmt.ListAuthFiles)
-			mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
-			mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
-			mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
-
-			mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
-			mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
-			mgmt.GET("/gemini-cli-auth-url", s.mgmt.RequestGeminiCLIToken)
-			mgmt.POST("/gemini-web-token", s.mgmt.CreateGeminiWebToken)
-			mgmt.GET("/qwen-auth-url", s.mgmt.RequestQwenToken)
-			mgmt.GET("/get-auth-status", s.mgmt.GetAuthStatus)
-		}
+	// Routes are registered even without a management key, so the middleware can tell
+	// clients that management is disabled instead of answering with a bare 404.
+	mgmt := s.engine.Group("/v0/management")
+	mgmt.Use(s.mgmt.Middleware())
+	{
+		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.GET("/config", s.mgmt.GetConfig)
+
+		mgmt.GET("/debug", s.mgmt.GetDebug)
+		mgmt.PUT("/debug", s.mgmt.PutDebug)
+		mgmt.PATCH("/debug", s.mgmt.PutDebug)
+
+		mgmt.GET("/logging-to-file", s.mgmt.GetLoggingToFile)
+		mgmt.PUT("/logging-to-file", s.mgmt.PutLoggingToFile)
+		mgmt.PATCH("/logging-to-file", s.mgmt.PutLoggingToFile)
+
+		mgmt.GET("/usage-statistics-enabled", s.mgmt.GetUsageStatisticsEnabled)
+		mgmt.PUT("/usage-statistics-enabled", s.mgmt.PutUsageStatisticsEnabled)
+		mgmt.PATCH("/usage-statistics-enabled", s.mgmt.PutUsageStatisticsEnabled)
+
+		mgmt.GET("/proxy-url", s.mgmt.GetProxyURL)
+		mgmt.PUT("/proxy-url", s.mgmt.PutProxyURL)
+		mgmt.PATCH("/proxy-url", s.mgmt.PutProxyURL)
+		mgmt.DELETE("/proxy-url", s.mgmt.DeleteProxyURL)
+
+		mgmt.GET("/quota-exceeded/switch-project", s.mgmt.GetSwitchProject)
+		mgmt.PUT("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
+		mgmt.PATCH("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
+
+		mgmt.GET("/quota-exceeded/switch-preview-model", s.mgmt.GetSwitchPreviewModel)
+		mgmt.PUT("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
+		mgmt.PATCH("/quota-exceeded/switch-preview-model", s.mgmt.PutSwitchPreviewModel)
+
+		mgmt.GET("/api-keys", s.mgmt.GetAPIKeys)
+		mgmt.PUT("/api-keys", s.mgmt.PutAPIKeys)
+		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
+		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
+
+		mgmt.GET("/generative-language-api-key", s.mgmt.GetGlKeys)
+		mgmt.PUT("/generative-language-api-key", s.mgmt.PutGlKeys)
+		mgmt.PATCH("/generative-language-api-key", s.mgmt.PatchGlKeys)
+		mgmt.DELETE("/generative-language-api-key", s.mgmt.DeleteGlKeys)
+
+		mgmt.GET("/request-log", s.mgmt.GetRequestLog)
+		mgmt.PUT("/request-log", s.mgmt.PutRequestLog)
+		mgmt.PATCH("/request-log", s.mgmt.PutRequestLog)
+		mgmt.GET("/request-logs/:id/export", s.mgmt.ExportRequestLog)
+
+		mgmt.GET("/request-retry", s.mgmt.GetRequestRetry)
+		mgmt.PUT("/request-retry", s.mgmt.PutRequestRetry)
+		mgmt.PATCH("/request-retry", s.mgmt.PutRequestRetry)
+
+		mgmt.GET("/claude-api-key", s.mgmt.GetClaudeKeys)
+		mgmt.PUT("/claude-api-key", s.mgmt.PutClaudeKeys)
+		mgmt.PATCH("/claude-api-key", s.mgmt.PatchClaudeKey)
+		mgmt.DELETE("/claude-api-key", s.mgmt.DeleteClaudeKey)
+
+		mgmt.GET("/codex-api-key", s.mgmt.GetCodexKeys)
+		mgmt.PUT("/codex-api-key", s.mgmt.PutCodexKeys)
+		mgmt.PATCH("/codex-api-key", s.mgmt.PatchCodexKey)
+		mgmt.DELETE("/codex-api-key", s.mgmt.DeleteCodexKey)
+
+		mgmt.GET("/openai-compatibility", s.mgmt.GetOpenAICompat)
+		mgmt.PUT("/openai-compatibility", s.mgmt.PutOpenAICompat)
+		mgmt.PATCH("/openai-compatibility", s.mgmt.PatchOpenAICompat)
+		mgmt.DELETE("/openai-compatibility", s.mgmt.DeleteOpenAICompat)
+
+		mgmt.GET("/auth-files", s.mgmt.ListAuthFiles)
+		mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
+		mgmt.GET("/auth-files/errors", s.mgmt.GetAuthFileErrors)
+		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
+		mgmt.POST("/auth-files/import", s.mgmt.ImportAuthFiles)
+		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
+		mgmt.PATCH("/auth-files", s.mgmt.PatchAuthFile)
+		mgmt.POST("/auth-files/maintenance", s.mgmt.SetAuthMaintenance)
+		mgmt.POST("/auth-files/disable", s.mgmt.DisableAuthFile)
+		mgmt.POST("/auth-files/enable", s.mgmt.EnableAuthFile)
+
+		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
+		mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
+		mgmt.GET("/gemini-cli-auth-url", s.mgmt.RequestGeminiCLIToken)
+		mgmt.POST("/gemini-web-token", s.mgmt.CreateGeminiWebToken)
+		mgmt.POST("/gemini-web/rotate", s.mgmt.RotateGeminiWebCookies)
+		mgmt.GET("/gemini-web/health", s.mgmt.GetGeminiWebHealth)
+		mgmt.GET("/gemini-web/conversations", s.mgmt.GetGeminiWebConversations)
+		mgmt.GET("/gemini-web/conversations/:id", s.mgmt.GetGeminiWebConversation)
+		mgmt.GET("/qwen-auth-url", s.mgmt.RequestQwenToken)
+		mgmt.GET("/get-auth-status", s.mgmt.GetAuthStatus)
+		mgmt.GET("/oauth-sessions", s.mgmt.GetOAuthSessions)
+		mgmt.GET("/model-versions", s.mgmt.GetModelVersions)
+
+		mgmt.POST("/route-preview", s.mgmt.PostRoutePreview)
+		mgmt.GET("/quota-status", s.mgmt.GetQuotaStatus)
+		mgmt.GET("/capabilities", s.mgmt.GetCapabilities)
+		mgmt.GET("/upstream-connections", s.mgmt.GetUpstreamConnections)
+		mgmt.GET("/logs/recent", s.mgmt.GetRecentLogs)
+		mgmt.GET("/logs/stream", s.mgmt.StreamLogs)
+		mgmt.GET("/management-keys", s.mgmt.GetManagementKeys)
+
+		mgmt.GET("/rag-documents", s.mgmt.ListRAGDocuments)
+		mgmt.POST("/rag-documents", s.mgmt.UploadRAGDocument)
+		mgmt.DELETE("/rag-documents", s.mgmt.DeleteRAGDocument)
+	}
+
+	if s.cfg.AdminUI.Enable {
+		prefix := adminui.NormalizePath(s.cfg.AdminUI.Path)
+		// gin redirects the bare prefix to prefix+"/" through its trailing slash handling.
+		s.engine.GET(prefix+"/*filepath", s.mgmt.AdminUIMiddleware(), gin.WrapH(adminui.Handler(prefix)))
 	}
 }
 
@@ -539,6 +655,12 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Headers", "*")
 
 		if c.Request.Method == "OPTIONS" {
+			// OPTIONS is never routed, so Gin has listed the methods of a known path in Allow.
+			if allowed := c.Writer.Header().Get("Allow"); allowed != "" {
+				allowed += ", " + http.MethodOptions
+				c.Header("Allow", allowed)
+				c.Header("Access-Control-Allow-Methods", allowed)
+			}
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -576,8 +698,14 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		log.Debugf("request logging updated from %t to %t", s.cfg.RequestLog, cfg.RequestLog)
 	}
 
-	if s.cfg.LoggingToFile != cfg.LoggingToFile {
-		if err := logging.ConfigureLogOutput(cfg.LoggingToFile); err != nil {
+	if s.requestLogger != nil && s.cfg.RequestLogRotation != cfg.RequestLogRotation {
+		if rotator, ok := s.requestLogger.(interface{ SetRotation(logging.Rotation) }); ok {
+			rotator.SetRotation(logging.Rotation(cfg.RequestLogRotation))
+		}
+	}
+
+	if s.cfg.LoggingToFile != cfg.LoggingToFile || s.cfg.LogRotation != cfg.LogRotation {
+		if err := logging.ConfigureLogOutput(cfg.LoggingToFile, logging.Rotation(cfg.LogRotation)); err != nil {
 			log.Errorf("failed to reconfigure log output: %v", err)
 		} else {
 			log.Debugf("logging_to_file updated from %t to %t", s.cfg.LoggingToFile, cfg.LoggingToFile)
@@ -597,7 +725,13 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		log.Debugf("debug mode updated from %t to %t", s.cfg.Debug, cfg.Debug)
 	}
 
+	registry.GetGlobalRegistry().SetCapabilityOverrides(cfg.ModelCapabilities)
+	util.ConfigureUpstreamTransports(cfg)
+	usage.SetModelPrices(cfg.Pricing.Models)
+	modelversion.Default().Configure(cfg.ModelVersions.Enable, cfg.AuthDir, cfg.ModelVersions.WebhookURL)
+
 	s.cfg = cfg
+	s.liveCfg.Store(cfg)
 	s.handlers.UpdateClients(cfg)
 	if s.mgmt != nil {
 		s.mgmt.SetConfig(cfg)
@@ -630,8 +764,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 
 // AuthMiddleware returns a Gin middleware handler that authenticates requests
 // using the configured authentication providers. When no providers are available,
-// it allows all requests (legacy behaviour).
-func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
+// it allows all requests (legacy behaviour). Requests without credentials pass when
+// requiresAuth reports their method and path as open to anonymous access.
+func AuthMiddleware(manager *sdkaccess.Manager, requiresAuth func(method, path string) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if manager == nil {
 			c.Next()
@@ -639,6 +774,10 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 		}
 
 		result, err := manager.Authenticate(c.Request.Context(), c.Request)
+		if errors.Is(err, sdkaccess.ErrNoCredentials) && requiresAuth != nil && !requiresAuth(c.Request.Method, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
 		if err == nil {
 			if result != nil {
 				c.Set("apiKey", result.Principal)
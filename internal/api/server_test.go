@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+// keyProvider accepts the "Bearer valid" header and reports every other request as
+// lacking credentials.
+type keyProvider struct{}
+
+func (keyProvider) Identifier() string { return "test" }
+
+func (keyProvider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if r.Header.Get("Authorization") == "Bearer valid" {
+		return &sdkaccess.Result{Provider: "test", Principal: "valid"}, nil
+	}
+	return nil, sdkaccess.ErrNoCredentials
+}
+
+func TestAuthMiddlewareOpensRoutesOnlyForTheirMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	s.liveCfg.Store(&config.Config{Access: config.AccessConfig{Routes: []config.AccessRoute{
+		{Path: "/v1beta/models*", Methods: []string{"GET"}, Required: false},
+	}}})
+	manager := sdkaccess.NewManager()
+	manager.SetProviders([]sdkaccess.Provider{keyProvider{}})
+
+	engine := gin.New()
+	engine.Use(AuthMiddleware(manager, s.requiresAuth))
+	engine.Any("/v1beta/models/*rest", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	cases := []struct {
+		method, path, auth string
+		want               int
+	}{
+		{http.MethodGet, "/v1beta/models/gemini-2.5-pro", "", http.StatusOK},
+		{http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", "", http.StatusUnauthorized},
+		{http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", "Bearer valid", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s (auth %q) = %d, want %d", tc.method, tc.path, tc.auth, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestRequiresAuthFollowsTheStoredConfig(t *testing.T) {
+	s := &Server{}
+	if !s.requiresAuth(http.MethodGet, "/v1/models") {
+		t.Fatal("requiresAuth without a config should require credentials")
+	}
+	s.liveCfg.Store(&config.Config{Access: config.AccessConfig{Routes: []config.AccessRoute{{Path: "/v1/models"}}}})
+	if s.requiresAuth(http.MethodGet, "/v1/models") {
+		t.Fatal("requiresAuth ignored the stored config")
+	}
+}
+
+// newMethodTestServer returns a server with the full route table that accepts the
+// "Bearer valid" header.
+func newMethodTestServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	accessManager := sdkaccess.NewManager()
+	accessManager.SetProviders([]sdkaccess.Provider{keyProvider{}})
+	return NewServer(&config.Config{}, coreauth.NewManager(nil, nil, nil), accessManager, "")
+}
+
+func serveMethod(s *Server, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWrongMethodGets405InThePathsDialect(t *testing.T) {
+	s := newMethodTestServer(t)
+	cases := []struct {
+		method, path, allow, messagePath string
+	}{
+		{http.MethodGet, "/v1/chat/completions", "POST, OPTIONS", "error.message"},
+		{http.MethodGet, "/v1/messages", "POST, OPTIONS", "error.message"},
+		{http.MethodPut, "/v1beta/models", "GET, HEAD, OPTIONS", "error.message"},
+	}
+	for _, tc := range cases {
+		rec := serveMethod(s, tc.method, tc.path)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s %s = %d, want 405", tc.method, tc.path, rec.Code)
+		}
+		if allow := rec.Header().Get("Allow"); allow != tc.allow {
+			t.Errorf("%s %s Allow = %q, want %q", tc.method, tc.path, allow, tc.allow)
+		}
+		if gjson.Get(rec.Body.String(), tc.messagePath).String() == "" {
+			t.Errorf("%s %s body = %s, want an error message", tc.method, tc.path, rec.Body.String())
+		}
+	}
+
+	openAI := serveMethod(s, http.MethodGet, "/v1/chat/completions").Body.String()
+	if code := gjson.Get(openAI, "error.code").String(); code != "method_not_allowed" {
+		t.Errorf("OpenAI error code = %q in %s", code, openAI)
+	}
+	claude := serveMethod(s, http.MethodGet, "/v1/messages").Body.String()
+	if gjson.Get(claude, "type").String() != "error" || gjson.Get(claude, "error.type").String() != "invalid_request_error" {
+		t.Errorf("Claude error body = %s", claude)
+	}
+	gemini := serveMethod(s, http.MethodPut, "/v1beta/models").Body.String()
+	if gjson.Get(gemini, "error.code").Int() != http.StatusMethodNotAllowed || gjson.Get(gemini, "error.status").String() == "" {
+		t.Errorf("Gemini error body = %s", gemini)
+	}
+}
+
+func TestHeadOnModelsAnswersWithoutBody(t *testing.T) {
+	s := newMethodTestServer(t)
+	get := serveMethod(s, http.MethodGet, "/v1/models")
+	if get.Code != http.StatusOK || get.Body.Len() == 0 {
+		t.Fatalf("GET /v1/models = %d with %d bytes", get.Code, get.Body.Len())
+	}
+	head := serveMethod(s, http.MethodHead, "/v1/models")
+	if head.Code != http.StatusOK {
+		t.Fatalf("HEAD /v1/models = %d, want 200", head.Code)
+	}
+	if ct := head.Header().Get("Content-Type"); ct != get.Header().Get("Content-Type") {
+		t.Errorf("HEAD Content-Type = %q, want that of GET %q", ct, get.Header().Get("Content-Type"))
+	}
+}
+
+func TestOptionsListsTheMethodsOfThePath(t *testing.T) {
+	s := newMethodTestServer(t)
+	cases := []struct {
+		path, allow string
+	}{
+		{"/v1/chat/completions", "POST, OPTIONS"},
+		{"/v1/models", "GET, HEAD, OPTIONS"},
+		{"/v1/responses/resp_1", "GET, HEAD, DELETE, OPTIONS"},
+	}
+	for _, tc := range cases {
+		rec := serveMethod(s, http.MethodOptions, tc.path)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("OPTIONS %s = %d, want 204", tc.path, rec.Code)
+		}
+		if allow := rec.Header().Get("Allow"); allow != tc.allow {
+			t.Errorf("OPTIONS %s Allow = %q, want %q", tc.path, allow, tc.allow)
+		}
+		if methods := rec.Header().Get("Access-Control-Allow-Methods"); methods != tc.allow {
+			t.Errorf("OPTIONS %s Access-Control-Allow-Methods = %q, want %q", tc.path, methods, tc.allow)
+		}
+	}
+	if rec := serveMethod(s, http.MethodOptions, "/no/such/path"); rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != "" {
+		t.Errorf("OPTIONS on an unknown path = %d with Allow %q, want the blanket CORS answer", rec.Code, rec.Header().Get("Allow"))
+	}
+}
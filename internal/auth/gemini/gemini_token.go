@@ -34,6 +34,9 @@ type GeminiTokenStorage struct {
 
 	// Type indicates the authentication provider type, always "gemini" for this storage.
 	Type string `json:"type"`
+
+	// Endpoint optionally overrides the Code Assist endpoint, e.g. with a regional one.
+	Endpoint string `json:"endpoint,omitempty"`
 }
 
 // SaveTokenToFile serializes the Gemini token storage to a JSON file.
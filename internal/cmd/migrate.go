@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/migrate"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoMigrate runs the legacy v5 migration once and prints what was converted.
+//
+// Parameters:
+//   - cfg: The application configuration
+func DoMigrate(cfg *config.Config) {
+	report, err := migrate.Run(migrate.Options{AuthDir: cfg.AuthDir})
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+		return
+	}
+	if len(report.Results) == 0 {
+		fmt.Println("Nothing to migrate.")
+		return
+	}
+	for _, r := range report.Results {
+		if r.Action == migrate.ActionFailed {
+			fmt.Printf("[%s] %s: failed: %s\n", r.Kind, r.Path, r.Error)
+			continue
+		}
+		fmt.Printf("[%s] %s: migrated\n", r.Kind, r.Path)
+		for _, change := range r.Changes {
+			fmt.Printf("    - %s\n", change)
+		}
+	}
+	fmt.Printf("Migrated %d file(s), %d failed. Report written to %s\n", report.Migrated, report.Failed, report.Path)
+}
+
+// runStartupMigration performs the legacy v5 migration before the service starts. Failures
+// are logged and never prevent startup.
+func runStartupMigration(cfg *config.Config) {
+	report, err := migrate.Run(migrate.Options{AuthDir: cfg.AuthDir})
+	if err != nil {
+		log.Warnf("legacy migration skipped: %v", err)
+		return
+	}
+	if len(report.Results) == 0 {
+		return
+	}
+	log.Infof("legacy migration: %d file(s) migrated, %d failed, report at %s", report.Migrated, report.Failed, report.Path)
+	for _, r := range report.Results {
+		if r.Action == migrate.ActionFailed {
+			log.Warnf("legacy migration failed for %s: %s", r.Path, r.Error)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// preflightHandlerFormats lists the request formats the HTTP handlers accept.
+var preflightHandlerFormats = []string{
+	constant.OpenAI,
+	constant.OpenaiResponse,
+	constant.Claude,
+	constant.Gemini,
+	constant.GeminiCLI,
+}
+
+// preflightProviderHandlers lists the handler formats of providers that do not serve every
+// handler. Gemini Web reads Gemini requests as they are and translates only from the OpenAI
+// formats.
+var preflightProviderHandlers = map[string][]string{
+	constant.GeminiWeb: {constant.OpenAI, constant.OpenaiResponse, constant.Gemini},
+}
+
+// preflightNativeFormats maps a provider format to a handler format it reads without a
+// translator.
+var preflightNativeFormats = map[string]string{
+	constant.GeminiWeb: constant.Gemini,
+}
+
+// DoPreflight loads every configured account, checks that it carries usable credentials and
+// that a translator exists between each handler and each configured provider, without
+// starting the server or changing any account. It prints one line per check and reports
+// whether all of them passed.
+//
+// Parameters:
+//   - cfg: The application configuration
+//   - configPath: The path of the loaded configuration file
+//
+// Returns:
+//   - bool: True when no check failed
+func DoPreflight(cfg *config.Config, configPath string) bool {
+	failed := 0
+	check := func(kind, subject string, err error) {
+		if err != nil {
+			failed++
+			fmt.Printf("[%s] %s: failed: %v\n", kind, subject, err)
+			return
+		}
+		fmt.Printf("[%s] %s: ok\n", kind, subject)
+	}
+
+	for i, entry := range cfg.MaintenanceWindows {
+		_, err := coreauth.ParseMaintenanceWindow(entry.Provider, entry.Auth, entry.Days, entry.Start, entry.End, entry.Timezone)
+		check("config", fmt.Sprintf("maintenance-windows[%d]", i), err)
+	}
+	if spec := strings.TrimSpace(cfg.GeminiWeb.ActiveHours); spec != "" {
+		_, err := coreauth.ParseActiveHours(spec, cfg.GeminiWeb.Timezone)
+		check("config", "gemini-web.active-hours", err)
+	}
+
+	auths, err := preflightAuths(cfg, configPath)
+	if err != nil {
+		check("auth", cfg.AuthDir, err)
+		return false
+	}
+	providers := make(map[string]struct{})
+	for _, auth := range auths {
+		if auth.Disabled {
+			fmt.Printf("[auth] %s (%s): disabled, skipped\n", auth.ID, auth.Provider)
+			continue
+		}
+		providers[strings.ToLower(auth.Provider)] = struct{}{}
+		check("auth", fmt.Sprintf("%s (%s)", auth.ID, auth.Provider), preflightAuth(auth, time.Now()))
+	}
+	if len(providers) == 0 {
+		check("auth", "accounts", fmt.Errorf("no enabled account is configured"))
+	}
+
+	names := make([]string, 0, len(providers))
+	for provider := range providers {
+		names = append(names, provider)
+	}
+	sort.Strings(names)
+	for _, provider := range names {
+		to := preflightProviderFormat(provider)
+		handlers := preflightHandlerFormats
+		if served, ok := preflightProviderHandlers[provider]; ok {
+			handlers = served
+		}
+		for _, from := range handlers {
+			check("translator", fmt.Sprintf("%s -> %s (%s)", from, to, provider), preflightTranslator(from, to))
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("Preflight failed: %d check(s) did not pass.\n", failed)
+		return false
+	}
+	fmt.Println("Preflight passed.")
+	return true
+}
+
+// preflightAuths returns the accounts the service would load: the API keys of the
+// configuration and the files of the auth directory.
+func preflightAuths(cfg *config.Config, configPath string) ([]*coreauth.Auth, error) {
+	w, err := watcher.NewWatcher(configPath, cfg.AuthDir, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errStop := w.Stop(); errStop != nil {
+			log.Debugf("preflight: failed to stop watcher: %v", errStop)
+		}
+	}()
+	w.SetConfig(cfg)
+	return w.SnapshotCoreAuths(), nil
+}
+
+// preflightAuth checks that auth carries credentials it can authenticate with at now.
+// Accounts from the configuration need a key; file-backed accounts an access token that has
+// not expired or a refresh token to get one, and Gemini Web accounts their session cookie.
+// Credentials are not refreshed: some providers rotate the refresh token on every refresh,
+// and a check must leave the accounts as it found them.
+func preflightAuth(auth *coreauth.Auth, now time.Time) error {
+	if auth.Metadata == nil {
+		if strings.TrimSpace(auth.Attributes["api_key"]) == "" {
+			return fmt.Errorf("api key is empty")
+		}
+		return nil
+	}
+	if strings.EqualFold(auth.Provider, constant.GeminiWeb) {
+		if preflightMetadataString(auth.Metadata, "secure_1psid") == "" {
+			return fmt.Errorf("secure_1psid cookie is missing")
+		}
+		return nil
+	}
+	if preflightMetadataString(auth.Metadata, "refresh_token") != "" {
+		return nil
+	}
+	if preflightMetadataString(auth.Metadata, "access_token") == "" {
+		return fmt.Errorf("no access or refresh token")
+	}
+	if expiry, ok := auth.ExpirationTime(); ok && !expiry.After(now) {
+		return fmt.Errorf("access token expired at %s and there is no refresh token", expiry.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// preflightMetadataString returns the string key of metadata, also when an auth file nests
+// its token under "token".
+func preflightMetadataString(metadata map[string]any, key string) string {
+	if v, ok := metadata[key].(string); ok && strings.TrimSpace(v) != "" {
+		return v
+	}
+	if token, ok := metadata["token"].(map[string]any); ok {
+		if v, ok := token[key].(string); ok && strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// preflightProviderFormat returns the request format the executor of provider sends upstream.
+func preflightProviderFormat(provider string) string {
+	switch provider {
+	case constant.Gemini, constant.GeminiCLI, constant.GeminiWeb, constant.Claude, constant.Codex:
+		return provider
+	default:
+		return constant.OpenAI
+	}
+}
+
+// preflightTranslator reports an error when requests in format from cannot reach a provider
+// speaking format to, or its answers cannot come back.
+func preflightTranslator(from, to string) error {
+	if from == to || preflightNativeFormats[to] == from {
+		return nil
+	}
+	source, target := sdktranslator.FromString(from), sdktranslator.FromString(to)
+	if !sdktranslator.HasRequestTransformer(source, target) {
+		return fmt.Errorf("no request translator is registered")
+	}
+	if !sdktranslator.HasResponseTransformer(source, target) {
+		return fmt.Errorf("no response translator is registered")
+	}
+	return nil
+}
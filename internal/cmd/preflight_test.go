@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// preflightSetup writes a configuration and the given auth files to a temporary directory.
+func preflightSetup(t *testing.T, files map[string]map[string]any) (*config.Config, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	authDir := filepath.Join(dir, "auths")
+	if err := os.MkdirAll(authDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, metadata := range files {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(authDir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &config.Config{AuthDir: authDir}, configPath, authDir
+}
+
+func TestPreflightPassesHealthyConfig(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Format(time.RFC3339)
+	cfg, configPath, authDir := preflightSetup(t, map[string]map[string]any{
+		"claude.json":     {"type": "claude", "email": "a@example.com", "access_token": "at", "expired": expiry},
+		"codex.json":      {"type": "codex", "email": "b@example.com", "access_token": "at", "refresh_token": "rt"},
+		"gemini-web.json": {"type": "gemini-web", "secure_1psid": "sid", "secure_1psidts": "ts"},
+	})
+	before, err := os.ReadFile(filepath.Join(authDir, "claude.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !DoPreflight(cfg, configPath) {
+		t.Fatal("preflight failed for a healthy configuration")
+	}
+	after, err := os.ReadFile(filepath.Join(authDir, "claude.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Fatal("preflight changed an auth file")
+	}
+}
+
+func TestPreflightFailsBrokenProvider(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	cfg, configPath, _ := preflightSetup(t, map[string]map[string]any{
+		"claude.json": {"type": "claude", "email": "a@example.com", "access_token": "at", "expired": expired},
+	})
+	if DoPreflight(cfg, configPath) {
+		t.Fatal("preflight passed with an expired account and no refresh token")
+	}
+}
+
+func TestPreflightAuth(t *testing.T) {
+	now := time.Now()
+	for _, tt := range []struct {
+		name    string
+		auth    *coreauth.Auth
+		wantErr bool
+	}{
+		{name: "api key", auth: &coreauth.Auth{Provider: "claude", Attributes: map[string]string{"api_key": "k"}}},
+		{name: "empty api key", auth: &coreauth.Auth{Provider: "claude", Attributes: map[string]string{}}, wantErr: true},
+		{name: "nested refresh token", auth: &coreauth.Auth{Provider: "gemini-cli", Metadata: map[string]any{"token": map[string]any{"refresh_token": "rt"}}}},
+		{name: "no token", auth: &coreauth.Auth{Provider: "qwen", Metadata: map[string]any{"email": "a"}}, wantErr: true},
+		{name: "gemini web without cookie", auth: &coreauth.Auth{Provider: "gemini-web", Metadata: map[string]any{"secure_1psidts": "ts"}}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := preflightAuth(tt.auth, now); (err != nil) != tt.wantErr {
+				t.Fatalf("preflightAuth = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreflightTranslatorsCoverGeminiWeb(t *testing.T) {
+	to := preflightProviderFormat(constant.GeminiWeb)
+	for _, from := range preflightProviderHandlers[constant.GeminiWeb] {
+		if err := preflightTranslator(from, to); err != nil {
+			t.Fatalf("%s -> %s: %v", from, to, err)
+		}
+	}
+}
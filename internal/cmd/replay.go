@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// replayVolatileKeys lists the response fields that differ between two runs of the same
+// exchange, such as generated IDs and timestamps. Their values are ignored when comparing.
+var replayVolatileKeys = map[string]struct{}{
+	"id":                 {},
+	"created":            {},
+	"created_at":         {},
+	"createTime":         {},
+	"responseId":         {},
+	"response_id":        {},
+	"item_id":            {},
+	"call_id":            {},
+	"tool_use_id":        {},
+	"system_fingerprint": {},
+}
+
+// DoReplay feeds every exchange captured in dir through the translators of this build and
+// compares the result with the response the client originally got, ignoring generated IDs
+// and timestamps. It prints one line per capture and reports whether all of them matched.
+//
+// Parameters:
+//   - dir: The directory holding the capture files
+//
+// Returns:
+//   - bool: True when every capture replayed to the recorded response
+func DoReplay(dir string) bool {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(paths) == 0 {
+		fmt.Printf("[replay] %s: no captures found\n", dir)
+		return false
+	}
+	sort.Strings(paths)
+	failed := 0
+	for _, path := range paths {
+		name := filepath.Base(path)
+		exchange, errRead := logging.ReadCapture(path)
+		if errRead != nil {
+			failed++
+			fmt.Printf("[replay] %s: failed: %v\n", name, errRead)
+			continue
+		}
+		if diff := replayExchange(exchange); diff != "" {
+			failed++
+			fmt.Printf("[replay] %s: %s -> %s: differs: %s\n", name, exchange.SourceFormat, exchange.Provider, diff)
+			continue
+		}
+		fmt.Printf("[replay] %s: %s -> %s: ok\n", name, exchange.SourceFormat, exchange.Provider)
+	}
+	fmt.Printf("[replay] %d of %d captures matched\n", len(paths)-failed, len(paths))
+	return failed == 0
+}
+
+// replayExchange translates the upstream response of exchange as the executor of its
+// provider does and returns a description of the first difference from the recorded
+// response, or "" when they match.
+func replayExchange(exchange logging.CapturedExchange) string {
+	target := sdktranslator.FromString(preflightProviderFormat(exchange.Provider))
+	source := sdktranslator.FromString(exchange.SourceFormat)
+	original := []byte(exchange.Request)
+	request := []byte(exchange.UpstreamRequest)
+	ctx := context.WithValue(context.Background(), "alt", "")
+
+	var replayed []string
+	var param any
+	if exchange.Stream {
+		chunks := strings.Split(exchange.UpstreamResponse, "\n\n")
+		if target == sdktranslator.FromString(constant.Gemini) || target == sdktranslator.FromString(constant.GeminiCLI) {
+			chunks = append(chunks, "[DONE]")
+		}
+		for _, chunk := range chunks {
+			if strings.TrimSpace(chunk) == "" {
+				continue
+			}
+			out, err := sdktranslator.TranslateStreamWithError(ctx, target, source, exchange.Model, original, request, []byte(chunk), &param)
+			if err != nil {
+				return fmt.Sprintf("translation failed: %v", err)
+			}
+			replayed = append(replayed, out...)
+		}
+	} else {
+		body := []byte(exchange.UpstreamResponse)
+		if target == sdktranslator.FromString(constant.Codex) {
+			// Codex answers with an event stream; the executor translates its completed event.
+			body = codexCompletedEvent(body)
+		}
+		out, err := sdktranslator.TranslateNonStreamWithError(ctx, target, source, exchange.Model, original, request, body, &param)
+		if err != nil {
+			return fmt.Sprintf("translation failed: %v", err)
+		}
+		replayed = append(replayed, out)
+	}
+
+	want := normalizeReplayOutput(exchange.Response)
+	got := normalizeReplayOutput(strings.Join(replayed, "\n"))
+	for i := 0; i < len(want) || i < len(got); i++ {
+		switch {
+		case i >= len(got):
+			return fmt.Sprintf("line %d missing: %s", i+1, want[i])
+		case i >= len(want):
+			return fmt.Sprintf("line %d unexpected: %s", i+1, got[i])
+		case want[i] != got[i]:
+			return fmt.Sprintf("line %d: recorded %s, replayed %s", i+1, want[i], got[i])
+		}
+	}
+	return ""
+}
+
+// codexCompletedEvent returns the data of the response.completed event in a Codex event
+// stream, or data unchanged when it has none.
+func codexCompletedEvent(data []byte) []byte {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		line = bytes.TrimSpace(line[5:])
+		if gjson.GetBytes(line, "type").String() == "response.completed" {
+			return line
+		}
+	}
+	return data
+}
+
+// normalizeReplayOutput splits a response body into comparable lines: SSE framing, comments
+// and the [DONE] marker are dropped, and JSON lines are re-encoded with volatile fields
+// blanked so formatting and generated values do not count as differences.
+func normalizeReplayOutput(body string) []string {
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "data:"); ok {
+			line = strings.TrimSpace(rest)
+		}
+		if line == "[DONE]" {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal([]byte(line), &value); err == nil {
+			if encoded, errMarshal := json.Marshal(maskVolatile(value)); errMarshal == nil {
+				line = string(encoded)
+			}
+		}
+		lines = append(lines, line)
+	}
+	// A non-streaming body may be indented JSON spread over many lines.
+	if joined := strings.Join(lines, ""); len(lines) > 1 && json.Valid([]byte(joined)) {
+		var value any
+		_ = json.Unmarshal([]byte(joined), &value)
+		encoded, _ := json.Marshal(maskVolatile(value))
+		return []string{string(encoded)}
+	}
+	return lines
+}
+
+// maskVolatile blanks the values of replayVolatileKeys throughout value.
+func maskVolatile(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, inner := range v {
+			if _, ok := replayVolatileKeys[key]; ok {
+				v[key] = "*"
+				continue
+			}
+			v[key] = maskVolatile(inner)
+		}
+	case []any:
+		for i, inner := range v {
+			v[i] = maskVolatile(inner)
+		}
+	}
+	return value
+}
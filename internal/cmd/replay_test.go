@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/openai"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+const replayClaudeMessage = `{"id":"msg_1","type":"message","role":"assistant","model":"replay-test-model","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":2}}`
+
+var replayClaudeStream = strings.Join([]string{
+	`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"replay-test-model","content":[],"usage":{"input_tokens":3,"output_tokens":0}}}`,
+	`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hel"}}`,
+	`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+	`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":0}`,
+	`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+	`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+}, "\n\n") + "\n\n"
+
+// captureThroughProxy serves an OpenAI chat completion request through the proxy, with a
+// fake Claude upstream, capturing the exchange into dir.
+func captureThroughProxy(t *testing.T, dir string, body string) {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if gjson.GetBytes(data, "stream").Bool() {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, replayClaudeStream)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, replayClaudeMessage)
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := &config.Config{}
+	cfg.Capture = config.CaptureConfig{Enable: true, Dir: dir}
+	cfg.ResponseFooter.Text = "-- footer --"
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor.NewClaudeExecutor(cfg))
+	auth := &coreauth.Auth{ID: "replay-claude", Provider: "claude", Status: coreauth.StatusActive, Attributes: map[string]string{"api_key": "key", "base_url": upstream.URL}}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatal(err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("replay-test", "claude", []*registry.ModelInfo{{ID: "replay-test-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("replay-test") })
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(middleware.CaptureMiddleware(func() (bool, string) { return true, dir }))
+	engine.POST("/v1/chat/completions", openai.NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager)).ChatCompletions)
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("proxy answered %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "footer") {
+		t.Fatalf("response lacks the footer the handlers add: %s", rec.Body.String())
+	}
+}
+
+func TestCaptureReplayRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	captureThroughProxy(t, dir, `{"model":"replay-test-model","messages":[{"role":"user","content":"hi"}]}`)
+	captureThroughProxy(t, dir, `{"model":"replay-test-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(paths) != 2 {
+		t.Fatalf("%d captures written, want 2", len(paths))
+	}
+	for _, path := range paths {
+		exchange, err := logging.ReadCapture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exchange.Stream && strings.Count(exchange.UpstreamResponse, "\n\n") < 7 {
+			t.Fatalf("recorded stream chunks are not separated: %q", exchange.UpstreamResponse)
+		}
+	}
+	if !DoReplay(dir) {
+		t.Fatal("captures of this build do not replay")
+	}
+}
+
+func TestReplayReportsDifferences(t *testing.T) {
+	dir := t.TempDir()
+	captureThroughProxy(t, dir, `{"model":"replay-test-model","messages":[{"role":"user","content":"hi"}]}`)
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(paths) != 1 {
+		t.Fatalf("%d captures written, want 1", len(paths))
+	}
+	exchange, err := logging.ReadCapture(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchange.Response = strings.Replace(exchange.Response, "hello", "goodbye", 1)
+	data, err := json.Marshal(exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(paths[0], data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if DoReplay(dir) {
+		t.Fatal("replay matched a changed response")
+	}
+}
@@ -7,7 +7,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/wildcard"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
 )
@@ -20,18 +24,40 @@ type Config struct {
 	// AuthDir is the directory where authentication token files are stored.
 	AuthDir string `yaml:"auth-dir" json:"-"`
 
+	// AuthRemoval controls how auths whose files were deleted are drained and torn down.
+	AuthRemoval AuthRemovalConfig `yaml:"auth-removal" json:"auth-removal"`
+
 	// Debug enables or disables debug-level logging and other debug features.
 	Debug bool `yaml:"debug" json:"debug"`
 
 	// LoggingToFile controls whether application logs are written to rotating files or stdout.
 	LoggingToFile bool `yaml:"logging-to-file" json:"logging-to-file"`
 
+	// LogRotation bounds the rotating main log written when LoggingToFile is set.
+	LogRotation LogRotationConfig `yaml:"log-rotation" json:"log-rotation"`
+
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// Pricing turns the token usage of requests into an approximate cost.
+	Pricing PricingConfig `yaml:"pricing" json:"pricing"`
+
 	// ProxyURL is the URL of an optional proxy server to use for outbound requests.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
 
+	// UpstreamTransport tunes the connection pools shared by requests to upstream providers.
+	UpstreamTransport UpstreamTransportConfig `yaml:"upstream-transport" json:"upstream-transport"`
+
+	// ForwardHeaders lists client request headers copied onto upstream requests, e.g. caching
+	// hints an upstream honors. Credential and connection headers are never forwarded.
+	ForwardHeaders []string `yaml:"forward-headers" json:"forward-headers"`
+
+	// UserAgents replaces the User-Agent sent upstream, keyed by provider: claude, codex,
+	// gemini, gemini-cli, gemini-web, qwen, the name of an openai-compatibility entry, or
+	// openai-compatibility for all of them. Providers without an entry keep their built-in
+	// user agent.
+	UserAgents map[string]string `yaml:"user-agents" json:"user-agents"`
+
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
@@ -47,29 +73,230 @@ type Config struct {
 	// RequestLog enables or disables detailed request logging functionality.
 	RequestLog bool `yaml:"request-log" json:"request-log"`
 
+	// RequestLogRotation bounds the per-request log files written when RequestLog is set.
+	RequestLogRotation LogRotationConfig `yaml:"request-log-rotation" json:"request-log-rotation"`
+
+	// Capture writes successful exchanges to files that the -replay command checks the
+	// translators against.
+	Capture CaptureConfig `yaml:"capture" json:"capture"`
+
+	// ModelVersions records the upstream identity each served model reports and warns when a
+	// provider swaps the model behind a name.
+	ModelVersions ModelVersionsConfig `yaml:"model-versions" json:"model-versions"`
+
 	// RequestRetry defines the retry times when the request failed.
 	RequestRetry int `yaml:"request-retry" json:"request-retry"`
 
+	// DeadLetter records requests that failed on every account tried.
+	DeadLetter DeadLetterConfig `yaml:"dead-letter" json:"dead-letter"`
+
+	// Mirroring replays a sample of successful requests against a second provider, to
+	// compare its answers offline.
+	Mirroring MirroringConfig `yaml:"mirroring" json:"mirroring"`
+
+	// RetryBudget bounds the accounts a single request may try before it gives up.
+	RetryBudget RetryBudgetConfig `yaml:"retry-budget" json:"retry-budget"`
+
+	// CoalesceRequests makes identical non-streaming requests that are in flight at the same
+	// time, from the same API key, share a single upstream call and its response. Requests
+	// with tools, several choices or server-side state never do.
+	CoalesceRequests bool `yaml:"coalesce-requests" json:"coalesce-requests"`
+
+	// RecentFailureWindow is the number of seconds an account that just failed a request is
+	// passed over for healthy ones of the same provider. 0 disables it.
+	RecentFailureWindow int `yaml:"recent-failure-window" json:"recent-failure-window"`
+
+	// MaintenanceWindows lists recurring periods during which matching accounts are not
+	// selected, so requests fail over at once instead of retrying a backend known to be down.
+	MaintenanceWindows []MaintenanceWindow `yaml:"maintenance-windows" json:"maintenance-windows"`
+
+	// ModelAffinity concentrates the requests for some models on designated accounts, which
+	// serve them while available before the other accounts of the provider are used.
+	ModelAffinity []ModelAffinity `yaml:"model-affinity" json:"model-affinity"`
+
 	// ClaudeKey defines a list of Claude API key configurations as specified in the YAML configuration file.
 	ClaudeKey []ClaudeKey `yaml:"claude-api-key" json:"claude-api-key"`
 
+	// ClaudeBeta configures the anthropic-beta header and beta query of Claude requests.
+	ClaudeBeta ClaudeBetaConfig `yaml:"claude-beta" json:"claude-beta"`
+
+	// Passthrough sends requests to the provider untranslated and returns its raw response.
+	Passthrough PassthroughConfig `yaml:"passthrough" json:"passthrough"`
+
 	// Codex defines a list of Codex API key configurations as specified in the YAML configuration file.
 	CodexKey []CodexKey `yaml:"codex-api-key" json:"codex-api-key"`
 
 	// OpenAICompatibility defines OpenAI API compatibility configurations for external providers.
 	OpenAICompatibility []OpenAICompatibility `yaml:"openai-compatibility" json:"openai-compatibility"`
 
+	// Providers holds built-in providers that need no credentials.
+	Providers ProvidersConfig `yaml:"providers" json:"providers"`
+
 	// RemoteManagement nests management-related options under 'remote-management'.
 	RemoteManagement RemoteManagement `yaml:"remote-management" json:"-"`
 
+	// AdminUI serves the embedded management web UI.
+	AdminUI AdminUIConfig `yaml:"admin-ui" json:"admin-ui"`
+
 	// GeminiWeb groups configuration for Gemini Web client
 	GeminiWeb GeminiWebConfig `yaml:"gemini-web" json:"gemini-web"`
+
+	// Streaming controls flow control between upstream producers and downstream SSE consumers.
+	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
+
+	// ResponseModelName selects the model name reported in responses: "upstream" keeps the
+	// name returned by the backend, "requested" echoes the name the client asked for.
+	ResponseModelName string `yaml:"response-model-name" json:"response-model-name"`
+
+	// StrictModelNames rejects model names that match a registered model only after case and
+	// whitespace normalization with a 400 naming the exact ID, instead of routing them.
+	StrictModelNames bool `yaml:"strict-model-names" json:"strict-model-names"`
+
+	// PublicCapabilities serves GET /v1/capabilities without authentication: the features and
+	// client dialects of each provider and whether any account serves it.
+	PublicCapabilities bool `yaml:"public-capabilities" json:"public-capabilities"`
+
+	// ResponseLanguage injects an instruction asking backends to reply in a fixed language.
+	ResponseLanguage ResponseLanguageConfig `yaml:"response-language" json:"response-language"`
+
+	// ModelPolicy restricts the models each client API key may use.
+	ModelPolicy ModelPolicyConfig `yaml:"model-policy" json:"model-policy"`
+
+	// ClientCredentials lets requests bring their own upstream API key in X-Provider-Key,
+	// used for that request only instead of the stored accounts.
+	ClientCredentials ClientCredentialsConfig `yaml:"client-credentials" json:"client-credentials"`
+
+	// RequestValidation checks inbound request bodies for required fields and their types
+	// before any backend work, answering malformed requests with a 400.
+	RequestValidation bool `yaml:"request-validation" json:"request-validation"`
+
+	// MaxMessages caps the number of messages (or Gemini contents) a request may carry,
+	// answering longer histories with a 400 before translation. Zero means no limit.
+	MaxMessages int `yaml:"max-messages" json:"max-messages"`
+
+	// StrictOpenAI rejects unknown fields in OpenAI requests and flags deprecated ones, for
+	// clients checking their requests against the API.
+	StrictOpenAI StrictOpenAIConfig `yaml:"strict-openai" json:"strict-openai"`
+
+	// ModelCapabilities overrides the built-in capability metadata reported for models,
+	// keyed by model ID.
+	ModelCapabilities map[string]ModelCapability `yaml:"model-capabilities" json:"model-capabilities"`
+
+	// MaxOutputTokens is a hard cap on the output tokens generated per request, applied on
+	// top of whatever the client asks for.
+	MaxOutputTokens OutputTokenCapConfig `yaml:"max-output-tokens" json:"max-output-tokens"`
+
+	// RequestTimeout is the hard limit in seconds on the duration of a client request. A
+	// request still running after it is cancelled and answered with a 504. Zero disables it.
+	RequestTimeout int `yaml:"request-timeout" json:"request-timeout"`
+
+	// SlowRequestThreshold is the duration in seconds after which a finished request is
+	// logged as slow, without aborting it. Zero disables the warning.
+	SlowRequestThreshold int `yaml:"slow-request-threshold" json:"slow-request-threshold"`
+
+	// GeminiCLIOperationTimeout is how long in seconds a Gemini CLI request answered with a
+	// long-running operation polls it before failing with a 504 naming the operation.
+	// Defaults to 300.
+	GeminiCLIOperationTimeout int `yaml:"gemini-cli-operation-timeout" json:"gemini-cli-operation-timeout"`
+
+	// RequestQueue limits the API requests served at once and queues the overflow.
+	RequestQueue RequestQueueConfig `yaml:"request-queue" json:"request-queue"`
+
+	// RAG configures the local document store used to add reference material to requests.
+	RAG RAGConfig `yaml:"rag" json:"rag"`
+
+	// ResponseStore keeps Responses API results so clients can fetch them again and continue
+	// them with previous_response_id.
+	ResponseStore ResponseStoreConfig `yaml:"response-store" json:"response-store"`
+
+	// ToolResultLimit guards against oversized tool results in client requests, which
+	// backends reject with errors that do not say why.
+	ToolResultLimit ToolResultLimitConfig `yaml:"tool-result-limit" json:"tool-result-limit"`
+
+	// ResponseTag appends an audit tag linking responses back to their request record.
+	ResponseTag ResponseTagConfig `yaml:"response-tag" json:"response-tag"`
+
+	// ResponseFooter adds a fixed text, such as a safety disclaimer, to assistant answers.
+	ResponseFooter ResponseFooterConfig `yaml:"response-footer" json:"response-footer"`
+
+	// JSONRepair fixes slightly malformed JSON, such as output wrapped in code fences or with
+	// trailing commas, in non-streaming responses to requests asking for JSON output.
+	JSONRepair bool `yaml:"json-repair" json:"json-repair"`
+
+	// AccountHeaderKeys lists the client API keys whose responses name the label of the auth
+	// that served them, which reveals how the accounts behind the proxy are arranged.
+	AccountHeaderKeys []string `yaml:"account-header-keys,omitempty" json:"account-header-keys,omitempty"`
+
+	// Moderation ends streamed responses as soon as their text matches a blocked rule.
+	Moderation ModerationConfig `yaml:"moderation" json:"moderation"`
+
+	// SafetySettings sets the safety thresholds of requests served by Gemini backends.
+	SafetySettings SafetySettingsConfig `yaml:"safety-settings" json:"safety-settings"`
+
+	// UnknownRequestFields maps provider identifiers to "strip", removing the top-level
+	// request fields their upstream API does not define before forwarding, or "keep", passing
+	// client extensions through. Providers not listed strip when their upstream rejects
+	// unknown fields (gemini, gemini-cli, claude and codex) and keep otherwise.
+	UnknownRequestFields map[string]string `yaml:"unknown-request-fields,omitempty" json:"unknown-request-fields,omitempty"`
+
+	// OAuthSuccessPage customizes the page the OAuth callback endpoints show after a login.
+	OAuthSuccessPage OAuthSuccessPageConfig `yaml:"oauth-success-page" json:"oauth-success-page"`
+
+	// OverrideFiles lists the files applied on top of the config file, in order, as given
+	// with --override.
+	OverrideFiles []string `yaml:"-" json:"-"`
+
+	// OverrideSources maps the dotted path of each field set or cleared by an override file
+	// to that file.
+	OverrideSources map[string]string `yaml:"-" json:"override-sources,omitempty"`
+
+	// overridden holds the same fields as OverrideSources with unambiguous key paths.
+	overridden []overrideSource
 }
 
 // AccessConfig groups request authentication providers.
 type AccessConfig struct {
 	// Providers lists configured authentication providers.
 	Providers []AccessProvider `yaml:"providers" json:"providers"`
+
+	// Routes sets per route whether API routes require authentication. Routes not listed
+	// require it.
+	Routes []AccessRoute `yaml:"routes,omitempty" json:"routes,omitempty"`
+}
+
+// AccessRoute sets whether requests to a path require authentication.
+type AccessRoute struct {
+	// Path is the request path, or a prefix when it ends in '*'.
+	Path string `yaml:"path" json:"path"`
+
+	// Methods limits the route to these HTTP methods; empty matches every method. A prefix
+	// such as "/v1beta/models*" also covers generation, so opening it should name GET.
+	Methods []string `yaml:"methods,omitempty" json:"methods,omitempty"`
+
+	// Required reports whether the requests need valid credentials. When false they are
+	// served without any, and with the key's identity when one is given.
+	Required bool `yaml:"required" json:"required"`
+}
+
+// RequiresAuth reports whether requests with method to path need valid credentials. The
+// first route matching both decides; requests without a match require them.
+func (c AccessConfig) RequiresAuth(method, path string) bool {
+	for _, route := range c.Routes {
+		if len(route.Methods) > 0 && !slices.ContainsFunc(route.Methods, func(m string) bool {
+			return strings.EqualFold(strings.TrimSpace(m), method)
+		}) {
+			continue
+		}
+		pattern := strings.TrimSpace(route.Path)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return route.Required
+			}
+		} else if pattern == path {
+			return route.Required
+		}
+	}
+	return true
 }
 
 // AccessProvider describes a request authentication provider entry.
@@ -110,6 +337,10 @@ type GeminiWebConfig struct {
 	// - Merge <think> content into visible content for tool-friendly output
 	CodeMode bool `yaml:"code-mode" json:"code-mode"`
 
+	// CodeModeReasoning keeps thoughts out of the visible content in code mode, so OpenAI
+	// clients receive them as reasoning_content instead of inline <think> tags.
+	CodeModeReasoning bool `yaml:"code-mode-reasoning,omitempty" json:"code-mode-reasoning,omitempty"`
+
 	// MaxCharsPerRequest caps the number of characters (runes) sent to
 	// Gemini Web in a single request. Long prompts will be split into
 	// multiple requests with a continuation hint, and only the final
@@ -120,6 +351,186 @@ type GeminiWebConfig struct {
 	// DisableContinuationHint, when true, disables the continuation hint for split prompts.
 	// The hint is enabled by default.
 	DisableContinuationHint bool `yaml:"disable-continuation-hint,omitempty" json:"disable-continuation-hint,omitempty"`
+
+	// WarmStandby is the number of idle Gemini Web accounts kept initialized so a
+	// blocked account can be replaced without a cold start. Zero disables the pool.
+	// Defaults to 1 if not set in YAML (see LoadConfig).
+	WarmStandby int `yaml:"warm-standby" json:"warm-standby"`
+
+	// InitMaxRetries stops background re-initialization of an account after this many
+	// consecutive network or outage failures, until its auth file changes. Rejected cookies
+	// disable the account after 3 attempts regardless. Zero retries forever.
+	// Defaults to 12 if not set in YAML (see LoadConfig).
+	InitMaxRetries int `yaml:"init-max-retries" json:"init-max-retries"`
+
+	// RefreshInterval is the number of seconds between background cookie refreshes of an
+	// account. When unset or <=0, a default of 540 is used.
+	RefreshInterval int `yaml:"refresh-interval,omitempty" json:"refresh-interval,omitempty"`
+
+	// PersistInterval is the minimum number of seconds between auth file writes of a refreshed
+	// account whose cookies did not change; rotated cookies are written at once. When unset or
+	// <=0, a default of 10800 is used.
+	PersistInterval int `yaml:"persist-interval,omitempty" json:"persist-interval,omitempty"`
+
+	// IntervalJitter spreads the refresh and persist intervals by up to this percentage per
+	// account, so accounts loaded together do not refresh or write in lockstep. Capped at 50;
+	// zero (default) disables it.
+	IntervalJitter int `yaml:"interval-jitter,omitempty" json:"interval-jitter,omitempty"`
+
+	// EmptyPrompt selects what happens to a request left without a prompt once system and
+	// thought content is filtered out, e.g. one carrying only a system prompt or tools:
+	// "error" (default) rejects it with 400, "placeholder" sends a minimal user turn and
+	// "empty" answers with an empty completion without contacting Gemini Web.
+	EmptyPrompt string `yaml:"empty-prompt,omitempty" json:"empty-prompt,omitempty"`
+
+	// ToolSchemas selects what becomes of the tool declarations of a request, which Gemini Web
+	// has no field for: "drop" (default) leaves them out, "full" writes them ahead of the
+	// prompt on every turn and "compact" writes them once per conversation, referring back to
+	// them on later turns that resume the same conversation with the same tools.
+	ToolSchemas string `yaml:"tool-schemas,omitempty" json:"tool-schemas,omitempty"`
+
+	// AllowTools lets requests declaring tools be served by Gemini Web, which cannot call
+	// them. When false (default) such requests go to the model's other providers and fail
+	// with 400 when Gemini Web is the only one.
+	AllowTools bool `yaml:"allow-tools,omitempty" json:"allow-tools,omitempty"`
+
+	// TitleModel names the model that writes short titles for stored conversations, listed by
+	// the management API. Titles are generated in the background; empty skips them.
+	TitleModel string `yaml:"title-model,omitempty" json:"title-model,omitempty"`
+
+	// ActiveHours is the daily period, as "HH:MM-HH:MM" in Timezone, during which accounts are
+	// selected; outside it they rest. An account file's active_hours and timezone fields take
+	// precedence. Empty keeps accounts available around the clock.
+	ActiveHours string `yaml:"active-hours,omitempty" json:"active-hours,omitempty"`
+
+	// Timezone is the IANA time zone of ActiveHours. Empty means UTC.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// ProxyPool lists fallback proxy URLs for Gemini Web. When Gemini Web blocks the address
+	// an account sends from, the account moves to the next proxy of the list, signs in again
+	// through it and retries the request, instead of only cooling down. Empty disables it.
+	ProxyPool []string `yaml:"proxy-pool,omitempty" json:"proxy-pool,omitempty"`
+}
+
+// Values of GeminiWebConfig.EmptyPrompt.
+const (
+	EmptyPromptError       = "error"
+	EmptyPromptPlaceholder = "placeholder"
+	EmptyPromptEmpty       = "empty"
+)
+
+// Values of GeminiWebConfig.ToolSchemas.
+const (
+	ToolSchemasDrop    = "drop"
+	ToolSchemasFull    = "full"
+	ToolSchemasCompact = "compact"
+)
+
+// ResponseLanguageConfig nests response language options under 'response-language'.
+type ResponseLanguageConfig struct {
+	// Default is the language applied to every client API key without an override.
+	// Empty disables the instruction.
+	Default string `yaml:"default" json:"default"`
+
+	// APIKeys overrides Default per client API key. An empty value opts the key out.
+	APIKeys map[string]string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+}
+
+// ModelPolicyConfig nests the per client API key model restrictions under 'model-policy'.
+type ModelPolicyConfig struct {
+	// APIKeys maps client API keys to their policy. Keys without one may use every model.
+	APIKeys map[string]ModelPolicy `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+}
+
+// ModelPolicy restricts the models one client API key may use. Models are matched by their
+// registered ID, after case and aliases are resolved, against glob patterns such as
+// "gemini-2.5-*", case-insensitively.
+type ModelPolicy struct {
+	// AllowedModels lists the models the key may use. Empty allows every model.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// DeniedModels lists models the key may not use, even when AllowedModels matches them.
+	DeniedModels []string `yaml:"denied-models,omitempty" json:"denied-models,omitempty"`
+
+	// DefaultModel is used for requests of the key that leave the model out.
+	DefaultModel string `yaml:"default-model,omitempty" json:"default-model,omitempty"`
+}
+
+// For returns the policy of apiKey and whether it has one.
+func (c ModelPolicyConfig) For(apiKey string) (ModelPolicy, bool) {
+	policy, ok := c.APIKeys[apiKey]
+	return policy, ok
+}
+
+// Denial names the setting of the policy that forbids model, or returns "" when model is
+// allowed.
+func (p ModelPolicy) Denial(model string) string {
+	for _, pattern := range p.DeniedModels {
+		if matchModelPattern(pattern, model) {
+			return fmt.Sprintf("denied-models entry %q", pattern)
+		}
+	}
+	if len(p.AllowedModels) == 0 {
+		return ""
+	}
+	for _, pattern := range p.AllowedModels {
+		if matchModelPattern(pattern, model) {
+			return ""
+		}
+	}
+	return "allowed-models, no entry matches"
+}
+
+// matchModelPattern reports whether model matches pattern, in which '*' stands for any run
+// of characters, ignoring case.
+func matchModelPattern(pattern, model string) bool {
+	return wildcard.Match(strings.ToLower(strings.TrimSpace(pattern)), strings.ToLower(model))
+}
+
+// StreamingConfig nests streaming flow control options under 'streaming'.
+//
+// Every streaming response is forwarded through a bounded buffer. When the
+// downstream client reads slower than the upstream produces, the buffer fills
+// and the producer blocks (backpressure) instead of growing memory. Chunks are
+// never dropped, because SSE consumers cannot recover from gaps. If the producer
+// stays blocked longer than SlowConsumerTimeout, the request is cancelled and
+// the upstream connection is released.
+type StreamingConfig struct {
+	// BufferSize is the number of chunks buffered per stream before backpressure applies.
+	// When unset or <=0, a default of 64 is used.
+	BufferSize int `yaml:"buffer-size,omitempty" json:"buffer-size,omitempty"`
+
+	// SlowConsumerTimeout is the number of seconds a producer may stay blocked on a
+	// full buffer before the request is cancelled. When unset or <=0, a default of 60 is used.
+	SlowConsumerTimeout int `yaml:"slow-consumer-timeout,omitempty" json:"slow-consumer-timeout,omitempty"`
+
+	// BufferOnFlushError holds back the rest of a response once flushing it to the client
+	// fails, and writes it as one final piece when the response is complete. At most 8 MiB
+	// are held; past that the response is relayed as usual.
+	BufferOnFlushError bool `yaml:"buffer-on-flush-error,omitempty" json:"buffer-on-flush-error,omitempty"`
+}
+
+// ProvidersConfig nests the built-in providers under 'providers'.
+type ProvidersConfig struct {
+	// Echo is a provider answering every request with the request itself, for testing a
+	// deployment without spending quota.
+	Echo EchoProviderConfig `yaml:"echo" json:"echo"`
+}
+
+// EchoProviderConfig configures the echo provider under 'providers.echo'.
+type EchoProviderConfig struct {
+	// Enabled registers the cliproxy-echo model.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// ChunkSize is the number of bytes of the echo sent per stream chunk. 0 uses 32.
+	ChunkSize int `yaml:"chunk-size,omitempty" json:"chunk-size,omitempty"`
+
+	// ChunkDelay is the number of milliseconds between stream chunks.
+	ChunkDelay int `yaml:"chunk-delay,omitempty" json:"chunk-delay,omitempty"`
+
+	// Timeout is the number of seconds a request starting with "!timeout" hangs before it
+	// fails with 504, unless the request is cancelled first. 0 uses 30.
+	Timeout int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 }
 
 // RemoteManagement holds management API configuration under 'remote-management'.
@@ -128,6 +539,153 @@ type RemoteManagement struct {
 	AllowRemote bool `yaml:"allow-remote"`
 	// SecretKey is the management key (plaintext or bcrypt hashed). YAML key intentionally 'secret-key'.
 	SecretKey string `yaml:"secret-key"`
+	// Keys lists further management keys, each limited to a role. SecretKey stays an admin key.
+	Keys []ManagementKey `yaml:"keys,omitempty"`
+	// Leader makes this server a follower that forwards mutating management calls to a leader.
+	Leader ManagementLeader `yaml:"leader,omitempty"`
+}
+
+// ManagementLeader names the server that accepts management changes for a follower.
+type ManagementLeader struct {
+	// URL is the base URL of the leader, e.g. https://leader:8317. Empty serves every call here.
+	URL string `yaml:"url"`
+	// SecretKey is the plaintext management key the follower sends to the leader. It is not
+	// hashed at load, since it has to be sent as it is.
+	SecretKey string `yaml:"secret-key"`
+}
+
+// Roles of a ManagementKey.
+const (
+	// ManagementRoleAdmin may call every management endpoint.
+	ManagementRoleAdmin = "admin"
+	// ManagementRoleReadOnly may call endpoints that change nothing and reveal no credentials.
+	ManagementRoleReadOnly = "read-only"
+)
+
+// ManagementKey is one labelled management key under 'remote-management.keys'.
+type ManagementKey struct {
+	// Label names the key in audit logs and key listings.
+	Label string `yaml:"label"`
+	// Key is the key itself, plaintext or bcrypt hashed. Plaintext keys are hashed at load.
+	Key string `yaml:"key"`
+	// Role is admin or read-only. It is ignored when Groups is set.
+	Role string `yaml:"role,omitempty"`
+	// Groups lists the endpoint groups the key may call, each the first path segment after
+	// /v0/management (e.g. usage, auth-files, quota-status).
+	Groups []string `yaml:"groups,omitempty"`
+}
+
+// AdminUIConfig nests admin web UI options under 'admin-ui'.
+type AdminUIConfig struct {
+	// Enable serves the UI. It requires remote-management.secret-key to be set.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Path is the URL prefix the UI is served under. Defaults to "/admin".
+	Path string `yaml:"path" json:"path"`
+}
+
+// LogRotationConfig bounds the disk space used by log files. Zero fields mean no limit.
+type LogRotationConfig struct {
+	// MaxSize is in megabytes. For the main log it is the size at which the file is rotated;
+	// for request logs it caps the total size of all request log files.
+	MaxSize int `yaml:"max-size" json:"max-size"`
+
+	// MaxBackups is the number of rotated main logs, or of request log files, to keep.
+	MaxBackups int `yaml:"max-backups" json:"max-backups"`
+
+	// MaxAge is the number of days old log files are kept.
+	MaxAge int `yaml:"max-age" json:"max-age"`
+
+	// Compress gzips rotated main logs and finished request logs.
+	Compress bool `yaml:"compress" json:"compress"`
+}
+
+// DeadLetterConfig names where requests that failed on every account tried are recorded.
+// Each is one JSON object holding the request metadata, the error of every attempt and the
+// final status, with secrets redacted. Either destination may be empty; both empty disables it.
+type DeadLetterConfig struct {
+	// File is a JSON Lines file the entries are appended to.
+	File string `yaml:"file" json:"file"`
+
+	// URL receives every entry as a JSON POST.
+	URL string `yaml:"url" json:"url"`
+}
+
+// MirroringConfig nests shadow traffic under 'mirroring'. A sampled request is replayed,
+// without streaming, against the target of the first matching rule once its response has
+// been sent. The client never sees the shadow response or its errors.
+type MirroringConfig struct {
+	// MaxConcurrency bounds the shadow requests running at once; requests sampled beyond it
+	// are not mirrored. 0 uses 4.
+	MaxConcurrency int `yaml:"max-concurrency" json:"max-concurrency"`
+
+	// Timeout is the number of seconds a shadow request may take. 0 uses 300.
+	Timeout int `yaml:"timeout" json:"timeout"`
+
+	// Rules lists the traffic to mirror. The first rule matching a request decides.
+	Rules []MirrorRule `yaml:"rules" json:"rules"`
+}
+
+// MirrorRule mirrors a percentage of the requests of some API keys or models to a target.
+type MirrorRule struct {
+	// APIKeys restricts the rule to these client API keys. Empty matches every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Models restricts the rule to these requested models. Empty matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Provider is the provider the shadow requests are sent to, such as "claude".
+	Provider string `yaml:"provider" json:"provider"`
+
+	// Model is the model the shadow requests ask for.
+	Model string `yaml:"model" json:"model"`
+
+	// Percent is the share of matching requests mirrored, from 0 to 100.
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// Match returns the first rule covering a request of apiKey for model.
+func (c MirroringConfig) Match(apiKey, model string) (MirrorRule, bool) {
+	for _, rule := range c.Rules {
+		if len(rule.APIKeys) > 0 && !slices.Contains(rule.APIKeys, apiKey) {
+			continue
+		}
+		if len(rule.Models) > 0 && !slices.Contains(rule.Models, model) {
+			continue
+		}
+		return rule, true
+	}
+	return MirrorRule{}, false
+}
+
+// RetryBudgetConfig nests the per-request retry limits under 'retry-budget'. Every account
+// tried counts against them, whether it was switched to because of a quota or another error.
+type RetryBudgetConfig struct {
+	// MaxAttempts is the number of upstream attempts a request may make. 0 tries every
+	// eligible account.
+	MaxAttempts int `yaml:"max-attempts" json:"max-attempts"`
+
+	// Deadline is the number of seconds after which no further attempt is started and the
+	// one waiting for the upstream is cancelled. Responses already streaming are not cut. 0
+	// disables it.
+	Deadline int `yaml:"deadline" json:"deadline"`
+}
+
+// AuthRemovalConfig nests the teardown of deleted auth files under 'auth-removal'. A deleted
+// auth is disabled at once, but its state is kept until the file has stayed gone for the
+// grace period and the requests it was serving have finished.
+type AuthRemovalConfig struct {
+	// Grace is the number of seconds a deleted file may take to reappear and keep its auth.
+	// 0 uses 5 seconds.
+	Grace int `yaml:"grace" json:"grace"`
+
+	// DrainTimeout is the number of seconds the teardown waits for in-flight requests. 0
+	// uses 300 seconds.
+	DrainTimeout int `yaml:"drain-timeout" json:"drain-timeout"`
+
+	// CleanupOrphanedArtifacts also deletes the files kept for a torn-down auth, which is its
+	// Gemini Web conversation store.
+	CleanupOrphanedArtifacts bool `yaml:"cleanup-orphaned-artifacts" json:"cleanup-orphaned-artifacts"`
 }
 
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
@@ -151,6 +709,25 @@ type ClaudeKey struct {
 	BaseURL string `yaml:"base-url" json:"base-url"`
 }
 
+// ClaudeBetaConfig nests the Claude beta options under 'claude-beta'.
+type ClaudeBetaConfig struct {
+	// Models adds anthropic-beta values to requests per model ID, after the built-in values and
+	// those sent by an Anthropic client.
+	Models map[string][]string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// DisableQuery stops adding ?beta=true to Messages API URLs.
+	DisableQuery bool `yaml:"disable-query,omitempty" json:"disable-query,omitempty"`
+}
+
+// PassthroughConfig nests the raw passthrough options under 'passthrough'. A passed-through
+// request body must already be in the native format of the provider serving the model.
+type PassthroughConfig struct {
+	// AllowHeader lets clients ask for a passthrough with the X-Passthrough: true header.
+	AllowHeader bool `yaml:"allow-header" json:"allow-header"`
+	// Models lists model IDs whose requests are always passed through.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
 // CodexKey represents the configuration for a Codex API key,
 // including the API key itself and an optional base URL for the API endpoint.
 type CodexKey struct {
@@ -188,6 +765,466 @@ type OpenAICompatibilityModel struct {
 	Alias string `yaml:"alias" json:"alias"`
 }
 
+// ModelCapability overrides capability metadata of one model. Unset fields keep the
+// built-in value.
+type ModelCapability struct {
+	// ContextLength is the context window size in tokens.
+	ContextLength *int `yaml:"context-length,omitempty" json:"context-length,omitempty"`
+
+	// MaxOutputTokens is the maximum number of tokens the model generates per response.
+	MaxOutputTokens *int `yaml:"max-output-tokens,omitempty" json:"max-output-tokens,omitempty"`
+
+	// MaxInputTokens is the largest estimated prompt accepted for the model. Longer requests
+	// are rejected with a 400 before reaching the upstream. Zero accepts any size.
+	MaxInputTokens *int `yaml:"max-input-tokens,omitempty" json:"max-input-tokens,omitempty"`
+
+	// SupportsVision reports whether the model accepts image input.
+	SupportsVision *bool `yaml:"supports-vision,omitempty" json:"supports-vision,omitempty"`
+
+	// SupportsTools reports whether the model supports tool or function calling.
+	SupportsTools *bool `yaml:"supports-tools,omitempty" json:"supports-tools,omitempty"`
+
+	// SupportsStreaming reports whether the model can stream responses.
+	SupportsStreaming *bool `yaml:"supports-streaming,omitempty" json:"supports-streaming,omitempty"`
+
+	// OwnedBy replaces the owned_by value listed for the model, which otherwise names the
+	// provider serving it.
+	OwnedBy string `yaml:"owned-by,omitempty" json:"owned-by,omitempty"`
+}
+
+// RAGConfig nests document store options under 'rag'. Clients opt in per request with the
+// X-CLIProxy-RAG header or a "rag" body field naming one of their stores.
+type RAGConfig struct {
+	// Enable turns on the document store endpoints and request augmentation.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// EmbeddingModel is the embedding-capable model used to index and query documents.
+	// When empty, "gemini-embedding-001" is used.
+	EmbeddingModel string `yaml:"embedding-model,omitempty" json:"embedding-model,omitempty"`
+
+	// TopK is the number of chunks injected per request. When unset or <=0, 4 is used.
+	TopK int `yaml:"top-k,omitempty" json:"top-k,omitempty"`
+
+	// ChunkSize is the chunk length in characters. When unset or <=0, 1000 is used.
+	ChunkSize int `yaml:"chunk-size,omitempty" json:"chunk-size,omitempty"`
+
+	// ChunkOverlap is the number of characters repeated between consecutive chunks.
+	ChunkOverlap int `yaml:"chunk-overlap,omitempty" json:"chunk-overlap,omitempty"`
+
+	// MaxDocumentBytes caps the size of one document. When unset or <=0, 262144 is used.
+	MaxDocumentBytes int `yaml:"max-document-bytes,omitempty" json:"max-document-bytes,omitempty"`
+
+	// MaxDocuments caps the number of documents per store. When unset or <=0, 100 is used.
+	MaxDocuments int `yaml:"max-documents,omitempty" json:"max-documents,omitempty"`
+}
+
+// ModelAffinity designates the accounts preferred for a set of models. Entries are checked
+// in order and the first one whose models match a request applies.
+type ModelAffinity struct {
+	// Models lists model names the entry applies to; '*' matches any run of characters,
+	// e.g. "gemini-*-pro*".
+	Models []string `yaml:"models" json:"models"`
+
+	// Auths lists the preferred accounts by ID or auth file name, '*' wildcards allowed, so
+	// a naming prefix can designate a group of accounts.
+	Auths []string `yaml:"auths" json:"auths"`
+}
+
+// MaintenanceWindow is one recurring maintenance period, matching either every account of a
+// provider or a single account.
+type MaintenanceWindow struct {
+	// Provider matches every account of the provider, e.g. "claude".
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Auth matches one account by its ID or auth file name.
+	Auth string `yaml:"auth,omitempty" json:"auth,omitempty"`
+
+	// Days lists the weekdays the window starts on ("mon" ... "sun"). Empty means every day.
+	Days []string `yaml:"days,omitempty" json:"days,omitempty"`
+
+	// Start and End are HH:MM wall clock times. An End not after Start ends the next day.
+	Start string `yaml:"start" json:"start"`
+	End   string `yaml:"end" json:"end"`
+
+	// Timezone is an IANA time zone name. When empty, UTC is used.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+}
+
+// ToolResultLimitConfig nests the tool result size guard under 'tool-result-limit'. Sizes
+// count the bytes of tool result text; zero disables a limit.
+type ToolResultLimitConfig struct {
+	// MaxBytes limits each tool result text part.
+	MaxBytes int `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+
+	// MaxRequestBytes limits the tool result text of a whole request.
+	MaxRequestBytes int `yaml:"max-request-bytes,omitempty" json:"max-request-bytes,omitempty"`
+
+	// Strategy handles results over a limit: "truncate" (default) keeps their head and tail
+	// around a marker, "reject" fails the request with 413 and "summarize" replaces them with
+	// a summary written by SummaryModel.
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// SummaryModel is the model used by the summarize strategy. When empty,
+	// "gemini-2.5-flash-lite" is used.
+	SummaryModel string `yaml:"summary-model,omitempty" json:"summary-model,omitempty"`
+
+	// Providers overrides the limits per provider identifier (e.g. "claude", "gemini-web").
+	Providers map[string]ToolResultLimits `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// ToolResultLimits are the limits of one provider. Unset fields use the defaults.
+type ToolResultLimits struct {
+	MaxBytes        int `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+	MaxRequestBytes int `yaml:"max-request-bytes,omitempty" json:"max-request-bytes,omitempty"`
+}
+
+// Values of ToolResultLimitConfig.Strategy.
+const (
+	ToolResultTruncate  = "truncate"
+	ToolResultReject    = "reject"
+	ToolResultSummarize = "summarize"
+)
+
+// Limits returns the limits for a request that may be served by any of providers: the
+// strictest of their limits, each provider falling back to the defaults.
+func (c ToolResultLimitConfig) Limits(providers []string) ToolResultLimits {
+	limits := ToolResultLimits{MaxBytes: c.MaxBytes, MaxRequestBytes: c.MaxRequestBytes}
+	if len(providers) == 0 {
+		return limits
+	}
+	var strictest ToolResultLimits
+	for i, provider := range providers {
+		own := limits
+		if override, ok := c.Providers[provider]; ok {
+			if override.MaxBytes > 0 {
+				own.MaxBytes = override.MaxBytes
+			}
+			if override.MaxRequestBytes > 0 {
+				own.MaxRequestBytes = override.MaxRequestBytes
+			}
+		}
+		if i == 0 {
+			strictest = own
+			continue
+		}
+		strictest.MaxBytes = stricterLimit(strictest.MaxBytes, own.MaxBytes)
+		strictest.MaxRequestBytes = stricterLimit(strictest.MaxRequestBytes, own.MaxRequestBytes)
+	}
+	return strictest
+}
+
+func stricterLimit(a, b int) int {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// ResponseStoreConfig nests the Responses API store options under 'response-store'.
+type ResponseStoreConfig struct {
+	// Enable stores every Responses API result whose request does not set "store": false.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxAge is the number of days a stored response is kept. When unset or <=0, 30 is used.
+	MaxAge int `yaml:"max-age,omitempty" json:"max-age,omitempty"`
+}
+
+// ResponseTagConfig nests the audit tag options under 'response-tag'.
+type ResponseTagConfig struct {
+	// Enable turns tagging on for the client API keys listed in APIKeys.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Template is the tag text. {request_id}, {timestamp} and {key} are replaced with a
+	// reference generated for the request, the RFC 3339 time it was served and the key
+	// label. When empty, "[ref:{request_id}]" is used.
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+
+	// ZeroWidth encodes the tag in zero-width characters so it does not show in rendered
+	// text. Otherwise it is appended as a plain text footer.
+	ZeroWidth bool `yaml:"zero-width,omitempty" json:"zero-width,omitempty"`
+
+	// APIKeys maps each client API key whose responses are tagged to the label used for {key}.
+	APIKeys map[string]string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Formats limits tagging to these client formats: "openai", "openai-response", "claude",
+	// "gemini" and "gemini-cli". When empty, all of them are tagged.
+	Formats []string `yaml:"formats,omitempty" json:"formats,omitempty"`
+}
+
+// Values of ResponseFooterConfig.Position.
+const (
+	FooterAppend  = "append"
+	FooterPrepend = "prepend"
+)
+
+// RequestQueueConfig nests the concurrency limit and request queue options under
+// 'request-queue'.
+type RequestQueueConfig struct {
+	// MaxConcurrent is the number of API requests served at once. Zero disables the limit.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
+
+	// MaxQueued is the number of requests that may wait for a slot; a request arriving when
+	// the queue is full gets a 503 at once. Zero rejects every request over the limit.
+	MaxQueued int `yaml:"max-queued,omitempty" json:"max-queued,omitempty"`
+
+	// MaxWait is how long in seconds a request waits for a slot before it gets a 503.
+	// Defaults to 30.
+	MaxWait int `yaml:"max-wait,omitempty" json:"max-wait,omitempty"`
+
+	// Weights maps client API keys to their share of the slots while requests are queued.
+	// Keys not listed weigh 1.
+	Weights map[string]int `yaml:"weights,omitempty" json:"weights,omitempty"`
+
+	// ReservedFraction is the share of max-concurrent kept for high-priority requests:
+	// low-priority requests queue once only that many slots are free, and may fill only the
+	// rest of max-queued in the same proportion. At least one slot and one queue place are
+	// always left to low-priority requests. Zero reserves nothing.
+	ReservedFraction float64 `yaml:"reserved-fraction,omitempty" json:"reserved-fraction,omitempty"`
+
+	// LowPriorityKeys lists the client API keys whose requests are low priority, such as
+	// batch jobs. Requests of other keys are high priority unless they send
+	// "X-CLIProxy-Priority: low".
+	LowPriorityKeys []string `yaml:"low-priority-keys,omitempty" json:"low-priority-keys,omitempty"`
+}
+
+// CaptureConfig nests the exchange capture options under 'capture'.
+type CaptureConfig struct {
+	// Enable writes every successful API request, with the upstream request and response
+	// it caused and the response the client got, to a file in Dir. Secrets are masked.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Dir is the directory the captures are written to, relative to the configuration file
+	// unless absolute. Defaults to "captures".
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+}
+
+// ModelVersionsConfig nests the model version tracking options under 'model-versions'.
+type ModelVersionsConfig struct {
+	// Enable records, per provider and model, the identity responses report (Gemini's
+	// modelVersion, Claude's model, the OpenAI model and system_fingerprint) in the auth
+	// dir and logs a warning when it changes.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// WebhookURL receives a JSON POST for every change. Empty disables the webhook.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+}
+
+// ResponseFooterConfig nests the fixed response text options under 'response-footer'.
+type ResponseFooterConfig struct {
+	// Text is added to the assistant text of every response. Empty disables the footer.
+	Text string `yaml:"text,omitempty" json:"text,omitempty"`
+
+	// Position is "append" to add Text after the answer or "prepend" to add it in front.
+	// Defaults to "append".
+	Position string `yaml:"position,omitempty" json:"position,omitempty"`
+
+	// SkipAPIKeys lists client API keys whose responses are left unchanged.
+	SkipAPIKeys []string `yaml:"skip-api-keys,omitempty" json:"skip-api-keys,omitempty"`
+
+	// SkipModels lists requested model IDs whose responses are left unchanged.
+	SkipModels []string `yaml:"skip-models,omitempty" json:"skip-models,omitempty"`
+}
+
+// PricingConfig nests the token prices under 'pricing'.
+type PricingConfig struct {
+	// Header sends the cost of non-streaming responses in the X-Request-Cost header.
+	Header bool `yaml:"header,omitempty" json:"header,omitempty"`
+
+	// Models lists the token prices; the first entry matching a request applies.
+	Models []ModelPrice `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// ModelPrice prices the tokens of the matching models in dollars per million tokens.
+type ModelPrice struct {
+	// Model is the model ID, or a pattern such as "gpt-5*" where '*' stands for any run of
+	// characters, matched case-insensitively.
+	Model string `yaml:"model" json:"model"`
+
+	// Provider limits the entry to one provider, such as "claude" or "gemini-cli".
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Input is the price of prompt tokens.
+	Input float64 `yaml:"input" json:"input"`
+
+	// Output is the price of generated tokens, reasoning included.
+	Output float64 `yaml:"output" json:"output"`
+
+	// CachedInput is the price of prompt tokens read from a cache. Zero prices them as Input.
+	CachedInput float64 `yaml:"cached-input,omitempty" json:"cached-input,omitempty"`
+
+	// CacheWriteInput is the price of prompt tokens written to a cache, which Claude reports
+	// as cache creation. Zero prices them as Input.
+	CacheWriteInput float64 `yaml:"cache-write-input,omitempty" json:"cache-write-input,omitempty"`
+}
+
+// UpstreamTransportConfig nests the upstream connection options under 'upstream-transport'.
+// Zero fields keep the built-in defaults.
+type UpstreamTransportConfig struct {
+	// HTTP2 selects the protocol spoken to upstreams over TLS: "auto" uses HTTP/2 where the
+	// upstream offers it, "force" only offers HTTP/2 and keeps a single multiplexed
+	// connection per host, "off" stays on HTTP/1.1. Defaults to "auto".
+	HTTP2 string `yaml:"http2,omitempty" json:"http2,omitempty"`
+
+	// MaxIdleConnsPerHost is the number of idle connections kept per host. Defaults to 16.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+
+	// MaxConnsPerHost caps the connections per host, including those in use. Zero means no
+	// limit.
+	MaxConnsPerHost int `yaml:"max-conns-per-host,omitempty" json:"max-conns-per-host,omitempty"`
+
+	// IdleConnTimeout is the number of seconds an idle connection is kept. Defaults to 90.
+	IdleConnTimeout int `yaml:"idle-conn-timeout,omitempty" json:"idle-conn-timeout,omitempty"`
+
+	// TLSHandshakeTimeout is the number of seconds a TLS handshake may take. Defaults to 10.
+	TLSHandshakeTimeout int `yaml:"tls-handshake-timeout,omitempty" json:"tls-handshake-timeout,omitempty"`
+
+	// Providers overrides the fields above per provider identifier (e.g. "claude",
+	// "gemini-cli"). Providers nested inside an override are ignored.
+	Providers map[string]UpstreamTransportConfig `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// Values of UpstreamTransportConfig.HTTP2.
+const (
+	HTTP2Auto  = "auto"
+	HTTP2Force = "force"
+	HTTP2Off   = "off"
+)
+
+// Values of Config.UnknownRequestFields.
+const (
+	UnknownFieldsStrip = "strip"
+	UnknownFieldsKeep  = "keep"
+)
+
+// SafetySettingsConfig nests the Gemini safety threshold options under 'safety-settings'.
+type SafetySettingsConfig struct {
+	// Defaults are the thresholds of every client API key.
+	Defaults []SafetySetting `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+
+	// APIKeys maps client API keys to thresholds replacing the defaults of the same
+	// categories.
+	APIKeys map[string][]SafetySetting `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// EnforceFloor treats the configured thresholds as a floor: a client threshold blocking
+	// less than the configured one for its category is raised to it. Otherwise thresholds
+	// sent by the client win.
+	EnforceFloor bool `yaml:"enforce-floor,omitempty" json:"enforce-floor,omitempty"`
+}
+
+// SafetySetting is the Gemini block threshold of one harm category, e.g.
+// HARM_CATEGORY_DANGEROUS_CONTENT and BLOCK_ONLY_HIGH.
+type SafetySetting struct {
+	Category  string `yaml:"category" json:"category"`
+	Threshold string `yaml:"threshold" json:"threshold"`
+}
+
+// StrictOpenAIConfig nests the strict OpenAI request options under 'strict-openai'.
+type StrictOpenAIConfig struct {
+	// Enable checks the OpenAI requests of the keys in APIKeys.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// APIKeys lists the client API keys whose requests are checked. When empty, every key is.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+}
+
+// ClientCredentialsConfig nests the options for client-supplied upstream keys under
+// 'client-credentials'.
+type ClientCredentialsConfig struct {
+	// Enable accepts X-Provider-Key from the keys in APIKeys for the providers in Providers.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Providers lists the providers a key may be supplied for: "claude", "gemini", "codex"
+	// or the name of an openai-compatibility entry. When empty, all of them.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// APIKeys lists the client API keys that may supply a key. When empty, every key may.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+}
+
+// ModerationConfig nests the streamed output moderation options under 'moderation'.
+type ModerationConfig struct {
+	// Enable moderates the streams served to the keys in APIKeys.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// APIKeys lists the client API keys whose streams are moderated. When empty, every key is.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Keywords are blocked wherever they appear, ignoring case.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+
+	// Patterns are blocked regular expressions (RE2 syntax).
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Window is the number of characters of earlier output checked along with each chunk, so
+	// matches spanning chunks are caught. When unset or <=0, 256 is used.
+	Window int `yaml:"window,omitempty" json:"window,omitempty"`
+
+	// LatencyBudget is the time in milliseconds a check may take. Checks taking longer are
+	// skipped and counted. The external classifier runs beside the stream, so only the rules
+	// hold chunks back. When unset or <=0, 200 is used.
+	LatencyBudget int `yaml:"latency-budget-ms,omitempty" json:"latency-budget-ms,omitempty"`
+
+	// External sends the output to an HTTP classifier in addition to the rules above.
+	External ModerationExternalConfig `yaml:"external,omitempty" json:"external,omitempty"`
+}
+
+// ModerationExternalConfig configures the HTTP classifier of moderation.
+type ModerationExternalConfig struct {
+	// URL receives POST {"input": text} and answers {"flagged": bool, "rule": string}.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// Headers are sent with every classifier request, e.g. an Authorization header.
+	Headers map[string]string `yaml:"headers,omitempty" json:"-"`
+
+	// BatchSize is the number of new characters collected before the classifier is called.
+	// When unset or <=0, 256 is used.
+	BatchSize int `yaml:"batch-size,omitempty" json:"batch-size,omitempty"`
+}
+
+// OAuthSuccessPageConfig nests the OAuth callback page options under 'oauth-success-page'.
+type OAuthSuccessPageConfig struct {
+	// HTML is the page body shown once the login callback was received. When empty, a short
+	// "Authentication successful" notice is shown.
+	HTML string `yaml:"html,omitempty" json:"html,omitempty"`
+
+	// RedirectURL sends the browser on to this address, e.g. a dashboard.
+	RedirectURL string `yaml:"redirect-url,omitempty" json:"redirect-url,omitempty"`
+
+	// AutoClose closes the browser window, unless RedirectURL is set.
+	AutoClose bool `yaml:"auto-close,omitempty" json:"auto-close,omitempty"`
+
+	// Delay is the number of seconds the page is shown before redirecting or closing.
+	Delay int `yaml:"delay,omitempty" json:"delay,omitempty"`
+}
+
+// OutputTokenCapConfig nests output token caps under 'max-output-tokens'. The most specific
+// entry wins: a model cap over a provider cap over the default. Zero means no cap.
+type OutputTokenCapConfig struct {
+	// Default applies to every request without a more specific cap.
+	Default int `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// Providers caps requests per provider identifier (e.g. "qwen", "gemini-web").
+	Providers map[string]int `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Models caps requests per model ID.
+	Models map[string]int `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// Limit returns the output token cap for a request to model served by provider, or zero
+// when the request is not capped.
+func (c OutputTokenCapConfig) Limit(provider, model string) int {
+	if limit, ok := c.Models[model]; ok && limit > 0 {
+		return limit
+	}
+	if limit, ok := c.Providers[provider]; ok && limit > 0 {
+		return limit
+	}
+	if c.Default > 0 {
+		return c.Default
+	}
+	return 0
+}
+
 // LoadConfig reads a YAML configuration file from the given path,
 // unmarshals it into a Config struct, applies environment variable overrides,
 // and returns it.
@@ -199,20 +1236,63 @@ type OpenAICompatibilityModel struct {
 //   - *Config: The loaded configuration
 //   - error: An error if the configuration could not be loaded
 func LoadConfig(configFile string) (*Config, error) {
+	return LoadConfigWithOverrides(configFile, nil)
+}
+
+// LoadConfigWithOverrides loads configFile like LoadConfig, then applies each override file
+// on top of it in order. See applyOverlay for how an override file changes the base.
+func LoadConfigWithOverrides(configFile string, overrides []string) (*Config, error) {
 	// Read the entire configuration file into memory.
 	data, err := os.ReadFile(configFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	var sources []overrideSource
+	for _, file := range overrides {
+		overlayData, errRead := os.ReadFile(file)
+		if errRead != nil {
+			return nil, fmt.Errorf("failed to read override file: %w", errRead)
+		}
+		var overlay yaml.Node
+		if errParse := yaml.Unmarshal(overlayData, &overlay); errParse != nil {
+			return nil, fmt.Errorf("failed to parse override file %s: %w", file, errParse)
+		}
+		if sources, err = applyOverlay(&root, &overlay, file, sources); err != nil {
+			return nil, err
+		}
+	}
 
 	// Unmarshal the YAML data into the Config struct.
 	var config Config
 	// Set defaults before unmarshal so that absent keys keep defaults.
 	config.LoggingToFile = true
+	config.LogRotation.MaxSize = 10
 	config.UsageStatisticsEnabled = true
 	config.GeminiWeb.Context = true
-	if err = yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	config.GeminiWeb.WarmStandby = 1
+	config.GeminiWeb.InitMaxRetries = 12
+	config.RequestValidation = true
+	config.RecentFailureWindow = 5
+	if root.Kind != 0 {
+		if err = root.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+	if len(overrides) > 0 {
+		config.OverrideFiles = append([]string(nil), overrides...)
+		config.OverrideSources = make(map[string]string, len(sources))
+		for _, source := range sources {
+			config.OverrideSources[strings.Join(source.path, ".")] = source.file
+			if source.cleared {
+				clearField(reflect.ValueOf(&config), source.path)
+			}
+		}
+		config.overridden = sources
 	}
 
 	// Hash remote management key if plaintext is detected (nested)
@@ -225,17 +1305,54 @@ func LoadConfig(configFile string) (*Config, error) {
 		config.RemoteManagement.SecretKey = hashed
 
 		// Persist the hashed value back to the config file to avoid re-hashing on next startup.
-		// Preserve YAML comments and ordering; update only the nested key.
-		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		// Preserve YAML comments and ordering; update only the nested key. A key set by an
+		// override file is written back there.
+		keyFile := configFile
+		if file := config.OverrideSources["remote-management.secret-key"]; file != "" {
+			keyFile = file
+		}
+		_ = SaveConfigPreserveCommentsUpdateNestedScalar(keyFile, []string{"remote-management", "secret-key"}, hashed)
+	}
+
+	// Labelled management keys are hashed and persisted the same way, in the file that set
+	// the list.
+	hashedKeys := make(map[string]string)
+	for i := range config.RemoteManagement.Keys {
+		entry := &config.RemoteManagement.Keys[i]
+		if entry.Key != "" && !looksLikeBcrypt(entry.Key) {
+			hashed, errHash := hashSecret(entry.Key)
+			if errHash != nil {
+				return nil, fmt.Errorf("failed to hash management key %q: %w", entry.Label, errHash)
+			}
+			hashedKeys[entry.Key] = hashed
+			entry.Key = hashed
+		}
+	}
+	if len(hashedKeys) > 0 {
+		keysFile := configFile
+		if file := config.OverrideSources["remote-management.keys"]; file != "" {
+			keysFile = file
+		}
+		_ = saveHashedManagementKeys(keysFile, hashedKeys)
 	}
 
 	// Sync request authentication providers with inline API keys for backwards compatibility.
 	syncInlineAccessProvider(&config)
+	snapshotOverrides(&config)
 
 	// Return the populated configuration struct.
 	return &config, nil
 }
 
+// UserAgent returns the user agent configured for provider, or empty when it keeps the
+// built-in one.
+func (c *Config) UserAgent(provider string) string {
+	if c == nil {
+		return ""
+	}
+	return strings.TrimSpace(c.UserAgents[provider])
+}
+
 // SyncInlineAPIKeys updates the inline API key provider and top-level APIKeys field.
 func SyncInlineAPIKeys(cfg *Config, keys []string) {
 	if cfg == nil {
@@ -357,6 +1474,11 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 		return fmt.Errorf("expected generated root mapping node")
 	}
 
+	// Fields coming from override files stay as they are in this file.
+	for _, source := range cfg.overridden {
+		removeNodePath(generated.Content[0], source.path)
+	}
+
 	// Merge generated into original in-place, preserving comments/order of existing nodes.
 	mergeMappingPreserve(original.Content[0], generated.Content[0])
 
@@ -421,6 +1543,61 @@ func SaveConfigPreserveCommentsUpdateNestedScalar(configFile string, path []stri
 	return enc.Close()
 }
 
+// saveHashedManagementKeys replaces the plaintext keys of remote-management.keys in
+// configFile with the hashes in hashed, keyed by plaintext, preserving comments and order.
+func saveHashedManagementKeys(configFile string, hashed map[string]string) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return fmt.Errorf("invalid yaml document structure")
+	}
+	rm := root.Content[0]
+	idx := findMapKeyIndex(rm, "remote-management")
+	if idx < 0 {
+		return nil
+	}
+	rm = rm.Content[idx+1]
+	idx = findMapKeyIndex(rm, "keys")
+	if idx < 0 || rm.Content[idx+1].Kind != yaml.SequenceNode {
+		return nil
+	}
+	changed := false
+	for _, item := range rm.Content[idx+1].Content {
+		keyIdx := findMapKeyIndex(item, "key")
+		if keyIdx < 0 {
+			continue
+		}
+		value := item.Content[keyIdx+1]
+		if h, ok := hashed[value.Value]; ok && value.Kind == yaml.ScalarNode {
+			value.Value = h
+			value.Tag = "!!str"
+			value.Style = 0
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	f, err := os.Create(configFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	enc := yaml.NewEncoder(f)
+	enc.SetIndent(2)
+	if err = enc.Encode(&root); err != nil {
+		_ = enc.Close()
+		return err
+	}
+	return enc.Close()
+}
+
 // getOrCreateMapValue finds the value node for a given key in a mapping node.
 // If not found, it appends a new key/value pair and returns the new value node.
 func getOrCreateMapValue(mapNode *yaml.Node, key string) *yaml.Node {
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoadConfigPersistsHashedManagementKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := `# management
+remote-management:
+  secret-key: admin-secret
+  keys:
+    # dashboards
+    - label: viewer
+      key: viewer-secret
+      role: read-only
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.RemoteManagement.Keys) != 1 {
+		t.Fatalf("keys = %d, want 1", len(cfg.RemoteManagement.Keys))
+	}
+	if errCompare := bcrypt.CompareHashAndPassword([]byte(cfg.RemoteManagement.Keys[0].Key), []byte("viewer-secret")); errCompare != nil {
+		t.Fatalf("labelled key not hashed in memory: %v", errCompare)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plaintext := range []string{"admin-secret", "viewer-secret"} {
+		if strings.Contains(string(saved), plaintext) {
+			t.Errorf("saved config still contains %q:\n%s", plaintext, saved)
+		}
+	}
+	if !strings.Contains(string(saved), "# dashboards") {
+		t.Errorf("saved config lost comments:\n%s", saved)
+	}
+
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.RemoteManagement.Keys[0].Key != cfg.RemoteManagement.Keys[0].Key {
+		t.Errorf("labelled key rehashed on reload")
+	}
+}
+
+func TestAccessConfigRequiresAuthMatchesMethods(t *testing.T) {
+	access := AccessConfig{Routes: []AccessRoute{
+		{Path: "/v1beta/models*", Methods: []string{"get"}, Required: false},
+		{Path: "/v1/models", Required: false},
+	}}
+	cases := []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/v1beta/models", false},
+		{"GET", "/v1beta/models/gemini-2.5-pro", false},
+		{"POST", "/v1beta/models/gemini-2.5-pro:generateContent", true},
+		{"POST", "/v1/models", false},
+		{"GET", "/v1/chat/completions", true},
+	}
+	for _, tc := range cases {
+		if got := access.RequiresAuth(tc.method, tc.path); got != tc.want {
+			t.Errorf("RequiresAuth(%s, %s) = %v, want %v", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestModelPolicyPatternsSpanSlashes(t *testing.T) {
+	policy := ModelPolicy{AllowedModels: []string{"Anthropic/*"}, DeniedModels: []string{"*/claude-opus-*"}}
+	if denial := policy.Denial("anthropic/claude-sonnet-4"); denial != "" {
+		t.Fatalf("allowed model denied by %s", denial)
+	}
+	if denial := policy.Denial("anthropic/claude-opus-4"); !strings.Contains(denial, "denied-models") {
+		t.Fatalf("denied model reported as %q", denial)
+	}
+	if denial := policy.Denial("gpt-5"); !strings.Contains(denial, "allowed-models") {
+		t.Fatalf("unlisted model reported as %q", denial)
+	}
+}
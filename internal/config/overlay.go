@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// overrideSource records that the field at path was set or cleared by an override file.
+type overrideSource struct {
+	path    []string
+	file    string
+	cleared bool
+	// loaded is the field's YAML encoding once loading finished, so in-memory changes to it
+	// can be told apart and undone.
+	loaded []byte
+}
+
+// applyOverlay merges the override document overlay into the config document root. Fields
+// with a non-zero value replace those of root, with mappings merged key by key, while zero
+// values are ignored so an override file only needs the fields it changes. An explicit null
+// clears a field, dropping its default as well. Returns sources extended by the fields the
+// override file set.
+func applyOverlay(root, overlay *yaml.Node, file string, sources []overrideSource) ([]overrideSource, error) {
+	if overlay.Kind == 0 || len(overlay.Content) == 0 {
+		return sources, nil
+	}
+	if overlay.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse override file %s: expected a mapping", file)
+	}
+	if root.Kind == 0 {
+		root.Kind = yaml.DocumentNode
+		root.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse config file: expected a mapping")
+	}
+	return overlayMapping(root.Content[0], overlay.Content[0], nil, file, sources), nil
+}
+
+func overlayMapping(dst, src *yaml.Node, path []string, file string, sources []overrideSource) []overrideSource {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if value.Tag != "!!null" && isZeroNode(value) {
+			continue
+		}
+		fieldPath := append(path[:len(path):len(path)], key.Value)
+		idx := findMapKeyIndex(dst, key.Value)
+		if value.Kind == yaml.MappingNode && idx >= 0 && dst.Content[idx+1].Kind == yaml.MappingNode {
+			sources = overlayMapping(dst.Content[idx+1], value, fieldPath, file, sources)
+			continue
+		}
+		if idx >= 0 {
+			dst.Content[idx+1] = deepCopyNode(value)
+		} else {
+			dst.Content = append(dst.Content, deepCopyNode(key), deepCopyNode(value))
+		}
+		sources = recordOverride(sources, overrideSource{path: fieldPath, file: file, cleared: value.Tag == "!!null"})
+	}
+	return sources
+}
+
+// recordOverride adds added to sources, dropping earlier entries for the same field or
+// fields below it, which the new value replaced.
+func recordOverride(sources []overrideSource, added overrideSource) []overrideSource {
+	kept := sources[:0]
+	for _, source := range sources {
+		if len(source.path) < len(added.path) || !reflect.DeepEqual(source.path[:len(added.path)], added.path) {
+			kept = append(kept, source)
+		}
+	}
+	return append(kept, added)
+}
+
+// clearField resets the field at the YAML key path below v to its zero value. Decoding an
+// explicit null leaves fields untouched, which would keep defaults set before decoding.
+func clearField(v reflect.Value, path []string) {
+	field, complete := fieldByYAMLPath(v, path)
+	// Map entries decoded from null are zero already.
+	if complete && field.CanSet() {
+		field.Set(reflect.Zero(field.Type()))
+	}
+}
+
+// fieldByYAMLPath follows the YAML key path below v through struct fields. It stops at the
+// first map, which holds the rest of the path, reporting whether the path was followed to
+// its end. An invalid value is returned when a key has no field.
+func fieldByYAMLPath(v reflect.Value, path []string) (reflect.Value, bool) {
+	for _, key := range path {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return v, false
+		}
+		field, ok := structFieldByYAMLName(v, key)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		v = field
+	}
+	return v, true
+}
+
+// snapshotOverrides records the loaded value of every overridden field of cfg.
+func snapshotOverrides(cfg *Config) {
+	root := reflect.ValueOf(cfg)
+	for i := range cfg.overridden {
+		if field, _ := fieldByYAMLPath(root, cfg.overridden[i].path); field.IsValid() {
+			cfg.overridden[i].loaded, _ = yaml.Marshal(field.Interface())
+		}
+	}
+}
+
+// RevertOverridden undoes in-memory changes to fields set by override files, which saving
+// cannot persist since the override file keeps precedence, and returns the dotted paths of
+// the fields reverted with the file each comes from.
+func (c *Config) RevertOverridden() map[string]string {
+	if c == nil {
+		return nil
+	}
+	root := reflect.ValueOf(c)
+	var reverted map[string]string
+	for _, source := range c.overridden {
+		field, _ := fieldByYAMLPath(root, source.path)
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+		current, err := yaml.Marshal(field.Interface())
+		if err != nil || string(current) == string(source.loaded) {
+			continue
+		}
+		restored := reflect.New(field.Type())
+		if err = yaml.Unmarshal(source.loaded, restored.Interface()); err != nil {
+			continue
+		}
+		field.Set(restored.Elem())
+		if reverted == nil {
+			reverted = make(map[string]string)
+		}
+		reverted[strings.Join(source.path, ".")] = source.file
+	}
+	return reverted
+}
+
+func structFieldByYAMLName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == name && t.Field(i).IsExported() {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// isZeroNode reports whether n holds the zero value of its type: an empty string, zero,
+// false, or an empty sequence or mapping.
+func isZeroNode(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		var value any
+		if err := n.Decode(&value); err != nil {
+			return false
+		}
+		return value == nil || reflect.ValueOf(value).IsZero()
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(n.Content) == 0
+	}
+	return false
+}
+
+// removeNodePath deletes the key at path from a mapping node tree, if present.
+func removeNodePath(mapNode *yaml.Node, path []string) {
+	for i, key := range path {
+		idx := findMapKeyIndex(mapNode, key)
+		if idx < 0 {
+			return
+		}
+		if i == len(path)-1 {
+			mapNode.Content = append(mapNode.Content[:idx], mapNode.Content[idx+2:]...)
+			return
+		}
+		mapNode = mapNode.Content[idx+1]
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOverridesMergeAndClear(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "base.yaml", "port: 8317\ndebug: true\nproxy-url: socks5://base\napi-keys: [a, b]\n")
+	override := writeFile(t, dir, "team.yaml", "port: 9000\nproxy-url: null\ndebug: false\n")
+
+	cfg, err := LoadConfigWithOverrides(base, []string{override})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9000 {
+		t.Errorf("port = %d, want the override's 9000", cfg.Port)
+	}
+	if !cfg.Debug {
+		t.Error("zero value in the override replaced debug")
+	}
+	if cfg.ProxyURL != "" {
+		t.Errorf("proxy-url = %q, want cleared by null", cfg.ProxyURL)
+	}
+	if len(cfg.APIKeys) != 2 {
+		t.Errorf("api-keys = %v, want the base list", cfg.APIKeys)
+	}
+	if cfg.OverrideSources["port"] != override || cfg.OverrideSources["proxy-url"] != override {
+		t.Errorf("override sources = %v", cfg.OverrideSources)
+	}
+	if _, ok := cfg.OverrideSources["debug"]; ok {
+		t.Error("zero override recorded as a source")
+	}
+}
+
+func TestRevertOverridden(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "base.yaml", "port: 8317\ndebug: true\n")
+	override := writeFile(t, dir, "team.yaml", "port: 9000\n")
+	cfg, err := LoadConfigWithOverrides(base, []string{override})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted := cfg.RevertOverridden(); len(reverted) != 0 {
+		t.Fatalf("unchanged config reverted %v", reverted)
+	}
+
+	cfg.Port = 1234
+	cfg.Debug = false
+	reverted := cfg.RevertOverridden()
+	if len(reverted) != 1 || reverted["port"] != override {
+		t.Fatalf("reverted = %v, want port from %s", reverted, override)
+	}
+	if cfg.Port != 9000 {
+		t.Errorf("port = %d after revert, want 9000", cfg.Port)
+	}
+	if cfg.Debug {
+		t.Error("revert touched a field the override does not set")
+	}
+}
@@ -24,4 +24,7 @@ const (
 
 	// OpenaiResponse represents the OpenAI response format identifier.
 	OpenaiResponse = "openai-response"
+
+	// Echo represents the built-in echo provider identifier.
+	Echo = "echo"
 )
@@ -0,0 +1,76 @@
+package docstore
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ChunkText splits text into chunks of at most size runes, each starting overlap runes
+// before the end of the previous one. Chunks end at the last paragraph break, line break or
+// space inside the window when there is one, so words are not cut in half.
+func ChunkText(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) == 0 {
+		return nil
+	}
+	if size <= 0 {
+		return []string{string(runes)}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	var chunks []string
+	start := 0
+	for start < len(runes) {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else {
+			end = breakPoint(runes, start, end)
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end >= len(runes) {
+			break
+		}
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// breakPoint moves end back to just after the best break inside the second half of the
+// window [start, end), preferring paragraph breaks over line breaks over spaces.
+func breakPoint(runes []rune, start, end int) int {
+	floor := start + (end-start)/2
+	for _, isBreak := range []func(i int) bool{
+		func(i int) bool { return runes[i] == '\n' && i > 0 && runes[i-1] == '\n' },
+		func(i int) bool { return runes[i] == '\n' },
+		func(i int) bool { return unicode.IsSpace(runes[i]) },
+	} {
+		for i := end - 1; i > floor; i-- {
+			if isBreak(i) {
+				return i + 1
+			}
+		}
+	}
+	return end
+}
+
+// FormatContext renders matches as the reference block injected into a request.
+func FormatContext(store string, matches []Match) string {
+	if len(matches) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Use the following excerpts from the document store %q when they are relevant to the request. Cite them by their bracketed number.\n", store)
+	for i, match := range matches {
+		fmt.Fprintf(&b, "\n[%d] %s (chunk %s)\n%s\n", i+1, match.DocumentName, match.ID, match.Text)
+	}
+	return b.String()
+}
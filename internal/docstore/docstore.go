@@ -0,0 +1,383 @@
+// Package docstore keeps small text document collections for retrieval augmented requests.
+// Documents are owned by a client API key and grouped into named stores. On upload they are
+// split into chunks and embedded synchronously; at request time the chunks closest to the
+// query by cosine similarity are returned. Everything lives in one bolt file, opened per
+// operation like the Gemini Web conversation stores.
+package docstore
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DirName is the directory inside the auth dir holding the store. The watcher ignores
+	// sub-directories, so nothing in it is mistaken for an auth file.
+	DirName  = ".docstore"
+	fileName = "documents.bolt"
+
+	docPrefix   = "doc:"
+	chunkPrefix = "chunk:"
+)
+
+var (
+	// ErrNotFound is returned when a store or document does not exist.
+	ErrNotFound = errors.New("docstore: not found")
+	// ErrInvalidName is returned for store names outside [A-Za-z0-9._-]{1,64}.
+	ErrInvalidName = errors.New("docstore: store name must be 1-64 characters of letters, digits, '.', '_' or '-'")
+	// ErrRejected wraps errors for documents that are empty or exceed the configured limits.
+	ErrRejected = errors.New("docstore: document rejected")
+	// ErrEmbedding wraps errors returned by the Embedder.
+	ErrEmbedding = errors.New("docstore: embedding failed")
+
+	storeNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+	// mu serialises access to the bolt file, whose lock is held per open handle.
+	mu sync.Mutex
+)
+
+// Embedder turns texts into embedding vectors, one per text and in the same order.
+type Embedder func(ctx context.Context, texts []string) ([][]float32, error)
+
+// Limits bounds what a store accepts and how documents are chunked. Zero fields use defaults.
+type Limits struct {
+	MaxDocumentBytes int
+	MaxDocuments     int
+	ChunkSize        int
+	ChunkOverlap     int
+}
+
+// Document describes one uploaded document.
+type Document struct {
+	ID        string    `json:"id"`
+	Store     string    `json:"store"`
+	Name      string    `json:"name"`
+	Size      int       `json:"size"`
+	Chunks    int       `json:"chunks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Chunk is one embedded slice of a document.
+type Chunk struct {
+	ID           string    `json:"id"`
+	DocumentID   string    `json:"document_id"`
+	DocumentName string    `json:"document_name"`
+	Index        int       `json:"index"`
+	Text         string    `json:"text"`
+	Vector       []float32 `json:"vector"`
+}
+
+// Match is a chunk returned by Search with its similarity to the query.
+type Match struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Store is the document store kept in one bolt file.
+type Store struct {
+	path string
+}
+
+// New returns the store kept under authDir.
+func New(authDir string) *Store {
+	return &Store{path: filepath.Join(authDir, DirName, fileName)}
+}
+
+// ValidStoreName reports whether name may be used as a store name.
+func ValidStoreName(name string) bool {
+	return storeNamePattern.MatchString(name)
+}
+
+// Add chunks and embeds content and saves it as a new document of store.
+func (s *Store) Add(ctx context.Context, apiKey, store, name, content string, embed Embedder, limits Limits) (Document, error) {
+	if !ValidStoreName(store) {
+		return Document{}, ErrInvalidName
+	}
+	limits = limits.withDefaults()
+	if strings.TrimSpace(content) == "" {
+		return Document{}, fmt.Errorf("%w: document is empty", ErrRejected)
+	}
+	if len(content) > limits.MaxDocumentBytes {
+		return Document{}, fmt.Errorf("%w: document is %d bytes, the limit is %d", ErrRejected, len(content), limits.MaxDocumentBytes)
+	}
+	// A full store is turned away before paying for the embeddings; the count is checked
+	// again in the transaction saving the document, as concurrent uploads may fill it.
+	docs, err := s.List(apiKey, store)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return Document{}, err
+	}
+	if len(docs) >= limits.MaxDocuments {
+		return Document{}, storeFullError(store, limits.MaxDocuments)
+	}
+
+	texts := ChunkText(content, limits.ChunkSize, limits.ChunkOverlap)
+	vectors, err := embed(ctx, texts)
+	if err != nil {
+		return Document{}, fmt.Errorf("%w: %v", ErrEmbedding, err)
+	}
+	if len(vectors) != len(texts) {
+		return Document{}, fmt.Errorf("%w: %d vectors for %d chunks", ErrEmbedding, len(vectors), len(texts))
+	}
+
+	doc := Document{
+		ID:        newID(),
+		Store:     store,
+		Name:      strings.TrimSpace(name),
+		Size:      len(content),
+		Chunks:    len(texts),
+		CreatedAt: time.Now().UTC(),
+	}
+	if doc.Name == "" {
+		doc.Name = doc.ID
+	}
+	err = s.update(func(tx *bolt.Tx) error {
+		bucket, errBucket := tx.CreateBucketIfNotExists(bucketName(apiKey, store))
+		if errBucket != nil {
+			return errBucket
+		}
+		count := 0
+		if errCount := scanPrefix(bucket, docPrefix, func([]byte) error { count++; return nil }); errCount != nil {
+			return errCount
+		}
+		if count >= limits.MaxDocuments {
+			return storeFullError(store, limits.MaxDocuments)
+		}
+		for i, text := range texts {
+			chunk := Chunk{
+				ID:           fmt.Sprintf("%s-%d", doc.ID, i),
+				DocumentID:   doc.ID,
+				DocumentName: doc.Name,
+				Index:        i,
+				Text:         text,
+				Vector:       vectors[i],
+			}
+			if errPut := putJSON(bucket, chunkKey(doc.ID, i), chunk); errPut != nil {
+				return errPut
+			}
+		}
+		return putJSON(bucket, docPrefix+doc.ID, doc)
+	})
+	if err != nil {
+		return Document{}, err
+	}
+	return doc, nil
+}
+
+// List returns the documents of store, oldest first.
+func (s *Store) List(apiKey, store string) ([]Document, error) {
+	if !ValidStoreName(store) {
+		return nil, ErrInvalidName
+	}
+	var docs []Document
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(apiKey, store))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		return scanPrefix(bucket, docPrefix, func(value []byte) error {
+			var doc Document
+			if err := json.Unmarshal(value, &doc); err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].CreatedAt.Before(docs[j].CreatedAt) })
+	return docs, nil
+}
+
+// Delete removes a document and its chunks from store.
+func (s *Store) Delete(apiKey, store, id string) error {
+	if !ValidStoreName(store) {
+		return ErrInvalidName
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(apiKey, store))
+		if bucket == nil || bucket.Get([]byte(docPrefix+id)) == nil {
+			return ErrNotFound
+		}
+		var keys [][]byte
+		prefix := []byte(chunkPrefix + id + ":")
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return bucket.Delete([]byte(docPrefix + id))
+	})
+}
+
+// Search embeds query and returns the topK chunks of store most similar to it, best first.
+func (s *Store) Search(ctx context.Context, apiKey, store, query string, topK int, embed Embedder) ([]Match, error) {
+	if !ValidStoreName(store) {
+		return nil, ErrInvalidName
+	}
+	var chunks []Chunk
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(apiKey, store))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		return scanPrefix(bucket, chunkPrefix, func(value []byte) error {
+			var chunk Chunk
+			if err := json.Unmarshal(value, &chunk); err != nil {
+				return err
+			}
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	vectors, err := embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEmbedding, err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("%w: %d vectors for the query", ErrEmbedding, len(vectors))
+	}
+	return Rank(chunks, vectors[0], topK), nil
+}
+
+// Rank scores chunks by cosine similarity to query and returns the topK best, best first.
+// Ties keep the order of chunks.
+func Rank(chunks []Chunk, query []float32, topK int) []Match {
+	matches := make([]Match, 0, len(chunks))
+	for _, chunk := range chunks {
+		matches = append(matches, Match{Chunk: chunk, Score: CosineSimilarity(chunk.Vector, query)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, zero when their lengths
+// differ or either is a zero vector.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func storeFullError(store string, limit int) error {
+	return fmt.Errorf("%w: store %q already holds %d documents, the limit", ErrRejected, store, limit)
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxDocumentBytes <= 0 {
+		l.MaxDocumentBytes = 256 * 1024
+	}
+	if l.MaxDocuments <= 0 {
+		l.MaxDocuments = 100
+	}
+	if l.ChunkSize <= 0 {
+		l.ChunkSize = 1000
+	}
+	if l.ChunkOverlap < 0 || l.ChunkOverlap >= l.ChunkSize {
+		l.ChunkOverlap = 0
+	}
+	return l
+}
+
+// bucketName keys a store by a hash of the owning API key, so raw keys are never persisted.
+func bucketName(apiKey, store string) []byte {
+	sum := sha256.Sum256([]byte(apiKey))
+	return []byte(hex.EncodeToString(sum[:8]) + "/" + store)
+}
+
+func chunkKey(docID string, index int) string {
+	return fmt.Sprintf("%s%s:%06d", chunkPrefix, docID, index)
+}
+
+func newID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+func putJSON(bucket *bolt.Bucket, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
+
+func scanPrefix(bucket *bolt.Bucket, prefix string, fn func(value []byte) error) error {
+	c := bucket.Cursor()
+	for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return db.View(fn)
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	return db.Update(fn)
+}
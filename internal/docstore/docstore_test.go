@@ -0,0 +1,117 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// wordEmbedder embeds a text as the counts of a fixed vocabulary.
+func wordEmbedder(_ context.Context, texts []string) ([][]float32, error) {
+	vocabulary := []string{"apple", "banana", "cherry"}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, len(vocabulary))
+		for j, word := range vocabulary {
+			vector[j] = float32(strings.Count(strings.ToLower(text), word))
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func TestChunkTextEndsChunksAtWordsWithOverlap(t *testing.T) {
+	chunks := ChunkText("alpha beta gamma delta epsilon zeta", 12, 4)
+	want := []string{"alpha beta", "eta gamma", "mma delta", "lta epsilon", "lon zeta"}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Fatalf("chunks = %q, want %q", chunks, want)
+	}
+	for _, chunk := range chunks {
+		if len([]rune(chunk)) > 12 {
+			t.Fatalf("chunk %q longer than the chunk size", chunk)
+		}
+	}
+	if got := ChunkText("  \n ", 10, 0); got != nil {
+		t.Fatalf("chunks of blank text = %q, want none", got)
+	}
+}
+
+func TestRankOrdersBySimilarity(t *testing.T) {
+	chunks := []Chunk{
+		{ID: "bananas", Vector: []float32{0, 1, 0}},
+		{ID: "mixed", Vector: []float32{1, 1, 0}},
+		{ID: "apples", Vector: []float32{1, 0, 0}},
+		{ID: "apples-again", Vector: []float32{2, 0, 0}},
+	}
+	matches := Rank(chunks, []float32{1, 0, 0}, 3)
+	var ids []string
+	for _, match := range matches {
+		ids = append(ids, match.ID)
+	}
+	if strings.Join(ids, ",") != "apples,apples-again,mixed" {
+		t.Fatalf("ranked = %v, want the closest first, ties in input order", ids)
+	}
+}
+
+func TestStoreSearchesUploadedDocuments(t *testing.T) {
+	s := New(t.TempDir())
+	ctx := context.Background()
+	if _, err := s.Add(ctx, "key", "fruit", "a.txt", "apple apple apple", wordEmbedder, Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(ctx, "key", "fruit", "b.txt", "banana cherry", wordEmbedder, Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := s.Search(ctx, "key", "fruit", "which apple?", 1, wordEmbedder)
+	if err != nil || len(matches) != 1 || matches[0].DocumentName != "a.txt" {
+		t.Fatalf("matches = %+v, %v, want a.txt", matches, err)
+	}
+	if _, err = s.Search(ctx, "other-key", "fruit", "apple", 1, wordEmbedder); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("search with another key = %v, want not found", err)
+	}
+}
+
+func TestConcurrentUploadsRespectTheDocumentLimit(t *testing.T) {
+	s := New(t.TempDir())
+	limits := Limits{MaxDocuments: 2}
+	const uploads = 8
+	// Every upload passes the early count before any of them saves its document.
+	var arrived sync.WaitGroup
+	arrived.Add(uploads)
+	embed := func(ctx context.Context, texts []string) ([][]float32, error) {
+		arrived.Done()
+		arrived.Wait()
+		return wordEmbedder(ctx, texts)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.Add(context.Background(), "key", "docs", "doc", "apple banana", embed, limits)
+		}()
+	}
+	wg.Wait()
+	docs, err := s.List("key", "docs")
+	if err != nil || len(docs) != limits.MaxDocuments {
+		t.Fatalf("stored %d documents (%v), want the limit of %d", len(docs), err, limits.MaxDocuments)
+	}
+}
+
+func TestFormatContextNumbersExcerpts(t *testing.T) {
+	got := FormatContext("notes", []Match{
+		{Chunk: Chunk{ID: "d1-0", DocumentName: "a.txt", Text: "first"}},
+		{Chunk: Chunk{ID: "d2-3", DocumentName: "b.txt", Text: "second"}},
+	})
+	want := "Use the following excerpts from the document store \"notes\" when they are relevant to the request. Cite them by their bracketed number.\n" +
+		"\n[1] a.txt (chunk d1-0)\nfirst\n" +
+		"\n[2] b.txt (chunk d2-3)\nsecond\n"
+	if got != want {
+		t.Fatalf("context = %q, want %q", got, want)
+	}
+	if FormatContext("notes", nil) != "" {
+		t.Fatal("context without matches is not empty")
+	}
+}
@@ -0,0 +1,112 @@
+package errorlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// deadLetterPostTimeout bounds the delivery of one dead letter to the configured URL.
+const deadLetterPostTimeout = 10 * time.Second
+
+// DeadLetterAttempt describes one account tried for a request that failed on all of them.
+type DeadLetterAttempt struct {
+	AuthID     string `json:"auth_id"`
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// DeadLetter records a request the proxy gave up on after trying every eligible account.
+type DeadLetter struct {
+	Time       time.Time           `json:"time"`
+	RequestID  string              `json:"request_id,omitempty"`
+	Method     string              `json:"method,omitempty"`
+	Path       string              `json:"path,omitempty"`
+	Model      string              `json:"model"`
+	Providers  []string            `json:"providers,omitempty"`
+	APIKey     string              `json:"api_key,omitempty"`
+	Stream     bool                `json:"stream"`
+	StatusCode int                 `json:"status_code"`
+	Error      string              `json:"error"`
+	Attempts   []DeadLetterAttempt `json:"attempts"`
+}
+
+var (
+	deadLetterMu     sync.Mutex
+	deadLetterClient = &http.Client{Timeout: deadLetterPostTimeout}
+)
+
+// WriteDeadLetter appends entry to the dead-letter file and posts it to the dead-letter URL
+// configured in cfg, doing nothing when neither is set. Error texts are redacted and
+// truncated first; the caller is expected to have masked the client key already.
+func WriteDeadLetter(cfg *config.Config, entry DeadLetter) {
+	if cfg == nil {
+		return
+	}
+	file := strings.TrimSpace(cfg.DeadLetter.File)
+	url := strings.TrimSpace(cfg.DeadLetter.URL)
+	if file == "" && url == "" {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Error = logging.Truncate(logging.RedactSecrets(entry.Error), MaxBodyBytes)
+	for i := range entry.Attempts {
+		entry.Attempts[i].Message = logging.Truncate(logging.RedactSecrets(entry.Attempts[i].Message), MaxBodyBytes)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Warnf("dead letter: failed to encode entry for model %s: %v", entry.Model, err)
+		return
+	}
+	if file != "" {
+		if err = appendDeadLetter(file, data); err != nil {
+			log.Warnf("dead letter: failed to write %s: %v", file, err)
+		}
+	}
+	if url != "" {
+		go postDeadLetter(url, data)
+	}
+}
+
+func appendDeadLetter(path string, data []byte) error {
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func postDeadLetter(url string, data []byte) {
+	resp, err := deadLetterClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Warnf("dead letter: failed to post entry: %v", logging.RedactSecrets(err.Error()))
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Warnf("dead letter: endpoint answered with status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,99 @@
+// Package errorlog keeps the most recent failed upstream exchanges per auth in memory so
+// operators can see what an upstream actually answered when an account starts failing.
+package errorlog
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+const (
+	// DefaultCapacity is the number of failures retained per auth.
+	DefaultCapacity = 20
+	// MaxBodyBytes bounds the stored response body of each failure.
+	MaxBodyBytes = 2048
+)
+
+// Entry describes one failed upstream exchange.
+type Entry struct {
+	Time          time.Time `json:"time"`
+	Provider      string    `json:"provider,omitempty"`
+	Endpoint      string    `json:"endpoint,omitempty"`
+	StatusCode    int       `json:"status_code"`
+	Body          string    `json:"body,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+// Summary condenses the failures of one auth into a single line for listings.
+type Summary struct {
+	LastError *Entry `json:"last_error,omitempty"`
+	LastHour  int    `json:"errors_last_hour"`
+}
+
+// Store holds a bounded ring of failures for every auth ID.
+type Store struct {
+	mu       sync.RWMutex
+	capacity int
+	entries  map[string][]Entry
+}
+
+var defaultStore = NewStore(DefaultCapacity)
+
+// NewStore creates a store retaining up to capacity failures per auth.
+func NewStore(capacity int) *Store {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Store{capacity: capacity, entries: make(map[string][]Entry)}
+}
+
+// Default returns the process-wide store populated by the executors.
+func Default() *Store { return defaultStore }
+
+// Record appends entry to the ring of authID after truncating and redacting its body.
+func (s *Store) Record(authID string, entry Entry) {
+	if s == nil || strings.TrimSpace(authID) == "" {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Body = logging.Truncate(logging.RedactSecrets(strings.TrimSpace(entry.Body)), MaxBodyBytes)
+	entry.Endpoint = logging.RedactSecrets(entry.Endpoint)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ring := append(s.entries[authID], entry)
+	if len(ring) > s.capacity {
+		ring = append([]Entry(nil), ring[len(ring)-s.capacity:]...)
+	}
+	s.entries[authID] = ring
+}
+
+// List returns the failures recorded for authID, newest first.
+func (s *Store) List(authID string) []Entry {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ring := s.entries[authID]
+	out := make([]Entry, 0, len(ring))
+	for i := len(ring) - 1; i >= 0; i-- {
+		out = append(out, ring[i])
+	}
+	return out
+}
+
+// Forget drops every failure recorded for authID.
+func (s *Store) Forget(authID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.entries, authID)
+	s.mu.Unlock()
+}
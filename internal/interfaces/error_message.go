@@ -3,7 +3,11 @@
 // such as AI service clients, API handlers, and data models.
 package interfaces
 
-import "net/http"
+import (
+	"net/http"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
 
 // ErrorMessage encapsulates an error with an associated HTTP status code.
 // This structure is used to provide detailed error information including
@@ -15,6 +19,9 @@ type ErrorMessage struct {
 	// Error is the underlying error that occurred.
 	Error error
 
+	// Kind classifies the failure (quota, auth, transient, invalid, upstream).
+	Kind cliproxyexecutor.ErrorKind
+
 	// Addon contains additional headers to be added to the response.
 	Addon http.Header
 }
@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// captureSourceKey is the Gin context key holding the CaptureSource of the request.
+	captureSourceKey = "REQUEST_CAPTURE_SOURCE"
+	// captureResponseKey holds the translated response of the request, before the handlers
+	// added anything of their own such as footers or tags.
+	captureResponseKey = "REQUEST_CAPTURE_RESPONSE"
+)
+
+// CaptureSource describes a request as the handlers passed it to the executors.
+type CaptureSource struct {
+	Format  string
+	Stream  bool
+	Request []byte
+}
+
+// RecordCaptureSource notes on the Gin context of ctx the request handed to the executors, so
+// a captured exchange can be replayed through the same translators.
+func RecordCaptureSource(ctx context.Context, source CaptureSource) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Set(captureSourceKey, source)
+		ginCtx.Set(captureResponseKey, []byte(nil))
+	}
+}
+
+// RecordCaptureResponse appends to the captured response of ctx a response or stream chunk as
+// the translators produced it. Chunks are separated by newlines, as replays join them.
+func RecordCaptureResponse(ctx context.Context, data []byte) {
+	if ctx == nil || len(data) == 0 {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	existing, _ := ginCtx.Get(captureResponseKey)
+	response, _ := existing.([]byte)
+	if len(response) > 0 {
+		response = append(response, '\n')
+	}
+	ginCtx.Set(captureResponseKey, append(response, data...))
+}
+
+// RecordedCaptureResponse returns the translated response recorded for c.
+func RecordedCaptureResponse(c *gin.Context) []byte {
+	if c == nil {
+		return nil
+	}
+	existing, _ := c.Get(captureResponseKey)
+	response, _ := existing.([]byte)
+	return response
+}
+
+// RecordedCaptureSource returns the CaptureSource recorded for c.
+func RecordedCaptureSource(c *gin.Context) (CaptureSource, bool) {
+	if c == nil {
+		return CaptureSource{}, false
+	}
+	v, ok := c.Get(captureSourceKey)
+	if !ok {
+		return CaptureSource{}, false
+	}
+	source, ok := v.(CaptureSource)
+	return source, ok
+}
+
+// CapturedExchange is one request with the upstream exchange it caused and the response the
+// translators made of it, with secrets masked.
+type CapturedExchange struct {
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+	// SourceFormat is the request format of the client, Provider the provider that served it
+	// and Model the upstream model.
+	SourceFormat string `json:"source_format"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Stream       bool   `json:"stream"`
+
+	Request          string `json:"request"`
+	UpstreamRequest  string `json:"upstream_request"`
+	UpstreamResponse string `json:"upstream_response"`
+	Response         string `json:"response"`
+}
+
+// WriteCapture masks the secrets of exchange and writes it as a new JSON file in dir,
+// returning the file path.
+func WriteCapture(dir string, exchange CapturedExchange) (string, error) {
+	exchange.Request = RedactSecrets(exchange.Request)
+	exchange.UpstreamRequest = RedactSecrets(exchange.UpstreamRequest)
+	exchange.UpstreamResponse = RedactSecrets(exchange.UpstreamResponse)
+	exchange.Response = RedactSecrets(exchange.Response)
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create capture directory: %w", err)
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.json", exchange.Time.UTC().Format("20060102-150405.000"), hex.EncodeToString(suffix))
+	path := filepath.Join(dir, name)
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("write capture: %w", err)
+	}
+	return path, nil
+}
+
+// ReadCapture loads an exchange written by WriteCapture.
+func ReadCapture(path string) (CapturedExchange, error) {
+	var exchange CapturedExchange
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return exchange, err
+	}
+	if err = json.Unmarshal(data, &exchange); err != nil {
+		return exchange, fmt.Errorf("parse capture %s: %w", filepath.Base(path), err)
+	}
+	return exchange, nil
+}
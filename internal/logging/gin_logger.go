@@ -47,6 +47,11 @@ func GinLogrusLogger() gin.HandlerFunc {
 		if errorMessage != "" {
 			logLine = logLine + " | " + errorMessage
 		}
+		if streamStatus := StreamErrorStatus(c); streamStatus != 0 {
+			// The 200 was sent before the stream failed; rate the line by the real status.
+			logLine = fmt.Sprintf("%s | stream ended with %d", logLine, streamStatus)
+			statusCode = streamStatus
+		}
 
 		switch {
 		case statusCode >= http.StatusInternalServerError:
@@ -50,6 +50,7 @@ func SetupBaseLogger() {
 		log.SetOutput(os.Stdout)
 		log.SetReportCaller(true)
 		log.SetFormatter(&LogFormatter{})
+		log.AddHook(logStream)
 
 		ginInfoWriter = log.StandardLogger().Writer()
 		gin.DefaultWriter = ginInfoWriter
@@ -64,8 +65,17 @@ func SetupBaseLogger() {
 	})
 }
 
+// Rotation bounds the disk space used by log files. Zero fields mean no limit, except that
+// the main log is rotated at 10 MB when MaxSize is zero.
+type Rotation struct {
+	MaxSize    int
+	MaxBackups int
+	MaxAge     int
+	Compress   bool
+}
+
 // ConfigureLogOutput switches the global log destination between rotating files and stdout.
-func ConfigureLogOutput(loggingToFile bool) error {
+func ConfigureLogOutput(loggingToFile bool, rotation Rotation) error {
 	SetupBaseLogger()
 
 	writerMu.Lock()
@@ -79,13 +89,7 @@ func ConfigureLogOutput(loggingToFile bool) error {
 		if logWriter != nil {
 			_ = logWriter.Close()
 		}
-		logWriter = &lumberjack.Logger{
-			Filename:   filepath.Join(logDir, "main.log"),
-			MaxSize:    10,
-			MaxBackups: 0,
-			MaxAge:     0,
-			Compress:   false,
-		}
+		logWriter = newMainLogWriter(filepath.Join(logDir, "main.log"), rotation)
 		log.SetOutput(logWriter)
 		return nil
 	}
@@ -98,6 +102,20 @@ func ConfigureLogOutput(loggingToFile bool) error {
 	return nil
 }
 
+func newMainLogWriter(filename string, rotation Rotation) *lumberjack.Logger {
+	maxSize := rotation.MaxSize
+	if maxSize <= 0 {
+		maxSize = 10
+	}
+	return &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    maxSize,
+		MaxBackups: max(rotation.MaxBackups, 0),
+		MaxAge:     max(rotation.MaxAge, 0),
+		Compress:   rotation.Compress,
+	}
+}
+
 func closeLogOutputs() {
 	writerMu.Lock()
 	defer writerMu.Unlock()
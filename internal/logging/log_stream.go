@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// logRingSize is the number of recent log lines kept in memory for backfills.
+	logRingSize = 2000
+	// logSubscriberBuffer is the number of lines queued for a slow subscriber before the
+	// oldest ones are dropped.
+	logSubscriberBuffer = 512
+)
+
+// LogLine is one log entry as kept in memory for the management log endpoints.
+type LogLine struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	// Line is the entry rendered as it appears in the log output.
+	Line string `json:"line"`
+}
+
+// LogSubscription receives the log lines written after it was opened.
+type LogSubscription struct {
+	mu      sync.Mutex
+	lines   []LogLine
+	dropped int
+	notify  chan struct{}
+}
+
+// Ready is signalled whenever lines are waiting to be taken.
+func (s *LogSubscription) Ready() <-chan struct{} {
+	return s.notify
+}
+
+// Take returns the waiting lines, oldest first, and the number of lines dropped since the
+// previous call because the subscriber did not keep up.
+func (s *LogSubscription) Take() ([]LogLine, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines, dropped := s.lines, s.dropped
+	s.lines, s.dropped = nil, 0
+	return lines, dropped
+}
+
+// push queues line, dropping the oldest queued line when the buffer is full. It never blocks.
+func (s *LogSubscription) push(line LogLine) {
+	s.mu.Lock()
+	if len(s.lines) >= logSubscriberBuffer {
+		s.lines = s.lines[1:]
+		s.dropped++
+	}
+	s.lines = append(s.lines, line)
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// logBroadcast keeps the recent log lines and hands new ones to subscribers.
+type logBroadcast struct {
+	mu          sync.Mutex
+	ring        []LogLine
+	next        int
+	full        bool
+	subscribers map[*LogSubscription]struct{}
+}
+
+var logStream = &logBroadcast{
+	ring:        make([]LogLine, logRingSize),
+	subscribers: make(map[*LogSubscription]struct{}),
+}
+
+// Levels implements log.Hook for every level.
+func (b *logBroadcast) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook. It only takes short locks and never waits on a subscriber, so
+// a stalled log stream cannot hold up logging.
+func (b *logBroadcast) Fire(entry *log.Entry) error {
+	message := strings.TrimRight(entry.Message, "\r\n")
+	caller := "-"
+	if entry.Caller != nil {
+		caller = fmt.Sprintf("%s:%d", filepath.Base(entry.Caller.File), entry.Caller.Line)
+	}
+	line := LogLine{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: message,
+		Line:    fmt.Sprintf("[%s] [%s] [%s] %s", entry.Time.Format("2006-01-02 15:04:05"), entry.Level, caller, message),
+	}
+
+	b.mu.Lock()
+	b.ring[b.next] = line
+	b.next = (b.next + 1) % len(b.ring)
+	if b.next == 0 {
+		b.full = true
+	}
+	for sub := range b.subscribers {
+		sub.push(line)
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// recent returns up to n of the latest lines, oldest first.
+func (b *logBroadcast) recent(n int) []LogLine {
+	size := b.next
+	if b.full {
+		size = len(b.ring)
+	}
+	if n <= 0 || n > size {
+		n = size
+	}
+	out := make([]LogLine, 0, n)
+	for i := b.next - n; i < b.next; i++ {
+		out = append(out, b.ring[(i+len(b.ring))%len(b.ring)])
+	}
+	return out
+}
+
+// RecentLogLines returns up to n of the latest log lines, oldest first; n <= 0 returns all
+// lines kept in memory.
+func RecentLogLines(n int) []LogLine {
+	logStream.mu.Lock()
+	defer logStream.mu.Unlock()
+	return logStream.recent(n)
+}
+
+// SubscribeLogs opens a subscription to new log lines and returns it with up to backfill of
+// the latest lines written before it. The subscription must be closed with UnsubscribeLogs.
+func SubscribeLogs(backfill int) (*LogSubscription, []LogLine) {
+	sub := &LogSubscription{notify: make(chan struct{}, 1)}
+	logStream.mu.Lock()
+	defer logStream.mu.Unlock()
+	var lines []LogLine
+	if backfill > 0 {
+		lines = logStream.recent(backfill)
+	}
+	logStream.subscribers[sub] = struct{}{}
+	return sub, lines
+}
+
+// UnsubscribeLogs closes sub.
+func UnsubscribeLogs(sub *LogSubscription) {
+	logStream.mu.Lock()
+	delete(logStream.subscribers, sub)
+	logStream.mu.Unlock()
+}
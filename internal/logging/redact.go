@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// redactedValue replaces any secret removed from logged content.
+const redactedValue = "[REDACTED]"
+
+// sensitiveHeaders lists the credential-bearing request and response headers, whose values
+// are never logged nor forwarded upstream.
+var sensitiveHeaders = map[string]struct{}{
+	"authorization":       {},
+	"proxy-authorization": {},
+	"x-api-key":           {},
+	"x-goog-api-key":      {},
+	"api-key":             {},
+	"cookie":              {},
+	"set-cookie":          {},
+	"x-management-key":    {},
+	"x-provider-key":      {},
+	"x-auth-token":        {},
+	"x-access-token":      {},
+}
+
+// secretPatterns match credentials embedded in free-form text such as upstream error bodies.
+// Each pattern keeps its first capture group and masks the rest of the match.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`(?i)("(?:access_token|refresh_token|id_token|api_key|apikey|token|secret|client_secret|password|secure_1psid|secure_1psidts|__secure-1psid|__secure-1psidts)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`(?i)((?:access_token|refresh_token|api_key|apikey|key|token)=)[^&\s"']+`),
+	regexp.MustCompile(`(?i)((?:__Secure-1PSID|__Secure-1PSIDTS)=)[^;\s"']+`),
+	regexp.MustCompile(`()\bsk-[A-Za-z0-9_-]{8,}`),
+	regexp.MustCompile(`()\bAIza[0-9A-Za-z_-]{20,}`),
+}
+
+// IsSensitiveHeader reports whether name is a credential-bearing header.
+func IsSensitiveHeader(name string) bool {
+	_, sensitive := sensitiveHeaders[strings.ToLower(strings.TrimSpace(name))]
+	return sensitive
+}
+
+// RedactHeaderValue returns value, or a placeholder when name is a credential-bearing header.
+func RedactHeaderValue(name, value string) string {
+	if IsSensitiveHeader(name) && value != "" {
+		return redactedValue
+	}
+	return value
+}
+
+// RedactSecrets masks bearer tokens, API keys, cookies and credential JSON fields in s.
+func RedactSecrets(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redactedValue)
+	}
+	return s
+}
+
+// Truncate shortens s to at most limit bytes without splitting a UTF-8 sequence and
+// marks the cut. A non-positive limit leaves s untouched.
+func Truncate(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "...[truncated]"
+}
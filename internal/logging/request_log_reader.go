@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRequestLogNotFound is returned when no request log matches a lookup.
+var ErrRequestLogNotFound = errors.New("request log not found")
+
+// requestLogSection matches the heading of a request log section, such as "=== RESPONSE ===".
+var requestLogSection = regexp.MustCompile(`^=== ([A-Z][A-Z ]*) ===$`)
+
+// RequestLogRecord is a request log file read back into the parts formatLogContent and the
+// streaming writer wrote. Header names are canonical, as net/http stores them.
+type RequestLogRecord struct {
+	// File is the base name of the log file.
+	File      string
+	URL       string
+	Method    string
+	Timestamp time.Time
+
+	RequestHeaders map[string]string
+	RequestBody    []byte
+
+	// APIRequest and APIResponse are the upstream exchange of non-streaming requests.
+	APIRequest  []byte
+	APIResponse []byte
+
+	Status          int
+	ResponseHeaders map[string]string
+	// Response is the body sent to the client; for streams, the chunks as they were sent.
+	Response []byte
+}
+
+// RequestID returns the X-Request-ID the request was served under, or "" when the log
+// predates request IDs.
+func (r *RequestLogRecord) RequestID() string {
+	if id := r.ResponseHeaders["X-Request-Id"]; id != "" {
+		return id
+	}
+	return r.RequestHeaders["X-Request-Id"]
+}
+
+// Dir returns the directory request logs are written to.
+func (l *FileRequestLogger) Dir() string { return l.logsDir }
+
+// ReadRequestLog reads and parses the request log at path, compressed or not.
+func ReadRequestLog(path string) (*RequestLogRecord, error) {
+	data, err := readRequestLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRequestLog(filepath.Base(path), data), nil
+}
+
+// FindRequestLog returns the newest request log in dir for which match reports true. Only
+// files containing needle are parsed, so a lookup by an ID found in the log text stays cheap
+// on large directories. It returns ErrRequestLogNotFound when no log matches.
+func FindRequestLog(dir, needle string, match func(*RequestLogRecord) bool) (*RequestLogRecord, error) {
+	var found *RequestLogRecord
+	err := ScanRequestLogs(dir, []string{needle}, func(record *RequestLogRecord) bool {
+		if match(record) {
+			found = record
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrRequestLogNotFound
+	}
+	return found, nil
+}
+
+// ScanRequestLogs reads the request logs in dir once, newest first, and passes each one
+// containing any of needles, or every one when needles is empty, to fn until it returns
+// false. A missing dir holds no logs.
+func ScanRequestLogs(dir string, needles []string, fn func(*RequestLogRecord) bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !requestLogName.MatchString(entry.Name()) {
+			continue
+		}
+		if info, errInfo := entry.Info(); errInfo == nil {
+			files = append(files, info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+	for _, info := range files {
+		data, errRead := readRequestLogFile(filepath.Join(dir, info.Name()))
+		if errRead != nil || !containsAny(data, needles) {
+			continue
+		}
+		if !fn(parseRequestLog(info.Name(), data)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func containsAny(data []byte, needles []string) bool {
+	if len(needles) == 0 {
+		return true
+	}
+	for _, needle := range needles {
+		if bytes.Contains(data, []byte(needle)) {
+			return true
+		}
+	}
+	return false
+}
+
+func readRequestLogFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	if !strings.HasSuffix(path, ".gz") {
+		return io.ReadAll(f)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = zr.Close() }()
+	return io.ReadAll(zr)
+}
+
+// parseRequestLog splits a request log into its sections. Note sections, which follow the
+// upstream response or the streamed chunks, are left out of the bodies.
+func parseRequestLog(name string, data []byte) *RequestLogRecord {
+	record := &RequestLogRecord{File: name, RequestHeaders: map[string]string{}, ResponseHeaders: map[string]string{}}
+	sections := make(map[string][]byte)
+	var current string
+	var body []byte
+	flush := func() {
+		if current != "" {
+			if _, seen := sections[current]; !seen {
+				sections[current] = bytes.TrimRight(body, "\n")
+			}
+		}
+	}
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		trimmed := bytes.TrimRight(line, "\n")
+		if m := requestLogSection.FindSubmatch(trimmed); m != nil {
+			flush()
+			current, body = string(m[1]), nil
+			continue
+		}
+		// The streaming writer sets the response off with a rule of '='.
+		if len(trimmed) > 0 && len(bytes.Trim(trimmed, "=")) == 0 {
+			continue
+		}
+		body = append(body, line...)
+	}
+	flush()
+
+	for _, line := range strings.Split(string(sections["REQUEST INFO"]), "\n") {
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "URL":
+			record.URL = value
+		case "Method":
+			record.Method = value
+		case "Timestamp":
+			record.Timestamp, _ = time.Parse(time.RFC3339Nano, value)
+		}
+	}
+	parseLogHeaders(string(sections["HEADERS"]), record.RequestHeaders)
+	record.RequestBody = sections["REQUEST BODY"]
+	record.APIRequest = sections["API REQUEST"]
+	record.APIResponse = sections["API RESPONSE"]
+
+	// The response section holds the status and headers, a blank line and the body.
+	head, rest, _ := strings.Cut(string(sections["RESPONSE"]), "\n\n")
+	status, headers, _ := strings.Cut(head, "\n")
+	record.Status, _ = strconv.Atoi(strings.TrimPrefix(status, "Status: "))
+	parseLogHeaders(headers, record.ResponseHeaders)
+	record.Response = []byte(rest)
+	return record
+}
+
+func parseLogHeaders(text string, headers map[string]string) {
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		if _, exists := headers[key]; !exists {
+			headers[key] = value
+		}
+	}
+}
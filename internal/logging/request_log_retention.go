@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// requestLogPruneInterval is the minimum time between two scans of the logs directory.
+const requestLogPruneInterval = 30 * time.Second
+
+// requestLogName matches the names generateFilename produces, compressed or not, so the
+// main log and its backups in the same directory are never touched.
+var requestLogName = regexp.MustCompile(`-\d{4}-\d{2}-\d{2}T\d{6}-\d{9}\.log(\.gz)?$`)
+
+// requestLogRetention compresses finished request logs and removes the oldest ones once
+// the configured limits are exceeded.
+type requestLogRetention struct {
+	mu        sync.Mutex
+	rotation  Rotation
+	lastPrune time.Time
+
+	// pruneMu keeps scans from overlapping.
+	pruneMu sync.Mutex
+}
+
+// SetRotation updates the limits applied to request log files. The next finished request
+// applies them.
+func (l *FileRequestLogger) SetRotation(rotation Rotation) {
+	l.retention.mu.Lock()
+	l.retention.rotation = rotation
+	l.retention.lastPrune = time.Time{}
+	l.retention.mu.Unlock()
+}
+
+// finish compresses the just written log file at path when enabled and prunes dir when the
+// last scan is old enough.
+func (r *requestLogRetention) finish(dir, path string) {
+	r.mu.Lock()
+	rotation := r.rotation
+	limited := rotation.MaxSize > 0 || rotation.MaxBackups > 0 || rotation.MaxAge > 0
+	due := limited && time.Since(r.lastPrune) >= requestLogPruneInterval
+	if due {
+		r.lastPrune = time.Now()
+	}
+	r.mu.Unlock()
+
+	if rotation.Compress {
+		if err := gzipFile(path); err != nil {
+			log.Warnf("request log: failed to compress %s: %v", filepath.Base(path), err)
+		}
+	}
+	if due {
+		r.prune(dir, rotation)
+	}
+}
+
+// prune removes request logs older than MaxAge days and, newest first, every file past
+// MaxBackups or past a running total of MaxSize megabytes.
+func (r *requestLogRetention) prune(dir string, rotation Rotation) {
+	r.pruneMu.Lock()
+	defer r.pruneMu.Unlock()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !requestLogName.MatchString(entry.Name()) {
+			continue
+		}
+		if info, errInfo := entry.Info(); errInfo == nil {
+			files = append(files, info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+
+	var cutoff time.Time
+	if rotation.MaxAge > 0 {
+		cutoff = time.Now().Add(-time.Duration(rotation.MaxAge) * 24 * time.Hour)
+	}
+	maxBytes := int64(rotation.MaxSize) * 1024 * 1024
+	var total int64
+	removed := 0
+	for i, info := range files {
+		total += info.Size()
+		expired := !cutoff.IsZero() && info.ModTime().Before(cutoff)
+		tooMany := rotation.MaxBackups > 0 && i >= rotation.MaxBackups
+		tooLarge := maxBytes > 0 && total > maxBytes
+		if !expired && !tooMany && !tooLarge {
+			continue
+		}
+		if errRemove := os.Remove(filepath.Join(dir, info.Name())); errRemove == nil {
+			removed++
+		} else if !os.IsNotExist(errRemove) {
+			log.Warnf("request log: failed to remove %s: %v", info.Name(), errRemove)
+		}
+	}
+	if removed > 0 {
+		log.Debugf("request log: removed %d old log files", removed)
+	}
+}
+
+// gzipFile replaces path with path.gz.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, src)
+	if errClose := zw.Close(); err == nil {
+		err = errClose
+	}
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -92,6 +92,9 @@ type FileRequestLogger struct {
 
 	// logsDir is the directory where log files are stored.
 	logsDir string
+
+	// retention bounds the request log files kept in logsDir.
+	retention requestLogRetention
 }
 
 // NewFileRequestLogger creates a new file-based request logger.
@@ -178,6 +181,7 @@ func (l *FileRequestLogger) LogRequest(url, method string, requestHeaders map[st
 	if err = os.WriteFile(filePath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write log file: %w", err)
 	}
+	go l.retention.finish(l.logsDir, filePath)
 
 	return nil
 }
@@ -226,6 +230,7 @@ func (l *FileRequestLogger) LogStreamingRequest(url, method string, headers map[
 		chunkChan: make(chan []byte, 100), // Buffered channel for async writes
 		closeChan: make(chan struct{}),
 		errorChan: make(chan error, 1),
+		onClose:   func() { l.retention.finish(l.logsDir, filePath) },
 	}
 
 	// Start async writer goroutine
@@ -329,18 +334,18 @@ func (l *FileRequestLogger) formatLogContent(url, method string, headers map[str
 	content.WriteString(l.formatRequestInfo(url, method, headers, body))
 
 	content.WriteString("=== API REQUEST ===\n")
-	content.Write(apiRequest)
+	content.WriteString(RedactSecrets(string(apiRequest)))
 	content.WriteString("\n\n")
 
 	for i := 0; i < len(apiResponseErrors); i++ {
 		content.WriteString("=== API ERROR RESPONSE ===\n")
 		content.WriteString(fmt.Sprintf("HTTP Status: %d\n", apiResponseErrors[i].StatusCode))
-		content.WriteString(apiResponseErrors[i].Error.Error())
+		content.WriteString(RedactSecrets(apiResponseErrors[i].Error.Error()))
 		content.WriteString("\n\n")
 	}
 
 	content.WriteString("=== API RESPONSE ===\n")
-	content.Write(apiResponse)
+	content.WriteString(RedactSecrets(string(apiResponse)))
 	content.WriteString("\n\n")
 
 	// Response section
@@ -350,13 +355,13 @@ func (l *FileRequestLogger) formatLogContent(url, method string, headers map[str
 	if responseHeaders != nil {
 		for key, values := range responseHeaders {
 			for _, value := range values {
-				content.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+				content.WriteString(fmt.Sprintf("%s: %s\n", key, RedactHeaderValue(key, value)))
 			}
 		}
 	}
 
 	content.WriteString("\n")
-	content.Write(response)
+	content.WriteString(RedactSecrets(string(response)))
 	content.WriteString("\n")
 
 	return content.String()
@@ -457,7 +462,7 @@ func (l *FileRequestLogger) formatRequestInfo(url, method string, headers map[st
 	var content strings.Builder
 
 	content.WriteString("=== REQUEST INFO ===\n")
-	content.WriteString(fmt.Sprintf("URL: %s\n", url))
+	content.WriteString(fmt.Sprintf("URL: %s\n", RedactSecrets(url)))
 	content.WriteString(fmt.Sprintf("Method: %s\n", method))
 	content.WriteString(fmt.Sprintf("Timestamp: %s\n", time.Now().Format(time.RFC3339Nano)))
 	content.WriteString("\n")
@@ -465,13 +470,13 @@ func (l *FileRequestLogger) formatRequestInfo(url, method string, headers map[st
 	content.WriteString("=== HEADERS ===\n")
 	for key, values := range headers {
 		for _, value := range values {
-			content.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+			content.WriteString(fmt.Sprintf("%s: %s\n", key, RedactHeaderValue(key, value)))
 		}
 	}
 	content.WriteString("\n")
 
 	content.WriteString("=== REQUEST BODY ===\n")
-	content.Write(body)
+	content.WriteString(RedactSecrets(string(body)))
 	content.WriteString("\n\n")
 
 	return content.String()
@@ -494,6 +499,9 @@ type FileStreamingLogWriter struct {
 
 	// statusWritten indicates whether the response status has been written.
 	statusWritten bool
+
+	// onClose runs once the file is closed.
+	onClose func()
 }
 
 // WriteChunkAsync writes a response chunk asynchronously (non-blocking).
@@ -537,7 +545,7 @@ func (w *FileStreamingLogWriter) WriteStatus(status int, headers map[string][]st
 
 	for key, values := range headers {
 		for _, value := range values {
-			content.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+			content.WriteString(fmt.Sprintf("%s: %s\n", key, RedactHeaderValue(key, value)))
 		}
 	}
 	content.WriteString("\n")
@@ -565,7 +573,12 @@ func (w *FileStreamingLogWriter) Close() error {
 	}
 
 	if w.file != nil {
-		return w.file.Close()
+		err := w.file.Close()
+		w.file = nil
+		if w.onClose != nil {
+			go w.onClose()
+		}
+		return err
 	}
 
 	return nil
@@ -578,7 +591,7 @@ func (w *FileStreamingLogWriter) asyncWriter() {
 
 	for chunk := range w.chunkChan {
 		if w.file != nil {
-			_, _ = w.file.Write(chunk)
+			_, _ = w.file.WriteString(RedactSecrets(string(chunk)))
 		}
 	}
 }
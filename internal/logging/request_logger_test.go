@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testUpstreamKey = "sk-ant-REDACTED"
+	testGoogleKey   = "AIzaSyA1234567890abcdefghijk"
+)
+
+func readOnlyLog(t *testing.T, dir string) string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("log files = %d, want 1", len(entries))
+	}
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func assertRedacted(t *testing.T, content string) {
+	t.Helper()
+	for _, secret := range []string{testUpstreamKey, testGoogleKey, "refresh-secret"} {
+		if strings.Contains(content, secret) {
+			t.Errorf("request log contains %q:\n%s", secret, content)
+		}
+	}
+	if !strings.Contains(content, redactedValue) {
+		t.Errorf("request log has no redaction marker:\n%s", content)
+	}
+}
+
+func TestLogRequestRedactsBodies(t *testing.T) {
+	dir := t.TempDir()
+	logger := NewFileRequestLogger(true, dir, "")
+	err := logger.LogRequest(
+		"/v1beta/models/gemini-2.5-pro:generateContent?key="+testGoogleKey, "POST",
+		map[string][]string{"Content-Type": {"application/json"}},
+		[]byte(`{"messages":[{"role":"user","content":"my key is `+testUpstreamKey+`"}]}`),
+		200, map[string][]string{"Content-Type": {"application/json"}},
+		[]byte(`{"content":"echo `+testUpstreamKey+`"}`),
+		[]byte(`{"api_key":"`+testGoogleKey+`"}`),
+		[]byte(`{"refresh_token":"refresh-secret"}`),
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRedacted(t, readOnlyLog(t, dir))
+}
+
+func TestStreamingLogRedactsBodies(t *testing.T) {
+	dir := t.TempDir()
+	logger := NewFileRequestLogger(true, dir, "")
+	writer, err := logger.LogStreamingRequest("/v1/chat/completions", "POST", nil, []byte(`{"messages":[{"content":"`+testUpstreamKey+`"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.WriteStatus(200, nil); err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteChunkAsync([]byte(`data: {"delta":"` + testGoogleKey + `","refresh_token":"refresh-secret"}` + "\n\n"))
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertRedacted(t, readOnlyLog(t, dir))
+}
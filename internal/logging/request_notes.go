@@ -0,0 +1,205 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin context keys collecting notes for extra request log sections.
+const (
+	outputCapKey  = "API_OUTPUT_CAP"
+	retrievalKey  = "API_RETRIEVAL"
+	toolResultKey = "API_TOOL_RESULT_LIMIT"
+	upstreamKey   = "API_UPSTREAM_HEADERS"
+	moderationKey = "API_MODERATION"
+	safetyKey     = "API_SAFETY_SETTINGS"
+	attemptsKey   = "API_ATTEMPTS"
+	accountKey    = "API_ACCOUNT"
+	policyKey     = "API_MODEL_POLICY"
+	mirrorKey     = "API_MIRROR"
+	// streamErrorKey holds the note and streamErrorStatusKey the status of the error that
+	// ended a stream after its response had begun.
+	streamErrorKey       = "API_STREAM_ERROR"
+	streamErrorStatusKey = "API_STREAM_ERROR_STATUS"
+)
+
+// RecordOutputCapNote notes in the request log of ctx that the output token cap changed the
+// request or cut off its response.
+func RecordOutputCapNote(ctx context.Context, note string) {
+	appendNote(ctx, outputCapKey, note)
+}
+
+// RecordRetrievalNote notes in the request log of ctx which document store chunks were
+// added to the request.
+func RecordRetrievalNote(ctx context.Context, note string) {
+	appendNote(ctx, retrievalKey, note)
+}
+
+// RecordToolResultNote notes in the request log of ctx how an oversized tool result was
+// handled.
+func RecordToolResultNote(ctx context.Context, note string) {
+	appendNote(ctx, toolResultKey, note)
+}
+
+// RecordUpstreamHeaderNote notes in the request log of ctx a header the upstream request was
+// sent with.
+func RecordUpstreamHeaderNote(ctx context.Context, note string) {
+	appendNote(ctx, upstreamKey, note)
+}
+
+// RecordModerationNote notes in the request log of ctx the moderation rule that ended the
+// stream.
+func RecordModerationNote(ctx context.Context, note string) {
+	appendNote(ctx, moderationKey, note)
+}
+
+// RecordSafetyNote notes in the request log of ctx a safety threshold the client sent that
+// was raised to the configured floor.
+func RecordSafetyNote(ctx context.Context, note string) {
+	appendNote(ctx, safetyKey, note)
+}
+
+// RecordAttemptNote notes in the request log of ctx one upstream attempt of the request.
+func RecordAttemptNote(ctx context.Context, note string) {
+	appendNote(ctx, attemptsKey, note)
+}
+
+// RecordAccountNote notes in the request log of ctx the label of the auth that served the
+// request.
+func RecordAccountNote(ctx context.Context, note string) {
+	appendNote(ctx, accountKey, note)
+}
+
+// RecordModelPolicyNote notes in the request log of ctx a decision of the model policy of
+// the calling API key.
+func RecordModelPolicyNote(ctx context.Context, note string) {
+	appendNote(ctx, policyKey, note)
+}
+
+// RecordMirrorNote notes in the request log of ctx the shadow request started for it.
+func RecordMirrorNote(ctx context.Context, note string) {
+	appendNote(ctx, mirrorKey, note)
+}
+
+// RecordStreamError notes on c that the stream was ended by an error with status after the
+// response had begun with a 200, so the logs can report the status the client only saw in
+// the error event.
+func RecordStreamError(c *gin.Context, status int, message string) {
+	if c == nil {
+		return
+	}
+	c.Set(streamErrorStatusKey, status)
+	c.Set(streamErrorKey, []string{fmt.Sprintf("status %d: %s", status, message)})
+}
+
+// StreamErrorStatus returns the status recorded by RecordStreamError for c, or 0.
+func StreamErrorStatus(c *gin.Context) int {
+	if c == nil {
+		return 0
+	}
+	return c.GetInt(streamErrorStatusKey)
+}
+
+// OutputCapSection returns the request log section listing the output cap notes recorded on
+// c, or "" when there are none.
+func OutputCapSection(c *gin.Context) string {
+	return noteSection(c, outputCapKey, "OUTPUT CAP")
+}
+
+// RetrievalSection returns the request log section listing the retrieval notes recorded on
+// c, or "" when there are none.
+func RetrievalSection(c *gin.Context) string {
+	return noteSection(c, retrievalKey, "RETRIEVAL")
+}
+
+// ToolResultSection returns the request log section listing the tool result limit notes
+// recorded on c, or "" when there are none.
+func ToolResultSection(c *gin.Context) string {
+	return noteSection(c, toolResultKey, "TOOL RESULT LIMIT")
+}
+
+// UpstreamHeaderSection returns the request log section listing the upstream header notes
+// recorded on c, or "" when there are none.
+func UpstreamHeaderSection(c *gin.Context) string {
+	return noteSection(c, upstreamKey, "UPSTREAM HEADERS")
+}
+
+// ModerationSection returns the request log section listing the moderation notes recorded
+// on c, or "" when there are none.
+func ModerationSection(c *gin.Context) string {
+	return noteSection(c, moderationKey, "MODERATION")
+}
+
+// SafetySection returns the request log section listing the safety settings notes recorded
+// on c, or "" when there are none.
+func SafetySection(c *gin.Context) string {
+	return noteSection(c, safetyKey, "SAFETY SETTINGS")
+}
+
+// AttemptsSection returns the request log section listing the upstream attempts recorded on
+// c, or "" when there are none.
+func AttemptsSection(c *gin.Context) string {
+	return noteSection(c, attemptsKey, "ATTEMPTS")
+}
+
+// StreamErrorSection returns the request log section describing the error that ended the
+// stream, or "" when it was not ended by one.
+func StreamErrorSection(c *gin.Context) string {
+	return noteSection(c, streamErrorKey, "STREAM ERROR")
+}
+
+// AccountSection returns the request log section naming the auth label recorded on c, or ""
+// when there is none.
+func AccountSection(c *gin.Context) string {
+	return noteSection(c, accountKey, "ACCOUNT")
+}
+
+// ModelPolicySection returns the request log section listing the model policy decisions
+// recorded on c, or "" when there are none.
+func ModelPolicySection(c *gin.Context) string {
+	return noteSection(c, policyKey, "MODEL POLICY")
+}
+
+// MirrorSection returns the request log section naming the shadow request recorded on c,
+// or "" when there is none.
+func MirrorSection(c *gin.Context) string {
+	return noteSection(c, mirrorKey, "MIRROR")
+}
+
+// appendNote adds note under key to the gin context of ctx, which may also be the gin
+// context itself.
+func appendNote(ctx context.Context, key, note string) {
+	if ctx == nil || note == "" {
+		return
+	}
+	ginCtx, ok := ctx.(*gin.Context)
+	if !ok {
+		ginCtx, ok = ctx.Value("gin").(*gin.Context)
+	}
+	if !ok || ginCtx == nil {
+		return
+	}
+	var notes []string
+	if existing, exists := ginCtx.Get(key); exists {
+		notes, _ = existing.([]string)
+	}
+	ginCtx.Set(key, append(notes, note))
+}
+
+func noteSection(c *gin.Context, key, title string) string {
+	if c == nil {
+		return ""
+	}
+	existing, exists := c.Get(key)
+	if !exists {
+		return ""
+	}
+	notes, _ := existing.([]string)
+	if len(notes) == 0 {
+		return ""
+	}
+	return "=== " + title + " ===\n" + strings.Join(notes, "\n") + "\n\n"
+}
@@ -0,0 +1,180 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin context keys holding the backend serving the current request.
+const (
+	requestProviderKey = "REQUEST_PROVIDER"
+	requestModelKey    = "REQUEST_MODEL"
+	fingerprintKey     = "REQUEST_SYSTEM_FINGERPRINT"
+	metadataKey        = "REQUEST_METADATA"
+	responseTaggedKey  = "REQUEST_RESPONSE_TAGGED"
+	requestCostKey     = "REQUEST_COST"
+	requestAccountKey  = "REQUEST_ACCOUNT"
+	moderationRuleKey  = "REQUEST_MODERATION_RULE"
+)
+
+// RecordRequestTarget notes on the Gin context of ctx which provider and model served the
+// request. Later calls, e.g. after a retry on another provider, replace earlier ones.
+func RecordRequestTarget(ctx context.Context, provider, model string) {
+	if ctx == nil {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	ginCtx.Set(requestProviderKey, provider)
+	ginCtx.Set(requestModelKey, model)
+}
+
+// RequestTarget returns the provider and model recorded for c, "-" when unknown.
+func RequestTarget(c *gin.Context) (provider, model string) {
+	provider, model = "-", "-"
+	if c == nil {
+		return provider, model
+	}
+	if v := c.GetString(requestProviderKey); v != "" {
+		provider = v
+	}
+	if v := c.GetString(requestModelKey); v != "" {
+		model = v
+	}
+	return provider, model
+}
+
+// RecordSystemFingerprint notes on the Gin context of ctx the system fingerprint of the
+// backend serving the request, replacing any earlier one.
+func RecordSystemFingerprint(ctx context.Context, fingerprint string) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Set(fingerprintKey, fingerprint)
+	}
+}
+
+// SystemFingerprint returns the system fingerprint recorded for c, or "".
+func SystemFingerprint(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(fingerprintKey)
+}
+
+// RecordRequestMetadata notes on c the metadata the client attached to the request, so usage
+// records carry it.
+func RecordRequestMetadata(c *gin.Context, metadata map[string]string) {
+	if c == nil || len(metadata) == 0 {
+		return
+	}
+	c.Set(metadataKey, metadata)
+}
+
+// RequestMetadata returns the client metadata recorded for the request of ctx, or nil.
+func RequestMetadata(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return nil
+	}
+	if v, exists := ginCtx.Get(metadataKey); exists {
+		metadata, _ := v.(map[string]string)
+		return metadata
+	}
+	return nil
+}
+
+// RecordResponseTagged notes on c that the response gets the audit tag of response-tag,
+// carrying the reference ref, so usage records show both.
+func RecordResponseTagged(c *gin.Context, ref string) {
+	if c == nil {
+		return
+	}
+	c.Set(responseTaggedKey, ref)
+}
+
+// ResponseTagRef returns the reference in the audit tag of the response to the request of
+// ctx, or "" when the response is not tagged.
+func ResponseTagRef(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return ""
+	}
+	return ginCtx.GetString(responseTaggedKey)
+}
+
+// RecordRequestCost notes on the Gin context of ctx the estimated cost of the request in
+// dollars.
+func RecordRequestCost(ctx context.Context, cost float64) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Set(requestCostKey, cost)
+	}
+}
+
+// RequestCost returns the cost recorded for c, and false when none was.
+func RequestCost(c *gin.Context) (float64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	v, ok := c.Get(requestCostKey)
+	if !ok {
+		return 0, false
+	}
+	cost, ok := v.(float64)
+	return cost, ok
+}
+
+// RecordRequestAccount notes on the Gin context of ctx the label of the auth serving the
+// request. Later calls, e.g. after a retry on another auth, replace earlier ones.
+func RecordRequestAccount(ctx context.Context, label string) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Set(requestAccountKey, label)
+	}
+}
+
+// RequestAccount returns the auth label recorded for c, or "".
+func RequestAccount(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(requestAccountKey)
+}
+
+// RecordModerationRule notes on the Gin context of ctx the moderation rule that ended the
+// stream, so the usage record of the request is marked as filtered.
+func RecordModerationRule(ctx context.Context, rule string) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Set(moderationRuleKey, rule)
+	}
+}
+
+// ModerationRule returns the moderation rule recorded for the request of ctx, or "".
+func ModerationRule(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return ""
+	}
+	return ginCtx.GetString(moderationRuleKey)
+}
@@ -0,0 +1,32 @@
+package logging
+
+import "context"
+
+// ShadowRequest identifies a request replayed against a mirror target after its response
+// was sent. Shadow requests run outside any Gin context, so what their usage records need
+// from the primary request travels with them.
+type ShadowRequest struct {
+	// PrimaryRequestID identifies the request being mirrored. It is generated by the proxy,
+	// since clients choose their X-Request-ID, and noted in the primary's request log.
+	PrimaryRequestID string
+	// APIKey is the client API key of the request being mirrored.
+	APIKey string
+}
+
+type shadowRequestKey struct{}
+
+// WithShadowRequest returns a context marking the requests made with it as the shadow of
+// shadow.PrimaryRequestID.
+func WithShadowRequest(ctx context.Context, shadow ShadowRequest) context.Context {
+	return context.WithValue(ctx, shadowRequestKey{}, shadow)
+}
+
+// ShadowRequestFrom returns the shadow request ctx belongs to, and false for ordinary
+// requests.
+func ShadowRequestFrom(ctx context.Context) (ShadowRequest, bool) {
+	if ctx == nil {
+		return ShadowRequest{}, false
+	}
+	shadow, ok := ctx.Value(shadowRequestKey{}).(ShadowRequest)
+	return shadow, ok
+}
@@ -0,0 +1,137 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// legacyCookieKeys maps cookie names stored verbatim by v5 Gemini Web auth files onto the
+// current field names, in the order they are tried.
+var legacyCookieKeys = [][2]string{
+	{"__Secure-1PSID", "secure_1psid"},
+	{"__Secure-1PSIDTS", "secure_1psidts"},
+	{"Secure_1PSID", "secure_1psid"},
+	{"Secure_1PSIDTS", "secure_1psidts"},
+}
+
+func migrateAuthFiles(authDir, backupDir string) ([]Result, error) {
+	files, err := jsonFiles(authDir)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read auth dir: %w", err)
+	}
+	var results []Result
+	for _, path := range files {
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			results = append(results, Result{Kind: KindAuth, Path: path, Action: ActionFailed, Error: errRead.Error()})
+			continue
+		}
+		metadata := make(map[string]any)
+		if errUnmarshal := json.Unmarshal(data, &metadata); errUnmarshal != nil {
+			// Not an auth file this pass understands; leave it for the watcher to report.
+			continue
+		}
+		changes, errUpgrade := upgradeAuthMetadata(metadata)
+		if len(changes) == 0 && errUpgrade == nil {
+			continue
+		}
+		result := Result{Kind: KindAuth, Path: path, Changes: changes}
+		if errUpgrade != nil {
+			result.Action = ActionFailed
+			result.Error = errUpgrade.Error()
+			results = append(results, result)
+			continue
+		}
+		backup, errBackup := backupFile(path, backupDir)
+		if errBackup != nil {
+			result.Action = ActionFailed
+			result.Error = fmt.Sprintf("backup failed: %v", errBackup)
+			results = append(results, result)
+			continue
+		}
+		result.Backup = backup
+		out, errMarshal := json.Marshal(metadata)
+		if errMarshal == nil {
+			errMarshal = writeFile(path, out)
+		}
+		if errMarshal != nil {
+			result.Action = ActionFailed
+			result.Error = errMarshal.Error()
+		} else {
+			result.Action = ActionMigrated
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// upgradeAuthMetadata rewrites a v5 auth file in place and describes every change. Unknown
+// fields, emails, project IDs and cookie values are kept as they are. A file already in the
+// current layout yields no changes.
+func upgradeAuthMetadata(metadata map[string]any) ([]string, error) {
+	var changes []string
+	for _, pair := range legacyCookieKeys {
+		legacy, current := pair[0], pair[1]
+		value, ok := metadata[legacy]
+		if !ok {
+			continue
+		}
+		if _, exists := metadata[current]; !exists {
+			metadata[current] = value
+			changes = append(changes, fmt.Sprintf("renamed %s to %s", legacy, current))
+		} else {
+			changes = append(changes, fmt.Sprintf("dropped duplicate %s", legacy))
+		}
+		delete(metadata, legacy)
+	}
+
+	// v5 Gemini OAuth files could carry the token as an encoded JSON string.
+	if raw, ok := metadata["token"].(string); ok && strings.HasPrefix(strings.TrimSpace(raw), "{") {
+		var token map[string]any
+		if err := json.Unmarshal([]byte(raw), &token); err == nil {
+			metadata["token"] = token
+			changes = append(changes, "decoded token object")
+		}
+	}
+
+	if t, _ := metadata["type"].(string); strings.TrimSpace(t) == "" {
+		inferred := inferAuthType(metadata)
+		if inferred == "" {
+			if len(changes) == 0 {
+				return nil, nil
+			}
+			return changes, fmt.Errorf("cannot infer auth type")
+		}
+		metadata["type"] = inferred
+		changes = append(changes, fmt.Sprintf("set type to %s", inferred))
+	}
+	return changes, nil
+}
+
+// inferAuthType guesses the provider of a v5 auth file written without a type field.
+func inferAuthType(metadata map[string]any) string {
+	has := func(key string) bool {
+		v, ok := metadata[key]
+		if !ok || v == nil {
+			return false
+		}
+		if s, isString := v.(string); isString {
+			return strings.TrimSpace(s) != ""
+		}
+		return true
+	}
+	switch {
+	case has("secure_1psid"):
+		return "gemini-web"
+	case has("token") && has("project_id"):
+		return "gemini"
+	case has("resource_url"):
+		return "qwen"
+	case has("id_token") && has("account_id"):
+		return "codex"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,210 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+)
+
+// Suffixes of the JSON conversation stores written by v5 next to each account. Data files
+// hold {"items":{...},"index":{...}}; the others hold the account metadata map, keyed by
+// account-meta|<email>|<model>. Names alone are not enough to tell a v5 store, so
+// isLegacyConvStore checks the content too.
+var (
+	legacyConvDataSuffixes = []string{".conv_data.json", ".data.json"}
+	legacyConvMetaSuffixes = []string{".conv.json", ".json"}
+)
+
+// legacyConvData is the v5 JSON layout of conversation records and their hash index.
+type legacyConvData struct {
+	Items map[string]geminiwebapi.ConversationRecord `json:"items"`
+	Index map[string]string                          `json:"index"`
+}
+
+func migrateConvStores(convDir, authDir, backupDir string) ([]Result, error) {
+	files, err := jsonFiles(convDir)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read conv dir: %w", err)
+	}
+	var results []Result
+	for _, path := range files {
+		name := filepath.Base(path)
+		base, isData := legacyConvBase(name)
+		if base == "" {
+			continue
+		}
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			results = append(results, Result{Kind: KindConv, Path: path, Action: ActionFailed, Error: errRead.Error()})
+			continue
+		}
+		if !isLegacyConvStore(data, isData) {
+			// Some other JSON file; it is neither converted nor reported.
+			continue
+		}
+		result := Result{Kind: KindConv, Path: path}
+		changes, errConvert := convertConvFile(data, base, isData, convDir, authDir)
+		result.Changes = changes
+		if errConvert != nil {
+			result.Action = ActionFailed
+			result.Error = errConvert.Error()
+			results = append(results, result)
+			continue
+		}
+		// Move the original out of the conv dir so the next pass does not convert it again.
+		backup, errBackup := backupFile(path, backupDir)
+		if errBackup == nil {
+			errBackup = os.Remove(path)
+		}
+		if errBackup != nil {
+			result.Action = ActionFailed
+			result.Error = fmt.Sprintf("converted but failed to move original: %v", errBackup)
+		} else {
+			result.Action = ActionMigrated
+			result.Backup = backup
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// legacyConvBase returns the account base name of a v5 conversation store file and whether
+// it holds conversation data rather than account metadata.
+func legacyConvBase(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, suffix := range legacyConvDataSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return name[:len(name)-len(suffix)], true
+		}
+	}
+	for _, suffix := range legacyConvMetaSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return name[:len(name)-len(suffix)], false
+		}
+	}
+	return "", false
+}
+
+// isLegacyConvStore reports whether data has the layout of a v5 conversation data store, or
+// of a v5 account metadata store when isData is false. An empty metadata map is not taken
+// for one, as any JSON object without fields would match it.
+func isLegacyConvStore(data []byte, isData bool) bool {
+	if isData {
+		var legacy map[string]json.RawMessage
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return false
+		}
+		var items map[string]json.RawMessage
+		return legacy["items"] != nil && json.Unmarshal(legacy["items"], &items) == nil && items != nil
+	}
+	var legacy map[string][]string
+	if err := json.Unmarshal(data, &legacy); err != nil || len(legacy) == 0 {
+		return false
+	}
+	for key := range legacy {
+		if !strings.HasPrefix(key, "account-meta|") {
+			return false
+		}
+	}
+	return true
+}
+
+// convertConvFile merges one v5 store into the bolt file of its account. Entries already in
+// the bolt file win, so converting the same data twice changes nothing.
+func convertConvFile(data []byte, base string, isData bool, convDir, authDir string) ([]string, error) {
+	boltPath := filepath.Join(convDir, base+".bolt")
+	if !isData {
+		var legacy map[string][]string
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, fmt.Errorf("invalid account metadata store: %w", err)
+		}
+		store, errLoad := geminiwebapi.LoadConvStore(boltPath)
+		if errLoad != nil {
+			return nil, errLoad
+		}
+		added := 0
+		for key, meta := range legacy {
+			key = rekeyAccountMeta(key, base)
+			if _, exists := store[key]; exists {
+				continue
+			}
+			store[key] = meta
+			added++
+		}
+		if err := geminiwebapi.SaveConvStore(boltPath, store); err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("merged %d account metadata entries into %s", added, filepath.Base(boltPath))}, nil
+	}
+
+	var legacy legacyConvData
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, fmt.Errorf("invalid conversation store: %w", err)
+	}
+	items, index, err := geminiwebapi.LoadConvData(boltPath)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	clientID := stableClientIDFor(authDir, base)
+	if clientID == "" {
+		changes = append(changes, "no matching gemini-web auth file; records kept under their v5 keys")
+	}
+	added := 0
+	for key, rec := range legacy.Items {
+		if clientID != "" {
+			rec.ClientID = clientID
+			key = geminiwebapi.HashConversation(clientID, rec.Model, rec.Messages)
+		}
+		if _, exists := items[key]; exists {
+			continue
+		}
+		items[key] = rec
+		index["hash:"+key] = key
+		added++
+	}
+	if clientID == "" {
+		for k, v := range legacy.Index {
+			if _, exists := index[k]; !exists {
+				index[k] = v
+			}
+		}
+	}
+	if err = geminiwebapi.SaveConvData(boltPath, items, index); err != nil {
+		return nil, err
+	}
+	changes = append(changes, fmt.Sprintf("merged %d conversation records into %s", added, filepath.Base(boltPath)))
+	return changes, nil
+}
+
+// rekeyAccountMeta rewrites an account metadata key of the form account-meta|<id>|<model>
+// so <id> is the account base name used by v6 instead of the v5 email.
+func rekeyAccountMeta(key, base string) string {
+	parts := strings.SplitN(key, "|", 3)
+	if len(parts) != 3 || parts[0] != "account-meta" {
+		return key
+	}
+	return geminiwebapi.AccountMetaKey(base, parts[2])
+}
+
+// stableClientIDFor returns the conversation client ID of the gemini-web account stored as
+// <base>.json in authDir, or "" when there is no such account.
+func stableClientIDFor(authDir, base string) string {
+	data, err := os.ReadFile(filepath.Join(authDir, base+".json"))
+	if err != nil {
+		return ""
+	}
+	var metadata map[string]any
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		return ""
+	}
+	psid, _ := metadata["secure_1psid"].(string)
+	if strings.TrimSpace(psid) == "" {
+		return ""
+	}
+	return geminiwebapi.StableClientID(psid)
+}
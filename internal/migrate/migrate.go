@@ -0,0 +1,183 @@
+// Package migrate upgrades auth files and Gemini Web conversation stores written by v5
+// releases to the layout used by v6. Every pass is idempotent: files already in the current
+// layout are left alone, and originals are backed up before anything is rewritten.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// workDirName holds backups and reports inside the auth dir. The watcher ignores
+	// sub-directories, so nothing in it is mistaken for an auth file.
+	workDirName = ".migrate"
+
+	// Result kinds.
+	KindAuth = "auth"
+	KindConv = "conv"
+
+	// Result actions.
+	ActionMigrated = "migrated"
+	ActionFailed   = "failed"
+)
+
+// Options locates the data to migrate.
+type Options struct {
+	// AuthDir is the directory holding auth files.
+	AuthDir string
+	// ConvDir is the directory holding Gemini Web conversation stores.
+	ConvDir string
+	// Now overrides the clock used to name backups and reports.
+	Now func() time.Time
+}
+
+// Result reports what happened to one legacy file.
+type Result struct {
+	Kind    string   `json:"kind"`
+	Path    string   `json:"path"`
+	Action  string   `json:"action"`
+	Changes []string `json:"changes,omitempty"`
+	Backup  string   `json:"backup,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Report summarises one migration pass.
+type Report struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	AuthDir    string    `json:"auth_dir"`
+	ConvDir    string    `json:"conv_dir"`
+	Migrated   int       `json:"migrated"`
+	Failed     int       `json:"failed"`
+	Results    []Result  `json:"results"`
+	// Path is where the report was written, empty when nothing needed migrating.
+	Path string `json:"-"`
+}
+
+// DefaultConvDir returns the conversation store directory used by the Gemini Web provider.
+func DefaultConvDir() string {
+	wd, err := os.Getwd()
+	if err != nil || wd == "" {
+		wd = "."
+	}
+	return filepath.Join(wd, "conv")
+}
+
+// Run migrates every legacy auth file and conversation store found under opts. A report
+// is written to the .migrate directory of the auth dir whenever at least one file was
+// migrated or failed; a pass with nothing to do leaves no trace.
+func Run(opts Options) (*Report, error) {
+	if strings.TrimSpace(opts.AuthDir) == "" {
+		return nil, fmt.Errorf("migrate: auth dir is empty")
+	}
+	if opts.ConvDir == "" {
+		opts.ConvDir = DefaultConvDir()
+	}
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	started := now()
+	report := &Report{StartedAt: started, AuthDir: opts.AuthDir, ConvDir: opts.ConvDir}
+	backupDir := filepath.Join(opts.AuthDir, workDirName, "backup-"+started.UTC().Format("20060102T150405Z"))
+
+	authResults, err := migrateAuthFiles(opts.AuthDir, backupDir)
+	if err != nil {
+		return nil, err
+	}
+	report.Results = append(report.Results, authResults...)
+
+	convResults, err := migrateConvStores(opts.ConvDir, opts.AuthDir, backupDir)
+	if err != nil {
+		return nil, err
+	}
+	report.Results = append(report.Results, convResults...)
+
+	for _, r := range report.Results {
+		switch r.Action {
+		case ActionMigrated:
+			report.Migrated++
+		case ActionFailed:
+			report.Failed++
+		}
+	}
+	report.FinishedAt = now()
+	if len(report.Results) == 0 {
+		return report, nil
+	}
+	reportPath := filepath.Join(opts.AuthDir, workDirName, "report-"+started.UTC().Format("20060102T150405Z")+".json")
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return report, err
+	}
+	if err = writeFile(reportPath, data); err != nil {
+		return report, fmt.Errorf("migrate: failed to write report: %w", err)
+	}
+	report.Path = reportPath
+	return report, nil
+}
+
+// backupFile copies src into backupDir, keeping its base name.
+func backupFile(src, backupDir string) (string, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(backupDir, filepath.Base(src))
+	if err = writeFile(dst, data); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// writeFile writes data next to path and renames it into place.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".migrate-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = os.Chmod(tmpName, 0o600); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// jsonFiles lists the regular .json files directly under dir, sorted by name.
+func jsonFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".json") {
+			continue
+		}
+		out = append(out, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,85 @@
+package migrate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+)
+
+func writeFixture(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunMigratesLegacyFilesOnce(t *testing.T) {
+	dir := t.TempDir()
+	authDir, convDir := filepath.Join(dir, "auth"), filepath.Join(dir, "conv")
+	writeFixture(t, filepath.Join(authDir, "web.json"), `{"__Secure-1PSID":"psid","__Secure-1PSIDTS":"psidts","email":"user@example.com"}`)
+	writeFixture(t, filepath.Join(authDir, "current.json"), `{"type":"claude","email":"c@example.com"}`)
+	writeFixture(t, filepath.Join(convDir, "web.conv_data.json"), `{"items":{"old-key":{"model":"gemini-2.5-pro","client_id":"v5","messages":[{"role":"user","content":"hi"}]}},"index":{"hash:old-key":"old-key"}}`)
+	writeFixture(t, filepath.Join(convDir, "web.conv.json"), `{"account-meta|user@example.com|gemini-2.5-pro":["c_1","r_1"]}`)
+	const unrelated = `{"note":"not a conversation store"}`
+	writeFixture(t, filepath.Join(convDir, "notes.json"), unrelated)
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	report, err := Run(Options{AuthDir: authDir, ConvDir: convDir, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Migrated != 3 || report.Failed != 0 || report.Path == "" {
+		t.Fatalf("report = %+v, want the auth file and both stores migrated", report)
+	}
+
+	var auth map[string]any
+	data, _ := os.ReadFile(filepath.Join(authDir, "web.json"))
+	_ = json.Unmarshal(data, &auth)
+	if auth["secure_1psid"] != "psid" || auth["secure_1psidts"] != "psidts" || auth["type"] != "gemini-web" || auth["email"] != "user@example.com" {
+		t.Fatalf("migrated auth file = %v", auth)
+	}
+	if _, errStat := os.Stat(filepath.Join(authDir, workDirName, "backup-20260102T030405Z", "web.json")); errStat != nil {
+		t.Fatalf("auth file not backed up: %v", errStat)
+	}
+
+	clientID := geminiwebapi.StableClientID("psid")
+	items, _, err := geminiwebapi.LoadConvData(filepath.Join(convDir, "web.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := geminiwebapi.HashConversation(clientID, "gemini-2.5-pro", []geminiwebapi.StoredMessage{{Role: "user", Content: "hi"}})
+	if rec, ok := items[key]; !ok || rec.ClientID != clientID {
+		t.Fatalf("conversation records = %v, want the record under its v6 hash", items)
+	}
+	store, err := geminiwebapi.LoadConvStore(filepath.Join(convDir, "web.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := store[geminiwebapi.AccountMetaKey("web", "gemini-2.5-pro")]; len(meta) != 2 {
+		t.Fatalf("account metadata = %v, want it keyed by the account base name", store)
+	}
+
+	if data, _ = os.ReadFile(filepath.Join(convDir, "notes.json")); string(data) != unrelated {
+		t.Fatalf("unrelated JSON file changed to %s", data)
+	}
+	for _, r := range report.Results {
+		if filepath.Base(r.Path) == "notes.json" || filepath.Base(r.Path) == "current.json" {
+			t.Fatalf("report lists %s, which is not a legacy file", r.Path)
+		}
+	}
+
+	again, err := Run(Options{AuthDir: authDir, ConvDir: convDir, Now: func() time.Time { return now.Add(time.Hour) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Results) != 0 || again.Path != "" {
+		t.Fatalf("second pass = %+v, want nothing to do and no report", again)
+	}
+}
@@ -0,0 +1,5 @@
+package misc
+
+// ProxyVersion is the version of the running proxy binary. The server entry point sets it
+// from its build-time version before serving.
+var ProxyVersion = "dev"
@@ -0,0 +1,217 @@
+// Package modelversion tracks the upstream identity of each served model, such as Gemini's
+// modelVersion or the snapshot name Claude reports, and notices when a provider swaps the
+// model behind a name. The last identity of every (provider, model) pair and the changes
+// seen so far are kept in one JSON file, written only when something changes.
+package modelversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DirName is the directory inside the auth dir holding the store.
+	DirName  = ".model-versions"
+	fileName = "model-versions.json"
+
+	// maxChanges bounds the changes kept per model.
+	maxChanges = 20
+
+	webhookTimeout = 10 * time.Second
+)
+
+// Change is a switch of the upstream identity of a model.
+type Change struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Previous  string    `json:"previous"`
+	Current   string    `json:"current"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Entry is the identity last observed for a model, with the changes that led to it, oldest
+// first.
+type Entry struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Identity  string    `json:"identity"`
+	FirstSeen time.Time `json:"first_seen"`
+	Changes   []Change  `json:"changes,omitempty"`
+}
+
+// Tracker records model identities and reports changes.
+type Tracker struct {
+	mu         sync.RWMutex
+	enabled    bool
+	path       string
+	webhookURL string
+	entries    map[string]*Entry
+}
+
+var defaultTracker = &Tracker{entries: map[string]*Entry{}}
+
+// Default returns the process-wide tracker.
+func Default() *Tracker { return defaultTracker }
+
+// Configure enables or disables tracking, loading the store kept under authDir when it
+// differs from the current one. Changes are POSTed as JSON to webhookURL unless it is empty.
+func (t *Tracker) Configure(enabled bool, authDir, webhookURL string) {
+	path := ""
+	if authDir != "" {
+		path = filepath.Join(authDir, DirName, fileName)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enabled = enabled
+	t.webhookURL = webhookURL
+	if !enabled || path == t.path {
+		return
+	}
+	t.path = path
+	t.entries = map[string]*Entry{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("model versions: failed to read %s: %v", path, err)
+		}
+		return
+	}
+	var entries []*Entry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		log.Warnf("model versions: ignoring malformed %s: %v", path, err)
+		return
+	}
+	for _, entry := range entries {
+		t.entries[key(entry.Provider, entry.Model)] = entry
+	}
+}
+
+// Observe records identity as the upstream identity of model served by provider. When it
+// differs from the identity recorded before, the change is logged and sent to the webhook,
+// once however many requests observe it. Empty identities are ignored.
+func (t *Tracker) Observe(provider, model, identity string) {
+	if identity == "" || provider == "" || model == "" {
+		return
+	}
+	k := key(provider, model)
+	t.mu.RLock()
+	enabled := t.enabled
+	entry := t.entries[k]
+	unchanged := entry != nil && entry.Identity == identity
+	t.mu.RUnlock()
+	if !enabled || unchanged {
+		return
+	}
+
+	now := time.Now().UTC()
+	t.mu.Lock()
+	entry = t.entries[k]
+	var change *Change
+	switch {
+	case entry == nil:
+		t.entries[k] = &Entry{Provider: provider, Model: model, Identity: identity, FirstSeen: now}
+	case entry.Identity != identity:
+		change = &Change{Provider: provider, Model: model, Previous: entry.Identity, Current: identity, ChangedAt: now}
+		entry.Identity = identity
+		entry.Changes = append(entry.Changes, *change)
+		if len(entry.Changes) > maxChanges {
+			entry.Changes = entry.Changes[len(entry.Changes)-maxChanges:]
+		}
+	default:
+		// Another request recorded the same identity in the meantime.
+		t.mu.Unlock()
+		return
+	}
+	// Saving under the lock keeps concurrent first sightings from overwriting each other;
+	// it happens only when an identity is new or changed.
+	if t.path != "" {
+		if errSave := t.saveLocked(); errSave != nil {
+			log.Warnf("model versions: failed to save %s: %v", t.path, errSave)
+		}
+	}
+	webhookURL := t.webhookURL
+	t.mu.Unlock()
+
+	if change == nil {
+		return
+	}
+	log.Warnf("model version of %s/%s changed from %q to %q", provider, model, change.Previous, change.Current)
+	if webhookURL != "" {
+		go postWebhook(webhookURL, *change)
+	}
+}
+
+// Entries returns the recorded models ordered by provider and model.
+func (t *Tracker) Entries() []Entry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entries := t.snapshotLocked()
+	out := make([]Entry, len(entries))
+	for i, entry := range entries {
+		out[i] = *entry
+		out[i].Changes = append([]Change(nil), entry.Changes...)
+	}
+	return out
+}
+
+func (t *Tracker) snapshotLocked() []*Entry {
+	entries := make([]*Entry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Provider != entries[j].Provider {
+			return entries[i].Provider < entries[j].Provider
+		}
+		return entries[i].Model < entries[j].Model
+	})
+	return entries
+}
+
+func key(provider, model string) string { return provider + "\x00" + model }
+
+// saveLocked replaces the store file through a temporary file, so readers never see a
+// partial store.
+func (t *Tracker) saveLocked() error {
+	data, err := json.MarshalIndent(t.snapshotLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(t.path), 0o700); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// postWebhook sends change to url as a "model_version_changed" event.
+func postWebhook(url string, change Change) {
+	body, err := json.Marshal(struct {
+		Type string `json:"type"`
+		Change
+	}{Type: "model_version_changed", Change: change})
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Warnf("model versions: webhook failed: %v", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Warnf("model versions: webhook answered %d", resp.StatusCode)
+	}
+}
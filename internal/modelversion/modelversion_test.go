@@ -0,0 +1,68 @@
+package modelversion
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObserveReportsChangeOnce(t *testing.T) {
+	events := make(chan Change, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var change Change
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			t.Errorf("decode webhook: %v", err)
+		}
+		events <- change
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	tracker := &Tracker{entries: map[string]*Entry{}}
+	tracker.Configure(true, dir, server.URL)
+	tracker.Observe("gemini", "gemini-2.5-pro", "gemini-2.5-pro-001")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.Observe("gemini", "gemini-2.5-pro", "gemini-2.5-pro-002")
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case change := <-events:
+		if change.Previous != "gemini-2.5-pro-001" || change.Current != "gemini-2.5-pro-002" {
+			t.Fatalf("change = %+v", change)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook event for the change")
+	}
+	select {
+	case change := <-events:
+		t.Fatalf("change reported twice: %+v", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The store survives a restart and keeps the change.
+	reloaded := &Tracker{entries: map[string]*Entry{}}
+	reloaded.Configure(true, dir, "")
+	entries := reloaded.Entries()
+	if len(entries) != 1 || entries[0].Identity != "gemini-2.5-pro-002" || len(entries[0].Changes) != 1 {
+		t.Fatalf("reloaded entries = %+v", entries)
+	}
+}
+
+func TestObserveIgnoredWhileDisabled(t *testing.T) {
+	tracker := &Tracker{entries: map[string]*Entry{}}
+	tracker.Configure(false, t.TempDir(), "")
+	tracker.Observe("claude", "claude-sonnet-4", "claude-sonnet-4-20250514")
+	if entries := tracker.Entries(); len(entries) != 0 {
+		t.Fatalf("entries = %+v", entries)
+	}
+}
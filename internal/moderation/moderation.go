@@ -0,0 +1,292 @@
+// Package moderation checks streamed output text against blocked rules while it is being
+// generated. A Stream keeps a sliding window of the text already seen, so a match spanning
+// two chunks is found when its second half arrives. The external checker runs in the
+// background, bounded by a timeout, so a slow classifier never stalls the stream.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation describes the rule a text broke.
+type Violation struct {
+	// Checker names the checker that found it, "rules" or "external".
+	Checker string
+	// Rule identifies the rule, e.g. "keyword:foo" or a label chosen by the classifier.
+	Rule string
+}
+
+// String returns the violation as "checker/rule".
+func (v *Violation) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.Checker + "/" + v.Rule
+}
+
+// Checker inspects text for blocked content.
+type Checker interface {
+	// Name identifies the checker in violations and logs.
+	Name() string
+	// Check returns the violation text contains, nil when it is allowed. It must give up
+	// once ctx is done.
+	Check(ctx context.Context, text string) (*Violation, error)
+}
+
+// RuleChecker blocks keywords, ignoring case, and regular expressions.
+type RuleChecker struct {
+	keywords []string
+	patterns []*regexp.Regexp
+}
+
+// patternCache holds compiled patterns by expression, so requests sharing a configuration
+// compile each pattern once.
+var patternCache sync.Map
+
+// NewRuleChecker returns a checker for keywords and patterns, or nil when both are empty.
+// Patterns that do not compile are left out and reported in the returned error.
+func NewRuleChecker(keywords, patterns []string) (*RuleChecker, error) {
+	checker := &RuleChecker{}
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			checker.keywords = append(checker.keywords, keyword)
+		}
+	}
+	var errs []string
+	for _, expr := range patterns {
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+		if cached, ok := patternCache.Load(expr); ok {
+			checker.patterns = append(checker.patterns, cached.(*regexp.Regexp))
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%q: %v", expr, err))
+			continue
+		}
+		patternCache.Store(expr, re)
+		checker.patterns = append(checker.patterns, re)
+	}
+	var err error
+	if len(errs) > 0 {
+		err = fmt.Errorf("moderation: invalid patterns: %s", strings.Join(errs, "; "))
+	}
+	if len(checker.keywords) == 0 && len(checker.patterns) == 0 {
+		return nil, err
+	}
+	return checker, err
+}
+
+// Name implements Checker.
+func (c *RuleChecker) Name() string { return "rules" }
+
+// Check implements Checker.
+func (c *RuleChecker) Check(_ context.Context, text string) (*Violation, error) {
+	lower := strings.ToLower(text)
+	for _, keyword := range c.keywords {
+		if strings.Contains(lower, keyword) {
+			return &Violation{Checker: c.Name(), Rule: "keyword:" + keyword}, nil
+		}
+	}
+	for _, re := range c.patterns {
+		if re.MatchString(text) {
+			return &Violation{Checker: c.Name(), Rule: "pattern:" + re.String()}, nil
+		}
+	}
+	return nil, nil
+}
+
+// HTTPChecker asks an external classifier. It posts {"input": text} and expects
+// {"flagged": bool, "rule": string} back.
+type HTTPChecker struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewHTTPChecker returns a checker posting to url with headers.
+func NewHTTPChecker(url string, headers map[string]string) *HTTPChecker {
+	return &HTTPChecker{url: url, headers: headers, client: &http.Client{}}
+}
+
+// Name implements Checker.
+func (c *HTTPChecker) Name() string { return "external" }
+
+// Check implements Checker.
+func (c *HTTPChecker) Check(ctx context.Context, text string) (*Violation, error) {
+	body, err := json.Marshal(map[string]string{"input": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("moderation: classifier answered %d", resp.StatusCode)
+	}
+	var verdict struct {
+		Flagged bool   `json:"flagged"`
+		Rule    string `json:"rule"`
+	}
+	if err = json.Unmarshal(data, &verdict); err != nil {
+		return nil, fmt.Errorf("moderation: invalid classifier answer: %w", err)
+	}
+	if !verdict.Flagged {
+		return nil, nil
+	}
+	if verdict.Rule == "" {
+		verdict.Rule = "flagged"
+	}
+	return &Violation{Checker: c.Name(), Rule: verdict.Rule}, nil
+}
+
+// Stream moderates the text of one streamed response. Local rules run on every chunk; the
+// external checker runs in the background once BatchSize new characters have collected,
+// one check at a time, and its verdict is picked up by a later Write or by Flush. A Stream
+// is used by one goroutine.
+type Stream struct {
+	// Rules is the checker run on every chunk, nil for none.
+	Rules Checker
+	// External is the checker run on batches, nil for none.
+	External Checker
+	// Window is the number of characters of earlier text checked along with new text.
+	Window int
+	// BatchSize is the number of new characters that trigger the external checker.
+	BatchSize int
+	// Budget bounds the duration of a single check.
+	Budget time.Duration
+	// OnSkip, when set, is called for every check given up because it failed or exceeded
+	// Budget.
+	OnSkip func(checker string, err error)
+
+	text    string
+	pending int
+	// external delivers the verdict of the external check in flight, nil when none is.
+	external chan *Violation
+}
+
+// Write adds delta to the stream and returns the violation found in the window ending with
+// it, or one an earlier external check has found since, or nil.
+func (s *Stream) Write(ctx context.Context, delta string) *Violation {
+	if s.external != nil {
+		select {
+		case violation := <-s.external:
+			s.external = nil
+			if violation != nil {
+				return violation
+			}
+		default:
+		}
+	}
+	if delta == "" {
+		return nil
+	}
+	s.text += delta
+	s.pending += utf8.RuneCountInString(delta)
+	if s.Rules != nil {
+		if violation := s.run(ctx, s.Rules, s.text); violation != nil {
+			return violation
+		}
+	}
+	if s.External != nil && s.external == nil && s.pending >= s.BatchSize {
+		s.pending = 0
+		s.external = make(chan *Violation, 1)
+		go func(done chan<- *Violation, text string) {
+			done <- s.run(ctx, s.External, text)
+		}(s.external, s.text)
+	}
+	keep := s.Window
+	if s.External != nil && s.pending > keep {
+		keep = s.pending
+	}
+	s.text = tail(s.text, keep)
+	return nil
+}
+
+// Flush waits for the external check in flight and runs the external checker on text it has
+// not seen yet, once the stream ended.
+func (s *Stream) Flush(ctx context.Context) *Violation {
+	if s.external != nil {
+		violation := <-s.external
+		s.external = nil
+		if violation != nil {
+			return violation
+		}
+	}
+	if s.External == nil || s.pending == 0 {
+		return nil
+	}
+	s.pending = 0
+	return s.run(ctx, s.External, s.text)
+}
+
+// run checks text with checker within the budget.
+func (s *Stream) run(ctx context.Context, checker Checker, text string) *Violation {
+	ctx, cancel := context.WithTimeout(ctx, s.Budget)
+	defer cancel()
+	type result struct {
+		violation *Violation
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		violation, err := checker.Check(ctx, text)
+		done <- result{violation, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			s.skip(checker, r.err)
+			return nil
+		}
+		return r.violation
+	case <-ctx.Done():
+		s.skip(checker, ctx.Err())
+		return nil
+	}
+}
+
+func (s *Stream) skip(checker Checker, err error) {
+	if s.OnSkip != nil {
+		s.OnSkip(checker.Name(), err)
+	}
+}
+
+// tail returns the last n characters of text.
+func tail(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	i := len(text)
+	for count := 0; i > 0 && count < n; count++ {
+		_, size := utf8.DecodeLastRuneInString(text[:i])
+		i -= size
+	}
+	return text[i:]
+}
@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowChecker flags text containing word after delay.
+type slowChecker struct {
+	word  string
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (c *slowChecker) Name() string { return "external" }
+
+func (c *slowChecker) Check(ctx context.Context, text string) (*Violation, error) {
+	c.calls.Add(1)
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if strings.Contains(text, c.word) {
+		return &Violation{Checker: c.Name(), Rule: "flagged"}, nil
+	}
+	return nil, nil
+}
+
+func TestRulesMatchAcrossChunks(t *testing.T) {
+	rules, err := NewRuleChecker([]string{"codename"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Stream{Rules: rules, Window: 16, Budget: time.Second}
+	if v := s.Write(context.Background(), "the code"); v != nil {
+		t.Fatalf("violation before the match completed: %v", v)
+	}
+	if v := s.Write(context.Background(), "name is"); v == nil || v.String() != "rules/keyword:codename" {
+		t.Fatalf("violation = %v, want rules/keyword:codename", v)
+	}
+}
+
+func TestExternalCheckRunsOffTheWritePath(t *testing.T) {
+	checker := &slowChecker{word: "bad", delay: 100 * time.Millisecond}
+	s := &Stream{External: checker, Window: 64, BatchSize: 3, Budget: time.Second}
+
+	start := time.Now()
+	if v := s.Write(context.Background(), "bad words"); v != nil {
+		t.Fatalf("violation reported before the check finished: %v", v)
+	}
+	if v := s.Write(context.Background(), " and more"); v != nil {
+		t.Fatalf("violation reported before the check finished: %v", v)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("writes waited %v for the external checker", elapsed)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if calls := checker.calls.Load(); calls != 1 {
+		t.Fatalf("external checker called %d times, want 1: a check was in flight", calls)
+	}
+	if v := s.Write(context.Background(), "!"); v == nil || v.String() != "external/flagged" {
+		t.Fatalf("violation = %v, want external/flagged from the finished check", v)
+	}
+}
+
+func TestFlushWaitsForTheExternalCheck(t *testing.T) {
+	checker := &slowChecker{word: "bad", delay: 50 * time.Millisecond}
+	s := &Stream{External: checker, Window: 64, BatchSize: 3, Budget: time.Second}
+	s.Write(context.Background(), "bad")
+	if v := s.Flush(context.Background()); v == nil {
+		t.Fatal("Flush did not report the violation of the check in flight")
+	}
+}
+
+func TestExternalCheckTimesOut(t *testing.T) {
+	checker := &slowChecker{word: "bad", delay: time.Second}
+	var skipped atomic.Int32
+	s := &Stream{External: checker, Window: 64, BatchSize: 3, Budget: 20 * time.Millisecond,
+		OnSkip: func(string, error) { skipped.Add(1) }}
+	s.Write(context.Background(), "bad")
+
+	start := time.Now()
+	if v := s.Flush(context.Background()); v != nil {
+		t.Fatalf("violation = %v, want none from a check that timed out", v)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Flush waited %v, longer than the budget", elapsed)
+	}
+	if skipped.Load() != 1 {
+		t.Fatalf("skipped = %d, want 1", skipped.Load())
+	}
+}
@@ -1,7 +1,8 @@
 package geminiwebapi
 
 import (
-	"crypto/tls"
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -25,6 +27,7 @@ type GeminiClient struct {
 	AccessToken string
 	Timeout     time.Duration
 	insecure    bool
+	userAgent   string
 }
 
 // HTTP bootstrap utilities -------------------------------------------------
@@ -35,17 +38,8 @@ type httpOptions struct {
 }
 
 func newHTTPClient(opts httpOptions) *http.Client {
-	transport := &http.Transport{}
-	if opts.ProxyURL != "" {
-		if pu, err := url.Parse(opts.ProxyURL); err == nil {
-			transport.Proxy = http.ProxyURL(pu)
-		}
-	}
-	if opts.Insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	}
 	jar, _ := cookiejar.New(nil)
-	client := &http.Client{Transport: transport, Timeout: 60 * time.Second, Jar: jar}
+	client := &http.Client{Transport: upstreamTransport(opts.ProxyURL, opts.Insecure), Timeout: 60 * time.Second, Jar: jar}
 	if !opts.FollowRedirects {
 		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
@@ -54,6 +48,28 @@ func newHTTPClient(opts httpOptions) *http.Client {
 	return client
 }
 
+// upstreamTransport returns the shared Gemini Web transport through proxyURL. A proxy URL
+// that cannot be used falls back to a direct connection, as before transports were shared.
+func upstreamTransport(proxyURL string, insecure bool) http.RoundTripper {
+	var rt http.RoundTripper
+	if insecure {
+		rt = util.InsecureUpstreamTransport("gemini-web", proxyURL)
+	} else {
+		rt = util.UpstreamTransport("gemini-web", proxyURL)
+	}
+	if rt == nil && proxyURL != "" {
+		return upstreamTransport("", insecure)
+	}
+	return rt
+}
+
+// applyUserAgent replaces the built-in User-Agent of HeadersGemini when one is configured.
+func applyUserAgent(req *http.Request, userAgent string) {
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+}
+
 func applyHeaders(req *http.Request, headers http.Header) {
 	for k, v := range headers {
 		for _, vv := range v {
@@ -68,17 +84,23 @@ func applyCookies(req *http.Request, cookies map[string]string) {
 	}
 }
 
-func sendInitRequest(cookies map[string]string, proxy string, insecure bool) (*http.Response, map[string]string, error) {
+func sendInitRequest(cookies map[string]string, proxy string, insecure bool, userAgent string) (*http.Response, map[string]string, error) {
 	client := newHTTPClient(httpOptions{ProxyURL: proxy, Insecure: insecure, FollowRedirects: true})
 	req, _ := http.NewRequest(http.MethodGet, EndpointInit, nil)
 	applyHeaders(req, HeadersGemini)
+	applyUserAgent(req, userAgent)
 	applyCookies(req, cookies)
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, nil, err
 	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		_ = resp.Body.Close()
+		return nil, nil, &AuthError{Msg: resp.Status}
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return resp, nil, &AuthError{Msg: resp.Status}
+		_ = resp.Body.Close()
+		return nil, nil, &APIError{Msg: "init request failed: " + resp.Status}
 	}
 	outCookies := map[string]string{}
 	for _, c := range resp.Cookies() {
@@ -90,7 +112,7 @@ func sendInitRequest(cookies map[string]string, proxy string, insecure bool) (*h
 	return resp, outCookies, nil
 }
 
-func getAccessToken(baseCookies map[string]string, proxy string, verbose bool, insecure bool) (string, map[string]string, error) {
+func getAccessToken(baseCookies map[string]string, proxy string, verbose bool, insecure bool, userAgent string) (string, map[string]string, error) {
 	extraCookies := map[string]string{}
 	{
 		client := newHTTPClient(httpOptions{ProxyURL: proxy, Insecure: insecure, FollowRedirects: true})
@@ -130,14 +152,25 @@ func getAccessToken(baseCookies map[string]string, proxy string, verbose bool, i
 
 	reToken := regexp.MustCompile(`"SNlM0e":"([^"]+)"`)
 
+	// A missing token only proves the cookies are dead when Google actually answered; when
+	// every attempt failed in transit the last transport error is returned instead.
+	var lastErr error
+	answered := false
 	for _, cookies := range trySets {
-		resp, mergedCookies, err := sendInitRequest(cookies, proxy, insecure)
+		resp, mergedCookies, err := sendInitRequest(cookies, proxy, insecure, userAgent)
 		if err != nil {
 			if verbose {
 				log.Warnf("Failed init request: %v", err)
 			}
+			var authErr *AuthError
+			if errors.As(err, &authErr) {
+				answered = true
+			} else {
+				lastErr = err
+			}
 			continue
 		}
+		answered = true
 		body, err := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
 		if err != nil {
@@ -152,6 +185,9 @@ func getAccessToken(baseCookies map[string]string, proxy string, verbose bool, i
 			return token, mergedCookies, nil
 		}
 	}
+	if !answered && lastErr != nil {
+		return "", nil, lastErr
+	}
 	return "", nil, &AuthError{Msg: "Failed to retrieve token."}
 }
 
@@ -254,10 +290,15 @@ func WithInsecureTLS(insecure bool) func(*GeminiClient) {
 	return func(c *GeminiClient) { c.insecure = insecure }
 }
 
+// WithUserAgent replaces the built-in browser User-Agent sent to Gemini Web.
+func WithUserAgent(userAgent string) func(*GeminiClient) {
+	return func(c *GeminiClient) { c.userAgent = userAgent }
+}
+
 // Init initializes the access token and http client.
 func (c *GeminiClient) Init(timeoutSec float64, verbose bool) error {
 	// get access token
-	token, validCookies, err := getAccessToken(c.Cookies, c.Proxy, verbose, c.insecure)
+	token, validCookies, err := getAccessToken(c.Cookies, c.Proxy, verbose, c.insecure, c.userAgent)
 	if err != nil {
 		c.Close(0)
 		return err
@@ -265,17 +306,8 @@ func (c *GeminiClient) Init(timeoutSec float64, verbose bool) error {
 	c.AccessToken = token
 	c.Cookies = validCookies
 
-	tr := &http.Transport{}
-	if c.Proxy != "" {
-		if pu, errParse := url.Parse(c.Proxy); errParse == nil {
-			tr.Proxy = http.ProxyURL(pu)
-		}
-	}
-	if c.insecure {
-		// set via roundtripper in utils_get_access_token for token; here we reuse via default Transport
-		// intentionally not adding here, as requests rely on endpoints with normal TLS
-	}
-	c.httpClient = &http.Client{Transport: tr, Timeout: time.Duration(timeoutSec * float64(time.Second))}
+	// Certificates are only skipped for the token bootstrap; the chat endpoints use normal TLS.
+	c.httpClient = &http.Client{Transport: upstreamTransport(c.Proxy, false), Timeout: time.Duration(timeoutSec * float64(time.Second))}
 	c.Running = true
 
 	c.Timeout = time.Duration(timeoutSec * float64(time.Second))
@@ -309,7 +341,8 @@ func (c *GeminiClient) RotateTS() (string, error) {
 }
 
 // GenerateContent sends a prompt (with optional files) and parses the response into ModelOutput.
-func (c *GeminiClient) GenerateContent(prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
+// Cancelling ctx aborts the request and any pending retry.
+func (c *GeminiClient) GenerateContent(ctx context.Context, prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
 	var empty ModelOutput
 	if prompt == "" {
 		return empty, &ValueError{Msg: "Prompt cannot be empty."}
@@ -321,10 +354,13 @@ func (c *GeminiClient) GenerateContent(prompt string, files []string, model Mode
 	// Retry wrapper similar to decorator (retry=2)
 	retries := 2
 	for {
-		out, err := c.generateOnce(prompt, files, model, gem, chat)
+		out, err := c.generateOnce(ctx, prompt, files, model, gem, chat)
 		if err == nil {
 			return out, nil
 		}
+		if ctx.Err() != nil {
+			return empty, ctx.Err()
+		}
 		var apiErr *APIError
 		var imgErr *ImageGenerationError
 		shouldRetry := false
@@ -337,7 +373,11 @@ func (c *GeminiClient) GenerateContent(prompt string, files []string, model Mode
 			shouldRetry = true
 		}
 		if shouldRetry && retries > 0 {
-			time.Sleep(time.Second)
+			select {
+			case <-ctx.Done():
+				return empty, ctx.Err()
+			case <-time.After(time.Second):
+			}
 			retries--
 			continue
 		}
@@ -345,6 +385,95 @@ func (c *GeminiClient) GenerateContent(prompt string, files []string, model Mode
 	}
 }
 
+type outputLimitKey struct{}
+
+// withOutputLimit returns ctx asking generate requests to stop reading a reply whose text
+// exceeds limit estimated tokens. A limit of zero or less reads replies in full.
+func withOutputLimit(ctx context.Context, limit int) context.Context {
+	if limit <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, outputLimitKey{}, limit)
+}
+
+func outputLimitFrom(ctx context.Context) int {
+	limit, _ := ctx.Value(outputLimitKey{}).(int)
+	return limit
+}
+
+// readGenerateResponse reads the lines of a generate response. Gemini sends the reply as
+// frames carrying the text generated so far; with a limit, reading stops at the first frame
+// whose text exceeds it, and that frame takes the place of the data line parsed below, so a
+// runaway generation is abandoned rather than read to its end. Closing the body unread then
+// drops the connection.
+func readGenerateResponse(r io.Reader, limit int) string {
+	if limit <= 0 {
+		b, _ := io.ReadAll(r)
+		return string(b)
+	}
+	reader := bufio.NewReader(r)
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+			if last := len(lines) - 1; last >= 2 && frameOutputTokens(lines[last]) > limit {
+				return strings.Join(append(lines[:2:2], lines[last]), "\n")
+			}
+		}
+		if err != nil {
+			return strings.Join(lines, "\n")
+		}
+	}
+}
+
+// frameOutputTokens estimates the output tokens of the longest candidate, text and thoughts,
+// in a response frame, or returns zero for frames carrying no candidates.
+func frameOutputTokens(line string) int {
+	var frame []any
+	if err := json.Unmarshal([]byte(line), &frame); err != nil {
+		return 0
+	}
+	longest := 0
+	for _, p := range frame {
+		arr, ok := p.([]any)
+		if !ok || len(arr) < 3 {
+			continue
+		}
+		s, ok := arr[2].(string)
+		if !ok {
+			continue
+		}
+		var mainPart []any
+		if err := json.Unmarshal([]byte(s), &mainPart); err != nil || len(mainPart) <= 4 {
+			continue
+		}
+		candidates, _ := mainPart[4].([]any)
+		for _, candAny := range candidates {
+			cArr, _ := candAny.([]any)
+			tokens := 0
+			if len(cArr) > 1 {
+				if sArr, isOk := cArr[1].([]any); isOk && len(sArr) > 0 {
+					text, _ := sArr[0].(string)
+					tokens += estimateTokens(text)
+				}
+			}
+			if len(cArr) > 37 {
+				if a, isOk := cArr[37].([]any); isOk && len(a) > 0 {
+					if b1, isOk1 := a[0].([]any); isOk1 && len(b1) > 0 {
+						thoughts, _ := b1[0].(string)
+						tokens += estimateTokens(thoughts)
+					}
+				}
+			}
+			if tokens > longest {
+				longest = tokens
+			}
+		}
+	}
+	return longest
+}
+
 func ensureAnyLen(slice []any, index int) []any {
 	if index < len(slice) {
 		return slice
@@ -353,7 +482,7 @@ func ensureAnyLen(slice []any, index int) []any {
 	return append(slice, make([]any, gap)...)
 }
 
-func (c *GeminiClient) generateOnce(prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
+func (c *GeminiClient) generateOnce(ctx context.Context, prompt string, files []string, model Model, gem *Gem, chat *ChatSession) (ModelOutput, error) {
 	var empty ModelOutput
 	// Build f.req
 	var uploaded [][]any
@@ -404,14 +533,18 @@ func (c *GeminiClient) generateOnce(prompt string, files []string, model Model,
 	form.Set("at", c.AccessToken)
 	form.Set("f.req", string(outerJSON))
 
-	req, _ := http.NewRequest(http.MethodPost, EndpointGenerate, strings.NewReader(form.Encode()))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, EndpointGenerate, strings.NewReader(form.Encode()))
 	applyHeaders(req, HeadersGemini)
 	applyHeaders(req, model.ModelHeader)
+	applyUserAgent(req, c.userAgent)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
 	applyCookies(req, c.Cookies)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if ctx.Err() != nil {
+			return empty, ctx.Err()
+		}
 		return empty, &TimeoutError{GeminiError{Msg: "Generate content request timed out."}}
 	}
 	defer func() {
@@ -421,7 +554,7 @@ func (c *GeminiClient) generateOnce(prompt string, files []string, model Model,
 	if resp.StatusCode == 429 {
 		// Surface 429 as TemporarilyBlocked to match reference behavior
 		c.Close(0)
-		return empty, &TemporarilyBlocked{GeminiError{Msg: "Too many requests. IP temporarily blocked."}}
+		return empty, &TemporarilyBlocked{GeminiError: GeminiError{Msg: "Too many requests."}}
 	}
 	if resp.StatusCode != 200 {
 		c.Close(0)
@@ -429,8 +562,8 @@ func (c *GeminiClient) generateOnce(prompt string, files []string, model Model,
 	}
 
 	// Read body and split lines; take the 3rd line (index 2)
-	b, _ := io.ReadAll(resp.Body)
-	parts := strings.Split(string(b), "\n")
+	b := readGenerateResponse(resp.Body, outputLimitFrom(ctx))
+	parts := strings.Split(b, "\n")
 	if len(parts) < 3 {
 		c.Close(0)
 		return empty, &APIError{Msg: "Invalid response data received."}
@@ -517,7 +650,7 @@ func (c *GeminiClient) generateOnce(prompt string, files []string, model Model,
 				case ErrorModelHeaderInvalid:
 					return empty, &APIError{Msg: "Invalid model header string. Please update the selected model header."}
 				case ErrorIPTemporarilyBlocked:
-					return empty, &TemporarilyBlocked{GeminiError{Msg: "Too many requests. IP temporarily blocked."}}
+					return empty, &TemporarilyBlocked{GeminiError: GeminiError{Msg: "Too many requests. IP temporarily blocked."}, IPBlocked: true}
 				}
 			}
 		}
@@ -858,8 +991,8 @@ func (cs *ChatSession) setRCID(v string) {
 }
 
 // SendMessage shortcut to client's GenerateContent
-func (cs *ChatSession) SendMessage(prompt string, files []string) (ModelOutput, error) {
-	out, err := cs.client.GenerateContent(prompt, files, cs.model, cs.gem, cs)
+func (cs *ChatSession) SendMessage(ctx context.Context, prompt string, files []string) (ModelOutput, error) {
+	out, err := cs.client.GenerateContent(ctx, prompt, files, cs.model, cs.gem, cs)
 	if err == nil {
 		cs.lastOutput = &out
 		cs.SetMetadata(out.Metadata)
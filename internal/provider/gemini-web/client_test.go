@@ -0,0 +1,63 @@
+package geminiwebapi
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+// generateFrame returns a generate response line carrying text as its only candidate.
+func generateFrame(t *testing.T, text string) string {
+	t.Helper()
+	mainPart, _ := json.Marshal([]any{nil, []any{"c_1", "r_1"}, nil, nil, []any{[]any{"rc_1", []any{text}}}})
+	frame, _ := json.Marshal([]any{[]any{"wrb.fr", nil, string(mainPart)}})
+	return string(frame)
+}
+
+// unreadable fails the test when read, marking data that must not be consumed.
+type unreadable struct{ t *testing.T }
+
+func (r unreadable) Read([]byte) (int, error) {
+	r.t.Error("read past the frame that crossed the limit")
+	return 0, io.EOF
+}
+
+func TestReadGenerateResponseStopsAtTheLimit(t *testing.T) {
+	frames := []string{")]}'", "", generateFrame(t, "short"), generateFrame(t, strings.Repeat("word ", 40))}
+	body := io.MultiReader(strings.NewReader(strings.Join(frames, "\n")+"\n"), unreadable{t})
+
+	got := readGenerateResponse(body, 20)
+	parts := strings.Split(got, "\n")
+	if len(parts) != 3 || parts[2] != frames[3] {
+		t.Fatalf("lines = %q, want the crossing frame as the data line", parts)
+	}
+	if tokens := frameOutputTokens(parts[2]); tokens != 50 {
+		t.Fatalf("frame tokens = %d, want 50", tokens)
+	}
+}
+
+func TestReadGenerateResponseReadsEverythingWithinTheLimit(t *testing.T) {
+	body := ")]}'\n\n" + generateFrame(t, "short") + "\n[\"di\",1]"
+	if got := readGenerateResponse(strings.NewReader(body), 20); got != body {
+		t.Fatalf("response = %q, want it unchanged", got)
+	}
+	if got := readGenerateResponse(strings.NewReader(body), 0); got != body {
+		t.Fatalf("response without a limit = %q, want it unchanged", got)
+	}
+}
+
+func TestCapOutputTokensCutsThoughtsFirst(t *testing.T) {
+	thoughts := strings.Repeat("t", 40)
+	output := ModelOutput{Candidates: []Candidate{{Text: strings.Repeat("x", 100), Thoughts: &thoughts}}}
+	if !capOutputTokens(&output, 15) {
+		t.Fatal("output within the cap")
+	}
+	c := output.Candidates[0]
+	if len(*c.Thoughts) != 40 || len(c.Text) != 20 {
+		t.Fatalf("thoughts %d runes, text %d runes, want 40 and 20", len(*c.Thoughts), len(c.Text))
+	}
+	if capOutputTokens(&output, 15) {
+		t.Fatal("capped output cut again")
+	}
+}
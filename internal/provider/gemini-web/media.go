@@ -22,6 +22,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 // Image helpers ------------------------------------------------------------
@@ -192,6 +193,20 @@ func ParseMessagesAndFiles(rawJSON []byte) ([]RoleText, [][]byte, []string, [][]
 					}
 					b.WriteString(text.String())
 				}
+				// Gemini Web has no function calling; calls and results stay in the transcript
+				// as text so a turn holding only tool calls is not lost.
+				if call := part.Get("functionCall"); call.Exists() {
+					if b.Len() > 0 {
+						b.WriteString("\n")
+					}
+					b.WriteString("[tool call] " + call.Get("name").String() + " " + call.Get("args").Raw)
+				}
+				if resp := part.Get("functionResponse"); resp.Exists() {
+					if b.Len() > 0 {
+						b.WriteString("\n")
+					}
+					b.WriteString("[tool result] " + resp.Get("name").String() + " " + resp.Get("response").Raw)
+				}
 				if inlineData := part.Get("inlineData"); inlineData.Exists() {
 					data := inlineData.Get("data").String()
 					if data != "" {
@@ -207,17 +222,27 @@ func ParseMessagesAndFiles(rawJSON []byte) ([]RoleText, [][]byte, []string, [][]
 				}
 				return true
 			})
-			messages = append(messages, RoleText{Role: role, Text: b.String()})
 			endFile := len(files)
-			if endFile > startFile {
-				idxs := make([]int, 0, endFile-startFile)
-				for i := startFile; i < endFile; i++ {
-					idxs = append(idxs, i)
+			if b.Len() == 0 && endFile == startFile {
+				return true
+			}
+			var idxs []int
+			for i := startFile; i < endFile; i++ {
+				idxs = append(idxs, i)
+			}
+			// A turn left next to one of the same role by a dropped empty turn joins it.
+			if n := len(messages); n > 0 && messages[n-1].Role == role {
+				if b.Len() > 0 {
+					if messages[n-1].Text != "" {
+						messages[n-1].Text += "\n"
+					}
+					messages[n-1].Text += b.String()
 				}
-				perMsgFileIdx = append(perMsgFileIdx, idxs)
-			} else {
-				perMsgFileIdx = append(perMsgFileIdx, nil)
+				perMsgFileIdx[n-1] = append(perMsgFileIdx[n-1], idxs...)
+				return true
 			}
+			messages = append(messages, RoleText{Role: role, Text: b.String()})
+			perMsgFileIdx = append(perMsgFileIdx, idxs)
 			return true
 		})
 	}
@@ -505,6 +530,42 @@ func ConvertOutputToGemini(output *ModelOutput, modelName string, promptText str
 	return ensureColonSpacing(b), nil
 }
 
+// mergeThoughtIntoSingleContent folds the thought parts of a converted response, in order,
+// into its text as a leading <think>...</think> block, leaving one visible text part.
+// Responses without thoughts are returned unchanged.
+func mergeThoughtIntoSingleContent(gemBytes []byte) []byte {
+	parts := gjson.GetBytes(gemBytes, "candidates.0.content.parts")
+	var thoughts []string
+	var text string
+	var merged []any
+	parts.ForEach(func(_, part gjson.Result) bool {
+		switch {
+		case part.Get("thought").Bool():
+			if thought := part.Get("text").String(); thought != "" {
+				thoughts = append(thoughts, thought)
+			}
+		case part.Get("text").Exists() && text == "":
+			text = part.Get("text").String()
+		default:
+			merged = append(merged, part.Value())
+		}
+		return true
+	})
+	if len(thoughts) == 0 {
+		return gemBytes
+	}
+	content := "<think>\n" + strings.Join(thoughts, "\n\n") + "\n</think>"
+	if text != "" {
+		content += "\n\n" + text
+	}
+	merged = append([]any{map[string]any{"text": content}}, merged...)
+	out, err := sjson.SetBytes(gemBytes, "candidates.0.content.parts", merged)
+	if err != nil {
+		return gemBytes
+	}
+	return out
+}
+
 // ensureColonSpacing inserts a single space after JSON key-value colons while
 // leaving string content untouched. This matches the relaxed formatting used by
 // Gemini responses and keeps downstream text-processing tools compatible with
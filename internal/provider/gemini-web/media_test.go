@@ -0,0 +1,103 @@
+package geminiwebapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+const thoughtResponse = `{"candidates":[{"content":{"role":"model","parts":[` +
+	`{"text":"first thought","thought":true},` +
+	`{"text":"second thought","thought":true},` +
+	`{"text":"answer"},` +
+	`{"inlineData":{"mimeType":"image/png","data":"AAAA"}}` +
+	`]},"finishReason":"STOP"}],"modelVersion":"gemini-2.5-pro"}`
+
+func TestMergeThoughtIntoSingleContentKeepsEveryThought(t *testing.T) {
+	out := mergeThoughtIntoSingleContent([]byte(thoughtResponse))
+	parts := gjson.GetBytes(out, "candidates.0.content.parts").Array()
+	if len(parts) != 2 {
+		t.Fatalf("parts = %s, want the merged text and the image", gjson.GetBytes(out, "candidates.0.content.parts").Raw)
+	}
+	want := "<think>\nfirst thought\n\nsecond thought\n</think>\n\nanswer"
+	if got := parts[0].Get("text").String(); got != want {
+		t.Fatalf("merged text = %q, want %q", got, want)
+	}
+	if !parts[1].Get("inlineData").Exists() {
+		t.Fatalf("second part = %s, want the image", parts[1].Raw)
+	}
+}
+
+func TestMergeThoughtIntoSingleContentLeavesResponsesWithoutThoughts(t *testing.T) {
+	in := []byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"answer"}]}}]}`)
+	if out := mergeThoughtIntoSingleContent(in); string(out) != string(in) {
+		t.Fatalf("response changed to %s", out)
+	}
+}
+
+func TestConvertStreamCodeModeReasoning(t *testing.T) {
+	prep := &geminiWebPrepared{
+		handlerType:   constant.OpenAI,
+		originalRaw:   []byte(`{"model":"gemini-2.5-pro","stream":true,"messages":[{"role":"user","content":"hi"}]}`),
+		translatedRaw: []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`),
+	}
+	// The executor translates the converted lines for the client, as here; the content and
+	// reasoning deltas of the chunks are returned.
+	convert := func(reasoning bool, response string) (string, string) {
+		cfg := &config.Config{}
+		cfg.GeminiWeb.CodeMode = true
+		cfg.GeminiWeb.CodeModeReasoning = reasoning
+		s := &GeminiWebState{cfg: cfg}
+		var param any
+		var text, thoughts strings.Builder
+		for _, line := range s.ConvertStream(context.Background(), "gemini-2.5-pro", prep, []byte(response)) {
+			for _, chunk := range sdktranslator.TranslateStream(context.Background(), sdktranslator.FromString(constant.GeminiWeb), sdktranslator.FromString(constant.OpenAI), "gemini-2.5-pro", prep.originalRaw, prep.translatedRaw, []byte(line), &param) {
+				text.WriteString(gjson.Get(chunk, "choices.0.delta.content").String())
+				thoughts.WriteString(gjson.Get(chunk, "choices.0.delta.reasoning_content").String())
+			}
+		}
+		return text.String(), thoughts.String()
+	}
+
+	content, reasoning := convert(false, thoughtResponse)
+	if want := "<think>\nfirst thought\n\nsecond thought\n</think>\n\nanswer"; content != want || reasoning != "" {
+		t.Errorf("code mode stream has content %q and reasoning %q, want the thoughts inline in <think> tags", content, reasoning)
+	}
+
+	single := `{"candidates":[{"content":{"role":"model","parts":[{"text":"a thought","thought":true},{"text":"answer"}]},"finishReason":"STOP"}]}`
+	content, reasoning = convert(true, single)
+	if content != "answer" || reasoning != "a thought" {
+		t.Errorf("code-mode-reasoning stream has content %q and reasoning %q, want the thoughts as reasoning_content", content, reasoning)
+	}
+}
+
+func TestParseMessagesAndFilesMergesTurnsAroundDroppedOnes(t *testing.T) {
+	raw := []byte(`{"contents":[
+		{"role":"user","parts":[{"text":"first"}]},
+		{"role":"model","parts":[]},
+		{"role":"user","parts":[{"text":"second"},{"inlineData":{"mimeType":"image/png","data":"AAAA"}}]},
+		{"role":"model","parts":[{"functionCall":{"name":"f","args":{}}}]}
+	]}`)
+	messages, files, _, perMsgFileIdx, err := ParseMessagesAndFiles(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("messages = %+v, want two turns", messages)
+	}
+	if messages[0].Text != "first\nsecond" {
+		t.Errorf("user text = %q", messages[0].Text)
+	}
+	if len(files) != 1 || len(perMsgFileIdx[0]) != 1 || perMsgFileIdx[0][0] != 0 {
+		t.Errorf("files = %d, indexes = %v", len(files), perMsgFileIdx)
+	}
+	if !strings.Contains(messages[1].Text, "[tool call] f") {
+		t.Errorf("model text = %q", messages[1].Text)
+	}
+}
@@ -178,6 +178,7 @@ type StoredMessage struct {
 
 type ConversationRecord struct {
 	Model     string          `json:"model"`
+	Title     string          `json:"title,omitempty"`
 	ClientID  string          `json:"client_id"`
 	Metadata  []string        `json:"metadata,omitempty"`
 	Messages  []StoredMessage `json:"messages"`
@@ -298,7 +299,12 @@ type UsageLimitExceeded struct{ GeminiError }
 
 type ModelInvalid struct{ GeminiError }
 
-type TemporarilyBlocked struct{ GeminiError }
+type TemporarilyBlocked struct {
+	GeminiError
+	// IPBlocked is set when Gemini Web answered with ErrorIPTemporarilyBlocked, blocking the
+	// address the request came from, rather than with a plain HTTP 429.
+	IPBlocked bool
+}
 
 type ValueError struct{ Msg string }
 
@@ -1,6 +1,7 @@
 package geminiwebapi
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"regexp"
@@ -170,7 +171,7 @@ func MaxCharsPerRequest(cfg *config.Config) int {
 	return 1_000_000
 }
 
-func SendWithSplit(chat *ChatSession, text string, files []string, cfg *config.Config) (ModelOutput, error) {
+func SendWithSplit(ctx context.Context, chat *ChatSession, text string, files []string, cfg *config.Config) (ModelOutput, error) {
 	// Validate chat session
 	if chat == nil {
 		return ModelOutput{}, fmt.Errorf("nil chat session")
@@ -184,7 +185,7 @@ func SendWithSplit(chat *ChatSession, text string, files []string, cfg *config.C
 
 	// If within limit, send directly
 	if utf8.RuneCountInString(text) <= maxChars {
-		return chat.SendMessage(text, files)
+		return chat.SendMessage(ctx, text, files)
 	}
 
 	// Decide whether to use continuation hint (enabled by default)
@@ -217,11 +218,11 @@ func SendWithSplit(chat *ChatSession, text string, files []string, cfg *config.C
 		if useHint {
 			part += continuationHint
 		}
-		if _, err := chat.SendMessage(part, nil); err != nil {
+		if _, err := chat.SendMessage(ctx, part, nil); err != nil {
 			return ModelOutput{}, err
 		}
 	}
 
 	// Send final chunk with files and return the actual output
-	return chat.SendMessage(chunks[len(chunks)-1], files)
+	return chat.SendMessage(ctx, chunks[len(chunks)-1], files)
 }
@@ -0,0 +1,101 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// errNoPoolProxy is returned by RotateProxy when no proxy of the pool could take over.
+var errNoPoolProxy = errors.New("gemini web: no usable proxy left in the proxy pool")
+
+// ProxyURL returns the proxy the account sends through, empty for a direct connection.
+func (s *GeminiWebState) ProxyURL() string { return s.proxyURL() }
+
+// RotateProxy moves the account to the proxy following its current one in the gemini-web
+// proxy-pool, wrapping around, and returns the proxy in use afterwards. A client is signed in
+// through the new proxy before it replaces the current one, so a proxy that fails to
+// initialize is passed over like those skip reports. Concurrent rotations share one, and a
+// caller whose proxy was already replaced gets the replacement without rotating again.
+func (s *GeminiWebState) RotateProxy(ctx context.Context, skip func(proxyURL string) bool) (string, error) {
+	from := s.proxyURL()
+	current, err := s.shared(ctx, "proxy", func() (any, error) {
+		if current := s.proxyURL(); current != from {
+			return current, nil
+		}
+		return s.rotateProxy(from, skip)
+	})
+	if err != nil {
+		return "", err
+	}
+	return current.(string), nil
+}
+
+func (s *GeminiWebState) rotateProxy(from string, skip func(string) bool) (string, error) {
+	var pool []string
+	if s.cfg != nil {
+		pool = s.cfg.GeminiWeb.ProxyPool
+	}
+	lastErr := errNoPoolProxy
+	for _, candidate := range proxyPoolAfter(pool, from) {
+		if skip != nil && skip(candidate) {
+			continue
+		}
+		token := s.TokenSnapshot()
+		client := NewGeminiClient(token.Secure1PSID, token.Secure1PSIDTS, candidate, WithUserAgent(s.userAgent()))
+		if err := client.Init(float64(geminiWebDefaultTimeoutSec), false); err != nil {
+			log.Debugf("gemini web account %s: proxy %s failed to initialize: %v", s.accountID, redactProxy(candidate), err)
+			lastErr = err
+			continue
+		}
+		s.proxyMu.Lock()
+		s.proxy, s.rotated = candidate, true
+		s.proxyMu.Unlock()
+		s.clientMu.Lock()
+		previous := s.client
+		s.client = client
+		s.lastRefresh = time.Now()
+		s.clientMu.Unlock()
+		if previous != nil {
+			previous.Close(0)
+		}
+		log.Infof("gemini web account %s: egress %s blocked, switched to proxy %s", s.accountID, redactProxy(from), redactProxy(candidate))
+		return candidate, nil
+	}
+	return "", lastErr
+}
+
+// proxyPoolAfter returns the proxies of pool in the order they are tried after current: those
+// following it first, then those before it. current itself and blank entries are left out.
+func proxyPoolAfter(pool []string, current string) []string {
+	start := 0
+	for i, proxy := range pool {
+		if strings.TrimSpace(proxy) == current {
+			start = i + 1
+			break
+		}
+	}
+	ordered := make([]string, 0, len(pool))
+	for i := range pool {
+		proxy := strings.TrimSpace(pool[(start+i)%len(pool)])
+		if proxy != "" && proxy != current {
+			ordered = append(ordered, proxy)
+		}
+	}
+	return ordered
+}
+
+// redactProxy hides the password of a proxy URL for logging; an empty URL reads as direct.
+func redactProxy(proxyURL string) string {
+	if proxyURL == "" {
+		return "direct"
+	}
+	if u, err := url.Parse(proxyURL); err == nil {
+		return u.Redacted()
+	}
+	return proxyURL
+}
@@ -19,12 +19,15 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 	bolt "go.etcd.io/bbolt"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -39,8 +42,18 @@ type GeminiWebState struct {
 	stableClientID string
 	accountID      string
 
-	reqMu  sync.Mutex
-	client *GeminiClient
+	reqMu    sync.Mutex
+	clientMu sync.Mutex
+	client   *GeminiClient
+	// flight lets concurrent callers share one client initialization, refresh or cookie
+	// rotation instead of each sending their own requests to Google.
+	flight singleflight.Group
+
+	// proxy replaces the configured proxy once a block moved the account to a proxy of the
+	// gemini-web proxy-pool, which rotated records.
+	proxyMu sync.Mutex
+	proxy   string
+	rotated bool
 
 	tokenMu    sync.Mutex
 	tokenDirty bool
@@ -49,6 +62,8 @@ type GeminiWebState struct {
 	convStore map[string][]string
 	convData  map[string]ConversationRecord
 	convIndex map[string]string
+	// toolSchemas maps a conversation ID to the hash of the tool schemas it was last given.
+	toolSchemas map[string]string
 
 	lastRefresh time.Time
 }
@@ -62,11 +77,8 @@ func NewGeminiWebState(cfg *config.Config, token *gemini.GeminiWebTokenStorage,
 		convData:    make(map[string]ConversationRecord),
 		convIndex:   make(map[string]string),
 	}
-	suffix := Sha256Hex(token.Secure1PSID)
-	if len(suffix) > 16 {
-		suffix = suffix[:16]
-	}
-	state.stableClientID = "gemini-web-" + suffix
+	suffix := stableClientSuffix(token.Secure1PSID)
+	state.stableClientID = StableClientID(token.Secure1PSID)
 	if storagePath != "" {
 		base := strings.TrimSuffix(filepath.Base(storagePath), filepath.Ext(storagePath))
 		if base != "" {
@@ -81,6 +93,20 @@ func NewGeminiWebState(cfg *config.Config, token *gemini.GeminiWebTokenStorage,
 	return state
 }
 
+// StableClientID derives the client ID conversation hashes are keyed by from the account's
+// __Secure-1PSID cookie, so it survives auth file renames and cookie rotation of 1PSIDTS.
+func StableClientID(secure1PSID string) string {
+	return "gemini-web-" + stableClientSuffix(secure1PSID)
+}
+
+func stableClientSuffix(secure1PSID string) string {
+	suffix := Sha256Hex(secure1PSID)
+	if len(suffix) > 16 {
+		suffix = suffix[:16]
+	}
+	return suffix
+}
+
 // Label returns a stable account label for logging and persistence.
 // If a storage file path is known, it uses the file base name (without extension).
 // Otherwise, it falls back to the stable client ID (e.g., "gemini-web-<hash>").
@@ -123,64 +149,196 @@ func (s *GeminiWebState) convPath() string {
 
 func (s *GeminiWebState) GetRequestMutex() *sync.Mutex { return &s.reqMu }
 
-func (s *GeminiWebState) EnsureClient() error {
-	if s.client != nil && s.client.Running {
+// clientFlight is the key shared by initializations and refreshes, which both replace the
+// client: a caller arriving while either runs takes its client rather than starting another.
+const clientFlight = "client"
+
+// EnsureClient initializes the account's client unless it is ready. Concurrent callers share
+// one initialization, or the refresh in flight, and its outcome; each stops waiting when its
+// own ctx is done, leaving the initialization to finish for the others.
+func (s *GeminiWebState) EnsureClient(ctx context.Context) error {
+	if s.IsReady() {
 		return nil
 	}
-	proxyURL := ""
-	if s.cfg != nil {
-		proxyURL = s.cfg.ProxyURL
-	}
-	s.client = NewGeminiClient(
-		s.token.Secure1PSID,
-		s.token.Secure1PSIDTS,
-		proxyURL,
-	)
-	timeout := geminiWebDefaultTimeoutSec
-	if err := s.client.Init(float64(timeout), false); err != nil {
+	_, err := s.shared(ctx, clientFlight, func() (any, error) {
+		if s.IsReady() {
+			return nil, nil
+		}
+		client := s.newClient()
+		if err := client.Init(float64(geminiWebDefaultTimeoutSec), false); err != nil {
+			return nil, err
+		}
+		s.clientMu.Lock()
+		s.client = client
+		s.lastRefresh = time.Now()
+		s.clientMu.Unlock()
+		return nil, nil
+	})
+	return err
+}
+
+// shared runs fn for key once for all concurrent callers and waits for its result, giving up
+// when ctx is done. No lock is held while waiting.
+func (s *GeminiWebState) shared(ctx context.Context, key string, fn func() (any, error)) (any, error) {
+	result := s.flight.DoChan(key, fn)
+	select {
+	case res := <-result:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newClient returns an uninitialized client for the account's current cookies.
+func (s *GeminiWebState) newClient() *GeminiClient {
+	token := s.TokenSnapshot()
+	return NewGeminiClient(token.Secure1PSID, token.Secure1PSIDTS, s.proxyURL(), WithUserAgent(s.userAgent()))
+}
+
+// IsReady reports whether the state holds an initialized client.
+func (s *GeminiWebState) IsReady() bool {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	return s.client != nil && s.client.Running
+}
+
+// Release drops the initialized client so an idle account stops holding a session.
+// Conversation caches are kept; the next EnsureClient starts a fresh client.
+func (s *GeminiWebState) Release() {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	if s.client != nil {
+		s.client.Close(0)
 		s.client = nil
-		return err
 	}
-	s.lastRefresh = time.Now()
-	return nil
 }
 
+// Refresh replaces the account's client with a newly initialized one and rotates
+// __Secure-1PSIDTS. Concurrent refreshes share one, as does an initialization in flight.
 func (s *GeminiWebState) Refresh(ctx context.Context) error {
-	_ = ctx
-	proxyURL := ""
-	if s.cfg != nil {
-		proxyURL = s.cfg.ProxyURL
-	}
-	s.client = NewGeminiClient(
-		s.token.Secure1PSID,
-		s.token.Secure1PSIDTS,
-		proxyURL,
-	)
-	timeout := geminiWebDefaultTimeoutSec
-	if err := s.client.Init(float64(timeout), false); err != nil {
-		return err
+	_, err := s.shared(ctx, clientFlight, func() (any, error) {
+		client := s.newClient()
+		if err := client.Init(float64(geminiWebDefaultTimeoutSec), false); err != nil {
+			return nil, err
+		}
+		// Attempt rotation proactively to persist new TS sooner
+		if newTS, err := client.RotateTS(); err == nil && newTS != "" {
+			s.tokenMu.Lock()
+			if newTS != s.token.Secure1PSIDTS {
+				s.token.Secure1PSIDTS = newTS
+				s.tokenDirty = true
+				if client.Cookies != nil {
+					client.Cookies["__Secure-1PSIDTS"] = newTS
+				}
+				// Detailed debug log: provider and account.
+				log.Debugf("gemini web account %s rotated 1PSIDTS: %s", s.accountID, MaskToken28(newTS))
+			}
+			s.tokenMu.Unlock()
+		}
+		s.clientMu.Lock()
+		s.client = client
+		s.lastRefresh = time.Now()
+		s.clientMu.Unlock()
+		return nil, nil
+	})
+	return err
+}
+
+func (s *GeminiWebState) TokenSnapshot() *gemini.GeminiWebTokenStorage {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+	c := *s.token
+	return &c
+}
+
+// RotateNow rotates __Secure-1PSIDTS right away instead of waiting for the next refresh and
+// returns the cookie in use afterwards, which is unchanged when Google did not issue a new
+// one. The rotation holds the request mutex, so it waits for the request in flight on the
+// account; concurrent callers share it and only wait for its result.
+func (s *GeminiWebState) RotateNow() (string, error) {
+	current, err := s.shared(context.Background(), "rotate", func() (any, error) {
+		return s.rotateNow()
+	})
+	if err != nil {
+		return "", err
+	}
+	return current.(string), nil
+}
+
+func (s *GeminiWebState) rotateNow() (string, error) {
+	s.reqMu.Lock()
+	defer s.reqMu.Unlock()
+
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	var newTS string
+	var err error
+	if s.client != nil && s.client.Running {
+		newTS, err = s.client.RotateTS()
+	} else {
+		newTS, err = rotate1PSIDTS(s.baseCookies(), s.proxyURL(), false)
+	}
+	if err != nil {
+		return "", err
 	}
-	// Attempt rotation proactively to persist new TS sooner
-	if newTS, err := s.client.RotateTS(); err == nil && newTS != "" && newTS != s.token.Secure1PSIDTS {
-		s.tokenMu.Lock()
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+	if newTS != "" && newTS != s.token.Secure1PSIDTS {
 		s.token.Secure1PSIDTS = newTS
 		s.tokenDirty = true
 		if s.client != nil && s.client.Cookies != nil {
 			s.client.Cookies["__Secure-1PSIDTS"] = newTS
 		}
-		s.tokenMu.Unlock()
-		// Detailed debug log: provider and account.
-		log.Debugf("gemini web account %s rotated 1PSIDTS: %s", s.accountID, MaskToken28(newTS))
+		log.Debugf("gemini web account %s rotated 1PSIDTS on demand: %s", s.accountID, MaskToken28(newTS))
 	}
 	s.lastRefresh = time.Now()
-	return nil
+	return s.token.Secure1PSIDTS, nil
 }
 
-func (s *GeminiWebState) TokenSnapshot() *gemini.GeminiWebTokenStorage {
+// CheckCookies reports whether Google still accepts the account's cookies by fetching an
+// access token with them. Nothing is changed: the client in use and the cookies are kept.
+// It also returns when the cookies were last refreshed or rotated, zero if never.
+func (s *GeminiWebState) CheckCookies() (time.Time, error) {
+	s.reqMu.Lock()
+	defer s.reqMu.Unlock()
+	_, _, err := getAccessToken(s.baseCookies(), s.proxyURL(), false, false, s.userAgent())
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	return s.lastRefresh, err
+}
+
+func (s *GeminiWebState) baseCookies() map[string]string {
 	s.tokenMu.Lock()
 	defer s.tokenMu.Unlock()
-	c := *s.token
-	return &c
+	return map[string]string{
+		"__Secure-1PSID":   s.token.Secure1PSID,
+		"__Secure-1PSIDTS": s.token.Secure1PSIDTS,
+	}
+}
+
+func (s *GeminiWebState) proxyURL() string {
+	s.proxyMu.Lock()
+	proxy, rotated := s.proxy, s.rotated
+	s.proxyMu.Unlock()
+	if rotated {
+		return proxy
+	}
+	if s.cfg == nil {
+		return ""
+	}
+	return s.cfg.ProxyURL
+}
+
+// userAgent returns the configured Gemini Web user agent, or empty for the built-in one.
+func (s *GeminiWebState) userAgent() string {
+	if s.cfg == nil {
+		return ""
+	}
+	if userAgent := s.cfg.UserAgent(constant.GeminiWeb); userAgent != "" {
+		log.Debugf("gemini web: using configured user agent %q", userAgent)
+		return userAgent
+	}
+	return ""
 }
 
 type geminiWebPrepared struct {
@@ -194,6 +352,89 @@ type geminiWebPrepared struct {
 	reuse         bool
 	tagged        bool
 	originalRaw   []byte
+	outputCap     int
+	truncated     bool
+	// emptyReply is set when the request had no prompt and is answered with an empty
+	// completion instead of being sent.
+	emptyReply bool
+	tools      toolSchemaBlock
+	reuseStats ReuseStats
+}
+
+// Values of ReuseStats.Mode.
+const (
+	// ReuseNone is a cold start: the whole history was sent in a new conversation.
+	ReuseNone = "none"
+	// ReuseMatch continued the recorded conversation whose history the request repeats.
+	ReuseMatch = "match"
+	// ReuseFallback continued the latest conversation of the account for the model, as the
+	// history matched no recorded one.
+	ReuseFallback = "fallback"
+)
+
+// ReuseStats describes how much of the history of a request Gemini Web already held
+// server-side through conversation metadata reuse.
+type ReuseStats struct {
+	Mode string
+	// Matched is the number of prior messages carried by the continued conversation and
+	// Resent the number of messages sent in the prompt.
+	Matched int
+	Resent  int
+	// TokensSaved estimates the prompt tokens spared: the estimate for the full history less
+	// the one for the messages sent.
+	TokensSaved int
+}
+
+// Reuse returns how the request reused an earlier conversation.
+func (p *geminiWebPrepared) Reuse() ReuseStats {
+	if p == nil {
+		return ReuseStats{Mode: ReuseNone}
+	}
+	return p.reuseStats
+}
+
+// newReuseStats returns the reuse figures of a request whose history is sent as sent
+// after reusing a conversation in mode. A fallback continues a conversation whose history is
+// unknown, so nothing is reported as matched or saved for it.
+func newReuseStats(mode string, history, sent []RoleText) ReuseStats {
+	stats := ReuseStats{Mode: mode, Resent: len(sent)}
+	if mode != ReuseMatch {
+		return stats
+	}
+	stats.Matched = len(history) - len(sent)
+	if saved := estimateMessageTokens(history) - estimateMessageTokens(sent); saved > 0 {
+		stats.TokensSaved = saved
+	}
+	return stats
+}
+
+// estimateMessageTokens estimates the prompt tokens of msgs as EstimateTotalTokensFromRawJSON
+// does for a request holding them.
+func estimateMessageTokens(msgs []RoleText) int {
+	raw := []byte(`{"contents":[]}`)
+	for _, msg := range msgs {
+		content, _ := sjson.Set(`{"parts":[{"text":""}]}`, "parts.0.text", msg.Text)
+		raw, _ = sjson.SetRawBytes(raw, "contents.-1", []byte(content))
+	}
+	return EstimateTotalTokensFromRawJSON(raw)
+}
+
+// OutputCap returns the output token cap applied to the response, zero when none applied,
+// and whether the response was cut off at it.
+func (p *geminiWebPrepared) OutputCap() (int, bool) {
+	if p == nil {
+		return 0, false
+	}
+	return p.outputCap, p.truncated
+}
+
+// ToolSchemaTokensSaved estimates the prompt tokens spared by referring to tool schemas the
+// conversation already received instead of sending them again.
+func (p *geminiWebPrepared) ToolSchemaTokensSaved() int {
+	if p == nil {
+		return 0
+	}
+	return p.tools.saved
 }
 
 func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON []byte, stream bool, original []byte) (*geminiWebPrepared, *interfaces.ErrorMessage) {
@@ -218,6 +459,9 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 	}
 
 	var meta []string
+	// matched is set when meta belongs to a conversation recorded with exactly this history.
+	matched := false
+	reuseMode := ReuseNone
 	useMsgs := cleaned
 	filesSubset := files
 	mimesSubset := mimes
@@ -226,6 +470,8 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 		reuseMeta, remaining := s.findReusableSession(res.underlying, cleaned)
 		if len(reuseMeta) > 0 {
 			res.reuse = true
+			matched = true
+			reuseMode = ReuseMatch
 			meta = reuseMeta
 			if len(remaining) == 1 {
 				useMsgs = []RoleText{remaining[0]}
@@ -272,6 +518,7 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 					meta = fallbackMeta
 					useMsgs = []RoleText{cleaned[len(cleaned)-1]}
 					res.reuse = true
+					reuseMode = ReuseFallback
 					filesSubset = nil
 					mimesSubset = nil
 				}
@@ -289,6 +536,7 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 		s.convMu.RUnlock()
 	}
 
+	res.reuseStats = newReuseStats(reuseMode, cleaned, useMsgs)
 	res.tagged = NeedRoleTags(useMsgs)
 	if res.reuse && len(useMsgs) == 1 {
 		res.tagged = false
@@ -299,7 +547,23 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 
 	res.prompt = BuildPrompt(useMsgs, res.tagged, res.tagged)
 	if strings.TrimSpace(res.prompt) == "" {
-		return nil, &interfaces.ErrorMessage{StatusCode: 400, Error: errors.New("bad request: empty prompt after filtering system/thought content")}
+		switch s.emptyPromptMode() {
+		case config.EmptyPromptPlaceholder:
+			res.prompt = emptyPromptPlaceholder(res.translatedRaw)
+		case config.EmptyPromptEmpty:
+			res.emptyReply = true
+			return res, nil
+		default:
+			return nil, &interfaces.ErrorMessage{StatusCode: 400, Error: errors.New("bad request: empty prompt after filtering system/thought content")}
+		}
+	}
+	res.tools = s.buildToolSchemaBlock(res.translatedRaw, meta, matched)
+	if res.tools.text != "" {
+		res.prompt = res.tools.text + "\n\n" + res.prompt
+	}
+	// System instructions are dropped for Gemini Web, so carry the response language hint as a prompt prefix.
+	if hint := util.FindResponseLanguageInstruction(gjson.GetBytes(res.translatedRaw, "systemInstruction").Raw + gjson.GetBytes(res.translatedRaw, "system_instruction").Raw); hint != "" && !strings.Contains(res.prompt, hint) {
+		res.prompt = hint + "\n\n" + res.prompt
 	}
 
 	uploaded, upErr := MaterializeInlineFiles(filesSubset, mimesSubset)
@@ -308,7 +572,7 @@ func (s *GeminiWebState) prepare(ctx context.Context, modelName string, rawJSON
 	}
 	res.uploaded = uploaded
 
-	if err = s.EnsureClient(); err != nil {
+	if err = s.EnsureClient(ctx); err != nil {
 		return nil, &interfaces.ErrorMessage{StatusCode: 500, Error: err}
 	}
 	chat := s.client.StartChat(model, s.getConfiguredGem(), meta)
@@ -323,12 +587,25 @@ func (s *GeminiWebState) Send(ctx context.Context, modelName string, reqPayload
 	if errMsg != nil {
 		return nil, errMsg, nil
 	}
+	if prep.emptyReply {
+		gemBytes, err := ConvertOutputToGemini(&ModelOutput{Candidates: []Candidate{{}}}, modelName, "")
+		if err != nil {
+			return nil, &interfaces.ErrorMessage{StatusCode: 500, Error: err}, nil
+		}
+		return gemBytes, nil, prep
+	}
 	defer CleanupFiles(prep.uploaded)
 
-	output, err := SendWithSplit(prep.chat, prep.prompt, prep.uploaded, s.cfg)
+	// Gemini Web ignores output limits, so the configured cap is enforced while the reply is
+	// read: reading stops once the text crosses it, and the text is then cut to it exactly.
+	if s.cfg != nil {
+		prep.outputCap = s.cfg.MaxOutputTokens.Limit(constant.GeminiWeb, modelName)
+	}
+	output, err := SendWithSplit(withOutputLimit(ctx, prep.outputCap), prep.chat, prep.prompt, prep.uploaded, s.cfg)
 	if err != nil {
 		return nil, s.wrapSendError(err), nil
 	}
+	s.rememberToolSchemas(prep.chat, prep.tools)
 
 	// Hook: For gemini-2.5-flash-image-preview, if the API returns only images without any text,
 	// inject a small textual summary so that conversation persistence has non-empty assistant text.
@@ -349,39 +626,78 @@ func (s *GeminiWebState) Send(ctx context.Context, modelName string, reqPayload
 		}
 	}
 
+	prep.truncated = capOutputTokens(&output, prep.outputCap)
+
 	gemBytes, err := ConvertOutputToGemini(&output, modelName, prep.prompt)
 	if err != nil {
 		return nil, &interfaces.ErrorMessage{StatusCode: 500, Error: err}, nil
 	}
+	if prep.truncated {
+		gemBytes, _ = sjson.SetBytes(gemBytes, "candidates.0.finishReason", cappedFinishReason(prep.handlerType))
+		logging.RecordOutputCapNote(ctx, fmt.Sprintf("response cut off at %d output tokens", prep.outputCap))
+	}
 
 	s.addAPIResponseData(ctx, gemBytes)
-	s.persistConversation(modelName, prep, &output)
+	s.persistConversation(ctx, modelName, prep, &output)
 	return gemBytes, nil, prep
 }
 
+// emptyPromptFallback is sent when the placeholder behavior is configured and the request
+// carries no system instructions either.
+const emptyPromptFallback = "Hello."
+
+func (s *GeminiWebState) emptyPromptMode() string {
+	if s.cfg == nil {
+		return config.EmptyPromptError
+	}
+	return strings.ToLower(strings.TrimSpace(s.cfg.GeminiWeb.EmptyPrompt))
+}
+
+// emptyPromptPlaceholder returns the user turn sent for a request without a prompt. Gemini
+// Web has no system instructions, so those of a system-only request become the turn itself.
+func emptyPromptPlaceholder(rawJSON []byte) string {
+	var parts []string
+	for _, path := range []string{"systemInstruction.parts", "system_instruction.parts"} {
+		gjson.GetBytes(rawJSON, path).ForEach(func(_, part gjson.Result) bool {
+			if text := strings.TrimSpace(part.Get("text").String()); text != "" {
+				parts = append(parts, text)
+			}
+			return true
+		})
+	}
+	if len(parts) == 0 {
+		return emptyPromptFallback
+	}
+	return strings.Join(parts, "\n\n")
+}
+
 func (s *GeminiWebState) wrapSendError(genErr error) *interfaces.ErrorMessage {
 	status := 500
+	kind := cliproxyexecutor.ErrorKindUpstream
 	var usage *UsageLimitExceeded
 	var blocked *TemporarilyBlocked
+	var authErr *AuthError
 	var invalid *ModelInvalid
 	var valueErr *ValueError
 	var timeout *TimeoutError
 	switch {
 	case errors.As(genErr, &usage):
-		status = 429
+		status, kind = 429, cliproxyexecutor.ErrorKindQuota
 	case errors.As(genErr, &blocked):
-		status = 429
+		status, kind = 429, cliproxyexecutor.ErrorKindQuota
+	case errors.As(genErr, &authErr):
+		status, kind = 401, cliproxyexecutor.ErrorKindAuth
 	case errors.As(genErr, &invalid):
-		status = 400
+		status, kind = 400, cliproxyexecutor.ErrorKindInvalid
 	case errors.As(genErr, &valueErr):
-		status = 400
-	case errors.As(genErr, &timeout):
-		status = 504
+		status, kind = 400, cliproxyexecutor.ErrorKindInvalid
+	case errors.As(genErr, &timeout), errors.Is(genErr, context.DeadlineExceeded):
+		status, kind = 504, cliproxyexecutor.ErrorKindTransient
 	}
-	return &interfaces.ErrorMessage{StatusCode: status, Error: genErr}
+	return &interfaces.ErrorMessage{StatusCode: status, Error: genErr, Kind: kind}
 }
 
-func (s *GeminiWebState) persistConversation(modelName string, prep *geminiWebPrepared, output *ModelOutput) {
+func (s *GeminiWebState) persistConversation(ctx context.Context, modelName string, prep *geminiWebPrepared, output *ModelOutput) {
 	if output == nil || prep == nil || prep.chat == nil {
 		return
 	}
@@ -416,11 +732,28 @@ func (s *GeminiWebState) persistConversation(modelName string, prep *geminiWebPr
 	accountHash := HashConversation(s.accountID, prep.underlying, rec.Messages)
 
 	s.convMu.Lock()
+	// A follow-up turn extends the record of the previous one, whose title still applies.
+	if len(rec.Messages) > 2 {
+		previous := HashConversation(rec.ClientID, prep.underlying, rec.Messages[:len(rec.Messages)-2])
+		if prev, ok := s.convData[previous]; ok {
+			rec.Title = prev.Title
+			rec.CreatedAt = prev.CreatedAt
+		}
+	}
 	s.convData[stableHash] = rec
 	s.convIndex["hash:"+stableHash] = stableHash
 	if accountHash != stableHash {
 		s.convIndex["hash:"+accountHash] = stableHash
 	}
+	dataSnapshot, indexSnapshot := s.convDataSnapshot()
+	s.convMu.Unlock()
+	_ = SaveConvData(s.convPath(), dataSnapshot, indexSnapshot)
+	s.enqueueTitle(ctx, stableHash, rec)
+}
+
+// convDataSnapshot copies the conversation data and index for saving. The caller must hold
+// convMu.
+func (s *GeminiWebState) convDataSnapshot() (map[string]ConversationRecord, map[string]string) {
 	dataSnapshot := make(map[string]ConversationRecord, len(s.convData))
 	for k, v := range s.convData {
 		dataSnapshot[k] = v
@@ -429,14 +762,63 @@ func (s *GeminiWebState) persistConversation(modelName string, prep *geminiWebPr
 	for k, v := range s.convIndex {
 		indexSnapshot[k] = v
 	}
-	s.convMu.Unlock()
-	_ = SaveConvData(s.convPath(), dataSnapshot, indexSnapshot)
+	return dataSnapshot, indexSnapshot
 }
 
 func (s *GeminiWebState) addAPIResponseData(ctx context.Context, line []byte) {
 	appendAPIResponseChunk(ctx, s.cfg, line)
 }
 
+// capOutputTokens cuts every candidate of output to roughly limit tokens, thoughts first,
+// and reports whether anything was cut.
+func capOutputTokens(output *ModelOutput, limit int) bool {
+	if output == nil || limit <= 0 {
+		return false
+	}
+	cut := false
+	for i := range output.Candidates {
+		c := &output.Candidates[i]
+		budget := limit
+		if c.Thoughts != nil {
+			thoughts, truncated := truncateToTokens(*c.Thoughts, budget)
+			c.Thoughts = &thoughts
+			cut = cut || truncated
+			budget -= estimateTokens(thoughts)
+		}
+		text, truncated := truncateToTokens(c.Text, budget)
+		c.Text = text
+		cut = cut || truncated
+	}
+	return cut
+}
+
+// truncateToTokens cuts s to limit tokens using the same four runes per token estimate as
+// the reported usage.
+func truncateToTokens(s string, limit int) (string, bool) {
+	if s == "" {
+		return s, false
+	}
+	if limit <= 0 {
+		return "", true
+	}
+	runes := []rune(s)
+	if len(runes) <= limit*4 {
+		return s, false
+	}
+	return string(runes[:limit*4]), true
+}
+
+// cappedFinishReason returns the finish reason reporting a cut off response in the format
+// the client's handler translates from.
+func cappedFinishReason(handlerType string) string {
+	switch handlerType {
+	case constant.OpenAI, constant.OpenaiResponse:
+		return "length"
+	default:
+		return "MAX_TOKENS"
+	}
+}
+
 func (s *GeminiWebState) ConvertToTarget(ctx context.Context, modelName string, prep *geminiWebPrepared, gemBytes []byte) []byte {
 	if prep == nil || prep.handlerType == "" {
 		return gemBytes
@@ -462,6 +844,9 @@ func (s *GeminiWebState) ConvertStream(ctx context.Context, modelName string, pr
 	if !translator.NeedConvert(prep.handlerType, constant.GeminiWeb) {
 		return []string{string(gemBytes)}
 	}
+	if s.cfg != nil && s.cfg.GeminiWeb.CodeMode && !s.cfg.GeminiWeb.CodeModeReasoning {
+		gemBytes = mergeThoughtIntoSingleContent(gemBytes)
+	}
 	var param any
 	return translator.Response(prep.handlerType, constant.GeminiWeb, ctx, modelName, prep.originalRaw, prep.translatedRaw, gemBytes, &param)
 }
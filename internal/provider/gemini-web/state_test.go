@@ -0,0 +1,62 @@
+package geminiwebapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+)
+
+func TestNewReuseStats(t *testing.T) {
+	history := []RoleText{
+		{Role: "user", Text: "What is the capital of France?"},
+		{Role: "assistant", Text: "Paris."},
+		{Role: "user", Text: "And of Italy?"},
+	}
+	sent := history[2:]
+
+	match := newReuseStats(ReuseMatch, history, sent)
+	if match.Matched != 2 || match.Resent != 1 || match.TokensSaved <= 0 {
+		t.Fatalf("match stats = %+v", match)
+	}
+	// The conversation a fallback continues may hold anything, so nothing is claimed.
+	fallback := newReuseStats(ReuseFallback, history, sent)
+	if fallback != (ReuseStats{Mode: ReuseFallback, Resent: 1}) {
+		t.Fatalf("fallback stats = %+v", fallback)
+	}
+	if none := newReuseStats(ReuseNone, history, history); none != (ReuseStats{Mode: ReuseNone, Resent: 3}) {
+		t.Fatalf("cold start stats = %+v", none)
+	}
+}
+
+func TestEnsureClientJoinsRefreshInFlight(t *testing.T) {
+	// The state opens its conversation store under ./conv.
+	t.Chdir(t.TempDir())
+	state := NewGeminiWebState(nil, &gemini.GeminiWebTokenStorage{Secure1PSID: "psid"}, "")
+	errRefresh := errors.New("refresh failed")
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_, _ = state.shared(context.Background(), clientFlight, func() (any, error) {
+			close(started)
+			<-release
+			return nil, errRefresh
+		})
+	}()
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- state.EnsureClient(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	select {
+	case err := <-done:
+		if !errors.Is(err, errRefresh) {
+			t.Fatalf("EnsureClient error = %v, want the refresh's", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("EnsureClient did not join the refresh in flight")
+	}
+}
@@ -0,0 +1,211 @@
+package geminiwebapi
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// titleQueueSize bounds the pending title jobs; jobs arriving while it is full are dropped.
+	titleQueueSize = 64
+	// titleInterval is the minimum time between two title requests.
+	titleInterval = 2 * time.Second
+	titleTimeout  = 30 * time.Second
+	// titleMaxWords caps the length of a stored title.
+	titleMaxWords = 8
+	// titleInputRunes caps the conversation text sent to the title model.
+	titleInputRunes = 4000
+)
+
+// TitleFunc asks the title model for a title of conversation, a plain text transcript.
+type TitleFunc func(ctx context.Context, conversation string) (string, error)
+
+type titleJob struct {
+	state *GeminiWebState
+	key   string
+	rec   ConversationRecord
+}
+
+type titleJobKey struct{}
+
+var (
+	titleMu    sync.RWMutex
+	titleFunc  TitleFunc
+	titleQueue chan titleJob
+	titleOnce  sync.Once
+)
+
+// SetTitleGenerator sets the function titling stored conversations and starts the worker
+// that runs title jobs one at a time.
+func SetTitleGenerator(fn TitleFunc) {
+	titleMu.Lock()
+	titleFunc = fn
+	titleMu.Unlock()
+	titleOnce.Do(func() {
+		titleQueue = make(chan titleJob, titleQueueSize)
+		go runTitleJobs(titleQueue)
+	})
+}
+
+// enqueueTitle schedules a title for the record stored under key. It never blocks: the job
+// is dropped when titles are disabled, the queue is full, or ctx is a title request itself.
+func (s *GeminiWebState) enqueueTitle(ctx context.Context, key string, rec ConversationRecord) {
+	if rec.Title != "" || s.cfg == nil || strings.TrimSpace(s.cfg.GeminiWeb.TitleModel) == "" {
+		return
+	}
+	if ctx != nil && ctx.Value(titleJobKey{}) != nil {
+		return
+	}
+	titleMu.RLock()
+	queue, fn := titleQueue, titleFunc
+	titleMu.RUnlock()
+	if queue == nil || fn == nil {
+		return
+	}
+	select {
+	case queue <- titleJob{state: s, key: key, rec: rec}:
+	default:
+		log.Debugf("gemini web: title queue full, conversation %s stays untitled", key)
+	}
+}
+
+func runTitleJobs(queue <-chan titleJob) {
+	var last time.Time
+	for job := range queue {
+		if wait := titleInterval - time.Since(last); wait > 0 {
+			time.Sleep(wait)
+		}
+		last = time.Now()
+		titleMu.RLock()
+		fn := titleFunc
+		titleMu.RUnlock()
+		if fn == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), titleJobKey{}, true), titleTimeout)
+		title, err := fn(ctx, titleTranscript(job.rec.Messages))
+		cancel()
+		title = cleanTitle(title)
+		if err != nil || title == "" {
+			log.Debugf("gemini web: failed to title conversation %s: %v", job.key, err)
+			continue
+		}
+		job.state.setConversationTitle(job.key, title)
+	}
+}
+
+// setConversationTitle stores title on the record under key, if it is still stored.
+func (s *GeminiWebState) setConversationTitle(key, title string) {
+	s.convMu.Lock()
+	rec, ok := s.convData[key]
+	if !ok {
+		s.convMu.Unlock()
+		return
+	}
+	rec.Title = title
+	s.convData[key] = rec
+	dataSnapshot, indexSnapshot := s.convDataSnapshot()
+	s.convMu.Unlock()
+	_ = SaveConvData(s.convPath(), dataSnapshot, indexSnapshot)
+}
+
+func titleTranscript(messages []StoredMessage) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		b.WriteString(msg.Role)
+		b.WriteString(": ")
+		b.WriteString(msg.Content)
+		b.WriteString("\n")
+	}
+	runes := []rune(b.String())
+	if len(runes) > titleInputRunes {
+		runes = runes[:titleInputRunes]
+	}
+	return string(runes)
+}
+
+// cleanTitle keeps the first line of a model reply, without quotes or a trailing period,
+// cut to titleMaxWords words.
+func cleanTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	words := strings.Fields(strings.Trim(title, "\"'`*# "))
+	if len(words) > titleMaxWords {
+		words = words[:titleMaxWords]
+	}
+	return strings.TrimRight(strings.Join(words, " "), ".")
+}
+
+// ConversationSummary describes one stored conversation in a listing.
+type ConversationSummary struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Model        string    `json:"model"`
+	MessageCount int       `json:"message_count"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ListConversations returns the conversations stored at path, most recently updated first,
+// skipping offset and returning at most limit of them (all when limit <= 0), along with the
+// total number stored.
+func ListConversations(path string, offset, limit int) ([]ConversationSummary, int, error) {
+	items, err := loadConversations(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	list := make([]ConversationSummary, 0, len(items))
+	for id, rec := range items {
+		list = append(list, ConversationSummary{
+			ID:           id,
+			Title:        rec.Title,
+			Model:        rec.Model,
+			MessageCount: len(rec.Messages),
+			UpdatedAt:    rec.UpdatedAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].UpdatedAt.Equal(list[j].UpdatedAt) {
+			return list[i].UpdatedAt.After(list[j].UpdatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	total := len(list)
+	if offset >= total {
+		return []ConversationSummary{}, total, nil
+	}
+	list = list[max(offset, 0):]
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, total, nil
+}
+
+// LoadConversation returns the conversation stored at path under id.
+func LoadConversation(path, id string) (ConversationRecord, bool, error) {
+	items, err := loadConversations(path)
+	if err != nil {
+		return ConversationRecord{}, false, err
+	}
+	rec, ok := items[id]
+	return rec, ok, nil
+}
+
+// loadConversations reads the conversations stored at path without creating the store.
+func loadConversations(path string) (map[string]ConversationRecord, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return map[string]ConversationRecord{}, nil
+		}
+		return nil, err
+	}
+	items, _, err := LoadConvData(path)
+	return items, err
+}
@@ -0,0 +1,90 @@
+package geminiwebapi
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+// toolSchemaMemory caps the conversations whose tool schemas are remembered. Beyond it the
+// memory starts over, which only costs the forgotten conversations one full resend.
+const toolSchemaMemory = 4096
+
+// toolSchemaRefLength is the number of hash characters quoted in a tool schema reference.
+const toolSchemaRefLength = 12
+
+// toolSchemaBlock is the prompt prefix carrying the tool schemas of one request.
+type toolSchemaBlock struct {
+	// text is the prefix as sent: the full schemas or a reference to them.
+	text string
+	// hash identifies the schemas, empty when the request declares no tools.
+	hash string
+	// saved estimates the prompt tokens spared by sending a reference.
+	saved int
+}
+
+// toolSchemaMode returns the configured gemini-web.tool-schemas mode.
+func (s *GeminiWebState) toolSchemaMode() string {
+	if s.cfg == nil {
+		return config.ToolSchemasDrop
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(s.cfg.GeminiWeb.ToolSchemas)); mode {
+	case config.ToolSchemasFull, config.ToolSchemasCompact:
+		return mode
+	default:
+		return config.ToolSchemasDrop
+	}
+}
+
+// buildToolSchemaBlock renders the function declarations of the Gemini request rawJSON. In
+// compact mode the schemas are replaced by a reference when meta resumes a conversation that
+// already received the same schemas; matched must only be set when meta was found for exactly
+// this message history, as anything looser cannot promise the schemas are upstream.
+func (s *GeminiWebState) buildToolSchemaBlock(rawJSON []byte, meta []string, matched bool) toolSchemaBlock {
+	mode := s.toolSchemaMode()
+	if mode == config.ToolSchemasDrop {
+		return toolSchemaBlock{}
+	}
+	declarations := gjson.GetBytes(rawJSON, "tools.#.functionDeclarations|@flatten|@ugly")
+	if !declarations.IsArray() || len(declarations.Array()) == 0 {
+		return toolSchemaBlock{}
+	}
+	full := "Available tools (JSON schema):\n" + declarations.Raw
+	block := toolSchemaBlock{text: full, hash: Sha256Hex(declarations.Raw)}
+	if mode != config.ToolSchemasCompact || !matched || len(meta) == 0 || meta[0] == "" {
+		return block
+	}
+	s.convMu.RLock()
+	sent := s.toolSchemas[meta[0]]
+	s.convMu.RUnlock()
+	if sent != block.hash {
+		return block
+	}
+	ref := "Available tools: unchanged since they were given earlier in this conversation (ref " + block.hash[:toolSchemaRefLength] + ")."
+	// Schemas shorter than their reference are simply sent again.
+	if saved := estimateTextTokens(full) - estimateTextTokens(ref); saved > 0 {
+		block.text, block.saved = ref, saved
+	}
+	return block
+}
+
+// rememberToolSchemas records that the conversation of chat has received the schemas of block.
+func (s *GeminiWebState) rememberToolSchemas(chat *ChatSession, block toolSchemaBlock) {
+	if chat == nil || block.hash == "" || chat.CID() == "" {
+		return
+	}
+	s.convMu.Lock()
+	if s.toolSchemas == nil || len(s.toolSchemas) >= toolSchemaMemory {
+		s.toolSchemas = make(map[string]string)
+	}
+	s.toolSchemas[chat.CID()] = block.hash
+	s.convMu.Unlock()
+}
+
+// estimateTextTokens approximates the token count of text at four characters per token.
+func estimateTextTokens(text string) int {
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / 4.0))
+}
@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// ModelCapabilities describes what a model supports so clients can choose between models.
+// Capabilities start from built-in defaults per provider type and model, and can be
+// overridden per model ID through the configuration.
+type ModelCapabilities struct {
+	// ContextLength is the context window size in tokens
+	ContextLength int `json:"context_length,omitempty"`
+	// MaxOutputTokens is the maximum number of tokens generated per response
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+	// MaxInputTokens is the largest estimated prompt accepted, zero for no limit
+	MaxInputTokens int `json:"max_input_tokens,omitempty"`
+	// SupportsVision reports whether the model accepts image input
+	SupportsVision bool `json:"supports_vision"`
+	// SupportsTools reports whether the model supports tool or function calling
+	SupportsTools bool `json:"supports_tools"`
+	// SupportsStreaming reports whether the model can stream responses
+	SupportsStreaming bool `json:"supports_streaming"`
+}
+
+// typeCapabilities holds the defaults for every model of a provider type.
+var typeCapabilities = map[string]ModelCapabilities{
+	"claude": {ContextLength: 200000, MaxOutputTokens: 8192, SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"gemini": {SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"openai": {SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"qwen":   {SupportsTools: true, SupportsStreaming: true},
+}
+
+// modelCapabilities holds defaults for specific models whose limits differ from their type.
+var modelCapabilities = map[string]ModelCapabilities{
+	"claude-opus-4-1-20250805":   {ContextLength: 200000, MaxOutputTokens: 32000, SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"claude-opus-4-20250514":     {ContextLength: 200000, MaxOutputTokens: 32000, SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"claude-sonnet-4-20250514":   {ContextLength: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"claude-3-7-sonnet-20250219": {ContextLength: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"claude-3-5-haiku-20241022":  {ContextLength: 200000, MaxOutputTokens: 8192, SupportsVision: true, SupportsTools: true, SupportsStreaming: true},
+	"gemini-embedding-001":       {ContextLength: 2048},
+	"text-embedding-004":         {ContextLength: 2048},
+}
+
+// defaultCapabilities derives the built-in capabilities of a model from its type, any
+// model specific entry and the token limits carried by its definition.
+func defaultCapabilities(model *ModelInfo) ModelCapabilities {
+	caps, ok := modelCapabilities[model.ID]
+	if !ok {
+		caps, ok = typeCapabilities[model.Type]
+		if !ok {
+			caps = ModelCapabilities{SupportsStreaming: true}
+		}
+	}
+	if model.ContextLength > 0 {
+		caps.ContextLength = model.ContextLength
+	} else if model.InputTokenLimit > 0 {
+		caps.ContextLength = model.InputTokenLimit
+	}
+	if model.MaxCompletionTokens > 0 {
+		caps.MaxOutputTokens = model.MaxCompletionTokens
+	} else if model.OutputTokenLimit > 0 {
+		caps.MaxOutputTokens = model.OutputTokenLimit
+	}
+	return caps
+}
+
+// applyCapabilityOverride replaces the fields set in override.
+func applyCapabilityOverride(caps ModelCapabilities, override config.ModelCapability) ModelCapabilities {
+	if override.ContextLength != nil {
+		caps.ContextLength = *override.ContextLength
+	}
+	if override.MaxOutputTokens != nil {
+		caps.MaxOutputTokens = *override.MaxOutputTokens
+	}
+	if override.MaxInputTokens != nil {
+		caps.MaxInputTokens = *override.MaxInputTokens
+	}
+	if override.SupportsVision != nil {
+		caps.SupportsVision = *override.SupportsVision
+	}
+	if override.SupportsTools != nil {
+		caps.SupportsTools = *override.SupportsTools
+	}
+	if override.SupportsStreaming != nil {
+		caps.SupportsStreaming = *override.SupportsStreaming
+	}
+	return caps
+}
+
+// SetCapabilityOverrides replaces the configured capability overrides, keyed by model ID.
+// Parameters:
+//   - overrides: The per-model overrides from the configuration
+func (r *ModelRegistry) SetCapabilityOverrides(overrides map[string]config.ModelCapability) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.capabilityOverrides = make(map[string]config.ModelCapability, len(overrides))
+	for modelID, override := range overrides {
+		r.capabilityOverrides[modelID] = override
+	}
+}
+
+// GetModelCapabilities returns the effective capabilities of a registered model
+// Parameters:
+//   - modelID: The model ID to look up
+//
+// Returns:
+//   - ModelCapabilities: The built-in capabilities with configured overrides applied
+//   - bool: False when the model is not registered
+func (r *ModelRegistry) GetModelCapabilities(modelID string) (ModelCapabilities, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	registration, ok := r.models[modelID]
+	if !ok || registration.Info == nil {
+		return ModelCapabilities{}, false
+	}
+	return r.capabilitiesFor(registration.Info), true
+}
+
+// capabilitiesFor computes the effective capabilities of model. Callers must hold the mutex.
+func (r *ModelRegistry) capabilitiesFor(model *ModelInfo) ModelCapabilities {
+	caps := defaultCapabilities(model)
+	if override, ok := r.capabilityOverrides[model.ID]; ok {
+		caps = applyCapabilityOverride(caps, override)
+	}
+	return caps
+}
@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// listedModel returns the entry of modelID in the handlerType model list.
+func listedModel(t *testing.T, r *ModelRegistry, handlerType, modelID string) map[string]any {
+	t.Helper()
+	for _, model := range r.GetAvailableModels(handlerType) {
+		if model["id"] == modelID {
+			return model
+		}
+	}
+	t.Fatalf("model %s not listed for %s", modelID, handlerType)
+	return nil
+}
+
+func TestModelListReportsCapabilities(t *testing.T) {
+	r := GetGlobalRegistry()
+	r.RegisterClient("capabilities-test", "claude", []*ModelInfo{
+		{ID: "claude-opus-4-20250514", Object: "model", Type: "claude"},
+		{ID: "capabilities-test-model", Object: "model", Type: "qwen", ContextLength: 32768},
+	})
+	t.Cleanup(func() {
+		r.UnregisterClient("capabilities-test")
+		r.SetCapabilityOverrides(nil)
+	})
+
+	opus := listedModel(t, r, "openai", "claude-opus-4-20250514")
+	if opus["context_length"] != 200000 || opus["max_output_tokens"] != 32000 || opus["supports_vision"] != true {
+		t.Fatalf("opus capabilities = %v, want the built-in model defaults", opus)
+	}
+	qwen := listedModel(t, r, "openai", "capabilities-test-model")
+	if qwen["context_length"] != 32768 || qwen["supports_vision"] != false || qwen["supports_tools"] != true {
+		t.Fatalf("qwen capabilities = %v, want the type defaults and the definition's context", qwen)
+	}
+
+	contextLength, vision := 1000000, true
+	r.SetCapabilityOverrides(map[string]config.ModelCapability{
+		"capabilities-test-model": {ContextLength: &contextLength, SupportsVision: &vision},
+	})
+	qwen = listedModel(t, r, "openai", "capabilities-test-model")
+	if qwen["context_length"] != contextLength || qwen["supports_vision"] != true || qwen["supports_tools"] != true {
+		t.Fatalf("overridden capabilities = %v, want the configured fields and the rest kept", qwen)
+	}
+	if caps, ok := r.GetModelCapabilities("capabilities-test-model"); !ok || caps.ContextLength != contextLength {
+		t.Fatalf("GetModelCapabilities = %+v, %v, want the override", caps, ok)
+	}
+}
+
+func TestEmbeddingModelsAreListedOnlyForGemini(t *testing.T) {
+	r := GetGlobalRegistry()
+	r.RegisterClient("embedding-test", "gemini", append(GetGeminiModels(), GetGeminiEmbeddingModels()...))
+	t.Cleanup(func() { r.UnregisterClient("embedding-test") })
+
+	for _, handlerType := range []string{"openai", "claude"} {
+		for _, model := range r.GetAvailableModels(handlerType) {
+			if model["id"] == "gemini-embedding-001" || model["id"] == "text-embedding-004" {
+				t.Errorf("%s model list includes embedding model %v", handlerType, model["id"])
+			}
+		}
+	}
+	listedModel(t, r, "openai", GetGeminiModels()[0].ID)
+	found := false
+	for _, model := range r.GetAvailableModels("gemini") {
+		if model["name"] == "models/gemini-embedding-001" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("gemini model list lacks gemini-embedding-001")
+	}
+}
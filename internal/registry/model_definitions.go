@@ -3,8 +3,6 @@
 // when registering their supported models.
 package registry
 
-import "time"
-
 // GetClaudeModels returns the standard Claude model definitions
 func GetClaudeModels() []*ModelInfo {
 	return []*ModelInfo{
@@ -57,7 +55,7 @@ func GetGeminiModels() []*ModelInfo {
 		{
 			ID:                         "gemini-2.5-flash",
 			Object:                     "model",
-			Created:                    time.Now().Unix(),
+			Created:                    1750118400, // 2025-06-17
 			OwnedBy:                    "google",
 			Type:                       "gemini",
 			Name:                       "models/gemini-2.5-flash",
@@ -71,7 +69,7 @@ func GetGeminiModels() []*ModelInfo {
 		{
 			ID:                         "gemini-2.5-pro",
 			Object:                     "model",
-			Created:                    time.Now().Unix(),
+			Created:                    1750118400, // 2025-06-17
 			OwnedBy:                    "google",
 			Type:                       "gemini",
 			Name:                       "models/gemini-2.5-pro",
@@ -85,7 +83,7 @@ func GetGeminiModels() []*ModelInfo {
 		{
 			ID:                         "gemini-2.5-flash-lite",
 			Object:                     "model",
-			Created:                    time.Now().Unix(),
+			Created:                    1753142400, // 2025-07-22
 			OwnedBy:                    "google",
 			Type:                       "gemini",
 			Name:                       "models/gemini-2.5-flash-lite",
@@ -99,13 +97,48 @@ func GetGeminiModels() []*ModelInfo {
 	}
 }
 
+// GetGeminiEmbeddingModels returns the Gemini embedding model definitions served by
+// Generative Language API keys
+func GetGeminiEmbeddingModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752451200, // 2025-07-14
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent", "countTextTokens", "countTokens"},
+		},
+		{
+			ID:                         "text-embedding-004",
+			Object:                     "model",
+			Created:                    1715644800, // 2024-05-14
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/text-embedding-004",
+			Version:                    "004",
+			DisplayName:                "Text Embedding 004",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			OutputTokenLimit:           1,
+			SupportedGenerationMethods: []string{"embedContent"},
+		},
+	}
+}
+
 // GetGeminiCLIModels returns the standard Gemini model definitions
 func GetGeminiCLIModels() []*ModelInfo {
 	return []*ModelInfo{
 		{
 			ID:                         "gemini-2.5-flash",
 			Object:                     "model",
-			Created:                    time.Now().Unix(),
+			Created:                    1750118400, // 2025-06-17
 			OwnedBy:                    "google",
 			Type:                       "gemini",
 			Name:                       "models/gemini-2.5-flash",
@@ -119,7 +152,7 @@ func GetGeminiCLIModels() []*ModelInfo {
 		{
 			ID:                         "gemini-2.5-pro",
 			Object:                     "model",
-			Created:                    time.Now().Unix(),
+			Created:                    1750118400, // 2025-06-17
 			OwnedBy:                    "google",
 			Type:                       "gemini",
 			Name:                       "models/gemini-2.5-pro",
@@ -133,7 +166,7 @@ func GetGeminiCLIModels() []*ModelInfo {
 		{
 			ID:                         "gemini-2.5-flash-lite",
 			Object:                     "model",
-			Created:                    time.Now().Unix(),
+			Created:                    1753142400, // 2025-07-22
 			OwnedBy:                    "google",
 			Type:                       "gemini",
 			Name:                       "models/gemini-2.5-flash-lite",
@@ -153,7 +186,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1754524800, // 2025-08-07
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-08-07",
@@ -166,7 +199,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-minimal",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1754524800, // 2025-08-07
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-08-07",
@@ -179,7 +212,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-low",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1754524800, // 2025-08-07
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-08-07",
@@ -192,7 +225,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-medium",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1754524800, // 2025-08-07
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-08-07",
@@ -205,7 +238,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-high",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1754524800, // 2025-08-07
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-08-07",
@@ -218,7 +251,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-codex",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1757894400, // 2025-09-15
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-09-15",
@@ -231,7 +264,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-codex-low",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1757894400, // 2025-09-15
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-09-15",
@@ -244,7 +277,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-codex-medium",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1757894400, // 2025-09-15
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-09-15",
@@ -257,7 +290,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "gpt-5-codex-high",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1757894400, // 2025-09-15
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "gpt-5-2025-09-15",
@@ -270,7 +303,7 @@ func GetOpenAIModels() []*ModelInfo {
 		{
 			ID:                  "codex-mini-latest",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1747353600, // 2025-05-16
 			OwnedBy:             "openai",
 			Type:                "openai",
 			Version:             "1.0",
@@ -289,7 +322,7 @@ func GetQwenModels() []*ModelInfo {
 		{
 			ID:                  "qwen3-coder-plus",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1753142400, // 2025-07-22
 			OwnedBy:             "qwen",
 			Type:                "qwen",
 			Version:             "3.0",
@@ -302,7 +335,7 @@ func GetQwenModels() []*ModelInfo {
 		{
 			ID:                  "qwen3-coder-flash",
 			Object:              "model",
-			Created:             time.Now().Unix(),
+			Created:             1753920000, // 2025-07-31
 			OwnedBy:             "qwen",
 			Type:                "qwen",
 			Version:             "3.0",
@@ -314,3 +347,24 @@ func GetQwenModels() []*ModelInfo {
 		},
 	}
 }
+
+// EchoModelID is the model served by the built-in echo provider.
+const EchoModelID = "cliproxy-echo"
+
+// GetEchoModels returns the model served by the built-in echo provider.
+func GetEchoModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:                  EchoModelID,
+			Object:              "model",
+			Created:             1735689600, // 2025-01-01
+			OwnedBy:             "cliproxy",
+			Type:                "echo",
+			DisplayName:         "CLIProxy Echo",
+			Description:         "Answers with the request it received, for testing deployments",
+			ContextLength:       1000000,
+			MaxCompletionTokens: 1000000,
+			SupportedParameters: []string{"max_tokens", "stream", "tools"},
+		},
+	}
+}
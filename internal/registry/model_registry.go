@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -44,6 +45,8 @@ type ModelInfo struct {
 	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
 	// SupportedParameters lists supported parameters
 	SupportedParameters []string `json:"supported_parameters,omitempty"`
+	// Features overrides the features of the model's provider in the capabilities matrix
+	Features *ProviderFeatures `json:"-"`
 }
 
 // ModelRegistration tracks a model's availability
@@ -70,6 +73,12 @@ type ModelRegistry struct {
 	clientModels map[string][]string
 	// clientProviders maps client ID to its provider identifier
 	clientProviders map[string]string
+	// normalizedIDs maps a NormalizeModelName key to the sorted model IDs that fold to it
+	normalizedIDs map[string][]string
+	// capabilityOverrides maps model ID to configured capability overrides
+	capabilityOverrides map[string]config.ModelCapability
+	// providerFeatures maps provider identifier to the features it declared
+	providerFeatures map[string]ProviderFeatures
 	// mutex ensures thread-safe access to the registry
 	mutex *sync.RWMutex
 }
@@ -85,6 +94,7 @@ func GetGlobalRegistry() *ModelRegistry {
 			models:          make(map[string]*ModelRegistration),
 			clientModels:    make(map[string][]string),
 			clientProviders: make(map[string]string),
+			normalizedIDs:   make(map[string][]string),
 			mutex:           &sync.RWMutex{},
 		}
 	})
@@ -137,6 +147,7 @@ func (r *ModelRegistry) RegisterClient(clientID, clientProvider string, models [
 				registration.Providers = map[string]int{provider: 1}
 			}
 			r.models[model.ID] = registration
+			r.indexModelID(model.ID)
 			log.Debugf("Registered new model %s from provider %s", model.ID, clientProvider)
 		}
 	}
@@ -197,6 +208,7 @@ func (r *ModelRegistry) unregisterClientInternal(clientID string) {
 			// Remove model if no clients remain
 			if registration.Count <= 0 {
 				delete(r.models, modelID)
+				r.unindexModelID(modelID)
 				log.Debugf("Removed model %s as no clients remain", modelID)
 			}
 		}
@@ -327,10 +339,19 @@ func (r *ModelRegistry) GetAvailableModels(handlerType string) []map[string]any
 			effectiveClients = 0
 		}
 
-		// Only include models that have available clients
-		if effectiveClients > 0 {
+		// Only include models that have available clients, and embedding models only
+		// where they can be called
+		if effectiveClients > 0 && (handlerType == "gemini" || generatesContent(registration.Info)) {
 			model := r.convertModelToMap(registration.Info, handlerType)
 			if model != nil {
+				if _, ok := model["owned_by"]; ok {
+					if providers := sortedProviders(registration); len(providers) > 0 {
+						model["owned_by"] = providers[0]
+					}
+					if owner := r.capabilityOverrides[registration.Info.ID].OwnedBy; owner != "" {
+						model["owned_by"] = owner
+					}
+				}
 				models = append(models, model)
 			}
 		}
@@ -339,6 +360,20 @@ func (r *ModelRegistry) GetAvailableModels(handlerType string) []map[string]any
 	return models
 }
 
+// generatesContent reports whether model answers generation requests. Gemini embedding
+// models only serve embedContent, which the chat APIs have no route for.
+func generatesContent(model *ModelInfo) bool {
+	if model == nil || len(model.SupportedGenerationMethods) == 0 {
+		return true
+	}
+	for _, method := range model.SupportedGenerationMethods {
+		if method == "generateContent" {
+			return true
+		}
+	}
+	return false
+}
+
 // GetModelCount returns the number of available clients for a specific model
 // Parameters:
 //   - modelID: The model ID to check
@@ -373,6 +408,67 @@ func (r *ModelRegistry) GetModelCount(modelID string) int {
 	return 0
 }
 
+// NormalizeModelName folds a model name for lookups: surrounding whitespace is trimmed, inner
+// whitespace runs become a single space and letters are lower-cased.
+func NormalizeModelName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// ResolveModelID returns the registered model ID that name refers to, preferring an exact
+// match over one found after NormalizeModelName, and whether there is one.
+func (r *ModelRegistry) ResolveModelID(name string) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if _, exists := r.models[name]; exists {
+		return name, true
+	}
+	normalized := NormalizeModelName(name)
+	if normalized == "" {
+		return "", false
+	}
+	if ids := r.normalizedIDs[normalized]; len(ids) > 0 {
+		return ids[0], true
+	}
+	return "", false
+}
+
+// indexModelID adds modelID to the normalized-name index, keeping each entry sorted so that
+// ResolveModelID settles collisions on the same ID every time (internal, no locking).
+func (r *ModelRegistry) indexModelID(modelID string) {
+	key := NormalizeModelName(modelID)
+	if key == "" {
+		return
+	}
+	if r.normalizedIDs == nil {
+		r.normalizedIDs = make(map[string][]string)
+	}
+	ids := r.normalizedIDs[key]
+	i := sort.SearchStrings(ids, modelID)
+	if i < len(ids) && ids[i] == modelID {
+		return
+	}
+	ids = append(ids, "")
+	copy(ids[i+1:], ids[i:])
+	ids[i] = modelID
+	r.normalizedIDs[key] = ids
+}
+
+// unindexModelID removes modelID from the normalized-name index (internal, no locking).
+func (r *ModelRegistry) unindexModelID(modelID string) {
+	key := NormalizeModelName(modelID)
+	ids := r.normalizedIDs[key]
+	i := sort.SearchStrings(ids, modelID)
+	if i >= len(ids) || ids[i] != modelID {
+		return
+	}
+	ids = append(ids[:i], ids[i+1:]...)
+	if len(ids) == 0 {
+		delete(r.normalizedIDs, key)
+		return
+	}
+	r.normalizedIDs[key] = ids
+}
+
 // GetModelProviders returns provider identifiers that currently supply the given model
 // Parameters:
 //   - modelID: The model ID to check
@@ -384,7 +480,16 @@ func (r *ModelRegistry) GetModelProviders(modelID string) []string {
 	defer r.mutex.RUnlock()
 
 	registration, exists := r.models[modelID]
-	if !exists || registration == nil || len(registration.Providers) == 0 {
+	if !exists || registration == nil {
+		return nil
+	}
+	return sortedProviders(registration)
+}
+
+// sortedProviders returns the providers supplying registration, those with the most clients
+// first. The caller must hold the registry lock.
+func sortedProviders(registration *ModelRegistration) []string {
+	if len(registration.Providers) == 0 {
 		return nil
 	}
 
@@ -430,6 +535,35 @@ func (r *ModelRegistry) GetModelProviders(modelID string) []string {
 	return result
 }
 
+// HasProvider reports whether at least one registered client uses the given provider identifier
+// Parameters:
+//   - provider: The provider identifier to look up
+//
+// Returns:
+//   - bool: True if a client with that provider is registered
+func (r *ModelRegistry) HasProvider(provider string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, name := range r.clientProviders {
+		if name == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultModelCreated is listed as the creation time of models registered without one, so
+// that listings stay the same across restarts.
+const defaultModelCreated int64 = 1735689600 // 2025-01-01
+
+func createdOf(model *ModelInfo) int64 {
+	if model.Created > 0 {
+		return model.Created
+	}
+	return defaultModelCreated
+}
+
 // convertModelToMap converts ModelInfo to the appropriate format for different handler types
 func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string) map[string]any {
 	if model == nil {
@@ -443,9 +577,7 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 			"object":   "model",
 			"owned_by": model.OwnedBy,
 		}
-		if model.Created > 0 {
-			result["created"] = model.Created
-		}
+		result["created"] = createdOf(model)
 		if model.Type != "" {
 			result["type"] = model.Type
 		}
@@ -458,12 +590,19 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if model.Description != "" {
 			result["description"] = model.Description
 		}
-		if model.ContextLength > 0 {
-			result["context_length"] = model.ContextLength
+		caps := r.capabilitiesFor(model)
+		if caps.ContextLength > 0 {
+			result["context_length"] = caps.ContextLength
 		}
 		if model.MaxCompletionTokens > 0 {
 			result["max_completion_tokens"] = model.MaxCompletionTokens
 		}
+		if caps.MaxOutputTokens > 0 {
+			result["max_output_tokens"] = caps.MaxOutputTokens
+		}
+		result["supports_vision"] = caps.SupportsVision
+		result["supports_tools"] = caps.SupportsTools
+		result["supports_streaming"] = caps.SupportsStreaming
 		if len(model.SupportedParameters) > 0 {
 			result["supported_parameters"] = model.SupportedParameters
 		}
@@ -475,9 +614,7 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 			"object":   "model",
 			"owned_by": model.OwnedBy,
 		}
-		if model.Created > 0 {
-			result["created"] = model.Created
-		}
+		result["created"] = createdOf(model)
 		if model.Type != "" {
 			result["type"] = model.Type
 		}
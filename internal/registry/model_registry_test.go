@@ -0,0 +1,55 @@
+package registry
+
+import "testing"
+
+func TestResolveModelIDPrefersExactMatch(t *testing.T) {
+	r := GetGlobalRegistry()
+	r.RegisterClient("resolve-exact-test", "openai", []*ModelInfo{
+		{ID: "Resolve-Exact-Model", Object: "model", Type: "openai"},
+		{ID: "resolve-exact-model", Object: "model", Type: "openai"},
+	})
+	t.Cleanup(func() { r.UnregisterClient("resolve-exact-test") })
+
+	for _, name := range []string{"Resolve-Exact-Model", "resolve-exact-model"} {
+		if id, ok := r.ResolveModelID(name); !ok || id != name {
+			t.Fatalf("ResolveModelID(%q) = %q, %v, want the exact ID", name, id, ok)
+		}
+	}
+}
+
+func TestResolveModelIDSettlesCollisionsOnTheSortedFirstID(t *testing.T) {
+	r := GetGlobalRegistry()
+	r.RegisterClient("resolve-collision-test", "openai", []*ModelInfo{
+		{ID: "resolve-COLLISION-model", Object: "model", Type: "openai"},
+		{ID: "Resolve-Collision-Model", Object: "model", Type: "openai"},
+		{ID: "resolve-collision-MODEL", Object: "model", Type: "openai"},
+	})
+	t.Cleanup(func() { r.UnregisterClient("resolve-collision-test") })
+
+	for i := 0; i < 50; i++ {
+		id, ok := r.ResolveModelID("  RESOLVE-collision-model ")
+		if !ok || id != "Resolve-Collision-Model" {
+			t.Fatalf("ResolveModelID = %q, %v, want Resolve-Collision-Model on every call", id, ok)
+		}
+	}
+}
+
+func TestResolveModelIDForgetsUnregisteredModels(t *testing.T) {
+	r := GetGlobalRegistry()
+	r.RegisterClient("resolve-unregister-a", "openai", []*ModelInfo{
+		{ID: "Resolve-Unregister-Model", Object: "model", Type: "openai"},
+	})
+	r.RegisterClient("resolve-unregister-b", "openai", []*ModelInfo{
+		{ID: "resolve-unregister-model", Object: "model", Type: "openai"},
+	})
+	t.Cleanup(func() { r.UnregisterClient("resolve-unregister-b") })
+
+	r.UnregisterClient("resolve-unregister-a")
+	if id, ok := r.ResolveModelID("RESOLVE-UNREGISTER-MODEL"); !ok || id != "resolve-unregister-model" {
+		t.Fatalf("ResolveModelID = %q, %v, want the model still registered", id, ok)
+	}
+	r.UnregisterClient("resolve-unregister-b")
+	if id, ok := r.ResolveModelID("RESOLVE-UNREGISTER-MODEL"); ok {
+		t.Fatalf("ResolveModelID = %q after every client left, want no match", id)
+	}
+}
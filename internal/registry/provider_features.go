@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+)
+
+// ProviderFeatures describes the request features a provider supports in this build.
+type ProviderFeatures struct {
+	// Format is the request dialect the provider is sent, e.g. "gemini" or "openai".
+	Format string `json:"format"`
+	// NativeFormats lists other dialects the provider reads without a translator.
+	NativeFormats []string `json:"native_formats,omitempty"`
+	// Streaming reports whether responses can be streamed
+	Streaming bool `json:"streaming"`
+	// Tools reports whether tool or function calls reach the provider
+	Tools bool `json:"tools"`
+	// Vision reports whether image input reaches the provider
+	Vision bool `json:"vision"`
+	// JSONSchema reports whether structured output schemas reach the provider
+	JSONSchema bool `json:"json_schema"`
+	// Embeddings reports whether embedding requests are served
+	Embeddings bool `json:"embeddings"`
+	// CountTokens reports whether token counting requests are answered
+	CountTokens bool `json:"count_tokens"`
+	// Reasoning reports whether reasoning settings and output are passed through
+	Reasoning bool `json:"reasoning"`
+	// Logprobs reports whether token log probabilities can be requested and are returned
+	Logprobs bool `json:"logprobs"`
+	// Prediction reports whether predicted outputs reach the provider
+	Prediction bool `json:"prediction"`
+	// StreamChoices reports whether a streamed response can carry several choices (n above 1)
+	StreamChoices bool `json:"stream_choices"`
+}
+
+// ModelFeatures describes the features of one model under a provider.
+type ModelFeatures struct {
+	ID       string           `json:"id"`
+	Features ProviderFeatures `json:"features"`
+}
+
+// ProviderCapabilities is one provider of the capabilities matrix.
+type ProviderCapabilities struct {
+	Provider string `json:"provider"`
+	// Declared reports whether the provider declared its features; undeclared providers list
+	// none.
+	Declared bool             `json:"declared"`
+	Features ProviderFeatures `json:"features"`
+	// Auths is the number of accounts currently registered for the provider; the management
+	// API replaces it with the number of accounts able to serve requests.
+	Auths  int             `json:"auths"`
+	Models []ModelFeatures `json:"models,omitempty"`
+}
+
+// SetProviderFeatures records the features provider supports, replacing earlier ones
+// Parameters:
+//   - provider: The provider identifier
+//   - features: The features the provider supports
+func (r *ModelRegistry) SetProviderFeatures(provider string, features ProviderFeatures) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == "" {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.providerFeatures == nil {
+		r.providerFeatures = make(map[string]ProviderFeatures)
+	}
+	r.providerFeatures[provider] = features
+}
+
+// GetProviderFeatures returns the features declared for provider
+// Parameters:
+//   - provider: The provider identifier
+//
+// Returns:
+//   - ProviderFeatures: The declared features
+//   - bool: False when the provider declared none
+func (r *ModelRegistry) GetProviderFeatures(provider string) (ProviderFeatures, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	features, ok := r.providerFeatures[strings.ToLower(strings.TrimSpace(provider))]
+	return features, ok
+}
+
+// Capabilities returns the capabilities matrix: every provider that declared features or has
+// registered accounts, with its account count and the features of each model it serves.
+// Model features are the provider's narrowed by the model's capabilities and replaced by the
+// features a model definition carries itself.
+func (r *ModelRegistry) Capabilities() []ProviderCapabilities {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	byProvider := make(map[string]*ProviderCapabilities, len(r.providerFeatures))
+	for provider, features := range r.providerFeatures {
+		byProvider[provider] = &ProviderCapabilities{Provider: provider, Declared: true, Features: features}
+	}
+	for _, provider := range r.clientProviders {
+		entry, ok := byProvider[provider]
+		if !ok {
+			entry = &ProviderCapabilities{Provider: provider}
+			byProvider[provider] = entry
+		}
+		entry.Auths++
+	}
+	for modelID, registration := range r.models {
+		if registration == nil || registration.Info == nil {
+			continue
+		}
+		for provider, count := range registration.Providers {
+			entry, ok := byProvider[provider]
+			if !ok || count <= 0 {
+				continue
+			}
+			entry.Models = append(entry.Models, ModelFeatures{ID: modelID, Features: r.modelFeatures(entry.Features, registration.Info)})
+		}
+	}
+
+	out := make([]ProviderCapabilities, 0, len(byProvider))
+	for _, entry := range byProvider {
+		sort.Slice(entry.Models, func(i, j int) bool { return entry.Models[i].ID < entry.Models[j].ID })
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
+
+// modelFeatures derives the features of model served under a provider with features.
+// Callers must hold the mutex.
+func (r *ModelRegistry) modelFeatures(features ProviderFeatures, model *ModelInfo) ProviderFeatures {
+	if model.Features != nil {
+		own := *model.Features
+		if own.Format == "" {
+			own.Format, own.NativeFormats = features.Format, features.NativeFormats
+		}
+		return own
+	}
+	caps := r.capabilitiesFor(model)
+	features.Streaming = features.Streaming && caps.SupportsStreaming
+	features.Tools = features.Tools && caps.SupportsTools
+	features.Vision = features.Vision && caps.SupportsVision
+	if features.Embeddings {
+		features.Embeddings = false
+		for _, method := range model.SupportedGenerationMethods {
+			if method == "embedContent" {
+				features.Embeddings = true
+				break
+			}
+		}
+	}
+	return features
+}
@@ -0,0 +1,204 @@
+// Package responsestore keeps Responses API results that clients asked the proxy to store,
+// so they can be fetched again by ID and continued with previous_response_id. Records are
+// owned by the client API key that created them and live in one bolt file, opened once and
+// kept open for the life of the process.
+package responsestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DirName is the directory inside the auth dir holding the store.
+	DirName  = ".responses"
+	fileName = "responses.bolt"
+)
+
+// ErrNotFound is returned when no response with the requested ID is stored for the API key.
+var ErrNotFound = errors.New("responsestore: not found")
+
+// stores holds the store of every path in use, so the bolt file, which only one handle may
+// hold open, is opened once however many requests use it.
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+// Record is one stored response.
+type Record struct {
+	ID string `json:"id"`
+	// Input is the request input as a list of items, including the input of the responses
+	// it continued, so a later previous_response_id sees the whole conversation.
+	Input json.RawMessage `json:"input"`
+	// Response is the response object returned to the client.
+	Response json.RawMessage `json:"response"`
+	// Metadata is the metadata the client attached to the request.
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Store is the response store kept in one bolt file.
+type Store struct {
+	path string
+
+	mu sync.Mutex
+	db *bolt.DB
+}
+
+// New returns the store kept under authDir. Stores are shared per path.
+func New(authDir string) *Store {
+	path := filepath.Join(authDir, DirName, fileName)
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if s, ok := stores[path]; ok {
+		return s
+	}
+	s := &Store{path: path}
+	stores[path] = s
+	return s
+}
+
+// Save stores record for apiKey, replacing any record with the same ID. Records of apiKey
+// older than maxAge are removed at the same time; a zero maxAge keeps them forever.
+func (s *Store) Save(apiKey string, record Record, maxAge time.Duration) error {
+	if strings.TrimSpace(record.ID) == "" {
+		return errors.New("responsestore: record has no id")
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		bucket, errBucket := tx.CreateBucketIfNotExists(bucketName(apiKey))
+		if errBucket != nil {
+			return errBucket
+		}
+		if maxAge > 0 {
+			if errPrune := pruneBucket(bucket, time.Now().Add(-maxAge)); errPrune != nil {
+				return errPrune
+			}
+		}
+		return bucket.Put([]byte(record.ID), data)
+	})
+}
+
+// Get returns the record stored for apiKey under id.
+func (s *Store) Get(apiKey, id string) (Record, error) {
+	var record Record
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(apiKey))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		value := bucket.Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(value, &record)
+	})
+	return record, err
+}
+
+// Delete removes the record stored for apiKey under id.
+func (s *Store) Delete(apiKey, id string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName(apiKey))
+		if bucket == nil || bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+func pruneBucket(bucket *bolt.Bucket, cutoff time.Time) error {
+	var expired [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		var record struct {
+			CreatedAt time.Time `json:"created_at"`
+		}
+		if json.Unmarshal(v, &record) == nil && record.CreatedAt.Before(cutoff) {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if errDelete := bucket.Delete(k); errDelete != nil {
+			return errDelete
+		}
+	}
+	return nil
+}
+
+// bucketName keys records by a hash of the owning API key, so raw keys are never persisted.
+func bucketName(apiKey string) []byte {
+	sum := sha256.Sum256([]byte(apiKey))
+	return []byte(hex.EncodeToString(sum[:8]))
+}
+
+// open returns the bolt handle of the store, opening the file the first time. Unless create
+// is set, a store whose file does not exist yet reports ErrNotFound instead of creating it.
+func (s *Store) open(create bool) (*bolt.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil {
+		return s.db, nil
+	}
+	if !create {
+		if _, err := os.Stat(s.path); os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	return db, nil
+}
+
+// Close closes the bolt file; the next operation opens it again.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	return db.View(fn)
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	return db.Update(fn)
+}
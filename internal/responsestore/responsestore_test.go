@@ -0,0 +1,70 @@
+package responsestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoreKeepsOneHandleAcrossOperations(t *testing.T) {
+	dir := t.TempDir()
+	store := New(dir)
+	t.Cleanup(func() { _ = store.Close() })
+	if New(dir) != store {
+		t.Fatal("New returned a second store for the same path")
+	}
+
+	if _, err := store.Get("key", "resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get on an empty store = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DirName, fileName)); !os.IsNotExist(err) {
+		t.Fatal("a read created the store file")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("resp_%d", i)
+			if err := store.Save("key", Record{ID: id, Response: json.RawMessage(`{}`), Input: json.RawMessage(`[]`)}, 0); err != nil {
+				t.Errorf("save %s: %v", id, err)
+				return
+			}
+			if _, err := store.Get("key", id); err != nil {
+				t.Errorf("get %s: %v", id, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, err := store.Get("other-key", "resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("record visible to another API key: %v", err)
+	}
+	if err := store.Delete("key", "resp_1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("key", "resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted record still stored: %v", err)
+	}
+}
+
+func TestSavePrunesExpiredRecords(t *testing.T) {
+	store := New(t.TempDir())
+	t.Cleanup(func() { _ = store.Close() })
+	old := Record{ID: "old", Response: json.RawMessage(`{}`), CreatedAt: time.Now().Add(-48 * time.Hour)}
+	if err := store.Save("key", old, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("key", Record{ID: "new", Response: json.RawMessage(`{}`)}, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("key", "old"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired record kept: %v", err)
+	}
+}
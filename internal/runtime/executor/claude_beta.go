@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// defaultClaudeBetas are the anthropic-beta values sent with every request; OAuth accounts
+// are rejected without oauth-2025-04-20.
+const defaultClaudeBetas = "claude-code-20250219,oauth-2025-04-20,interleaved-thinking-2025-05-14,fine-grained-tool-streaming-2025-05-14"
+
+// claudeURL returns the URL of the Messages API endpoint path, with the beta query unless
+// the config disables it.
+func claudeURL(cfg *config.Config, baseURL, path string) string {
+	if cfg != nil && cfg.ClaudeBeta.DisableQuery {
+		return baseURL + path
+	}
+	return baseURL + path + "?beta=true"
+}
+
+// applyClaudeBetas sets the anthropic-beta header of a request to model and notes it in the
+// request log. Betas an Anthropic client or a raw passthrough request sent are added to the
+// built-in ones, followed by the betas configured for model.
+func applyClaudeBetas(ctx context.Context, cfg *config.Config, r *http.Request, from sdktranslator.Format, model string) {
+	var clientBetas string
+	if from == sdktranslator.FromString("claude") || from == sdktranslator.FormatRaw {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			clientBetas = ginCtx.Request.Header.Get("Anthropic-Beta")
+		}
+	}
+	var modelBetas []string
+	if cfg != nil {
+		modelBetas = cfg.ClaudeBeta.Models[model]
+	}
+	betas := mergeClaudeBetas(clientBetas, modelBetas)
+	if len(betas) == 0 {
+		r.Header.Del("Anthropic-Beta")
+		return
+	}
+	value := strings.Join(betas, ",")
+	r.Header.Set("Anthropic-Beta", value)
+	logging.RecordUpstreamHeaderNote(ctx, "anthropic-beta: "+value)
+}
+
+// mergeClaudeBetas lists the default betas followed by those of clientBetas and extra,
+// dropping blanks and duplicates. Entries may hold comma separated lists.
+func mergeClaudeBetas(clientBetas string, extra []string) []string {
+	var betas []string
+	seen := make(map[string]struct{})
+	for _, entry := range append([]string{defaultClaudeBetas, clientBetas}, extra...) {
+		for _, beta := range strings.Split(entry, ",") {
+			beta = strings.TrimSpace(beta)
+			if beta == "" {
+				continue
+			}
+			if _, dup := seen[beta]; dup {
+				continue
+			}
+			seen[beta] = struct{}{}
+			betas = append(betas, beta)
+		}
+	}
+	return betas
+}
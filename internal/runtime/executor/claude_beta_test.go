@@ -0,0 +1,22 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeClaudeBetasKeepsDefaults(t *testing.T) {
+	defaults := strings.Split(defaultClaudeBetas, ",")
+
+	got := mergeClaudeBetas("", nil)
+	if strings.Join(got, ",") != defaultClaudeBetas {
+		t.Fatalf("betas without client ones = %v", got)
+	}
+
+	// Client betas are added to the defaults, so OAuth accounts keep their beta.
+	got = mergeClaudeBetas("context-1m-2025-08-07, oauth-2025-04-20", []string{"extra-beta,context-1m-2025-08-07"})
+	want := append(append([]string(nil), defaults...), "context-1m-2025-08-07", "extra-beta")
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("merged betas = %v, want %v", got, want)
+	}
+}
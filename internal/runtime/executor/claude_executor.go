@@ -48,23 +48,26 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	// Use streaming translation to preserve function calling, except for claude.
 	stream := from != to
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), stream)
+	body = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "claude", req.Model, body)
+	body = stripUnknownFields(e.cfg, e.Identifier(), from, "claude", body)
+	body = stripUnsupportedParams(e.Features(), from, body)
 
 	if !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
-		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+		body = withClaudeCodeSystem(body, from)
 	}
 
-	url := fmt.Sprintf("%s/v1/messages?beta=true", baseURL)
+	url := claudeURL(e.cfg, baseURL, "/v1/messages")
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
 	applyClaudeHeaders(httpReq, apiKey, false)
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
+	applyClaudeBetas(ctx, e.cfg, httpReq, from, req.Model)
 
-	httpClient := &http.Client{}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
@@ -78,7 +81,7 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+		return cliproxyexecutor.Response{}, upstreamStatusErr(ctx, auth, resp, b)
 	}
 	reader := io.Reader(resp.Body)
 	var decoder *zstd.Decoder
@@ -101,13 +104,18 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 			if detail, ok := parseClaudeStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
 			}
+			reporter.observeModelIdentity(line, claudeModelIdentity)
 		}
 	} else {
 		reporter.publish(ctx, parseClaudeUsage(data))
+		reporter.observeModelIdentity(data, claudeModelIdentity)
 	}
 	var param any
-	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
-	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+	out, err := translateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: out}, nil
 }
 
 func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
@@ -120,20 +128,23 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("claude")
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
-	body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+	body = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "claude", req.Model, body)
+	body = stripUnknownFields(e.cfg, e.Identifier(), from, "claude", body)
+	body = stripUnsupportedParams(e.Features(), from, body)
+	body = withClaudeCodeSystem(body, from)
 
-	url := fmt.Sprintf("%s/v1/messages?beta=true", baseURL)
+	url := claudeURL(e.cfg, baseURL, "/v1/messages")
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 	applyClaudeHeaders(httpReq, apiKey, true)
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
+	applyClaudeBetas(ctx, e.cfg, httpReq, from, req.Model)
 
-	httpClient := &http.Client{Timeout: 0}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -143,7 +154,7 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+		return nil, upstreamStatusErr(ctx, auth, resp, b)
 	}
 	out := make(chan cliproxyexecutor.StreamChunk)
 	go func() {
@@ -159,13 +170,19 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 			if detail, ok := parseClaudeStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
 			}
-			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			reporter.observeModelIdentity(line, claudeModelIdentity)
+			chunks, errTranslate := translateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			if errTranslate != nil {
+				failStream(ctx, reporter, out, errTranslate)
+				return
+			}
 			for i := range chunks {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
 			}
 		}
 		if err = scanner.Err(); err != nil {
-			out <- cliproxyexecutor.StreamChunk{Err: err}
+			recordUpstreamError(ctx, auth, endpointOf(resp), 0, err.Error())
+			failStream(ctx, reporter, out, err)
 		}
 	}()
 	return out, nil
@@ -185,21 +202,21 @@ func (e *ClaudeExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), stream)
 
 	if !strings.HasPrefix(req.Model, "claude-3-5-haiku") {
-		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+		body = withClaudeCodeSystem(body, from)
 	}
 
-	url := fmt.Sprintf("%s/v1/messages/count_tokens?beta=true", baseURL)
+	url := claudeURL(e.cfg, baseURL, "/v1/messages/count_tokens")
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
 	applyClaudeHeaders(httpReq, apiKey, false)
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
+	applyClaudeBetas(ctx, e.cfg, httpReq, from, req.Model)
 
-	httpClient := &http.Client{}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
@@ -212,7 +229,7 @@ func (e *ClaudeExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+		return cliproxyexecutor.Response{}, upstreamStatusErr(ctx, auth, resp, b)
 	}
 	reader := io.Reader(resp.Body)
 	var decoder *zstd.Decoder
@@ -268,6 +285,36 @@ func (e *ClaudeExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (
 	return auth, nil
 }
 
+// withClaudeCodeSystem makes the Claude Code instructions the system prompt of body. The
+// system prompt of a raw passthrough request is kept after them, unless it already starts
+// with them.
+func withClaudeCodeSystem(body []byte, from sdktranslator.Format) []byte {
+	system := gjson.GetBytes(body, "system")
+	if from != sdktranslator.FormatRaw || !system.Exists() {
+		body, _ = sjson.SetRawBytes(body, "system", []byte(misc.ClaudeCodeInstructions))
+		return body
+	}
+	instructions := gjson.Parse(misc.ClaudeCodeInstructions).Array()
+	blocks := make([]string, 0, len(instructions)+1)
+	for _, block := range instructions {
+		blocks = append(blocks, block.Raw)
+	}
+	switch {
+	case system.IsArray():
+		if len(instructions) > 0 && system.Get("0.text").String() == instructions[0].Get("text").String() {
+			return body
+		}
+		for _, block := range system.Array() {
+			blocks = append(blocks, block.Raw)
+		}
+	case system.String() != "":
+		block, _ := sjson.Set(`{"type":"text"}`, "text", system.String())
+		blocks = append(blocks, block)
+	}
+	body, _ = sjson.SetRawBytes(body, "system", []byte("["+strings.Join(blocks, ",")+"]"))
+	return body
+}
+
 func hasZSTDEcoding(contentEncoding string) bool {
 	if contentEncoding == "" {
 		return false
@@ -292,7 +339,6 @@ func applyClaudeHeaders(r *http.Request, apiKey string, stream bool) {
 
 	misc.EnsureHeader(r.Header, ginHeaders, "Anthropic-Version", "2023-06-01")
 	misc.EnsureHeader(r.Header, ginHeaders, "Anthropic-Dangerous-Direct-Browser-Access", "true")
-	misc.EnsureHeader(r.Header, ginHeaders, "Anthropic-Beta", "claude-code-20250219,oauth-2025-04-20,interleaved-thinking-2025-05-14,fine-grained-tool-streaming-2025-05-14")
 	misc.EnsureHeader(r.Header, ginHeaders, "X-App", "cli")
 	misc.EnsureHeader(r.Header, ginHeaders, "X-Stainless-Helper-Method", "stream")
 	misc.EnsureHeader(r.Header, ginHeaders, "X-Stainless-Retry-Count", "0")
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestWithClaudeCodeSystem(t *testing.T) {
+	instructions := gjson.Parse(misc.ClaudeCodeInstructions).Array()
+	first := instructions[0].Get("text").String()
+
+	// Translated requests get the instructions as their whole system prompt.
+	body := withClaudeCodeSystem([]byte(`{"system":"translated"}`), sdktranslator.FromString("openai"))
+	if got := gjson.GetBytes(body, "system").Raw; got != misc.ClaudeCodeInstructions {
+		t.Fatalf("translated system = %s", got)
+	}
+
+	// Passthrough requests keep their own system prompt after the instructions.
+	for _, tt := range []struct {
+		name, body string
+	}{
+		{name: "string", body: `{"system":"be brief"}`},
+		{name: "array", body: `{"system":[{"type":"text","text":"be brief"}]}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			system := gjson.GetBytes(withClaudeCodeSystem([]byte(tt.body), sdktranslator.FormatRaw), "system").Array()
+			if len(system) != len(instructions)+1 {
+				t.Fatalf("system has %d blocks, want %d", len(system), len(instructions)+1)
+			}
+			if system[0].Get("text").String() != first || system[len(system)-1].Get("text").String() != "be brief" {
+				t.Fatalf("system = %v", system)
+			}
+		})
+	}
+
+	// A passthrough prompt already starting with the instructions is left alone.
+	prefixed := []byte(`{"system":[` + instructions[0].Raw + `,{"type":"text","text":"be brief"}]}`)
+	if got := withClaudeCodeSystem(prefixed, sdktranslator.FormatRaw); string(got) != string(prefixed) {
+		t.Fatalf("prefixed body changed to %s", got)
+	}
+
+	// Without a system prompt the instructions are set as usual.
+	body = withClaudeCodeSystem([]byte(`{"messages":[]}`), sdktranslator.FormatRaw)
+	if got := gjson.GetBytes(body, "system").Raw; got != misc.ClaudeCodeInstructions {
+		t.Fatalf("raw system without one = %s", got)
+	}
+}
+
+func TestCodexRawNonStreamReturnsWholeBody(t *testing.T) {
+	const stream = "event: response.created\ndata: {\"type\":\"response.created\"}\n\n" +
+		"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"hi\"}\n\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(stream))
+	}))
+	t.Cleanup(srv.Close)
+
+	auth := &cliproxyauth.Auth{ID: "codex", Provider: "codex", Attributes: map[string]string{"api_key": "key", "base_url": srv.URL}}
+	exec := NewCodexExecutor(&config.Config{})
+	req := cliproxyexecutor.Request{Model: "gpt-5", Payload: []byte(`{"model":"gpt-5","input":"hi"}`)}
+
+	resp, err := exec.Execute(context.Background(), auth, req, cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatRaw})
+	if err != nil {
+		t.Fatalf("raw Execute: %v", err)
+	}
+	if string(resp.Payload) != stream {
+		t.Fatalf("raw payload = %q", resp.Payload)
+	}
+
+	// Translated clients still need response.completed.
+	_, err = exec.Execute(context.Background(), auth, req, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai-response")})
+	if err == nil {
+		t.Fatal("translated Execute without response.completed succeeded")
+	}
+}
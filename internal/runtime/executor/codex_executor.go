@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -81,6 +80,9 @@ func (e *CodexExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 
 	body, _ = sjson.SetBytes(body, "stream", true)
 
+	body = stripUnknownFields(e.cfg, e.Identifier(), from, "codex", body)
+	body = stripUnsupportedParams(e.Features(), from, body)
+
 	url := strings.TrimSuffix(baseURL, "/") + "/responses"
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
@@ -88,11 +90,10 @@ func (e *CodexExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 		return cliproxyexecutor.Response{}, err
 	}
 	applyCodexHeaders(httpReq, auth, apiKey)
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
 
-	httpClient := &http.Client{}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
@@ -102,7 +103,7 @@ func (e *CodexExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+		return cliproxyexecutor.Response{}, upstreamStatusErr(ctx, auth, resp, b)
 	}
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -110,26 +111,38 @@ func (e *CodexExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, re
 	}
 	appendAPIResponseChunk(ctx, e.cfg, data)
 
-	lines := bytes.Split(data, []byte("\n"))
-	for _, line := range lines {
+	var completed []byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
 		if !bytes.HasPrefix(line, dataTag) {
 			continue
 		}
-
 		line = bytes.TrimSpace(line[5:])
-		if gjson.GetBytes(line, "type").String() != "response.completed" {
-			continue
+		if gjson.GetBytes(line, "type").String() == "response.completed" {
+			completed = line
+			break
 		}
-
-		if detail, ok := parseCodexUsage(line); ok {
+	}
+	if completed != nil {
+		if detail, ok := parseCodexUsage(completed); ok {
 			reporter.publish(ctx, detail)
 		}
+		reporter.observeModelIdentity(completed, codexModelIdentity)
+	}
 
-		var param any
-		out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, line, &param)
-		return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+	// Raw passthrough clients get the whole upstream stream as it came, also when it ended
+	// before response.completed.
+	if from == sdktranslator.FormatRaw {
+		return cliproxyexecutor.Response{Payload: data}, nil
+	}
+	if completed == nil {
+		return cliproxyexecutor.Response{}, statusErr{code: 408, msg: "stream error: stream disconnected before completion: stream closed before response.completed"}
+	}
+	var param any
+	out, errTranslate := translateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, completed, &param)
+	if errTranslate != nil {
+		return cliproxyexecutor.Response{}, errTranslate
 	}
-	return cliproxyexecutor.Response{}, statusErr{code: 408, msg: "stream error: stream disconnected before completion: stream closed before response.completed"}
+	return cliproxyexecutor.Response{Payload: out}, nil
 }
 
 func (e *CodexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
@@ -172,6 +185,9 @@ func (e *CodexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 		}
 	}
 
+	body = stripUnknownFields(e.cfg, e.Identifier(), from, "codex", body)
+	body = stripUnsupportedParams(e.Features(), from, body)
+
 	url := strings.TrimSuffix(baseURL, "/") + "/responses"
 	recordAPIRequest(ctx, e.cfg, body)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
@@ -179,11 +195,10 @@ func (e *CodexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 		return nil, err
 	}
 	applyCodexHeaders(httpReq, auth, apiKey)
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
 
-	httpClient := &http.Client{Timeout: 0}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -193,7 +208,7 @@ func (e *CodexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+		return nil, upstreamStatusErr(ctx, auth, resp, b)
 	}
 	out := make(chan cliproxyexecutor.StreamChunk)
 	go func() {
@@ -213,23 +228,29 @@ func (e *CodexExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Au
 					if detail, ok := parseCodexUsage(data); ok {
 						reporter.publish(ctx, detail)
 					}
+					reporter.observeModelIdentity(data, codexModelIdentity)
 				}
 			}
 
-			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			chunks, errTranslate := translateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			if errTranslate != nil {
+				failStream(ctx, reporter, out, errTranslate)
+				return
+			}
 			for i := range chunks {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
 			}
 		}
 		if err = scanner.Err(); err != nil {
-			out <- cliproxyexecutor.StreamChunk{Err: err}
+			recordUpstreamError(ctx, auth, endpointOf(resp), 0, err.Error())
+			failStream(ctx, reporter, out, err)
 		}
 	}()
 	return out, nil
 }
 
 func (e *CodexExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	return cliproxyexecutor.Response{Payload: []byte{}}, fmt.Errorf("not implemented")
+	return cliproxyexecutor.Response{Payload: []byte{}}, statusErr{code: http.StatusNotImplemented, msg: "countTokens is not supported by the codex executor"}
 }
 
 func (e *CodexExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
@@ -0,0 +1,263 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Prompt prefixes making the echo provider fail the way a real upstream can.
+const (
+	echoRateLimitPrefix    = "!429"
+	echoTimeoutPrefix      = "!timeout"
+	echoMalformedSSEPrefix = "!malformed-sse"
+)
+
+// echoBytesPerToken is how many bytes of a request or echo count as one token.
+const echoBytesPerToken = 4
+
+// EchoExecutor is a provider answering with the request it received, as translated to the
+// OpenAI chat format, so a deployment can be exercised end to end without an upstream. Its
+// answers take the same way back through the translators, usage and request logs as those
+// of a real provider.
+type EchoExecutor struct {
+	cfg *config.Config
+}
+
+// NewEchoExecutor creates the executor of the echo provider.
+func NewEchoExecutor(cfg *config.Config) *EchoExecutor { return &EchoExecutor{cfg: cfg} }
+
+// Identifier implements cliproxyauth.ProviderExecutor.
+func (e *EchoExecutor) Identifier() string { return constant.Echo }
+
+// PrepareRequest is a no-op; the echo provider has no credentials.
+func (e *EchoExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error {
+	return nil
+}
+
+func (e *EchoExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	translated := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	translated = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "openai", req.Model, translated)
+	recordAPIRequest(ctx, e.cfg, translated)
+
+	malformed, err := e.simulate(ctx, translated)
+	if err != nil {
+		appendAPIResponseChunk(ctx, e.cfg, []byte(err.Error()))
+		return cliproxyexecutor.Response{}, err
+	}
+	text, finish := echoText(translated)
+	body := echoCompletion(req.Model, text, finish, echoUsage(translated, text))
+	if malformed {
+		body = body[:len(body)/2]
+	}
+	appendAPIResponseChunk(ctx, e.cfg, body)
+	reporter.publish(ctx, parseOpenAIUsage(body))
+	var param any
+	out, err := translateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, body, &param)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: out}, nil
+}
+
+func (e *EchoExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	translated := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	translated = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "openai", req.Model, translated)
+	recordAPIRequest(ctx, e.cfg, translated)
+
+	malformed, err := e.simulate(ctx, translated)
+	if err != nil {
+		appendAPIResponseChunk(ctx, e.cfg, []byte(err.Error()))
+		return nil, err
+	}
+	text, finish := echoText(translated)
+	lines := echoStreamLines(req.Model, text, finish, echoUsage(translated, text), e.chunkSize())
+	if malformed {
+		// Cut the stream inside an event after the first chunk, as a dropped connection would.
+		cut := lines[1]
+		lines = append(lines[:1:1], cut[:len(cut)/2])
+	}
+	delay := time.Duration(0)
+	if e.cfg != nil && e.cfg.Providers.Echo.ChunkDelay > 0 {
+		delay = time.Duration(e.cfg.Providers.Echo.ChunkDelay) * time.Millisecond
+	}
+
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		var param any
+		for i, line := range lines {
+			if i > 0 && delay > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(delay):
+				}
+			}
+			appendAPIResponseChunk(ctx, e.cfg, line)
+			if detail, ok := parseOpenAIStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			chunks, errTranslate := translateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, bytes.Clone(line), &param)
+			if errTranslate != nil {
+				failStream(ctx, reporter, out, errTranslate)
+				return
+			}
+			for j := range chunks {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[j])}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e *EchoExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: []byte{}}, statusErr{code: http.StatusNotImplemented, msg: "countTokens is not supported by the echo executor"}
+}
+
+// Refresh is a no-op; the echo provider has no credentials.
+func (e *EchoExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *EchoExecutor) chunkSize() int {
+	if e.cfg != nil && e.cfg.Providers.Echo.ChunkSize > 0 {
+		return e.cfg.Providers.Echo.ChunkSize
+	}
+	return 32
+}
+
+// simulate acts on the magic prefix of the last user message: it fails with 429, hangs
+// until the configured timeout and fails with 504, or reports that the answer is to be
+// malformed.
+func (e *EchoExecutor) simulate(ctx context.Context, translated []byte) (malformed bool, err error) {
+	prompt := echoPrompt(translated)
+	switch {
+	case strings.HasPrefix(prompt, echoRateLimitPrefix):
+		return false, statusErr{code: http.StatusTooManyRequests, msg: "echo: simulated rate limit", retryAfter: time.Second}
+	case strings.HasPrefix(prompt, echoTimeoutPrefix):
+		timeout := 30 * time.Second
+		if e.cfg != nil && e.cfg.Providers.Echo.Timeout > 0 {
+			timeout = time.Duration(e.cfg.Providers.Echo.Timeout) * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(timeout):
+			return false, statusErr{code: http.StatusGatewayTimeout, msg: "echo: simulated upstream timeout"}
+		}
+	case strings.HasPrefix(prompt, echoMalformedSSEPrefix):
+		return true, nil
+	}
+	return false, nil
+}
+
+// echoPrompt returns the text of the last user message of an OpenAI chat request.
+func echoPrompt(translated []byte) string {
+	messages := gjson.GetBytes(translated, "messages").Array()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Get("role").String() != "user" {
+			continue
+		}
+		content := messages[i].Get("content")
+		if content.Type == gjson.String {
+			return strings.TrimSpace(content.String())
+		}
+		for _, part := range content.Array() {
+			if part.Get("type").String() == "text" {
+				return strings.TrimSpace(part.Get("text").String())
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// echoText returns the echo of an OpenAI chat request, which is the request itself, cut to
+// its max_tokens, and the finish reason that goes with it.
+func echoText(translated []byte) (string, string) {
+	text := string(translated)
+	limit := gjson.GetBytes(translated, "max_completion_tokens").Int()
+	if limit <= 0 {
+		limit = gjson.GetBytes(translated, "max_tokens").Int()
+	}
+	if limit <= 0 || int64(len(text)) <= limit*echoBytesPerToken {
+		return text, "stop"
+	}
+	cut := int(limit * echoBytesPerToken)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut], "length"
+}
+
+// echoUsage returns the usage object of an echo, counting four bytes as a token.
+func echoUsage(translated []byte, text string) string {
+	prompt := (len(translated) + echoBytesPerToken - 1) / echoBytesPerToken
+	completion := (len(text) + echoBytesPerToken - 1) / echoBytesPerToken
+	return fmt.Sprintf(`{"prompt_tokens":%d,"completion_tokens":%d,"total_tokens":%d}`, prompt, completion, prompt+completion)
+}
+
+// echoCompletion builds an OpenAI chat completion answering with text.
+func echoCompletion(model, text, finish, usage string) []byte {
+	body := []byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant"}}]}`)
+	body, _ = sjson.SetBytes(body, "id", fmt.Sprintf("chatcmpl-echo-%d", time.Now().UnixNano()))
+	body, _ = sjson.SetBytes(body, "created", time.Now().Unix())
+	body, _ = sjson.SetBytes(body, "model", model)
+	body, _ = sjson.SetBytes(body, "choices.0.message.content", text)
+	body, _ = sjson.SetBytes(body, "choices.0.finish_reason", finish)
+	body, _ = sjson.SetRawBytes(body, "usage", []byte(usage))
+	return body
+}
+
+// echoStreamLines builds the event stream of an OpenAI chat completion answering with text
+// in pieces of size bytes.
+func echoStreamLines(model, text, finish, usage string, size int) [][]byte {
+	id := fmt.Sprintf("chatcmpl-echo-%d", time.Now().UnixNano())
+	created := time.Now().Unix()
+	chunk := func(delta string, finishReason string) []byte {
+		event := []byte(`{"object":"chat.completion.chunk","choices":[{"index":0}]}`)
+		event, _ = sjson.SetBytes(event, "id", id)
+		event, _ = sjson.SetBytes(event, "created", created)
+		event, _ = sjson.SetBytes(event, "model", model)
+		event, _ = sjson.SetRawBytes(event, "choices.0.delta", []byte(delta))
+		if finishReason != "" {
+			event, _ = sjson.SetBytes(event, "choices.0.finish_reason", finishReason)
+			event, _ = sjson.SetRawBytes(event, "usage", []byte(usage))
+		} else {
+			event, _ = sjson.SetRawBytes(event, "choices.0.finish_reason", []byte("null"))
+		}
+		return append([]byte("data: "), event...)
+	}
+
+	lines := [][]byte{chunk(`{"role":"assistant","content":""}`, "")}
+	for len(text) > 0 {
+		n := min(size, len(text))
+		for n < len(text) && !utf8.RuneStart(text[n]) {
+			n++
+		}
+		delta, _ := sjson.Set(`{}`, "content", text[:n])
+		lines = append(lines, chunk(delta, ""))
+		text = text[n:]
+	}
+	lines = append(lines, chunk(`{}`, finish), []byte("data: [DONE]"))
+	return lines
+}
@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// init declares the features of the built-in providers, so the capabilities matrix lists
+// them before any of their accounts is loaded.
+func init() {
+	reg := registry.GetGlobalRegistry()
+	for _, declarer := range []interface {
+		Identifier() string
+		Features() registry.ProviderFeatures
+	}{
+		&GeminiExecutor{},
+		&GeminiCLIExecutor{},
+		&GeminiWebExecutor{},
+		&ClaudeExecutor{},
+		&CodexExecutor{},
+		&QwenExecutor{},
+	} {
+		reg.SetProviderFeatures(declarer.Identifier(), declarer.Features())
+	}
+}
+
+// Features reports what the Generative Language API supports through this proxy.
+func (e *GeminiExecutor) Features() registry.ProviderFeatures {
+	return registry.ProviderFeatures{
+		Format:      constant.Gemini,
+		Streaming:   true,
+		Tools:       true,
+		Vision:      true,
+		JSONSchema:  true,
+		Embeddings:  true,
+		CountTokens: true,
+		Reasoning:   true,
+	}
+}
+
+// Features reports what Gemini CLI accounts support through this proxy.
+func (e *GeminiCLIExecutor) Features() registry.ProviderFeatures {
+	return registry.ProviderFeatures{
+		Format:      constant.GeminiCLI,
+		Streaming:   true,
+		Tools:       true,
+		Vision:      true,
+		JSONSchema:  true,
+		CountTokens: true,
+		Reasoning:   true,
+	}
+}
+
+// Features reports what Gemini Web accounts support. Tools never reach the web app and
+// token counts are estimated locally; Gemini requests are read without translation.
+func (e *GeminiWebExecutor) Features() registry.ProviderFeatures {
+	return registry.ProviderFeatures{
+		Format:        constant.GeminiWeb,
+		NativeFormats: []string{constant.Gemini},
+		Streaming:     true,
+		Vision:        true,
+		CountTokens:   true,
+	}
+}
+
+// Features reports what Claude supports through this proxy.
+func (e *ClaudeExecutor) Features() registry.ProviderFeatures {
+	return registry.ProviderFeatures{
+		Format:      constant.Claude,
+		Streaming:   true,
+		Tools:       true,
+		Vision:      true,
+		CountTokens: true,
+		Reasoning:   true,
+	}
+}
+
+// Features reports what Codex supports through this proxy.
+func (e *CodexExecutor) Features() registry.ProviderFeatures {
+	return registry.ProviderFeatures{
+		Format:     constant.Codex,
+		Streaming:  true,
+		Tools:      true,
+		Vision:     true,
+		JSONSchema: true,
+		Reasoning:  true,
+	}
+}
+
+// Features reports what Qwen supports through this proxy.
+func (e *QwenExecutor) Features() registry.ProviderFeatures {
+	return registry.ProviderFeatures{
+		Format:    constant.OpenAI,
+		Streaming: true,
+		Tools:     true,
+	}
+}
+
+// Features reports what OpenAI compatible providers support. Requests are forwarded as
+// they are, so every feature the upstream has is available except token counting.
+func (e *OpenAICompatExecutor) Features() registry.ProviderFeatures {
+	return registry.ProviderFeatures{
+		Format:        constant.OpenAI,
+		Streaming:     true,
+		Tools:         true,
+		Vision:        true,
+		JSONSchema:    true,
+		Reasoning:     true,
+		Logprobs:      true,
+		Prediction:    true,
+		StreamChoices: true,
+	}
+}
+
+// stripUnsupportedParams removes the request options of the features a provider does not
+// declare: logprobs and top_logprobs without Logprobs, prediction without Prediction. Bodies
+// of requests passed through raw, with source format from, go out byte-for-byte.
+func stripUnsupportedParams(features registry.ProviderFeatures, from sdktranslator.Format, body []byte) []byte {
+	if from == sdktranslator.FormatRaw {
+		return body
+	}
+	if !features.Logprobs {
+		body, _ = sjson.DeleteBytes(body, "logprobs")
+		body, _ = sjson.DeleteBytes(body, "top_logprobs")
+	}
+	if !features.Prediction {
+		body, _ = sjson.DeleteBytes(body, "prediction")
+	}
+	return body
+}
+
+// omitUnsupportedLogprobs removes the logprobs of every choice of an OpenAI chat response,
+// or of one "data:" line of its stream, from a provider that does not declare Logprobs.
+func omitUnsupportedLogprobs(features registry.ProviderFeatures, data []byte) []byte {
+	if features.Logprobs {
+		return data
+	}
+	payload := jsonPayload(data)
+	if payload == nil {
+		return data
+	}
+	stripped := payload
+	gjson.GetBytes(payload, "choices").ForEach(func(key, choice gjson.Result) bool {
+		if choice.Get("logprobs").Exists() {
+			stripped, _ = sjson.DeleteBytes(stripped, "choices."+strconv.Itoa(int(key.Int()))+".logprobs")
+		}
+		return true
+	})
+	if len(stripped) == len(payload) {
+		return data
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("data:")) {
+		return append([]byte("data: "), stripped...)
+	}
+	return stripped
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestStripUnsupportedParamsFollowsFeatures(t *testing.T) {
+	body := []byte(`{"model":"m","logprobs":true,"top_logprobs":3,"prediction":{"type":"content","content":"x"}}`)
+	openai := sdktranslator.FromString("openai")
+
+	for _, declarer := range []interface {
+		Identifier() string
+		Features() registry.ProviderFeatures
+	}{&ClaudeExecutor{}, &CodexExecutor{}, &GeminiExecutor{}, &GeminiCLIExecutor{}, &QwenExecutor{}} {
+		stripped := stripUnsupportedParams(declarer.Features(), openai, body)
+		for _, field := range []string{"logprobs", "top_logprobs", "prediction"} {
+			if gjson.GetBytes(stripped, field).Exists() {
+				t.Errorf("%s: %s kept: %s", declarer.Identifier(), field, stripped)
+			}
+		}
+	}
+
+	compat := (&OpenAICompatExecutor{}).Features()
+	if kept := stripUnsupportedParams(compat, openai, body); string(kept) != string(body) {
+		t.Errorf("openai compatibility body changed: %s", kept)
+	}
+	qwen := (&QwenExecutor{}).Features()
+	if raw := stripUnsupportedParams(qwen, sdktranslator.FormatRaw, body); string(raw) != string(body) {
+		t.Errorf("raw body changed: %s", raw)
+	}
+}
+
+func TestOmitUnsupportedLogprobs(t *testing.T) {
+	qwen := (&QwenExecutor{}).Features()
+	body := []byte(`{"choices":[{"index":0,"message":{"content":"hi"},"logprobs":null},{"index":1,"message":{"content":"yo"},"logprobs":{"content":[]}}]}`)
+	out := omitUnsupportedLogprobs(qwen, body)
+	if strings.Contains(string(out), "logprobs") || gjson.GetBytes(out, "choices.1.message.content").String() != "yo" {
+		t.Errorf("body = %s", out)
+	}
+
+	line := []byte(`data: {"choices":[{"index":0,"delta":{"content":"hi"},"logprobs":null}]}`)
+	out = omitUnsupportedLogprobs(qwen, line)
+	if string(out) != `data: {"choices":[{"index":0,"delta":{"content":"hi"}}]}` {
+		t.Errorf("stream line = %s", out)
+	}
+	if done := omitUnsupportedLogprobs(qwen, []byte("data: [DONE]")); string(done) != "data: [DONE]" {
+		t.Errorf("done line = %s", done)
+	}
+
+	compat := (&OpenAICompatExecutor{}).Features()
+	if kept := omitUnsupportedLogprobs(compat, body); string(kept) != string(body) {
+		t.Errorf("openai compatibility body changed: %s", kept)
+	}
+}
@@ -0,0 +1,25 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// systemFingerprint derives the OpenAI style system_fingerprint of a backend from its
+// provider, upstream model, the model_version recorded on the auth and the proxy version.
+// It stays the same for identical inputs, so a change signals that the serving setup moved.
+func systemFingerprint(provider, model string, auth *cliproxyauth.Auth) string {
+	version := ""
+	if auth != nil {
+		version = stringFromMetadata(auth.Metadata, "model_version")
+		if version == "" && auth.Attributes != nil {
+			version = strings.TrimSpace(auth.Attributes["model_version"])
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{provider, model, version, misc.ProxyVersion}, "\x00")))
+	return "fp_" + hex.EncodeToString(sum[:6])
+}
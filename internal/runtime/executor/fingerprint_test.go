@@ -0,0 +1,30 @@
+package executor
+
+import (
+	"testing"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestSystemFingerprintFollowsTheModelVersion(t *testing.T) {
+	auth := &cliproxyauth.Auth{Metadata: map[string]any{"model_version": "2025-01"}}
+	first := systemFingerprint("codex", "gpt-5", auth)
+	if again := systemFingerprint("codex", "gpt-5", &cliproxyauth.Auth{Metadata: map[string]any{"model_version": "2025-01"}}); again != first {
+		t.Fatalf("fingerprint changed from %s to %s for the same backend", first, again)
+	}
+	if len(first) != len("fp_")+12 || first[:3] != "fp_" {
+		t.Fatalf("fingerprint = %q, want fp_ and 12 hex digits", first)
+	}
+
+	auth.Metadata["model_version"] = "2025-06"
+	if moved := systemFingerprint("codex", "gpt-5", auth); moved == first {
+		t.Fatal("fingerprint unchanged after the model version changed")
+	}
+	if other := systemFingerprint("openai-compat", "gpt-5", &cliproxyauth.Auth{Metadata: map[string]any{"model_version": "2025-01"}}); other == first {
+		t.Fatal("fingerprint shared by another provider")
+	}
+	attributed := systemFingerprint("codex", "gpt-5", &cliproxyauth.Auth{Attributes: map[string]string{"model_version": "2025-01"}})
+	if attributed != first {
+		t.Fatal("model_version attribute not used like the metadata field")
+	}
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// unforwardableHeaders describe the client connection, so they never reach an upstream even
+// when listed in forward-headers. Credential headers, those the request log redacts, are
+// refused too.
+var unforwardableHeaders = map[string]struct{}{
+	"Host":              {},
+	"Content-Length":    {},
+	"Content-Type":      {},
+	"Connection":        {},
+	"Transfer-Encoding": {},
+	"Accept-Encoding":   {},
+}
+
+// forwardClientHeaders copies the client request headers named in forward-headers onto the
+// upstream request r, replacing any value the executor set. Headers the client did not send
+// are left alone.
+func forwardClientHeaders(ctx context.Context, cfg *config.Config, r *http.Request) {
+	if cfg == nil || len(cfg.ForwardHeaders) == 0 || r == nil {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return
+	}
+	for _, name := range cfg.ForwardHeaders {
+		key := http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if key == "" {
+			continue
+		}
+		if _, blocked := unforwardableHeaders[key]; blocked || logging.IsSensitiveHeader(key) {
+			continue
+		}
+		if values := ginCtx.Request.Header.Values(key); len(values) > 0 {
+			r.Header[key] = append([]string(nil), values...)
+		}
+	}
+}
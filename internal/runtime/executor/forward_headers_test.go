@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestForwardClientHeadersSkipsCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	sent := map[string]string{
+		"OpenAI-Organization": "org-1",
+		"Authorization":       "Bearer client",
+		"X-Api-Key":           "client-key",
+		"X-Management-Key":    "admin",
+		"X-Provider-Key":      "sk-upstream",
+		"Cookie":              "session=1",
+		"Host":                "proxy.local",
+	}
+	cfg := &config.Config{}
+	for name, value := range sent {
+		c.Request.Header.Set(name, value)
+		cfg.ForwardHeaders = append(cfg.ForwardHeaders, name)
+	}
+
+	upstream := httptest.NewRequest(http.MethodPost, "https://upstream.example.com", nil)
+	upstream.Header.Set("Authorization", "Bearer upstream")
+	forwardClientHeaders(context.WithValue(context.Background(), "gin", c), cfg, upstream)
+
+	if got := upstream.Header.Get("OpenAI-Organization"); got != "org-1" {
+		t.Errorf("OpenAI-Organization = %q, want forwarded", got)
+	}
+	if got := upstream.Header.Get("Authorization"); got != "Bearer upstream" {
+		t.Errorf("Authorization = %q, want the upstream credential", got)
+	}
+	for _, name := range []string{"X-Api-Key", "X-Management-Key", "X-Provider-Key", "Cookie", "Host"} {
+		if got := upstream.Header.Get(name); got != "" {
+			t.Errorf("%s forwarded as %q", name, got)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
@@ -55,6 +56,10 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
+	endpoint, err := codeAssistEndpointFor(auth)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 
 	from := opts.SourceFormat
@@ -67,6 +72,12 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 			action = "countTokens"
 		}
 	}
+	if action == "generateContent" {
+		basePayload = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "gemini-cli", req.Model, basePayload)
+		basePayload = stripUnknownFields(e.cfg, e.Identifier(), from, "gemini-cli", basePayload)
+		basePayload = stripUnsupportedParams(e.Features(), from, basePayload)
+		basePayload = applySafetySettings(ctx, e.cfg, "gemini-cli", basePayload)
+	}
 
 	projectID := strings.TrimSpace(stringValue(auth.Metadata, "project_id"))
 	models := cliPreviewFallbackOrder(req.Model)
@@ -74,13 +85,14 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 		models = append([]string{req.Model}, models...)
 	}
 
-	httpClient := newHTTPClient(ctx, 0)
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	respCtx := context.WithValue(ctx, "alt", opts.Alt)
 
 	var lastStatus int
 	var lastBody []byte
 
 	for _, attemptModel := range models {
+		reporter.servedModel = attemptModel
 		payload := append([]byte(nil), basePayload...)
 		if action == "countTokens" {
 			payload = deleteJSONField(payload, "project")
@@ -96,7 +108,7 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 		}
 		updateGeminiCLITokenMetadata(auth, baseTokenData, tok)
 
-		url := fmt.Sprintf("%s/%s:%s", codeAssistEndpoint, codeAssistVersion, action)
+		url := fmt.Sprintf("%s/%s:%s", endpoint, codeAssistVersion, action)
 		if opts.Alt != "" && action != "countTokens" {
 			url = url + fmt.Sprintf("?$alt=%s", opts.Alt)
 		}
@@ -109,23 +121,44 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 		reqHTTP.Header.Set("Content-Type", "application/json")
 		reqHTTP.Header.Set("Authorization", "Bearer "+tok.AccessToken)
 		applyGeminiCLIHeaders(reqHTTP)
+		applyUserAgent(e.cfg, reqHTTP, e.Identifier())
+		forwardClientHeaders(ctx, e.cfg, reqHTTP)
 		reqHTTP.Header.Set("Accept", "application/json")
 
 		resp, errDo := httpClient.Do(reqHTTP)
 		if errDo != nil {
 			return cliproxyexecutor.Response{}, errDo
 		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 && action != "countTokens" && canPassthroughBody(e.cfg, opts, to.String()) {
+			return cliproxyexecutor.Response{Body: newPassthroughBody(ctx, resp.Body, reporter)}, nil
+		}
 		data, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
 		appendAPIResponseChunk(ctx, e.cfg, data)
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if name, ok := geminiCLIOperationName(data); ok && action == "generateContent" {
+				var errOp error
+				if gjson.GetBytes(data, "done").Bool() {
+					data, errOp = geminiCLIOperationResult(name, data)
+				} else {
+					data, errOp = e.awaitOperation(ctx, auth, httpClient, endpoint, name, tokenSource, nil)
+				}
+				if errOp != nil {
+					return cliproxyexecutor.Response{}, errOp
+				}
+			}
 			reporter.publish(ctx, parseGeminiCLIUsage(data))
+			reporter.observeModelIdentity(data, geminiModelIdentity)
 			var param any
-			out := sdktranslator.TranslateNonStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), payload, data, &param)
-			return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+			out, errTranslate := translateNonStream(respCtx, to, from, attemptModel, bytes.Clone(opts.OriginalRequest), payload, data, &param)
+			if errTranslate != nil {
+				return cliproxyexecutor.Response{}, errTranslate
+			}
+			return cliproxyexecutor.Response{Payload: out}, nil
 		}
 		lastStatus = resp.StatusCode
 		lastBody = data
+		recordUpstreamError(ctx, auth, endpointOf(resp), resp.StatusCode, string(data))
 		if resp.StatusCode != 429 {
 			break
 		}
@@ -142,11 +175,19 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 	if err != nil {
 		return nil, err
 	}
+	endpoint, err := codeAssistEndpointFor(auth)
+	if err != nil {
+		return nil, err
+	}
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini-cli")
 	basePayload := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	basePayload = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "gemini-cli", req.Model, basePayload)
+	basePayload = stripUnknownFields(e.cfg, e.Identifier(), from, "gemini-cli", basePayload)
+	basePayload = stripUnsupportedParams(e.Features(), from, basePayload)
+	basePayload = applySafetySettings(ctx, e.cfg, "gemini-cli", basePayload)
 
 	projectID := strings.TrimSpace(stringValue(auth.Metadata, "project_id"))
 
@@ -155,13 +196,14 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 		models = append([]string{req.Model}, models...)
 	}
 
-	httpClient := newHTTPClient(ctx, 0)
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	respCtx := context.WithValue(ctx, "alt", opts.Alt)
 
 	var lastStatus int
 	var lastBody []byte
 
 	for _, attemptModel := range models {
+		reporter.servedModel = attemptModel
 		payload := append([]byte(nil), basePayload...)
 		payload = setJSONField(payload, "project", projectID)
 		payload = setJSONField(payload, "model", attemptModel)
@@ -172,7 +214,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 		}
 		updateGeminiCLITokenMetadata(auth, baseTokenData, tok)
 
-		url := fmt.Sprintf("%s/%s:%s", codeAssistEndpoint, codeAssistVersion, "streamGenerateContent")
+		url := fmt.Sprintf("%s/%s:%s", endpoint, codeAssistVersion, "streamGenerateContent")
 		if opts.Alt == "" {
 			url = url + "?alt=sse"
 		} else {
@@ -187,6 +229,8 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 		reqHTTP.Header.Set("Content-Type", "application/json")
 		reqHTTP.Header.Set("Authorization", "Bearer "+tok.AccessToken)
 		applyGeminiCLIHeaders(reqHTTP)
+		applyUserAgent(e.cfg, reqHTTP, e.Identifier())
+		forwardClientHeaders(ctx, e.cfg, reqHTTP)
 		reqHTTP.Header.Set("Accept", "text/event-stream")
 
 		resp, errDo := httpClient.Do(reqHTTP)
@@ -199,6 +243,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 			appendAPIResponseChunk(ctx, e.cfg, data)
 			lastStatus = resp.StatusCode
 			lastBody = data
+			recordUpstreamError(ctx, auth, endpointOf(resp), resp.StatusCode, string(data))
 			log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
 			if resp.StatusCode == 429 {
 				continue
@@ -206,10 +251,68 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 			return nil, statusErr{code: resp.StatusCode, msg: string(data)}
 		}
 
+		// Large requests may be answered with a long-running operation instead of a stream.
+		var operation string
+		var operationBody []byte
+		if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+			// Operations are small; anything longer than the peek is a body to stream.
+			head, _ := io.ReadAll(io.LimitReader(resp.Body, maxOperationPeek+1))
+			if len(head) <= maxOperationPeek {
+				if name, ok := geminiCLIOperationName(head); ok {
+					appendAPIResponseChunk(ctx, e.cfg, head)
+					operation, operationBody = name, head
+				}
+			}
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+		}
+
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(resp *http.Response, reqBody []byte, attempt string) {
 			defer close(out)
 			defer func() { _ = resp.Body.Close() }()
+			if operation != "" {
+				var heartbeat func()
+				if opts.Alt == "" {
+					// The handler turns heartbeats into SSE comments on the client stream.
+					heartbeat = func() {
+						select {
+						case out <- cliproxyexecutor.StreamChunk{Heartbeat: true}:
+						case <-ctx.Done():
+						}
+					}
+				}
+				var data []byte
+				var errOp error
+				if gjson.GetBytes(operationBody, "done").Bool() {
+					data, errOp = geminiCLIOperationResult(operation, operationBody)
+				} else {
+					data, errOp = e.awaitOperation(ctx, auth, httpClient, endpoint, operation, tokenSource, heartbeat)
+				}
+				if errOp != nil {
+					failStream(ctx, reporter, out, errOp)
+					return
+				}
+				reporter.publish(ctx, parseGeminiCLIUsage(data))
+				reporter.observeModelIdentity(data, geminiModelIdentity)
+				if opts.Alt == "" {
+					data = append([]byte("data: "), data...)
+				}
+				var param any
+				for _, chunk := range [][]byte{data, []byte("[DONE]")} {
+					segments, errTranslate := translateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, chunk, &param)
+					if errTranslate != nil {
+						failStream(ctx, reporter, out, errTranslate)
+						return
+					}
+					for i := range segments {
+						out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
+					}
+				}
+				return
+			}
 			if opts.Alt == "" {
 				scanner := bufio.NewScanner(resp.Body)
 				buf := make([]byte, 1024*1024)
@@ -221,38 +324,58 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 					if detail, ok := parseGeminiCLIStreamUsage(line); ok {
 						reporter.publish(ctx, detail)
 					}
-					if bytes.HasPrefix(line, dataTag) {
-						segments := sdktranslator.TranslateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone(line), &param)
+					reporter.observeModelIdentity(line, geminiModelIdentity)
+					if bytes.HasPrefix(line, dataTag) || from == sdktranslator.FormatRaw {
+						segments, errTranslate := translateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone(line), &param)
+						if errTranslate != nil {
+							failStream(ctx, reporter, out, errTranslate)
+							return
+						}
 						for i := range segments {
 							out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 						}
 					}
 				}
 
-				segments := sdktranslator.TranslateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone([]byte("[DONE]")), &param)
+				segments, errTranslate := translateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone([]byte("[DONE]")), &param)
+				if errTranslate != nil {
+					failStream(ctx, reporter, out, errTranslate)
+					return
+				}
 				for i := range segments {
 					out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 				}
 				if errScan := scanner.Err(); errScan != nil {
-					out <- cliproxyexecutor.StreamChunk{Err: errScan}
+					recordUpstreamError(ctx, auth, endpointOf(resp), 0, errScan.Error())
+					failStream(ctx, reporter, out, errScan)
 				}
 				return
 			}
 
 			data, errRead := io.ReadAll(resp.Body)
 			if errRead != nil {
-				out <- cliproxyexecutor.StreamChunk{Err: errRead}
+				recordUpstreamError(ctx, auth, endpointOf(resp), 0, errRead.Error())
+				failStream(ctx, reporter, out, errRead)
 				return
 			}
 			appendAPIResponseChunk(ctx, e.cfg, data)
 			reporter.publish(ctx, parseGeminiCLIUsage(data))
+			reporter.observeModelIdentity(data, geminiModelIdentity)
 			var param any
-			segments := sdktranslator.TranslateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, data, &param)
+			segments, errTranslate := translateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, data, &param)
+			if errTranslate != nil {
+				failStream(ctx, reporter, out, errTranslate)
+				return
+			}
 			for i := range segments {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 			}
 
-			segments = sdktranslator.TranslateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone([]byte("[DONE]")), &param)
+			segments, errTranslate = translateStream(respCtx, to, from, attempt, bytes.Clone(opts.OriginalRequest), reqBody, bytes.Clone([]byte("[DONE]")), &param)
+			if errTranslate != nil {
+				failStream(ctx, reporter, out, errTranslate)
+				return
+			}
 			for i := range segments {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(segments[i])}
 			}
@@ -272,6 +395,10 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
+	endpoint, err := codeAssistEndpointFor(auth)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini-cli")
@@ -281,7 +408,7 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 		models = append([]string{req.Model}, models...)
 	}
 
-	httpClient := newHTTPClient(ctx, 0)
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	respCtx := context.WithValue(ctx, "alt", opts.Alt)
 
 	var lastStatus int
@@ -298,7 +425,7 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 		}
 		updateGeminiCLITokenMetadata(auth, baseTokenData, tok)
 
-		url := fmt.Sprintf("%s/%s:%s", codeAssistEndpoint, codeAssistVersion, "countTokens")
+		url := fmt.Sprintf("%s/%s:%s", endpoint, codeAssistVersion, "countTokens")
 		if opts.Alt != "" {
 			url = url + fmt.Sprintf("?$alt=%s", opts.Alt)
 		}
@@ -311,6 +438,8 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 		reqHTTP.Header.Set("Content-Type", "application/json")
 		reqHTTP.Header.Set("Authorization", "Bearer "+tok.AccessToken)
 		applyGeminiCLIHeaders(reqHTTP)
+		applyUserAgent(e.cfg, reqHTTP, e.Identifier())
+		forwardClientHeaders(ctx, e.cfg, reqHTTP)
 		reqHTTP.Header.Set("Accept", "application/json")
 
 		resp, errDo := httpClient.Do(reqHTTP)
@@ -327,6 +456,7 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 		}
 		lastStatus = resp.StatusCode
 		lastBody = data
+		recordUpstreamError(ctx, auth, endpointOf(resp), resp.StatusCode, string(data))
 		if resp.StatusCode == 429 {
 			continue
 		}
@@ -438,13 +568,17 @@ func updateGeminiCLITokenMetadata(auth *cliproxyauth.Auth, base map[string]any,
 	auth.Metadata["token"] = merged
 }
 
-func newHTTPClient(ctx context.Context, timeout time.Duration) *http.Client {
+// newHTTPClient returns a client for requests to provider. It uses the round tripper the
+// auth manager put on ctx for the auth's proxy, or else the provider's shared transport.
+func newHTTPClient(ctx context.Context, provider string, timeout time.Duration) *http.Client {
 	client := &http.Client{}
 	if timeout > 0 {
 		client.Timeout = timeout
 	}
 	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
 		client.Transport = rt
+	} else if rt = util.UpstreamTransport(provider, ""); rt != nil {
+		client.Transport = rt
 	}
 	return client
 }
@@ -460,6 +594,25 @@ func cloneMap(in map[string]any) map[string]any {
 	return out
 }
 
+// codeAssistEndpointFor returns the Code Assist endpoint for auth. An "endpoint" entry in the
+// auth file metadata overrides the default, e.g. to reach a regional endpoint. Loaders disable
+// auths with a bad override, so an error here means the metadata changed since; it is a
+// configuration problem, not a credential one.
+func codeAssistEndpointFor(auth *cliproxyauth.Auth) (string, error) {
+	var raw string
+	if auth != nil {
+		raw = strings.TrimSpace(stringValue(auth.Metadata, cliproxyauth.MetadataEndpointKey))
+	}
+	if raw == "" {
+		return codeAssistEndpoint, nil
+	}
+	endpoint, err := cliproxyauth.ValidateEndpoint(raw)
+	if err != nil {
+		return "", statusErr{code: http.StatusInternalServerError, msg: "gemini-cli " + err.Error()}
+	}
+	return endpoint, nil
+}
+
 func stringValue(m map[string]any, key string) string {
 	if m == nil {
 		return ""
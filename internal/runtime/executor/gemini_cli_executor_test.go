@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestCodeAssistEndpointFor(t *testing.T) {
+	endpoint, err := codeAssistEndpointFor(&cliproxyauth.Auth{})
+	if err != nil || endpoint != codeAssistEndpoint {
+		t.Fatalf("default endpoint = %q, %v", endpoint, err)
+	}
+
+	auth := &cliproxyauth.Auth{Metadata: map[string]any{"endpoint": "https://regional.example.com/"}}
+	if endpoint, err = codeAssistEndpointFor(auth); err != nil || endpoint != "https://regional.example.com" {
+		t.Fatalf("override endpoint = %q, %v", endpoint, err)
+	}
+
+	// A plain http override would send the OAuth token in clear text.
+	auth = &cliproxyauth.Auth{Metadata: map[string]any{"endpoint": "http://regional.example.com"}}
+	_, err = codeAssistEndpointFor(auth)
+	var status statusErr
+	if !errors.As(err, &status) {
+		t.Fatalf("expected statusErr, got %v", err)
+	}
+	if status.code == http.StatusUnauthorized {
+		t.Fatal("bad endpoint reported as an auth failure")
+	}
+}
+
+// geminiCLIStream starts ExecuteStream of a Gemini CLI executor against a TLS test server
+// answering with handler.
+func geminiCLIStream(t *testing.T, handler http.HandlerFunc) <-chan cliproxyexecutor.StreamChunk {
+	t.Helper()
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+
+	auth := &cliproxyauth.Auth{ID: "cli", Provider: "gemini-cli", Metadata: map[string]any{
+		"access_token":                   "token",
+		cliproxyauth.MetadataEndpointKey: srv.URL,
+		"expiry":                         time.Now().Add(time.Hour).Format(time.RFC3339),
+	}}
+	ctx := context.WithValue(context.Background(), "cliproxy.roundtripper", srv.Client().Transport)
+	chunks, err := NewGeminiCLIExecutor(&config.Config{}).ExecuteStream(ctx, auth, cliproxyexecutor.Request{
+		Model:   "gemini-2.5-pro",
+		Payload: []byte(`{"request":{"contents":[]}}`),
+	}, cliproxyexecutor.Options{Stream: true, SourceFormat: sdktranslator.FormatRaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chunks
+}
+
+// streamFrom returns the chunk payloads of a Gemini CLI stream answered with contentType and
+// body.
+func streamFrom(t *testing.T, contentType, body string) []string {
+	t.Helper()
+	chunks := geminiCLIStream(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, body)
+	})
+	var got []string
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		got = append(got, string(chunk.Payload))
+	}
+	return got
+}
+
+const cliStreamLine = "data: " + `{"response":{"candidates":[{"content":{"parts":[{"text":"chunk"}]}}]}}`
+
+func TestGeminiCLIStreamsLargeNonSSEBodies(t *testing.T) {
+	lines := maxOperationPeek/len(cliStreamLine) + 10
+	got := streamFrom(t, "application/json", strings.Repeat(cliStreamLine+"\n", lines))
+	if len(got) != lines {
+		t.Fatalf("got %d chunks, want %d", len(got), lines)
+	}
+}
+
+func TestGeminiCLINonSSEStreamStartsBeforeBodyEnds(t *testing.T) {
+	release := make(chan struct{})
+	chunks := geminiCLIStream(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, strings.Repeat(cliStreamLine+"\n", maxOperationPeek/len(cliStreamLine)+10))
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, cliStreamLine+"\n")
+	})
+	defer close(release)
+	select {
+	case chunk := <-chunks:
+		if chunk.Err != nil || !strings.Contains(string(chunk.Payload), "chunk") {
+			t.Fatalf("first chunk = %q, %v", chunk.Payload, chunk.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no chunk before the body ended")
+	}
+}
+
+func TestGeminiCLIStreamDetectsFinishedOperation(t *testing.T) {
+	got := streamFrom(t, "application/json", `{"name":"operations/op-1","done":true,"response":{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}}`)
+	if len(got) != 1 || !strings.Contains(got[0], `"text":"hi"`) || strings.Contains(got[0], "operations/") {
+		t.Fatalf("chunks = %q, want the operation response", got)
+	}
+}
@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// defaultGeminiCLIOperationTimeout bounds the polling of an operation when
+	// gemini-cli-operation-timeout is unset.
+	defaultGeminiCLIOperationTimeout = 5 * time.Minute
+	// geminiCLIOperationMinBackoff and geminiCLIOperationMaxBackoff bound the wait between
+	// two polls; it doubles after every poll that finds the operation still running.
+	geminiCLIOperationMinBackoff = time.Second
+	geminiCLIOperationMaxBackoff = 10 * time.Second
+	// geminiCLIOperationHeartbeat is the interval of the SSE comments that keep a stream open
+	// while its operation is polled.
+	geminiCLIOperationHeartbeat = 15 * time.Second
+	// maxOperationPeek is how much of a non-SSE stream response is read to tell an
+	// operation from a plain body; the body of a finished operation fits well within it.
+	maxOperationPeek = 1 << 20
+)
+
+// geminiCLIOperationName returns the name of the long-running operation data refers to
+// instead of holding generated content, if any.
+func geminiCLIOperationName(data []byte) (string, bool) {
+	name := gjson.GetBytes(data, "name").String()
+	if !strings.HasPrefix(name, "operations/") || !gjson.GetBytes(data, "done").Exists() {
+		return "", false
+	}
+	return name, true
+}
+
+// geminiCLIOperationResult returns the generate response of a finished operation in the
+// shape generateContent answers with, or the status error the operation failed with.
+func geminiCLIOperationResult(name string, operation []byte) ([]byte, error) {
+	if opErr := gjson.GetBytes(operation, "error"); opErr.Exists() {
+		return nil, statusErr{code: http.StatusBadGateway, msg: fmt.Sprintf("gemini-cli operation %s failed: %s", name, opErr.Raw)}
+	}
+	response := gjson.GetBytes(operation, "response")
+	if !response.Exists() {
+		return nil, statusErr{code: http.StatusBadGateway, msg: fmt.Sprintf("gemini-cli operation %s finished without a response", name)}
+	}
+	out := []byte(response.Raw)
+	if trimmed, err := sjson.DeleteBytes(out, `\@type`); err == nil {
+		out = trimmed
+	}
+	if !gjson.GetBytes(out, "response").Exists() {
+		wrapped, err := sjson.SetRawBytes([]byte(`{}`), "response", out)
+		if err == nil {
+			out = wrapped
+		}
+	}
+	return out, nil
+}
+
+// operationTimeout returns how long an operation is polled before giving up.
+func (e *GeminiCLIExecutor) operationTimeout() time.Duration {
+	if e.cfg != nil && e.cfg.GeminiCLIOperationTimeout > 0 {
+		return time.Duration(e.cfg.GeminiCLIOperationTimeout) * time.Second
+	}
+	return defaultGeminiCLIOperationTimeout
+}
+
+// awaitOperation polls the operation name until it is done and returns its generate
+// response. It gives up with a 504 naming the operation after the operation timeout, so the
+// caller can follow it up by hand. heartbeat, when set, is called between polls at most every
+// geminiCLIOperationHeartbeat.
+func (e *GeminiCLIExecutor) awaitOperation(ctx context.Context, auth *cliproxyauth.Auth, httpClient *http.Client, endpoint, name string, tokenSource oauth2.TokenSource, heartbeat func()) ([]byte, error) {
+	timeout := e.operationTimeout()
+	deadline := time.Now().Add(timeout)
+	backoff := geminiCLIOperationMinBackoff
+	lastBeat := time.Now()
+	url := fmt.Sprintf("%s/%s/%s", endpoint, codeAssistVersion, name)
+	for {
+		wait := min(backoff, time.Until(deadline))
+		if wait <= 0 {
+			return nil, statusErr{code: http.StatusGatewayTimeout, msg: fmt.Sprintf("gemini-cli operation %s did not finish within %s; poll it manually", name, timeout)}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, geminiCLIOperationMaxBackoff)
+		if heartbeat != nil && time.Since(lastBeat) >= geminiCLIOperationHeartbeat {
+			heartbeat()
+			lastBeat = time.Now()
+		}
+
+		tok, errTok := tokenSource.Token()
+		if errTok != nil {
+			return nil, errTok
+		}
+		reqHTTP, errReq := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if errReq != nil {
+			return nil, errReq
+		}
+		reqHTTP.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		applyGeminiCLIHeaders(reqHTTP)
+		applyUserAgent(e.cfg, reqHTTP, e.Identifier())
+		reqHTTP.Header.Set("Accept", "application/json")
+		resp, errDo := httpClient.Do(reqHTTP)
+		if errDo != nil {
+			return nil, errDo
+		}
+		data, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			recordUpstreamError(ctx, auth, endpointOf(resp), resp.StatusCode, string(data))
+			return nil, statusErr{code: resp.StatusCode, msg: string(data)}
+		}
+		if gjson.GetBytes(data, "done").Bool() {
+			appendAPIResponseChunk(ctx, e.cfg, data)
+			return geminiCLIOperationResult(name, data)
+		}
+	}
+}
@@ -80,10 +80,19 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 
 	action := "generateContent"
 	if req.Metadata != nil {
-		if a, _ := req.Metadata["action"].(string); a == "countTokens" {
+		switch a, _ := req.Metadata["action"].(string); a {
+		case "countTokens":
 			action = "countTokens"
+		case "embedContent":
+			return e.embedContent(ctx, auth, req, opts)
 		}
 	}
+	if action == "generateContent" {
+		body = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "gemini", req.Model, body)
+		body = stripUnknownFields(e.cfg, e.Identifier(), from, "gemini", body)
+		body = stripUnsupportedParams(e.Features(), from, body)
+		body = applySafetySettings(ctx, e.cfg, "gemini", body)
+	}
 	url := fmt.Sprintf("%s/%s/models/%s:%s", glEndpoint, glAPIVersion, req.Model, action)
 	if opts.Alt != "" && action != "countTokens" {
 		url = url + fmt.Sprintf("?$alt=%s", opts.Alt)
@@ -102,21 +111,23 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	} else if bearer != "" {
 		httpReq.Header.Set("Authorization", "Bearer "+bearer)
 	}
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
 
-	httpClient := &http.Client{}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && action != "countTokens" && canPassthroughBody(e.cfg, opts, to.String()) {
+		return cliproxyexecutor.Response{Body: newPassthroughBody(ctx, resp.Body, reporter)}, nil
+	}
 	defer func() { _ = resp.Body.Close() }()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(b)}
+		return cliproxyexecutor.Response{}, upstreamStatusErr(ctx, auth, resp, b)
 	}
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -124,9 +135,13 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	}
 	appendAPIResponseChunk(ctx, e.cfg, data)
 	reporter.publish(ctx, parseGeminiUsage(data))
+	reporter.observeModelIdentity(data, geminiModelIdentity)
 	var param any
-	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
-	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+	out, err := translateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, data, &param)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: out}, nil
 }
 
 func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
@@ -137,6 +152,10 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = applyOutputCap(ctx, e.cfg, reporter, e.Identifier(), "gemini", req.Model, body)
+	body = stripUnknownFields(e.cfg, e.Identifier(), from, "gemini", body)
+	body = stripUnsupportedParams(e.Features(), from, body)
+	body = applySafetySettings(ctx, e.cfg, "gemini", body)
 
 	url := fmt.Sprintf("%s/%s/models/%s:%s", glEndpoint, glAPIVersion, req.Model, "streamGenerateContent")
 	if opts.Alt == "" {
@@ -158,11 +177,10 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	} else {
 		httpReq.Header.Set("Authorization", "Bearer "+bearer)
 	}
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
 
-	httpClient := &http.Client{Timeout: 0}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -172,7 +190,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		b, _ := io.ReadAll(resp.Body)
 		appendAPIResponseChunk(ctx, e.cfg, b)
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(b))
-		return nil, statusErr{code: resp.StatusCode, msg: string(b)}
+		return nil, upstreamStatusErr(ctx, auth, resp, b)
 	}
 	out := make(chan cliproxyexecutor.StreamChunk)
 	go func() {
@@ -188,17 +206,27 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 			if detail, ok := parseGeminiStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
 			}
-			lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			reporter.observeModelIdentity(line, geminiModelIdentity)
+			lines, errTranslate := translateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone(line), &param)
+			if errTranslate != nil {
+				failStream(ctx, reporter, out, errTranslate)
+				return
+			}
 			for i := range lines {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(lines[i])}
 			}
 		}
-		lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone([]byte("[DONE]")), &param)
+		lines, errTranslate := translateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, bytes.Clone([]byte("[DONE]")), &param)
+		if errTranslate != nil {
+			failStream(ctx, reporter, out, errTranslate)
+			return
+		}
 		for i := range lines {
 			out <- cliproxyexecutor.StreamChunk{Payload: []byte(lines[i])}
 		}
 		if err = scanner.Err(); err != nil {
-			out <- cliproxyexecutor.StreamChunk{Err: err}
+			recordUpstreamError(ctx, auth, endpointOf(resp), 0, err.Error())
+			failStream(ctx, reporter, out, err)
 		}
 	}()
 	return out, nil
@@ -229,11 +257,10 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	} else {
 		httpReq.Header.Set("Authorization", "Bearer "+bearer)
 	}
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
 
-	httpClient := &http.Client{}
-	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-	}
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
@@ -247,7 +274,7 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	appendAPIResponseChunk(ctx, e.cfg, data)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
-		return cliproxyexecutor.Response{}, statusErr{code: resp.StatusCode, msg: string(data)}
+		return cliproxyexecutor.Response{}, upstreamStatusErr(ctx, auth, resp, data)
 	}
 
 	count := gjson.GetBytes(data, "totalTokens").Int()
@@ -255,6 +282,49 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 }
 
+// embedContent forwards a Generative Language embedContent request unchanged and returns
+// the upstream response as is. Only Gemini formatted requests can be embedded.
+func (e *GeminiExecutor) embedContent(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if opts.SourceFormat != sdktranslator.FromString("gemini") {
+		return cliproxyexecutor.Response{}, statusErr{code: http.StatusNotImplemented, msg: fmt.Sprintf("embedContent is not supported for %s requests", opts.SourceFormat)}
+	}
+	apiKey, bearer := geminiCreds(auth)
+
+	url := fmt.Sprintf("%s/%s/models/%s:%s", glEndpoint, glAPIVersion, req.Model, "embedContent")
+	body := bytes.Clone(req.Payload)
+	recordAPIRequest(ctx, e.cfg, body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+	} else if bearer != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	applyUserAgent(e.cfg, httpReq, e.Identifier())
+	forwardClientHeaders(ctx, e.cfg, httpReq)
+
+	httpClient := newHTTPClient(ctx, e.Identifier(), 0)
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Debugf("request error, error status: %d, error body: %s", resp.StatusCode, string(data))
+		return cliproxyexecutor.Response{}, upstreamStatusErr(ctx, auth, resp, data)
+	}
+	return cliproxyexecutor.Response{Payload: data}, nil
+}
+
 func (e *GeminiExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	log.Debugf("gemini executor: refresh called")
 	// OAuth bearer token refresh for official Gemini API.
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// redirectTransport sends every request to target, keeping its path and query.
+type redirectTransport struct{ target *url.URL }
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// glUpstream returns a context routing Generative Language requests to handler.
+func glUpstream(t *testing.T, handler http.HandlerFunc) context.Context {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	return context.WithValue(context.Background(), "cliproxy.roundtripper", http.RoundTripper(redirectTransport{target}))
+}
+
+func TestGeminiExecutorPassesEmbedContentThrough(t *testing.T) {
+	const upstreamBody = `{"embedding":{"values":[0.1,-0.2,0.3]}}`
+	var path, key, body string
+	ctx := glUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.Header.Get("x-goog-api-key")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		_, _ = io.WriteString(w, upstreamBody)
+	})
+	auth := &cliproxyauth.Auth{ID: "gl", Provider: "gemini", Attributes: map[string]string{"api_key": "gl-key"}}
+	request := `{"content":{"parts":[{"text":"hello"}]}}`
+
+	resp, err := NewGeminiExecutor(&config.Config{}).Execute(ctx, auth, cliproxyexecutor.Request{
+		Model:    "gemini-embedding-001",
+		Payload:  []byte(request),
+		Metadata: map[string]any{"action": "embedContent"},
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1beta/models/gemini-embedding-001:embedContent" || key != "gl-key" || body != request {
+		t.Fatalf("upstream saw %s with key %q and body %s", path, key, body)
+	}
+	if string(resp.Payload) != upstreamBody {
+		t.Fatalf("payload = %s, want the upstream embedding unchanged", resp.Payload)
+	}
+}
+
+func TestGeminiExecutorRejectsEmbedContentFromOtherFormats(t *testing.T) {
+	_, err := NewGeminiExecutor(&config.Config{}).Execute(context.Background(), &cliproxyauth.Auth{}, cliproxyexecutor.Request{
+		Model:    "gemini-embedding-001",
+		Payload:  []byte(`{}`),
+		Metadata: map[string]any{"action": "embedContent"},
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai")})
+	var status statusErr
+	if !errors.As(err, &status) || status.code != http.StatusNotImplemented {
+		t.Fatalf("error = %v, want 501", err)
+	}
+}
+
+func TestGeminiExecutorCountsTokensUpstream(t *testing.T) {
+	var path string
+	ctx := glUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_, _ = io.WriteString(w, `{"totalTokens":7}`)
+	})
+	auth := &cliproxyauth.Auth{ID: "gl", Provider: "gemini", Attributes: map[string]string{"api_key": "gl-key"}}
+	resp, err := NewGeminiExecutor(&config.Config{}).CountTokens(ctx, auth, cliproxyexecutor.Request{
+		Model:   "gemini-2.5-pro",
+		Payload: []byte(`{"contents":[{"role":"user","parts":[{"text":"hello"}]}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1beta/models/gemini-2.5-pro:countTokens" || gjson.GetBytes(resp.Payload, "totalTokens").Int() != 7 {
+		t.Fatalf("upstream path %s, payload %s", path, resp.Payload)
+	}
+}
+
+func TestGeminiWebExecutorEstimatesTokenCounts(t *testing.T) {
+	resp, err := NewGeminiWebExecutor(&config.Config{}).CountTokens(context.Background(), &cliproxyauth.Auth{}, cliproxyexecutor.Request{
+		Model:   "gemini-2.5-pro",
+		Payload: []byte(`{"contents":[{"role":"user","parts":[{"text":"count these words for me please"}]}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := gjson.GetBytes(resp.Payload, "totalTokens"); !total.Exists() || total.Int() <= 0 {
+		t.Fatalf("payload = %s, want a positive totalTokens", resp.Payload)
+	}
+}
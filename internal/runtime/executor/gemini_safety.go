@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// safetySettingsFields names the body field carrying the safety settings per upstream
+// request format.
+var safetySettingsFields = map[string]string{
+	"gemini":     "safetySettings",
+	"gemini-cli": "request.safetySettings",
+}
+
+// applySafetySettings merges the safety thresholds configured for the client API key of ctx
+// under those of an upstream request body in format: categories the client set keep its
+// threshold, unless enforce-floor raises it to the configured one, and the configured
+// thresholds fill in the other categories.
+func applySafetySettings(ctx context.Context, cfg *config.Config, format string, body []byte) []byte {
+	field := safetySettingsFields[format]
+	if cfg == nil || field == "" {
+		return body
+	}
+	configured := configuredSafetySettings(cfg.SafetySettings, apiKeyFromContext(ctx))
+	if len(configured) == 0 {
+		return body
+	}
+	// Handlers reject invalid client settings before they get here.
+	client, _ := util.ParseGeminiSafetySettings(gjson.GetBytes(body, field))
+
+	floors := make(map[string]string, len(configured))
+	for _, setting := range configured {
+		floors[setting.Category] = setting.Threshold
+	}
+	merged := make([]util.GeminiSafetySetting, 0, len(client)+len(configured))
+	set := make(map[string]struct{}, len(client))
+	for _, setting := range client {
+		if floor, ok := floors[setting.Category]; ok && cfg.SafetySettings.EnforceFloor && util.GeminiThresholdLooser(setting.Threshold, floor) {
+			logging.RecordSafetyNote(ctx, fmt.Sprintf("%s raised from %s to %s", setting.Category, setting.Threshold, floor))
+			setting.Threshold = floor
+		}
+		set[setting.Category] = struct{}{}
+		merged = append(merged, setting)
+	}
+	for _, setting := range configured {
+		if _, ok := set[setting.Category]; !ok {
+			merged = append(merged, setting)
+		}
+	}
+	updated, err := sjson.SetBytes(body, field, merged)
+	if err != nil {
+		return body
+	}
+	return updated
+}
+
+// configuredSafetySettings returns the default thresholds overridden by those of apiKey,
+// leaving out entries with an unknown category or threshold.
+func configuredSafetySettings(settings config.SafetySettingsConfig, apiKey string) []util.GeminiSafetySetting {
+	var out []util.GeminiSafetySetting
+	index := make(map[string]int)
+	for _, list := range [][]config.SafetySetting{settings.Defaults, settings.APIKeys[apiKey]} {
+		for _, entry := range list {
+			setting, err := util.NormalizeGeminiSafetySetting(entry.Category, entry.Threshold)
+			if err != nil {
+				log.Debugf("safety-settings: %v", err)
+				continue
+			}
+			if i, ok := index[setting.Category]; ok {
+				out[i] = setting
+				continue
+			}
+			index[setting.Category] = len(out)
+			out = append(out, setting)
+		}
+	}
+	return out
+}
@@ -3,10 +3,10 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
@@ -21,7 +21,6 @@ import (
 
 type GeminiWebExecutor struct {
 	cfg *config.Config
-	mu  sync.Mutex
 }
 
 func NewGeminiWebExecutor(cfg *config.Config) *GeminiWebExecutor {
@@ -32,14 +31,25 @@ func (e *GeminiWebExecutor) Identifier() string { return "gemini-web" }
 
 func (e *GeminiWebExecutor) PrepareRequest(_ *http.Request, _ *cliproxyauth.Auth) error { return nil }
 
+// errGeminiWebPassthrough rejects raw passthrough requests: Gemini Web is a browser session,
+// not an API whose native format a client could send.
+var errGeminiWebPassthrough = statusErr{code: http.StatusBadRequest, msg: "gemini-web does not support raw passthrough requests"}
+
 func (e *GeminiWebExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if opts.SourceFormat == sdktranslator.FormatRaw {
+		return cliproxyexecutor.Response{}, errGeminiWebPassthrough
+	}
 	state, err := e.stateFor(auth)
 	if err != nil {
 		return cliproxyexecutor.Response{}, err
 	}
-	if err = state.EnsureClient(); err != nil {
+	defer e.rebalance()
+	if err = state.EnsureClient(ctx); err != nil {
+		err = geminiWebInitError(err)
+		geminiWebStates.fail(auth.ID, err)
 		return cliproxyexecutor.Response{}, err
 	}
+	geminiWebStates.touch(auth.ID)
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 
 	mutex := state.GetRequestMutex()
@@ -50,28 +60,46 @@ func (e *GeminiWebExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 
 	payload := bytes.Clone(req.Payload)
 	resp, errMsg, prep := state.Send(ctx, req.Model, payload, opts)
+	if errMsg != nil && e.rotateProxy(ctx, auth, state, errMsg) {
+		resp, errMsg, prep = state.Send(ctx, req.Model, payload, opts)
+	}
 	if errMsg != nil {
-		return cliproxyexecutor.Response{}, geminiWebErrorFromMessage(errMsg)
+		return cliproxyexecutor.Response{}, e.failed(ctx, auth, errMsg)
 	}
+	geminiWebStates.succeed(auth.ID)
+	if limit, truncated := prep.OutputCap(); truncated {
+		reporter.outputCap, reporter.truncated = int64(limit), true
+	}
+	reporter.toolSchemaSaved = int64(prep.ToolSchemaTokensSaved())
+	reuse := reportContextReuse(ctx, reporter, prep.Reuse())
 	resp = state.ConvertToTarget(ctx, req.Model, prep, resp)
 	reporter.publish(ctx, parseGeminiUsage(resp))
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini-web")
 	var param any
-	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), payload, bytes.Clone(resp), &param)
-
-	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+	out, err := translateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), payload, bytes.Clone(resp), &param)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: withContextReuse(out, reuse)}, nil
 }
 
 func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	if opts.SourceFormat == sdktranslator.FormatRaw {
+		return nil, errGeminiWebPassthrough
+	}
 	state, err := e.stateFor(auth)
 	if err != nil {
 		return nil, err
 	}
-	if err = state.EnsureClient(); err != nil {
+	defer e.rebalance()
+	if err = state.EnsureClient(ctx); err != nil {
+		err = geminiWebInitError(err)
+		geminiWebStates.fail(auth.ID, err)
 		return nil, err
 	}
+	geminiWebStates.touch(auth.ID)
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 
 	mutex := state.GetRequestMutex()
@@ -80,12 +108,21 @@ func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 	}
 
 	gemBytes, errMsg, prep := state.Send(ctx, req.Model, bytes.Clone(req.Payload), opts)
+	if errMsg != nil && e.rotateProxy(ctx, auth, state, errMsg) {
+		gemBytes, errMsg, prep = state.Send(ctx, req.Model, bytes.Clone(req.Payload), opts)
+	}
 	if errMsg != nil {
 		if mutex != nil {
 			mutex.Unlock()
 		}
-		return nil, geminiWebErrorFromMessage(errMsg)
+		return nil, e.failed(ctx, auth, errMsg)
+	}
+	geminiWebStates.succeed(auth.ID)
+	if limit, truncated := prep.OutputCap(); truncated {
+		reporter.outputCap, reporter.truncated = int64(limit), true
 	}
+	reporter.toolSchemaSaved = int64(prep.ToolSchemaTokensSaved())
+	reportContextReuse(ctx, reporter, prep.Reuse())
 	reporter.publish(ctx, parseGeminiUsage(gemBytes))
 
 	from := opts.SourceFormat
@@ -100,15 +137,13 @@ func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 		if mutex != nil {
 			defer mutex.Unlock()
 		}
-		for _, line := range lines {
-			lines = sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), req.Payload, bytes.Clone([]byte(line)), &param)
-			for _, l := range lines {
-				out <- cliproxyexecutor.StreamChunk{Payload: []byte(l)}
+		for _, line := range append(lines, done...) {
+			translated, errTranslate := translateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), req.Payload, bytes.Clone([]byte(line)), &param)
+			if errTranslate != nil {
+				failStream(ctx, reporter, out, errTranslate)
+				return
 			}
-		}
-		for _, line := range done {
-			lines = sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), req.Payload, bytes.Clone([]byte(line)), &param)
-			for _, l := range lines {
+			for _, l := range translated {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(l)}
 			}
 		}
@@ -116,8 +151,16 @@ func (e *GeminiWebExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 	return out, nil
 }
 
+// CountTokens estimates the prompt size locally because Gemini Web has no token counting
+// endpoint. The request is read in Gemini form and the count is reported in the caller's format.
 func (e *GeminiWebExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	return cliproxyexecutor.Response{Payload: []byte{}}, fmt.Errorf("not implemented")
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("gemini")
+	payload := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), false)
+	count := int64(geminiwebapi.EstimateTotalTokensFromRawJSON(payload))
+	fallback := []byte(fmt.Sprintf(`{"totalTokens":%d}`, count))
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, fallback)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
 }
 
 func (e *GeminiWebExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
@@ -127,16 +170,24 @@ func (e *GeminiWebExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth
 		return nil, err
 	}
 	if err = state.Refresh(ctx); err != nil {
+		err = geminiWebInitError(err)
+		geminiWebStates.fail(auth.ID, err)
 		return nil, err
 	}
 	ts := state.TokenSnapshot()
 	if auth.Metadata == nil {
 		auth.Metadata = make(map[string]any)
 	}
-	auth.Metadata["secure_1psid"] = ts.Secure1PSID
-	auth.Metadata["secure_1psidts"] = ts.Secure1PSIDTS
-	auth.Metadata["type"] = "gemini-web"
-	auth.Metadata["last_refresh"] = time.Now().Format(time.RFC3339)
+	// The metadata is left as it was when nothing needs writing, which spares the auth file
+	// write; rotated cookies are always written.
+	now := time.Now()
+	changed := auth.Metadata["secure_1psid"] != ts.Secure1PSID || auth.Metadata["secure_1psidts"] != ts.Secure1PSIDTS
+	if changed || geminiWebPersistDue(e.cfg, auth.ID, auth.Metadata, now) {
+		auth.Metadata["secure_1psid"] = ts.Secure1PSID
+		auth.Metadata["secure_1psidts"] = ts.Secure1PSIDTS
+		auth.Metadata["type"] = "gemini-web"
+		auth.Metadata["last_refresh"] = now.Format(time.RFC3339)
+	}
 	if v, ok := auth.Metadata["label"].(string); !ok || strings.TrimSpace(v) == "" {
 		if lbl := state.Label(); strings.TrimSpace(lbl) != "" {
 			auth.Metadata["label"] = strings.TrimSpace(lbl)
@@ -145,25 +196,48 @@ func (e *GeminiWebExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth
 	return auth, nil
 }
 
-type geminiWebRuntime struct {
-	state *geminiwebapi.GeminiWebState
+// RotateCookies rotates the __Secure-1PSIDTS cookie of auth on demand. It returns a copy of
+// auth whose metadata carries the cookie in use afterwards, ready to be persisted, and
+// whether Google issued a new one.
+func (e *GeminiWebExecutor) RotateCookies(auth *cliproxyauth.Auth) (*cliproxyauth.Auth, bool, error) {
+	state, err := e.stateFor(auth)
+	if err != nil {
+		return nil, false, err
+	}
+	previous := state.TokenSnapshot().Secure1PSIDTS
+	current, err := state.RotateNow()
+	if err != nil {
+		return nil, false, geminiWebInitError(err)
+	}
+	updated := auth.Clone()
+	if updated.Metadata == nil {
+		updated.Metadata = make(map[string]any)
+	}
+	updated.Metadata["secure_1psidts"] = current
+	updated.Metadata["last_refresh"] = time.Now().Format(time.RFC3339)
+	return updated, current != previous, nil
 }
 
-func (e *GeminiWebExecutor) stateFor(auth *cliproxyauth.Auth) (*geminiwebapi.GeminiWebState, error) {
-	if auth == nil {
-		return nil, fmt.Errorf("gemini-web executor: auth is nil")
+// CheckCookies tests whether Google still accepts the cookies of auth without changing any
+// state, and returns when they were last refreshed or rotated.
+func (e *GeminiWebExecutor) CheckCookies(auth *cliproxyauth.Auth) (time.Time, error) {
+	state, err := e.stateFor(auth)
+	if err != nil {
+		return time.Time{}, err
 	}
-	if runtime, ok := auth.Runtime.(*geminiWebRuntime); ok && runtime != nil && runtime.state != nil {
-		return runtime.state, nil
+	lastRefresh, err := state.CheckCookies()
+	if err != nil {
+		return lastRefresh, geminiWebInitError(err)
 	}
+	return lastRefresh, nil
+}
 
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if runtime, ok := auth.Runtime.(*geminiWebRuntime); ok && runtime != nil && runtime.state != nil {
-		return runtime.state, nil
+// stateFor returns the pooled state for auth. States outlive the cloned auth handed to the
+// executor, so conversation caches and initialized clients survive across requests.
+func (e *GeminiWebExecutor) stateFor(auth *cliproxyauth.Auth) (*geminiwebapi.GeminiWebState, error) {
+	if auth == nil {
+		return nil, fmt.Errorf("gemini-web executor: auth is nil")
 	}
-
 	ts, err := parseGeminiWebToken(auth)
 	if err != nil {
 		return nil, err
@@ -182,10 +256,54 @@ func (e *GeminiWebExecutor) stateFor(auth *cliproxyauth.Auth) (*geminiwebapi.Gem
 			storagePath = p
 		}
 	}
-	state := geminiwebapi.NewGeminiWebState(cfg, ts, storagePath)
-	runtime := &geminiWebRuntime{state: state}
-	auth.Runtime = runtime
-	return state, nil
+	return geminiWebStates.get(auth.ID, ts.Secure1PSID, auth.ProxyURL, func() *geminiwebapi.GeminiWebState {
+		return geminiwebapi.NewGeminiWebState(cfg, ts, storagePath)
+	}), nil
+}
+
+// failed converts a send error, records it in the error log and takes the account out of
+// the standby pool when it hit its quota. An IP block takes out every account sharing its
+// proxy, so standbys are warmed on other egresses.
+func (e *GeminiWebExecutor) failed(ctx context.Context, auth *cliproxyauth.Auth, msg *interfaces.ErrorMessage) error {
+	err := geminiWebErrorFromMessage(msg)
+	if msg != nil {
+		recordUpstreamError(ctx, auth, geminiwebapi.EndpointGenerate, msg.StatusCode, err.Error())
+	}
+	switch {
+	case cliproxyexecutor.EgressBlockedOf(err):
+		geminiWebStates.blockProxy(auth.ProxyURL, time.Now().Add(geminiWebBlockCooldown), err)
+	case cliproxyexecutor.ErrorKindOf(err) == cliproxyexecutor.ErrorKindQuota:
+		geminiWebStates.block(auth.ID, time.Now().Add(geminiWebBlockCooldown), err)
+	}
+	return err
+}
+
+// rotateProxy moves the account of state to the next proxy of the gemini-web proxy-pool when
+// msg reports that Gemini Web blocked the address it sends from, and reports whether the
+// request should be sent again through the new proxy. The proxy left behind is skipped by
+// rotations until its cooldown ends. Without a pool, or when no proxy of it can take over,
+// the block is handled by failed as before.
+func (e *GeminiWebExecutor) rotateProxy(ctx context.Context, auth *cliproxyauth.Auth, state *geminiwebapi.GeminiWebState, msg *interfaces.ErrorMessage) bool {
+	if e.cfg == nil || len(e.cfg.GeminiWeb.ProxyPool) == 0 || !cliproxyexecutor.EgressBlockedOf(geminiWebErrorFromMessage(msg)) {
+		return false
+	}
+	from := state.ProxyURL()
+	geminiWebStates.blockEgress(from, time.Now().Add(geminiWebBlockCooldown))
+	to, err := state.RotateProxy(ctx, geminiWebStates.egressBlocked)
+	if err != nil {
+		log.Warnf("gemini web executor: auth %s blocked and no pool proxy could replace its egress: %v", auth.ID, err)
+		return false
+	}
+	return to != from
+}
+
+// rebalance schedules a standby pool pass using the configured warm standby count.
+func (e *GeminiWebExecutor) rebalance() {
+	standby := 0
+	if e.cfg != nil {
+		standby = e.cfg.GeminiWeb.WarmStandby
+	}
+	geminiWebStates.rebalance(standby)
 }
 
 func parseGeminiWebToken(auth *cliproxyauth.Auth) (*gemini.GeminiWebTokenStorage, error) {
@@ -214,6 +332,48 @@ func stringFromMetadata(meta map[string]any, keys ...string) string {
 	return ""
 }
 
+// geminiWebInitError classifies a failed client initialization. A rejected cookie is an auth
+// failure that retrying will not fix; anything else (network errors, Google outages) is
+// transient.
+func geminiWebInitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var authErr *geminiwebapi.AuthError
+	if errors.As(err, &authErr) {
+		return geminiWebError{message: &interfaces.ErrorMessage{
+			StatusCode: http.StatusUnauthorized,
+			Error:      fmt.Errorf("gemini-web cookies rejected: %w", err),
+			Kind:       cliproxyexecutor.ErrorKindAuth,
+		}}
+	}
+	return geminiWebError{message: &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadGateway,
+		Error:      fmt.Errorf("gemini-web init failed: %w", err),
+		Kind:       cliproxyexecutor.ErrorKindTransient,
+	}}
+}
+
+// TrackGeminiWebAuth adds a Gemini Web auth to the standby pool before its first request, so
+// idle accounts can be warmed as standbys, and schedules a pool pass.
+func TrackGeminiWebAuth(cfg *config.Config, auth *cliproxyauth.Auth) {
+	if auth == nil || auth.Disabled {
+		return
+	}
+	e := NewGeminiWebExecutor(cfg)
+	if _, err := e.stateFor(auth); err != nil {
+		log.Debugf("gemini web pool: not tracking %s: %v", auth.ID, err)
+		return
+	}
+	e.rebalance()
+}
+
+// ReleaseGeminiWebState drops the pooled state of a removed auth so the standby pool stops
+// warming it.
+func ReleaseGeminiWebState(authID string) {
+	geminiWebStates.forget(authID)
+}
+
 func geminiWebErrorFromMessage(msg *interfaces.ErrorMessage) error {
 	if msg == nil {
 		return nil
@@ -241,3 +401,23 @@ func (e geminiWebError) StatusCode() int {
 	}
 	return e.message.StatusCode
 }
+
+// EgressBlocked reports whether Gemini Web blocked the IP address the request came from. A
+// plain 429 is rate limiting of the account and does not count.
+func (e geminiWebError) EgressBlocked() bool {
+	if e.message == nil {
+		return false
+	}
+	var blocked *geminiwebapi.TemporarilyBlocked
+	return errors.As(e.message.Error, &blocked) && blocked.IPBlocked
+}
+
+func (e geminiWebError) Kind() cliproxyexecutor.ErrorKind {
+	if e.message == nil {
+		return cliproxyexecutor.ErrorKindUpstream
+	}
+	if e.message.Kind != "" {
+		return e.message.Kind
+	}
+	return cliproxyexecutor.ErrorKindForStatus(e.message.StatusCode)
+}
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestGeminiWebEgressBlockedOnlyForIPBlocks(t *testing.T) {
+	rateLimited := &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: &geminiwebapi.TemporarilyBlocked{}}
+	ipBlocked := &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: &geminiwebapi.TemporarilyBlocked{IPBlocked: true}}
+	if cliproxyexecutor.EgressBlockedOf(geminiWebErrorFromMessage(rateLimited)) {
+		t.Error("plain 429 reported as an egress block")
+	}
+	if !cliproxyexecutor.EgressBlockedOf(geminiWebErrorFromMessage(ipBlocked)) {
+		t.Error("IP block not reported as an egress block")
+	}
+}
+
+// countingProxy refuses every request and counts them, so client initialization through it
+// fails fast.
+func countingProxy(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestGeminiWebRotateProxyOnlyOnIPBlock(t *testing.T) {
+	// The state opens its conversation store under ./conv.
+	t.Chdir(t.TempDir())
+	current, currentHits := countingProxy(t)
+	next, nextHits := countingProxy(t)
+	cfg := &config.Config{}
+	cfg.ProxyURL = current.URL
+	cfg.GeminiWeb.ProxyPool = []string{current.URL, next.URL}
+	exec := &GeminiWebExecutor{cfg: cfg}
+	state := geminiwebapi.NewGeminiWebState(cfg, &gemini.GeminiWebTokenStorage{Secure1PSID: "psid", Secure1PSIDTS: "psidts"}, "")
+	auth := &cliproxyauth.Auth{ID: "rotate-test"}
+	t.Cleanup(func() {
+		geminiWebStates.blockEgress(current.URL, time.Time{})
+	})
+
+	rateLimited := &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: &geminiwebapi.TemporarilyBlocked{}}
+	if exec.rotateProxy(context.Background(), auth, state, rateLimited) {
+		t.Fatal("rotated on a plain 429")
+	}
+	if geminiWebStates.egressBlocked(current.URL) || nextHits.Load() != 0 {
+		t.Fatal("plain 429 blocked the egress or tried the pool")
+	}
+
+	ipBlocked := &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: &geminiwebapi.TemporarilyBlocked{IPBlocked: true}}
+	if exec.rotateProxy(context.Background(), auth, state, ipBlocked) {
+		t.Fatal("rotated although the only other proxy failed to initialize")
+	}
+	if !geminiWebStates.egressBlocked(current.URL) {
+		t.Fatal("blocked egress not put on cooldown")
+	}
+	if nextHits.Load() == 0 {
+		t.Fatal("next pool proxy was not tried")
+	}
+	if currentHits.Load() != 0 {
+		t.Fatal("blocked proxy was tried again")
+	}
+	if state.ProxyURL() != current.URL {
+		t.Fatalf("proxy switched to %s after a failed rotation", state.ProxyURL())
+	}
+}
+
+func TestContextReuseHeaderIsDroppedOnRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	reporter := newUsageReporter(ctx, "gemini-web", "gemini-2.5-pro", nil)
+	reportContextReuse(ctx, reporter, geminiwebapi.ReuseStats{Mode: geminiwebapi.ReuseMatch, Matched: 2, Resent: 1})
+	if c.Writer.Header().Get(contextReuseHeader) == "" {
+		t.Fatal("reuse header not set")
+	}
+	// The next attempt, here on another provider, starts without it.
+	newUsageReporter(ctx, "claude", "claude-sonnet-4", nil)
+	if got := c.Writer.Header().Get(contextReuseHeader); got != "" {
+		t.Fatalf("stale reuse header %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+package executor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// geminiWebActiveWindow is how long after its last request an account counts as active
+	// rather than as a standby.
+	geminiWebActiveWindow = 5 * time.Minute
+	// geminiWebBlockCooldown mirrors the cooldown the auth manager applies after a quota error.
+	geminiWebBlockCooldown = 30 * time.Minute
+)
+
+// Pool states reported by GeminiWebPoolSnapshot.
+const (
+	GeminiWebPoolActive  = "active"
+	GeminiWebPoolWarm    = "warm"
+	GeminiWebPoolCold    = "cold"
+	GeminiWebPoolBlocked = "blocked"
+	GeminiWebPoolFailing = "failing"
+)
+
+// GeminiWebPoolStatus describes one Gemini Web account tracked by the standby pool.
+type GeminiWebPoolStatus struct {
+	AuthID       string     `json:"auth_id"`
+	Label        string     `json:"label,omitempty"`
+	State        string     `json:"state"`
+	LastUsed     *time.Time `json:"last_used,omitempty"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+type geminiWebPoolEntry struct {
+	state        *geminiwebapi.GeminiWebState
+	psid         string
+	proxyURL     string
+	lastUsed     time.Time
+	blockedUntil time.Time
+	lastErr      string
+	failing      bool
+}
+
+// geminiWebPool keeps one state per auth ID across requests and maintains a fixed number of
+// initialized idle accounts so a blocked account can be replaced without a cold start.
+type geminiWebPool struct {
+	mu        sync.Mutex
+	entries   map[string]*geminiWebPoolEntry
+	balancing bool
+	// rebalanceAgain is set when a pass is requested while one runs, so accounts added
+	// meanwhile are warmed by a pass right after it.
+	rebalanceAgain bool
+	// egressBlocks holds until when each proxy an account was moved away from after a block
+	// is left out of proxy rotations.
+	egressBlocks map[string]time.Time
+}
+
+var geminiWebStates = &geminiWebPool{
+	entries:      make(map[string]*geminiWebPoolEntry),
+	egressBlocks: make(map[string]time.Time),
+}
+
+// get returns the pooled state for authID, creating it with build when missing or when the
+// account cookie or proxy no longer matches.
+func (p *geminiWebPool) get(authID, psid, proxyURL string, build func() *geminiwebapi.GeminiWebState) *geminiwebapi.GeminiWebState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[authID]; ok {
+		if entry.psid == psid && entry.proxyURL == proxyURL {
+			return entry.state
+		}
+		entry.state.Release()
+	}
+	state := build()
+	p.entries[authID] = &geminiWebPoolEntry{state: state, psid: psid, proxyURL: proxyURL}
+	return state
+}
+
+// forget releases and removes the state of authID.
+func (p *geminiWebPool) forget(authID string) {
+	p.mu.Lock()
+	entry, ok := p.entries[authID]
+	delete(p.entries, authID)
+	p.mu.Unlock()
+	if ok {
+		releaseIdle(entry.state)
+	}
+}
+
+// touch records a request served by authID.
+func (p *geminiWebPool) touch(authID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[authID]; ok {
+		entry.lastUsed = time.Now()
+	}
+}
+
+// fail records that the account could not be initialized; it is skipped when warming until
+// a later request succeeds.
+func (p *geminiWebPool) fail(authID string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[authID]; ok {
+		entry.failing = true
+		if err != nil {
+			entry.lastErr = err.Error()
+		}
+	}
+}
+
+// succeed clears failure markers after a successful request.
+func (p *geminiWebPool) succeed(authID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[authID]; ok {
+		entry.failing = false
+		entry.lastErr = ""
+	}
+}
+
+// block marks the account as unusable until the given time.
+func (p *geminiWebPool) block(authID string, until time.Time, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[authID]; ok {
+		entry.blockedUntil = until
+		if err != nil {
+			entry.lastErr = err.Error()
+		}
+	}
+}
+
+// blockProxy marks every account sending through proxyURL as unusable until the given time.
+func (p *geminiWebPool) blockProxy(proxyURL string, until time.Time, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.entries {
+		if entry.proxyURL != proxyURL || until.Before(entry.blockedUntil) {
+			continue
+		}
+		entry.blockedUntil = until
+		if err != nil {
+			entry.lastErr = err.Error()
+		}
+	}
+}
+
+// blockEgress keeps proxyURL out of proxy rotations until the given time.
+func (p *geminiWebPool) blockEgress(proxyURL string, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for proxy, blockedUntil := range p.egressBlocks {
+		if !now.Before(blockedUntil) {
+			delete(p.egressBlocks, proxy)
+		}
+	}
+	p.egressBlocks[proxyURL] = until
+}
+
+// egressBlocked reports whether proxyURL was left after a block whose cooldown still runs.
+func (p *geminiWebPool) egressBlocked(proxyURL string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Now().Before(p.egressBlocks[proxyURL])
+}
+
+// rebalance warms idle accounts up to standby and releases warm idle accounts beyond it.
+// Blocked accounts are released as soon as they are idle. It runs in the background and
+// only one pass is in flight at a time; a pass requested meanwhile follows it.
+func (p *geminiWebPool) rebalance(standby int) {
+	if standby < 0 {
+		standby = 0
+	}
+	p.mu.Lock()
+	if p.balancing {
+		p.rebalanceAgain = true
+		p.mu.Unlock()
+		return
+	}
+	p.balancing = true
+	now := time.Now()
+	var warm, cold, blocked []*geminiWebPoolEntry
+	for _, entry := range p.entries {
+		switch {
+		case now.Before(entry.blockedUntil):
+			blocked = append(blocked, entry)
+		case now.Sub(entry.lastUsed) < geminiWebActiveWindow:
+		case entry.state.IsReady():
+			warm = append(warm, entry)
+		case !entry.failing:
+			cold = append(cold, entry)
+		}
+	}
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			p.balancing = false
+			again := p.rebalanceAgain
+			p.rebalanceAgain = false
+			p.mu.Unlock()
+			if again {
+				p.rebalance(standby)
+			}
+		}()
+		for _, entry := range blocked {
+			releaseIdle(entry.state)
+		}
+		// Most recently used accounts are kept or warmed first.
+		sort.Slice(warm, func(i, j int) bool { return warm[i].lastUsed.After(warm[j].lastUsed) })
+		sort.Slice(cold, func(i, j int) bool { return cold[i].lastUsed.After(cold[j].lastUsed) })
+		for i := standby; i < len(warm); i++ {
+			releaseIdle(warm[i].state)
+		}
+		for i := 0; i < len(cold) && len(warm)+i < standby; i++ {
+			// EnsureClient shares the initialization with a request arriving meanwhile, so
+			// warming holds no lock and never keeps a request waiting.
+			entry := cold[i]
+			err := entry.state.EnsureClient(context.Background())
+			if err != nil {
+				log.Debugf("gemini web pool: failed to warm %s: %v", entry.state.Label(), err)
+				p.mu.Lock()
+				entry.failing = true
+				entry.lastErr = err.Error()
+				p.mu.Unlock()
+				continue
+			}
+			log.Debugf("gemini web pool: warmed standby %s", entry.state.Label())
+		}
+	}()
+}
+
+// releaseIdle drops the client of a state that is not serving a request.
+func releaseIdle(state *geminiwebapi.GeminiWebState) {
+	mutex := state.GetRequestMutex()
+	if !mutex.TryLock() {
+		return
+	}
+	defer mutex.Unlock()
+	state.Release()
+}
+
+// snapshot reports the current state of every pooled account.
+func (p *geminiWebPool) snapshot() []GeminiWebPoolStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	out := make([]GeminiWebPoolStatus, 0, len(p.entries))
+	for id, entry := range p.entries {
+		status := GeminiWebPoolStatus{
+			AuthID:    id,
+			Label:     entry.state.Label(),
+			LastError: entry.lastErr,
+		}
+		if !entry.lastUsed.IsZero() {
+			lastUsed := entry.lastUsed
+			status.LastUsed = &lastUsed
+		}
+		switch {
+		case now.Before(entry.blockedUntil):
+			status.State = GeminiWebPoolBlocked
+			until := entry.blockedUntil
+			status.BlockedUntil = &until
+		case entry.failing:
+			status.State = GeminiWebPoolFailing
+		case now.Sub(entry.lastUsed) < geminiWebActiveWindow:
+			status.State = GeminiWebPoolActive
+		case entry.state.IsReady():
+			status.State = GeminiWebPoolWarm
+		default:
+			status.State = GeminiWebPoolCold
+		}
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
+
+// GeminiWebPoolSnapshot returns the standby pool state of every tracked Gemini Web account.
+func GeminiWebPoolSnapshot() []GeminiWebPoolStatus {
+	return geminiWebStates.snapshot()
+}
@@ -0,0 +1,76 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func poolStatus(authID string) (GeminiWebPoolStatus, bool) {
+	for _, status := range GeminiWebPoolSnapshot() {
+		if status.AuthID == authID {
+			return status, true
+		}
+	}
+	return GeminiWebPoolStatus{}, false
+}
+
+func TestTrackedGeminiWebAuthIsWarmedBeforeItsFirstRequest(t *testing.T) {
+	// The warmed state opens its conversation store under ./conv.
+	t.Chdir(t.TempDir())
+	proxy, hits := countingProxy(t)
+	cfg := &config.Config{}
+	cfg.GeminiWeb.WarmStandby = 1
+	auth := &cliproxyauth.Auth{
+		ID:       "pool-track-test",
+		Provider: "gemini-web",
+		ProxyURL: proxy.URL,
+		Metadata: map[string]any{"secure_1psid": "psid", "secure_1psidts": "psidts"},
+	}
+	t.Cleanup(func() { ReleaseGeminiWebState(auth.ID) })
+
+	TrackGeminiWebAuth(cfg, auth)
+	if _, ok := poolStatus(auth.ID); !ok {
+		t.Fatal("tracked account missing from the pool")
+	}
+	// The proxy answers every call with 502, so the warm-up fails and marks the account.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := poolStatus(auth.ID)
+		if status.State == GeminiWebPoolFailing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %q, want the standby warm-up attempted", status.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hits.Load() == 0 {
+		t.Fatal("warm-up never reached the upstream")
+	}
+}
+
+func TestTrackGeminiWebAuthSkipsDisabledAccounts(t *testing.T) {
+	auth := &cliproxyauth.Auth{
+		ID:       "pool-disabled-test",
+		Provider: "gemini-web",
+		Disabled: true,
+		Metadata: map[string]any{"secure_1psid": "psid", "secure_1psidts": "psidts"},
+	}
+	t.Cleanup(func() { ReleaseGeminiWebState(auth.ID) })
+	TrackGeminiWebAuth(&config.Config{}, auth)
+	if _, ok := poolStatus(auth.ID); ok {
+		t.Fatal("disabled account added to the pool")
+	}
+}
+
+func TestRebalanceRequestedDuringAPassRunsAgain(t *testing.T) {
+	p := &geminiWebPool{entries: make(map[string]*geminiWebPoolEntry), egressBlocks: make(map[string]time.Time)}
+	p.balancing = true
+	p.rebalance(1)
+	if !p.rebalanceAgain {
+		t.Fatal("a pass requested during another was dropped")
+	}
+}
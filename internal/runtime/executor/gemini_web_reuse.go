@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	geminiwebapi "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// contextReuseHeader reports how much of the history of a Gemini Web request the upstream
+// conversation already held.
+const contextReuseHeader = "X-CLIProxy-Context-Reuse"
+
+// reportContextReuse attaches the conversation reuse figures of a Gemini Web request to its
+// usage record and response header, and returns them for the response body.
+func reportContextReuse(ctx context.Context, reporter *usageReporter, stats geminiwebapi.ReuseStats) *usage.ContextReuse {
+	reuse := &usage.ContextReuse{
+		Mode:            stats.Mode,
+		MatchedMessages: int64(stats.Matched),
+		ResentMessages:  int64(stats.Resent),
+		TokensSaved:     int64(stats.TokensSaved),
+	}
+	reporter.contextReuse = reuse
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(contextReuseHeader, fmt.Sprintf("%s; matched=%d; resent=%d; tokens-saved=%d", reuse.Mode, reuse.MatchedMessages, reuse.ResentMessages, reuse.TokensSaved))
+	}
+	return reuse
+}
+
+// clearContextReuse removes the reuse header an earlier attempt of the request set, so a
+// retry on another account or provider does not report its figures.
+func clearContextReuse(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && !ginCtx.Writer.Written() {
+		ginCtx.Writer.Header().Del(contextReuseHeader)
+	}
+}
+
+// withContextReuse adds reuse to a non-streaming response body as its context_reuse field.
+// Bodies that are not a JSON object are returned unchanged.
+func withContextReuse(body []byte, reuse *usage.ContextReuse) []byte {
+	if reuse == nil || !gjson.ParseBytes(body).IsObject() {
+		return body
+	}
+	out, err := sjson.SetBytes(body, "context_reuse", reuse)
+	if err != nil {
+		return body
+	}
+	return out
+}
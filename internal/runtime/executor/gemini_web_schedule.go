@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultGeminiWebRefreshInterval = 540 * time.Second
+	defaultGeminiWebPersistInterval = 10800 * time.Second
+	maxGeminiWebIntervalJitter      = 50
+)
+
+// geminiWebJitter returns a factor in [-1, 1] for the schedule named by key. It is derived
+// from key rather than drawn at random, so an account keeps its schedule across refreshes and
+// restarts while different accounts spread out.
+func geminiWebJitter(key string) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return float64(h.Sum64()%2001)/1000 - 1
+}
+
+// jitterInterval spreads base by up to percent of itself according to key.
+func jitterInterval(base time.Duration, percent int, key string) time.Duration {
+	percent = min(max(percent, 0), maxGeminiWebIntervalJitter)
+	offset := float64(base) * float64(percent) / 100 * geminiWebJitter(key)
+	return (base + time.Duration(offset)).Round(time.Second)
+}
+
+// GeminiWebRefreshInterval returns the time between background refreshes of a Gemini Web
+// account under cfg, by auth ID, for the auth manager's refresh loop.
+func GeminiWebRefreshInterval(cfg *config.Config) func(id string) time.Duration {
+	return func(id string) time.Duration { return geminiWebRefreshInterval(cfg, id) }
+}
+
+// geminiWebRefreshInterval returns the time between background refreshes of the account id.
+func geminiWebRefreshInterval(cfg *config.Config, id string) time.Duration {
+	base := defaultGeminiWebRefreshInterval
+	jitter := 0
+	if cfg != nil {
+		if cfg.GeminiWeb.RefreshInterval > 0 {
+			base = time.Duration(cfg.GeminiWeb.RefreshInterval) * time.Second
+		}
+		jitter = cfg.GeminiWeb.IntervalJitter
+	}
+	return jitterInterval(base, jitter, id+"/refresh")
+}
+
+// geminiWebPersistInterval returns the minimum time between auth file writes of the account
+// id while its cookies are unchanged.
+func geminiWebPersistInterval(cfg *config.Config, id string) time.Duration {
+	base := defaultGeminiWebPersistInterval
+	jitter := 0
+	if cfg != nil {
+		if cfg.GeminiWeb.PersistInterval > 0 {
+			base = time.Duration(cfg.GeminiWeb.PersistInterval) * time.Second
+		}
+		jitter = cfg.GeminiWeb.IntervalJitter
+	}
+	return jitterInterval(base, jitter, id+"/persist")
+}
+
+// geminiWebPersistDue reports whether a refresh that left the cookies unchanged should still
+// be written, because the auth file was last written at least the persist interval ago.
+func geminiWebPersistDue(cfg *config.Config, id string, metadata map[string]any, now time.Time) bool {
+	interval := geminiWebPersistInterval(cfg, id)
+	raw, _ := metadata["last_refresh"].(string)
+	last, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return true
+	}
+	return now.Sub(last) >= interval
+}
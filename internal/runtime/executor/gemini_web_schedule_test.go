@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestGeminiWebIntervalsKeepTheCurrentDefaults(t *testing.T) {
+	for _, cfg := range []*config.Config{nil, {}} {
+		if got := geminiWebRefreshInterval(cfg, "a.json"); got != 540*time.Second {
+			t.Errorf("refresh interval = %v, want 540s", got)
+		}
+		if got := geminiWebPersistInterval(cfg, "a.json"); got != 10800*time.Second {
+			t.Errorf("persist interval = %v, want 10800s", got)
+		}
+	}
+}
+
+func TestGeminiWebIntervalsHonourTheConfig(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.GeminiWeb.RefreshInterval = 300
+	cfg.GeminiWeb.PersistInterval = 3600
+	if got := GeminiWebRefreshInterval(cfg)("a.json"); got != 300*time.Second {
+		t.Errorf("refresh interval = %v, want 300s", got)
+	}
+	if got := geminiWebPersistInterval(cfg, "a.json"); got != time.Hour {
+		t.Errorf("persist interval = %v, want 1h", got)
+	}
+}
+
+func TestGeminiWebJitterSpreadsAccounts(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.GeminiWeb.RefreshInterval = 1000
+	cfg.GeminiWeb.IntervalJitter = 20
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("account-%d.json", i)
+		got := geminiWebRefreshInterval(cfg, id)
+		if got < 800*time.Second || got > 1200*time.Second {
+			t.Fatalf("refresh interval of %s = %v, outside 1000s ± 20%%", id, got)
+		}
+		if again := geminiWebRefreshInterval(cfg, id); again != got {
+			t.Fatalf("refresh interval of %s changed from %v to %v", id, got, again)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 15 {
+		t.Fatalf("20 accounts share %d refresh intervals, want them spread out", len(seen))
+	}
+}
+
+func TestGeminiWebPersistDue(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.GeminiWeb.PersistInterval = 3600
+	now := time.Now()
+	recent := map[string]any{"last_refresh": now.Add(-10 * time.Minute).Format(time.RFC3339)}
+	stale := map[string]any{"last_refresh": now.Add(-2 * time.Hour).Format(time.RFC3339)}
+	if geminiWebPersistDue(cfg, "a.json", recent, now) {
+		t.Error("persist due 10 minutes after the last write")
+	}
+	if !geminiWebPersistDue(cfg, "a.json", stale, now) {
+		t.Error("persist not due 2 hours after the last write")
+	}
+	if !geminiWebPersistDue(cfg, "a.json", map[string]any{}, now) {
+		t.Error("persist not due without a recorded write")
+	}
+}
@@ -3,39 +3,88 @@ package executor
 import (
 	"bytes"
 	"context"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/errorlog"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
+// recordsExchange reports whether upstream exchanges are kept for the request log or for
+// capturing.
+func recordsExchange(cfg *config.Config) bool {
+	return cfg != nil && (cfg.RequestLog || cfg.Capture.Enable)
+}
+
 // recordAPIRequest stores the upstream request payload in Gin context for request logging.
 func recordAPIRequest(ctx context.Context, cfg *config.Config, payload []byte) {
-	if cfg == nil || !cfg.RequestLog || len(payload) == 0 {
+	if !recordsExchange(cfg) || len(payload) == 0 {
 		return
 	}
 	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
 		ginCtx.Set("API_REQUEST", bytes.Clone(payload))
+		// Responses of earlier attempts stay in the request log; captures start here.
+		existing, _ := ginCtx.Get("API_RESPONSE")
+		previous, _ := existing.([]byte)
+		ginCtx.Set("API_RESPONSE_OFFSET", len(previous))
 	}
 }
 
 // appendAPIResponseChunk appends an upstream response chunk to Gin context for request logging.
+// Every chunk, the first included, ends with a blank line, which replays split streams on.
 func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byte) {
-	if cfg == nil || !cfg.RequestLog {
+	if !recordsExchange(cfg) {
 		return
 	}
-	data := bytes.TrimSpace(bytes.Clone(chunk))
+	data := bytes.TrimSpace(chunk)
 	if len(data) == 0 {
 		return
 	}
 	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
-		if existing, exists := ginCtx.Get("API_RESPONSE"); exists {
-			if prev, okBytes := existing.([]byte); okBytes {
-				prev = append(prev, data...)
-				prev = append(prev, []byte("\n\n")...)
-				ginCtx.Set("API_RESPONSE", prev)
-				return
-			}
+		existing, _ := ginCtx.Get("API_RESPONSE")
+		prev, _ := existing.([]byte)
+		prev = append(prev, data...)
+		ginCtx.Set("API_RESPONSE", append(prev, "\n\n"...))
+	}
+}
+
+// upstreamStatusErr builds the statusErr for a failed upstream response and records the
+// exchange in the per-auth error log.
+func upstreamStatusErr(ctx context.Context, auth *cliproxyauth.Auth, resp *http.Response, body []byte) statusErr {
+	recordUpstreamError(ctx, auth, endpointOf(resp), resp.StatusCode, string(body))
+	return newStatusErr(resp, body)
+}
+
+// recordUpstreamError stores a failed upstream exchange for auth. The correlation ID is
+// taken from the client's X-Request-ID header when one was sent.
+func recordUpstreamError(ctx context.Context, auth *cliproxyauth.Auth, endpoint string, status int, body string) {
+	if auth == nil || auth.ID == "" {
+		return
+	}
+	entry := errorlog.Entry{
+		Provider:   auth.Provider,
+		Endpoint:   endpoint,
+		StatusCode: status,
+		Body:       body,
+	}
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			entry.CorrelationID = strings.TrimSpace(ginCtx.GetHeader("X-Request-ID"))
 		}
-		ginCtx.Set("API_RESPONSE", data)
 	}
+	errorlog.Default().Record(auth.ID, entry)
+}
+
+// endpointOf returns the scheme, host and path of the request behind resp, leaving out the
+// query string which may carry credentials.
+func endpointOf(resp *http.Response) string {
+	if resp == nil || resp.Request == nil || resp.Request.URL == nil {
+		return ""
+	}
+	u := *resp.Request.URL
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
 }
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelversion"
+	"github.com/tidwall/gjson"
+)
+
+// observeModelIdentity passes the upstream identity extract finds in data, a response body
+// or stream line, to the model version tracker, under the model the attempt asked for so a
+// fallback model is not mistaken for a new version of the requested one. Only the first
+// identity of a request is used, so streams stop being inspected once it has been seen.
+func (r *usageReporter) observeModelIdentity(data []byte, extract func([]byte) string) {
+	if r == nil || r.identitySeen {
+		return
+	}
+	identity := extract(data)
+	if identity == "" {
+		return
+	}
+	r.identitySeen = true
+	model := r.model
+	if r.servedModel != "" {
+		model = r.servedModel
+	}
+	modelversion.Default().Observe(r.provider, model, identity)
+}
+
+// geminiModelIdentity returns the modelVersion of a Gemini response or chunk, also when
+// Gemini CLI wraps it in "response".
+func geminiModelIdentity(data []byte) string {
+	payload := jsonPayload(data)
+	if v := gjson.GetBytes(payload, "modelVersion").String(); v != "" {
+		return v
+	}
+	return gjson.GetBytes(payload, "response.modelVersion").String()
+}
+
+// claudeModelIdentity returns the model of a Claude message, or of the message_start event
+// of a stream.
+func claudeModelIdentity(data []byte) string {
+	payload := jsonPayload(data)
+	if gjson.GetBytes(payload, "type").String() == "message_start" {
+		return gjson.GetBytes(payload, "message.model").String()
+	}
+	return gjson.GetBytes(payload, "model").String()
+}
+
+// openAIModelIdentity returns the model of an OpenAI chat completion or chunk, followed by
+// its system_fingerprint when there is one.
+func openAIModelIdentity(data []byte) string {
+	payload := jsonPayload(data)
+	model := gjson.GetBytes(payload, "model").String()
+	if model == "" {
+		return ""
+	}
+	if fingerprint := gjson.GetBytes(payload, "system_fingerprint").String(); fingerprint != "" {
+		return model + " " + fingerprint
+	}
+	return model
+}
+
+// codexModelIdentity returns the model of a Responses API response event.
+func codexModelIdentity(data []byte) string {
+	return gjson.GetBytes(jsonPayload(data), "response.model").String()
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelversion"
+)
+
+func TestObserveModelIdentityRecordsFallbackUnderServedModel(t *testing.T) {
+	tracker := modelversion.Default()
+	tracker.Configure(true, t.TempDir(), "")
+	t.Cleanup(func() { tracker.Configure(false, "", "") })
+
+	requested := &usageReporter{provider: "gemini-cli", model: "gemini-2.5-pro"}
+	requested.observeModelIdentity([]byte(`{"response":{"modelVersion":"gemini-2.5-pro"}}`), geminiModelIdentity)
+	fallback := &usageReporter{provider: "gemini-cli", model: "gemini-2.5-pro", servedModel: "gemini-2.5-pro-preview-05-06"}
+	fallback.observeModelIdentity([]byte(`{"response":{"modelVersion":"gemini-2.5-pro-preview-05-06"}}`), geminiModelIdentity)
+
+	identities := map[string]string{}
+	for _, entry := range tracker.Entries() {
+		if entry.Provider == "gemini-cli" {
+			identities[entry.Model] = entry.Identity
+			if len(entry.Changes) != 0 {
+				t.Fatalf("fallback recorded as a change of %s: %+v", entry.Model, entry.Changes)
+			}
+		}
+	}
+	if identities["gemini-2.5-pro"] != "gemini-2.5-pro" || identities["gemini-2.5-pro-preview-05-06"] != "gemini-2.5-pro-preview-05-06" {
+		t.Fatalf("identities = %v", identities)
+	}
+}
+
+func TestModelIdentityExtraction(t *testing.T) {
+	cases := []struct {
+		name    string
+		extract func([]byte) string
+		data    string
+		want    string
+	}{
+		{"gemini", geminiModelIdentity, `{"modelVersion":"gemini-2.5-pro-002"}`, "gemini-2.5-pro-002"},
+		{"gemini cli", geminiModelIdentity, `data: {"response":{"modelVersion":"gemini-2.5-flash"}}`, "gemini-2.5-flash"},
+		{"claude message_start", claudeModelIdentity, `data: {"type":"message_start","message":{"model":"claude-sonnet-4-20250514"}}`, "claude-sonnet-4-20250514"},
+		{"claude message", claudeModelIdentity, `{"type":"message","model":"claude-opus-4-20250514"}`, "claude-opus-4-20250514"},
+		{"openai fingerprint", openAIModelIdentity, `{"model":"gpt-4o","system_fingerprint":"fp_1"}`, "gpt-4o fp_1"},
+		{"openai model only", openAIModelIdentity, `{"model":"gpt-4o"}`, "gpt-4o"},
+		{"openai fingerprint only", openAIModelIdentity, `{"system_fingerprint":"fp_1"}`, ""},
+		{"codex", codexModelIdentity, `data: {"type":"response.created","response":{"model":"gpt-5"}}`, "gpt-5"},
+		{"empty", geminiModelIdentity, ``, ""},
+		{"done marker", openAIModelIdentity, `data: [DONE]`, ""},
+		{"no identity", claudeModelIdentity, `{"type":"content_block_delta"}`, ""},
+	}
+	for _, tc := range cases {
+		if got := tc.extract([]byte(tc.data)); got != tc.want {
+			t.Errorf("%s: identity = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestObserveModelIdentityIgnoresResponsesWithoutOne(t *testing.T) {
+	tracker := modelversion.Default()
+	tracker.Configure(true, t.TempDir(), "")
+	t.Cleanup(func() { tracker.Configure(false, "", "") })
+
+	reporter := &usageReporter{provider: "openai-compat-identity-test", model: "m"}
+	reporter.observeModelIdentity([]byte(`{"model":"m-v1"}`), openAIModelIdentity)
+	reporter.observeModelIdentity([]byte(`{"choices":[]}`), openAIModelIdentity)
+	reporter.observeModelIdentity(nil, openAIModelIdentity)
+
+	for _, entry := range tracker.Entries() {
+		if entry.Provider == "openai-compat-identity-test" && (entry.Identity != "m-v1" || len(entry.Changes) != 0) {
+			t.Fatalf("entry = %+v, want m-v1 kept without changes", entry)
+		}
+	}
+}
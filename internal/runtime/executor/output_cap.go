@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// outputTokenFields lists the body fields carrying the output token limit per upstream
+// request format. Every field present is clamped; when none is, the first one is set.
+// Codex is absent because its backend rejects token limits.
+var outputTokenFields = map[string][]string{
+	"openai":     {"max_tokens", "max_completion_tokens"},
+	"claude":     {"max_tokens"},
+	"gemini":     {"generationConfig.maxOutputTokens"},
+	"gemini-cli": {"request.generationConfig.maxOutputTokens"},
+}
+
+// clampOutputTokens holds the output token limit of body to limit. It returns the updated
+// body, the largest limit the client asked for (zero when it set none) and whether the body
+// changed.
+func clampOutputTokens(body []byte, fields []string, limit int) ([]byte, int64, bool) {
+	if limit <= 0 || len(fields) == 0 {
+		return body, 0, false
+	}
+	var requested int64
+	changed := false
+	present := false
+	for _, field := range fields {
+		value := gjson.GetBytes(body, field)
+		if !value.Exists() || value.Type == gjson.Null {
+			continue
+		}
+		present = true
+		asked := value.Int()
+		if asked > requested {
+			requested = asked
+		}
+		if asked > 0 && asked <= int64(limit) {
+			continue
+		}
+		if updated, err := sjson.SetBytes(body, field, limit); err == nil {
+			body = updated
+			changed = true
+		}
+	}
+	if !present {
+		if updated, err := sjson.SetBytes(body, fields[0], limit); err == nil {
+			body = updated
+			changed = true
+		}
+	}
+	return body, requested, changed
+}
+
+// applyOutputCap clamps the output token limit of an upstream request body in format to the
+// cap configured for provider and model. A clamp is noted in the request log and carried on
+// the usage record published by reporter.
+func applyOutputCap(ctx context.Context, cfg *config.Config, reporter *usageReporter, provider, format, model string, body []byte) []byte {
+	if cfg == nil {
+		return body
+	}
+	limit := cfg.MaxOutputTokens.Limit(provider, model)
+	fields := outputTokenFields[format]
+	body, requested, changed := clampOutputTokens(body, fields, limit)
+	if !changed {
+		return body
+	}
+	if reporter != nil {
+		reporter.outputCap = int64(limit)
+	}
+	if requested > 0 {
+		logging.RecordOutputCapNote(ctx, fmt.Sprintf("output token limit clamped from %d to %d", requested, limit))
+	} else {
+		logging.RecordOutputCapNote(ctx, fmt.Sprintf("output token limit set to %d", limit))
+	}
+	return body
+}
@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestClampOutputTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		fields    []string
+		limit     int
+		want      map[string]int64
+		requested int64
+		changed   bool
+	}{
+		{name: "above the cap", body: `{"max_tokens":60000}`, fields: outputTokenFields["claude"], limit: 4096,
+			want: map[string]int64{"max_tokens": 4096}, requested: 60000, changed: true},
+		{name: "within the cap", body: `{"max_tokens":100}`, fields: outputTokenFields["claude"], limit: 4096,
+			want: map[string]int64{"max_tokens": 100}, requested: 100},
+		{name: "unset", body: `{"generationConfig":{}}`, fields: outputTokenFields["gemini"], limit: 2048,
+			want: map[string]int64{"generationConfig.maxOutputTokens": 2048}, changed: true},
+		{name: "every field", body: `{"max_tokens":10,"max_completion_tokens":9000}`, fields: outputTokenFields["openai"], limit: 512,
+			want: map[string]int64{"max_tokens": 10, "max_completion_tokens": 512}, requested: 9000, changed: true},
+		{name: "no cap", body: `{"max_tokens":60000}`, fields: outputTokenFields["claude"],
+			want: map[string]int64{"max_tokens": 60000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, requested, changed := clampOutputTokens([]byte(tt.body), tt.fields, tt.limit)
+			if requested != tt.requested || changed != tt.changed {
+				t.Fatalf("requested = %d, changed = %v, want %d, %v", requested, changed, tt.requested, tt.changed)
+			}
+			for field, want := range tt.want {
+				if got := gjson.GetBytes(body, field).Int(); got != want {
+					t.Errorf("%s = %d, want %d in %s", field, got, want, body)
+				}
+			}
+		})
+	}
+}
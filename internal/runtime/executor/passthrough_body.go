@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// passthroughTailSize is how much of a passed-through body is retained to read usage
+// metadata, which Gemini places at the end of the document.
+const passthroughTailSize = 64 << 10
+
+// canPassthroughBody reports whether a successful non-streaming upstream body can be handed
+// to the caller unread: the caller opted in, no translation is needed and neither the
+// request log nor capturing, which record the full body, is on.
+func canPassthroughBody(cfg *config.Config, opts cliproxyexecutor.Options, to string) bool {
+	if !opts.PreferBody || (opts.SourceFormat.String() != to && opts.SourceFormat != sdktranslator.FormatRaw) {
+		return false
+	}
+	return !recordsExchange(cfg)
+}
+
+// passthroughBody forwards an upstream response body while keeping its tail. Usage and the
+// model identity are read from the tail once the body has been fully read or closed; a body
+// that fails midway is published as a failure instead.
+type passthroughBody struct {
+	rc       io.ReadCloser
+	tail     []byte
+	once     sync.Once
+	finished func(tail []byte, err error)
+}
+
+func newPassthroughBody(ctx context.Context, rc io.ReadCloser, reporter *usageReporter) *passthroughBody {
+	return &passthroughBody{
+		rc: rc,
+		finished: func(tail []byte, err error) {
+			if err != nil {
+				reporter.publishFailure(ctx, http.StatusBadGateway)
+				return
+			}
+			reporter.publish(ctx, usageFromTail(tail))
+			reporter.observeModelIdentity(tail, modelVersionFromTail)
+		},
+	}
+}
+
+func (b *passthroughBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	if n > 0 {
+		b.tail = append(b.tail, p[:n]...)
+		if over := len(b.tail) - passthroughTailSize; over > 0 {
+			b.tail = append(b.tail[:0], b.tail[over:]...)
+		}
+	}
+	switch {
+	case err == io.EOF:
+		b.finish(nil)
+	case err != nil:
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *passthroughBody) Close() error {
+	b.finish(nil)
+	return b.rc.Close()
+}
+
+func (b *passthroughBody) finish(err error) {
+	b.once.Do(func() {
+		if b.finished != nil {
+			b.finished(b.tail, err)
+		}
+	})
+}
+
+// modelVersionFromTail extracts the Gemini modelVersion from the end of a response document,
+// which the tail may hold without the document's start.
+func modelVersionFromTail(tail []byte) string {
+	idx := bytes.LastIndex(tail, []byte(`"modelVersion"`))
+	if idx < 0 {
+		return ""
+	}
+	snippet := make([]byte, 0, len(tail)-idx+1)
+	snippet = append(snippet, '{')
+	snippet = append(snippet, tail[idx:]...)
+	return gjson.GetBytes(snippet, "modelVersion").String()
+}
+
+// usageFromTail extracts Gemini usage metadata from the end of a response document,
+// whether it sits at the top level or under the Gemini CLI "response" envelope.
+func usageFromTail(tail []byte) usage.Detail {
+	for _, key := range []string{`"usageMetadata"`, `"usage_metadata"`} {
+		idx := bytes.LastIndex(tail, []byte(key))
+		if idx < 0 {
+			continue
+		}
+		snippet := make([]byte, 0, len(tail)-idx+1)
+		snippet = append(snippet, '{')
+		snippet = append(snippet, tail[idx:]...)
+		return parseGeminiUsage(snippet)
+	}
+	return usage.Detail{}
+}
@@ -0,0 +1,26 @@
+package executor
+
+import "testing"
+
+func TestPassthroughTailParsing(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		tail string
+	}{
+		{name: "gemini", tail: `lo"}]}}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":5,"totalTokenCount":17},"modelVersion":"gemini-2.5-pro-002","responseId":"r"}`},
+		{name: "gemini cli", tail: `lo"}]}}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":5,"totalTokenCount":17},"modelVersion":"gemini-2.5-pro-002"},"traceId":"t"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			detail := usageFromTail([]byte(tt.tail))
+			if detail.InputTokens != 12 || detail.OutputTokens != 5 || detail.TotalTokens != 17 {
+				t.Fatalf("usage = %+v", detail)
+			}
+			if got := modelVersionFromTail([]byte(tt.tail)); got != "gemini-2.5-pro-002" {
+				t.Fatalf("modelVersion = %q", got)
+			}
+		})
+	}
+	if got := modelVersionFromTail([]byte(`{"candidates":[]}`)); got != "" {
+		t.Fatalf("modelVersion without one = %q", got)
+	}
+}
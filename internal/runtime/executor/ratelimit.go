@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAdvisedRetryAfter caps server-advised waits so a malformed header cannot park an
+// account indefinitely.
+const maxAdvisedRetryAfter = 24 * time.Hour
+
+// rateLimitBudgets lists the per-budget remaining/reset header pairs understood by
+// retryAfterFromHeaders. Anthropic reports reset times as RFC 3339 timestamps, OpenAI
+// and compatible providers as Go-style durations such as "6m0s".
+var rateLimitBudgets = []struct {
+	remaining string
+	reset     string
+}{
+	{"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset"},
+	{"anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset"},
+	{"anthropic-ratelimit-input-tokens-remaining", "anthropic-ratelimit-input-tokens-reset"},
+	{"anthropic-ratelimit-output-tokens-remaining", "anthropic-ratelimit-output-tokens-reset"},
+	{"x-ratelimit-remaining-requests", "x-ratelimit-reset-requests"},
+	{"x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens"},
+}
+
+// retryAfterFromHeaders returns the wait advised by a rate-limited upstream response.
+// Retry-After wins when present; otherwise the longest reset among exhausted budgets is
+// used. It returns zero when the headers advise nothing.
+func retryAfterFromHeaders(header http.Header, now time.Time) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if d := parseRetryAfter(header.Get("Retry-After"), now); d > 0 {
+		return capRetryAfter(d)
+	}
+	var longest time.Duration
+	for _, budget := range rateLimitBudgets {
+		remaining := strings.TrimSpace(header.Get(budget.remaining))
+		if remaining == "" {
+			continue
+		}
+		if n, err := strconv.ParseInt(remaining, 10, 64); err != nil || n > 0 {
+			continue
+		}
+		if d := parseRateLimitReset(header.Get(budget.reset), now); d > longest {
+			longest = d
+		}
+	}
+	return capRetryAfter(longest)
+}
+
+// parseRetryAfter parses a Retry-After value given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// parseRateLimitReset parses a reset header given as an RFC 3339 timestamp or a duration.
+func parseRateLimitReset(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at.Sub(now)
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	return 0
+}
+
+func capRetryAfter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	if d > maxAdvisedRetryAfter {
+		return maxAdvisedRetryAfter
+	}
+	return d
+}
+
+// newStatusErr builds a statusErr for a failed upstream response, recording the retry delay
+// its headers advise. Providers send Retry-After and exhausted budgets with other statuses
+// than 429 too, such as 503 or a 403 quota error, so every failure is inspected.
+func newStatusErr(resp *http.Response, body []byte) statusErr {
+	return statusErr{code: resp.StatusCode, msg: string(body), retryAfter: retryAfterFromHeaders(resp.Header, time.Now())}
+}
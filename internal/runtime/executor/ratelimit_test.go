@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterFromHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"42"}}, 42 * time.Second},
+		{"http date", http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}, 90 * time.Second},
+		{"exhausted anthropic budget", http.Header{
+			"Anthropic-Ratelimit-Tokens-Remaining":   {"0"},
+			"Anthropic-Ratelimit-Tokens-Reset":       {now.Add(5 * time.Minute).Format(time.RFC3339)},
+			"Anthropic-Ratelimit-Requests-Remaining": {"10"},
+			"Anthropic-Ratelimit-Requests-Reset":     {now.Add(time.Hour).Format(time.RFC3339)},
+		}, 5 * time.Minute},
+		{"exhausted openai budget", http.Header{
+			"X-Ratelimit-Remaining-Requests": {"0"},
+			"X-Ratelimit-Reset-Requests":     {"6m0s"},
+		}, 6 * time.Minute},
+		{"capped", http.Header{"Retry-After": {"999999"}}, maxAdvisedRetryAfter},
+		{"nothing advised", http.Header{"X-Ratelimit-Remaining-Requests": {"3"}}, 0},
+	}
+	for _, tc := range cases {
+		if got := retryAfterFromHeaders(tc.header, now); got != tc.want {
+			t.Errorf("%s: retry after = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNewStatusErrRecordsRetryAfterForEveryFailure(t *testing.T) {
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusForbidden} {
+		resp := &http.Response{StatusCode: code, Header: http.Header{"Retry-After": {"30"}}}
+		if got := newStatusErr(resp, nil).RetryAfter(); got != 30*time.Second {
+			t.Errorf("status %d: retry after = %s, want 30s", code, got)
+		}
+	}
+}
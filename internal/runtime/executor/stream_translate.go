@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxLoggedStreamLine caps how much of an untranslatable upstream line goes to the log.
+const maxLoggedStreamLine = 2048
+
+// translateStream runs the response translator on one upstream stream line. Translators
+// have no error return and may panic on a malformed upstream event; the panic is turned into
+// a 502 error here, since a dead stream goroutine would end the stream as if it had completed.
+func translateStream(ctx context.Context, from, to sdktranslator.Format, model string, originalRequest, request, line []byte, param *any) (chunks []string, err error) {
+	if to == sdktranslator.FormatRaw {
+		// Raw clients get upstream lines only, not the "[DONE]" marker executors feed in.
+		if string(line) == "[DONE]" {
+			return nil, nil
+		}
+		return []string{string(line)}, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logged := line
+			if len(logged) > maxLoggedStreamLine {
+				logged = logged[:maxLoggedStreamLine]
+			}
+			log.Errorf("failed to translate %s stream event for %s client: %v; upstream line (%d bytes): %s", from, to, r, len(line), logged)
+			chunks = nil
+			err = statusErr{code: http.StatusBadGateway, msg: fmt.Sprintf("failed to translate upstream %s stream event: %v", from, r)}
+		}
+	}()
+	chunks, err = sdktranslator.TranslateStreamWithError(ctx, from, to, model, originalRequest, request, line, param)
+	if err != nil {
+		return nil, translationFailed(err, line)
+	}
+	return chunks, nil
+}
+
+// translateNonStream runs the response translator on a complete upstream response. A
+// conversion that fails or produces no valid JSON becomes a 502 error instead of reaching the
+// client as a broken body.
+func translateNonStream(ctx context.Context, from, to sdktranslator.Format, model string, originalRequest, request, data []byte, param *any) ([]byte, error) {
+	out, err := sdktranslator.TranslateNonStreamWithError(ctx, from, to, model, originalRequest, request, data, param)
+	if err != nil {
+		return nil, translationFailed(err, data)
+	}
+	return []byte(out), nil
+}
+
+// translationFailed logs a failed conversion with the upstream data it was given and returns
+// the error passed on to the client.
+func translationFailed(err error, upstream []byte) error {
+	logged := upstream
+	if len(logged) > maxLoggedStreamLine {
+		logged = logged[:maxLoggedStreamLine]
+	}
+	log.Errorf("%v; upstream data (%d bytes): %s", err, len(upstream), logged)
+	return statusErr{code: http.StatusBadGateway, msg: fmt.Sprintf("failed to translate upstream response: %v", err)}
+}
+
+// failStream ends a stream cut short by err, such as a failed translation or a broken
+// upstream connection. The request is counted as failed in the usage statistics with the
+// status of err and the error is passed on, so the client gets an error event instead of a
+// truncated answer that looks complete.
+func failStream(ctx context.Context, reporter *usageReporter, out chan<- cliproxyexecutor.StreamChunk, err error) {
+	status := http.StatusBadGateway
+	var coder interface{ StatusCode() int }
+	if errors.As(err, &coder) && coder.StatusCode() > 0 {
+		status = coder.StatusCode()
+	}
+	reporter.publishFailure(ctx, status)
+	out <- cliproxyexecutor.StreamChunk{Err: err}
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// strictProviders lists the providers whose upstream answers a request carrying a field it
+// does not define with a 400.
+var strictProviders = map[string]struct{}{
+	"gemini":     {},
+	"gemini-cli": {},
+	"claude":     {},
+	"codex":      {},
+}
+
+// stripsUnknownFields reports whether requests to provider lose the fields their upstream
+// format does not define, following unknown-request-fields and otherwise strictProviders.
+func stripsUnknownFields(cfg *config.Config, provider string) bool {
+	if cfg != nil {
+		switch strings.ToLower(strings.TrimSpace(cfg.UnknownRequestFields[provider])) {
+		case config.UnknownFieldsStrip:
+			return true
+		case config.UnknownFieldsKeep:
+			return false
+		}
+	}
+	_, strict := strictProviders[provider]
+	return strict
+}
+
+// stripUnknownFields removes the top-level fields of an upstream request body in format that
+// the format does not define when provider is set to strip them. Bodies of requests passed
+// through raw, with source format from, go out byte-for-byte.
+func stripUnknownFields(cfg *config.Config, provider string, from sdktranslator.Format, format string, body []byte) []byte {
+	if from == sdktranslator.FormatRaw || !stripsUnknownFields(cfg, provider) {
+		return body
+	}
+	stripped, removed := translator.StripUnknownFields(format, body)
+	if len(removed) > 0 {
+		log.Debugf("%s: dropped unknown request fields %s", provider, strings.Join(removed, ", "))
+	}
+	return stripped
+}
@@ -0,0 +1,22 @@
+package executor
+
+import (
+	"testing"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestStripUnknownFieldsKeepsRawBodies(t *testing.T) {
+	body := []byte(`{"model":"claude-sonnet-4","messages":[],"x_custom":true}`)
+
+	stripped := stripUnknownFields(nil, "claude", sdktranslator.FromString("openai"), "claude", body)
+	if gjson.GetBytes(stripped, "x_custom").Exists() {
+		t.Fatalf("translated body kept unknown field: %s", stripped)
+	}
+
+	raw := stripUnknownFields(nil, "claude", sdktranslator.FormatRaw, "claude", body)
+	if string(raw) != string(body) {
+		t.Fatalf("raw body changed: %s", raw)
+	}
+}
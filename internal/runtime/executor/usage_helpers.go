@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	usagestats "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
@@ -17,9 +19,31 @@ type usageReporter struct {
 	provider    string
 	model       string
 	authID      string
+	authLabel   string
 	apiKey      string
 	requestedAt time.Time
-	once        sync.Once
+	outputCap   int64
+	truncated   bool
+	fingerprint string
+	metadata    map[string]string
+	// tagRef is the reference in the audit tag of the response, empty when it has none.
+	tagRef string
+	// toolSchemaSaved estimates the prompt tokens spared by referring to known tool schemas.
+	toolSchemaSaved int64
+	// contextReuse describes the conversation a Gemini Web request continued.
+	contextReuse *usage.ContextReuse
+	// shadowOf is the request ID of the request a shadow request mirrors, empty otherwise.
+	shadowOf string
+	shadow   bool
+	// identitySeen is set once the response revealed the upstream model identity.
+	identitySeen bool
+	// servedModel is the model an attempt actually asked upstream for when it differs from
+	// model, such as a preview fallback; identities are recorded under it.
+	servedModel string
+	// once guards the usage record and failOnce the failure record, which a stream failing
+	// after it reported usage needs as well.
+	once     sync.Once
+	failOnce sync.Once
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -30,8 +54,23 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 	}
 	if auth != nil {
 		reporter.authID = auth.ID
+		reporter.authLabel = auth.AssignedLabel()
+		logging.RecordRequestAccount(ctx, reporter.authLabel)
 	}
 	reporter.apiKey = apiKeyFromContext(ctx)
+	if shadow, ok := logging.ShadowRequestFrom(ctx); ok {
+		reporter.shadow = true
+		reporter.shadowOf = shadow.PrimaryRequestID
+		reporter.apiKey = shadow.APIKey
+	}
+	reporter.fingerprint = systemFingerprint(provider, model, auth)
+	reporter.metadata = logging.RequestMetadata(ctx)
+	reporter.tagRef = logging.ResponseTagRef(ctx)
+	// Every upstream call starts here, so this is also where the request learns its backend
+	// and where the figures of an earlier attempt are dropped.
+	logging.RecordRequestTarget(ctx, provider, model)
+	clearContextReuse(ctx)
+	logging.RecordSystemFingerprint(ctx, reporter.fingerprint)
 	return reporter
 }
 
@@ -45,17 +84,69 @@ func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
 			detail.TotalTokens = total
 		}
 	}
-	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 {
+	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.CacheCreationTokens == 0 && detail.TotalTokens == 0 {
 		return
 	}
 	r.once.Do(func() {
+		if cost, ok := usagestats.RequestCost(r.provider, r.model, detail); ok {
+			logging.RecordRequestCost(ctx, cost)
+		}
+		// A stream ended by moderation is cancelled; the usage it reported by then is
+		// published once, marked as filtered.
+		status := ""
+		rule := logging.ModerationRule(ctx)
+		if rule != "" {
+			status = usage.StatusContentFiltered
+		}
+		usage.PublishRecord(ctx, usage.Record{
+			Provider:              r.provider,
+			Model:                 r.model,
+			APIKey:                r.apiKey,
+			AuthID:                r.authID,
+			AuthLabel:             r.authLabel,
+			RequestedAt:           r.requestedAt,
+			Detail:                detail,
+			Status:                status,
+			ModerationRule:        rule,
+			OutputCap:             r.outputCap,
+			OutputTruncated:       r.truncated,
+			SystemFingerprint:     r.fingerprint,
+			Metadata:              r.metadata,
+			ResponseTagged:        r.tagRef != "",
+			ResponseTagRef:        r.tagRef,
+			ToolSchemaTokensSaved: r.toolSchemaSaved,
+			ContextReuse:          r.contextReuse,
+			Shadow:                r.shadow,
+			ShadowOf:              r.shadowOf,
+		})
+	})
+}
+
+// publishFailure records the request as a stream failed with status. It is published also
+// when usage was reported before the failure, which the usage record alone would hide; it
+// carries no tokens then, so they are not counted twice.
+func (r *usageReporter) publishFailure(ctx context.Context, status int) {
+	if r == nil {
+		return
+	}
+	r.failOnce.Do(func() {
+		// Usage arriving after the failure no longer makes the request a success.
+		r.once.Do(func() {})
 		usage.PublishRecord(ctx, usage.Record{
-			Provider:    r.provider,
-			Model:       r.model,
-			APIKey:      r.apiKey,
-			AuthID:      r.authID,
-			RequestedAt: r.requestedAt,
-			Detail:      detail,
+			Provider:          r.provider,
+			Model:             r.model,
+			APIKey:            r.apiKey,
+			AuthID:            r.authID,
+			AuthLabel:         r.authLabel,
+			RequestedAt:       r.requestedAt,
+			SystemFingerprint: r.fingerprint,
+			Status:            usage.StatusStreamError,
+			StatusCode:        status,
+			Metadata:          r.metadata,
+			ResponseTagged:    r.tagRef != "",
+			ResponseTagRef:    r.tagRef,
+			Shadow:            r.shadow,
+			ShadowOf:          r.shadowOf,
 		})
 	})
 }
@@ -116,6 +207,8 @@ func parseOpenAIUsage(data []byte) usage.Detail {
 	if reasoning := usageNode.Get("completion_tokens_details.reasoning_tokens"); reasoning.Exists() {
 		detail.ReasoningTokens = reasoning.Int()
 	}
+	detail.AcceptedPredictionTokens = usageNode.Get("completion_tokens_details.accepted_prediction_tokens").Int()
+	detail.RejectedPredictionTokens = usageNode.Get("completion_tokens_details.rejected_prediction_tokens").Int()
 	return detail
 }
 
@@ -139,6 +232,8 @@ func parseOpenAIStreamUsage(line []byte) (usage.Detail, bool) {
 	if reasoning := usageNode.Get("completion_tokens_details.reasoning_tokens"); reasoning.Exists() {
 		detail.ReasoningTokens = reasoning.Int()
 	}
+	detail.AcceptedPredictionTokens = usageNode.Get("completion_tokens_details.accepted_prediction_tokens").Int()
+	detail.RejectedPredictionTokens = usageNode.Get("completion_tokens_details.rejected_prediction_tokens").Int()
 	return detail, true
 }
 
@@ -148,13 +243,10 @@ func parseClaudeUsage(data []byte) usage.Detail {
 		return usage.Detail{}
 	}
 	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
-	}
-	if detail.CachedTokens == 0 {
-		// fall back to creation tokens when read tokens are absent
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
+		InputTokens:         usageNode.Get("input_tokens").Int(),
+		OutputTokens:        usageNode.Get("output_tokens").Int(),
+		CachedTokens:        usageNode.Get("cache_read_input_tokens").Int(),
+		CacheCreationTokens: usageNode.Get("cache_creation_input_tokens").Int(),
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
 	return detail
@@ -170,12 +262,10 @@ func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 		return usage.Detail{}, false
 	}
 	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
-	}
-	if detail.CachedTokens == 0 {
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
+		InputTokens:         usageNode.Get("input_tokens").Int(),
+		OutputTokens:        usageNode.Get("output_tokens").Int(),
+		CachedTokens:        usageNode.Get("cache_read_input_tokens").Int(),
+		CacheCreationTokens: usageNode.Get("cache_creation_input_tokens").Int(),
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
 	return detail, true
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// usageCollector collects the usage records published for one model, until a record for
+// the model with the "-sentinel" suffix arrives.
+type usageCollector struct {
+	model   string
+	mu      sync.Mutex
+	records []usage.Record
+	done    chan struct{}
+}
+
+func collectUsage(model string) *usageCollector {
+	c := &usageCollector{model: model, done: make(chan struct{})}
+	usage.RegisterPlugin(c)
+	return c
+}
+
+func (c *usageCollector) HandleUsage(_ context.Context, record usage.Record) {
+	switch record.Model {
+	case c.model + "-sentinel":
+		close(c.done)
+	case c.model:
+		c.mu.Lock()
+		c.records = append(c.records, record)
+		c.mu.Unlock()
+	}
+}
+
+func (c *usageCollector) wait(t *testing.T) []usage.Record {
+	t.Helper()
+	usage.PublishRecord(context.Background(), usage.Record{Model: c.model + "-sentinel"})
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("usage records were not delivered")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.records
+}
+
+func TestStreamFailureAfterUsageIsRecorded(t *testing.T) {
+	collector := collectUsage("usage-failure-after-model")
+	reporter := newUsageReporter(context.Background(), "gemini", collector.model, nil)
+	reporter.publish(context.Background(), usage.Detail{InputTokens: 10, OutputTokens: 5})
+	reporter.publishFailure(context.Background(), http.StatusBadGateway)
+	reporter.publishFailure(context.Background(), http.StatusBadGateway)
+
+	records := collector.wait(t)
+	if len(records) != 2 {
+		t.Fatalf("records = %d, want the usage record and one failure record", len(records))
+	}
+	if records[0].Status != "" || records[0].Detail.InputTokens != 10 {
+		t.Fatalf("usage record = %+v", records[0])
+	}
+	if records[1].Status != usage.StatusStreamError || records[1].StatusCode != http.StatusBadGateway || records[1].Detail.TotalTokens != 0 {
+		t.Fatalf("failure record = %+v, want a stream error without tokens", records[1])
+	}
+}
+
+func TestUsageAfterStreamFailureIsNotASuccess(t *testing.T) {
+	collector := collectUsage("usage-failure-before-model")
+	reporter := newUsageReporter(context.Background(), "gemini", collector.model, nil)
+	reporter.publishFailure(context.Background(), http.StatusBadGateway)
+	reporter.publish(context.Background(), usage.Detail{InputTokens: 10})
+
+	records := collector.wait(t)
+	if len(records) != 1 || records[0].Status != usage.StatusStreamError {
+		t.Fatalf("records = %+v, want only the failure record", records)
+	}
+}
+
+func TestClaudeUsageKeepsCacheWritesApart(t *testing.T) {
+	body := []byte(`{"usage":{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":200}}`)
+	detail := parseClaudeUsage(body)
+	if detail.CachedTokens != 0 || detail.CacheCreationTokens != 200 {
+		t.Fatalf("detail = %+v, want the cache write apart from cache reads", detail)
+	}
+	line := []byte(`data: {"type":"message_delta","usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":30,"cache_creation_input_tokens":200}}`)
+	detail, _ = parseClaudeStreamUsage(line)
+	if detail.CachedTokens != 30 || detail.CacheCreationTokens != 200 {
+		t.Fatalf("stream detail = %+v", detail)
+	}
+}
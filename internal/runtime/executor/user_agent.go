@@ -0,0 +1,23 @@
+package executor
+
+import (
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// applyUserAgent sets the user agent configured for the first of providers that has one,
+// replacing the built-in value, and logs the user agent the request goes out with.
+func applyUserAgent(cfg *config.Config, r *http.Request, providers ...string) {
+	if r == nil {
+		return
+	}
+	for _, provider := range providers {
+		if userAgent := cfg.UserAgent(provider); userAgent != "" {
+			r.Header.Set("User-Agent", userAgent)
+			break
+		}
+	}
+	log.Debugf("%s upstream user agent: %q", providers[0], r.Header.Get("User-Agent"))
+}
@@ -0,0 +1,454 @@
+package transcript
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// normalizeRole maps the role names of the client formats to those of a transcript.
+func normalizeRole(role string) string {
+	switch role {
+	case "developer", "system":
+		return RoleSystem
+	case "model", "assistant":
+		return RoleAssistant
+	case "tool", "function":
+		return RoleTool
+	}
+	return RoleUser
+}
+
+// joinedText concatenates the text of a content value given as a string or as parts.
+func joinedText(content gjson.Result) string {
+	if content.Type == gjson.String {
+		return content.String()
+	}
+	var texts []string
+	for _, part := range content.Array() {
+		if text := part.Get("text"); text.Exists() {
+			texts = append(texts, text.String())
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// OpenAI Chat Completions.
+
+func openAIChatRequest(body gjson.Result) []Message {
+	var messages []Message
+	for _, item := range body.Get("messages").Array() {
+		msg := openAIChatMessage(item)
+		if len(msg.Parts) > 0 {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+func openAIChatMessage(item gjson.Result) Message {
+	msg := Message{Role: normalizeRole(item.Get("role").String())}
+	if msg.Role == RoleTool {
+		msg.Parts = append(msg.Parts, Part{Type: PartToolResult, CallID: item.Get("tool_call_id").String(), Name: item.Get("name").String(), Text: joinedText(item.Get("content"))})
+		return msg
+	}
+	content := item.Get("content")
+	if content.Type == gjson.String {
+		if part, ok := textPart(content.String()); ok {
+			msg.Parts = append(msg.Parts, part)
+		}
+	}
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "text":
+			if p, ok := textPart(part.Get("text").String()); ok {
+				msg.Parts = append(msg.Parts, p)
+			}
+		case "image_url":
+			msg.Parts = append(msg.Parts, urlAttachment("image", part.Get("image_url.url").String()))
+		case "input_audio":
+			msg.Parts = append(msg.Parts, inlineAttachment("audio/"+part.Get("input_audio.format").String(), part.Get("input_audio.data").String()))
+		case "file":
+			if data := part.Get("file.file_data").String(); data != "" {
+				msg.Parts = append(msg.Parts, urlAttachment("", data))
+			} else {
+				msg.Parts = append(msg.Parts, Part{Type: PartAttachment, Name: part.Get("file.file_id").String()})
+			}
+		}
+	}
+	for _, call := range item.Get("tool_calls").Array() {
+		msg.Parts = append(msg.Parts, Part{
+			Type:      PartToolCall,
+			CallID:    call.Get("id").String(),
+			Name:      call.Get("function.name").String(),
+			Arguments: call.Get("function.arguments").String(),
+		})
+	}
+	return msg
+}
+
+func openAIChatResponse(body []byte) *Message {
+	if !isStream(body) {
+		msg := openAIChatMessage(gjson.GetBytes(body, "choices.0.message"))
+		msg.Role = RoleAssistant
+		return &msg
+	}
+	msg := &Message{Role: RoleAssistant}
+	calls := make(map[int64]*Part)
+	for _, event := range streamEvents(body) {
+		delta := event.Get("choices.0.delta")
+		if part, ok := textPart(delta.Get("content").String()); ok {
+			appendPart(msg, part)
+		}
+		for _, call := range delta.Get("tool_calls").Array() {
+			index := call.Get("index").Int()
+			part, ok := calls[index]
+			if !ok {
+				part = &Part{Type: PartToolCall}
+				calls[index] = part
+			}
+			if id := call.Get("id").String(); id != "" {
+				part.CallID = id
+			}
+			if name := call.Get("function.name").String(); name != "" {
+				part.Name = name
+			}
+			part.Arguments += call.Get("function.arguments").String()
+		}
+	}
+	appendIndexed(msg, calls)
+	return msg
+}
+
+// appendIndexed adds the parts assembled from a stream to msg in the order of their index.
+func appendIndexed(msg *Message, parts map[int64]*Part) {
+	indexes := make([]int64, 0, len(parts))
+	for index := range parts {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	for _, index := range indexes {
+		appendPart(msg, *parts[index])
+	}
+}
+
+// OpenAI Completions.
+
+func completionsRequest(body gjson.Result) []Message {
+	prompt := body.Get("prompt")
+	text := prompt.String()
+	if prompt.IsArray() {
+		var prompts []string
+		for _, p := range prompt.Array() {
+			prompts = append(prompts, p.String())
+		}
+		text = strings.Join(prompts, "\n")
+	}
+	if text == "" {
+		return nil
+	}
+	return []Message{{Role: RoleUser, Parts: []Part{{Type: PartText, Text: text}}}}
+}
+
+func completionsResponse(body []byte) *Message {
+	msg := &Message{Role: RoleAssistant}
+	if !isStream(body) {
+		if part, ok := textPart(gjson.GetBytes(body, "choices.0.text").String()); ok {
+			msg.Parts = append(msg.Parts, part)
+		}
+		return msg
+	}
+	for _, event := range streamEvents(body) {
+		if part, ok := textPart(event.Get("choices.0.text").String()); ok {
+			appendPart(msg, part)
+		}
+	}
+	return msg
+}
+
+// OpenAI Responses.
+
+func responsesRequest(body gjson.Result) []Message {
+	var messages []Message
+	if part, ok := textPart(body.Get("instructions").String()); ok {
+		messages = append(messages, Message{Role: RoleSystem, Parts: []Part{part}})
+	}
+	input := body.Get("input")
+	if input.Type == gjson.String {
+		if part, ok := textPart(input.String()); ok {
+			messages = append(messages, Message{Role: RoleUser, Parts: []Part{part}})
+		}
+		return messages
+	}
+	for _, item := range input.Array() {
+		if msg, ok := responsesItem(item); ok {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// responsesItem converts one input or output item of the Responses API.
+func responsesItem(item gjson.Result) (Message, bool) {
+	switch item.Get("type").String() {
+	case "function_call":
+		return Message{Role: RoleAssistant, Parts: []Part{{
+			Type:      PartToolCall,
+			CallID:    item.Get("call_id").String(),
+			Name:      item.Get("name").String(),
+			Arguments: item.Get("arguments").String(),
+		}}}, true
+	case "function_call_output":
+		return Message{Role: RoleTool, Parts: []Part{{
+			Type:   PartToolResult,
+			CallID: item.Get("call_id").String(),
+			Text:   joinedText(item.Get("output")),
+		}}}, true
+	case "message", "":
+		if !item.Get("role").Exists() {
+			return Message{}, false
+		}
+	default:
+		return Message{}, false
+	}
+	msg := Message{Role: normalizeRole(item.Get("role").String())}
+	content := item.Get("content")
+	if content.Type == gjson.String {
+		if part, ok := textPart(content.String()); ok {
+			msg.Parts = append(msg.Parts, part)
+		}
+	}
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "input_text", "output_text", "text":
+			if p, ok := textPart(part.Get("text").String()); ok {
+				msg.Parts = append(msg.Parts, p)
+			}
+		case "input_image":
+			if url := part.Get("image_url").String(); url != "" {
+				msg.Parts = append(msg.Parts, urlAttachment("image", url))
+			} else {
+				msg.Parts = append(msg.Parts, Part{Type: PartAttachment, MimeType: "image", Name: part.Get("file_id").String()})
+			}
+		case "input_file":
+			if data := part.Get("file_data").String(); data != "" {
+				attachment := urlAttachment("", data)
+				attachment.Name = part.Get("filename").String()
+				msg.Parts = append(msg.Parts, attachment)
+			} else {
+				msg.Parts = append(msg.Parts, Part{Type: PartAttachment, Name: part.Get("file_id").String()})
+			}
+		}
+	}
+	return msg, len(msg.Parts) > 0
+}
+
+// responsesResponse returns the output of a Responses API response and its ID. Streams are
+// read from their final event, which carries the whole response, falling back to the text
+// deltas when the stream ended early.
+func responsesResponse(body []byte) (*Message, string) {
+	response := gjson.ParseBytes(body)
+	var deltas []string
+	if isStream(body) {
+		response = gjson.Result{}
+		for _, event := range streamEvents(body) {
+			switch event.Get("type").String() {
+			case "response.completed", "response.incomplete", "response.failed":
+				response = event.Get("response")
+			case "response.output_text.delta":
+				deltas = append(deltas, event.Get("delta").String())
+			}
+		}
+	}
+	msg := &Message{Role: RoleAssistant}
+	if !response.Exists() {
+		if part, ok := textPart(strings.Join(deltas, "")); ok {
+			msg.Parts = append(msg.Parts, part)
+		}
+		return msg, ""
+	}
+	for _, item := range response.Get("output").Array() {
+		if out, ok := responsesItem(item); ok {
+			for _, part := range out.Parts {
+				appendPart(msg, part)
+			}
+		}
+	}
+	return msg, response.Get("id").String()
+}
+
+// Claude Messages.
+
+func claudeRequest(body gjson.Result) []Message {
+	var messages []Message
+	if part, ok := textPart(joinedText(body.Get("system"))); ok {
+		messages = append(messages, Message{Role: RoleSystem, Parts: []Part{part}})
+	}
+	for _, item := range body.Get("messages").Array() {
+		msg := Message{Role: normalizeRole(item.Get("role").String())}
+		content := item.Get("content")
+		if content.Type == gjson.String {
+			if part, ok := textPart(content.String()); ok {
+				msg.Parts = append(msg.Parts, part)
+			}
+		}
+		for _, block := range content.Array() {
+			if part, ok := claudeBlock(block); ok {
+				msg.Parts = append(msg.Parts, part)
+			}
+		}
+		if len(msg.Parts) > 0 {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+func claudeBlock(block gjson.Result) (Part, bool) {
+	switch block.Get("type").String() {
+	case "text":
+		return textPart(block.Get("text").String())
+	case "image", "document":
+		source := block.Get("source")
+		if source.Get("type").String() == "url" {
+			return urlAttachment(source.Get("media_type").String(), source.Get("url").String()), true
+		}
+		return inlineAttachment(source.Get("media_type").String(), source.Get("data").String()), true
+	case "tool_use":
+		return Part{Type: PartToolCall, CallID: block.Get("id").String(), Name: block.Get("name").String(), Arguments: block.Get("input").Raw}, true
+	case "tool_result":
+		return Part{Type: PartToolResult, CallID: block.Get("tool_use_id").String(), Text: joinedText(block.Get("content"))}, true
+	}
+	return Part{}, false
+}
+
+func claudeResponse(body []byte) *Message {
+	msg := &Message{Role: RoleAssistant}
+	if !isStream(body) {
+		for _, block := range gjson.GetBytes(body, "content").Array() {
+			if part, ok := claudeBlock(block); ok {
+				msg.Parts = append(msg.Parts, part)
+			}
+		}
+		return msg
+	}
+	blocks := make(map[int64]*Part)
+	for _, event := range streamEvents(body) {
+		index := event.Get("index").Int()
+		switch event.Get("type").String() {
+		case "content_block_start":
+			block := event.Get("content_block")
+			switch block.Get("type").String() {
+			case "text":
+				blocks[index] = &Part{Type: PartText, Text: block.Get("text").String()}
+			case "tool_use":
+				blocks[index] = &Part{Type: PartToolCall, CallID: block.Get("id").String(), Name: block.Get("name").String()}
+			}
+		case "content_block_delta":
+			part, ok := blocks[index]
+			if !ok {
+				continue
+			}
+			delta := event.Get("delta")
+			switch delta.Get("type").String() {
+			case "text_delta":
+				part.Text += delta.Get("text").String()
+			case "input_json_delta":
+				part.Arguments += delta.Get("partial_json").String()
+			}
+		}
+	}
+	appendIndexed(msg, blocks)
+	return msg
+}
+
+// Gemini generateContent.
+
+func geminiRequest(body gjson.Result) []Message {
+	var messages []Message
+	system := body.Get("systemInstruction")
+	if !system.Exists() {
+		system = body.Get("system_instruction")
+	}
+	if part, ok := textPart(joinedText(system.Get("parts"))); ok {
+		messages = append(messages, Message{Role: RoleSystem, Parts: []Part{part}})
+	}
+	for _, content := range body.Get("contents").Array() {
+		msg := geminiContent(content)
+		if len(msg.Parts) > 0 {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+func geminiContent(content gjson.Result) Message {
+	msg := Message{Role: normalizeRole(content.Get("role").String())}
+	for _, part := range content.Get("parts").Array() {
+		if p, ok := geminiPart(part); ok {
+			appendPart(&msg, p)
+		}
+	}
+	return msg
+}
+
+func geminiPart(part gjson.Result) (Part, bool) {
+	if part.Get("thought").Bool() {
+		return Part{}, false
+	}
+	if text := part.Get("text"); text.Exists() {
+		return textPart(text.String())
+	}
+	for _, key := range []string{"inlineData", "inline_data"} {
+		if data := part.Get(key); data.Exists() {
+			mimeType := data.Get("mimeType").String()
+			if mimeType == "" {
+				mimeType = data.Get("mime_type").String()
+			}
+			return inlineAttachment(mimeType, data.Get("data").String()), true
+		}
+	}
+	for _, key := range []string{"fileData", "file_data"} {
+		if data := part.Get(key); data.Exists() {
+			uri := data.Get("fileUri").String()
+			if uri == "" {
+				uri = data.Get("file_uri").String()
+			}
+			return urlAttachment(data.Get("mimeType").String(), uri), true
+		}
+	}
+	if call := part.Get("functionCall"); call.Exists() {
+		return Part{Type: PartToolCall, CallID: call.Get("id").String(), Name: call.Get("name").String(), Arguments: call.Get("args").Raw}, true
+	}
+	if response := part.Get("functionResponse"); response.Exists() {
+		return Part{Type: PartToolResult, CallID: response.Get("id").String(), Name: response.Get("name").String(), Text: response.Get("response").Raw}, true
+	}
+	return Part{}, false
+}
+
+// geminiResponse reads a response in the Gemini or Gemini CLI form, whole, streamed as
+// events or streamed as a JSON array.
+func geminiResponse(body []byte) *Message {
+	var chunks []gjson.Result
+	switch parsed := gjson.ParseBytes(body); {
+	case isStream(body):
+		chunks = streamEvents(body)
+	case parsed.IsArray():
+		chunks = parsed.Array()
+	default:
+		chunks = []gjson.Result{parsed}
+	}
+	msg := &Message{Role: RoleAssistant}
+	for _, chunk := range chunks {
+		if response := chunk.Get("response"); response.IsObject() {
+			chunk = response
+		}
+		for _, part := range chunk.Get("candidates.0.content.parts").Array() {
+			if p, ok := geminiPart(part); ok {
+				appendPart(msg, p)
+			}
+		}
+	}
+	return msg
+}
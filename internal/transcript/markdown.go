@@ -0,0 +1,138 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Markdown renders transcripts, oldest first, as one Markdown document: a heading per
+// request, a heading per message naming its role, and tool calls and results in fenced code
+// blocks. The output depends only on the transcripts, so an export can be compared with an
+// earlier one.
+func Markdown(transcripts []*Transcript) string {
+	var b strings.Builder
+	for i, t := range transcripts {
+		if i > 0 {
+			b.WriteString("---\n\n")
+		}
+		writeTranscript(&b, t)
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func writeTranscript(b *strings.Builder, t *Transcript) {
+	fmt.Fprintf(b, "# Request %s\n\n", t.RequestID)
+	fmt.Fprintf(b, "- Endpoint: `%s`\n", t.Endpoint)
+	if t.Model != "" {
+		fmt.Fprintf(b, "- Model: `%s`\n", t.Model)
+	}
+	if !t.Timestamp.IsZero() {
+		fmt.Fprintf(b, "- Time: %s\n", t.Timestamp.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(b, "- Status: %d\n", t.Status)
+	if t.PreviousResponseID != "" {
+		fmt.Fprintf(b, "- Continues: `%s`\n", t.PreviousResponseID)
+	}
+	b.WriteString("\n")
+	for _, msg := range t.Messages {
+		heading := roleHeading(msg.Role)
+		if msg.Response {
+			heading += " (response)"
+		}
+		fmt.Fprintf(b, "## %s\n\n", heading)
+		for _, part := range msg.Parts {
+			writePart(b, part)
+		}
+	}
+}
+
+func roleHeading(role string) string {
+	switch role {
+	case RoleSystem:
+		return "System"
+	case RoleAssistant:
+		return "Assistant"
+	case RoleTool:
+		return "Tool"
+	}
+	return "User"
+}
+
+func writePart(b *strings.Builder, part Part) {
+	switch part.Type {
+	case PartText:
+		b.WriteString(strings.TrimSpace(part.Text))
+		b.WriteString("\n\n")
+	case PartToolCall:
+		fmt.Fprintf(b, "**Tool call** `%s`%s\n\n", part.Name, callSuffix(part.CallID))
+		writeFenced(b, "json", prettyJSON(part.Arguments))
+	case PartToolResult:
+		label := "**Tool result**"
+		if part.Name != "" {
+			label += fmt.Sprintf(" `%s`", part.Name)
+		}
+		fmt.Fprintf(b, "%s%s\n\n", label, callSuffix(part.CallID))
+		language := ""
+		if json.Valid([]byte(part.Text)) {
+			language = "json"
+		}
+		writeFenced(b, language, prettyJSON(part.Text))
+	case PartAttachment:
+		fmt.Fprintf(b, "**Attachment** %s\n\n", describeAttachment(part))
+	}
+}
+
+func callSuffix(callID string) string {
+	if callID == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (`%s`)", callID)
+}
+
+func describeAttachment(part Part) string {
+	var details []string
+	if part.Name != "" {
+		details = append(details, "`"+part.Name+"`")
+	}
+	if part.MimeType != "" {
+		details = append(details, part.MimeType)
+	}
+	if part.Size > 0 {
+		details = append(details, fmt.Sprintf("%d bytes", part.Size))
+	}
+	if part.SHA256 != "" {
+		details = append(details, "sha256 `"+part.SHA256+"`")
+	}
+	if part.URL != "" {
+		details = append(details, "<"+part.URL+">")
+	}
+	return strings.Join(details, ", ")
+}
+
+// writeFenced writes text as a fenced code block whose fence is longer than any run of
+// backticks in text, so the block cannot be closed early.
+func writeFenced(b *strings.Builder, language, text string) {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n\n", fence, language, strings.TrimRight(text, "\n"), fence)
+}
+
+// prettyJSON indents text when it is JSON and returns it unchanged otherwise.
+func prettyJSON(text string) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(text), "", "  "); err != nil {
+		return text
+	}
+	return out.String()
+}
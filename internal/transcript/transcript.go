@@ -0,0 +1,329 @@
+// Package transcript rebuilds the conversation of a logged request, the messages the client
+// sent and the response it got, as a normalized transcript that exports to JSON or Markdown.
+package transcript
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+)
+
+// ErrUnsupported is returned for request logs of endpoints that carry no conversation, such
+// as model listings or token counts.
+var ErrUnsupported = errors.New("endpoint carries no conversation")
+
+// Client formats a transcript can be rebuilt from.
+const (
+	FormatOpenAI          = "openai"
+	FormatOpenAIResponses = "openai-response"
+	FormatCompletions     = "openai-completions"
+	FormatClaude          = "claude"
+	FormatGemini          = "gemini"
+)
+
+// Message roles.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Part types.
+const (
+	PartText       = "text"
+	PartToolCall   = "tool_call"
+	PartToolResult = "tool_result"
+	PartAttachment = "attachment"
+)
+
+// Transcript is the conversation of one logged request.
+type Transcript struct {
+	RequestID string    `json:"request_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Endpoint  string    `json:"endpoint"`
+	Format    string    `json:"format"`
+	Model     string    `json:"model,omitempty"`
+	Status    int       `json:"status"`
+	// ResponseID and PreviousResponseID link the requests of a Responses API conversation.
+	ResponseID         string `json:"response_id,omitempty"`
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	// ConversationID is the session or conversation ID the client sent, which links the
+	// requests of one conversation in any format.
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Messages       []Message `json:"messages"`
+}
+
+// Message is one turn of the conversation.
+type Message struct {
+	Role string `json:"role"`
+	// Response marks the messages the client received, as opposed to those it sent.
+	Response bool   `json:"response,omitempty"`
+	Parts    []Part `json:"parts"`
+}
+
+// Part is the content of a message. Attachments are referenced by the SHA-256 of their data,
+// or by URL when the client linked them, and never inlined.
+type Part struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	Name      string `json:"name,omitempty"`
+	CallID    string `json:"call_id,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	Size      int    `json:"size,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// Build rebuilds the transcript of record from the request as it was sent upstream, so
+// instructions the proxy injected such as a system prompt are included, and the response the
+// client was sent. Logs without the upstream request, such as those of streams, fall back to
+// the request body the client sent. Secrets are redacted from every text.
+func Build(record *logging.RequestLogRecord) (*Transcript, error) {
+	format := formatOf(record.URL)
+	if format == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, record.URL)
+	}
+	body := unwrapGemini(gjson.ParseBytes(record.RequestBody))
+	t := &Transcript{
+		RequestID:      record.RequestID(),
+		Timestamp:      record.Timestamp,
+		Endpoint:       strings.TrimSpace(record.Method + " " + record.URL),
+		Format:         format,
+		Model:          gjson.GetBytes(record.RequestBody, "model").String(),
+		Status:         record.Status,
+		ConversationID: conversationID(record.RequestHeaders, body),
+	}
+	if t.Model == "" && format == FormatGemini {
+		t.Model = geminiModelFromURL(record.URL)
+	}
+
+	requestFormat := format
+	if upstream := unwrapGemini(gjson.ParseBytes(record.APIRequest)); upstream.IsObject() {
+		if detected := upstreamFormatOf(upstream); detected != "" {
+			requestFormat = detected
+			body = upstream
+		}
+	}
+	t.Messages = requestMessages(requestFormat, body)
+
+	var response *Message
+	switch format {
+	case FormatOpenAI:
+		response = openAIChatResponse(record.Response)
+	case FormatCompletions:
+		response = completionsResponse(record.Response)
+	case FormatOpenAIResponses:
+		t.PreviousResponseID = gjson.GetBytes(record.RequestBody, "previous_response_id").String()
+		response, t.ResponseID = responsesResponse(record.Response)
+	case FormatClaude:
+		response = claudeResponse(record.Response)
+	case FormatGemini:
+		response = geminiResponse(record.Response)
+	}
+	if response != nil && len(response.Parts) > 0 {
+		response.Response = true
+		t.Messages = append(t.Messages, *response)
+	}
+	for i := range t.Messages {
+		for j := range t.Messages[i].Parts {
+			redactPart(&t.Messages[i].Parts[j])
+		}
+	}
+	if t.Messages == nil {
+		t.Messages = []Message{}
+	}
+	return t, nil
+}
+
+// requestMessages returns the messages of body, a request in format.
+func requestMessages(format string, body gjson.Result) []Message {
+	switch format {
+	case FormatOpenAI:
+		return openAIChatRequest(body)
+	case FormatCompletions:
+		return completionsRequest(body)
+	case FormatOpenAIResponses:
+		return responsesRequest(body)
+	case FormatClaude:
+		return claudeRequest(body)
+	case FormatGemini:
+		return geminiRequest(body)
+	}
+	return nil
+}
+
+// unwrapGemini returns the Gemini request Gemini CLI wraps in "request", or body itself.
+func unwrapGemini(body gjson.Result) gjson.Result {
+	if request := body.Get("request"); request.IsObject() {
+		return request
+	}
+	return body
+}
+
+// upstreamFormatOf tells the format of an upstream request body by its fields, or returns ""
+// when it is none a transcript can be rebuilt from.
+func upstreamFormatOf(body gjson.Result) string {
+	switch {
+	case body.Get("contents").Exists():
+		return FormatGemini
+	case body.Get("input").Exists() || body.Get("instructions").Exists():
+		return FormatOpenAIResponses
+	case body.Get("prompt").Exists():
+		return FormatCompletions
+	case !body.Get("messages").IsArray():
+		return ""
+	case body.Get("system").Exists() || body.Get("anthropic_version").Exists():
+		return FormatClaude
+	}
+	format := FormatOpenAI
+	body.Get("messages").ForEach(func(_, msg gjson.Result) bool {
+		msg.Get("content").ForEach(func(_, block gjson.Result) bool {
+			switch block.Get("type").String() {
+			case "tool_use", "tool_result":
+				format = FormatClaude
+			case "image", "document":
+				if block.Get("source").Exists() {
+					format = FormatClaude
+				}
+			}
+			return format != FormatClaude
+		})
+		return format != FormatClaude
+	})
+	return format
+}
+
+// conversationHeaders carry the session or conversation ID clients such as the Codex CLI
+// send with every request of a conversation.
+var conversationHeaders = []string{"Conversation_id", "Session_id", "X-Conversation-Id", "X-Session-Id"}
+
+// conversationID returns the session or conversation ID a request carries in its headers or
+// body: the Responses API conversation, a prompt_cache_key, or the session Claude Code puts
+// in metadata.user_id.
+func conversationID(headers map[string]string, body gjson.Result) string {
+	for _, name := range conversationHeaders {
+		for key, value := range headers {
+			if strings.EqualFold(key, name) && strings.TrimSpace(value) != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	if conversation := body.Get("conversation"); conversation.Type == gjson.String {
+		return conversation.String()
+	} else if id := conversation.Get("id").String(); id != "" {
+		return id
+	}
+	if key := body.Get("prompt_cache_key").String(); key != "" {
+		return key
+	}
+	if _, session, ok := strings.Cut(body.Get("metadata.user_id").String(), "_session_"); ok {
+		return session
+	}
+	return ""
+}
+
+// formatOf returns the client format of the endpoint at url, or "" when it carries no
+// conversation.
+func formatOf(url string) string {
+	path, _, _ := strings.Cut(url, "?")
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		return FormatOpenAI
+	case strings.HasSuffix(path, "/completions"):
+		return FormatCompletions
+	case strings.HasSuffix(path, "/responses"):
+		return FormatOpenAIResponses
+	case strings.HasSuffix(path, "/messages"):
+		return FormatClaude
+	case strings.HasSuffix(path, ":generateContent"), strings.HasSuffix(path, ":streamGenerateContent"):
+		return FormatGemini
+	}
+	return ""
+}
+
+// geminiModelFromURL extracts the model of a Gemini endpoint path such as
+// "/v1beta/models/gemini-2.5-pro:generateContent".
+func geminiModelFromURL(url string) string {
+	path, _, _ := strings.Cut(url, "?")
+	_, model, ok := strings.Cut(path, "/models/")
+	if !ok {
+		return ""
+	}
+	model, _, _ = strings.Cut(model, ":")
+	return model
+}
+
+func redactPart(part *Part) {
+	part.Text = logging.RedactSecrets(part.Text)
+	part.Arguments = logging.RedactSecrets(part.Arguments)
+	part.URL = logging.RedactSecrets(part.URL)
+}
+
+// textPart returns a text part, or false when text is empty.
+func textPart(text string) (Part, bool) {
+	return Part{Type: PartText, Text: text}, text != ""
+}
+
+// inlineAttachment references inline base64 data by its hash.
+func inlineAttachment(mimeType, data string) Part {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		raw = []byte(data)
+	}
+	sum := sha256.Sum256(raw)
+	return Part{Type: PartAttachment, MimeType: mimeType, SHA256: hex.EncodeToString(sum[:]), Size: len(raw)}
+}
+
+// urlAttachment references an attachment given by URL. Data URLs are hashed like inline data.
+func urlAttachment(mimeType, url string) Part {
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		meta, data, _ := strings.Cut(rest, ",")
+		mediaType, _, _ := strings.Cut(meta, ";")
+		if mediaType == "" {
+			mediaType = mimeType
+		}
+		return inlineAttachment(mediaType, data)
+	}
+	return Part{Type: PartAttachment, MimeType: mimeType, URL: url}
+}
+
+// appendPart adds part to msg, joining consecutive text as streamed responses deliver it.
+func appendPart(msg *Message, part Part) {
+	if part.Type == PartText && len(msg.Parts) > 0 && msg.Parts[len(msg.Parts)-1].Type == PartText {
+		msg.Parts[len(msg.Parts)-1].Text += part.Text
+		return
+	}
+	msg.Parts = append(msg.Parts, part)
+}
+
+// streamEvents returns the JSON payloads of the data lines of a server-sent event stream.
+func streamEvents(body []byte) []gjson.Result {
+	var events []gjson.Result
+	for _, line := range strings.Split(string(body), "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" || data == "[DONE]" || !gjson.Valid(data) {
+			continue
+		}
+		events = append(events, gjson.Parse(data))
+	}
+	return events
+}
+
+// isStream reports whether a response body is a server-sent event stream.
+func isStream(body []byte) bool {
+	trimmed := strings.TrimSpace(string(body))
+	return strings.HasPrefix(trimmed, "data:") || strings.HasPrefix(trimmed, "event:")
+}
@@ -0,0 +1,110 @@
+package transcript
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// golden compares got with testdata/name, rewriting it with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Fatalf("%s differs:\n%s", name, got)
+	}
+}
+
+func TestMarkdownToolCallsAndImages(t *testing.T) {
+	record := &logging.RequestLogRecord{
+		URL:       "/v1/chat/completions",
+		Method:    "POST",
+		Timestamp: time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC),
+		Status:    200,
+		RequestBody: []byte(`{"model":"gpt-5","messages":[
+			{"role":"system","content":"Be brief."},
+			{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,aGVsbG8="}},{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]},
+			{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":\"cat\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"{\"found\":true}"}
+		]}`),
+		ResponseHeaders: map[string]string{"X-Request-Id": "req-1"},
+		Response:        []byte(`{"choices":[{"message":{"role":"assistant","content":"A cat, with ` + "```" + ` fences."}}]}`),
+	}
+	tr, err := Build(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "chat_tools_images.md", Markdown([]*Transcript{tr}))
+}
+
+func TestBuildUsesUpstreamRequest(t *testing.T) {
+	record := &logging.RequestLogRecord{
+		URL:         "/v1/messages",
+		Method:      "POST",
+		RequestBody: []byte(`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`),
+		APIRequest:  []byte(`{"model":"claude-sonnet-4","system":[{"type":"text","text":"Injected policy."}],"messages":[{"role":"user","content":"Hi"}]}`),
+		Response:    []byte(`{"content":[{"type":"text","text":"Hello."}]}`),
+	}
+	tr, err := Build(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Messages) != 3 || tr.Messages[0].Role != RoleSystem || tr.Messages[0].Parts[0].Text != "Injected policy." {
+		t.Fatalf("messages = %+v", tr.Messages)
+	}
+
+	// A Gemini CLI upstream request behind an OpenAI client request.
+	record = &logging.RequestLogRecord{
+		URL:         "/v1/chat/completions",
+		RequestBody: []byte(`{"model":"gemini-2.5-pro","messages":[{"role":"user","content":"Hi"}]}`),
+		APIRequest:  []byte(`{"model":"gemini-2.5-pro","request":{"systemInstruction":{"parts":[{"text":"Injected."}]},"contents":[{"role":"user","parts":[{"text":"Hi"}]}]}}`),
+	}
+	if tr, err = Build(record); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Messages) != 2 || tr.Messages[0].Parts[0].Text != "Injected." || tr.Messages[1].Parts[0].Text != "Hi" {
+		t.Fatalf("messages = %+v", tr.Messages)
+	}
+}
+
+func TestConversationID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+		want    string
+	}{
+		{name: "codex header", headers: map[string]string{"Session_id": "s-1"}, body: `{}`, want: "s-1"},
+		{name: "responses conversation", body: `{"conversation":{"id":"conv_1"}}`, want: "conv_1"},
+		{name: "prompt cache key", body: `{"prompt_cache_key":"k-1"}`, want: "k-1"},
+		{name: "claude code session", body: `{"metadata":{"user_id":"user_ab_account_cd_session_ef-12"}}`, want: "ef-12"},
+		{name: "none", body: `{"metadata":{"user_id":"u"}}`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &logging.RequestLogRecord{URL: "/v1/responses", RequestHeaders: tt.headers, RequestBody: []byte(tt.body)}
+			tr, err := Build(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tr.ConversationID != tt.want {
+				t.Fatalf("ConversationID = %q, want %q", tr.ConversationID, tt.want)
+			}
+		})
+	}
+}
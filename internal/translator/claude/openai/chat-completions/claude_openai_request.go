@@ -12,6 +12,7 @@ import (
 	"math/big"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -127,59 +128,41 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 					"content": []interface{}{},
 				}
 
-				// Handle content based on its type (string or array)
-				if contentResult.Exists() && contentResult.Type == gjson.String && contentResult.String() != "" {
-					// Simple text content conversion
-					msg["content"] = []interface{}{
-						map[string]interface{}{
+				// Text and images of the content, whichever shape it was sent in
+				var contentParts []interface{}
+				for _, part := range util.OpenAIContentParts(contentResult) {
+					switch part.Get("type").String() {
+					case "text":
+						contentParts = append(contentParts, map[string]interface{}{
 							"type": "text",
-							"text": contentResult.String(),
-						},
-					}
-				} else if contentResult.Exists() && contentResult.IsArray() {
-					// Array of content parts processing
-					var contentParts []interface{}
-					contentResult.ForEach(func(_, part gjson.Result) bool {
-						partType := part.Get("type").String()
-
-						switch partType {
-						case "text":
-							// Text part conversion
-							contentParts = append(contentParts, map[string]interface{}{
-								"type": "text",
-								"text": part.Get("text").String(),
-							})
-
-						case "image_url":
-							// Convert OpenAI image format to Claude Code format
-							imageURL := part.Get("image_url.url").String()
-							if strings.HasPrefix(imageURL, "data:") {
-								// Extract base64 data and media type from data URL
-								parts := strings.Split(imageURL, ",")
-								if len(parts) == 2 {
-									mediaTypePart := strings.Split(parts[0], ";")[0]
-									mediaType := strings.TrimPrefix(mediaTypePart, "data:")
-									data := parts[1]
-
-									contentParts = append(contentParts, map[string]interface{}{
-										"type": "image",
-										"source": map[string]interface{}{
-											"type":       "base64",
-											"media_type": mediaType,
-											"data":       data,
-										},
-									})
-								}
+							"text": part.Get("text").String(),
+						})
+
+					case "image_url":
+						// Convert OpenAI image format to Claude Code format
+						imageURL := part.Get("image_url.url").String()
+						if strings.HasPrefix(imageURL, "data:") {
+							// Extract base64 data and media type from data URL
+							parts := strings.Split(imageURL, ",")
+							if len(parts) == 2 {
+								mediaTypePart := strings.Split(parts[0], ";")[0]
+								mediaType := strings.TrimPrefix(mediaTypePart, "data:")
+								data := parts[1]
+
+								contentParts = append(contentParts, map[string]interface{}{
+									"type": "image",
+									"source": map[string]interface{}{
+										"type":       "base64",
+										"media_type": mediaType,
+										"data":       data,
+									},
+								})
 							}
 						}
-						return true
-					})
-					if len(contentParts) > 0 {
-						msg["content"] = contentParts
 					}
-				} else {
-					// Initialize empty content array for tool calls
-					msg["content"] = []interface{}{}
+				}
+				if len(contentParts) > 0 {
+					msg["content"] = contentParts
 				}
 
 				// Handle tool calls (for assistant messages)
@@ -230,6 +213,19 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 					msg["content"] = contentParts
 				}
 
+				// Claude rejects messages without content blocks, so empty turns are dropped, and
+				// a turn left next to one of the same role is merged into it.
+				blocks, _ := msg["content"].([]interface{})
+				if len(blocks) == 0 {
+					return true
+				}
+				if n := len(anthropicMessages); n > 0 {
+					if last, ok := anthropicMessages[n-1].(map[string]interface{}); ok && last["role"] == role {
+						lastBlocks, _ := last["content"].([]interface{})
+						last["content"] = append(lastBlocks, blocks...)
+						return true
+					}
+				}
 				anthropicMessages = append(anthropicMessages, msg)
 
 			case "tool":
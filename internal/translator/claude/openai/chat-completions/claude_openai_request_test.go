@@ -0,0 +1,24 @@
+package chat_completions
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIRequestToClaudeMergesTurnsAroundDroppedOnes(t *testing.T) {
+	in := []byte(`{"messages":[
+		{"role":"user","content":"first"},
+		{"role":"assistant","content":[]},
+		{"role":"user","content":[{"type":"text","text":"second"}]},
+		{"role":"assistant","content":"answer"}
+	]}`)
+	out := ConvertOpenAIRequestToClaude("claude-sonnet-4", in, false)
+
+	if got := gjson.GetBytes(out, "messages.#.role").Raw; got != `["user","assistant"]` {
+		t.Fatalf("roles = %s, want user then assistant", got)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content.#.text").Raw; got != `["first","second"]` {
+		t.Errorf("user blocks = %s", got)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -64,6 +65,9 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	}
 	out, _ = sjson.Set(out, "parallel_tool_calls", true)
 	out, _ = sjson.Set(out, "reasoning.summary", "auto")
+	if v := gjson.GetBytes(rawJSON, "seed"); v.Exists() {
+		out, _ = sjson.SetRaw(out, "seed", v.Raw)
+	}
 	out, _ = sjson.Set(out, "include", []string{"reasoning.encrypted_content"})
 
 	// Model
@@ -147,50 +151,38 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 
 				msg, _ = sjson.SetRaw(msg, "content", `[]`)
 
-				// Handle regular content
-				c := m.Get("content")
-				if c.Exists() && c.Type == gjson.String && c.String() != "" {
-					// Single string content
-					partType := "input_text"
-					if role == "assistant" {
-						partType = "output_text"
-					}
-					part := `{}`
-					part, _ = sjson.Set(part, "type", partType)
-					part, _ = sjson.Set(part, "text", c.String())
-					msg, _ = sjson.SetRaw(msg, "content.-1", part)
-				} else if c.Exists() && c.IsArray() {
-					items := c.Array()
-					for j := 0; j < len(items); j++ {
-						it := items[j]
-						t := it.Get("type").String()
-						switch t {
-						case "text":
-							partType := "input_text"
-							if role == "assistant" {
-								partType = "output_text"
-							}
+				// Handle regular content, whichever shape it was sent in
+				textType := "input_text"
+				if role == "assistant" {
+					textType = "output_text"
+				}
+				for _, it := range util.OpenAIContentParts(m.Get("content")) {
+					switch it.Get("type").String() {
+					case "text":
+						part := `{}`
+						part, _ = sjson.Set(part, "type", textType)
+						part, _ = sjson.Set(part, "text", it.Get("text").String())
+						msg, _ = sjson.SetRaw(msg, "content.-1", part)
+					case "image_url":
+						// Map image inputs to input_image for Responses API
+						if role == "user" {
 							part := `{}`
-							part, _ = sjson.Set(part, "type", partType)
-							part, _ = sjson.Set(part, "text", it.Get("text").String())
-							msg, _ = sjson.SetRaw(msg, "content.-1", part)
-						case "image_url":
-							// Map image inputs to input_image for Responses API
-							if role == "user" {
-								part := `{}`
-								part, _ = sjson.Set(part, "type", "input_image")
-								if u := it.Get("image_url.url"); u.Exists() {
-									part, _ = sjson.Set(part, "image_url", u.String())
-								}
-								msg, _ = sjson.SetRaw(msg, "content.-1", part)
+							part, _ = sjson.Set(part, "type", "input_image")
+							if u := it.Get("image_url.url"); u.Exists() {
+								part, _ = sjson.Set(part, "image_url", u.String())
 							}
-						case "file":
-							// Files are not specified in examples; skip for now
+							msg, _ = sjson.SetRaw(msg, "content.-1", part)
 						}
+					case "file":
+						// Files are not specified in examples; skip for now
 					}
 				}
 
-				out, _ = sjson.SetRaw(out, "input.-1", msg)
+				// A message without content, such as an assistant turn holding only tool
+				// calls, is left out; the calls follow as their own items.
+				if len(gjson.Get(msg, "content").Array()) > 0 {
+					out, _ = sjson.SetRaw(out, "input.-1", msg)
+				}
 
 				// Handle tool calls for assistant messages as separate top-level objects
 				if role == "assistant" {
@@ -0,0 +1,18 @@
+package chat_completions
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIRequestToCodexForwardsSeed(t *testing.T) {
+	out := ConvertOpenAIRequestToCodex("gpt-5", []byte(`{"model":"gpt-5","seed":42,"messages":[{"role":"user","content":"hi"}]}`), true)
+	if seed := gjson.GetBytes(out, "seed"); seed.Int() != 42 {
+		t.Fatalf("seed = %s in %s, want 42", seed.Raw, out)
+	}
+	out = ConvertOpenAIRequestToCodex("gpt-5", []byte(`{"model":"gpt-5","messages":[{"role":"user","content":"hi"}]}`), true)
+	if gjson.GetBytes(out, "seed").Exists() {
+		t.Fatalf("seed set without one in the request: %s", out)
+	}
+}
@@ -198,5 +198,10 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 		out, _ = sjson.Set(out, "request.generationConfig.topK", v.Num)
 	}
 
+	// safety_settings is an extension field carrying Gemini safetySettings.
+	if safety := gjson.GetBytes(rawJSON, "safety_settings"); safety.IsArray() {
+		out, _ = sjson.SetRaw(out, "request.safetySettings", safety.Raw)
+	}
+
 	return []byte(out)
 }
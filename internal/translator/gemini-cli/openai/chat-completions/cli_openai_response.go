@@ -12,6 +12,7 @@ import (
 	"time"
 
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -19,6 +20,16 @@ import (
 // convertCliResponseToOpenAIChatParams holds parameters for response conversion.
 type convertCliResponseToOpenAIChatParams struct {
 	UnixTimestamp int64
+	StopMatcher   *util.StopSequenceMatcher
+	// Finished is set once a chunk carried a finish reason; usage seen from then on is final.
+	Finished bool
+	// InterimUsage is set when the client asked for usage with stream_options.include_usage,
+	// which opts in to the running totals seen before the finish reason.
+	InterimUsage bool
+	// ResponseID and Model are those of the last chunk, for the chunk releasing text still
+	// held back when the stream ends.
+	ResponseID string
+	Model      string
 }
 
 // ConvertCliResponseToOpenAI translates a single chunk of a streaming response from the
@@ -39,11 +50,25 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 	if *param == nil {
 		*param = &convertCliResponseToOpenAIChatParams{
 			UnixTimestamp: 0,
+			StopMatcher:   util.NewStopSequenceMatcher(util.StopSequencesFromRequest(originalRequestRawJSON)),
+			InterimUsage:  gjson.GetBytes(originalRequestRawJSON, "stream_options.include_usage").Bool(),
 		}
 	}
 
 	if bytes.Equal(rawJSON, []byte("[DONE]")) {
-		return []string{}
+		// A stream ending without a finish reason still owes the client the text held back
+		// while checking for stop sequences.
+		params := (*param).(*convertCliResponseToOpenAIChatParams)
+		held := params.StopMatcher.Flush()
+		if held == "" {
+			return []string{}
+		}
+		template := `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}`
+		template, _ = sjson.Set(template, "id", params.ResponseID)
+		template, _ = sjson.Set(template, "created", params.UnixTimestamp)
+		template, _ = sjson.Set(template, "model", params.Model)
+		template, _ = sjson.Set(template, "choices.0.delta.content", held)
+		return []string{template}
 	}
 
 	// Initialize the OpenAI SSE template.
@@ -52,6 +77,7 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 	// Extract and set the model version.
 	if modelVersionResult := gjson.GetBytes(rawJSON, "response.modelVersion"); modelVersionResult.Exists() {
 		template, _ = sjson.Set(template, "model", modelVersionResult.String())
+		(*param).(*convertCliResponseToOpenAIChatParams).Model = modelVersionResult.String()
 	}
 
 	// Extract and set the creation timestamp.
@@ -68,28 +94,57 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 	// Extract and set the response ID.
 	if responseIDResult := gjson.GetBytes(rawJSON, "response.responseId"); responseIDResult.Exists() {
 		template, _ = sjson.Set(template, "id", responseIDResult.String())
+		(*param).(*convertCliResponseToOpenAIChatParams).ResponseID = responseIDResult.String()
 	}
 
 	// Extract and set the finish reason.
-	if finishReasonResult := gjson.GetBytes(rawJSON, "response.candidates.0.finishReason"); finishReasonResult.Exists() {
-		template, _ = sjson.Set(template, "choices.0.finish_reason", finishReasonResult.String())
-		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReasonResult.String())
+	// Output withheld by a safety filter, including a blocked prompt, ends as content_filter.
+	if finishReason, blocked := util.GeminiFinishReason(gjson.GetBytes(rawJSON, "response")); finishReason != "" {
+		if blocked {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", "content_filter")
+		} else {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
+		}
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
+		(*param).(*convertCliResponseToOpenAIChatParams).Finished = true
 	}
 
-	// Extract and set usage metadata (token counts).
-	if usageResult := gjson.GetBytes(rawJSON, "response.usageMetadata"); usageResult.Exists() {
+	// Extract and set usage metadata (token counts). Gemini repeats usageMetadata while it
+	// streams; counts seen before the finish reason are running totals. They go to the
+	// x_interim_usage extension for clients that asked for usage and are dropped for the
+	// others, so usage itself only ever holds the final counts.
+	usageResult := gjson.GetBytes(rawJSON, "response.usageMetadata")
+	usageField := "usage"
+	if !(*param).(*convertCliResponseToOpenAIChatParams).Finished {
+		usageField = "x_interim_usage"
+		if !(*param).(*convertCliResponseToOpenAIChatParams).InterimUsage {
+			usageResult = gjson.Result{}
+		}
+	}
+	if usageResult.Exists() {
 		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() {
-			template, _ = sjson.Set(template, "usage.completion_tokens", candidatesTokenCountResult.Int())
+			template, _ = sjson.Set(template, usageField+".completion_tokens", candidatesTokenCountResult.Int())
 		}
 		if totalTokenCountResult := usageResult.Get("totalTokenCount"); totalTokenCountResult.Exists() {
-			template, _ = sjson.Set(template, "usage.total_tokens", totalTokenCountResult.Int())
+			template, _ = sjson.Set(template, usageField+".total_tokens", totalTokenCountResult.Int())
 		}
 		promptTokenCount := usageResult.Get("promptTokenCount").Int()
 		thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
-		template, _ = sjson.Set(template, "usage.prompt_tokens", promptTokenCount+thoughtsTokenCount)
+		template, _ = sjson.Set(template, usageField+".prompt_tokens", promptTokenCount+thoughtsTokenCount)
 		if thoughtsTokenCount > 0 {
-			template, _ = sjson.Set(template, "usage.completion_tokens_details.reasoning_tokens", thoughtsTokenCount)
+			template, _ = sjson.Set(template, usageField+".completion_tokens_details.reasoning_tokens", thoughtsTokenCount)
+		}
+	}
+
+	// Once a client stop sequence has matched, only trailing usage is forwarded.
+	stopMatcher := (*param).(*convertCliResponseToOpenAIChatParams).StopMatcher
+	if stopMatcher.Stopped() {
+		if !usageResult.Exists() {
+			return []string{}
 		}
+		template, _ = sjson.Set(template, "choices.0.finish_reason", nil)
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", nil)
+		return []string{template}
 	}
 
 	// Process the main content part of the response.
@@ -106,9 +161,16 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 				if partResult.Get("thought").Bool() {
 					template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", partTextResult.String())
 				} else {
-					template, _ = sjson.Set(template, "choices.0.delta.content", partTextResult.String())
+					text := partTextResult.String()
+					if stopMatcher != nil {
+						text = stopMatcher.Feed(text)
+					}
+					template, _ = sjson.Set(template, "choices.0.delta.content", text)
 				}
 				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
+				if stopMatcher.Stopped() {
+					break
+				}
 			} else if functionCallResult.Exists() {
 				// Handle function call content.
 				toolCallsResult := gjson.Get(template, "choices.0.delta.tool_calls")
@@ -129,6 +191,19 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 		}
 	}
 
+	if stopMatcher != nil {
+		if !stopMatcher.Stopped() && gjson.GetBytes(rawJSON, "response.candidates.0.finishReason").Exists() {
+			if held := stopMatcher.Flush(); held != "" {
+				template, _ = sjson.Set(template, "choices.0.delta.content", gjson.Get(template, "choices.0.delta.content").String()+held)
+				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
+			}
+		}
+		if stopMatcher.Stopped() {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", "stop")
+			template, _ = sjson.Set(template, "choices.0.stop_sequence", stopMatcher.Matched())
+		}
+	}
+
 	return []string{template}
 }
 
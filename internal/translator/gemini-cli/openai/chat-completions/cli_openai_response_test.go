@@ -0,0 +1,44 @@
+package chat_completions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertCliResponseToOpenAIReleasesHeldTextWhenTheStreamEnds(t *testing.T) {
+	var param any
+	var content string
+	for _, chunk := range []string{
+		`{"response":{"responseId":"r1","candidates":[{"content":{"parts":[{"text":"see ##"}]}}]}}`,
+		"[DONE]",
+	} {
+		for _, out := range ConvertCliResponseToOpenAI(context.Background(), "", []byte(`{"stop":["###"]}`), nil, []byte(chunk), &param) {
+			content += gjson.Get(out, "choices.0.delta.content").String()
+		}
+	}
+	if content != "see ##" {
+		t.Fatalf("content = %q, want the held-back text released", content)
+	}
+}
+
+func TestConvertCliResponseToOpenAIStreamsInterimUsageOnlyWhenAsked(t *testing.T) {
+	chunks := []string{
+		`{"response":{"candidates":[{"content":{"parts":[{"text":"a"}]}}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":1,"totalTokenCount":6}}}`,
+		`{"response":{"candidates":[{"content":{"parts":[{"text":"b"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2,"totalTokenCount":7}}}`,
+	}
+	for request, wantInterim := range map[string]bool{`{"stream_options":{"include_usage":true}}`: true, `{}`: false} {
+		var param any
+		var interim, final bool
+		for _, chunk := range chunks {
+			for _, out := range ConvertCliResponseToOpenAI(context.Background(), "", []byte(request), nil, []byte(chunk), &param) {
+				interim = interim || gjson.Get(out, "x_interim_usage.completion_tokens").Int() == 1
+				final = final || gjson.Get(out, "usage.total_tokens").Int() == 7
+			}
+		}
+		if interim != wantInterim || !final {
+			t.Fatalf("request %s: interim usage %v, final usage %v, want %v and true", request, interim, final, wantInterim)
+		}
+	}
+}
@@ -191,5 +191,10 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		out, _ = sjson.Set(out, "generationConfig.topK", v.Num)
 	}
 
+	// safety_settings is an extension field carrying Gemini safetySettings.
+	if safety := gjson.GetBytes(rawJSON, "safety_settings"); safety.IsArray() {
+		out, _ = sjson.SetRaw(out, "safetySettings", safety.Raw)
+	}
+
 	return []byte(out)
 }
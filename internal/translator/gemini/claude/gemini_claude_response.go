@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -24,6 +25,7 @@ type Params struct {
 	HasFirstResponse bool
 	ResponseType     int
 	ResponseIndex    int
+	StopMatcher      *util.StopSequenceMatcher
 }
 
 // ConvertGeminiResponseToClaude performs sophisticated streaming response format conversion.
@@ -49,12 +51,20 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 			HasFirstResponse: false,
 			ResponseType:     0,
 			ResponseIndex:    0,
+			StopMatcher:      util.NewStopSequenceMatcher(util.StopSequencesFromRequest(originalRequestRawJSON)),
 		}
 	}
 
 	if bytes.Equal(rawJSON, []byte("[DONE]")) {
+		output := ""
+		// A stream ending without a finish chunk still owes the client the text held back
+		// while checking for stop sequences.
+		if held := (*param).(*Params).StopMatcher.Flush(); held != "" {
+			data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, (*param).(*Params).ResponseIndex), "delta.text", held)
+			output = fmt.Sprintf("event: content_block_delta\ndata: %s\n\n\n", data)
+		}
 		return []string{
-			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n\n",
+			output + "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n\n",
 		}
 	}
 
@@ -83,10 +93,54 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 		(*param).(*Params).HasFirstResponse = true
 	}
 
+	stopMatcher := (*param).(*Params).StopMatcher
+
+	// emitText writes user-visible text, opening a text content block when needed.
+	emitText := func(text string) {
+		// Continue existing text block
+		if (*param).(*Params).ResponseType == 1 {
+			output = output + "event: content_block_delta\n"
+			data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, (*param).(*Params).ResponseIndex), "delta.text", text)
+			output = output + fmt.Sprintf("data: %s\n\n\n", data)
+		} else {
+			// Transition from another state to text content
+			// First, close any existing content block
+			if (*param).(*Params).ResponseType != 0 {
+				if (*param).(*Params).ResponseType == 2 {
+					// output = output + "event: content_block_delta\n"
+					// output = output + fmt.Sprintf(`data: {"type":"content_block_delta","index":%d,"delta":{"type":"signature_delta","signature":null}}`, (*param).(*Params).ResponseIndex)
+					// output = output + "\n\n\n"
+				}
+				output = output + "event: content_block_stop\n"
+				output = output + fmt.Sprintf(`data: {"type":"content_block_stop","index":%d}`, (*param).(*Params).ResponseIndex)
+				output = output + "\n\n\n"
+				(*param).(*Params).ResponseIndex++
+			}
+
+			// Start a new text content block
+			output = output + "event: content_block_start\n"
+			output = output + fmt.Sprintf(`data: {"type":"content_block_start","index":%d,"content_block":{"type":"text","text":""}}`, (*param).(*Params).ResponseIndex)
+			output = output + "\n\n\n"
+			output = output + "event: content_block_delta\n"
+			data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, (*param).(*Params).ResponseIndex), "delta.text", text)
+			output = output + fmt.Sprintf("data: %s\n\n\n", data)
+			(*param).(*Params).ResponseType = 1 // Set state to content
+		}
+	}
+
+	// flushStopMatcher releases text held back while checking for stop sequences spanning chunks.
+	flushStopMatcher := func() {
+		if stopMatcher != nil {
+			if held := stopMatcher.Flush(); held != "" {
+				emitText(held)
+			}
+		}
+	}
+
 	// Process the response parts array from the backend client
 	// Each part can contain text content, thinking content, or function calls
 	partsResult := gjson.GetBytes(rawJSON, "candidates.0.content.parts")
-	if partsResult.IsArray() {
+	if partsResult.IsArray() && !stopMatcher.Stopped() {
 		partResults := partsResult.Array()
 		for i := 0; i < len(partResults); i++ {
 			partResult := partResults[i]
@@ -99,6 +153,7 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 			if partTextResult.Exists() {
 				// Process thinking content (internal reasoning)
 				if partResult.Get("thought").Bool() {
+					flushStopMatcher()
 					// Continue existing thinking block
 					if (*param).(*Params).ResponseType == 2 {
 						output = output + "event: content_block_delta\n"
@@ -130,39 +185,23 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 					}
 				} else {
 					// Process regular text content (user-visible output)
-					// Continue existing text block
-					if (*param).(*Params).ResponseType == 1 {
-						output = output + "event: content_block_delta\n"
-						data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, (*param).(*Params).ResponseIndex), "delta.text", partTextResult.String())
-						output = output + fmt.Sprintf("data: %s\n\n\n", data)
-					} else {
-						// Transition from another state to text content
-						// First, close any existing content block
-						if (*param).(*Params).ResponseType != 0 {
-							if (*param).(*Params).ResponseType == 2 {
-								// output = output + "event: content_block_delta\n"
-								// output = output + fmt.Sprintf(`data: {"type":"content_block_delta","index":%d,"delta":{"type":"signature_delta","signature":null}}`, (*param).(*Params).ResponseIndex)
-								// output = output + "\n\n\n"
-							}
-							output = output + "event: content_block_stop\n"
-							output = output + fmt.Sprintf(`data: {"type":"content_block_stop","index":%d}`, (*param).(*Params).ResponseIndex)
-							output = output + "\n\n\n"
-							(*param).(*Params).ResponseIndex++
+					text := partTextResult.String()
+					if stopMatcher != nil {
+						text = stopMatcher.Feed(text)
+						if text != "" {
+							emitText(text)
 						}
-
-						// Start a new text content block
-						output = output + "event: content_block_start\n"
-						output = output + fmt.Sprintf(`data: {"type":"content_block_start","index":%d,"content_block":{"type":"text","text":""}}`, (*param).(*Params).ResponseIndex)
-						output = output + "\n\n\n"
-						output = output + "event: content_block_delta\n"
-						data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":""}}`, (*param).(*Params).ResponseIndex), "delta.text", partTextResult.String())
-						output = output + fmt.Sprintf("data: %s\n\n\n", data)
-						(*param).(*Params).ResponseType = 1 // Set state to content
+						if stopMatcher.Stopped() {
+							break
+						}
+						continue
 					}
+					emitText(text)
 				}
 			} else if functionCallResult.Exists() {
 				// Handle function/tool calls from the AI model
 				// This processes tool usage requests and formats them for Claude API compatibility
+				flushStopMatcher()
 				usedTool = true
 				fcName := functionCallResult.Get("name").String()
 
@@ -214,6 +253,7 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 	usageResult := gjson.GetBytes(rawJSON, "usageMetadata")
 	if usageResult.Exists() && bytes.Contains(rawJSON, []byte(`"finishReason"`)) {
 		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() {
+			flushStopMatcher()
 			output = output + "event: content_block_stop\n"
 			output = output + fmt.Sprintf(`data: {"type":"content_block_stop","index":%d}`, (*param).(*Params).ResponseIndex)
 			output = output + "\n\n\n"
@@ -225,6 +265,14 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 			if usedTool {
 				template = `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
 			}
+			// Output withheld by a safety filter ends as a refusal
+			if finish := gjson.GetBytes(rawJSON, "candidates.0.finishReason"); !usedTool && util.GeminiBlocked(finish.String()) {
+				template, _ = sjson.Set(template, "delta.stop_reason", "refusal")
+			}
+			if stopMatcher.Stopped() {
+				template, _ = sjson.Set(template, "delta.stop_reason", "stop_sequence")
+				template, _ = sjson.Set(template, "delta.stop_sequence", stopMatcher.Matched())
+			}
 
 			thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
 			template, _ = sjson.Set(template, "usage.output_tokens", candidatesTokenCountResult.Int()+thoughtsTokenCount)
@@ -248,7 +296,6 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 // Returns:
 //   - string: A Claude-compatible JSON response.
 func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
-	_ = originalRequestRawJSON
 	_ = requestRawJSON
 
 	root := gjson.ParseBytes(rawJSON)
@@ -339,10 +386,14 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 	flushThinking()
 	flushText()
 
+	contentBlocks, matchedStop := truncateContentAtStopSequence(contentBlocks, util.StopSequencesFromRequest(originalRequestRawJSON))
 	response["content"] = contentBlocks
 
 	stopReason := "end_turn"
-	if hasToolCall {
+	if matchedStop != "" {
+		stopReason = "stop_sequence"
+		response["stop_sequence"] = matchedStop
+	} else if hasToolCall {
 		stopReason = "tool_use"
 	} else {
 		if finish := root.Get("candidates.0.finishReason"); finish.Exists() {
@@ -352,7 +403,11 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 			case "STOP", "FINISH_REASON_UNSPECIFIED", "UNKNOWN":
 				stopReason = "end_turn"
 			default:
-				stopReason = "end_turn"
+				if util.GeminiBlocked(finish.String()) {
+					stopReason = "refusal"
+				} else {
+					stopReason = "end_turn"
+				}
 			}
 		}
 	}
@@ -371,6 +426,30 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 	return string(encoded)
 }
 
+// truncateContentAtStopSequence cuts the content at the first text block containing a client
+// stop sequence, dropping everything generated after it.
+func truncateContentAtStopSequence(blocks []interface{}, sequences []string) ([]interface{}, string) {
+	if len(sequences) == 0 {
+		return blocks, ""
+	}
+	for i, block := range blocks {
+		textBlock, ok := block.(map[string]interface{})
+		if !ok || textBlock["type"] != "text" {
+			continue
+		}
+		text, matched := util.TruncateAtStopSequence(textBlock["text"].(string), sequences)
+		if matched == "" {
+			continue
+		}
+		if text == "" {
+			return blocks[:i], matched
+		}
+		textBlock["text"] = text
+		return blocks[:i+1], matched
+	}
+	return blocks, ""
+}
+
 func ClaudeTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"input_tokens":%d}`, count)
 }
@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// streamClaude runs chunks through the streaming translator and returns the data lines.
+func streamClaude(t *testing.T, request string, chunks ...string) []string {
+	t.Helper()
+	var param any
+	var events []string
+	for _, chunk := range chunks {
+		for _, out := range ConvertGeminiResponseToClaude(context.Background(), "", []byte(request), nil, []byte(chunk), &param) {
+			for _, line := range strings.Split(out, "\n") {
+				if data, ok := strings.CutPrefix(line, "data: "); ok {
+					events = append(events, data)
+				}
+			}
+		}
+	}
+	return events
+}
+
+func streamedText(events []string) string {
+	var text string
+	for _, event := range events {
+		text += gjson.Get(event, "delta.text").String()
+	}
+	return text
+}
+
+func TestConvertGeminiResponseToClaudeReportsTheStopSequence(t *testing.T) {
+	events := streamClaude(t, `{"stop_sequences":["</answer>","\n\nHuman:"]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"42 </ans"}]}}]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"wer> trailing"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":4}}`,
+		"[DONE]",
+	)
+	if got := streamedText(events); got != "42 " {
+		t.Fatalf("text = %q, want the text before the sequence", got)
+	}
+	var delta gjson.Result
+	for _, event := range events {
+		if gjson.Get(event, "type").String() == "message_delta" {
+			delta = gjson.Get(event, "delta")
+		}
+	}
+	if delta.Get("stop_reason").String() != "stop_sequence" || delta.Get("stop_sequence").String() != "</answer>" {
+		t.Fatalf("message_delta = %s, want stop_sequence </answer>", delta.Raw)
+	}
+}
+
+func TestConvertGeminiResponseToClaudeReleasesHeldTextWhenTheStreamEnds(t *testing.T) {
+	events := streamClaude(t, `{"stop_sequences":["</answer>"]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"partial </ans"}]}}]}`,
+		"[DONE]",
+	)
+	if got := streamedText(events); got != "partial </ans" {
+		t.Fatalf("text = %q, want the held-back text released", got)
+	}
+	if last := events[len(events)-1]; gjson.Get(last, "type").String() != "message_stop" {
+		t.Fatalf("last event = %s, want message_stop", last)
+	}
+}
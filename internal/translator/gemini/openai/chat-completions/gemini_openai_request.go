@@ -54,7 +54,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		out, _ = sjson.SetBytes(out, "generationConfig.thinkingConfig.thinkingBudget", -1)
 	}
 
-	// Temperature/top_p/top_k
+	// Temperature/top_p/top_k/seed
 	if tr := gjson.GetBytes(rawJSON, "temperature"); tr.Exists() && tr.Type == gjson.Number {
 		out, _ = sjson.SetBytes(out, "generationConfig.temperature", tr.Num)
 	}
@@ -64,6 +64,22 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 	if tkr := gjson.GetBytes(rawJSON, "top_k"); tkr.Exists() && tkr.Type == gjson.Number {
 		out, _ = sjson.SetBytes(out, "generationConfig.topK", tkr.Num)
 	}
+	if seed := gjson.GetBytes(rawJSON, "seed"); seed.Exists() && seed.Type == gjson.Number {
+		out, _ = sjson.SetBytes(out, "generationConfig.seed", seed.Int())
+	}
+
+	// frequency_penalty/presence_penalty, dropped for models that reject them
+	for _, penalty := range [][2]string{{"frequency_penalty", "frequencyPenalty"}, {"presence_penalty", "presencePenalty"}} {
+		value := gjson.GetBytes(rawJSON, penalty[0])
+		if !value.Exists() || value.Type != gjson.Number {
+			continue
+		}
+		if !util.GeminiSupportsPenalties(modelName) {
+			log.Debugf("dropping %s for model %s, which does not support it", penalty[0], modelName)
+			continue
+		}
+		out, _ = sjson.SetBytes(out, "generationConfig."+penalty[1], value.Num)
+	}
 
 	// messages -> systemInstruction + contents
 	messages := gjson.GetBytes(rawJSON, "messages")
@@ -97,12 +113,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			if role == "tool" {
 				toolCallID := m.Get("tool_call_id").String()
 				if toolCallID != "" {
-					c := m.Get("content")
-					if c.Type == gjson.String {
-						toolResponses[toolCallID] = c.String()
-					} else if c.IsObject() && c.Get("type").String() == "text" {
-						toolResponses[toolCallID] = c.Get("text").String()
-					}
+					toolResponses[toolCallID] = toolResultText(m.Get("content"))
 				}
 			}
 		}
@@ -114,128 +125,114 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 
 			if role == "system" && len(arr) > 1 {
 				// system -> system_instruction as a user message style
-				if content.Type == gjson.String {
-					out, _ = sjson.SetBytes(out, "system_instruction.role", "user")
-					out, _ = sjson.SetBytes(out, "system_instruction.parts.0.text", content.String())
-				} else if content.IsObject() && content.Get("type").String() == "text" {
+				if text := util.OpenAIContentText(content); text != "" {
 					out, _ = sjson.SetBytes(out, "system_instruction.role", "user")
-					out, _ = sjson.SetBytes(out, "system_instruction.parts.0.text", content.Get("text").String())
+					out, _ = sjson.SetBytes(out, "system_instruction.parts.0.text", text)
 				}
 			} else if role == "user" || (role == "system" && len(arr) == 1) {
 				// Build single user content node to avoid splitting into multiple contents
 				node := []byte(`{"role":"user","parts":[]}`)
-				if content.Type == gjson.String {
-					node, _ = sjson.SetBytes(node, "parts.0.text", content.String())
-				} else if content.IsArray() {
-					items := content.Array()
-					p := 0
-					for _, item := range items {
-						switch item.Get("type").String() {
-						case "text":
-							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
-							p++
-						case "image_url":
-							imageURL := item.Get("image_url.url").String()
-							if len(imageURL) > 5 {
-								pieces := strings.SplitN(imageURL[5:], ";", 2)
-								if len(pieces) == 2 && len(pieces[1]) > 7 {
-									mime := pieces[0]
-									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									p++
-								}
-							}
-						case "file":
-							filename := item.Get("file.filename").String()
-							fileData := item.Get("file.file_data").String()
-							ext := ""
-							if sp := strings.Split(filename, "."); len(sp) > 1 {
-								ext = sp[len(sp)-1]
-							}
-							if mimeType, ok := misc.MimeTypes[ext]; ok {
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
+				p := 0
+				for _, item := range util.OpenAIContentParts(content) {
+					switch item.Get("type").String() {
+					case "text":
+						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
+						p++
+					case "image_url":
+						imageURL := item.Get("image_url.url").String()
+						if len(imageURL) > 5 {
+							pieces := strings.SplitN(imageURL[5:], ";", 2)
+							if len(pieces) == 2 && len(pieces[1]) > 7 {
+								mime := pieces[0]
+								data := pieces[1][7:]
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
 								p++
-							} else {
-								log.Warnf("Unknown file name extension '%s' in user message, skip", ext)
 							}
 						}
+					case "file":
+						filename := item.Get("file.filename").String()
+						fileData := item.Get("file.file_data").String()
+						ext := ""
+						if sp := strings.Split(filename, "."); len(sp) > 1 {
+							ext = sp[len(sp)-1]
+						}
+						if mimeType, ok := misc.MimeTypes[ext]; ok {
+							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
+							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
+							p++
+						} else {
+							log.Warnf("Unknown file name extension '%s' in user message, skip", ext)
+						}
 					}
 				}
-				out, _ = sjson.SetRawBytes(out, "contents.-1", node)
+				// An empty user turn is dropped, as Gemini rejects contents without parts, and
+				// turns left next to one of the same role are merged into it.
+				if p > 0 {
+					out = util.AppendGeminiContent(out, "contents", node)
+				}
 			} else if role == "assistant" {
-				if content.Type == gjson.String {
-					// Assistant text -> single model content
-					node := []byte(`{"role":"model","parts":[{"text":""}]}`)
-					node, _ = sjson.SetBytes(node, "parts.0.text", content.String())
-					out, _ = sjson.SetRawBytes(out, "contents.-1", node)
-				} else if content.IsArray() {
-					// Assistant multimodal content (e.g. text + image) -> single model content with parts
-					node := []byte(`{"role":"model","parts":[]}`)
-					p := 0
-					for _, item := range content.Array() {
-						switch item.Get("type").String() {
-						case "text":
-							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
-							p++
-						case "image_url":
-							// If the assistant returned an inline data URL, preserve it for history fidelity.
-							imageURL := item.Get("image_url.url").String()
-							if len(imageURL) > 5 { // expect data:...
-								pieces := strings.SplitN(imageURL[5:], ";", 2)
-								if len(pieces) == 2 && len(pieces[1]) > 7 {
-									mime := pieces[0]
-									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									p++
-								}
+				// Assistant text and tool calls -> single model content
+				node := []byte(`{"role":"model","parts":[]}`)
+				p := 0
+				// Text and images keep their parts; tool calls follow as functionCall parts.
+				for _, item := range util.OpenAIContentParts(content) {
+					switch item.Get("type").String() {
+					case "text":
+						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
+						p++
+					case "image_url":
+						// If the assistant returned an inline data URL, preserve it for history fidelity.
+						imageURL := item.Get("image_url.url").String()
+						if len(imageURL) > 5 { // expect data:...
+							pieces := strings.SplitN(imageURL[5:], ";", 2)
+							if len(pieces) == 2 && len(pieces[1]) > 7 {
+								mime := pieces[0]
+								data := pieces[1][7:]
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
+								p++
 							}
 						}
 					}
-					out, _ = sjson.SetRawBytes(out, "contents.-1", node)
-				} else if !content.Exists() || content.Type == gjson.Null {
-					// Tool calls -> single model content with functionCall parts
-					tcs := m.Get("tool_calls")
-					if tcs.IsArray() {
-						node := []byte(`{"role":"model","parts":[]}`)
-						p := 0
-						fIDs := make([]string, 0)
-						for _, tc := range tcs.Array() {
-							if tc.Get("type").String() != "function" {
-								continue
-							}
-							fid := tc.Get("id").String()
-							fname := tc.Get("function.name").String()
-							fargs := tc.Get("function.arguments").String()
-							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
-							node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
-							p++
-							if fid != "" {
-								fIDs = append(fIDs, fid)
-							}
-						}
-						out, _ = sjson.SetRawBytes(out, "contents.-1", node)
+				}
+				fIDs := make([]string, 0)
+				for _, tc := range m.Get("tool_calls").Array() {
+					if tc.Get("type").String() != "function" {
+						continue
+					}
+					fid := tc.Get("id").String()
+					fname := tc.Get("function.name").String()
+					fargs := tc.Get("function.arguments").String()
+					if !gjson.Valid(fargs) {
+						fargs = "{}"
+					}
+					node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
+					node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
+					p++
+					if fid != "" {
+						fIDs = append(fIDs, fid)
+					}
+				}
+				if p > 0 {
+					out = util.AppendGeminiContent(out, "contents", node)
+				}
 
-						// Append a single tool content combining name + response per function
-						toolNode := []byte(`{"role":"tool","parts":[]}`)
-						pp := 0
-						for _, fid := range fIDs {
-							if name, ok := tcID2Name[fid]; ok {
-								toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", name)
-								resp := toolResponses[fid]
-								if resp == "" {
-									resp = "{}"
-								}
-								toolNode, _ = sjson.SetRawBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response", []byte(`{"result":`+quoteIfNeeded(resp)+`}`))
-								pp++
-							}
-						}
-						if pp > 0 {
-							out, _ = sjson.SetRawBytes(out, "contents.-1", toolNode)
-						}
+				// Tool results for these calls, matched by tool_call_id, follow as one function
+				// content with a functionResponse part per call so parallel calls stay paired.
+				toolNode := []byte(`{"role":"function","parts":[]}`)
+				pp := 0
+				for _, fid := range fIDs {
+					name, ok := tcID2Name[fid]
+					if !ok {
+						continue
 					}
+					toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", name)
+					toolNode, _ = sjson.SetRawBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response", functionResponseBody(toolResponses[fid]))
+					pp++
+				}
+				if pp > 0 {
+					out, _ = sjson.SetRawBytes(out, "contents.-1", toolNode)
 				}
 			}
 		}
@@ -250,12 +247,17 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			if t.Get("type").String() == "function" {
 				fn := t.Get("function")
 				if fn.Exists() && fn.IsObject() {
-					out, _ = sjson.SetRawBytes(out, fdPath+".-1", []byte(fn.Raw))
+					out, _ = sjson.SetRawBytes(out, fdPath+".-1", []byte(util.GeminiFunctionDeclaration(fn)))
 				}
 			}
 		}
 	}
 
+	// safety_settings is an extension field carrying Gemini safetySettings.
+	if safety := gjson.GetBytes(rawJSON, "safety_settings"); safety.IsArray() {
+		out, _ = sjson.SetRawBytes(out, "safetySettings", []byte(safety.Raw))
+	}
+
 	var pathsToType []string
 	root := gjson.ParseBytes(out)
 	util.Walk(root, "", "type", &pathsToType)
@@ -272,17 +274,41 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 // itoa converts int to string without strconv import for few usages.
 func itoa(i int) string { return fmt.Sprintf("%d", i) }
 
-// quoteIfNeeded ensures a string is valid JSON value (quotes plain text), pass-through for JSON objects/arrays.
-func quoteIfNeeded(s string) string {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return "\"\""
+// toolResultText returns the text of a tool message content: a string, a text part or an
+// array of text parts. Other content is passed through as raw JSON.
+func toolResultText(c gjson.Result) string {
+	switch {
+	case c.Type == gjson.String:
+		return c.String()
+	case c.IsObject() && c.Get("type").String() == "text":
+		return c.Get("text").String()
+	case c.IsArray():
+		var b strings.Builder
+		for _, item := range c.Array() {
+			if item.Get("type").String() == "text" {
+				b.WriteString(item.Get("text").String())
+			}
+		}
+		return b.String()
+	case c.Exists() && c.Type != gjson.Null:
+		return c.Raw
+	default:
+		return ""
 	}
-	if len(s) > 0 && (s[0] == '{' || s[0] == '[') {
-		return s
+}
+
+// functionResponseBody wraps a tool result as {"result": ...}. JSON objects and arrays are
+// embedded as is; anything else becomes a JSON string. A missing result becomes {}.
+func functionResponseBody(result string) []byte {
+	result = strings.TrimSpace(result)
+	if result == "" {
+		return []byte(`{"result":{}}`)
+	}
+	body := []byte(`{}`)
+	if (result[0] == '{' || result[0] == '[') && gjson.Valid(result) {
+		body, _ = sjson.SetRawBytes(body, "result", []byte(result))
+	} else {
+		body, _ = sjson.SetBytes(body, "result", result)
 	}
-	// escape quotes minimally
-	s = strings.ReplaceAll(s, "\\", "\\\\")
-	s = strings.ReplaceAll(s, "\"", "\\\"")
-	return "\"" + s + "\""
+	return body
 }
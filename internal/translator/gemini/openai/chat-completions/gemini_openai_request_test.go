@@ -0,0 +1,29 @@
+package chat_completions
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIRequestToGeminiKeepsRolesAlternating(t *testing.T) {
+	in := []byte(`{"messages":[
+		{"role":"user","content":"first"},
+		{"role":"assistant","content":""},
+		{"role":"user","content":[{"type":"text","text":"second"}]},
+		{"role":"assistant","content":[{"type":"text","text":"answer"}],"tool_calls":[{"id":"c1","type":"function","function":{"name":"f","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"c1","content":"done"}
+	]}`)
+	out := ConvertOpenAIRequestToGemini("gemini-2.5-pro", in, false)
+
+	contents := gjson.GetBytes(out, "contents").Array()
+	if got := gjson.GetBytes(out, "contents.#.role").Raw; got != `["user","model","function"]` {
+		t.Fatalf("roles = %s, want user, model, function", got)
+	}
+	if got := contents[0].Get("parts.#.text").Raw; got != `["first","second"]` {
+		t.Errorf("user parts = %s", got)
+	}
+	if !contents[1].Get("parts.1.functionCall").Exists() {
+		t.Errorf("model turn = %s, want the text then the call", contents[1].Raw)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -19,6 +20,16 @@ import (
 // convertGeminiResponseToOpenAIChatParams holds parameters for response conversion.
 type convertGeminiResponseToOpenAIChatParams struct {
 	UnixTimestamp int64
+	StopMatcher   *util.StopSequenceMatcher
+	// Finished is set once a chunk carried a finish reason; usage seen from then on is final.
+	Finished bool
+	// InterimUsage is set when the client asked for usage with stream_options.include_usage,
+	// which opts in to the running totals seen before the finish reason.
+	InterimUsage bool
+	// ResponseID and Model are those of the last chunk, for the chunk releasing text still
+	// held back when the stream ends.
+	ResponseID string
+	Model      string
 }
 
 // ConvertGeminiResponseToOpenAI translates a single chunk of a streaming response from the
@@ -39,6 +50,8 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 	if *param == nil {
 		*param = &convertGeminiResponseToOpenAIChatParams{
 			UnixTimestamp: 0,
+			StopMatcher:   util.NewStopSequenceMatcher(util.StopSequencesFromRequest(originalRequestRawJSON)),
+			InterimUsage:  gjson.GetBytes(originalRequestRawJSON, "stream_options.include_usage").Bool(),
 		}
 	}
 
@@ -47,7 +60,19 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 	}
 
 	if bytes.Equal(rawJSON, []byte("[DONE]")) {
-		return []string{}
+		// A stream ending without a finish reason still owes the client the text held back
+		// while checking for stop sequences.
+		params := (*param).(*convertGeminiResponseToOpenAIChatParams)
+		held := params.StopMatcher.Flush()
+		if held == "" {
+			return []string{}
+		}
+		template := `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}`
+		template, _ = sjson.Set(template, "id", params.ResponseID)
+		template, _ = sjson.Set(template, "created", params.UnixTimestamp)
+		template, _ = sjson.Set(template, "model", params.Model)
+		template, _ = sjson.Set(template, "choices.0.delta.content", held)
+		return []string{template}
 	}
 
 	// Initialize the OpenAI SSE template.
@@ -56,6 +81,7 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 	// Extract and set the model version.
 	if modelVersionResult := gjson.GetBytes(rawJSON, "modelVersion"); modelVersionResult.Exists() {
 		template, _ = sjson.Set(template, "model", modelVersionResult.String())
+		(*param).(*convertGeminiResponseToOpenAIChatParams).Model = modelVersionResult.String()
 	}
 
 	// Extract and set the creation timestamp.
@@ -72,28 +98,57 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 	// Extract and set the response ID.
 	if responseIDResult := gjson.GetBytes(rawJSON, "responseId"); responseIDResult.Exists() {
 		template, _ = sjson.Set(template, "id", responseIDResult.String())
+		(*param).(*convertGeminiResponseToOpenAIChatParams).ResponseID = responseIDResult.String()
 	}
 
 	// Extract and set the finish reason.
-	if finishReasonResult := gjson.GetBytes(rawJSON, "candidates.0.finishReason"); finishReasonResult.Exists() {
-		template, _ = sjson.Set(template, "choices.0.finish_reason", finishReasonResult.String())
-		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReasonResult.String())
+	// Output withheld by a safety filter, including a blocked prompt, ends as content_filter.
+	if finishReason, blocked := util.GeminiFinishReason(gjson.ParseBytes(rawJSON)); finishReason != "" {
+		if blocked {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", "content_filter")
+		} else {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
+		}
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
+		(*param).(*convertGeminiResponseToOpenAIChatParams).Finished = true
 	}
 
-	// Extract and set usage metadata (token counts).
-	if usageResult := gjson.GetBytes(rawJSON, "usageMetadata"); usageResult.Exists() {
+	// Extract and set usage metadata (token counts). Gemini repeats usageMetadata while it
+	// streams; counts seen before the finish reason are running totals. They go to the
+	// x_interim_usage extension for clients that asked for usage and are dropped for the
+	// others, so usage itself only ever holds the final counts.
+	usageResult := gjson.GetBytes(rawJSON, "usageMetadata")
+	usageField := "usage"
+	if !(*param).(*convertGeminiResponseToOpenAIChatParams).Finished {
+		usageField = "x_interim_usage"
+		if !(*param).(*convertGeminiResponseToOpenAIChatParams).InterimUsage {
+			usageResult = gjson.Result{}
+		}
+	}
+	if usageResult.Exists() {
 		if candidatesTokenCountResult := usageResult.Get("candidatesTokenCount"); candidatesTokenCountResult.Exists() {
-			template, _ = sjson.Set(template, "usage.completion_tokens", candidatesTokenCountResult.Int())
+			template, _ = sjson.Set(template, usageField+".completion_tokens", candidatesTokenCountResult.Int())
 		}
 		if totalTokenCountResult := usageResult.Get("totalTokenCount"); totalTokenCountResult.Exists() {
-			template, _ = sjson.Set(template, "usage.total_tokens", totalTokenCountResult.Int())
+			template, _ = sjson.Set(template, usageField+".total_tokens", totalTokenCountResult.Int())
 		}
 		promptTokenCount := usageResult.Get("promptTokenCount").Int()
 		thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
-		template, _ = sjson.Set(template, "usage.prompt_tokens", promptTokenCount+thoughtsTokenCount)
+		template, _ = sjson.Set(template, usageField+".prompt_tokens", promptTokenCount+thoughtsTokenCount)
 		if thoughtsTokenCount > 0 {
-			template, _ = sjson.Set(template, "usage.completion_tokens_details.reasoning_tokens", thoughtsTokenCount)
+			template, _ = sjson.Set(template, usageField+".completion_tokens_details.reasoning_tokens", thoughtsTokenCount)
+		}
+	}
+
+	// Once a client stop sequence has matched, only trailing usage is forwarded.
+	stopMatcher := (*param).(*convertGeminiResponseToOpenAIChatParams).StopMatcher
+	if stopMatcher.Stopped() {
+		if !usageResult.Exists() {
+			return []string{}
 		}
+		template, _ = sjson.Set(template, "choices.0.finish_reason", nil)
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", nil)
+		return []string{template}
 	}
 
 	// Process the main content part of the response.
@@ -114,9 +169,16 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 				if partResult.Get("thought").Bool() {
 					template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", partTextResult.String())
 				} else {
-					template, _ = sjson.Set(template, "choices.0.delta.content", partTextResult.String())
+					text := partTextResult.String()
+					if stopMatcher != nil {
+						text = stopMatcher.Feed(text)
+					}
+					template, _ = sjson.Set(template, "choices.0.delta.content", text)
 				}
 				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
+				if stopMatcher.Stopped() {
+					break
+				}
 			} else if functionCallResult.Exists() {
 				// Handle function call content.
 				toolCallsResult := gjson.Get(template, "choices.0.delta.tool_calls")
@@ -165,6 +227,19 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 		}
 	}
 
+	if stopMatcher != nil {
+		if !stopMatcher.Stopped() && gjson.GetBytes(rawJSON, "candidates.0.finishReason").Exists() {
+			if held := stopMatcher.Flush(); held != "" {
+				template, _ = sjson.Set(template, "choices.0.delta.content", gjson.Get(template, "choices.0.delta.content").String()+held)
+				template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
+			}
+		}
+		if stopMatcher.Stopped() {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", "stop")
+			template, _ = sjson.Set(template, "choices.0.stop_sequence", stopMatcher.Matched())
+		}
+	}
+
 	return []string{template}
 }
 
@@ -202,9 +277,13 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 		template, _ = sjson.Set(template, "id", responseIDResult.String())
 	}
 
-	if finishReasonResult := gjson.GetBytes(rawJSON, "candidates.0.finishReason"); finishReasonResult.Exists() {
-		template, _ = sjson.Set(template, "choices.0.finish_reason", finishReasonResult.String())
-		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReasonResult.String())
+	if finishReason, blocked := util.GeminiFinishReason(gjson.ParseBytes(rawJSON)); finishReason != "" {
+		if blocked {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", "content_filter")
+		} else {
+			template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
+		}
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
 	}
 
 	if usageResult := gjson.GetBytes(rawJSON, "usageMetadata"); usageResult.Exists() {
@@ -290,5 +369,13 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 		}
 	}
 
+	if content := gjson.Get(template, "choices.0.message.content"); content.Type == gjson.String {
+		if text, matched := util.TruncateAtStopSequence(content.String(), util.StopSequencesFromRequest(originalRequestRawJSON)); matched != "" {
+			template, _ = sjson.Set(template, "choices.0.message.content", text)
+			template, _ = sjson.Set(template, "choices.0.finish_reason", "stop")
+			template, _ = sjson.Set(template, "choices.0.stop_sequence", matched)
+		}
+	}
+
 	return template
 }
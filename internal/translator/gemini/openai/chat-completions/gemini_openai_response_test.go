@@ -0,0 +1,101 @@
+package chat_completions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// streamOpenAI runs chunks through the streaming translator and returns the emitted chunks.
+func streamOpenAI(t *testing.T, request string, chunks ...string) []string {
+	t.Helper()
+	var param any
+	var out []string
+	for _, chunk := range chunks {
+		out = append(out, ConvertGeminiResponseToOpenAI(context.Background(), "", []byte(request), nil, []byte(chunk), &param)...)
+	}
+	return out
+}
+
+func streamedContent(chunks []string) string {
+	var content string
+	for _, chunk := range chunks {
+		content += gjson.Get(chunk, "choices.0.delta.content").String()
+	}
+	return content
+}
+
+func TestConvertGeminiResponseToOpenAIStopsAtASequenceAcrossChunks(t *testing.T) {
+	out := streamOpenAI(t, `{"stop":["###","STOP"]}`,
+		`{"responseId":"r1","candidates":[{"content":{"parts":[{"text":"Hello ST"}]}}]}`,
+		`{"responseId":"r1","candidates":[{"content":{"parts":[{"text":"OP and more"}]}}]}`,
+		`{"responseId":"r1","candidates":[{"content":{"parts":[{"text":" ignored"}]},"finishReason":"STOP"}]}`,
+		"[DONE]",
+	)
+	if got := streamedContent(out); got != "Hello " {
+		t.Fatalf("content = %q, want the text before STOP", got)
+	}
+	var finish, sequence string
+	for _, chunk := range out {
+		if reason := gjson.Get(chunk, "choices.0.finish_reason"); reason.Exists() && reason.Type != gjson.Null {
+			finish, sequence = reason.String(), gjson.Get(chunk, "choices.0.stop_sequence").String()
+		}
+	}
+	if finish != "stop" || sequence != "STOP" {
+		t.Fatalf("finish = %q with sequence %q, want stop with STOP", finish, sequence)
+	}
+}
+
+func TestConvertGeminiResponseToOpenAIReleasesHeldTextWhenTheStreamEnds(t *testing.T) {
+	out := streamOpenAI(t, `{"stop":"STOP"}`,
+		`{"responseId":"r2","modelVersion":"gemini-test","candidates":[{"content":{"parts":[{"text":"almost ST"}]}}]}`,
+		"[DONE]",
+	)
+	if got := streamedContent(out); got != "almost ST" {
+		t.Fatalf("content = %q, want the held-back text released", got)
+	}
+	last := out[len(out)-1]
+	if gjson.Get(last, "id").String() != "r2" || gjson.Get(last, "model").String() != "gemini-test" {
+		t.Fatalf("release chunk = %s, want the id and model of the stream", last)
+	}
+}
+
+func TestConvertGeminiResponseToOpenAIReleasesHeldTextAtTheFinishReason(t *testing.T) {
+	out := streamOpenAI(t, `{"stop":"STOP"}`,
+		`{"candidates":[{"content":{"parts":[{"text":"almost ST"}]}}]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"!"}]},"finishReason":"STOP"}]}`,
+		"[DONE]",
+	)
+	if got := streamedContent(out); got != "almost ST!" {
+		t.Fatalf("content = %q, want all text", got)
+	}
+}
+
+func TestConvertGeminiResponseToOpenAIStreamsInterimUsageOnlyWhenAsked(t *testing.T) {
+	chunks := []string{
+		`{"candidates":[{"content":{"parts":[{"text":"a"}]}}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":1,"totalTokenCount":6}}`,
+		`{"candidates":[{"content":{"parts":[{"text":"b"}]}}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2,"totalTokenCount":7}}`,
+		`{"candidates":[{"content":{"parts":[{"text":"c"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":3,"totalTokenCount":8}}`,
+	}
+
+	out := streamOpenAI(t, `{"stream_options":{"include_usage":true}}`, chunks...)
+	var interim []int64
+	for _, chunk := range out {
+		if usage := gjson.Get(chunk, "x_interim_usage.completion_tokens"); usage.Exists() {
+			interim = append(interim, usage.Int())
+		}
+	}
+	if len(interim) != 2 || interim[0] != 1 || interim[1] != 2 {
+		t.Fatalf("interim completion tokens = %v, want 1 and 2", interim)
+	}
+	if final := gjson.Get(out[len(out)-1], "usage.total_tokens").Int(); final != 8 {
+		t.Fatalf("final total tokens = %d, want 8", final)
+	}
+
+	for _, chunk := range streamOpenAI(t, `{}`, chunks...) {
+		if gjson.Get(chunk, "x_interim_usage").Exists() {
+			t.Fatalf("chunk %s carries interim usage the client did not ask for", chunk)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -178,7 +179,7 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 				}
 				if params := tool.Get("parameters"); params.Exists() {
 					// Convert parameter types from OpenAI format to Gemini format
-					cleaned := params.Raw
+					cleaned, _, _ := util.GeminiFunctionSchema(params, "parameters", false)
 					// Convert type values to uppercase for Gemini
 					paramsResult := gjson.Parse(cleaned)
 					if properties := paramsResult.Get("properties"); properties.Exists() {
@@ -262,5 +263,10 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 		}
 	}
 
+	// safety_settings is an extension field carrying Gemini safetySettings.
+	if safety := gjson.GetBytes(rawJSON, "safety_settings"); safety.IsArray() {
+		out, _ = sjson.SetRaw(out, "safetySettings", safety.Raw)
+	}
+
 	return []byte(out)
 }
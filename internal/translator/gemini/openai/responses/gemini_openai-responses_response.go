@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -414,7 +415,15 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 			completed, _ = sjson.Set(completed, "response.output", outputs)
 		}
 
-		out = append(out, emitEvent("response.completed", completed))
+		// Output withheld by a safety filter ends the response as incomplete.
+		if util.GeminiBlocked(fr.String()) {
+			completed, _ = sjson.Set(completed, "type", "response.incomplete")
+			completed, _ = sjson.Set(completed, "response.status", "incomplete")
+			completed, _ = sjson.Set(completed, "response.incomplete_details.reason", "content_filter")
+			out = append(out, emitEvent("response.incomplete", completed))
+		} else {
+			out = append(out, emitEvent("response.completed", completed))
+		}
 	}
 
 	return out
@@ -437,6 +446,10 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		id = fmt.Sprintf("resp_%s", id)
 	}
 	resp, _ = sjson.Set(resp, "id", id)
+	if _, blocked := util.GeminiFinishReason(root); blocked {
+		resp, _ = sjson.Set(resp, "status", "incomplete")
+		resp, _ = sjson.Set(resp, "incomplete_details.reason", "content_filter")
+	}
 
 	// created_at: map from createTime if available
 	createdAt := time.Now().Unix()
@@ -556,6 +556,11 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 
 				flushThinking()
 				flushText()
+			} else if content := message.Get("content"); content.Type == gjson.String && content.String() != "" {
+				contentBlocks = append(contentBlocks, map[string]interface{}{
+					"type": "text",
+					"text": content.String(),
+				})
 			}
 
 			if toolCalls := message.Get("tool_calls"); toolCalls.Exists() && toolCalls.IsArray() {
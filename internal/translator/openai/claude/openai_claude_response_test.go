@@ -0,0 +1,24 @@
+package claude
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIResponseToClaudeNonStreamKeepsStringContent(t *testing.T) {
+	response := []byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello there"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2}}`)
+	out := ConvertOpenAIResponseToClaudeNonStream(context.Background(), "gpt-4o", nil, nil, response, nil)
+
+	content := gjson.Get(out, "content")
+	if len(content.Array()) != 1 {
+		t.Fatalf("content = %s, want one text block", content.Raw)
+	}
+	if got := content.Get("0.type").String(); got != "text" {
+		t.Fatalf("content[0].type = %q, want text", got)
+	}
+	if got := content.Get("0.text").String(); got != "Hello there" {
+		t.Fatalf("content[0].text = %q, want %q", got, "Hello there")
+	}
+}
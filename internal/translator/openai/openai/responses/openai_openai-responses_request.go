@@ -47,6 +47,14 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 		out, _ = sjson.Set(out, "parallel_tool_calls", parallelToolCalls.Bool())
 	}
 
+	// Log probabilities are requested through include, with top_logprobs alternatives
+	if topLogprobs := root.Get("top_logprobs"); topLogprobs.Exists() {
+		out, _ = sjson.Set(out, "logprobs", true)
+		out, _ = sjson.Set(out, "top_logprobs", topLogprobs.Int())
+	} else if root.Get(`include.#(=="message.output_text.logprobs")`).Exists() {
+		out, _ = sjson.Set(out, "logprobs", true)
+	}
+
 	// Convert instructions to system message
 	if instructions := root.Get("instructions"); instructions.Exists() {
 		systemMessage := `{"role":"system","content":""}`
@@ -3,6 +3,7 @@ package responses
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -21,6 +22,7 @@ type oaiToResponsesState struct {
 	// aggregation buffers for response.output
 	// Per-output message text buffers by index
 	MsgTextBuf   map[int]*strings.Builder
+	MsgLogprobs  map[int][]string // index -> raw logprobs entries of the message text
 	ReasoningBuf strings.Builder
 	FuncArgsBuf  map[int]*strings.Builder // index -> args
 	FuncNames    map[int]string           // index -> name
@@ -34,6 +36,20 @@ type oaiToResponsesState struct {
 	FuncItemDone map[int]bool
 }
 
+// logprobsJSON returns the logprobs collected for the message at index as a JSON array.
+func (st *oaiToResponsesState) logprobsJSON(index int) string {
+	return "[" + strings.Join(st.MsgLogprobs[index], ",") + "]"
+}
+
+// responsesLogprobs returns the logprobs of a chat completion choice as the JSON array of an
+// output_text part, which uses the same entries as the chat logprobs content.
+func responsesLogprobs(choice gjson.Result) string {
+	if lp := choice.Get("logprobs.content"); lp.IsArray() {
+		return lp.Raw
+	}
+	return "[]"
+}
+
 func emitRespEvent(event string, payload string) string {
 	return fmt.Sprintf("event: %s\ndata: %s", event, payload)
 }
@@ -47,6 +63,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 			FuncNames:       make(map[int]string),
 			FuncCallIDs:     make(map[int]string),
 			MsgTextBuf:      make(map[int]*strings.Builder),
+			MsgLogprobs:     make(map[int][]string),
 			MsgItemAdded:    make(map[int]bool),
 			MsgContentAdded: make(map[int]bool),
 			MsgItemDone:     make(map[int]bool),
@@ -74,6 +91,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 		st.Created = root.Get("created").Int()
 		// reset aggregation state for a new streaming response
 		st.MsgTextBuf = make(map[int]*strings.Builder)
+		st.MsgLogprobs = make(map[int][]string)
 		st.ReasoningBuf.Reset()
 		st.ReasoningID = ""
 		st.ReasoningIndex = 0
@@ -132,6 +150,12 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 					msg, _ = sjson.Set(msg, "output_index", idx)
 					msg, _ = sjson.Set(msg, "content_index", 0)
 					msg, _ = sjson.Set(msg, "delta", c.String())
+					if lp := choice.Get("logprobs.content"); lp.IsArray() {
+						msg, _ = sjson.SetRaw(msg, "logprobs", lp.Raw)
+						for _, entry := range lp.Array() {
+							st.MsgLogprobs[idx] = append(st.MsgLogprobs[idx], entry.Raw)
+						}
+					}
 					out = append(out, emitRespEvent("response.output_text.delta", msg))
 					// aggregate for response.output
 					if st.MsgTextBuf[idx] == nil {
@@ -182,6 +206,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						done, _ = sjson.Set(done, "output_index", idx)
 						done, _ = sjson.Set(done, "content_index", 0)
 						done, _ = sjson.Set(done, "text", fullText)
+						done, _ = sjson.SetRaw(done, "logprobs", st.logprobsJSON(idx))
 						out = append(out, emitRespEvent("response.output_text.done", done))
 
 						partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
@@ -190,6 +215,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						partDone, _ = sjson.Set(partDone, "output_index", idx)
 						partDone, _ = sjson.Set(partDone, "content_index", 0)
 						partDone, _ = sjson.Set(partDone, "part.text", fullText)
+						partDone, _ = sjson.SetRaw(partDone, "part.logprobs", st.logprobsJSON(idx))
 						out = append(out, emitRespEvent("response.content_part.done", partDone))
 
 						itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`
@@ -197,6 +223,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						itemDone, _ = sjson.Set(itemDone, "output_index", idx)
 						itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
 						itemDone, _ = sjson.Set(itemDone, "item.content.0.text", fullText)
+						itemDone, _ = sjson.SetRaw(itemDone, "item.content.0.logprobs", st.logprobsJSON(idx))
 						out = append(out, emitRespEvent("response.output_item.done", itemDone))
 						st.MsgItemDone[idx] = true
 					}
@@ -282,6 +309,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							done, _ = sjson.Set(done, "output_index", i)
 							done, _ = sjson.Set(done, "content_index", 0)
 							done, _ = sjson.Set(done, "text", fullText)
+							done, _ = sjson.SetRaw(done, "logprobs", st.logprobsJSON(i))
 							out = append(out, emitRespEvent("response.output_text.done", done))
 
 							partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
@@ -290,6 +318,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							partDone, _ = sjson.Set(partDone, "output_index", i)
 							partDone, _ = sjson.Set(partDone, "content_index", 0)
 							partDone, _ = sjson.Set(partDone, "part.text", fullText)
+							partDone, _ = sjson.SetRaw(partDone, "part.logprobs", st.logprobsJSON(i))
 							out = append(out, emitRespEvent("response.content_part.done", partDone))
 
 							itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`
@@ -297,6 +326,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							itemDone, _ = sjson.Set(itemDone, "output_index", i)
 							itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
 							itemDone, _ = sjson.Set(itemDone, "item.content.0.text", fullText)
+							itemDone, _ = sjson.SetRaw(itemDone, "item.content.0.logprobs", st.logprobsJSON(i))
 							out = append(out, emitRespEvent("response.output_item.done", itemDone))
 							st.MsgItemDone[i] = true
 						}
@@ -463,7 +493,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							"content": []interface{}{map[string]interface{}{
 								"type":        "output_text",
 								"annotations": []interface{}{},
-								"logprobs":    []interface{}{},
+								"logprobs":    json.RawMessage(st.logprobsJSON(i)),
 								"text":        txt,
 							}},
 							"role": "assistant",
@@ -653,7 +683,7 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 						"content": []interface{}{map[string]interface{}{
 							"type":        "output_text",
 							"annotations": []interface{}{},
-							"logprobs":    []interface{}{},
+							"logprobs":    json.RawMessage(responsesLogprobs(choice)),
 							"text":        c.String(),
 						}},
 						"role": "assistant",
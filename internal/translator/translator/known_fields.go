@@ -0,0 +1,113 @@
+package translator
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+)
+
+// fieldSet lists the request fields a format knows. A field mapped to a nested fieldSet holds
+// an object whose own fields are filtered the same way; one mapped to nil is kept as it is.
+type fieldSet map[string]fieldSet
+
+func fields(names ...string) fieldSet {
+	set := make(fieldSet, len(names))
+	for _, name := range names {
+		set[name] = nil
+	}
+	return set
+}
+
+// geminiRequestFields lists the GenerateContentRequest fields, in both the JSON and the proto
+// spelling the API accepts.
+var geminiRequestFields = fields(
+	"model", "contents", "tools", "toolConfig", "tool_config", "safetySettings", "safety_settings",
+	"systemInstruction", "system_instruction", "generationConfig", "generation_config",
+	"cachedContent", "cached_content", "labels",
+)
+
+// knownRequestFields maps the formats whose upstreams reject unknown fields to the fields
+// their request bodies may carry.
+var knownRequestFields = map[string]fieldSet{
+	"gemini": geminiRequestFields,
+	"gemini-cli": func() fieldSet {
+		request := fields("session_id")
+		for name := range geminiRequestFields {
+			request[name] = nil
+		}
+		set := fields("project", "model", "user_prompt_id")
+		set["request"] = request
+		return set
+	}(),
+	"claude": fields(
+		"model", "messages", "system", "max_tokens", "metadata", "stop_sequences", "stream",
+		"temperature", "top_p", "top_k", "tools", "tool_choice", "thinking", "service_tier",
+		"container", "mcp_servers", "context_management",
+	),
+	"codex": fields(
+		"model", "instructions", "input", "tools", "tool_choice", "parallel_tool_calls",
+		"reasoning", "store", "stream", "include", "prompt_cache_key", "text", "temperature",
+		"top_p", "max_output_tokens", "metadata", "previous_response_id", "truncation", "user",
+		"service_tier", "background", "prompt", "safety_identifier",
+	),
+	"openai": fields(
+		"model", "messages", "stream", "stream_options", "temperature", "top_p", "n", "stop",
+		"max_tokens", "max_completion_tokens", "presence_penalty", "frequency_penalty",
+		"logit_bias", "logprobs", "top_logprobs", "user", "tools", "tool_choice",
+		"parallel_tool_calls", "response_format", "seed", "reasoning_effort", "modalities",
+		"audio", "prediction", "metadata", "store", "service_tier", "web_search_options",
+		"functions", "function_call", "safety_identifier", "prompt_cache_key", "verbosity",
+	),
+}
+
+// StripUnknownFields removes the top-level fields of a request body in format that the
+// format's allow-list does not name, and returns the body with the names removed. Bodies of
+// formats without an allow-list, and bodies that are not JSON objects, are returned as they
+// are.
+func StripUnknownFields(format string, rawJSON []byte) ([]byte, []string) {
+	known, ok := knownRequestFields[format]
+	if !ok {
+		return rawJSON, nil
+	}
+	return stripFields(known, rawJSON, "")
+}
+
+func stripFields(known fieldSet, rawJSON []byte, prefix string) ([]byte, []string) {
+	root := gjson.ParseBytes(rawJSON)
+	if !root.IsObject() {
+		return rawJSON, nil
+	}
+	var removed []string
+	var out bytes.Buffer
+	out.WriteByte('{')
+	changed := false
+	root.ForEach(func(key, value gjson.Result) bool {
+		nested, allowed := known[key.String()]
+		if !allowed {
+			removed = append(removed, prefix+key.String())
+			changed = true
+			return true
+		}
+		raw := []byte(value.Raw)
+		if nested != nil {
+			var inner []string
+			raw, inner = stripFields(nested, raw, prefix+key.String()+".")
+			if len(inner) > 0 {
+				removed = append(removed, inner...)
+				changed = true
+			}
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		out.WriteString(key.Raw)
+		out.WriteByte(':')
+		out.Write(raw)
+		return true
+	})
+	if !changed {
+		return rawJSON, nil
+	}
+	out.WriteByte('}')
+	return out.Bytes(), removed
+}
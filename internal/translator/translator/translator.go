@@ -87,3 +87,23 @@ func Response(from, to string, ctx context.Context, modelName string, originalRe
 func ResponseNonStream(from, to string, ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
 	return registry.TranslateNonStream(ctx, sdktranslator.FromString(from), sdktranslator.FromString(to), modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 }
+
+// ResponseWithError translates a streaming response like Response, but reports a translator
+// failure or a chunk that is not valid JSON instead of returning it.
+//
+// Returns:
+//   - []string: The translated response lines
+//   - error: A *sdktranslator.TranslationError when the conversion failed
+func ResponseWithError(from, to string, ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) ([]string, error) {
+	return registry.TranslateStreamWithError(ctx, sdktranslator.FromString(from), sdktranslator.FromString(to), modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+}
+
+// ResponseNonStreamWithError translates a non-streaming response like ResponseNonStream, but
+// reports a translator failure or an empty or invalid JSON result instead of returning it.
+//
+// Returns:
+//   - string: The translated response JSON
+//   - error: A *sdktranslator.TranslationError when the conversion failed
+func ResponseNonStreamWithError(from, to string, ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) (string, error) {
+	return registry.TranslateNonStreamWithError(ctx, sdktranslator.FromString(from), sdktranslator.FromString(to), modelName, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+}
@@ -63,6 +63,12 @@ type RequestStatistics struct {
 	successCount  int64
 	failureCount  int64
 	totalTokens   int64
+	totalCost     float64
+
+	clientDisconnectedCount int64
+	moderationSkippedCount  int64
+	contentFilteredCount    int64
+	shadowFailureCount      int64
 
 	apis map[string]*apiStats
 
@@ -76,6 +82,7 @@ type RequestStatistics struct {
 type apiStats struct {
 	TotalRequests int64
 	TotalTokens   int64
+	TotalCost     float64
 	Models        map[string]*modelStats
 }
 
@@ -83,6 +90,7 @@ type apiStats struct {
 type modelStats struct {
 	TotalRequests int64
 	TotalTokens   int64
+	TotalCost     float64
 	Details       []RequestDetail
 }
 
@@ -90,6 +98,39 @@ type modelStats struct {
 type RequestDetail struct {
 	Timestamp time.Time  `json:"timestamp"`
 	Tokens    TokenStats `json:"tokens"`
+	// OutputCap is the output token cap the request was held to, if any.
+	OutputCap int64 `json:"output_cap,omitempty"`
+	// OutputTruncated reports whether the response was cut off at OutputCap.
+	OutputTruncated bool `json:"output_truncated,omitempty"`
+	// SystemFingerprint identifies the backend configuration that served the request.
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	// Status is "client_disconnected" for requests cancelled because the client went away,
+	// "stream_error" for streams that ended with an error event and "content_filtered" for
+	// streams ended by moderation.
+	Status string `json:"status,omitempty"`
+	// StatusCode is the HTTP status of the error that ended a "stream_error" stream.
+	StatusCode int `json:"status_code,omitempty"`
+	// Metadata is the metadata the client attached to the request.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ResponseTagged reports whether the response got the audit tag.
+	ResponseTagged bool `json:"response_tagged,omitempty"`
+	// ResponseTagRef is the reference the audit tag carries.
+	ResponseTagRef string `json:"response_tag_ref,omitempty"`
+	// ToolSchemaTokensSaved estimates the prompt tokens spared by not resending tool schemas.
+	ToolSchemaTokensSaved int64 `json:"tool_schema_tokens_saved,omitempty"`
+	// ModerationRule is the moderation rule that ended the stream.
+	ModerationRule string `json:"moderation_rule,omitempty"`
+	// AuthID identifies the account that served the request.
+	AuthID string `json:"auth_id,omitempty"`
+	// AuthLabel is the label of that account.
+	AuthLabel string `json:"auth_label,omitempty"`
+	// ContextReuse describes the conversation a Gemini Web request continued.
+	ContextReuse *coreusage.ContextReuse `json:"context_reuse,omitempty"`
+	// Cost is the estimated cost of the request in dollars, zero when no price matched.
+	Cost float64 `json:"cost,omitempty"`
+	// Shadow reports whether the request mirrored the request ShadowOf to another provider.
+	Shadow   bool   `json:"shadow,omitempty"`
+	ShadowOf string `json:"shadow_of,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -99,6 +140,12 @@ type TokenStats struct {
 	ReasoningTokens int64 `json:"reasoning_tokens"`
 	CachedTokens    int64 `json:"cached_tokens"`
 	TotalTokens     int64 `json:"total_tokens"`
+	// CacheCreationTokens counts the prompt tokens Claude wrote to its cache.
+	CacheCreationTokens int64 `json:"cache_creation_tokens,omitempty"`
+	// AcceptedPredictionTokens and RejectedPredictionTokens count the predicted output
+	// tokens that did and did not appear in the completion.
+	AcceptedPredictionTokens int64 `json:"accepted_prediction_tokens,omitempty"`
+	RejectedPredictionTokens int64 `json:"rejected_prediction_tokens,omitempty"`
 }
 
 // StatisticsSnapshot represents an immutable view of the aggregated metrics.
@@ -107,6 +154,18 @@ type StatisticsSnapshot struct {
 	SuccessCount  int64 `json:"success_count"`
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
+	// TotalCost sums the estimated cost of the priced requests, in dollars.
+	TotalCost float64 `json:"total_cost"`
+	// ClientDisconnectedCount counts requests abandoned by their client, which are neither
+	// successes nor failures.
+	ClientDisconnectedCount int64 `json:"client_disconnected_count"`
+	// ModerationSkippedCount counts moderation checks skipped because the checker failed or
+	// exceeded its latency budget.
+	ModerationSkippedCount int64 `json:"moderation_skipped_count"`
+	// ContentFilteredCount counts streams ended by moderation.
+	ContentFilteredCount int64 `json:"content_filtered_count"`
+	// ShadowFailureCount counts mirrored requests that failed. Their clients never see it.
+	ShadowFailureCount int64 `json:"shadow_failure_count"`
 
 	APIs map[string]APISnapshot `json:"apis"`
 
@@ -120,6 +179,7 @@ type StatisticsSnapshot struct {
 type APISnapshot struct {
 	TotalRequests int64                    `json:"total_requests"`
 	TotalTokens   int64                    `json:"total_tokens"`
+	TotalCost     float64                  `json:"total_cost"`
 	Models        map[string]ModelSnapshot `json:"models"`
 }
 
@@ -127,6 +187,7 @@ type APISnapshot struct {
 type ModelSnapshot struct {
 	TotalRequests int64           `json:"total_requests"`
 	TotalTokens   int64           `json:"total_tokens"`
+	TotalCost     float64         `json:"total_cost"`
 	Details       []RequestDetail `json:"details"`
 }
 
@@ -169,6 +230,7 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	if modelName == "" {
 		modelName = "unknown"
 	}
+	cost, _ := RequestCost(record.Provider, record.Model, record.Detail)
 	dayKey := timestamp.Format("2006-01-02")
 	hourKey := timestamp.Hour()
 
@@ -176,19 +238,42 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	defer s.mu.Unlock()
 
 	s.totalRequests++
-	if success {
+	switch {
+	case record.Status == coreusage.StatusClientDisconnected:
+		s.clientDisconnectedCount++
+	case success && record.Status != coreusage.StatusStreamError:
 		s.successCount++
-	} else {
+	default:
 		s.failureCount++
 	}
 	s.totalTokens += totalTokens
+	s.totalCost += cost
 
 	stats, ok := s.apis[statsKey]
 	if !ok {
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{Timestamp: timestamp, Tokens: detail})
+	s.updateAPIStats(stats, modelName, RequestDetail{
+		Timestamp:             timestamp,
+		Tokens:                detail,
+		OutputCap:             record.OutputCap,
+		OutputTruncated:       record.OutputTruncated,
+		SystemFingerprint:     record.SystemFingerprint,
+		Status:                record.Status,
+		StatusCode:            record.StatusCode,
+		Metadata:              record.Metadata,
+		ResponseTagged:        record.ResponseTagged,
+		ResponseTagRef:        record.ResponseTagRef,
+		ToolSchemaTokensSaved: record.ToolSchemaTokensSaved,
+		ModerationRule:        record.ModerationRule,
+		AuthID:                record.AuthID,
+		AuthLabel:             record.AuthLabel,
+		ContextReuse:          record.ContextReuse,
+		Cost:                  cost,
+		Shadow:                record.Shadow,
+		ShadowOf:              record.ShadowOf,
+	})
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
@@ -196,9 +281,41 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	s.tokensByHour[hourKey] += totalTokens
 }
 
+// RecordModerationSkipped counts a moderation check that was skipped.
+func (s *RequestStatistics) RecordModerationSkipped() {
+	if s == nil || !statisticsEnabled.Load() {
+		return
+	}
+	s.mu.Lock()
+	s.moderationSkippedCount++
+	s.mu.Unlock()
+}
+
+// RecordContentFiltered counts a stream ended by moderation. The request itself is counted
+// by the usage record its executor publishes.
+func (s *RequestStatistics) RecordContentFiltered() {
+	if s == nil || !statisticsEnabled.Load() {
+		return
+	}
+	s.mu.Lock()
+	s.contentFilteredCount++
+	s.mu.Unlock()
+}
+
+// RecordShadowFailure counts a mirrored request that failed.
+func (s *RequestStatistics) RecordShadowFailure() {
+	if s == nil || !statisticsEnabled.Load() {
+		return
+	}
+	s.mu.Lock()
+	s.shadowFailureCount++
+	s.mu.Unlock()
+}
+
 func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
 	stats.TotalRequests++
 	stats.TotalTokens += detail.Tokens.TotalTokens
+	stats.TotalCost += detail.Cost
 	modelStatsValue, ok := stats.Models[model]
 	if !ok {
 		modelStatsValue = &modelStats{}
@@ -206,6 +323,7 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	}
 	modelStatsValue.TotalRequests++
 	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
+	modelStatsValue.TotalCost += detail.Cost
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
 }
 
@@ -222,13 +340,19 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result.TotalRequests = s.totalRequests
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
+	result.ClientDisconnectedCount = s.clientDisconnectedCount
+	result.ModerationSkippedCount = s.moderationSkippedCount
+	result.ContentFilteredCount = s.contentFilteredCount
+	result.ShadowFailureCount = s.shadowFailureCount
 	result.TotalTokens = s.totalTokens
+	result.TotalCost = s.totalCost
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
 		apiSnapshot := APISnapshot{
 			TotalRequests: stats.TotalRequests,
 			TotalTokens:   stats.TotalTokens,
+			TotalCost:     stats.TotalCost,
 			Models:        make(map[string]ModelSnapshot, len(stats.Models)),
 		}
 		for modelName, modelStatsValue := range stats.Models {
@@ -237,6 +361,7 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 			apiSnapshot.Models[modelName] = ModelSnapshot{
 				TotalRequests: modelStatsValue.TotalRequests,
 				TotalTokens:   modelStatsValue.TotalTokens,
+				TotalCost:     modelStatsValue.TotalCost,
 				Details:       requestDetails,
 			}
 		}
@@ -317,6 +442,11 @@ func normaliseDetail(detail coreusage.Detail) TokenStats {
 		ReasoningTokens: detail.ReasoningTokens,
 		CachedTokens:    detail.CachedTokens,
 		TotalTokens:     detail.TotalTokens,
+
+		CacheCreationTokens: detail.CacheCreationTokens,
+
+		AcceptedPredictionTokens: detail.AcceptedPredictionTokens,
+		RejectedPredictionTokens: detail.RejectedPredictionTokens,
 	}
 	if tokens.TotalTokens == 0 {
 		tokens.TotalTokens = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
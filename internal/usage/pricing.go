@@ -0,0 +1,71 @@
+package usage
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/wildcard"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+var (
+	pricingMu sync.RWMutex
+	prices    []config.ModelPrice
+)
+
+// SetModelPrices replaces the token prices used to cost requests.
+func SetModelPrices(models []config.ModelPrice) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	prices = append([]config.ModelPrice(nil), models...)
+}
+
+// RequestCost returns the cost in dollars of a request to model served by provider with the
+// token usage of detail, and false when no price matches.
+//
+// Cached prompt tokens are part of the input tokens for every provider except Claude, which
+// reports cache reads and cache writes separately; cache writes are priced as
+// cache-write-input. Output is the total less the input when that is larger,
+// since Gemini counts thinking tokens apart from the candidates.
+func RequestCost(provider, model string, detail coreusage.Detail) (float64, bool) {
+	price, ok := modelPrice(provider, model)
+	if !ok {
+		return 0, false
+	}
+	input, cached := detail.InputTokens, detail.CachedTokens
+	if provider != "claude" {
+		cached = min(cached, input)
+		input -= cached
+	}
+	output := detail.OutputTokens
+	if rest := detail.TotalTokens - detail.InputTokens; rest > output {
+		output = rest
+	}
+	cachedPrice := price.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = price.Input
+	}
+	writePrice := price.CacheWriteInput
+	if writePrice == 0 {
+		writePrice = price.Input
+	}
+	cost := float64(input)*price.Input + float64(cached)*cachedPrice + float64(detail.CacheCreationTokens)*writePrice + float64(output)*price.Output
+	return cost / 1e6, true
+}
+
+// modelPrice returns the first configured price matching provider and model.
+func modelPrice(provider, model string) (config.ModelPrice, bool) {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	model = strings.ToLower(model)
+	for _, price := range prices {
+		if price.Provider != "" && !strings.EqualFold(price.Provider, provider) {
+			continue
+		}
+		if wildcard.Match(strings.ToLower(strings.TrimSpace(price.Model)), model) {
+			return price, true
+		}
+	}
+	return config.ModelPrice{}, false
+}
@@ -0,0 +1,29 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestRequestCostPricesClaudeCacheWrites(t *testing.T) {
+	SetModelPrices([]config.ModelPrice{
+		{Model: "claude-sonnet-4*", Provider: "claude", Input: 3, Output: 15, CachedInput: 0.3, CacheWriteInput: 3.75},
+		{Model: "*/claude-*", Input: 4, Output: 20},
+	})
+	t.Cleanup(func() { SetModelPrices(nil) })
+
+	detail := coreusage.Detail{InputTokens: 1e6, OutputTokens: 1e6, CachedTokens: 1e6, CacheCreationTokens: 1e6, TotalTokens: 2e6}
+	cost, ok := RequestCost("claude", "claude-sonnet-4-20250514", detail)
+	if !ok || math.Abs(cost-(3+15+0.3+3.75)) > 1e-9 {
+		t.Fatalf("cost = %v, %t", cost, ok)
+	}
+
+	// Cache writes default to the input price, and '*' spans the '/' of the model ID.
+	cost, ok = RequestCost("openai-compatibility", "anthropic/claude-sonnet-4", coreusage.Detail{CacheCreationTokens: 1e6})
+	if !ok || math.Abs(cost-4) > 1e-9 {
+		t.Fatalf("cost of a vendor-prefixed model = %v, %t", cost, ok)
+	}
+}
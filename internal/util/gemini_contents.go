@@ -0,0 +1,27 @@
+package util
+
+import (
+	"strconv"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// AppendGeminiContent appends content, a Gemini {"role","parts"} node, to the contents array
+// at path in body. When the last content has the same role the parts are added to it
+// instead, so dropping an empty turn never leaves two consecutive turns of one role.
+func AppendGeminiContent(body []byte, path string, content []byte) []byte {
+	contents := gjson.GetBytes(body, path).Array()
+	if n := len(contents); n > 0 {
+		last := contents[n-1]
+		if last.Get("role").String() == gjson.GetBytes(content, "role").String() {
+			lastPath := path + "." + strconv.Itoa(n-1) + ".parts"
+			for _, part := range gjson.GetBytes(content, "parts").Array() {
+				body, _ = sjson.SetRawBytes(body, lastPath+".-1", []byte(part.Raw))
+			}
+			return body
+		}
+	}
+	body, _ = sjson.SetRawBytes(body, path+".-1", content)
+	return body
+}
@@ -0,0 +1,19 @@
+package util
+
+import "strings"
+
+// penaltyModelPrefixes lists the Gemini model families accepting frequencyPenalty and
+// presencePenalty in generationConfig. Other models reject requests carrying them.
+var penaltyModelPrefixes = []string{"gemini-1.5-", "gemini-2.0-", "gemini-2.5-"}
+
+// GeminiSupportsPenalties reports whether the Gemini model accepts frequency and presence
+// penalties.
+func GeminiSupportsPenalties(model string) bool {
+	model = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(model)), "models/")
+	for _, prefix := range penaltyModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// GeminiSafetySetting is one entry of the safetySettings of a Gemini request.
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// geminiHarmCategories lists the harm categories accepted in safetySettings.
+var geminiHarmCategories = map[string]struct{}{
+	"HARM_CATEGORY_HARASSMENT":        {},
+	"HARM_CATEGORY_HATE_SPEECH":       {},
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT": {},
+	"HARM_CATEGORY_DANGEROUS_CONTENT": {},
+	"HARM_CATEGORY_CIVIC_INTEGRITY":   {},
+}
+
+// geminiThresholdStrictness ranks the block thresholds from the loosest to the strictest.
+var geminiThresholdStrictness = map[string]int{
+	"OFF":                    0,
+	"BLOCK_NONE":             1,
+	"BLOCK_ONLY_HIGH":        2,
+	"BLOCK_MEDIUM_AND_ABOVE": 3,
+	"BLOCK_LOW_AND_ABOVE":    4,
+}
+
+// NormalizeGeminiSafetySetting returns category and threshold in upper case, or an error
+// when either is not a known value.
+func NormalizeGeminiSafetySetting(category, threshold string) (GeminiSafetySetting, error) {
+	setting := GeminiSafetySetting{
+		Category:  strings.ToUpper(strings.TrimSpace(category)),
+		Threshold: strings.ToUpper(strings.TrimSpace(threshold)),
+	}
+	if _, ok := geminiHarmCategories[setting.Category]; !ok {
+		return setting, fmt.Errorf("unknown safety category %q", category)
+	}
+	if _, ok := geminiThresholdStrictness[setting.Threshold]; !ok {
+		return setting, fmt.Errorf("unknown safety threshold %q for %s", threshold, setting.Category)
+	}
+	return setting, nil
+}
+
+// ParseGeminiSafetySettings validates a safetySettings array and returns its entries
+// normalized. A missing or null value yields no entries.
+func ParseGeminiSafetySettings(settings gjson.Result) ([]GeminiSafetySetting, error) {
+	if !settings.Exists() || settings.Type == gjson.Null {
+		return nil, nil
+	}
+	if !settings.IsArray() {
+		return nil, fmt.Errorf("safety settings must be an array")
+	}
+	var out []GeminiSafetySetting
+	var err error
+	settings.ForEach(func(_, entry gjson.Result) bool {
+		var setting GeminiSafetySetting
+		setting, err = NormalizeGeminiSafetySetting(entry.Get("category").String(), entry.Get("threshold").String())
+		if err != nil {
+			return false
+		}
+		out = append(out, setting)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GeminiThresholdLooser reports whether threshold blocks less content than floor.
+func GeminiThresholdLooser(threshold, floor string) bool {
+	return geminiThresholdStrictness[threshold] < geminiThresholdStrictness[floor]
+}
+
+// GeminiBlocked reports whether a Gemini finishReason or promptFeedback.blockReason means
+// the output was withheld by a safety or content policy filter.
+func GeminiBlocked(reason string) bool {
+	switch reason {
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return true
+	}
+	return false
+}
+
+// GeminiFinishReason returns the finishReason of the first candidate of a Gemini response,
+// or the blockReason of its prompt feedback when no candidate was returned. blocked reports
+// whether a safety or content filter withheld the output.
+func GeminiFinishReason(response gjson.Result) (reason string, blocked bool) {
+	if finish := response.Get("candidates.0.finishReason"); finish.Exists() {
+		return finish.String(), GeminiBlocked(finish.String())
+	}
+	if block := response.Get("promptFeedback.blockReason"); block.Exists() && !response.Get("candidates").Exists() {
+		return block.String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,428 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// geminiSchemaKeywords are the JSON Schema keywords Gemini function declarations accept.
+var geminiSchemaKeywords = map[string]bool{
+	"type": true, "format": true, "title": true, "description": true, "nullable": true,
+	"enum": true, "items": true, "minItems": true, "maxItems": true, "properties": true,
+	"required": true, "minProperties": true, "maxProperties": true, "minLength": true,
+	"maxLength": true, "pattern": true, "example": true, "default": true, "minimum": true,
+	"maximum": true, "propertyOrdering": true,
+}
+
+// silentSchemaKeywords are dropped without a note: they only carry metadata, or the
+// definitions that $ref uses are inlined from.
+var silentSchemaKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "$defs": true, "definitions": true,
+}
+
+// SchemaError reports the part of a function schema Gemini cannot represent.
+type SchemaError struct {
+	// Path locates the offending schema in the request, such as
+	// "tools[0].function.parameters.properties.unit".
+	Path   string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+// GeminiFunctionSchema converts the JSON Schema of a function's parameters, as OpenAI
+// clients send it, into one Gemini function declarations accept. Local $ref are inlined,
+// nullable unions become nullable, const becomes a one-value enum, exclusive bounds become
+// inclusive ones and other unsupported keywords are dropped. It returns the converted
+// schema and the transformations applied, each named once.
+//
+// A schema with parts Gemini has no equivalent for, such as a recursive $ref or a union of
+// several non-null types, is converted with those parts loosened; with strict set it fails
+// instead with a *SchemaError naming the part, path being the location of schema.
+func GeminiFunctionSchema(schema gjson.Result, path string, strict bool) (string, []string, error) {
+	c := &geminiSchemaConverter{root: schema, strict: strict, resolving: make(map[string]bool), noted: make(map[string]bool)}
+	out := c.convert(schema, path)
+	if c.err != nil {
+		return "", c.notes, c.err
+	}
+	return out, c.notes, nil
+}
+
+// GeminiFunctionDeclaration converts an OpenAI function definition into a Gemini function
+// declaration: the strict flag, which Gemini does not know, is dropped and the parameters
+// are converted by GeminiFunctionSchema without failing.
+func GeminiFunctionDeclaration(fn gjson.Result) string {
+	decl := fn.Raw
+	decl, _ = sjson.Delete(decl, "strict")
+	if params := fn.Get("parameters"); params.IsObject() {
+		converted, _, _ := GeminiFunctionSchema(params, "parameters", false)
+		decl, _ = sjson.SetRaw(decl, "parameters", converted)
+	}
+	return decl
+}
+
+type geminiSchemaConverter struct {
+	root      gjson.Result
+	strict    bool
+	resolving map[string]bool
+	notes     []string
+	noted     map[string]bool
+	err       error
+}
+
+func (c *geminiSchemaConverter) note(transform string) {
+	if !c.noted[transform] {
+		c.noted[transform] = true
+		c.notes = append(c.notes, transform)
+	}
+}
+
+// unrepresentable records a part Gemini cannot express. It fails the conversion in strict
+// mode and is noted otherwise.
+func (c *geminiSchemaConverter) unrepresentable(path, reason, transform string) {
+	if c.strict {
+		if c.err == nil {
+			c.err = &SchemaError{Path: path, Reason: reason}
+		}
+		return
+	}
+	c.note(transform)
+}
+
+func (c *geminiSchemaConverter) convert(node gjson.Result, path string) string {
+	if !node.IsObject() {
+		return "{}"
+	}
+	if ref := node.Get(`\$ref`); ref.Exists() {
+		return c.inlineRef(node, ref.String(), path)
+	}
+	obj := &schemaObject{}
+	nullable := false
+	node.ForEach(func(key, value gjson.Result) bool {
+		name := key.String()
+		switch name {
+		case "properties":
+			props := &schemaObject{}
+			value.ForEach(func(prop, sub gjson.Result) bool {
+				props.set(prop.String(), c.convert(sub, path+".properties."+prop.String()))
+				return true
+			})
+			// Merged, not set, so properties an earlier allOf or anyOf brought are kept.
+			mergeSchemaObject(obj, `{"properties":`+props.raw()+`}`)
+		case "required":
+			if value.IsArray() {
+				mergeSchemaObject(obj, `{"required":`+value.Raw+`}`)
+			}
+		case "items":
+			if value.IsArray() {
+				c.unrepresentable(path+".items", "tuple items are not supported", "tuple items reduced to their first schema")
+				first := value.Get("0")
+				obj.set(name, c.convert(first, path+".items[0]"))
+			} else {
+				obj.set(name, c.convert(value, path+".items"))
+			}
+		case "type":
+			types := schemaTypes(value)
+			var kept []string
+			for _, t := range types {
+				if t == "null" {
+					nullable = true
+				} else {
+					kept = append(kept, t)
+				}
+			}
+			switch {
+			case len(kept) == 1:
+				obj.set(name, strconv.Quote(kept[0]))
+			case len(kept) > 1:
+				c.unrepresentable(path+".type", fmt.Sprintf("union type %s is not supported", value.Raw), "union types reduced to one type")
+				obj.set(name, strconv.Quote(preferredSchemaType(kept)))
+			}
+			if value.IsArray() && nullable {
+				c.note("null in type mapped to nullable")
+			}
+		case "anyOf", "oneOf":
+			merged, isNullable := c.union(value, path+"."+name, name)
+			nullable = nullable || isNullable
+			mergeSchemaObject(obj, merged)
+		case "allOf":
+			for i, part := range value.Array() {
+				mergeSchemaObject(obj, c.convert(part, fmt.Sprintf("%s.allOf[%d]", path, i)))
+			}
+			c.note("allOf merged")
+		case "const":
+			if value.Type == gjson.String {
+				obj.set("enum", "["+value.Raw+"]")
+				if !node.Get("type").Exists() {
+					obj.set("type", `"string"`)
+				}
+				c.note("const mapped to enum")
+			} else {
+				c.unrepresentable(path+".const", "const "+value.Raw+" is not a string", "non-string const dropped")
+			}
+		case "enum":
+			values := make([]string, 0, len(value.Array()))
+			strs := true
+			for _, v := range value.Array() {
+				switch v.Type {
+				case gjson.Null:
+					nullable = true
+				case gjson.String:
+					values = append(values, v.Raw)
+				default:
+					strs = false
+				}
+			}
+			if strs {
+				obj.set(name, "["+strings.Join(values, ",")+"]")
+			} else {
+				c.note("non-string enum dropped")
+			}
+		case "default":
+			if value.Type != gjson.Null {
+				obj.set(name, value.Raw)
+			}
+		case "exclusiveMinimum", "exclusiveMaximum":
+			if value.Type == gjson.Number {
+				bound := "minimum"
+				if name == "exclusiveMaximum" {
+					bound = "maximum"
+				}
+				obj.set(bound, value.Raw)
+				c.note(name + " mapped to " + bound + " (inclusive)")
+			}
+		case "additionalProperties":
+			c.note("additionalProperties dropped")
+		default:
+			if geminiSchemaKeywords[name] {
+				if !obj.has(name) {
+					obj.set(name, value.Raw)
+				}
+			} else if !silentSchemaKeywords[name] {
+				c.note(name + " dropped")
+			}
+		}
+		return true
+	})
+	if nullable {
+		obj.set("nullable", "true")
+	}
+	c.pruneRequired(obj)
+	return obj.raw()
+}
+
+// union converts an anyOf or oneOf. Null alternatives make the result nullable; a single
+// remaining alternative is inlined, and several are reduced to the first.
+func (c *geminiSchemaConverter) union(value gjson.Result, path, keyword string) (string, bool) {
+	nullable := false
+	var kept []string
+	for i, alt := range value.Array() {
+		if types := schemaTypes(alt.Get("type")); len(types) == 1 && types[0] == "null" && !alt.Get("properties").Exists() {
+			nullable = true
+			continue
+		}
+		kept = append(kept, c.convert(alt, fmt.Sprintf("%s[%d]", path, i)))
+	}
+	if nullable {
+		c.note(keyword + " with null mapped to nullable")
+	}
+	switch len(kept) {
+	case 0:
+		return "{}", nullable
+	case 1:
+		if !nullable {
+			c.note(keyword + " of one schema inlined")
+		}
+		return kept[0], nullable
+	}
+	c.unrepresentable(path, fmt.Sprintf("%s of %d schemas is not supported", keyword, len(kept)), keyword+" reduced to its first schema")
+	return kept[0], nullable
+}
+
+// inlineRef replaces a $ref to a definition of the same schema with the definition.
+// Keywords next to the $ref, such as a description, take precedence.
+func (c *geminiSchemaConverter) inlineRef(node gjson.Result, ref, path string) string {
+	target, ok := resolveLocalRef(c.root, ref)
+	if !ok {
+		c.unrepresentable(path, fmt.Sprintf("$ref %q cannot be resolved", ref), "unresolvable $ref replaced by an object")
+		return `{"type":"object"}`
+	}
+	if c.resolving[ref] {
+		c.unrepresentable(path, fmt.Sprintf("recursive $ref %q is not supported", ref), "recursive $ref cut off")
+		return `{"type":"object"}`
+	}
+	c.resolving[ref] = true
+	obj := &schemaObject{}
+	mergeSchemaObject(obj, c.convert(target, path))
+	delete(c.resolving, ref)
+	c.note("$ref inlined")
+
+	siblings := &schemaObject{}
+	node.ForEach(func(key, value gjson.Result) bool {
+		if key.String() != "$ref" {
+			siblings.set(key.String(), value.Raw)
+		}
+		return true
+	})
+	if len(siblings.keys) > 0 {
+		mergeSchemaObject(obj, c.convert(gjson.Parse(siblings.raw()), path))
+	}
+	return obj.raw()
+}
+
+// pruneRequired drops the names in required without a property, which Gemini rejects.
+func (c *geminiSchemaConverter) pruneRequired(obj *schemaObject) {
+	required, ok := obj.get("required")
+	if !ok {
+		return
+	}
+	props := gjson.Parse(mustRaw(obj.get("properties")))
+	kept := make([]string, 0)
+	dropped := false
+	for _, name := range gjson.Parse(required).Array() {
+		if props.Get(gjson.Escape(name.String())).Exists() {
+			kept = append(kept, name.String())
+		} else {
+			dropped = true
+		}
+	}
+	if dropped {
+		c.note("required names without a property dropped")
+	}
+	raw, _ := json.Marshal(kept)
+	obj.set("required", string(raw))
+}
+
+// resolveLocalRef looks up a JSON pointer reference into root, such as "#/$defs/Unit".
+func resolveLocalRef(root gjson.Result, ref string) (gjson.Result, bool) {
+	if !strings.HasPrefix(ref, "#/") {
+		return gjson.Result{}, false
+	}
+	node := root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		node = node.Get(gjson.Escape(token))
+		if !node.Exists() {
+			return gjson.Result{}, false
+		}
+	}
+	return node, node.IsObject()
+}
+
+func schemaTypes(value gjson.Result) []string {
+	if value.IsArray() {
+		types := make([]string, 0, len(value.Array()))
+		for _, t := range value.Array() {
+			types = append(types, t.String())
+		}
+		return types
+	}
+	if value.Exists() {
+		return []string{value.String()}
+	}
+	return nil
+}
+
+// preferredSchemaType picks the type a union is reduced to, as sanitizeTypeFields does.
+func preferredSchemaType(types []string) string {
+	preferred := ""
+	for _, t := range types {
+		switch {
+		case t == "string":
+			return t
+		case t == "number" || t == "integer":
+			preferred = t
+		case preferred == "":
+			preferred = t
+		}
+	}
+	return preferred
+}
+
+// mergeSchemaObject adds the keywords of the schema raw to obj. Properties and required
+// names are combined; other keywords of raw replace those of obj.
+func mergeSchemaObject(obj *schemaObject, raw string) {
+	gjson.Parse(raw).ForEach(func(key, value gjson.Result) bool {
+		name := key.String()
+		existing, ok := obj.get(name)
+		switch {
+		case ok && name == "properties":
+			props := &schemaObject{}
+			mergeSchemaObject(props, existing)
+			mergeSchemaObject(props, value.Raw)
+			obj.set(name, props.raw())
+		case ok && name == "required":
+			names := gjson.Parse(existing).Array()
+			seen := make(map[string]bool)
+			merged := make([]string, 0, len(names))
+			for _, n := range append(names, value.Array()...) {
+				if !seen[n.String()] {
+					seen[n.String()] = true
+					merged = append(merged, n.String())
+				}
+			}
+			rawNames, _ := json.Marshal(merged)
+			obj.set(name, string(rawNames))
+		default:
+			obj.set(name, value.Raw)
+		}
+		return true
+	})
+}
+
+func mustRaw(raw string, ok bool) string {
+	if !ok {
+		return "{}"
+	}
+	return raw
+}
+
+// schemaObject builds a JSON object keeping the order its keys were first set in, so
+// converted properties keep the order the client declared them in.
+type schemaObject struct {
+	keys   []string
+	values map[string]string
+}
+
+func (o *schemaObject) set(key, raw string) {
+	if o.values == nil {
+		o.values = make(map[string]string)
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = raw
+}
+
+func (o *schemaObject) get(key string) (string, bool) {
+	raw, ok := o.values[key]
+	return raw, ok
+}
+
+func (o *schemaObject) has(key string) bool {
+	_, ok := o.values[key]
+	return ok
+}
+
+func (o *schemaObject) raw() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		b.Write(name)
+		b.WriteByte(':')
+		b.WriteString(o.values[key])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// geminiSchemaFixture is a case of testdata/gemini_schema: the schema to convert and
+// either the converted schema or the path the conversion fails at.
+type geminiSchemaFixture struct {
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+	Want   json.RawMessage `json:"want"`
+	Error  string          `json:"error"`
+}
+
+func TestGeminiFunctionSchemaFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "gemini_schema", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures found: %v", err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			data, errRead := os.ReadFile(file)
+			if errRead != nil {
+				t.Fatal(errRead)
+			}
+			var fixture geminiSchemaFixture
+			if errRead = json.Unmarshal(data, &fixture); errRead != nil {
+				t.Fatalf("malformed fixture: %v", errRead)
+			}
+			out, _, errConvert := GeminiFunctionSchema(gjson.ParseBytes(fixture.Schema), "parameters", fixture.Strict)
+			if fixture.Error != "" {
+				var schemaErr *SchemaError
+				if !errors.As(errConvert, &schemaErr) {
+					t.Fatalf("error = %v, want a *SchemaError", errConvert)
+				}
+				if schemaErr.Path != fixture.Error {
+					t.Fatalf("error path = %q, want %q", schemaErr.Path, fixture.Error)
+				}
+				return
+			}
+			if errConvert != nil {
+				t.Fatalf("unexpected error: %v", errConvert)
+			}
+			var got, want any
+			if errRead = json.Unmarshal([]byte(out), &got); errRead != nil {
+				t.Fatalf("converted schema is not JSON: %s", out)
+			}
+			_ = json.Unmarshal(fixture.Want, &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("converted schema = %s, want %s", out, fixture.Want)
+			}
+		})
+	}
+}
+
+func TestGeminiFunctionSchemaKeepsPropertyOrder(t *testing.T) {
+	schema := `{"type":"object","properties":{"b":{"type":"string"}},"allOf":[{"properties":{"a":{"type":"string"}}}]}`
+	out, _, err := GeminiFunctionSchema(gjson.Parse(schema), "parameters", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	gjson.Get(out, "properties").ForEach(func(key, _ gjson.Result) bool {
+		names = append(names, key.String())
+		return true
+	})
+	if !reflect.DeepEqual(names, []string{"b", "a"}) {
+		t.Fatalf("property order = %v, want [b a]", names)
+	}
+}
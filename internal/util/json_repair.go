@@ -0,0 +1,125 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrTruncatedJSON is returned by RepairJSON when the JSON value in the text is cut off
+// before its closing brackets or quote, so it cannot be repaired without guessing its end.
+var ErrTruncatedJSON = errors.New("json value is truncated")
+
+// RepairJSON returns text as valid JSON when it holds a JSON value that is only slightly
+// malformed: wrapped in markdown code fences or prose, or with trailing commas. ok is false
+// when text is already valid or cannot be repaired, in which case it is returned unchanged;
+// err is ErrTruncatedJSON when the value was cut off early.
+func RepairJSON(text string) (repaired string, ok bool, err error) {
+	if json.Valid([]byte(text)) {
+		return text, false, nil
+	}
+	candidate := strings.TrimSpace(text)
+	if fenced, found := fencedBlock(candidate); found {
+		candidate = fenced
+	}
+	start := strings.IndexAny(candidate, "{[")
+	if start < 0 {
+		return text, false, nil
+	}
+	candidate, complete := extractJSON(candidate[start:])
+	if !complete {
+		return text, false, ErrTruncatedJSON
+	}
+	if !json.Valid([]byte(candidate)) {
+		return text, false, nil
+	}
+	return candidate, true, nil
+}
+
+// fencedBlock returns the content of the first markdown code fence in text.
+func fencedBlock(text string) (string, bool) {
+	open := strings.Index(text, "```")
+	if open < 0 {
+		return "", false
+	}
+	body := text[open+3:]
+	// Skip the info string, such as "json", up to the end of the opening line.
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		body = body[nl+1:]
+	} else {
+		return "", false
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body), true
+}
+
+// extractJSON walks the JSON value at the start of text in a single pass, dropping commas
+// that precede a closing bracket and everything after the value ends. complete is false when
+// text ends inside the value.
+func extractJSON(text string) (value string, complete bool) {
+	out := make([]byte, 0, len(text))
+	var stack []byte
+	inString, escaped := false, false
+	// comma is the offset in out of the last comma outside a string, or -1 once anything
+	// but whitespace follows it.
+	comma := -1
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			out = append(out, ch)
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case ' ', '\t', '\r', '\n':
+			out = append(out, ch)
+			continue
+		case ',':
+			comma = len(out)
+			out = append(out, ch)
+			continue
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != ch {
+				return string(out), false
+			}
+			stack = stack[:len(stack)-1]
+			if comma >= 0 {
+				// Remove the comma together with the whitespace before it, keeping the
+				// whitespace after it so the layout is kept.
+				before := comma
+				for before > 0 && isJSONSpace(out[before-1]) {
+					before--
+				}
+				out = append(out[:before], out[comma+1:]...)
+			}
+			out = append(out, ch)
+			comma = -1
+			if len(stack) == 0 {
+				return string(out), true
+			}
+			continue
+		}
+		comma = -1
+		out = append(out, ch)
+	}
+	return string(out), false
+}
+
+func isJSONSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'
+}
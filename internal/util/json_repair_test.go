@@ -0,0 +1,66 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+		ok   bool
+	}{
+		{name: "valid", text: `{"a":1}`, want: `{"a":1}`},
+		{name: "fenced", text: "```json\n{\"a\":1}\n```", want: `{"a":1}`, ok: true},
+		{name: "prose", text: `Here it is: {"a":[1,2]} hope this helps`, want: `{"a":[1,2]}`, ok: true},
+		{name: "trailing commas", text: "{\"a\":[1,2,],\n \"b\":{\"c\":3,\n},\n}", want: "{\"a\":[1,2],\n \"b\":{\"c\":3\n}\n}", ok: true},
+		{name: "comma in string", text: `{"a":"x,]",}`, want: `{"a":"x,]"}`, ok: true},
+		{name: "no json", text: "no value here", want: "no value here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := RepairJSON(tt.text)
+			if err != nil {
+				t.Fatalf("RepairJSON() error = %v", err)
+			}
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("RepairJSON() = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRepairJSONReportsTruncation(t *testing.T) {
+	for _, text := range []string{
+		`{"a":[1,2`,
+		"```json\n{\"a\":\"cut off",
+		`Sure: [{"a":1},`,
+	} {
+		got, ok, err := RepairJSON(text)
+		if !errors.Is(err, ErrTruncatedJSON) {
+			t.Fatalf("RepairJSON(%q) error = %v, want ErrTruncatedJSON", text, err)
+		}
+		if ok || got != text {
+			t.Fatalf("RepairJSON(%q) = %q, %v; want the text unchanged", text, got, ok)
+		}
+	}
+}
+
+func TestRepairJSONScansLargeInputOnce(t *testing.T) {
+	text := "[" + strings.Repeat(`{"a":[1,],},`, 200000) + "]"
+	start := time.Now()
+	got, ok, err := RepairJSON(text)
+	if err != nil || !ok {
+		t.Fatalf("RepairJSON() = %v, %v", ok, err)
+	}
+	if want := "[" + strings.TrimSuffix(strings.Repeat(`{"a":[1]},`, 200000), ",") + "]"; got != want {
+		t.Fatal("RepairJSON() returned unexpected output for large input")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("RepairJSON() took %v on %d bytes", elapsed, len(text))
+	}
+}
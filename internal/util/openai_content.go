@@ -0,0 +1,76 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// OpenAIContentParts returns the content of an OpenAI chat message as a list of typed parts,
+// whichever shape the client sent it in. A string becomes one text part, a single part
+// object a list of one, and bare strings inside an array text parts. The text types of the
+// Responses API (input_text, output_text) and refusals are returned as "text" parts and an
+// image_url given as a plain string is moved under "url". Text parts without text are
+// dropped, so empty or null content yields no parts at all.
+func OpenAIContentParts(content gjson.Result) []gjson.Result {
+	var items []gjson.Result
+	switch {
+	case content.Type == gjson.String:
+		items = []gjson.Result{content}
+	case content.IsObject():
+		items = []gjson.Result{content}
+	case content.IsArray():
+		items = content.Array()
+	default:
+		return nil
+	}
+	parts := make([]gjson.Result, 0, len(items))
+	for _, item := range items {
+		if item.Type == gjson.String {
+			if item.String() != "" {
+				parts = append(parts, textPart(item.String()))
+			}
+			continue
+		}
+		if !item.IsObject() {
+			continue
+		}
+		switch item.Get("type").String() {
+		case "text", "input_text", "output_text":
+			if text := item.Get("text").String(); text != "" {
+				parts = append(parts, textPart(text))
+			}
+		case "refusal":
+			if text := item.Get("refusal").String(); text != "" {
+				parts = append(parts, textPart(text))
+			}
+		case "image_url":
+			if url := item.Get("image_url"); url.Type == gjson.String {
+				raw, _ := sjson.Set(`{"type":"image_url"}`, "image_url.url", url.String())
+				parts = append(parts, gjson.Parse(raw))
+				continue
+			}
+			parts = append(parts, item)
+		default:
+			parts = append(parts, item)
+		}
+	}
+	return parts
+}
+
+// OpenAIContentText joins the text parts of an OpenAI message content, one per line.
+func OpenAIContentText(content gjson.Result) string {
+	var texts []string
+	for _, part := range OpenAIContentParts(content) {
+		if part.Get("type").String() == "text" {
+			texts = append(texts, part.Get("text").String())
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func textPart(text string) gjson.Result {
+	raw, _ := sjson.Set(`{"type":"text"}`, "text", text)
+	return gjson.Parse(raw)
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIContentParts(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"string", `"hello"`, 1},
+		{"text array", `[{"type":"text","text":"a"},{"type":"text","text":"b"}]`, 2},
+		{"bare strings", `["a","b"]`, 2},
+		{"single part", `{"type":"text","text":"a"}`, 1},
+		{"empty string", `""`, 0},
+		{"null", `null`, 0},
+		{"empty text part", `[{"type":"text","text":""}]`, 0},
+	}
+	for _, tc := range cases {
+		if got := len(OpenAIContentParts(gjson.Parse(tc.content))); got != tc.want {
+			t.Errorf("%s: %d parts, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestOpenAIContentTextSeparatesParts(t *testing.T) {
+	content := gjson.Parse(`[{"type":"text","text":"first"},{"type":"image_url","image_url":"data:x"},{"type":"input_text","text":"second"}]`)
+	if got := OpenAIContentText(content); got != "first\nsecond" {
+		t.Errorf("text = %q, want parts on their own lines", got)
+	}
+	if got := OpenAIContentText(gjson.Parse(`"only"`)); got != "only" {
+		t.Errorf("text = %q", got)
+	}
+}
+
+func TestAppendGeminiContentMergesSameRole(t *testing.T) {
+	body := []byte(`{"contents":[]}`)
+	body = AppendGeminiContent(body, "contents", []byte(`{"role":"user","parts":[{"text":"a"}]}`))
+	body = AppendGeminiContent(body, "contents", []byte(`{"role":"user","parts":[{"text":"b"}]}`))
+	body = AppendGeminiContent(body, "contents", []byte(`{"role":"model","parts":[{"text":"c"}]}`))
+
+	contents := gjson.GetBytes(body, "contents").Array()
+	if len(contents) != 2 {
+		t.Fatalf("contents = %s, want two turns", gjson.GetBytes(body, "contents").Raw)
+	}
+	if got := contents[0].Get("parts.#.text").Raw; got != `["a","b"]` {
+		t.Errorf("user parts = %s", got)
+	}
+}
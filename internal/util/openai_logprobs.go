@@ -0,0 +1,43 @@
+package util
+
+import (
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// CompletionsLogprobs converts the logprobs of an OpenAI chat choice, {"content":[{token,
+// logprob, top_logprobs}]}, into the legacy completions shape with parallel tokens,
+// token_logprobs, top_logprobs and text_offset lists. offset is the character position of
+// the first token in the choice text; the position after the last token is returned so
+// streamed chunks can continue from it. It returns nil when the choice carries no logprobs.
+func CompletionsLogprobs(logprobs gjson.Result, offset int) (map[string]any, int) {
+	content := logprobs.Get("content")
+	if !content.IsArray() {
+		return nil, offset
+	}
+	entries := content.Array()
+	tokens := make([]string, 0, len(entries))
+	tokenLogprobs := make([]float64, 0, len(entries))
+	topLogprobs := make([]map[string]float64, 0, len(entries))
+	textOffsets := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		token := entry.Get("token").String()
+		tokens = append(tokens, token)
+		tokenLogprobs = append(tokenLogprobs, entry.Get("logprob").Float())
+		top := make(map[string]float64)
+		entry.Get("top_logprobs").ForEach(func(_, alt gjson.Result) bool {
+			top[alt.Get("token").String()] = alt.Get("logprob").Float()
+			return true
+		})
+		topLogprobs = append(topLogprobs, top)
+		textOffsets = append(textOffsets, offset)
+		offset += utf8.RuneCountInString(token)
+	}
+	return map[string]any{
+		"tokens":         tokens,
+		"token_logprobs": tokenLogprobs,
+		"top_logprobs":   topLogprobs,
+		"text_offset":    textOffsets,
+	}, offset
+}
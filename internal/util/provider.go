@@ -4,6 +4,9 @@
 package util
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 )
@@ -56,6 +59,30 @@ func GetProviderName(modelName string, cfg *config.Config) []string {
 	return providers
 }
 
+// ApplyProviderOverride restricts the providers able to serve a model to an explicitly
+// requested provider. An empty override leaves the list unchanged.
+//
+// Parameters:
+//   - providers: The providers able to serve the model
+//   - override: The provider requested by the client, or an empty string
+//
+// Returns:
+//   - []string: The providers to route to
+//   - error: An error if the override is unknown or cannot serve the model
+func ApplyProviderOverride(providers []string, override string) ([]string, error) {
+	override = strings.ToLower(strings.TrimSpace(override))
+	if override == "" {
+		return providers, nil
+	}
+	if !registry.GetGlobalRegistry().HasProvider(override) {
+		return nil, fmt.Errorf("unknown provider %s", override)
+	}
+	if !InArray(providers, override) {
+		return nil, fmt.Errorf("provider %s cannot serve the requested model", override)
+	}
+	return []string{override}, nil
+}
+
 // IsOpenAICompatibilityAlias checks if the given model name is an alias
 // configured for OpenAI compatibility routing.
 //
@@ -0,0 +1,190 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// responseLanguageHint matches the instruction produced by ResponseLanguageInstruction so
+// downstream providers can find it again after translation.
+var responseLanguageHint = regexp.MustCompile(`Always respond in [^.\n]+ unless explicitly asked otherwise\.`)
+
+// ResponseLanguageInstruction returns the system-level instruction asking for replies in lang.
+func ResponseLanguageInstruction(lang string) string {
+	return fmt.Sprintf("Always respond in %s unless explicitly asked otherwise.", strings.TrimSpace(lang))
+}
+
+// HasResponseLanguageInstruction reports whether text already asks for replies in lang,
+// either through the injected instruction or an equivalent client-written one such as
+// "reply in French".
+func HasResponseLanguageInstruction(text, lang string) bool {
+	lang = strings.TrimSpace(lang)
+	if text == "" || lang == "" {
+		return false
+	}
+	pattern := `(?i)\b(respond|reply|answer|write)\s+(only\s+)?in\s+` + regexp.QuoteMeta(lang) + `\b`
+	matched, err := regexp.MatchString(pattern, text)
+	return err == nil && matched
+}
+
+// FindResponseLanguageInstruction returns the injected instruction contained in text, if any.
+func FindResponseLanguageInstruction(text string) string {
+	return responseLanguageHint.FindString(text)
+}
+
+// InjectResponseLanguage adds the response language instruction to a request in the
+// client's dialect: an OpenAI system message, the Responses API instructions, the
+// Anthropic system field or the Gemini systemInstruction. Requests that already carry an
+// equivalent instruction are returned unchanged, so repeated calls never duplicate it.
+//
+// Parameters:
+//   - handlerType: The client-facing API format of rawJSON
+//   - rawJSON: The request body
+//   - lang: The language to respond in; empty disables injection
+//
+// Returns:
+//   - []byte: The request body with the instruction in place
+func InjectResponseLanguage(handlerType string, rawJSON []byte, lang string) []byte {
+	lang = strings.TrimSpace(lang)
+	if lang == "" || len(rawJSON) == 0 || !gjson.ValidBytes(rawJSON) {
+		return rawJSON
+	}
+	instruction := ResponseLanguageInstruction(lang)
+	switch handlerType {
+	case constant.OpenAI:
+		return injectOpenAISystemMessage(rawJSON, instruction, lang)
+	case constant.OpenaiResponse:
+		return appendInstructionString(rawJSON, "instructions", instruction, lang)
+	case constant.Claude:
+		return injectClaudeSystem(rawJSON, instruction, lang)
+	case constant.Gemini:
+		return injectGeminiSystemInstruction(rawJSON, "", instruction, lang)
+	case constant.GeminiCLI:
+		return injectGeminiSystemInstruction(rawJSON, "request.", instruction, lang)
+	default:
+		return rawJSON
+	}
+}
+
+func injectOpenAISystemMessage(rawJSON []byte, instruction, lang string) []byte {
+	messages := gjson.GetBytes(rawJSON, "messages")
+	if !messages.IsArray() {
+		return rawJSON
+	}
+	first := -1
+	for i, message := range messages.Array() {
+		role := message.Get("role").String()
+		if role != "system" && role != "developer" {
+			continue
+		}
+		if HasResponseLanguageInstruction(contentText(message.Get("content")), lang) {
+			return rawJSON
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	// Extend the first system message rather than adding another one: several backends
+	// keep only a single system instruction.
+	if first >= 0 {
+		path := fmt.Sprintf("messages.%d.content", first)
+		if gjson.GetBytes(rawJSON, path).IsArray() {
+			block, _ := sjson.Set(`{"type":"text","text":""}`, "text", instruction)
+			out, err := sjson.SetRawBytes(rawJSON, path+".-1", []byte(block))
+			if err != nil {
+				return rawJSON
+			}
+			return out
+		}
+		return appendInstructionString(rawJSON, path, instruction, lang)
+	}
+	system, _ := sjson.Set(`{"role":"system","content":""}`, "content", instruction)
+	items := []string{system}
+	for _, message := range messages.Array() {
+		items = append(items, message.Raw)
+	}
+	out, err := sjson.SetRawBytes(rawJSON, "messages", []byte("["+strings.Join(items, ",")+"]"))
+	if err != nil {
+		return rawJSON
+	}
+	return out
+}
+
+func appendInstructionString(rawJSON []byte, path, instruction, lang string) []byte {
+	existing := gjson.GetBytes(rawJSON, path).String()
+	if HasResponseLanguageInstruction(existing, lang) {
+		return rawJSON
+	}
+	value := instruction
+	if strings.TrimSpace(existing) != "" {
+		value = existing + "\n\n" + instruction
+	}
+	out, err := sjson.SetBytes(rawJSON, path, value)
+	if err != nil {
+		return rawJSON
+	}
+	return out
+}
+
+func injectClaudeSystem(rawJSON []byte, instruction, lang string) []byte {
+	system := gjson.GetBytes(rawJSON, "system")
+	if !system.IsArray() {
+		return appendInstructionString(rawJSON, "system", instruction, lang)
+	}
+	if HasResponseLanguageInstruction(contentText(system), lang) {
+		return rawJSON
+	}
+	block, _ := sjson.Set(`{"type":"text","text":""}`, "text", instruction)
+	out, err := sjson.SetRawBytes(rawJSON, "system.-1", []byte(block))
+	if err != nil {
+		return rawJSON
+	}
+	return out
+}
+
+func injectGeminiSystemInstruction(rawJSON []byte, prefix, instruction, lang string) []byte {
+	path := prefix + "systemInstruction"
+	if !gjson.GetBytes(rawJSON, path).Exists() && gjson.GetBytes(rawJSON, prefix+"system_instruction").Exists() {
+		path = prefix + "system_instruction"
+	}
+	parts := gjson.GetBytes(rawJSON, path+".parts")
+	if HasResponseLanguageInstruction(contentText(parts), lang) {
+		return rawJSON
+	}
+	part, _ := sjson.Set(`{"text":""}`, "text", instruction)
+	var out []byte
+	var err error
+	if parts.IsArray() {
+		out, err = sjson.SetRawBytes(rawJSON, path+".parts.-1", []byte(part))
+	} else {
+		out, err = sjson.SetRawBytes(rawJSON, path, []byte(`{"role":"user","parts":[`+part+`]}`))
+	}
+	if err != nil {
+		return rawJSON
+	}
+	return out
+}
+
+// contentText flattens a string or an array of text blocks into plain text.
+func contentText(content gjson.Result) string {
+	if !content.IsArray() {
+		return content.String()
+	}
+	var b strings.Builder
+	for _, item := range content.Array() {
+		text := item.Get("text")
+		if !text.Exists() && item.Type == gjson.String {
+			text = item
+		}
+		if text.Exists() {
+			b.WriteString(text.String())
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Values accepted by the response-model-name configuration option.
+const (
+	// ResponseModelUpstream reports the model name returned by the backend (default).
+	ResponseModelUpstream = "upstream"
+	// ResponseModelRequested echoes the model name the client asked for.
+	ResponseModelRequested = "requested"
+)
+
+// responseModelPaths lists, per client format, the JSON paths that carry the model name.
+var responseModelPaths = map[string][]string{
+	constant.OpenAI:         {"model"},
+	constant.OpenaiResponse: {"model", "response.model"},
+	constant.Claude:         {"model", "message.model"},
+	constant.Gemini:         {"modelVersion", "response.modelVersion"},
+	constant.GeminiCLI:      {"modelVersion", "response.modelVersion"},
+}
+
+// RewriteResponseModel replaces the model name in a response payload with model.
+// The payload may be a single JSON document or a block of SSE lines, in which case
+// every "data:" line carrying JSON is rewritten. Fields that are absent stay absent.
+//
+// Parameters:
+//   - handlerType: The client-facing API format of the payload
+//   - payload: The response body or stream chunk
+//   - model: The model name to report
+//
+// Returns:
+//   - []byte: The rewritten payload, or the original when nothing needs changing
+func RewriteResponseModel(handlerType string, payload []byte, model string) []byte {
+	paths := responseModelPaths[handlerType]
+	if len(paths) == 0 || model == "" || len(payload) == 0 {
+		return payload
+	}
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return rewriteModelJSON(payload, paths, model)
+	}
+
+	lines := strings.Split(string(payload), "\n")
+	changed := false
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if !strings.HasPrefix(data, "{") {
+			continue
+		}
+		rewritten := rewriteModelJSON([]byte(data), paths, model)
+		if string(rewritten) != data {
+			lines[i] = "data: " + string(rewritten)
+			changed = true
+		}
+	}
+	if !changed {
+		return payload
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func rewriteModelJSON(doc []byte, paths []string, model string) []byte {
+	for _, path := range paths {
+		current := gjson.GetBytes(doc, path)
+		if !current.Exists() || current.String() == model {
+			continue
+		}
+		if updated, err := sjson.SetBytes(doc, path, model); err == nil {
+			doc = updated
+		}
+	}
+	return doc
+}
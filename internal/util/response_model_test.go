@@ -0,0 +1,28 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+)
+
+func TestRewriteResponseModel(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		handlerType string
+		payload     string
+		want        string
+	}{
+		{name: "openai json", handlerType: constant.OpenAI, payload: `{"model":"gemini-2.5-pro","choices":[]}`, want: `{"model":"gpt-4o","choices":[]}`},
+		{name: "claude stream", handlerType: constant.Claude, payload: "event: message_start\ndata: {\"message\":{\"model\":\"gemini-2.5-pro\"}}\n\n", want: "event: message_start\ndata: {\"message\":{\"model\":\"gpt-4o\"}}\n\n"},
+		{name: "absent field", handlerType: constant.OpenAI, payload: `{"choices":[]}`, want: `{"choices":[]}`},
+		{name: "done line", handlerType: constant.OpenAI, payload: "data: [DONE]\n\n", want: "data: [DONE]\n\n"},
+		{name: "unknown format", handlerType: "other", payload: `{"model":"gemini-2.5-pro"}`, want: `{"model":"gemini-2.5-pro"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(RewriteResponseModel(tt.handlerType, []byte(tt.payload), "gpt-4o")); got != tt.want {
+				t.Fatalf("rewritten = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
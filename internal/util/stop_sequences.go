@@ -0,0 +1,165 @@
+package util
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// StopSequenceMatcher enforces client-provided stop sequences on a stream of generated text.
+// Backends such as Gemini report a plain STOP finish reason without naming the sequence that
+// ended generation, so the proxy matches sequences itself. Text that could still be the prefix
+// of a stop sequence is held back until the next chunk (or Flush) resolves it, which keeps
+// matches that straddle chunk boundaries from leaking to the client.
+type StopSequenceMatcher struct {
+	sequences []string
+	maxLen    int
+	pending   string
+	matched   string
+	stopped   bool
+}
+
+// NewStopSequenceMatcher creates a matcher for the given sequences.
+// It returns nil when no non-empty sequence is provided.
+//
+// Parameters:
+//   - sequences: The stop sequences requested by the client
+//
+// Returns:
+//   - *StopSequenceMatcher: The matcher, or nil when there is nothing to match
+func NewStopSequenceMatcher(sequences []string) *StopSequenceMatcher {
+	m := &StopSequenceMatcher{}
+	for _, seq := range sequences {
+		if seq == "" {
+			continue
+		}
+		m.sequences = append(m.sequences, seq)
+		if len(seq) > m.maxLen {
+			m.maxLen = len(seq)
+		}
+	}
+	if len(m.sequences) == 0 {
+		return nil
+	}
+	return m
+}
+
+// StopSequencesFromRequest extracts stop sequences from a client request payload.
+// Both the Claude "stop_sequences" array and the OpenAI "stop" string or array are recognized.
+//
+// Parameters:
+//   - rawJSON: The original client request
+//
+// Returns:
+//   - []string: The requested stop sequences
+func StopSequencesFromRequest(rawJSON []byte) []string {
+	var sequences []string
+	collect := func(result gjson.Result) {
+		if result.IsArray() {
+			result.ForEach(func(_, value gjson.Result) bool {
+				sequences = append(sequences, value.String())
+				return true
+			})
+		} else if result.Type == gjson.String {
+			sequences = append(sequences, result.String())
+		}
+	}
+	collect(gjson.GetBytes(rawJSON, "stop_sequences"))
+	collect(gjson.GetBytes(rawJSON, "stop"))
+	return sequences
+}
+
+// Feed appends a chunk of generated text and returns the portion that is safe to emit.
+// Once a stop sequence matches, the text before it is returned and all later input is discarded.
+//
+// Parameters:
+//   - text: The next chunk of generated text
+//
+// Returns:
+//   - string: Text that can be forwarded to the client
+func (m *StopSequenceMatcher) Feed(text string) string {
+	if m.stopped {
+		return ""
+	}
+	buf := m.pending + text
+	if idx, seq := m.earliest(buf); idx >= 0 {
+		m.stopped = true
+		m.matched = seq
+		m.pending = ""
+		return buf[:idx]
+	}
+	cut := len(buf) - (m.maxLen - 1)
+	if cut <= 0 {
+		m.pending = buf
+		return ""
+	}
+	for cut > 0 && !utf8.RuneStart(buf[cut]) {
+		cut--
+	}
+	m.pending = buf[cut:]
+	return buf[:cut]
+}
+
+// Flush returns any text still held back. It is called when the text stream ends or when
+// output switches to a non-text block.
+//
+// Returns:
+//   - string: The held-back text
+func (m *StopSequenceMatcher) Flush() string {
+	if m == nil {
+		return ""
+	}
+	out := m.pending
+	m.pending = ""
+	return out
+}
+
+// Stopped reports whether a stop sequence has matched.
+func (m *StopSequenceMatcher) Stopped() bool {
+	return m != nil && m.stopped
+}
+
+// Matched returns the stop sequence that terminated the output, or an empty string.
+func (m *StopSequenceMatcher) Matched() string {
+	if m == nil {
+		return ""
+	}
+	return m.matched
+}
+
+// TruncateAtStopSequence cuts text at the earliest occurrence of any stop sequence.
+//
+// Parameters:
+//   - text: The complete generated text
+//   - sequences: The requested stop sequences
+//
+// Returns:
+//   - string: The text preceding the match, or the original text when nothing matched
+//   - string: The matched sequence, or an empty string
+func TruncateAtStopSequence(text string, sequences []string) (string, string) {
+	m := NewStopSequenceMatcher(sequences)
+	if m == nil {
+		return text, ""
+	}
+	if idx, seq := m.earliest(text); idx >= 0 {
+		return text[:idx], seq
+	}
+	return text, ""
+}
+
+// earliest finds the first stop sequence occurrence in text, preferring the longest
+// sequence when several start at the same offset.
+func (m *StopSequenceMatcher) earliest(text string) (int, string) {
+	bestIdx, bestSeq := -1, ""
+	for _, seq := range m.sequences {
+		idx := strings.Index(text, seq)
+		if idx < 0 {
+			continue
+		}
+		if bestIdx < 0 || idx < bestIdx || (idx == bestIdx && len(seq) > len(bestSeq)) {
+			bestIdx, bestSeq = idx, seq
+		}
+	}
+	return bestIdx, bestSeq
+}
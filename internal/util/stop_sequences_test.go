@@ -0,0 +1,63 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStopSequenceMatcherFindsSequencesAcrossChunks(t *testing.T) {
+	m := NewStopSequenceMatcher([]string{"END", "\n\nHuman:"})
+	var out string
+	for _, chunk := range []string{"Hello wor", "ld\n", "\nHum", "an: more"} {
+		out += m.Feed(chunk)
+	}
+	if out != "Hello world" {
+		t.Fatalf("output = %q, want the text before the sequence", out)
+	}
+	if !m.Stopped() || m.Matched() != "\n\nHuman:" {
+		t.Fatalf("matched = %q (stopped %v), want the sequence split across chunks", m.Matched(), m.Stopped())
+	}
+	if rest := m.Feed("ignored") + m.Flush(); rest != "" {
+		t.Fatalf("text after the match = %q, want none", rest)
+	}
+}
+
+func TestStopSequenceMatcherReleasesHeldTextOnFlush(t *testing.T) {
+	m := NewStopSequenceMatcher([]string{"STOP"})
+	out := m.Feed("almost ST")
+	out += m.Flush()
+	if out != "almost ST" || m.Stopped() {
+		t.Fatalf("output = %q (stopped %v), want all text once the stream ends", out, m.Stopped())
+	}
+}
+
+func TestStopSequenceMatcherPrefersTheEarliestMatch(t *testing.T) {
+	m := NewStopSequenceMatcher([]string{"b", "ab", "abc"})
+	if out := m.Feed("xxabcd"); out != "xx" || m.Matched() != "abc" {
+		t.Fatalf("output = %q, matched = %q, want the longest sequence at the earliest offset", out, m.Matched())
+	}
+}
+
+func TestNilStopSequenceMatcher(t *testing.T) {
+	var m *StopSequenceMatcher
+	if NewStopSequenceMatcher([]string{"", ""}) != nil {
+		t.Fatal("matcher created without sequences")
+	}
+	if m.Stopped() || m.Matched() != "" || m.Flush() != "" {
+		t.Fatal("nil matcher reported state")
+	}
+}
+
+func TestStopSequencesFromRequest(t *testing.T) {
+	got := StopSequencesFromRequest([]byte(`{"stop_sequences":["a","b"],"stop":"c"}`))
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sequences = %q, want %q", got, want)
+	}
+}
+
+func TestTruncateAtStopSequence(t *testing.T) {
+	text, seq := TruncateAtStopSequence("one two three", []string{"three", "two"})
+	if text != "one " || seq != "two" {
+		t.Fatalf("truncated = %q at %q, want the text before two", text, seq)
+	}
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/tidwall/gjson"
+)
+
+// InjectSystemText adds text to the system instructions of a request in the client's
+// dialect, the same places InjectResponseLanguage uses. Unlike the language instruction it
+// is added unconditionally, so callers must inject it once per request.
+func InjectSystemText(handlerType string, rawJSON []byte, text string) []byte {
+	if text == "" || len(rawJSON) == 0 || !gjson.ValidBytes(rawJSON) {
+		return rawJSON
+	}
+	switch handlerType {
+	case constant.OpenAI:
+		return injectOpenAISystemMessage(rawJSON, text, "")
+	case constant.OpenaiResponse:
+		return appendInstructionString(rawJSON, "instructions", text, "")
+	case constant.Claude:
+		return injectClaudeSystem(rawJSON, text, "")
+	case constant.Gemini:
+		return injectGeminiSystemInstruction(rawJSON, "", text, "")
+	case constant.GeminiCLI:
+		return injectGeminiSystemInstruction(rawJSON, "request.", text, "")
+	default:
+		return rawJSON
+	}
+}
+
+// LastUserText returns the text of the last user turn of a request in the client's dialect,
+// or "" when there is none.
+func LastUserText(handlerType string, rawJSON []byte) string {
+	root := gjson.ParseBytes(rawJSON)
+	var turns gjson.Result
+	userRole := "user"
+	switch handlerType {
+	case constant.OpenAI, constant.Claude:
+		turns = root.Get("messages")
+	case constant.OpenaiResponse:
+		input := root.Get("input")
+		if input.Type == gjson.String {
+			return input.String()
+		}
+		turns = input
+	case constant.Gemini:
+		turns = root.Get("contents")
+	case constant.GeminiCLI:
+		turns = root.Get("request.contents")
+	default:
+		return ""
+	}
+	items := turns.Array()
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if role := item.Get("role").String(); role != "" && role != userRole {
+			continue
+		}
+		var text string
+		if parts := item.Get("parts"); parts.Exists() {
+			text = contentText(parts)
+		} else {
+			text = contentText(item.Get("content"))
+		}
+		if text != "" {
+			return text
+		}
+	}
+	return ""
+}
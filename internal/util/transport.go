@@ -0,0 +1,274 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/proxy"
+)
+
+// Defaults of the upstream transport settings.
+const (
+	defaultMaxIdleConnsPerHost = 16
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// transportKey identifies a shared upstream transport.
+type transportKey struct {
+	provider string
+	proxy    string
+	insecure bool
+}
+
+// transportSettings are the effective settings of one provider's transports.
+type transportSettings struct {
+	http2               string
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	tlsHandshakeTimeout time.Duration
+}
+
+// connectionCounters counts the connections a transport key obtained. They survive the
+// transport being rebuilt after a configuration change.
+type connectionCounters struct {
+	created atomic.Int64
+	reused  atomic.Int64
+}
+
+// upstreamTransport is a shared transport counting whether each request got a new or a
+// reused connection.
+type upstreamTransport struct {
+	base     *http.Transport
+	settings transportSettings
+	counters *connectionCounters
+	trace    *httptrace.ClientTrace
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), t.trace)))
+}
+
+var (
+	transportConfig atomic.Pointer[config.UpstreamTransportConfig]
+
+	transportsMu sync.Mutex
+	transports   = make(map[transportKey]*upstreamTransport)
+	counters     = make(map[transportKey]*connectionCounters)
+)
+
+// ConfigureUpstreamTransports applies the upstream-transport settings of cfg to the shared
+// transports. Transports whose settings changed are rebuilt on their next use.
+func ConfigureUpstreamTransports(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	settings := cfg.UpstreamTransport
+	transportConfig.Store(&settings)
+}
+
+// UpstreamTransport returns the transport shared by all requests to provider through
+// proxyURL. An empty proxyURL connects directly, honouring the proxy environment variables.
+// It returns nil when proxyURL cannot be used.
+func UpstreamTransport(provider, proxyURL string) http.RoundTripper {
+	return sharedTransport(transportKey{provider: strings.ToLower(strings.TrimSpace(provider)), proxy: strings.TrimSpace(proxyURL)})
+}
+
+// InsecureUpstreamTransport is UpstreamTransport without TLS certificate verification.
+func InsecureUpstreamTransport(provider, proxyURL string) http.RoundTripper {
+	return sharedTransport(transportKey{provider: strings.ToLower(strings.TrimSpace(provider)), proxy: strings.TrimSpace(proxyURL), insecure: true})
+}
+
+func sharedTransport(key transportKey) http.RoundTripper {
+	settings := settingsFor(key.provider)
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if existing := transports[key]; existing != nil {
+		if existing.settings == settings {
+			return existing
+		}
+		existing.base.CloseIdleConnections()
+	}
+	base, err := buildTransport(key, settings)
+	if err != nil {
+		log.Errorf("create upstream transport for %s failed: %v", key.provider, err)
+		return nil
+	}
+	count := counters[key]
+	if count == nil {
+		count = &connectionCounters{}
+		counters[key] = count
+	}
+	rt := &upstreamTransport{
+		base:     base,
+		settings: settings,
+		counters: count,
+		trace: &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if info.Reused {
+					count.reused.Add(1)
+				} else {
+					count.created.Add(1)
+				}
+			},
+		},
+	}
+	transports[key] = rt
+	return rt
+}
+
+// settingsFor returns the configured settings of provider, its override over the defaults.
+func settingsFor(provider string) transportSettings {
+	settings := transportSettings{
+		http2:               config.HTTP2Auto,
+		maxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		idleConnTimeout:     defaultIdleConnTimeout,
+		tlsHandshakeTimeout: defaultTLSHandshakeTimeout,
+	}
+	cfg := transportConfig.Load()
+	if cfg == nil {
+		return settings
+	}
+	apply := func(c config.UpstreamTransportConfig) {
+		switch mode := strings.ToLower(strings.TrimSpace(c.HTTP2)); mode {
+		case config.HTTP2Auto, config.HTTP2Force, config.HTTP2Off:
+			settings.http2 = mode
+		}
+		if c.MaxIdleConnsPerHost > 0 {
+			settings.maxIdleConnsPerHost = c.MaxIdleConnsPerHost
+		}
+		if c.MaxConnsPerHost > 0 {
+			settings.maxConnsPerHost = c.MaxConnsPerHost
+		}
+		if c.IdleConnTimeout > 0 {
+			settings.idleConnTimeout = time.Duration(c.IdleConnTimeout) * time.Second
+		}
+		if c.TLSHandshakeTimeout > 0 {
+			settings.tlsHandshakeTimeout = time.Duration(c.TLSHandshakeTimeout) * time.Second
+		}
+	}
+	apply(*cfg)
+	for name, override := range cfg.Providers {
+		if strings.EqualFold(strings.TrimSpace(name), provider) {
+			apply(override)
+		}
+	}
+	return settings
+}
+
+// buildTransport creates the transport of key with settings.
+func buildTransport(key transportKey, settings transportSettings) (*http.Transport, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   settings.maxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.maxConnsPerHost,
+		IdleConnTimeout:       settings.idleConnTimeout,
+		TLSHandshakeTimeout:   settings.tlsHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	if key.proxy != "" {
+		proxyURL, err := url.Parse(key.proxy)
+		if err != nil {
+			return nil, err
+		}
+		switch proxyURL.Scheme {
+		case "socks5":
+			username := proxyURL.User.Username()
+			password, _ := proxyURL.User.Password()
+			socks, errSOCKS5 := proxy.SOCKS5("tcp", proxyURL.Host, &proxy.Auth{User: username, Password: password}, proxy.Direct)
+			if errSOCKS5 != nil {
+				return nil, errSOCKS5
+			}
+			transport.Proxy = nil
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if contextDialer, ok := socks.(proxy.ContextDialer); ok {
+					return contextDialer.DialContext(ctx, network, addr)
+				}
+				return socks.Dial(network, addr)
+			}
+		case "http", "https":
+			transport.Proxy = http.ProxyURL(proxyURL)
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+	}
+	if key.insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	switch settings.http2 {
+	case config.HTTP2Off:
+		// A non-nil empty map keeps the transport from upgrading to HTTP/2.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case config.HTTP2Force:
+		h2, err := http2.ConfigureTransports(transport)
+		if err != nil {
+			return nil, err
+		}
+		// Queue requests on the open connection instead of dialing more once the server's
+		// stream limit is reached.
+		h2.StrictMaxConcurrentStreams = true
+		transport.TLSClientConfig.NextProtos = []string{http2.NextProtoTLS}
+	default:
+		transport.ForceAttemptHTTP2 = true
+	}
+	return transport, nil
+}
+
+// UpstreamConnectionStat reports the connections one shared transport obtained.
+type UpstreamConnectionStat struct {
+	Provider string `json:"provider"`
+	// Proxy is the proxy URL without credentials, empty for direct connections.
+	Proxy    string `json:"proxy,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+	HTTP2    string `json:"http2"`
+	// New counts requests that opened a new connection, Reused those served by a pooled one.
+	New    int64 `json:"new"`
+	Reused int64 `json:"reused"`
+}
+
+// UpstreamConnectionStats returns the connection counts of every shared transport.
+func UpstreamConnectionStats() []UpstreamConnectionStat {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	out := make([]UpstreamConnectionStat, 0, len(transports))
+	for key, rt := range transports {
+		stat := UpstreamConnectionStat{
+			Provider: key.provider,
+			Insecure: key.insecure,
+			HTTP2:    rt.settings.http2,
+			New:      rt.counters.created.Load(),
+			Reused:   rt.counters.reused.Load(),
+		}
+		if key.proxy != "" {
+			if u, err := url.Parse(key.proxy); err == nil {
+				stat.Proxy = u.Redacted()
+			}
+		}
+		out = append(out, stat)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Proxy < out[j].Proxy
+	})
+	return out
+}
@@ -5,6 +5,7 @@ package util
 
 import (
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -30,6 +31,16 @@ func SetLogLevel(cfg *config.Config) {
 	}
 }
 
+// IsLoopbackHost reports whether host, as returned by url.URL.Hostname, is localhost or a
+// loopback IP address.
+func IsLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // CountAuthFiles returns the number of JSON auth files located under the provided directory.
 // The function resolves leading tildes to the user's home directory and performs a case-insensitive
 // match on the ".json" suffix so that files saved with uppercase extensions are also counted.
@@ -0,0 +1,21 @@
+package util
+
+import "testing"
+
+func TestIsLoopbackHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost":        true,
+		"LOCALHOST":        true,
+		"127.0.0.1":        true,
+		"127.8.0.1":        true,
+		"::1":              true,
+		"10.0.0.2":         false,
+		"leader.internal":  false,
+		"localhost.evil.x": false,
+		"":                 false,
+	} {
+		if got := IsLoopbackHost(host); got != want {
+			t.Errorf("IsLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -100,6 +101,13 @@ func (w *Watcher) Start(ctx context.Context) error {
 		return errAddConfig
 	}
 	log.Debugf("watching config file: %s", w.configPath)
+	for _, overridePath := range w.overrideFiles() {
+		if errAddOverride := w.watcher.Add(overridePath); errAddOverride != nil {
+			log.Errorf("failed to watch override file %s: %v", overridePath, errAddOverride)
+			return errAddOverride
+		}
+		log.Debugf("watching override file: %s", overridePath)
+	}
 
 	// Watch the auth directory
 	if errAddAuthDir := w.watcher.Add(w.authDir); errAddAuthDir != nil {
@@ -129,6 +137,16 @@ func (w *Watcher) SetConfig(cfg *config.Config) {
 	w.config = cfg
 }
 
+// overrideFiles returns the override files the current configuration was merged from.
+func (w *Watcher) overrideFiles() []string {
+	w.clientsMutex.RLock()
+	defer w.clientsMutex.RUnlock()
+	if w.config == nil {
+		return nil
+	}
+	return w.config.OverrideFiles
+}
+
 // SetAuthUpdateQueue sets the queue used to emit auth updates.
 func (w *Watcher) SetAuthUpdateQueue(queue chan<- AuthUpdate) {
 	w.clientsMutex.Lock()
@@ -361,7 +379,9 @@ func (w *Watcher) processEvents(ctx context.Context) {
 // handleEvent processes individual file system events
 func (w *Watcher) handleEvent(event fsnotify.Event) {
 	// Filter only relevant events: config file or auth-dir JSON files.
-	isConfigEvent := event.Name == w.configPath && (event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create)
+	overrides := w.overrideFiles()
+	isConfigFile := event.Name == w.configPath || slices.Contains(overrides, event.Name)
+	isConfigEvent := isConfigFile && (event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create)
 	isAuthJSON := strings.HasPrefix(event.Name, w.authDir) && strings.HasSuffix(event.Name, ".json")
 	if !isConfigEvent && !isAuthJSON {
 		// Ignore unrelated files (e.g., cookie snapshots *.cookie) and other noise.
@@ -383,8 +403,19 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 			log.Debugf("ignoring empty config file write event")
 			return
 		}
-		sum := sha256.Sum256(data)
-		newHash := hex.EncodeToString(sum[:])
+		// The hash covers the override files too, so a change to any of them re-merges.
+		hasher := sha256.New()
+		hasher.Write(data)
+		for _, overridePath := range overrides {
+			overrideData, errRead := os.ReadFile(overridePath)
+			if errRead != nil {
+				log.Errorf("failed to read override file for hash check: %v", errRead)
+				return
+			}
+			hasher.Write([]byte{0})
+			hasher.Write(overrideData)
+		}
+		newHash := hex.EncodeToString(hasher.Sum(nil))
 
 		w.clientsMutex.RLock()
 		currentHash := w.lastConfigHash
@@ -394,7 +425,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 			log.Debugf("config file content unchanged (hash match), skipping reload")
 			return
 		}
-		fmt.Printf("config file changed, reloading: %s\n", w.configPath)
+		fmt.Printf("config file changed, reloading: %s\n", event.Name)
 		if w.reloadConfig() {
 			w.clientsMutex.Lock()
 			w.lastConfigHash = newHash
@@ -424,7 +455,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 func (w *Watcher) reloadConfig() bool {
 	log.Debugf("starting config reload from: %s", w.configPath)
 
-	newConfig, errLoadConfig := config.LoadConfig(w.configPath)
+	newConfig, errLoadConfig := config.LoadConfigWithOverrides(w.configPath, w.overrideFiles())
 	if errLoadConfig != nil {
 		log.Errorf("failed to reload config: %v", errLoadConfig)
 		return false
@@ -452,18 +483,136 @@ func (w *Watcher) reloadConfig() bool {
 		if oldConfig.AuthDir != newConfig.AuthDir {
 			log.Debugf("  auth-dir: %s -> %s", oldConfig.AuthDir, newConfig.AuthDir)
 		}
+		if oldConfig.AuthRemoval.Grace != newConfig.AuthRemoval.Grace {
+			log.Debugf("  auth-removal.grace: %d -> %d", oldConfig.AuthRemoval.Grace, newConfig.AuthRemoval.Grace)
+		}
+		if oldConfig.AuthRemoval.DrainTimeout != newConfig.AuthRemoval.DrainTimeout {
+			log.Debugf("  auth-removal.drain-timeout: %d -> %d", oldConfig.AuthRemoval.DrainTimeout, newConfig.AuthRemoval.DrainTimeout)
+		}
+		if oldConfig.AuthRemoval.CleanupOrphanedArtifacts != newConfig.AuthRemoval.CleanupOrphanedArtifacts {
+			log.Debugf("  auth-removal.cleanup-orphaned-artifacts: %t -> %t", oldConfig.AuthRemoval.CleanupOrphanedArtifacts, newConfig.AuthRemoval.CleanupOrphanedArtifacts)
+		}
 		if oldConfig.Debug != newConfig.Debug {
 			log.Debugf("  debug: %t -> %t", oldConfig.Debug, newConfig.Debug)
 		}
 		if oldConfig.ProxyURL != newConfig.ProxyURL {
 			log.Debugf("  proxy-url: %s -> %s", oldConfig.ProxyURL, newConfig.ProxyURL)
 		}
+		if !reflect.DeepEqual(oldConfig.UpstreamTransport, newConfig.UpstreamTransport) {
+			log.Debugf("  upstream-transport: http2 %q -> %q, %d -> %d provider overrides", oldConfig.UpstreamTransport.HTTP2, newConfig.UpstreamTransport.HTTP2, len(oldConfig.UpstreamTransport.Providers), len(newConfig.UpstreamTransport.Providers))
+		}
+		if !reflect.DeepEqual(oldConfig.ForwardHeaders, newConfig.ForwardHeaders) {
+			log.Debugf("  forward-headers: %v -> %v", oldConfig.ForwardHeaders, newConfig.ForwardHeaders)
+		}
+		if !reflect.DeepEqual(oldConfig.UserAgents, newConfig.UserAgents) {
+			log.Debugf("  user-agents: %v -> %v", oldConfig.UserAgents, newConfig.UserAgents)
+		}
 		if oldConfig.RequestLog != newConfig.RequestLog {
 			log.Debugf("  request-log: %t -> %t", oldConfig.RequestLog, newConfig.RequestLog)
 		}
+		if oldConfig.MaxMessages != newConfig.MaxMessages {
+			log.Debugf("  max-messages: %d -> %d", oldConfig.MaxMessages, newConfig.MaxMessages)
+		}
+		if !reflect.DeepEqual(oldConfig.StrictOpenAI, newConfig.StrictOpenAI) {
+			log.Debugf("  strict-openai: enable %t -> %t, %d -> %d api keys", oldConfig.StrictOpenAI.Enable, newConfig.StrictOpenAI.Enable, len(oldConfig.StrictOpenAI.APIKeys), len(newConfig.StrictOpenAI.APIKeys))
+		}
+		if oldConfig.StrictModelNames != newConfig.StrictModelNames {
+			log.Debugf("  strict-model-names: %t -> %t", oldConfig.StrictModelNames, newConfig.StrictModelNames)
+		}
+		if oldConfig.PublicCapabilities != newConfig.PublicCapabilities {
+			log.Debugf("  public-capabilities: %t -> %t", oldConfig.PublicCapabilities, newConfig.PublicCapabilities)
+		}
 		if oldConfig.RequestRetry != newConfig.RequestRetry {
 			log.Debugf("  request-retry: %d -> %d", oldConfig.RequestRetry, newConfig.RequestRetry)
 		}
+		if oldConfig.DeadLetter.File != newConfig.DeadLetter.File {
+			log.Debugf("  dead-letter.file: %s -> %s", oldConfig.DeadLetter.File, newConfig.DeadLetter.File)
+		}
+		if oldConfig.DeadLetter.URL != newConfig.DeadLetter.URL {
+			log.Debugf("  dead-letter.url: set %t -> %t", oldConfig.DeadLetter.URL != "", newConfig.DeadLetter.URL != "")
+		}
+		if !reflect.DeepEqual(oldConfig.Mirroring, newConfig.Mirroring) {
+			log.Debugf("  mirroring: max-concurrency %d -> %d, timeout %d -> %d, %d -> %d rules", oldConfig.Mirroring.MaxConcurrency, newConfig.Mirroring.MaxConcurrency, oldConfig.Mirroring.Timeout, newConfig.Mirroring.Timeout, len(oldConfig.Mirroring.Rules), len(newConfig.Mirroring.Rules))
+		}
+		if oldConfig.Streaming.BufferOnFlushError != newConfig.Streaming.BufferOnFlushError {
+			log.Debugf("  streaming.buffer-on-flush-error: %t -> %t", oldConfig.Streaming.BufferOnFlushError, newConfig.Streaming.BufferOnFlushError)
+		}
+		if oldConfig.RetryBudget.MaxAttempts != newConfig.RetryBudget.MaxAttempts {
+			log.Debugf("  retry-budget.max-attempts: %d -> %d", oldConfig.RetryBudget.MaxAttempts, newConfig.RetryBudget.MaxAttempts)
+		}
+		if oldConfig.RetryBudget.Deadline != newConfig.RetryBudget.Deadline {
+			log.Debugf("  retry-budget.deadline: %d -> %d", oldConfig.RetryBudget.Deadline, newConfig.RetryBudget.Deadline)
+		}
+		if oldConfig.CoalesceRequests != newConfig.CoalesceRequests {
+			log.Debugf("  coalesce-requests: %t -> %t", oldConfig.CoalesceRequests, newConfig.CoalesceRequests)
+		}
+		if oldConfig.RecentFailureWindow != newConfig.RecentFailureWindow {
+			log.Debugf("  recent-failure-window: %d -> %d", oldConfig.RecentFailureWindow, newConfig.RecentFailureWindow)
+		}
+		if !reflect.DeepEqual(oldConfig.Access.Routes, newConfig.Access.Routes) {
+			log.Debugf("  auth.routes: %d -> %d entries", len(oldConfig.Access.Routes), len(newConfig.Access.Routes))
+		}
+		if !reflect.DeepEqual(oldConfig.ModelPolicy, newConfig.ModelPolicy) {
+			log.Debugf("  model-policy: %d -> %d api keys", len(oldConfig.ModelPolicy.APIKeys), len(newConfig.ModelPolicy.APIKeys))
+		}
+		if !reflect.DeepEqual(oldConfig.ClientCredentials, newConfig.ClientCredentials) {
+			log.Debugf("  client-credentials.enable: %t -> %t, providers: %d -> %d, api-keys: %d -> %d",
+				oldConfig.ClientCredentials.Enable, newConfig.ClientCredentials.Enable,
+				len(oldConfig.ClientCredentials.Providers), len(newConfig.ClientCredentials.Providers),
+				len(oldConfig.ClientCredentials.APIKeys), len(newConfig.ClientCredentials.APIKeys))
+		}
+		if !reflect.DeepEqual(oldConfig.MaintenanceWindows, newConfig.MaintenanceWindows) {
+			log.Debugf("  maintenance-windows: %d -> %d entries", len(oldConfig.MaintenanceWindows), len(newConfig.MaintenanceWindows))
+		}
+		if !reflect.DeepEqual(oldConfig.ModelAffinity, newConfig.ModelAffinity) {
+			log.Debugf("  model-affinity: %d -> %d entries", len(oldConfig.ModelAffinity), len(newConfig.ModelAffinity))
+		}
+		if !reflect.DeepEqual(oldConfig.ToolResultLimit, newConfig.ToolResultLimit) {
+			log.Debugf("  tool-result-limit: max-bytes %d -> %d, max-request-bytes %d -> %d, strategy %s -> %s", oldConfig.ToolResultLimit.MaxBytes, newConfig.ToolResultLimit.MaxBytes, oldConfig.ToolResultLimit.MaxRequestBytes, newConfig.ToolResultLimit.MaxRequestBytes, oldConfig.ToolResultLimit.Strategy, newConfig.ToolResultLimit.Strategy)
+		}
+		if !reflect.DeepEqual(oldConfig.ClaudeBeta, newConfig.ClaudeBeta) {
+			log.Debugf("  claude-beta: %d -> %d models, disable-query %t -> %t", len(oldConfig.ClaudeBeta.Models), len(newConfig.ClaudeBeta.Models), oldConfig.ClaudeBeta.DisableQuery, newConfig.ClaudeBeta.DisableQuery)
+		}
+		if !reflect.DeepEqual(oldConfig.Passthrough, newConfig.Passthrough) {
+			log.Debugf("  passthrough: allow-header %t -> %t, %d -> %d models", oldConfig.Passthrough.AllowHeader, newConfig.Passthrough.AllowHeader, len(oldConfig.Passthrough.Models), len(newConfig.Passthrough.Models))
+		}
+		if !reflect.DeepEqual(oldConfig.ResponseTag, newConfig.ResponseTag) {
+			log.Debugf("  response-tag: enable %t -> %t, %d -> %d api keys", oldConfig.ResponseTag.Enable, newConfig.ResponseTag.Enable, len(oldConfig.ResponseTag.APIKeys), len(newConfig.ResponseTag.APIKeys))
+		}
+		if !reflect.DeepEqual(oldConfig.ResponseFooter, newConfig.ResponseFooter) {
+			log.Debugf("  response-footer: position %q -> %q, text changed %t", oldConfig.ResponseFooter.Position, newConfig.ResponseFooter.Position, oldConfig.ResponseFooter.Text != newConfig.ResponseFooter.Text)
+		}
+		if oldConfig.JSONRepair != newConfig.JSONRepair {
+			log.Debugf("  json-repair: %t -> %t", oldConfig.JSONRepair, newConfig.JSONRepair)
+		}
+		if !reflect.DeepEqual(oldConfig.AccountHeaderKeys, newConfig.AccountHeaderKeys) {
+			log.Debugf("  account-header-keys: %d -> %d keys", len(oldConfig.AccountHeaderKeys), len(newConfig.AccountHeaderKeys))
+		}
+		if oldConfig.GeminiCLIOperationTimeout != newConfig.GeminiCLIOperationTimeout {
+			log.Debugf("  gemini-cli-operation-timeout: %d -> %d", oldConfig.GeminiCLIOperationTimeout, newConfig.GeminiCLIOperationTimeout)
+		}
+		if oldConfig.Capture != newConfig.Capture {
+			log.Debugf("  capture: enable %t -> %t, dir %q -> %q", oldConfig.Capture.Enable, newConfig.Capture.Enable, oldConfig.Capture.Dir, newConfig.Capture.Dir)
+		}
+		if oldConfig.ModelVersions != newConfig.ModelVersions {
+			log.Debugf("  model-versions: enable %t -> %t, webhook %t -> %t", oldConfig.ModelVersions.Enable, newConfig.ModelVersions.Enable, oldConfig.ModelVersions.WebhookURL != "", newConfig.ModelVersions.WebhookURL != "")
+		}
+		if !reflect.DeepEqual(oldConfig.RequestQueue, newConfig.RequestQueue) {
+			log.Debugf("  request-queue: max-concurrent %d -> %d, max-queued %d -> %d, max-wait %d -> %d", oldConfig.RequestQueue.MaxConcurrent, newConfig.RequestQueue.MaxConcurrent, oldConfig.RequestQueue.MaxQueued, newConfig.RequestQueue.MaxQueued, oldConfig.RequestQueue.MaxWait, newConfig.RequestQueue.MaxWait)
+			log.Debugf("  request-queue priority: reserved-fraction %.2f -> %.2f, low-priority-keys %d -> %d", oldConfig.RequestQueue.ReservedFraction, newConfig.RequestQueue.ReservedFraction, len(oldConfig.RequestQueue.LowPriorityKeys), len(newConfig.RequestQueue.LowPriorityKeys))
+		}
+		if !reflect.DeepEqual(oldConfig.Moderation, newConfig.Moderation) {
+			log.Debugf("  moderation: enable %t -> %t, %d -> %d rules", oldConfig.Moderation.Enable, newConfig.Moderation.Enable, len(oldConfig.Moderation.Keywords)+len(oldConfig.Moderation.Patterns), len(newConfig.Moderation.Keywords)+len(newConfig.Moderation.Patterns))
+		}
+		if !reflect.DeepEqual(oldConfig.SafetySettings, newConfig.SafetySettings) {
+			log.Debugf("  safety-settings: %d -> %d defaults, %d -> %d api keys, enforce-floor %t -> %t", len(oldConfig.SafetySettings.Defaults), len(newConfig.SafetySettings.Defaults), len(oldConfig.SafetySettings.APIKeys), len(newConfig.SafetySettings.APIKeys), oldConfig.SafetySettings.EnforceFloor, newConfig.SafetySettings.EnforceFloor)
+		}
+		if !reflect.DeepEqual(oldConfig.UnknownRequestFields, newConfig.UnknownRequestFields) {
+			log.Debugf("  unknown-request-fields: %v -> %v", oldConfig.UnknownRequestFields, newConfig.UnknownRequestFields)
+		}
+		if !reflect.DeepEqual(oldConfig.OAuthSuccessPage, newConfig.OAuthSuccessPage) {
+			log.Debugf("  oauth-success-page: redirect-url %q -> %q, auto-close %t -> %t", oldConfig.OAuthSuccessPage.RedirectURL, newConfig.OAuthSuccessPage.RedirectURL, oldConfig.OAuthSuccessPage.AutoClose, newConfig.OAuthSuccessPage.AutoClose)
+		}
 		if oldConfig.GeminiWeb.Context != newConfig.GeminiWeb.Context {
 			log.Debugf("  gemini-web.context: %t -> %t", oldConfig.GeminiWeb.Context, newConfig.GeminiWeb.Context)
 		}
@@ -476,6 +625,39 @@ func (w *Watcher) reloadConfig() bool {
 		if oldConfig.GeminiWeb.CodeMode != newConfig.GeminiWeb.CodeMode {
 			log.Debugf("  gemini-web.code-mode: %t -> %t", oldConfig.GeminiWeb.CodeMode, newConfig.GeminiWeb.CodeMode)
 		}
+		if oldConfig.GeminiWeb.CodeModeReasoning != newConfig.GeminiWeb.CodeModeReasoning {
+			log.Debugf("  gemini-web.code-mode-reasoning: %t -> %t", oldConfig.GeminiWeb.CodeModeReasoning, newConfig.GeminiWeb.CodeModeReasoning)
+		}
+		if oldConfig.GeminiWeb.EmptyPrompt != newConfig.GeminiWeb.EmptyPrompt {
+			log.Debugf("  gemini-web.empty-prompt: %s -> %s", oldConfig.GeminiWeb.EmptyPrompt, newConfig.GeminiWeb.EmptyPrompt)
+		}
+		if oldConfig.GeminiWeb.ToolSchemas != newConfig.GeminiWeb.ToolSchemas {
+			log.Debugf("  gemini-web.tool-schemas: %s -> %s", oldConfig.GeminiWeb.ToolSchemas, newConfig.GeminiWeb.ToolSchemas)
+		}
+		if oldConfig.GeminiWeb.AllowTools != newConfig.GeminiWeb.AllowTools {
+			log.Debugf("  gemini-web.allow-tools: %t -> %t", oldConfig.GeminiWeb.AllowTools, newConfig.GeminiWeb.AllowTools)
+		}
+		if oldConfig.GeminiWeb.TitleModel != newConfig.GeminiWeb.TitleModel {
+			log.Debugf("  gemini-web.title-model: %s -> %s", oldConfig.GeminiWeb.TitleModel, newConfig.GeminiWeb.TitleModel)
+		}
+		if oldConfig.GeminiWeb.ActiveHours != newConfig.GeminiWeb.ActiveHours {
+			log.Debugf("  gemini-web.active-hours: %s -> %s", oldConfig.GeminiWeb.ActiveHours, newConfig.GeminiWeb.ActiveHours)
+		}
+		if oldConfig.GeminiWeb.Timezone != newConfig.GeminiWeb.Timezone {
+			log.Debugf("  gemini-web.timezone: %s -> %s", oldConfig.GeminiWeb.Timezone, newConfig.GeminiWeb.Timezone)
+		}
+		if !reflect.DeepEqual(oldConfig.GeminiWeb.ProxyPool, newConfig.GeminiWeb.ProxyPool) {
+			log.Debugf("  gemini-web.proxy-pool: %d -> %d proxies", len(oldConfig.GeminiWeb.ProxyPool), len(newConfig.GeminiWeb.ProxyPool))
+		}
+		if oldConfig.GeminiWeb.RefreshInterval != newConfig.GeminiWeb.RefreshInterval {
+			log.Debugf("  gemini-web.refresh-interval: %d -> %d", oldConfig.GeminiWeb.RefreshInterval, newConfig.GeminiWeb.RefreshInterval)
+		}
+		if oldConfig.GeminiWeb.PersistInterval != newConfig.GeminiWeb.PersistInterval {
+			log.Debugf("  gemini-web.persist-interval: %d -> %d", oldConfig.GeminiWeb.PersistInterval, newConfig.GeminiWeb.PersistInterval)
+		}
+		if oldConfig.GeminiWeb.IntervalJitter != newConfig.GeminiWeb.IntervalJitter {
+			log.Debugf("  gemini-web.interval-jitter: %d -> %d", oldConfig.GeminiWeb.IntervalJitter, newConfig.GeminiWeb.IntervalJitter)
+		}
 		if len(oldConfig.APIKeys) != len(newConfig.APIKeys) {
 			log.Debugf("  api-keys count: %d -> %d", len(oldConfig.APIKeys), len(newConfig.APIKeys))
 		}
@@ -488,15 +670,27 @@ func (w *Watcher) reloadConfig() bool {
 		if len(oldConfig.CodexKey) != len(newConfig.CodexKey) {
 			log.Debugf("  codex-api-key count: %d -> %d", len(oldConfig.CodexKey), len(newConfig.CodexKey))
 		}
+		if oldConfig.Providers.Echo != newConfig.Providers.Echo {
+			log.Debugf("  providers.echo: enabled %t -> %t, chunk-size %d -> %d, chunk-delay %d -> %d, timeout %d -> %d", oldConfig.Providers.Echo.Enabled, newConfig.Providers.Echo.Enabled, oldConfig.Providers.Echo.ChunkSize, newConfig.Providers.Echo.ChunkSize, oldConfig.Providers.Echo.ChunkDelay, newConfig.Providers.Echo.ChunkDelay, oldConfig.Providers.Echo.Timeout, newConfig.Providers.Echo.Timeout)
+		}
+		if len(oldConfig.RemoteManagement.Keys) != len(newConfig.RemoteManagement.Keys) {
+			log.Debugf("  remote-management.keys: %d -> %d entries", len(oldConfig.RemoteManagement.Keys), len(newConfig.RemoteManagement.Keys))
+		}
 		if oldConfig.RemoteManagement.AllowRemote != newConfig.RemoteManagement.AllowRemote {
 			log.Debugf("  remote-management.allow-remote: %t -> %t", oldConfig.RemoteManagement.AllowRemote, newConfig.RemoteManagement.AllowRemote)
 		}
+		if oldConfig.RemoteManagement.Leader != newConfig.RemoteManagement.Leader {
+			log.Debugf("  remote-management.leader: url %q -> %q, secret-key changed %t", oldConfig.RemoteManagement.Leader.URL, newConfig.RemoteManagement.Leader.URL, oldConfig.RemoteManagement.Leader.SecretKey != newConfig.RemoteManagement.Leader.SecretKey)
+		}
 		if oldConfig.LoggingToFile != newConfig.LoggingToFile {
 			log.Debugf("  logging-to-file: %t -> %t", oldConfig.LoggingToFile, newConfig.LoggingToFile)
 		}
 		if oldConfig.UsageStatisticsEnabled != newConfig.UsageStatisticsEnabled {
 			log.Debugf("  usage-statistics-enabled: %t -> %t", oldConfig.UsageStatisticsEnabled, newConfig.UsageStatisticsEnabled)
 		}
+		if !reflect.DeepEqual(oldConfig.Pricing, newConfig.Pricing) {
+			log.Debugf("  pricing: header %t -> %t, %d -> %d models", oldConfig.Pricing.Header, newConfig.Pricing.Header, len(oldConfig.Pricing.Models), len(newConfig.Pricing.Models))
+		}
 	}
 
 	log.Infof("config successfully reloaded, triggering client reload")
@@ -705,6 +899,17 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			}
 			out = append(out, a)
 		}
+		if cfg.Providers.Echo.Enabled {
+			out = append(out, &coreauth.Auth{
+				ID:         "echo",
+				Provider:   "echo",
+				Label:      "echo",
+				Status:     coreauth.StatusActive,
+				Attributes: map[string]string{"source": "config:echo"},
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			})
+		}
 		for i := range cfg.OpenAICompatibility {
 			compat := &cfg.OpenAICompatibility[i]
 			providerName := strings.ToLower(strings.TrimSpace(compat.Name))
@@ -787,6 +992,9 @@ func (w *Watcher) SnapshotCoreAuths() []*coreauth.Auth {
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
+		a.ApplyMetadataDisabled()
+		a.ApplyMetadataRouting()
+		a.ApplyMetadataEndpoint()
 		out = append(out, a)
 	}
 	return out
@@ -0,0 +1,28 @@
+// Package wildcard matches model IDs and similar names against patterns in which '*' stands
+// for any run of characters. Unlike path.Match, '*' also spans '/', which model IDs such as
+// "models/gemini-2.5-pro" or "anthropic/claude-sonnet-4" contain.
+package wildcard
+
+import "strings"
+
+// Match reports whether value matches pattern, in which '*' stands for any run of
+// characters, including none. The comparison is case-sensitive.
+func Match(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, last)
+}
@@ -0,0 +1,25 @@
+package wildcard
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"gpt-5", "gpt-5", true},
+		{"gpt-5", "gpt-5-codex", false},
+		{"gpt-5*", "gpt-5-codex", true},
+		{"*", "", true},
+		{"*/claude-*", "anthropic/claude-sonnet-4", true},
+		{"models/*", "models/gemini-2.5-pro", true},
+		{"gemini-*-pro", "gemini-2.5-flash", false},
+		{"a*b*c", "axxbyyc", true},
+		{"ab*b", "ab", false},
+		{"GPT-5*", "gpt-5", false},
+	} {
+		if got := Match(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("Match(%q, %q) = %t, want %t", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
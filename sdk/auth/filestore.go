@@ -197,6 +197,9 @@ func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 	if email, ok := metadata["email"].(string); ok && email != "" {
 		auth.Attributes["email"] = email
 	}
+	auth.ApplyMetadataDisabled()
+	auth.ApplyMetadataRouting()
+	auth.ApplyMetadataEndpoint()
 	return auth, nil
 }
 
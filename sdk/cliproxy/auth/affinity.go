@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/wildcard"
+)
+
+// BlockReasonAffinitySpare is reported for auths passed over because the model prefers
+// other auths that are available.
+const BlockReasonAffinitySpare = "affinity_spare"
+
+// ModelAffinity makes requests for matching models prefer matching auths. Patterns may use
+// '*' for any run of characters; models match case-insensitively, auths by ID or file name.
+type ModelAffinity struct {
+	Models []string
+	Auths  []string
+}
+
+// SetModelAffinity replaces the model affinity rules. For every request the first rule whose
+// models match applies: its auths are selected while any of them is available, and the other
+// auths of the provider only serve the request once they are all tried, cooling down or out
+// of quota.
+func (m *Manager) SetModelAffinity(rules []ModelAffinity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.affinity = append([]ModelAffinity(nil), rules...)
+}
+
+// preferAffine narrows candidates to the auths model has affinity with, unless none of them
+// can serve it at now.
+func (m *Manager) preferAffine(candidates []*Auth, model string, now time.Time) []*Auth {
+	m.mu.RLock()
+	rules := m.affinity
+	m.mu.RUnlock()
+	for _, rule := range rules {
+		if !matchesAny(rule.Models, strings.ToLower(model), true) {
+			continue
+		}
+		preferred := make([]*Auth, 0, len(candidates))
+		for _, candidate := range candidates {
+			if isAuthBlockedForModel(candidate, model, now) {
+				continue
+			}
+			if matchesAny(rule.Auths, candidate.ID, false) || matchesAny(rule.Auths, filepath.Base(candidate.ID), false) {
+				preferred = append(preferred, candidate)
+			}
+		}
+		if len(preferred) == 0 {
+			return candidates
+		}
+		return preferred
+	}
+	return candidates
+}
+
+// matchesAny reports whether value matches one of patterns.
+func matchesAny(patterns []string, value string, fold bool) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if fold {
+			pattern = strings.ToLower(pattern)
+		}
+		if pattern != "" && wildcard.Match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Attempt describes one execution against an auth.
+type Attempt struct {
+	AuthID   string `json:"auth_id"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Status is the HTTP status of the attempt: 200 when it succeeded, the upstream status
+	// when it failed, or zero when the upstream never answered.
+	Status   int           `json:"status,omitempty"`
+	Error    *Error        `json:"error,omitempty"`
+	At       time.Time     `json:"at"`
+	Duration time.Duration `json:"duration"`
+}
+
+type attemptLogKey struct{}
+
+type attemptLog struct {
+	mu       sync.Mutex
+	attempts []Attempt
+}
+
+// WithAttemptLog returns a context under which the manager records every execution, so a
+// caller can report what was tried once a request completes or gives up.
+func WithAttemptLog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptLogKey{}, &attemptLog{})
+}
+
+// Attempts returns the executions recorded under ctx, oldest first.
+func Attempts(ctx context.Context) []Attempt {
+	l, _ := ctx.Value(attemptLogKey{}).(*attemptLog)
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Attempt(nil), l.attempts...)
+}
+
+// recordAttempt adds result to the attempt log of ctx; started is when the execution began.
+func recordAttempt(ctx context.Context, result Result, started time.Time) {
+	if ctx == nil {
+		return
+	}
+	l, _ := ctx.Value(attemptLogKey{}).(*attemptLog)
+	if l == nil {
+		return
+	}
+	attempt := Attempt{
+		AuthID:   result.AuthID,
+		Provider: result.Provider,
+		Model:    result.Model,
+		Error:    result.Error,
+		At:       started,
+		Duration: time.Since(started),
+	}
+	if result.Success {
+		attempt.Status = http.StatusOK
+	} else if result.Error != nil {
+		attempt.Status = result.Error.HTTPStatus
+	}
+	l.mu.Lock()
+	l.attempts = append(l.attempts, attempt)
+	l.mu.Unlock()
+}
+
+// retryBudget bounds the attempts of one request across all its providers and keeps the
+// errors worth returning once it gives up.
+type retryBudget struct {
+	// remaining is the number of attempts left, negative for no limit.
+	remaining int
+	// deadline is the time after which no attempt starts, zero for none.
+	deadline time.Time
+	// last is the error of the latest failed attempt, lastNonQuota that of the latest one
+	// not rejected with a 429.
+	last         error
+	lastNonQuota error
+}
+
+func (m *Manager) newRetryBudget() *retryBudget {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	budget := &retryBudget{remaining: -1}
+	if m.retryMaxAttempts > 0 {
+		budget.remaining = m.retryMaxAttempts
+	}
+	if m.retryDeadline > 0 {
+		budget.deadline = time.Now().Add(m.retryDeadline)
+	}
+	return budget
+}
+
+// attemptContext returns the context for one attempt, cancelled when the deadline passes
+// while the attempt is still running. The returned stop must be called once the attempt has
+// returned; it stops watching the deadline, so a stream or body it returned keeps running,
+// and reports whether the deadline cut the attempt short.
+func (b *retryBudget) attemptContext(ctx context.Context) (context.Context, func() bool) {
+	if b.deadline.IsZero() {
+		return ctx, func() bool { return false }
+	}
+	attemptCtx, cancel := context.WithCancel(ctx)
+	var expired atomic.Bool
+	timer := time.AfterFunc(time.Until(b.deadline), func() {
+		expired.Store(true)
+		cancel()
+	})
+	return attemptCtx, func() bool {
+		timer.Stop()
+		return expired.Load()
+	}
+}
+
+// deadlineExceeded is the error of an attempt the deadline cut short.
+func deadlineExceeded() *Error {
+	return &Error{Code: "retry_deadline_exceeded", Message: "retry deadline passed while the upstream request was running", HTTPStatus: http.StatusGatewayTimeout}
+}
+
+// allow reports whether another attempt may start.
+func (b *retryBudget) allow() bool {
+	if b.remaining == 0 {
+		return false
+	}
+	return b.deadline.IsZero() || time.Now().Before(b.deadline)
+}
+
+// spend counts an attempt that is about to start.
+func (b *retryBudget) spend() {
+	if b.remaining > 0 {
+		b.remaining--
+	}
+}
+
+// fail records the error of a failed attempt.
+func (b *retryBudget) fail(err error) {
+	b.last = err
+	if errorFromExecution(err).HTTPStatus != http.StatusTooManyRequests {
+		b.lastNonQuota = err
+	}
+}
+
+// err returns the most informative error seen: the latest one that was not a quota
+// rejection, since it usually tells more than the generic quota message, or else the latest.
+// It is nil when no attempt failed.
+func (b *retryBudget) err() error {
+	if b.lastNonQuota != nil {
+		return b.lastNonQuota
+	}
+	return b.last
+}
+
+// exhausted returns the error a request ends with once allow turned false.
+func (b *retryBudget) exhausted() error {
+	if err := b.err(); err != nil {
+		return err
+	}
+	return &Error{Code: "retry_budget_exhausted", Message: "retry budget exhausted before any attempt", HTTPStatus: http.StatusServiceUnavailable}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// hangingExecutor waits for its context on every call, like an upstream that never answers.
+type hangingExecutor struct{}
+
+func (hangingExecutor) Identifier() string { return "claude" }
+
+func (hangingExecutor) Execute(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	<-ctx.Done()
+	return cliproxyexecutor.Response{}, ctx.Err()
+}
+
+func (hangingExecutor) ExecuteStream(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (hangingExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (hangingExecutor) CountTokens(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	<-ctx.Done()
+	return cliproxyexecutor.Response{}, ctx.Err()
+}
+
+func TestRetryDeadlineCancelsRunningAttempt(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(hangingExecutor{})
+	m.SetRetryBudget(0, 50*time.Millisecond)
+	if _, err := m.Register(context.Background(), &Auth{ID: "slow", Provider: "claude", Status: StatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	req := cliproxyexecutor.Request{Model: "claude-sonnet-4"}
+
+	calls := map[string]func(context.Context) error{
+		"execute": func(ctx context.Context) error {
+			_, err := m.Execute(ctx, []string{"claude"}, req, cliproxyexecutor.Options{})
+			return err
+		},
+		"stream": func(ctx context.Context) error {
+			_, err := m.ExecuteStream(ctx, []string{"claude"}, req, cliproxyexecutor.Options{})
+			return err
+		},
+		"count": func(ctx context.Context) error {
+			_, err := m.ExecuteCount(ctx, []string{"claude"}, req, cliproxyexecutor.Options{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		ctx := WithAttemptLog(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- call(ctx) }()
+		var err error
+		select {
+		case err = <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: attempt ran past the deadline", name)
+		}
+		var authErr *Error
+		if !errors.As(err, &authErr) || authErr.Code != "retry_deadline_exceeded" || authErr.StatusCode() != http.StatusGatewayTimeout {
+			t.Errorf("%s: error = %v, want a 504 retry_deadline_exceeded", name, err)
+		}
+		if attempts := Attempts(ctx); len(attempts) != 1 || attempts[0].Status != http.StatusGatewayTimeout {
+			t.Errorf("%s: attempts = %+v, want the cancelled one", name, attempts)
+		}
+	}
+	if auth, _ := m.GetByID("slow"); auth.Unavailable || auth.LastError != nil {
+		t.Errorf("auth marked failed by the deadline: %+v", auth)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BlockReasonEgressBlocked is reported for auths sending through a proxy, or the direct
+// connection, that their provider's upstream temporarily blocked.
+const BlockReasonEgressBlocked = "egress_blocked"
+
+// egressBlocks tracks the egresses an upstream blocked. A block is keyed by provider and
+// proxy URL: an IP block hits every account of the provider behind that address, whatever
+// model it was serving, while other proxies stay usable.
+type egressBlocks struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// egressKey identifies the egress of auth. Auths without their own proxy share the global
+// proxy or direct connection.
+func egressKey(auth *Auth) string {
+	return strings.ToLower(auth.Provider) + "|" + strings.TrimSpace(auth.ProxyURL)
+}
+
+// block records that the egress of auth is blocked until until, keeping a later block.
+func (b *egressBlocks) block(auth *Auth, until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.until == nil {
+		b.until = make(map[string]time.Time)
+	}
+	key := egressKey(auth)
+	if current, ok := b.until[key]; !ok || until.After(current) {
+		b.until[key] = until
+	}
+}
+
+// blockedUntil reports whether the egress of auth is blocked at now and until when.
+func (b *egressBlocks) blockedUntil(auth *Auth, now time.Time) (time.Time, bool) {
+	if auth == nil {
+		return time.Time{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := egressKey(auth)
+	until, ok := b.until[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !until.After(now) {
+		delete(b.until, key)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// blockEgressLocked cools down every auth sharing the egress of auth until until. The
+// caller holds m.mu.
+func (m *Manager) blockEgressLocked(auth *Auth, until time.Time) {
+	m.egress.block(auth, until)
+	key := egressKey(auth)
+	shared := 0
+	for _, other := range m.auths {
+		if other != nil && egressKey(other) == key {
+			shared++
+		}
+	}
+	proxy := "the default egress"
+	if u, err := url.Parse(strings.TrimSpace(auth.ProxyURL)); err == nil && u.Host != "" {
+		proxy = u.Host
+	}
+	log.Warnf("%s blocked requests from %s; cooling down %d auth(s) sharing it until %s", auth.Provider, proxy, shared, until.Format(time.RFC3339))
+}
+
+// withoutBlockedEgress drops the candidates whose egress is blocked.
+func (m *Manager) withoutBlockedEgress(candidates []*Auth, now time.Time) []*Auth {
+	available := candidates[:0:0]
+	for _, candidate := range candidates {
+		if _, blocked := m.egress.blockedUntil(candidate, now); blocked {
+			continue
+		}
+		available = append(available, candidate)
+	}
+	return available
+}
@@ -0,0 +1,42 @@
+package auth
+
+import "context"
+
+// SourceRequest marks, in the "source" attribute, an auth built from a credential the client
+// sent with its request.
+const SourceRequest = "request"
+
+// BlockReasonClientCredential is reported for registered auths a credential sent with the
+// request stands in for.
+const BlockReasonClientCredential = "client_credential"
+
+type ephemeralAuthKey struct{}
+
+// WithEphemeralAuth returns a context under which executions for auth.Provider use auth
+// instead of picking one of the registered auths. The auth is never registered, persisted or
+// marked with the result, so nothing of it outlives the request.
+func WithEphemeralAuth(ctx context.Context, auth *Auth) context.Context {
+	return context.WithValue(ctx, ephemeralAuthKey{}, auth)
+}
+
+// EphemeralAuth returns the auth set on ctx by WithEphemeralAuth.
+func EphemeralAuth(ctx context.Context) (*Auth, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	auth, ok := ctx.Value(ephemeralAuthKey{}).(*Auth)
+	return auth, ok && auth != nil
+}
+
+// ephemeralFor returns the ephemeral auth of ctx when it is for provider. Once it has been
+// tried the request has no other auth to fall back to.
+func ephemeralFor(ctx context.Context, provider string, tried map[string]struct{}) (*Auth, bool, error) {
+	auth, ok := EphemeralAuth(ctx)
+	if !ok || auth.Provider != provider {
+		return nil, false, nil
+	}
+	if _, done := tried[auth.ID]; done {
+		return nil, true, &Error{Code: "auth_not_found", Message: "the credential supplied with the request failed"}
+	}
+	return auth, true, nil
+}
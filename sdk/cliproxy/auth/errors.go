@@ -1,5 +1,12 @@
 package auth
 
+import (
+	"errors"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
 // Error describes an authentication related failure in a provider agnostic format.
 type Error struct {
 	// Code is a short machine readable identifier.
@@ -10,6 +17,13 @@ type Error struct {
 	Retryable bool `json:"retryable"`
 	// HTTPStatus optionally records an HTTP-like status code for the error.
 	HTTPStatus int `json:"http_status,omitempty"`
+	// Kind classifies the failure so callers can branch without re-inspecting the status.
+	Kind cliproxyexecutor.ErrorKind `json:"kind,omitempty"`
+	// RetryAfter is the upstream's advised wait before retrying, zero when not advertised.
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+	// EgressBlocked marks a failure caused by the upstream blocking the proxy or address the
+	// request was sent from.
+	EgressBlocked bool `json:"egress_blocked,omitempty"`
 }
 
 // Error implements the error interface.
@@ -30,3 +44,51 @@ func (e *Error) StatusCode() int {
 	}
 	return e.HTTPStatus
 }
+
+// errorFromExecution converts an executor failure into an Error carrying its status and kind.
+func errorFromExecution(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	out := &Error{
+		Message:       err.Error(),
+		Kind:          cliproxyexecutor.ErrorKindOf(err),
+		RetryAfter:    cliproxyexecutor.RetryAfterOf(err),
+		EgressBlocked: cliproxyexecutor.EgressBlockedOf(err),
+	}
+	var se cliproxyexecutor.StatusError
+	if errors.As(err, &se) && se != nil {
+		out.HTTPStatus = se.StatusCode()
+	}
+	return out
+}
+
+// kindFromResult returns the recorded kind of err, deriving it from the status when absent.
+func kindFromResult(err *Error) cliproxyexecutor.ErrorKind {
+	if err == nil {
+		return ""
+	}
+	if err.Kind != "" {
+		return err.Kind
+	}
+	return cliproxyexecutor.ErrorKindForStatus(err.HTTPStatus)
+}
+
+// quotaCooldown returns how long an auth stays cooled down after a quota error, honouring
+// the upstream's advised wait when present.
+func quotaCooldown(err *Error) time.Duration {
+	return advisedCooldown(err, defaultQuotaCooldown)
+}
+
+// transientCooldown returns how long an auth stays cooled down after a transient error,
+// such as a 503 carrying Retry-After, honouring the upstream's advised wait when present.
+func transientCooldown(err *Error) time.Duration {
+	return advisedCooldown(err, defaultTransientCooldown)
+}
+
+func advisedCooldown(err *Error, fallback time.Duration) time.Duration {
+	if err != nil && err.RetryAfter > 0 {
+		return err.RetryAfter
+	}
+	return fallback
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"sync"
+)
+
+// inflightRequests counts the upstream requests each auth is serving, so an auth can be
+// drained before the state behind it is torn down.
+type inflightRequests struct {
+	mu     sync.Mutex
+	counts map[string]int
+	idle   map[string][]chan struct{}
+}
+
+// begin records that a request started on the auth with id.
+func (r *inflightRequests) begin(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	r.counts[id]++
+}
+
+// end records that a request on the auth with id finished, waking the waiters once the
+// auth has none left.
+func (r *inflightRequests) end(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts[id] > 1 {
+		r.counts[id]--
+		return
+	}
+	delete(r.counts, id)
+	for _, ch := range r.idle[id] {
+		close(ch)
+	}
+	delete(r.idle, id)
+}
+
+// waiter returns a channel closed when the auth with id has no requests left, or nil when
+// it has none now.
+func (r *inflightRequests) waiter(id string) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts[id] == 0 {
+		return nil
+	}
+	if r.idle == nil {
+		r.idle = make(map[string][]chan struct{})
+	}
+	ch := make(chan struct{})
+	r.idle[id] = append(r.idle[id], ch)
+	return ch
+}
+
+// InFlight returns the number of upstream requests the auth with id is serving.
+func (m *Manager) InFlight(id string) int {
+	m.inflight.mu.Lock()
+	defer m.inflight.mu.Unlock()
+	return m.inflight.counts[id]
+}
+
+// WaitIdle blocks until the auth with id serves no upstream requests, and reports false
+// when ctx ends first. Streams count until their last chunk is delivered.
+func (m *Manager) WaitIdle(ctx context.Context, id string) bool {
+	ch := m.inflight.waiter(id)
+	if ch == nil {
+		return true
+	}
+	select {
+	case <-ch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
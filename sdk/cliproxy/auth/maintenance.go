@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlockReasonMaintenance is reported for auths inside a maintenance period.
+const BlockReasonMaintenance = "maintenance"
+
+// MaintenanceWindow is a recurring period during which matching auths are not selected.
+// It matches auths of Provider, or the single auth whose ID or file name is AuthID.
+type MaintenanceWindow struct {
+	Provider string
+	AuthID   string
+	// Days lists the weekdays on which the window starts; empty means every day.
+	Days []time.Weekday
+	// Start and End are offsets from local midnight. An End not after Start ends the next day.
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// MaintenanceState describes the maintenance state of one auth.
+type MaintenanceState struct {
+	Active bool `json:"active"`
+	// Manual reports whether the active period was started through the management API.
+	Manual bool `json:"manual,omitempty"`
+	// Until is when the active period ends; nil for a manual period without an end.
+	Until *time.Time `json:"until,omitempty"`
+	// NextStart is when the next scheduled window starts, if any.
+	NextStart *time.Time `json:"next_start,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseMaintenanceWindow builds a window from its configuration form: days as three letter
+// English weekday names, start and end as HH:MM and timezone as an IANA name (UTC if empty).
+func ParseMaintenanceWindow(provider, authID string, days []string, start, end, timezone string) (MaintenanceWindow, error) {
+	window := MaintenanceWindow{Provider: strings.ToLower(strings.TrimSpace(provider)), AuthID: strings.TrimSpace(authID)}
+	if window.Provider == "" && window.AuthID == "" {
+		return window, fmt.Errorf("maintenance window needs a provider or an auth")
+	}
+	for _, day := range days {
+		key := strings.ToLower(strings.TrimSpace(day))
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		weekday, ok := weekdays[key]
+		if !ok {
+			return window, fmt.Errorf("maintenance window: unknown day %q", day)
+		}
+		window.Days = append(window.Days, weekday)
+	}
+	var err error
+	if window.Start, err = parseClock(start); err != nil {
+		return window, fmt.Errorf("maintenance window start: %w", err)
+	}
+	if window.End, err = parseClock(end); err != nil {
+		return window, fmt.Errorf("maintenance window end: %w", err)
+	}
+	window.Location = time.UTC
+	if tz := strings.TrimSpace(timezone); tz != "" {
+		if window.Location, err = time.LoadLocation(tz); err != nil {
+			return window, fmt.Errorf("maintenance window timezone: %w", err)
+		}
+	}
+	return window, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// matches reports whether the window applies to auth.
+func (w MaintenanceWindow) matches(auth *Auth) bool {
+	if auth == nil {
+		return false
+	}
+	if w.AuthID != "" {
+		return auth.ID == w.AuthID || filepath.Base(auth.ID) == w.AuthID
+	}
+	return strings.EqualFold(auth.Provider, w.Provider)
+}
+
+// at reports whether now falls inside an occurrence of the window and the next time that
+// changes: the end of the current occurrence, or the start of the next one.
+func (w MaintenanceWindow) at(now time.Time) (active bool, boundary time.Time) {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	year, month, day := local.Date()
+	// Occurrences start at most one day before now, and a weekly one within the next week.
+	for offset := -1; offset <= 7; offset++ {
+		date := time.Date(year, month, day+offset, 0, 0, 0, 0, loc)
+		if !w.onDay(date.Weekday()) {
+			continue
+		}
+		start := clockOn(date, w.Start, loc)
+		end := clockOn(date, w.End, loc)
+		if !end.After(start) {
+			end = clockOn(date.AddDate(0, 0, 1), w.End, loc)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return true, end
+		}
+		if start.After(now) {
+			return false, start
+		}
+	}
+	return false, time.Time{}
+}
+
+func (w MaintenanceWindow) onDay(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// clockOn returns the wall clock time offset after midnight of date, so DST changes shift
+// the window with local time rather than by elapsed duration.
+func clockOn(date time.Time, offset time.Duration, loc *time.Location) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, loc)
+}
+
+// maintenanceSchedule caches the state of every window until the earliest boundary, so
+// checking an auth during selection costs a loop over the windows without date arithmetic.
+type maintenanceSchedule struct {
+	mu         sync.Mutex
+	windows    []MaintenanceWindow
+	active     []bool
+	boundaries []time.Time
+	validUntil time.Time
+	// manual maps auth IDs to the end of a manual period; a zero time has no end.
+	manual map[string]time.Time
+}
+
+func (s *maintenanceSchedule) refreshLocked(now time.Time) {
+	if now.Before(s.validUntil) {
+		return
+	}
+	s.active = make([]bool, len(s.windows))
+	s.boundaries = make([]time.Time, len(s.windows))
+	s.validUntil = now.Add(24 * time.Hour)
+	for i, window := range s.windows {
+		active, boundary := window.at(now)
+		s.active[i], s.boundaries[i] = active, boundary
+		if !boundary.IsZero() && boundary.Before(s.validUntil) {
+			s.validUntil = boundary
+		}
+	}
+}
+
+// state returns the maintenance state of auth at now.
+func (s *maintenanceSchedule) state(auth *Auth, now time.Time) MaintenanceState {
+	var state MaintenanceState
+	if auth == nil {
+		return state
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if until, ok := s.manual[auth.ID]; ok {
+		if until.IsZero() || until.After(now) {
+			state.Active, state.Manual = true, true
+			if !until.IsZero() {
+				state.Until = &until
+			}
+		} else {
+			delete(s.manual, auth.ID)
+		}
+	}
+	if len(s.windows) == 0 {
+		return state
+	}
+	s.refreshLocked(now)
+	for i, window := range s.windows {
+		if !window.matches(auth) {
+			continue
+		}
+		boundary := s.boundaries[i]
+		if s.active[i] {
+			if !state.Active {
+				state.Active = true
+				state.Until = &boundary
+			} else if !state.Manual && state.Until != nil && boundary.After(*state.Until) {
+				state.Until = &boundary
+			}
+		} else if !boundary.IsZero() && (state.NextStart == nil || boundary.Before(*state.NextStart)) {
+			state.NextStart = &boundary
+		}
+	}
+	return state
+}
+
+// SetMaintenanceWindows replaces the scheduled maintenance windows. Manual periods are kept.
+func (m *Manager) SetMaintenanceWindows(windows []MaintenanceWindow) {
+	m.maintenance.mu.Lock()
+	defer m.maintenance.mu.Unlock()
+	m.maintenance.windows = append([]MaintenanceWindow(nil), windows...)
+	m.maintenance.validUntil = time.Time{}
+}
+
+// StartMaintenance puts the auth with id into maintenance until until, or until
+// StopMaintenance when until is zero. It reports false for unknown auths.
+func (m *Manager) StartMaintenance(id string, until time.Time) bool {
+	if _, ok := m.GetByID(id); !ok {
+		return false
+	}
+	m.maintenance.mu.Lock()
+	defer m.maintenance.mu.Unlock()
+	if m.maintenance.manual == nil {
+		m.maintenance.manual = make(map[string]time.Time)
+	}
+	m.maintenance.manual[id] = until
+	return true
+}
+
+// StopMaintenance ends a manual maintenance period of the auth with id. Scheduled windows
+// are unaffected. It reports false when the auth had no manual period.
+func (m *Manager) StopMaintenance(id string) bool {
+	m.maintenance.mu.Lock()
+	defer m.maintenance.mu.Unlock()
+	if _, ok := m.maintenance.manual[id]; !ok {
+		return false
+	}
+	delete(m.maintenance.manual, id)
+	return true
+}
+
+// Maintenance returns the maintenance state of auth.
+func (m *Manager) Maintenance(auth *Auth) MaintenanceState {
+	return m.maintenance.state(auth, time.Now())
+}
+
+// withoutMaintenance drops the candidates inside a maintenance period.
+func (m *Manager) withoutMaintenance(candidates []*Auth, now time.Time) []*Auth {
+	available := candidates[:0:0]
+	for _, candidate := range candidates {
+		if m.maintenance.state(candidate, now).Active {
+			continue
+		}
+		available = append(available, candidate)
+	}
+	return available
+}
@@ -3,8 +3,11 @@ package auth
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,6 +34,12 @@ type ProviderExecutor interface {
 	CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
 }
 
+// FeatureDeclarer is implemented by executors that declare which request features their
+// provider supports. RegisterExecutor publishes them in the capabilities matrix.
+type FeatureDeclarer interface {
+	Features() registry.ProviderFeatures
+}
+
 // RefreshEvaluator allows runtime state to override refresh decisions.
 type RefreshEvaluator interface {
 	ShouldRefresh(now time.Time, auth *Auth) bool
@@ -40,6 +49,13 @@ const (
 	refreshCheckInterval  = 5 * time.Second
 	refreshPendingBackoff = time.Minute
 	refreshFailureBackoff = 5 * time.Minute
+	// fatalRefreshAttempts is how many consecutive refreshes rejected as unauthorized disable
+	// an auth until it is updated again.
+	fatalRefreshAttempts = 3
+	// defaultQuotaCooldown applies after a quota error when the upstream advises no wait.
+	defaultQuotaCooldown = 30 * time.Minute
+	// defaultTransientCooldown applies after a transient error when the upstream advises no wait.
+	defaultTransientCooldown = time.Minute
 )
 
 // Result captures execution outcome used to adjust auth state.
@@ -99,6 +115,40 @@ type Manager struct {
 
 	// Auto refresh state
 	refreshCancel context.CancelFunc
+	// refreshFailures counts consecutive failed refreshes per auth ID; any Update resets it.
+	refreshFailures map[string]int
+	// refreshLimits caps consecutive failed refreshes per provider; zero retries forever.
+	refreshLimits map[string]int
+	// refreshIntervals returns the time between background refreshes of a provider's auth,
+	// by auth ID, ahead of any interval stored with the auth.
+	refreshIntervals map[string]func(id string) time.Duration
+	// rejectionDisables holds the providers whose auths are disabled once their refresh is
+	// rejected fatalRefreshAttempts times in a row.
+	rejectionDisables map[string]bool
+
+	// recentFailureWindow is how long an auth that just failed is passed over in favour of
+	// ones that did not; zero disables it.
+	recentFailureWindow time.Duration
+
+	// retryMaxAttempts caps the attempts of one request and retryDeadline how long it keeps
+	// starting new ones; zero disables either.
+	retryMaxAttempts int
+	retryDeadline    time.Duration
+
+	// affinity lists the auths requests for some models prefer.
+	affinity []ModelAffinity
+
+	// maintenance holds scheduled and manual maintenance periods.
+	maintenance maintenanceSchedule
+
+	// schedule holds the active hours of auths.
+	schedule activeHoursSchedule
+
+	// egress holds the proxies the upstream of a provider temporarily blocked.
+	egress egressBlocks
+
+	// inflight counts the upstream requests each auth is serving.
+	inflight inflightRequests
 }
 
 // NewManager constructs a manager with optional custom selector and hook.
@@ -110,12 +160,16 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook = NoopHook{}
 	}
 	return &Manager{
-		store:           store,
-		executors:       make(map[string]ProviderExecutor),
-		selector:        selector,
-		hook:            hook,
-		auths:           make(map[string]*Auth),
-		providerOffsets: make(map[string]int),
+		store:             store,
+		executors:         make(map[string]ProviderExecutor),
+		selector:          selector,
+		hook:              hook,
+		auths:             make(map[string]*Auth),
+		providerOffsets:   make(map[string]int),
+		refreshFailures:   make(map[string]int),
+		refreshLimits:     make(map[string]int),
+		refreshIntervals:  make(map[string]func(id string) time.Duration),
+		rejectionDisables: make(map[string]bool),
 	}
 }
 
@@ -138,11 +192,83 @@ func (m *Manager) RegisterExecutor(executor ProviderExecutor) {
 	if executor == nil {
 		return
 	}
+	if declarer, ok := executor.(FeatureDeclarer); ok {
+		registry.GetGlobalRegistry().SetProviderFeatures(executor.Identifier(), declarer.Features())
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.executors[executor.Identifier()] = executor
 }
 
+// SetRefreshRetryLimit stops background refreshes of provider auths after attempts consecutive
+// failures, until the auth is updated (e.g. its file changes on disk). Zero retries forever.
+func (m *Manager) SetRefreshRetryLimit(provider string, attempts int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if attempts <= 0 {
+		delete(m.refreshLimits, provider)
+		return
+	}
+	m.refreshLimits[provider] = attempts
+}
+
+// SetRefreshInterval makes interval decide the time between background refreshes of
+// provider auths, from the moment they are registered, instead of an interval stored with
+// the auth or the provider's refresh lead. A nil interval removes it.
+func (m *Manager) SetRefreshInterval(provider string, interval func(id string) time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if interval == nil {
+		delete(m.refreshIntervals, provider)
+		return
+	}
+	m.refreshIntervals[provider] = interval
+}
+
+// SetDisableOnRejectedRefresh makes refreshes of provider auths rejected as unauthorized
+// fatalRefreshAttempts times in a row disable the auth until it is updated again. Other
+// providers' rejected refreshes back off like any failure, since their credentials may
+// recover or be fixed by a later request.
+func (m *Manager) SetDisableOnRejectedRefresh(provider string, disable bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !disable {
+		delete(m.rejectionDisables, provider)
+		return
+	}
+	m.rejectionDisables[provider] = true
+}
+
+// SetRecentFailureWindow makes selection prefer auths that have not failed for a model within
+// window over ones that have. Unlike cooldowns this never excludes an auth: when every
+// candidate failed recently they all stay eligible. Zero disables it.
+func (m *Manager) SetRecentFailureWindow(window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if window < 0 {
+		window = 0
+	}
+	m.recentFailureWindow = window
+}
+
+// SetRetryBudget limits every request to maxAttempts executions across all auths and
+// providers, and once deadline has passed since the request began stops starting new ones
+// and cancels the one waiting for its upstream. Streams and bodies already returned run on.
+// Zero disables either limit. When a request runs out of budget it fails with the latest
+// error that was not a 429, or the latest error when all were.
+func (m *Manager) SetRetryBudget(maxAttempts int, deadline time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if maxAttempts < 0 {
+		maxAttempts = 0
+	}
+	if deadline < 0 {
+		deadline = 0
+	}
+	m.retryMaxAttempts = maxAttempts
+	m.retryDeadline = deadline
+}
+
 // Register inserts a new auth entry into the manager.
 func (m *Manager) Register(ctx context.Context, auth *Auth) (*Auth, error) {
 	if auth == nil {
@@ -153,23 +279,33 @@ func (m *Manager) Register(ctx context.Context, auth *Auth) (*Auth, error) {
 	}
 	m.mu.Lock()
 	m.auths[auth.ID] = auth.Clone()
+	delete(m.refreshFailures, auth.ID)
 	m.mu.Unlock()
 	_ = m.persist(ctx, auth)
 	m.hook.OnAuthRegistered(ctx, auth.Clone())
 	return auth.Clone(), nil
 }
 
-// Update replaces an existing auth entry and notifies hooks.
+// Update replaces an existing auth entry and notifies hooks. The entry is replaced in memory
+// even when saving it to the store fails; that error is returned.
 func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
+	return m.update(ctx, auth, true)
+}
+
+func (m *Manager) update(ctx context.Context, auth *Auth, persist bool) (*Auth, error) {
 	if auth == nil || auth.ID == "" {
 		return nil, nil
 	}
 	m.mu.Lock()
 	m.auths[auth.ID] = auth.Clone()
+	delete(m.refreshFailures, auth.ID)
 	m.mu.Unlock()
-	_ = m.persist(ctx, auth)
+	var errPersist error
+	if persist {
+		errPersist = m.persist(ctx, auth)
+	}
 	m.hook.OnAuthUpdated(ctx, auth.Clone())
-	return auth.Clone(), nil
+	return auth.Clone(), errPersist
 }
 
 // Load resets manager state from the backing store.
@@ -203,13 +339,17 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
+	budget := m.newRetryBudget()
 	var lastErr error
 	for _, provider := range rotated {
-		resp, errExec := m.executeWithProvider(ctx, provider, req, opts)
+		resp, errExec := m.executeWithProvider(ctx, provider, req, opts, budget)
 		if errExec == nil {
 			return resp, nil
 		}
 		lastErr = errExec
+		if ctx.Err() != nil || !budget.allow() {
+			break
+		}
 	}
 	if lastErr != nil {
 		return cliproxyexecutor.Response{}, lastErr
@@ -227,13 +367,17 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
+	budget := m.newRetryBudget()
 	var lastErr error
 	for _, provider := range rotated {
-		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts)
+		resp, errExec := m.executeCountWithProvider(ctx, provider, req, opts, budget)
 		if errExec == nil {
 			return resp, nil
 		}
 		lastErr = errExec
+		if ctx.Err() != nil || !budget.allow() {
+			break
+		}
 	}
 	if lastErr != nil {
 		return cliproxyexecutor.Response{}, lastErr
@@ -251,13 +395,17 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
+	budget := m.newRetryBudget()
 	var lastErr error
 	for _, provider := range rotated {
-		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts)
+		chunks, errStream := m.executeStreamWithProvider(ctx, provider, req, opts, budget)
 		if errStream == nil {
 			return chunks, nil
 		}
 		lastErr = errStream
+		if ctx.Err() != nil || !budget.allow() {
+			break
+		}
 	}
 	if lastErr != nil {
 		return nil, lastErr
@@ -265,17 +413,39 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
-func (m *Manager) executeWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+// resultBody reports the outcome of reading a response body once: nil at its end, or the
+// error that interrupted it. A body closed early reports nothing, as the caller gave up.
+type resultBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func(err error)
+}
+
+func (b *resultBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		errRead := err
+		if errRead == io.EOF {
+			errRead = nil
+		}
+		b.once.Do(func() { b.done(errRead) })
+	}
+	return n, err
+}
+
+func (m *Manager) executeWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, budget *retryBudget) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
 	tried := make(map[string]struct{})
-	var lastErr error
 	for {
+		if !budget.allow() {
+			return cliproxyexecutor.Response{}, budget.exhausted()
+		}
 		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
 		if errPick != nil {
-			if lastErr != nil {
-				return cliproxyexecutor.Response{}, lastErr
+			if errBudget := budget.err(); errBudget != nil {
+				return cliproxyexecutor.Response{}, errBudget
 			}
 			return cliproxyexecutor.Response{}, errPick
 		}
@@ -291,39 +461,68 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		budget.spend()
+		execCtx, stopDeadline := budget.attemptContext(ctx)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		started := time.Now()
+		m.inflight.begin(auth.ID)
 		resp, errExec := executor.Execute(execCtx, auth, req, opts)
+		cut := stopDeadline()
+		m.inflight.end(auth.ID)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
+			if ctx.Err() != nil {
+				// The caller gave up; the error says nothing about the auth.
+				return cliproxyexecutor.Response{}, errExec
+			}
+			if cut {
+				// The retry deadline cancelled the attempt, which says nothing about the auth
+				// either.
+				result.Error = deadlineExceeded()
+				recordAttempt(ctx, result, started)
+				budget.fail(result.Error)
+				continue
 			}
+			result.Error = errorFromExecution(errExec)
 			m.MarkResult(execCtx, result)
-			lastErr = errExec
+			recordAttempt(ctx, result, started)
+			budget.fail(errExec)
 			continue
 		}
+		if resp.Body != nil {
+			// An unread body only succeeds once it has been read to the end.
+			resp.Body = &resultBody{ReadCloser: resp.Body, done: func(errRead error) {
+				if errRead != nil {
+					result.Success = false
+					result.Error = errorFromExecution(errRead)
+				}
+				m.MarkResult(execCtx, result)
+			}}
+			recordAttempt(ctx, result, started)
+			return resp, nil
+		}
 		m.MarkResult(execCtx, result)
+		recordAttempt(ctx, result, started)
 		return resp, nil
 	}
 }
 
-func (m *Manager) executeCountWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeCountWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, budget *retryBudget) (cliproxyexecutor.Response, error) {
 	if provider == "" {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
 	tried := make(map[string]struct{})
-	var lastErr error
 	for {
+		if !budget.allow() {
+			return cliproxyexecutor.Response{}, budget.exhausted()
+		}
 		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
 		if errPick != nil {
-			if lastErr != nil {
-				return cliproxyexecutor.Response{}, lastErr
+			if errBudget := budget.err(); errBudget != nil {
+				return cliproxyexecutor.Response{}, errBudget
 			}
 			return cliproxyexecutor.Response{}, errPick
 		}
@@ -338,39 +537,56 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		budget.spend()
+		execCtx, stopDeadline := budget.attemptContext(ctx)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		started := time.Now()
+		m.inflight.begin(auth.ID)
 		resp, errExec := executor.CountTokens(execCtx, auth, req, opts)
+		cut := stopDeadline()
+		m.inflight.end(auth.ID)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: errExec == nil}
 		if errExec != nil {
-			result.Error = &Error{Message: errExec.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errExec, &se) && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
+			if ctx.Err() != nil {
+				// The caller gave up; the error says nothing about the auth.
+				return cliproxyexecutor.Response{}, errExec
 			}
+			if cut {
+				// The retry deadline cancelled the attempt, which says nothing about the auth
+				// either.
+				result.Error = deadlineExceeded()
+				recordAttempt(ctx, result, started)
+				budget.fail(result.Error)
+				continue
+			}
+			result.Error = errorFromExecution(errExec)
 			m.MarkResult(execCtx, result)
-			lastErr = errExec
+			recordAttempt(ctx, result, started)
+			budget.fail(errExec)
 			continue
 		}
 		m.MarkResult(execCtx, result)
+		recordAttempt(ctx, result, started)
 		return resp, nil
 	}
 }
 
-func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, budget *retryBudget) (<-chan cliproxyexecutor.StreamChunk, error) {
 	if provider == "" {
 		return nil, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
 	tried := make(map[string]struct{})
-	var lastErr error
 	for {
+		if !budget.allow() {
+			return nil, budget.exhausted()
+		}
 		auth, executor, errPick := m.pickNext(ctx, provider, req.Model, opts, tried)
 		if errPick != nil {
-			if lastErr != nil {
-				return nil, lastErr
+			if errBudget := budget.err(); errBudget != nil {
+				return nil, errBudget
 			}
 			return nil, errPick
 		}
@@ -385,40 +601,49 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		}
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		budget.spend()
+		execCtx, stopDeadline := budget.attemptContext(ctx)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		started := time.Now()
+		m.inflight.begin(auth.ID)
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, req, opts)
+		cut := stopDeadline()
 		if errStream != nil {
-			rerr := &Error{Message: errStream.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errStream, &se) && se != nil {
-				rerr.HTTPStatus = se.StatusCode()
+			m.inflight.end(auth.ID)
+			if ctx.Err() != nil {
+				return nil, errStream
+			}
+			if cut {
+				// The retry deadline cancelled the attempt, which says nothing about the auth
+				// either.
+				result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Error: deadlineExceeded()}
+				recordAttempt(ctx, result, started)
+				budget.fail(result.Error)
+				continue
 			}
-			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: rerr}
+			result := Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: false, Error: errorFromExecution(errStream)}
 			m.MarkResult(execCtx, result)
-			lastErr = errStream
+			recordAttempt(ctx, result, started)
+			budget.fail(errStream)
 			continue
 		}
+		recordAttempt(ctx, Result{AuthID: auth.ID, Provider: provider, Model: req.Model, Success: true}, started)
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
+			defer m.inflight.end(streamAuth.ID)
 			var failed bool
 			for chunk := range streamChunks {
-				if chunk.Err != nil && !failed {
+				if chunk.Err != nil && !failed && streamCtx.Err() == nil {
 					failed = true
-					rerr := &Error{Message: chunk.Err.Error()}
-					var se cliproxyexecutor.StatusError
-					if errors.As(chunk.Err, &se) && se != nil {
-						rerr.HTTPStatus = se.StatusCode()
-					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: rerr})
+					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: false, Error: errorFromExecution(chunk.Err)})
 				}
 				out <- chunk
 			}
-			if !failed {
+			if !failed && streamCtx.Err() == nil {
 				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: req.Model, Success: true})
 			}
 		}(execCtx, auth.Clone(), provider, chunks)
@@ -486,6 +711,9 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	if result.AuthID == "" {
 		return
 	}
+	if ephemeral, ok := EphemeralAuth(ctx); ok && ephemeral.ID == result.AuthID {
+		return
+	}
 
 	shouldResumeModel := false
 	shouldSuspendModel := false
@@ -526,27 +754,24 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 					auth.StatusMessage = result.Error.Message
 				}
 
-				statusCode := statusCodeFromResult(result.Error)
-				switch statusCode {
-				case 401:
-					next := now.Add(30 * time.Minute)
-					state.NextRetryAfter = next
-					suspendReason = "unauthorized"
-					shouldSuspendModel = true
-				case 402, 403:
+				switch kindFromResult(result.Error) {
+				case cliproxyexecutor.ErrorKindAuth:
 					next := now.Add(30 * time.Minute)
 					state.NextRetryAfter = next
 					suspendReason = "payment_required"
+					if statusCodeFromResult(result.Error) == 401 {
+						suspendReason = "unauthorized"
+					}
 					shouldSuspendModel = true
-				case 429:
-					next := now.Add(30 * time.Minute)
+				case cliproxyexecutor.ErrorKindQuota:
+					next := now.Add(quotaCooldown(result.Error))
 					state.NextRetryAfter = next
 					state.Quota = QuotaState{Exceeded: true, Reason: "quota", NextRecoverAt: next}
 					suspendReason = "quota"
 					shouldSuspendModel = true
 					setModelQuota = true
-				case 408, 500, 502, 503, 504:
-					next := now.Add(1 * time.Minute)
+				case cliproxyexecutor.ErrorKindTransient:
+					next := now.Add(transientCooldown(result.Error))
 					state.NextRetryAfter = next
 				default:
 					state.NextRetryAfter = time.Time{}
@@ -558,6 +783,9 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			} else {
 				applyAuthFailureState(auth, result.Error, now)
 			}
+			if result.Error != nil && result.Error.EgressBlocked {
+				m.blockEgressLocked(auth, now.Add(quotaCooldown(result.Error)))
+			}
 		}
 
 		_ = m.persist(ctx, auth)
@@ -702,10 +930,13 @@ func cloneError(err *Error) *Error {
 		return nil
 	}
 	return &Error{
-		Code:       err.Code,
-		Message:    err.Message,
-		Retryable:  err.Retryable,
-		HTTPStatus: err.HTTPStatus,
+		Code:          err.Code,
+		Message:       err.Message,
+		Retryable:     err.Retryable,
+		HTTPStatus:    err.HTTPStatus,
+		Kind:          err.Kind,
+		RetryAfter:    err.RetryAfter,
+		EgressBlocked: err.EgressBlocked,
 	}
 }
 
@@ -729,23 +960,22 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, now time.Time) {
 			auth.StatusMessage = resultErr.Message
 		}
 	}
-	statusCode := statusCodeFromResult(resultErr)
-	switch statusCode {
-	case 401:
-		auth.StatusMessage = "unauthorized"
-		auth.NextRetryAfter = now.Add(30 * time.Minute)
-	case 402, 403:
+	switch kindFromResult(resultErr) {
+	case cliproxyexecutor.ErrorKindAuth:
 		auth.StatusMessage = "payment_required"
+		if statusCodeFromResult(resultErr) == 401 {
+			auth.StatusMessage = "unauthorized"
+		}
 		auth.NextRetryAfter = now.Add(30 * time.Minute)
-	case 429:
+	case cliproxyexecutor.ErrorKindQuota:
 		auth.StatusMessage = "quota exhausted"
 		auth.Quota.Exceeded = true
 		auth.Quota.Reason = "quota"
-		auth.Quota.NextRecoverAt = now.Add(30 * time.Minute)
+		auth.Quota.NextRecoverAt = now.Add(quotaCooldown(resultErr))
 		auth.NextRetryAfter = auth.Quota.NextRecoverAt
-	case 408, 500, 502, 503, 504:
+	case cliproxyexecutor.ErrorKindTransient:
 		auth.StatusMessage = "transient upstream error"
-		auth.NextRetryAfter = now.Add(1 * time.Minute)
+		auth.NextRetryAfter = now.Add(transientCooldown(resultErr))
 	default:
 		if auth.StatusMessage == "" {
 			auth.StatusMessage = "request failed"
@@ -786,20 +1016,44 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		m.mu.RUnlock()
 		return nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
-	candidates := make([]*Auth, 0, len(m.auths))
-	for _, auth := range m.auths {
-		if auth.Provider != provider || auth.Disabled {
-			continue
-		}
-		if _, used := tried[auth.ID]; used {
-			continue
-		}
-		candidates = append(candidates, auth.Clone())
-	}
 	m.mu.RUnlock()
+	if auth, ok, err := ephemeralFor(ctx, provider, tried); ok {
+		return auth, executor, err
+	}
+	candidates := m.candidatesFor(provider, tried, false)
 	if len(candidates) == 0 {
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
+	now := time.Now()
+	if available := m.withoutMaintenance(candidates, now); len(available) > 0 {
+		candidates = available
+	} else {
+		return nil, nil, unavailableUntil("all auths are in maintenance", candidates, now, func(auth *Auth) time.Time {
+			if until := m.maintenance.state(auth, now).Until; until != nil {
+				return *until
+			}
+			return time.Time{}
+		})
+	}
+	if available := m.withinActiveHours(ctx, candidates, now); len(available) > 0 {
+		candidates = available
+	} else {
+		return nil, nil, unavailableUntil("all auths are outside their active hours", candidates, now, func(auth *Auth) time.Time {
+			_, boundary := m.outsideActiveHours(auth, now)
+			return boundary
+		})
+	}
+	if available := m.withoutBlockedEgress(candidates, now); len(available) > 0 {
+		candidates = available
+	} else {
+		return nil, nil, unavailableUntil("all auths send through a blocked egress", candidates, now, func(auth *Auth) time.Time {
+			until, _ := m.egress.blockedUntil(auth, now)
+			return until
+		})
+	}
+	candidates = m.preferFresh(candidates, model, now)
+	candidates = m.preferAffine(candidates, model, now)
+	candidates = preferPriority(candidates, model, now)
 	auth, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
 	if errPick != nil {
 		return nil, nil, errPick
@@ -810,6 +1064,77 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 	return auth, executor, nil
 }
 
+// unavailableUntil returns the error for a request whose candidates a filter all turned
+// away: a retryable 503 advising to retry once the first of them is available again, as
+// next reports it, or without advice when none reports a time.
+func unavailableUntil(message string, candidates []*Auth, now time.Time, next func(*Auth) time.Time) *Error {
+	err := &Error{Code: "auth_unavailable", Message: message, Retryable: true, HTTPStatus: http.StatusServiceUnavailable, Kind: cliproxyexecutor.ErrorKindTransient}
+	var earliest time.Time
+	for _, candidate := range candidates {
+		if at := next(candidate); at.After(now) && (earliest.IsZero() || at.Before(earliest)) {
+			earliest = at
+		}
+	}
+	if !earliest.IsZero() {
+		err.RetryAfter = earliest.Sub(now)
+	}
+	return err
+}
+
+// preferFresh drops the candidates that failed for model within the recent failure window,
+// unless that would leave none that the selector could pick.
+func (m *Manager) preferFresh(candidates []*Auth, model string, now time.Time) []*Auth {
+	m.mu.RLock()
+	window := m.recentFailureWindow
+	m.mu.RUnlock()
+	if window <= 0 {
+		return candidates
+	}
+	fresh := make([]*Auth, 0, len(candidates))
+	for _, candidate := range candidates {
+		if failedSince(candidate, model, now.Add(-window)) || isAuthBlockedForModel(candidate, model, now) {
+			continue
+		}
+		fresh = append(fresh, candidate)
+	}
+	if len(fresh) == 0 {
+		return candidates
+	}
+	return fresh
+}
+
+// failedSince reports whether the last result of auth for model, or of the auth as a whole
+// when model is empty, was a failure recorded after since.
+func failedSince(auth *Auth, model string, since time.Time) bool {
+	if auth == nil {
+		return false
+	}
+	if model != "" {
+		state, ok := auth.ModelStates[model]
+		return ok && state != nil && state.Status == StatusError && state.UpdatedAt.After(since)
+	}
+	return auth.Status == StatusError && auth.UpdatedAt.After(since)
+}
+
+// candidatesFor returns clones of the auths registered for provider ordered by ID, skipping
+// already tried entries and, unless includeDisabled is set, disabled ones.
+func (m *Manager) candidatesFor(provider string, tried map[string]struct{}, includeDisabled bool) []*Auth {
+	m.mu.RLock()
+	candidates := make([]*Auth, 0, len(m.auths))
+	for _, auth := range m.auths {
+		if auth.Provider != provider || (auth.Disabled && !includeDisabled) {
+			continue
+		}
+		if _, used := tried[auth.ID]; used {
+			continue
+		}
+		candidates = append(candidates, auth.Clone())
+	}
+	m.mu.RUnlock()
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+	return candidates
+}
+
 func (m *Manager) persist(ctx context.Context, auth *Auth) error {
 	if m.store == nil || auth == nil {
 		return nil
@@ -912,7 +1237,7 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 
 	expiry, hasExpiry := a.ExpirationTime()
 
-	if interval := authPreferredInterval(a); interval > 0 {
+	if interval := m.refreshInterval(a); interval > 0 {
 		if hasExpiry && !expiry.IsZero() {
 			if !expiry.After(now) {
 				return true
@@ -947,6 +1272,20 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 	return true
 }
 
+// refreshInterval returns the interval set for the provider of a, or else the one stored
+// with a.
+func (m *Manager) refreshInterval(a *Auth) time.Duration {
+	m.mu.RLock()
+	interval := m.refreshIntervals[a.Provider]
+	m.mu.RUnlock()
+	if interval != nil {
+		if d := interval(a.ID); d > 0 {
+			return d
+		}
+	}
+	return authPreferredInterval(a)
+}
+
 func authPreferredInterval(a *Auth) time.Duration {
 	if a == nil {
 		return 0
@@ -1107,6 +1446,9 @@ func (m *Manager) markRefreshPending(id string, now time.Time) bool {
 	if !auth.NextRefreshAfter.IsZero() && now.Before(auth.NextRefreshAfter) {
 		return false
 	}
+	if limit := m.refreshLimits[auth.Provider]; limit > 0 && m.refreshFailures[id] >= limit {
+		return false
+	}
 	auth.NextRefreshAfter = now.Add(refreshPendingBackoff)
 	m.auths[id] = auth
 	return true
@@ -1127,14 +1469,15 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	updated, err := exec.Refresh(ctx, cloned)
 	log.Debugf("refreshed %s, %s, %v", auth.Provider, auth.ID, err)
 	now := time.Now()
+	m.mu.RLock()
+	current := m.auths[id]
+	m.mu.RUnlock()
+	if current == nil || current.Disabled {
+		// Removed or disabled while refreshing; do not bring it back.
+		return
+	}
 	if err != nil {
-		m.mu.Lock()
-		if current := m.auths[id]; current != nil {
-			current.NextRefreshAfter = now.Add(refreshFailureBackoff)
-			current.LastError = &Error{Message: err.Error()}
-			m.auths[id] = current
-		}
-		m.mu.Unlock()
+		m.refreshFailed(ctx, id, err, now)
 		return
 	}
 	if updated == nil {
@@ -1149,7 +1492,44 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	updated.NextRefreshAfter = time.Time{}
 	updated.LastError = nil
 	updated.UpdatedAt = now
-	_, _ = m.Update(ctx, updated)
+	// A refresh that left the metadata as it was has nothing new for the store.
+	_, _ = m.update(ctx, updated, !reflect.DeepEqual(updated.Metadata, auth.Metadata))
+}
+
+// refreshFailed records a failed background refresh. Credentials of providers set with
+// SetDisableOnRejectedRefresh that are rejected as unauthorized fatalRefreshAttempts times in
+// a row disable the auth; other failures back off and stop once the provider's retry limit is
+// reached.
+func (m *Manager) refreshFailed(ctx context.Context, id string, err error, now time.Time) {
+	kind := cliproxyexecutor.ErrorKindOf(err)
+	m.mu.Lock()
+	current := m.auths[id]
+	if current == nil || current.Disabled {
+		delete(m.refreshFailures, id)
+		m.mu.Unlock()
+		return
+	}
+	m.refreshFailures[id]++
+	failures := m.refreshFailures[id]
+	limit := m.refreshLimits[current.Provider]
+	current.NextRefreshAfter = now.Add(refreshFailureBackoff)
+	current.LastError = &Error{Message: err.Error(), Kind: kind}
+	if !m.rejectionDisables[current.Provider] || kind != cliproxyexecutor.ErrorKindAuth || failures < fatalRefreshAttempts {
+		m.mu.Unlock()
+		if limit > 0 && failures == limit {
+			log.Warnf("auth %s (%s): giving up background refresh after %d failures: %v", id, current.Provider, failures, err)
+		}
+		return
+	}
+	disabled := current.Clone()
+	m.mu.Unlock()
+
+	disabled.Disabled = true
+	disabled.Status = StatusDisabled
+	disabled.StatusMessage = fmt.Sprintf("credentials rejected %d times (%v); re-import the auth file to enable it again", failures, err)
+	disabled.UpdatedAt = now
+	log.Warnf("auth %s (%s) disabled: %s", id, disabled.Provider, disabled.StatusMessage)
+	_, _ = m.Update(ctx, disabled)
 }
 
 func (m *Manager) executorFor(provider string) ProviderExecutor {
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// authKindError is a refresh failure classified as rejected credentials.
+type authKindError struct{}
+
+func (authKindError) Error() string   { return "401 unauthorized" }
+func (authKindError) StatusCode() int { return 401 }
+
+func TestRefreshFailedDisablesOnlyOptedInProviders(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetDisableOnRejectedRefresh("gemini-web", true)
+	for _, auth := range []*Auth{
+		{ID: "web", Provider: "gemini-web", Status: StatusActive},
+		{ID: "claude", Provider: "claude", Status: StatusActive},
+	} {
+		if _, err := m.Register(context.Background(), auth); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	for i := 0; i < fatalRefreshAttempts; i++ {
+		m.refreshFailed(context.Background(), "web", authKindError{}, now)
+		m.refreshFailed(context.Background(), "claude", authKindError{}, now)
+	}
+
+	web, _ := m.GetByID("web")
+	if !web.Disabled || web.Status != StatusDisabled {
+		t.Fatalf("gemini-web auth not disabled after %d rejected refreshes: %+v", fatalRefreshAttempts, web)
+	}
+	claude, _ := m.GetByID("claude")
+	if claude.Disabled {
+		t.Fatal("claude auth disabled by rejected refreshes")
+	}
+	if claude.LastError == nil || claude.NextRefreshAfter.IsZero() {
+		t.Fatal("claude refresh failure not recorded with a backoff")
+	}
+}
+
+func TestRefreshFailedKeepsTransientFailuresEnabled(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetDisableOnRejectedRefresh("gemini-web", true)
+	if _, err := m.Register(context.Background(), &Auth{ID: "web", Provider: "gemini-web", Status: StatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < fatalRefreshAttempts*2; i++ {
+		m.refreshFailed(context.Background(), "web", errors.New("connection reset"), time.Now())
+	}
+	if web, _ := m.GetByID("web"); web.Disabled {
+		t.Fatal("gemini-web auth disabled by network failures")
+	}
+}
+
+// bodyExecutor answers Execute with a body that returns data and then err.
+type bodyExecutor struct {
+	data string
+	err  error
+}
+
+func (e bodyExecutor) Identifier() string { return "gemini" }
+
+func (e bodyExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Body: io.NopCloser(io.MultiReader(strings.NewReader(e.data), errReader{e.err}))}, nil
+}
+
+func (e bodyExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, errors.New("not supported")
+}
+
+func (e bodyExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e bodyExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestExecuteMarksBodyResultOnceRead(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		err     error
+		success bool
+	}{
+		{name: "complete", err: io.EOF, success: true},
+		{name: "interrupted", err: errors.New("connection reset"), success: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(nil, nil, nil)
+			m.RegisterExecutor(bodyExecutor{data: `{"candidates":[]}`, err: tt.err})
+			if _, err := m.Register(context.Background(), &Auth{ID: "g", Provider: "gemini", Status: StatusActive}); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := m.Execute(context.Background(), []string{"gemini"}, cliproxyexecutor.Request{Model: "gemini-2.5-pro"}, cliproxyexecutor.Options{PreferBody: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if auth, _ := m.GetByID("g"); auth.LastError != nil || len(auth.ModelStates) != 0 {
+				t.Fatal("result recorded before the body was read")
+			}
+			_, errRead := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if (errRead == nil) != tt.success {
+				t.Fatalf("read error = %v", errRead)
+			}
+			auth, _ := m.GetByID("g")
+			if tt.success && auth.LastError != nil {
+				t.Fatalf("complete body recorded as failure: %v", auth.LastError)
+			}
+			if !tt.success && auth.LastError == nil {
+				t.Fatal("interrupted body not recorded as failure")
+			}
+		})
+	}
+}
+
+func TestExecuteDuringMaintenanceIsUnavailableWithRetryAfter(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(bodyExecutor{data: `{}`, err: io.EOF})
+	for _, id := range []string{"a", "b"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "gemini", Status: StatusActive}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.StartMaintenance("a", time.Now().Add(10*time.Minute))
+	m.StartMaintenance("b", time.Now().Add(90*time.Second))
+
+	_, err := m.Execute(context.Background(), []string{"gemini"}, cliproxyexecutor.Request{Model: "gemini-2.5-pro"}, cliproxyexecutor.Options{})
+	var authErr *Error
+	if !errors.As(err, &authErr) {
+		t.Fatalf("error = %v, want an *Error", err)
+	}
+	if authErr.StatusCode() != 503 || !authErr.Retryable {
+		t.Fatalf("status = %d, retryable = %v; want a retryable 503", authErr.StatusCode(), authErr.Retryable)
+	}
+	if authErr.RetryAfter <= 80*time.Second || authErr.RetryAfter > 90*time.Second {
+		t.Fatalf("retry after = %v, want the end of the shortest maintenance", authErr.RetryAfter)
+	}
+}
+
+func TestSetRefreshIntervalAppliesBeforeTheFirstRefresh(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	now := time.Now()
+	auth := &Auth{ID: "a.json", Provider: "gemini-web", LastRefreshedAt: now.Add(-10 * time.Minute)}
+	if m.shouldRefresh(auth, now) {
+		t.Fatal("refresh due without an interval")
+	}
+	m.SetRefreshInterval("gemini-web", func(string) time.Duration { return 9 * time.Minute })
+	if !m.shouldRefresh(auth, now) {
+		t.Fatal("refresh not due 10 minutes into a 9 minute interval")
+	}
+	m.SetRefreshInterval("gemini-web", func(string) time.Duration { return time.Hour })
+	if m.shouldRefresh(auth, now) {
+		t.Fatal("refresh due 10 minutes into an hour interval")
+	}
+}
+
+func TestMarkResultCoolsDownForTheAdvisedWait(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	for _, id := range []string{"quota", "transient", "default"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "claude", Status: StatusActive}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	started := time.Now()
+	m.MarkResult(context.Background(), Result{AuthID: "quota", Provider: "claude", Model: "claude-test",
+		Error: &Error{HTTPStatus: 429, RetryAfter: 2 * time.Minute}})
+	m.MarkResult(context.Background(), Result{AuthID: "transient", Provider: "claude", Model: "claude-test",
+		Error: &Error{HTTPStatus: 503, RetryAfter: 10 * time.Minute}})
+	m.MarkResult(context.Background(), Result{AuthID: "default", Provider: "claude", Model: "claude-test",
+		Error: &Error{HTTPStatus: 503}})
+
+	for id, want := range map[string]time.Duration{
+		"quota":     2 * time.Minute,
+		"transient": 10 * time.Minute,
+		"default":   defaultTransientCooldown,
+	} {
+		auth, _ := m.GetByID(id)
+		wait := auth.ModelStates["claude-test"].NextRetryAfter.Sub(started)
+		if wait < want || wait > want+time.Minute/2 {
+			t.Errorf("%s: cooled down for %s, want %s", id, wait, want)
+		}
+	}
+}
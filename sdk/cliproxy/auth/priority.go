@@ -0,0 +1,32 @@
+package auth
+
+import "time"
+
+// BlockReasonLowerPriority is reported for auths passed over because an auth of a higher
+// priority is available.
+const BlockReasonLowerPriority = "lower_priority"
+
+// preferPriority narrows candidates to the highest priority tier that has an auth able to
+// serve model at now, so the selector balances load within that tier only. Lower tiers take
+// over once every auth above them is cooling down or out of quota.
+func preferPriority(candidates []*Auth, model string, now time.Time) []*Auth {
+	top, found := 0, false
+	for _, candidate := range candidates {
+		if isAuthBlockedForModel(candidate, model, now) {
+			continue
+		}
+		if !found || candidate.Priority > top {
+			top, found = candidate.Priority, true
+		}
+	}
+	if !found {
+		return candidates
+	}
+	tier := make([]*Auth, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Priority == top {
+			tier = append(tier, candidate)
+		}
+	}
+	return tier
+}
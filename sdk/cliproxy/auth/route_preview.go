@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// Reasons reported when an auth is excluded from selection.
+const (
+	BlockReasonMissing          = "missing"
+	BlockReasonDisabled         = "disabled"
+	BlockReasonModelDisabled    = "model_disabled"
+	BlockReasonCooldown         = "cooldown"
+	BlockReasonQuotaExceeded    = "quota_exceeded"
+	BlockReasonNoExecutor       = "executor_not_registered"
+	BlockReasonNotSelectedFirst = "provider_not_reached"
+	BlockReasonRecentFailure    = "recently_failed"
+)
+
+// SelectorPreviewer is implemented by selectors that can report their next pick without
+// mutating internal rotation state.
+type SelectorPreviewer interface {
+	Preview(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error)
+}
+
+// RouteCandidate describes one auth considered while routing a request.
+type RouteCandidate struct {
+	AuthID   string `json:"auth_id"`
+	Provider string `json:"provider"`
+	Label    string `json:"label,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	// Included reports whether the selector may pick this auth.
+	Included bool `json:"included"`
+	// Reason explains why the auth was excluded.
+	Reason string `json:"reason,omitempty"`
+	// Until is when the exclusion lifts, if known.
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// RouteDecision is the trace produced by a dry-run of provider and auth selection.
+type RouteDecision struct {
+	Model string `json:"model"`
+	// Providers lists providers in the order they would be attempted.
+	Providers []string `json:"providers"`
+	// Candidates lists every auth considered, grouped by provider in attempt order.
+	Candidates []RouteCandidate `json:"candidates"`
+	// Selected is the auth that would serve the request, nil when none is available.
+	Selected *RouteCandidate `json:"selected,omitempty"`
+	// Error describes why no auth could be selected.
+	Error string `json:"error,omitempty"`
+}
+
+// PreviewRoute runs provider rotation and auth selection for model without dispatching the
+// request or advancing any rotation cursor. Like a real request it reads the ephemeral auth
+// and the schedule override from ctx.
+func (m *Manager) PreviewRoute(ctx context.Context, providers []string, model string, opts cliproxyexecutor.Options) *RouteDecision {
+	decision := &RouteDecision{Model: model, Candidates: []RouteCandidate{}}
+	normalized := m.normalizeProviders(providers)
+	if len(normalized) == 0 {
+		decision.Error = "no provider supplied"
+		return decision
+	}
+	decision.Providers = m.rotateProviders(model, normalized)
+	previewer, _ := m.selector.(SelectorPreviewer)
+	now := time.Now()
+
+	for _, provider := range decision.Providers {
+		m.mu.RLock()
+		_, okExecutor := m.executors[provider]
+		m.mu.RUnlock()
+		candidates := m.candidatesFor(provider, nil, true)
+		if !okExecutor {
+			for _, candidate := range candidates {
+				decision.Candidates = append(decision.Candidates, newRouteCandidate(candidate, BlockReasonNoExecutor, time.Time{}))
+			}
+			continue
+		}
+		if auth, ok, _ := ephemeralFor(ctx, provider, nil); ok {
+			for _, candidate := range candidates {
+				decision.Candidates = append(decision.Candidates, newRouteCandidate(candidate, BlockReasonClientCredential, time.Time{}))
+			}
+			reason := ""
+			if decision.Selected != nil {
+				reason = BlockReasonNotSelectedFirst
+			}
+			decision.Candidates = append(decision.Candidates, newRouteCandidate(auth, reason, time.Time{}))
+			if decision.Selected == nil {
+				selected := newRouteCandidate(auth, "", time.Time{})
+				decision.Selected = &selected
+			}
+			continue
+		}
+		eligible := make([]*Auth, 0, len(candidates))
+		for _, candidate := range candidates {
+			reason, until := m.selectionBlock(ctx, candidate, model, now)
+			if reason == "" {
+				if decision.Selected != nil {
+					reason = BlockReasonNotSelectedFirst
+				} else {
+					eligible = append(eligible, candidate)
+				}
+			}
+			decision.Candidates = append(decision.Candidates, newRouteCandidate(candidate, reason, until))
+		}
+		if decision.Selected != nil || len(eligible) == 0 {
+			continue
+		}
+		eligible = decision.narrow(provider, eligible, m.preferFresh(eligible, model, now), BlockReasonRecentFailure)
+		eligible = decision.narrow(provider, eligible, m.preferAffine(eligible, model, now), BlockReasonAffinitySpare)
+		eligible = decision.narrow(provider, eligible, preferPriority(eligible, model, now), BlockReasonLowerPriority)
+		var picked *Auth
+		if previewer != nil {
+			picked, _ = previewer.Preview(ctx, provider, model, opts, eligible)
+		} else {
+			picked = eligible[0]
+		}
+		if picked != nil {
+			selected := newRouteCandidate(picked, "", time.Time{})
+			decision.Selected = &selected
+		}
+	}
+	if decision.Selected == nil {
+		decision.Error = "no auth available"
+	}
+	return decision
+}
+
+// selectionBlock extends authBlockReason with the exclusions the manager applies itself:
+// maintenance, active hours unless ctx overrides the schedule, and egress blocks.
+func (m *Manager) selectionBlock(ctx context.Context, auth *Auth, model string, now time.Time) (string, time.Time) {
+	if reason, until := authBlockReason(auth, model, now); reason != "" {
+		return reason, until
+	}
+	if state := m.maintenance.state(auth, now); state.Active {
+		if state.Until != nil {
+			return BlockReasonMaintenance, *state.Until
+		}
+		return BlockReasonMaintenance, time.Time{}
+	}
+	if outside, next := m.outsideActiveHours(auth, now); outside && !scheduleOverridden(ctx) {
+		return BlockReasonQuietHours, next
+	}
+	if blockedUntil, blocked := m.egress.blockedUntil(auth, now); blocked {
+		return BlockReasonEgressBlocked, blockedUntil
+	}
+	return "", time.Time{}
+}
+
+// AvailableAuths returns the number of auths of each provider that could serve a request
+// now: enabled, not cooling down and not held back by maintenance, active hours or an
+// egress block. Providers without an available auth are omitted.
+func (m *Manager) AvailableAuths() map[string]int {
+	now := time.Now()
+	counts := make(map[string]int)
+	for _, auth := range m.List() {
+		if reason, _ := m.selectionBlock(context.Background(), auth, "", now); reason == "" {
+			counts[auth.Provider]++
+		}
+	}
+	return counts
+}
+
+// narrow excludes the candidates of provider that are in eligible but not in kept with
+// reason, and returns kept.
+func (d *RouteDecision) narrow(provider string, eligible, kept []*Auth, reason string) []*Auth {
+	if len(kept) == len(eligible) {
+		return kept
+	}
+	keep := make(map[string]struct{}, len(kept))
+	for _, auth := range kept {
+		keep[auth.ID] = struct{}{}
+	}
+	for i := range d.Candidates {
+		candidate := &d.Candidates[i]
+		if _, ok := keep[candidate.AuthID]; !ok && candidate.Included && candidate.Provider == provider {
+			candidate.Included = false
+			candidate.Reason = reason
+		}
+	}
+	return kept
+}
+
+func newRouteCandidate(auth *Auth, reason string, until time.Time) RouteCandidate {
+	candidate := RouteCandidate{
+		AuthID:   auth.ID,
+		Provider: auth.Provider,
+		Label:    auth.Label,
+		Priority: auth.Priority,
+		Included: reason == "",
+		Reason:   reason,
+	}
+	if !until.IsZero() {
+		candidate.Until = &until
+	}
+	return candidate
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestAvailableAuthsSkipsBlockedAccounts(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	for _, auth := range []*Auth{
+		{ID: "ready", Provider: "claude", Status: StatusActive},
+		{ID: "disabled", Provider: "claude", Status: StatusDisabled, Disabled: true},
+		{ID: "cooling", Provider: "claude", Status: StatusError, Unavailable: true, NextRetryAfter: time.Now().Add(time.Hour)},
+		{ID: "maintained", Provider: "claude", Status: StatusActive},
+		{ID: "web", Provider: "gemini-web", Status: StatusActive},
+	} {
+		if _, err := m.Register(context.Background(), auth); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.StartMaintenance("maintained", time.Now().Add(time.Hour))
+	m.StartMaintenance("web", time.Now().Add(time.Hour))
+
+	available := m.AvailableAuths()
+	if available["claude"] != 1 {
+		t.Errorf("claude available = %d, want 1", available["claude"])
+	}
+	if _, ok := available["gemini-web"]; ok {
+		t.Errorf("gemini-web listed with every account in maintenance: %v", available)
+	}
+}
+
+// newPreviewManager registers a claude executor and auths for a route preview.
+func newPreviewManager(t *testing.T, auths ...*Auth) *Manager {
+	t.Helper()
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(hangingExecutor{})
+	for _, auth := range auths {
+		if _, err := m.Register(context.Background(), auth); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func candidateReasons(decision *RouteDecision) map[string]string {
+	reasons := make(map[string]string, len(decision.Candidates))
+	for _, candidate := range decision.Candidates {
+		reasons[candidate.AuthID] = candidate.Reason
+	}
+	return reasons
+}
+
+func TestPreviewRouteExplainsACoolingAccount(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	m := newPreviewManager(t,
+		&Auth{ID: "a-cooling", Provider: "claude", Status: StatusActive, ModelStates: map[string]*ModelState{
+			"claude-test": {Status: StatusError, Unavailable: true, NextRetryAfter: until},
+		}},
+		&Auth{ID: "b-healthy", Provider: "claude", Status: StatusActive},
+	)
+
+	decision := m.PreviewRoute(context.Background(), []string{"claude"}, "claude-test", cliproxyexecutor.Options{})
+	if decision.Selected == nil || decision.Selected.AuthID != "b-healthy" {
+		t.Fatalf("selected = %+v, want b-healthy", decision.Selected)
+	}
+	cooling := decision.Candidates[0]
+	if cooling.AuthID != "a-cooling" || cooling.Included || cooling.Reason != BlockReasonCooldown || cooling.Until == nil {
+		t.Fatalf("cooling candidate = %+v, want it excluded for cooldown with its end", cooling)
+	}
+}
+
+func TestPreviewRouteFollowsTheRequestContext(t *testing.T) {
+	now := time.Now().UTC()
+	clock := func(at time.Time) time.Duration {
+		h, m, s := at.Clock()
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	}
+	m := newPreviewManager(t, &Auth{ID: "night", Provider: "claude", Status: StatusActive})
+	m.SetActiveHours("claude", &ActiveHours{Start: clock(now.Add(2 * time.Hour)), End: clock(now.Add(3 * time.Hour)), Location: time.UTC})
+
+	decision := m.PreviewRoute(context.Background(), []string{"claude"}, "claude-test", cliproxyexecutor.Options{})
+	if decision.Selected != nil || candidateReasons(decision)["night"] != BlockReasonQuietHours {
+		t.Fatalf("decision = %+v, want the account outside its hours excluded", decision)
+	}
+
+	decision = m.PreviewRoute(WithScheduleOverride(context.Background()), []string{"claude"}, "claude-test", cliproxyexecutor.Options{})
+	if decision.Selected == nil || decision.Selected.AuthID != "night" {
+		t.Fatalf("selected = %+v with the schedule overridden, want night", decision.Selected)
+	}
+
+	ctx := WithEphemeralAuth(context.Background(), &Auth{ID: "request:claude", Provider: "claude", Status: StatusActive})
+	decision = m.PreviewRoute(ctx, []string{"claude"}, "claude-test", cliproxyexecutor.Options{})
+	if decision.Selected == nil || decision.Selected.AuthID != "request:claude" {
+		t.Fatalf("selected = %+v, want the credential sent with the request", decision.Selected)
+	}
+	if reason := candidateReasons(decision)["night"]; reason != BlockReasonClientCredential {
+		t.Fatalf("stored account reason = %q, want %q", reason, BlockReasonClientCredential)
+	}
+}
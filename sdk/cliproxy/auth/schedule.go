@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlockReasonQuietHours is reported for auths outside their active hours.
+const BlockReasonQuietHours = "quiet_hours"
+
+// scheduleCoverageStep is the resolution at which ScheduleGap looks for uncovered times.
+const scheduleCoverageStep = 15 * time.Minute
+
+// ActiveHours is the daily period during which an auth may be selected. An End not after
+// Start runs into the next day, so "22:00-06:00" covers the night and equal ends the whole day.
+type ActiveHours struct {
+	Spec     string
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// ScheduleState describes the active hours of one auth.
+type ScheduleState struct {
+	ActiveHours string `json:"active_hours"`
+	Timezone    string `json:"timezone"`
+	// Source is "auth" for hours from the auth file and "config" for provider defaults.
+	Source string `json:"source"`
+	Active bool   `json:"active"`
+	// NextChange is when the auth next enters or leaves its active hours.
+	NextChange *time.Time `json:"next_change,omitempty"`
+}
+
+// ParseActiveHours builds active hours from spec, given as "HH:MM-HH:MM", and timezone as an
+// IANA name (UTC if empty).
+func ParseActiveHours(spec, timezone string) (ActiveHours, error) {
+	hours := ActiveHours{Spec: strings.TrimSpace(spec), Location: time.UTC}
+	start, end, ok := strings.Cut(hours.Spec, "-")
+	if !ok {
+		return hours, fmt.Errorf("active hours %q are not HH:MM-HH:MM", spec)
+	}
+	var err error
+	if hours.Start, err = parseClock(start); err != nil {
+		return hours, fmt.Errorf("active hours start: %w", err)
+	}
+	if hours.End, err = parseClock(end); err != nil {
+		return hours, fmt.Errorf("active hours end: %w", err)
+	}
+	if tz := strings.TrimSpace(timezone); tz != "" {
+		if hours.Location, err = time.LoadLocation(tz); err != nil {
+			return hours, fmt.Errorf("active hours timezone: %w", err)
+		}
+	}
+	return hours, nil
+}
+
+// at reports whether now falls inside the active hours and when that next changes.
+func (a ActiveHours) at(now time.Time) (active bool, boundary time.Time) {
+	return MaintenanceWindow{Start: a.Start, End: a.End, Location: a.Location}.at(now)
+}
+
+type scheduleOverrideKey struct{}
+
+// WithScheduleOverride returns a context under which auths are selected regardless of their
+// active hours.
+func WithScheduleOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scheduleOverrideKey{}, true)
+}
+
+func scheduleOverridden(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	overridden, _ := ctx.Value(scheduleOverrideKey{}).(bool)
+	return overridden
+}
+
+// activeHoursSchedule holds provider default active hours and the parsed hours of auth files.
+type activeHoursSchedule struct {
+	mu       sync.Mutex
+	defaults map[string]ActiveHours
+	// parsed caches the hours read from auth metadata by their spec and timezone.
+	parsed map[string]*ActiveHours
+}
+
+// SetActiveHours sets the active hours applying to auths of provider that set none in their
+// auth file. A nil hours removes the default.
+func (m *Manager) SetActiveHours(provider string, hours *ActiveHours) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	m.schedule.mu.Lock()
+	defer m.schedule.mu.Unlock()
+	if hours == nil {
+		delete(m.schedule.defaults, provider)
+		return
+	}
+	if m.schedule.defaults == nil {
+		m.schedule.defaults = make(map[string]ActiveHours)
+	}
+	m.schedule.defaults[provider] = *hours
+}
+
+// activeHoursFor returns the active hours of auth: the active_hours and timezone metadata of
+// its auth file, else the default of its provider.
+func (s *activeHoursSchedule) activeHoursFor(auth *Auth) (ActiveHours, string, bool) {
+	if auth == nil {
+		return ActiveHours{}, "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if spec, _ := auth.Metadata["active_hours"].(string); strings.TrimSpace(spec) != "" {
+		timezone, _ := auth.Metadata["timezone"].(string)
+		key := spec + "|" + timezone
+		hours, ok := s.parsed[key]
+		if !ok {
+			parsed, err := ParseActiveHours(spec, timezone)
+			if err == nil {
+				hours = &parsed
+			}
+			if s.parsed == nil {
+				s.parsed = make(map[string]*ActiveHours)
+			}
+			s.parsed[key] = hours
+		}
+		if hours != nil {
+			return *hours, "auth", true
+		}
+	}
+	hours, ok := s.defaults[strings.ToLower(auth.Provider)]
+	return hours, "config", ok
+}
+
+// Schedule returns the active hours state of auth, or false when it has none.
+func (m *Manager) Schedule(auth *Auth) (ScheduleState, bool) {
+	hours, source, ok := m.schedule.activeHoursFor(auth)
+	if !ok {
+		return ScheduleState{}, false
+	}
+	active, boundary := hours.at(time.Now())
+	state := ScheduleState{ActiveHours: hours.Spec, Timezone: hours.Location.String(), Source: source, Active: active}
+	if !boundary.IsZero() {
+		state.NextChange = &boundary
+	}
+	return state, true
+}
+
+// outsideActiveHours reports whether auth has active hours and now is outside them, along
+// with when they start again.
+func (m *Manager) outsideActiveHours(auth *Auth, now time.Time) (bool, time.Time) {
+	hours, _, ok := m.schedule.activeHoursFor(auth)
+	if !ok {
+		return false, time.Time{}
+	}
+	active, boundary := hours.at(now)
+	return !active, boundary
+}
+
+// withinActiveHours drops the candidates outside their active hours unless ctx overrides
+// the schedule.
+func (m *Manager) withinActiveHours(ctx context.Context, candidates []*Auth, now time.Time) []*Auth {
+	if scheduleOverridden(ctx) {
+		return candidates
+	}
+	available := candidates[:0:0]
+	for _, candidate := range candidates {
+		if outside, _ := m.outsideActiveHours(candidate, now); outside {
+			continue
+		}
+		available = append(available, candidate)
+	}
+	return available
+}
+
+// ScheduleGap reports the first time within the week after now at which no enabled auth of
+// provider is inside its active hours. Auths without active hours are always available, so
+// any of them closes every gap.
+func (m *Manager) ScheduleGap(provider string, now time.Time) (time.Time, bool) {
+	candidates := m.candidatesFor(strings.ToLower(strings.TrimSpace(provider)), nil, false)
+	if len(candidates) == 0 {
+		return time.Time{}, false
+	}
+	schedules := make([]ActiveHours, 0, len(candidates))
+	for _, candidate := range candidates {
+		hours, _, ok := m.schedule.activeHoursFor(candidate)
+		if !ok {
+			return time.Time{}, false
+		}
+		schedules = append(schedules, hours)
+	}
+	start := now.Truncate(scheduleCoverageStep)
+	for t := start; t.Before(start.Add(7 * 24 * time.Hour)); t = t.Add(scheduleCoverageStep) {
+		covered := false
+		for _, hours := range schedules {
+			if active, _ := hours.at(t); active {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -18,12 +18,20 @@ type RoundRobinSelector struct {
 func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
 	_ = ctx
 	_ = opts
+	return s.pick(provider, model, auths, true)
+}
+
+// Preview reports the auth Pick would return next without advancing the cursor.
+func (s *RoundRobinSelector) Preview(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	return s.pick(provider, model, auths, false)
+}
+
+func (s *RoundRobinSelector) pick(provider, model string, auths []*Auth, advance bool) (*Auth, error) {
 	if len(auths) == 0 {
 		return nil, &Error{Code: "auth_not_found", Message: "no auth candidates"}
 	}
-	if s.cursors == nil {
-		s.cursors = make(map[string]int)
-	}
 	available := make([]*Auth, 0, len(auths))
 	now := time.Now()
 	for i := 0; i < len(auths); i++ {
@@ -38,24 +46,36 @@ func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, o
 	}
 	key := provider + ":" + model
 	s.mu.Lock()
+	if s.cursors == nil {
+		s.cursors = make(map[string]int)
+	}
 	index := s.cursors[key]
 
 	if index >= 2_147_483_640 {
 		index = 0
 	}
 
-	s.cursors[key] = index + 1
+	if advance {
+		s.cursors[key] = index + 1
+	}
 	s.mu.Unlock()
 	// log.Debugf("available: %d, index: %d, key: %d", len(available), index, index%len(available))
 	return available[index%len(available)], nil
 }
 
 func isAuthBlockedForModel(auth *Auth, model string, now time.Time) bool {
+	reason, _ := authBlockReason(auth, model, now)
+	return reason != ""
+}
+
+// authBlockReason explains why an auth cannot serve model right now. It returns an empty
+// reason when the auth is eligible, and the time the block lifts when one is known.
+func authBlockReason(auth *Auth, model string, now time.Time) (string, time.Time) {
 	if auth == nil {
-		return true
+		return BlockReasonMissing, time.Time{}
 	}
 	if auth.Disabled || auth.Status == StatusDisabled {
-		return true
+		return BlockReasonDisabled, time.Time{}
 	}
 	// If a specific model is requested, prefer its per-model state over any aggregated
 	// auth-level unavailable flag. This prevents a failure on one model (e.g., 429 quota)
@@ -64,27 +84,33 @@ func isAuthBlockedForModel(auth *Auth, model string, now time.Time) bool {
 		if len(auth.ModelStates) > 0 {
 			if state, ok := auth.ModelStates[model]; ok && state != nil {
 				if state.Status == StatusDisabled {
-					return true
+					return BlockReasonModelDisabled, time.Time{}
 				}
 				if state.Unavailable {
 					if state.NextRetryAfter.IsZero() {
-						return false
+						return "", time.Time{}
 					}
 					if state.NextRetryAfter.After(now) {
-						return true
+						if state.Quota.Exceeded {
+							return BlockReasonQuotaExceeded, state.NextRetryAfter
+						}
+						return BlockReasonCooldown, state.NextRetryAfter
 					}
 				}
 				// Explicit state exists and is not blocking.
-				return false
+				return "", time.Time{}
 			}
 		}
 		// No explicit state for this model; do not block based on aggregated
 		// auth-level unavailable status. Allow trying this model.
-		return false
+		return "", time.Time{}
 	}
 	// No specific model context: fall back to auth-level unavailable window.
 	if auth.Unavailable && auth.NextRetryAfter.After(now) {
-		return true
+		if auth.Quota.Exceeded {
+			return BlockReasonQuotaExceeded, auth.NextRetryAfter
+		}
+		return BlockReasonCooldown, auth.NextRetryAfter
 	}
-	return false
+	return "", time.Time{}
 }
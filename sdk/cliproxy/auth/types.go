@@ -2,10 +2,14 @@ package auth
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 // Auth encapsulates the runtime state and metadata associated with a single credential.
@@ -14,8 +18,11 @@ type Auth struct {
 	ID string `json:"id"`
 	// Provider is the upstream provider key (e.g. "gemini", "claude").
 	Provider string `json:"provider"`
-	// Label is an optional human readable label for logging.
+	// Label is an optional human readable label for logging, such as the account tier.
 	Label string `json:"label,omitempty"`
+	// Priority ranks the auth among those of its provider: while an auth of a higher
+	// priority is available, lower ones are not selected. Defaults to zero.
+	Priority int `json:"priority,omitempty"`
 	// Status is the lifecycle status managed by the AuthManager.
 	Status Status `json:"status"`
 	// StatusMessage holds a short description for the current status.
@@ -124,10 +131,114 @@ func (m *ModelState) Clone() *ModelState {
 	return &copyState
 }
 
+// MetadataDisabledKey is the metadata field keeping an auth disabled across restarts. It is
+// written to the auth file when an operator disables the auth.
+const MetadataDisabledKey = "disabled"
+
+// ApplyMetadataDisabled marks the auth disabled when its metadata carries the disabled flag.
+// Loaders call it so disabled auth files stay out of rotation after a restart.
+func (a *Auth) ApplyMetadataDisabled() {
+	if a == nil || a.Metadata == nil {
+		return
+	}
+	if disabled, _ := a.Metadata[MetadataDisabledKey].(bool); disabled {
+		a.Disabled = true
+		a.Status = StatusDisabled
+		a.StatusMessage = "disabled via management API"
+	}
+}
+
+// Metadata fields keeping the label and priority an operator set on an auth file.
+const (
+	MetadataLabelKey    = "label"
+	MetadataPriorityKey = "priority"
+)
+
+// ApplyMetadataRouting takes the label and priority saved in the auth metadata, if any.
+// Loaders call it so both survive a restart.
+func (a *Auth) ApplyMetadataRouting() {
+	if a == nil || a.Metadata == nil {
+		return
+	}
+	if label, ok := a.Metadata[MetadataLabelKey].(string); ok && strings.TrimSpace(label) != "" {
+		a.Label = strings.TrimSpace(label)
+	}
+	switch priority := a.Metadata[MetadataPriorityKey].(type) {
+	case float64:
+		a.Priority = int(priority)
+	case int:
+		a.Priority = priority
+	case json.Number:
+		if n, err := priority.Int64(); err == nil {
+			a.Priority = int(n)
+		}
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(priority)); err == nil {
+			a.Priority = n
+		}
+	}
+}
+
+// AssignedLabel returns the label an operator set on the auth file, or "" when none was
+// set. Unlike Label it never falls back to the account email, so it is safe to show to
+// clients.
+func (a *Auth) AssignedLabel() string {
+	if a == nil || a.Metadata == nil {
+		return ""
+	}
+	label, _ := a.Metadata[MetadataLabelKey].(string)
+	return strings.TrimSpace(label)
+}
+
+// MetadataEndpointKey holds the Code Assist endpoint a Gemini CLI auth file overrides the
+// default with.
+const MetadataEndpointKey = "endpoint"
+
+// ValidateEndpoint checks an endpoint override and returns it without a trailing slash. It
+// must be an https URL without query or fragment, since the account's OAuth token is sent to
+// it; plain http is accepted only for loopback hosts, such as a local test server.
+func ValidateEndpoint(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Host == "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("invalid endpoint %q: expected an https URL without query", raw)
+	}
+	switch parsed.Scheme {
+	case "https":
+	case "http":
+		if !util.IsLoopbackHost(parsed.Hostname()) {
+			return "", fmt.Errorf("invalid endpoint %q: http is only allowed for loopback hosts", raw)
+		}
+	default:
+		return "", fmt.Errorf("invalid endpoint %q: expected an https URL", raw)
+	}
+	return strings.TrimSuffix(parsed.String(), "/"), nil
+}
+
+// ApplyMetadataEndpoint disables an auth whose endpoint override is unusable, so a bad auth
+// file is rejected when it is loaded rather than failing each request like a revoked token.
+func (a *Auth) ApplyMetadataEndpoint() {
+	if a == nil || a.Metadata == nil {
+		return
+	}
+	raw, _ := a.Metadata[MetadataEndpointKey].(string)
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+	if _, err := ValidateEndpoint(raw); err != nil {
+		a.Disabled = true
+		a.Status = StatusDisabled
+		a.StatusMessage = err.Error()
+	}
+}
+
 func (a *Auth) AccountInfo() (string, string) {
 	if a == nil {
 		return "", ""
 	}
+	if a.Attributes["source"] == SourceRequest {
+		// The client's key is not the proxy's to show.
+		return "request", a.Label
+	}
 	if strings.ToLower(a.Provider) == "gemini-web" {
 		// Prefer explicit label written into auth file (e.g., gemini-web-<hash>)
 		if a.Metadata != nil {
@@ -0,0 +1,56 @@
+package auth
+
+import "testing"
+
+func TestValidateEndpoint(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"https://us-central1-cloudcode-pa.googleapis.com/", "https://us-central1-cloudcode-pa.googleapis.com", true},
+		{"http://127.0.0.1:8080", "http://127.0.0.1:8080", true},
+		{"http://localhost:9000", "http://localhost:9000", true},
+		{"http://[::1]:9000", "http://[::1]:9000", true},
+		{"http://cloudcode-pa.googleapis.com", "", false},
+		{"ftp://example.com", "", false},
+		{"https://example.com/?q=1", "", false},
+		{"https://example.com/#frag", "", false},
+		{"not a url", "", false},
+	}
+	for _, tc := range cases {
+		got, err := ValidateEndpoint(tc.raw)
+		if (err == nil) != tc.ok {
+			t.Errorf("ValidateEndpoint(%q) error = %v, want ok %t", tc.raw, err, tc.ok)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ValidateEndpoint(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestApplyMetadataEndpointDisablesInvalidOverride(t *testing.T) {
+	a := &Auth{Status: StatusActive, Metadata: map[string]any{MetadataEndpointKey: "http://example.com"}}
+	a.ApplyMetadataEndpoint()
+	if !a.Disabled || a.Status != StatusDisabled || a.StatusMessage == "" {
+		t.Fatalf("auth with plain http endpoint not disabled: %+v", a)
+	}
+
+	b := &Auth{Status: StatusActive, Metadata: map[string]any{MetadataEndpointKey: "https://example.com"}}
+	b.ApplyMetadataEndpoint()
+	if b.Disabled || b.Status != StatusActive {
+		t.Fatalf("auth with valid endpoint disabled: %+v", b)
+	}
+}
+
+func TestAssignedLabelIgnoresEmailDefault(t *testing.T) {
+	auth := &Auth{Label: "user@example.com", Metadata: map[string]any{"email": "user@example.com"}}
+	if got := auth.AssignedLabel(); got != "" {
+		t.Fatalf("AssignedLabel() = %q, want none", got)
+	}
+	auth.Metadata[MetadataLabelKey] = " tier-1 "
+	if got := auth.AssignedLabel(); got != "tier-1" {
+		t.Fatalf("AssignedLabel() = %q, want tier-1", got)
+	}
+}
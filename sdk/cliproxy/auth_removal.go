@@ -0,0 +1,123 @@
+package cliproxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	geminiwebclient "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAuthRemovalGrace        = 5 * time.Second
+	defaultAuthRemovalDrainTimeout = 300 * time.Second
+)
+
+// authRemovalTimings returns the grace period and drain timeout configured for deleted auths.
+func authRemovalTimings(cfg *config.Config) (time.Duration, time.Duration) {
+	grace, drain := defaultAuthRemovalGrace, defaultAuthRemovalDrainTimeout
+	if cfg != nil {
+		if cfg.AuthRemoval.Grace > 0 {
+			grace = time.Duration(cfg.AuthRemoval.Grace) * time.Second
+		}
+		if cfg.AuthRemoval.DrainTimeout > 0 {
+			drain = time.Duration(cfg.AuthRemoval.DrainTimeout) * time.Second
+		}
+	}
+	return grace, drain
+}
+
+// scheduleAuthTeardown tears down the state of the deleted auth once it has stayed deleted for
+// the grace period and its in-flight requests have finished or the drain timeout passed. A
+// pending teardown of the same auth is replaced.
+func (s *Service) scheduleAuthTeardown(ctx context.Context, auth *coreauth.Auth) {
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	teardownCtx, cancel := context.WithCancel(ctx)
+	s.removalMu.Lock()
+	if s.pendingRemovals == nil {
+		s.pendingRemovals = make(map[string]context.CancelFunc)
+	}
+	if previous, ok := s.pendingRemovals[auth.ID]; ok {
+		previous()
+	}
+	s.pendingRemovals[auth.ID] = cancel
+	s.removalMu.Unlock()
+
+	grace, drain := authRemovalTimings(cfg)
+	cleanup := cfg != nil && cfg.AuthRemoval.CleanupOrphanedArtifacts
+	go func() {
+		defer cancel()
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-teardownCtx.Done():
+			return
+		case <-timer.C:
+		}
+		drainCtx, drainCancel := context.WithTimeout(teardownCtx, drain)
+		drained := s.coreManager.WaitIdle(drainCtx, auth.ID)
+		drainCancel()
+		if teardownCtx.Err() != nil {
+			return
+		}
+		if !drained {
+			log.Warnf("auth %s still had %d requests in flight after %s; tearing it down", auth.ID, s.coreManager.InFlight(auth.ID), drain)
+		}
+
+		s.removalMu.Lock()
+		if teardownCtx.Err() != nil {
+			s.removalMu.Unlock()
+			return
+		}
+		delete(s.pendingRemovals, auth.ID)
+		s.removalMu.Unlock()
+
+		executor.ReleaseGeminiWebState(auth.ID)
+		if cleanup {
+			removeAuthArtifacts(auth)
+		}
+		log.Debugf("auth %s torn down after removal", auth.ID)
+	}()
+}
+
+// cancelAuthTeardown stops the pending teardown of the auth with id, reporting whether there
+// was one.
+func (s *Service) cancelAuthTeardown(id string) bool {
+	s.removalMu.Lock()
+	defer s.removalMu.Unlock()
+	cancel, ok := s.pendingRemovals[id]
+	if !ok {
+		return false
+	}
+	cancel()
+	delete(s.pendingRemovals, id)
+	return true
+}
+
+// removeAuthArtifacts deletes the files kept for the token file of auth, which is the Gemini
+// Web conversation store. Auths without a token file have none.
+func removeAuthArtifacts(auth *coreauth.Auth) {
+	if auth == nil || auth.Attributes == nil {
+		return
+	}
+	path := strings.TrimSpace(auth.Attributes["path"])
+	if path == "" {
+		return
+	}
+	artifact := geminiwebclient.ConvBoltPath(path)
+	if err := os.Remove(artifact); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("failed to remove %s of removed auth %s: %v", artifact, auth.ID, err)
+		}
+		return
+	}
+	log.Infof("removed %s of removed auth %s", artifact, auth.ID)
+}
@@ -0,0 +1,177 @@
+package cliproxy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	geminiwebclient "github.com/router-for-me/CLIProxyAPI/v6/internal/provider/gemini-web"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestRemoveAuthArtifactsDeletesOnlyTheConversationStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "auths", "gemini-web-user.json")
+	store := geminiwebclient.ConvBoltPath(path)
+	unrelated := filepath.Join(dir, "auths", "gemini-web-user.cookie")
+	for _, file := range []string{store, unrelated} {
+		if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeAuthArtifacts(&coreauth.Auth{ID: "gemini-web-user.json", Attributes: map[string]string{"path": path}})
+
+	if _, err := os.Stat(store); !os.IsNotExist(err) {
+		t.Errorf("conversation store kept: %v", err)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("file the proxy never wrote was removed: %v", err)
+	}
+}
+
+// newRemovalTestService returns a service tearing removed auths down after a one second
+// grace period, deleting their conversation store.
+func newRemovalTestService() *Service {
+	cfg := &config.Config{}
+	cfg.AuthRemoval.Grace = 1
+	cfg.AuthRemoval.CleanupOrphanedArtifacts = true
+	return &Service{cfg: cfg, coreManager: coreauth.NewManager(nil, nil, nil)}
+}
+
+// writeConversationStore creates the conversation store of the token file at path.
+func writeConversationStore(t *testing.T, path string) string {
+	t.Helper()
+	store := geminiwebclient.ConvBoltPath(path)
+	if err := os.MkdirAll(filepath.Dir(store), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func pooled(id string) bool {
+	for _, status := range executor.GeminiWebPoolSnapshot() {
+		if status.AuthID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAuthReappearingWithinGraceKeepsItsState(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "auths", "gemini-web-grace.json")
+	store := writeConversationStore(t, path)
+	auth := &coreauth.Auth{
+		ID:         "gemini-web-grace.json",
+		Provider:   "gemini-web",
+		Status:     coreauth.StatusActive,
+		Attributes: map[string]string{"path": path},
+		Metadata:   map[string]any{"secure_1psid": "psid", "secure_1psidts": "psidts"},
+	}
+	t.Cleanup(func() { executor.ReleaseGeminiWebState(auth.ID) })
+	s := newRemovalTestService()
+	ctx := context.Background()
+
+	s.applyCoreAuthAddOrUpdate(ctx, auth)
+	if !pooled(auth.ID) {
+		t.Fatal("gemini-web state missing after the auth was added")
+	}
+	s.applyCoreAuthRemoval(ctx, auth.ID)
+	time.Sleep(200 * time.Millisecond)
+	s.applyCoreAuthAddOrUpdate(ctx, auth)
+
+	time.Sleep(1500 * time.Millisecond)
+	if !pooled(auth.ID) {
+		t.Error("gemini-web state torn down although the auth reappeared within the grace period")
+	}
+	if _, err := os.Stat(store); err != nil {
+		t.Errorf("conversation store removed although the auth reappeared: %v", err)
+	}
+	if current, ok := s.coreManager.GetByID(auth.ID); !ok || current.Disabled {
+		t.Errorf("reappeared auth = %+v, want it enabled", current)
+	}
+}
+
+// blockingExecutor holds every request until release is closed.
+type blockingExecutor struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *blockingExecutor) Identifier() string { return "removal-test" }
+
+func (e *blockingExecutor) Execute(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	close(e.started)
+	<-e.release
+	return cliproxyexecutor.Response{Payload: []byte(`{}`)}, nil
+}
+
+func (e *blockingExecutor) ExecuteStream(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	ch := make(chan cliproxyexecutor.StreamChunk)
+	close(ch)
+	return ch, nil
+}
+
+func (e *blockingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *blockingExecutor) CountTokens(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func TestRemovedAuthIsTornDownOnceItsRequestsDrain(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "auths", "drain.json")
+	store := writeConversationStore(t, path)
+	s := newRemovalTestService()
+	exec := &blockingExecutor{started: make(chan struct{}), release: make(chan struct{})}
+	s.coreManager.RegisterExecutor(exec)
+	auth := &coreauth.Auth{ID: "drain.json", Provider: "removal-test", Status: coreauth.StatusActive, Attributes: map[string]string{"path": path}}
+	ctx := context.Background()
+	if _, err := s.coreManager.Register(ctx, auth); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.coreManager.Execute(ctx, []string{"removal-test"}, cliproxyexecutor.Request{Model: "drain-model"}, cliproxyexecutor.Options{})
+		done <- err
+	}()
+	<-exec.started
+	s.applyCoreAuthRemoval(ctx, auth.ID)
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(store); err != nil {
+		t.Fatalf("conversation store removed while a request was in flight: %v", err)
+	}
+	close(exec.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(store); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("conversation store kept after the auth drained")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
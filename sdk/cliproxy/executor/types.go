@@ -1,8 +1,12 @@
 package executor
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 )
@@ -33,12 +37,18 @@ type Options struct {
 	OriginalRequest []byte
 	// SourceFormat identifies the inbound schema.
 	SourceFormat sdktranslator.Format
+	// PreferBody allows non-streaming executors to return an untranslated response as
+	// Response.Body instead of buffering it into Payload.
+	PreferBody bool
 }
 
 // Response wraps either a full provider response or metadata for streaming flows.
 type Response struct {
 	// Payload is the provider response in the executor format.
 	Payload []byte
+	// Body streams the response instead of Payload when the caller set Options.PreferBody
+	// and the executor could pass the upstream body through. The caller must close it.
+	Body io.ReadCloser
 	// Metadata exposes optional structured data for translators.
 	Metadata map[string]any
 }
@@ -49,6 +59,9 @@ type StreamChunk struct {
 	Payload []byte
 	// Err reports any terminal error encountered while producing chunks.
 	Err error
+	// Heartbeat marks a chunk without payload, sent while the upstream is still working so
+	// the caller can keep its client connection open.
+	Heartbeat bool
 }
 
 // StatusError represents an error that carries an HTTP-like status code.
@@ -58,3 +71,98 @@ type StatusError interface {
 	error
 	StatusCode() int
 }
+
+// ErrorKind classifies execution failures so callers can branch on the failure
+// category instead of re-inspecting status codes or messages.
+type ErrorKind string
+
+const (
+	// ErrorKindQuota marks rate limits and exhausted quotas.
+	ErrorKindQuota ErrorKind = "quota"
+	// ErrorKindAuth marks rejected, expired or unpaid credentials.
+	ErrorKindAuth ErrorKind = "auth"
+	// ErrorKindTransient marks timeouts and temporary upstream outages worth retrying.
+	ErrorKindTransient ErrorKind = "transient"
+	// ErrorKindInvalid marks requests the upstream rejected as malformed.
+	ErrorKindInvalid ErrorKind = "invalid"
+	// ErrorKindUpstream marks any other upstream failure.
+	ErrorKindUpstream ErrorKind = "upstream"
+)
+
+// KindError represents an error that knows its ErrorKind.
+// Provider executors should implement this when the status code alone is ambiguous.
+type KindError interface {
+	error
+	Kind() ErrorKind
+}
+
+// ErrorKindForStatus maps an HTTP-like status code onto an ErrorKind.
+func ErrorKindForStatus(code int) ErrorKind {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrorKindQuota
+	case code == http.StatusUnauthorized, code == http.StatusPaymentRequired, code == http.StatusForbidden:
+		return ErrorKindAuth
+	case code == http.StatusRequestTimeout, code == http.StatusInternalServerError, code == http.StatusBadGateway,
+		code == http.StatusServiceUnavailable, code == http.StatusGatewayTimeout:
+		return ErrorKindTransient
+	case code >= 400 && code < 500:
+		return ErrorKindInvalid
+	default:
+		return ErrorKindUpstream
+	}
+}
+
+// ErrorKindOf classifies err, preferring an explicit KindError and falling back to
+// the StatusError code. Context deadlines are reported as transient.
+func ErrorKindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	var ke KindError
+	if errors.As(err, &ke) && ke != nil {
+		if kind := ke.Kind(); kind != "" {
+			return kind
+		}
+	}
+	var se StatusError
+	if errors.As(err, &se) && se != nil && se.StatusCode() > 0 {
+		return ErrorKindForStatus(se.StatusCode())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTransient
+	}
+	return ErrorKindUpstream
+}
+
+// RetryAfterError represents an error carrying the upstream's advised wait before retrying,
+// typically taken from Retry-After or rate-limit reset headers.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// RetryAfterOf returns the wait advised by err, or zero when none is known.
+func RetryAfterOf(err error) time.Duration {
+	var ra RetryAfterError
+	if errors.As(err, &ra) && ra != nil {
+		if d := ra.RetryAfter(); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// EgressBlockedError represents an error caused by the upstream blocking the network address
+// the request came from rather than the credential, so every auth sending through the same
+// proxy is affected alike.
+type EgressBlockedError interface {
+	error
+	EgressBlocked() bool
+}
+
+// EgressBlockedOf reports whether err says the request's egress address is blocked.
+func EgressBlockedOf(err error) bool {
+	var eb EgressBlockedError
+	return errors.As(err, &eb) && eb != nil && eb.EgressBlocked()
+}
@@ -5,6 +5,10 @@ import "github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 // ModelInfo re-exports the registry model info structure.
 type ModelInfo = registry.ModelInfo
 
+// ProviderFeatures re-exports the provider feature set. Executors declare theirs by
+// implementing a Features() ProviderFeatures method.
+type ProviderFeatures = registry.ProviderFeatures
+
 // ModelRegistry describes registry operations consumed by external callers.
 type ModelRegistry interface {
 	RegisterClient(clientID, clientProvider string, models []*ModelInfo)
@@ -83,6 +83,12 @@ type Service struct {
 	// coreManager handles core authentication and execution.
 	coreManager *coreauth.Manager
 
+	// removalMu guards pendingRemovals.
+	removalMu sync.Mutex
+
+	// pendingRemovals cancels the scheduled teardowns of deleted auths by auth ID.
+	pendingRemovals map[string]context.CancelFunc
+
 	// shutdownOnce ensures shutdown is called only once.
 	shutdownOnce sync.Once
 }
@@ -195,8 +201,14 @@ func (s *Service) applyCoreAuthAddOrUpdate(ctx context.Context, auth *coreauth.A
 		return
 	}
 	auth = auth.Clone()
+	if s.cancelAuthTeardown(auth.ID) {
+		log.Infof("auth %s reappeared before its teardown; keeping it", auth.ID)
+	}
 	s.ensureExecutorsForAuth(auth)
 	s.registerModelsForAuth(auth)
+	if strings.EqualFold(auth.Provider, "gemini-web") {
+		executor.TrackGeminiWebAuth(s.cfg, auth)
+	}
 	if existing, ok := s.coreManager.GetByID(auth.ID); ok && existing != nil {
 		auth.CreatedAt = existing.CreatedAt
 		auth.LastRefreshedAt = existing.LastRefreshedAt
@@ -218,14 +230,20 @@ func (s *Service) applyCoreAuthRemoval(ctx context.Context, id string) {
 	if s.coreManager == nil {
 		return
 	}
+	// New requests stop reaching the auth at once; the ones it is serving finish before its
+	// state is torn down.
 	GlobalModelRegistry().UnregisterClient(id)
-	if existing, ok := s.coreManager.GetByID(id); ok && existing != nil {
-		existing.Disabled = true
-		existing.Status = coreauth.StatusDisabled
-		if _, err := s.coreManager.Update(ctx, existing); err != nil {
-			log.Errorf("failed to disable auth %s: %v", id, err)
-		}
+	existing, ok := s.coreManager.GetByID(id)
+	if !ok || existing == nil {
+		executor.ReleaseGeminiWebState(id)
+		return
 	}
+	existing.Disabled = true
+	existing.Status = coreauth.StatusDisabled
+	if _, err := s.coreManager.Update(ctx, existing); err != nil {
+		log.Errorf("failed to disable auth %s: %v", id, err)
+	}
+	s.scheduleAuthTeardown(ctx, existing)
 }
 
 func (s *Service) ensureExecutorsForAuth(a *coreauth.Auth) {
@@ -245,6 +263,8 @@ func (s *Service) ensureExecutorsForAuth(a *coreauth.Auth) {
 		s.coreManager.RegisterExecutor(executor.NewCodexExecutor(s.cfg))
 	case "qwen":
 		s.coreManager.RegisterExecutor(executor.NewQwenExecutor(s.cfg))
+	case "echo":
+		s.coreManager.RegisterExecutor(executor.NewEchoExecutor(s.cfg))
 	default:
 		providerKey := strings.ToLower(strings.TrimSpace(a.Provider))
 		if providerKey == "" {
@@ -354,6 +374,15 @@ func (s *Service) Run(ctx context.Context) error {
 		s.cfgMu.Lock()
 		s.cfg = newCfg
 		s.cfgMu.Unlock()
+		if s.coreManager != nil {
+			s.coreManager.SetRefreshRetryLimit("gemini-web", newCfg.GeminiWeb.InitMaxRetries)
+			s.coreManager.SetRefreshInterval("gemini-web", executor.GeminiWebRefreshInterval(newCfg))
+			s.coreManager.SetRecentFailureWindow(time.Duration(newCfg.RecentFailureWindow) * time.Second)
+			s.coreManager.SetRetryBudget(newCfg.RetryBudget.MaxAttempts, time.Duration(newCfg.RetryBudget.Deadline)*time.Second)
+			s.coreManager.SetMaintenanceWindows(maintenanceWindows(newCfg))
+			s.coreManager.SetModelAffinity(modelAffinity(newCfg))
+			s.applyActiveHours(newCfg)
+		}
 
 	}
 
@@ -377,6 +406,14 @@ func (s *Service) Run(ctx context.Context) error {
 
 	// Prefer core auth manager auto refresh if available.
 	if s.coreManager != nil {
+		s.coreManager.SetRefreshRetryLimit("gemini-web", s.cfg.GeminiWeb.InitMaxRetries)
+		s.coreManager.SetRefreshInterval("gemini-web", executor.GeminiWebRefreshInterval(s.cfg))
+		s.coreManager.SetDisableOnRejectedRefresh("gemini-web", true)
+		s.coreManager.SetRecentFailureWindow(time.Duration(s.cfg.RecentFailureWindow) * time.Second)
+		s.coreManager.SetRetryBudget(s.cfg.RetryBudget.MaxAttempts, time.Duration(s.cfg.RetryBudget.Deadline)*time.Second)
+		s.coreManager.SetMaintenanceWindows(maintenanceWindows(s.cfg))
+		s.coreManager.SetModelAffinity(modelAffinity(s.cfg))
+		s.applyActiveHours(s.cfg)
 		interval := 15 * time.Minute
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
@@ -481,6 +518,11 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	if a == nil || a.ID == "" {
 		return
 	}
+	// Disabled auths serve no models until they are enabled again.
+	if a.Disabled {
+		GlobalModelRegistry().UnregisterClient(a.ID)
+		return
+	}
 	// Unregister legacy client ID (if present) to avoid double counting
 	if a.Runtime != nil {
 		if idGetter, ok := a.Runtime.(interface{ GetClientID() string }); ok {
@@ -493,7 +535,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	var models []*ModelInfo
 	switch provider {
 	case "gemini":
-		models = registry.GetGeminiModels()
+		models = append(registry.GetGeminiModels(), registry.GetGeminiEmbeddingModels()...)
 	case "gemini-cli":
 		models = registry.GetGeminiCLIModels()
 	case "gemini-web":
@@ -504,6 +546,8 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		models = registry.GetOpenAIModels()
 	case "qwen":
 		models = registry.GetQwenModels()
+	case "echo":
+		models = registry.GetEchoModels()
 	default:
 		// Handle OpenAI-compatibility providers by name using config
 		if s.cfg != nil {
@@ -532,7 +576,6 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 						ms = append(ms, &ModelInfo{
 							ID:          m.Alias,
 							Object:      "model",
-							Created:     time.Now().Unix(),
 							OwnedBy:     compat.Name,
 							Type:        "openai-compatibility",
 							DisplayName: m.Name,
@@ -558,3 +601,57 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		GlobalModelRegistry().RegisterClient(a.ID, key, models)
 	}
 }
+
+// applyActiveHours sets the default Gemini Web active hours and warns when the accounts
+// leave some time of the week without any of them active.
+func (s *Service) applyActiveHours(cfg *config.Config) {
+	if cfg == nil || s.coreManager == nil {
+		return
+	}
+	var hours *coreauth.ActiveHours
+	if spec := strings.TrimSpace(cfg.GeminiWeb.ActiveHours); spec != "" {
+		parsed, err := coreauth.ParseActiveHours(spec, cfg.GeminiWeb.Timezone)
+		if err != nil {
+			log.Warnf("gemini-web.active-hours ignored: %v", err)
+		} else {
+			hours = &parsed
+		}
+	}
+	s.coreManager.SetActiveHours("gemini-web", hours)
+	if gap, ok := s.coreManager.ScheduleGap("gemini-web", time.Now()); ok {
+		log.Warnf("no gemini-web account is within its active hours at %s; requests then fail until one is", gap.Format(time.RFC3339))
+	}
+}
+
+// modelAffinity converts the configured model affinity entries, skipping incomplete ones.
+func modelAffinity(cfg *config.Config) []coreauth.ModelAffinity {
+	if cfg == nil {
+		return nil
+	}
+	rules := make([]coreauth.ModelAffinity, 0, len(cfg.ModelAffinity))
+	for i, entry := range cfg.ModelAffinity {
+		if len(entry.Models) == 0 || len(entry.Auths) == 0 {
+			log.Warnf("model-affinity[%d] ignored: models and auths are both required", i)
+			continue
+		}
+		rules = append(rules, coreauth.ModelAffinity{Models: entry.Models, Auths: entry.Auths})
+	}
+	return rules
+}
+
+// maintenanceWindows converts the configured maintenance windows, skipping invalid entries.
+func maintenanceWindows(cfg *config.Config) []coreauth.MaintenanceWindow {
+	if cfg == nil {
+		return nil
+	}
+	windows := make([]coreauth.MaintenanceWindow, 0, len(cfg.MaintenanceWindows))
+	for i, entry := range cfg.MaintenanceWindows {
+		window, err := coreauth.ParseMaintenanceWindow(entry.Provider, entry.Auth, entry.Days, entry.Start, entry.End, entry.Timezone)
+		if err != nil {
+			log.Warnf("maintenance-windows[%d] ignored: %v", i, err)
+			continue
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
@@ -10,14 +10,75 @@ import (
 
 // Record contains the usage statistics captured for a single provider request.
 type Record struct {
-	Provider    string
-	Model       string
-	APIKey      string
-	AuthID      string
+	Provider string
+	Model    string
+	APIKey   string
+	AuthID   string
+	// AuthLabel is the label of the auth that served the request, such as its account tier.
+	AuthLabel   string
 	RequestedAt time.Time
 	Detail      Detail
+	// OutputCap is the configured output token cap the request was held to, zero when the
+	// client's own limit was already within it.
+	OutputCap int64
+	// OutputTruncated reports whether the proxy cut the response off at OutputCap.
+	OutputTruncated bool
+	// SystemFingerprint identifies the provider, model version and proxy version that served
+	// the request, as reported to OpenAI clients in system_fingerprint.
+	SystemFingerprint string
+	// Status is empty for requests that ran to completion, StatusClientDisconnected for
+	// requests cancelled because the client went away, StatusStreamError for streams cut
+	// short by an error and StatusContentFiltered for streams ended by moderation.
+	Status string
+	// StatusCode is the HTTP status of the error behind StatusStreamError. The client only
+	// sees it in the stream's error event, the response having begun with a 200.
+	StatusCode int
+	// Metadata is the metadata the client attached to a Responses API request.
+	Metadata map[string]string
+	// ResponseTagged reports whether the response got the audit tag of response-tag. Responses
+	// without text, such as tool calls only, are counted but carry no tag.
+	ResponseTagged bool
+	// ResponseTagRef is the reference the tag carries for {request_id}, generated by the proxy.
+	ResponseTagRef string
+	// ToolSchemaTokensSaved estimates the prompt tokens spared by referring to tool schemas
+	// the provider already holds instead of sending them again.
+	ToolSchemaTokensSaved int64
+	// ModerationRule is the moderation rule that ended the stream, as "checker/rule".
+	ModerationRule string
+	// ContextReuse describes the conversation a Gemini Web request continued, nil for other
+	// providers.
+	ContextReuse *ContextReuse
+	// Shadow reports whether the request mirrored another one to compare providers, and
+	// ShadowOf is the request ID of the mirrored request.
+	Shadow   bool
+	ShadowOf string
 }
 
+// ContextReuse reports how much of a request's history the provider already held
+// server-side, so only the rest had to be sent.
+type ContextReuse struct {
+	// Mode is "match" when a recorded conversation with this history was continued,
+	// "fallback" when the latest conversation of the account was, and "none" otherwise.
+	Mode string `json:"mode"`
+	// MatchedMessages is the number of prior messages held server-side and ResentMessages the
+	// number sent with the request.
+	MatchedMessages int64 `json:"matched_messages"`
+	ResentMessages  int64 `json:"resent_messages"`
+	// TokensSaved estimates the prompt tokens not sent thanks to the reuse.
+	TokensSaved int64 `json:"tokens_saved"`
+}
+
+// StatusClientDisconnected marks a record of a request abandoned by its client.
+const StatusClientDisconnected = "client_disconnected"
+
+// StatusStreamError marks a record of a stream that ended with an error event after the
+// response had started.
+const StatusStreamError = "stream_error"
+
+// StatusContentFiltered marks a record of a stream ended by moderation because its output
+// broke a blocked rule.
+const StatusContentFiltered = "content_filtered"
+
 // Detail holds the token usage breakdown.
 type Detail struct {
 	InputTokens     int64
@@ -25,6 +86,13 @@ type Detail struct {
 	ReasoningTokens int64
 	CachedTokens    int64
 	TotalTokens     int64
+	// CacheCreationTokens are prompt tokens written to the cache, which Claude reports apart
+	// from InputTokens and CachedTokens.
+	CacheCreationTokens int64
+	// AcceptedPredictionTokens and RejectedPredictionTokens split the output of a request
+	// with a predicted output, when the upstream reports it.
+	AcceptedPredictionTokens int64
+	RejectedPredictionTokens int64
 }
 
 // Plugin consumes usage records emitted by the proxy runtime.
@@ -12,3 +12,7 @@ func FromString(v string) Format {
 func (f Format) String() string {
 	return string(f)
 }
+
+// FormatRaw is the source format of requests passed through untranslated. No translator is
+// registered for it, so payloads and provider responses cross the pipeline unchanged.
+const FormatRaw Format = "raw"
@@ -81,13 +81,22 @@ func (p *Pipeline) TranslateRequest(ctx context.Context, from, to Format, req Re
 	return handler(ctx, req)
 }
 
-// TranslateResponse applies middleware and registry transformations.
+// TranslateResponse applies middleware and registry transformations. A failed conversion, or
+// one producing output the client could not parse, is returned as a *TranslationError.
 func (p *Pipeline) TranslateResponse(ctx context.Context, from, to Format, resp ResponseEnvelope, originalReq, translatedReq []byte, param *any) (ResponseEnvelope, error) {
 	terminal := func(ctx context.Context, input ResponseEnvelope) (ResponseEnvelope, error) {
 		if input.Stream {
-			input.Chunks = p.registry.TranslateStream(ctx, from, to, input.Model, originalReq, translatedReq, input.Body, param)
+			chunks, err := p.registry.TranslateStreamWithError(ctx, from, to, input.Model, originalReq, translatedReq, input.Body, param)
+			if err != nil {
+				return input, err
+			}
+			input.Chunks = chunks
 		} else {
-			input.Body = []byte(p.registry.TranslateNonStream(ctx, from, to, input.Model, originalReq, translatedReq, input.Body, param))
+			out, err := p.registry.TranslateNonStreamWithError(ctx, from, to, input.Model, originalReq, translatedReq, input.Body, param)
+			if err != nil {
+				return input, err
+			}
+			input.Body = []byte(out)
 		}
 		input.Format = to
 		return input, nil
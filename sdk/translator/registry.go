@@ -2,7 +2,10 @@ package translator
 
 import (
 	"context"
+	"strings"
 	"sync"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // Registry manages translation functions across schemas.
@@ -52,6 +55,19 @@ func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byt
 	return rawJSON
 }
 
+// HasRequestTransformer indicates whether a request translator exists.
+func (r *Registry) HasRequestTransformer(from, to Format) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if byTarget, ok := r.requests[from]; ok {
+		if fn, isOk := byTarget[to]; isOk && fn != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // HasResponseTransformer indicates whether a response translator exists.
 func (r *Registry) HasResponseTransformer(from, to Format) bool {
 	r.mu.RLock()
@@ -65,33 +81,114 @@ func (r *Registry) HasResponseTransformer(from, to Format) bool {
 	return false
 }
 
-// TranslateStream applies the registered streaming response translator.
+// TranslateStream applies the registered streaming response translator. A failure reported
+// by the translator is logged and yields no chunks; use TranslateStreamWithError to handle it.
 func (r *Registry) TranslateStream(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	if byTarget, ok := r.responses[to]; ok {
-		if fn, isOk := byTarget[from]; isOk && fn.Stream != nil {
-			return fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+	fn, ok := r.responseTransform(from, to)
+	switch {
+	case ok && fn.StreamWithError != nil:
+		chunks, err := fn.StreamWithError(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+		if err != nil {
+			log.Errorf("%v", &TranslationError{From: from, To: to, Model: model, Err: err})
+			return nil
 		}
+		return chunks
+	case ok && fn.Stream != nil:
+		return fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 	}
 	return []string{string(rawJSON)}
 }
 
-// TranslateNonStream applies the registered non-stream response translator.
+// TranslateStreamWithError applies the registered streaming response translator like
+// TranslateStream, but returns a *TranslationError when the translator fails or produces a
+// chunk that is not valid JSON. Responses in the format they arrived in are not checked.
+func (r *Registry) TranslateStreamWithError(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) ([]string, error) {
+	fn, ok := r.responseTransform(from, to)
+	var chunks []string
+	switch {
+	case ok && fn.StreamWithError != nil:
+		var err error
+		if chunks, err = fn.StreamWithError(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param); err != nil {
+			return nil, &TranslationError{From: from, To: to, Model: model, Err: err}
+		}
+	case ok && fn.Stream != nil:
+		chunks = fn.Stream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+	default:
+		return []string{string(rawJSON)}, nil
+	}
+	if from == to {
+		return chunks, nil
+	}
+	for _, chunk := range chunks {
+		if err := validateChunk(chunk); err != nil {
+			return nil, &TranslationError{From: from, To: to, Model: model, Err: err}
+		}
+	}
+	return chunks, nil
+}
+
+// TranslateNonStream applies the registered non-stream response translator. A failure
+// reported by the translator is logged and yields an empty response; use
+// TranslateNonStreamWithError to handle it.
 func (r *Registry) TranslateNonStream(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+	fn, ok := r.responseTransform(from, to)
+	switch {
+	case ok && fn.NonStreamWithError != nil:
+		out, err := fn.NonStreamWithError(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+		if err != nil {
+			log.Errorf("%v", &TranslationError{From: from, To: to, Model: model, Err: err})
+			return ""
+		}
+		return out
+	case ok && fn.NonStream != nil:
+		return fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+	}
+	return string(rawJSON)
+}
+
+// TranslateNonStreamWithError applies the registered non-stream response translator like
+// TranslateNonStream, but returns a *TranslationError when the translator fails or produces
+// an empty or invalid JSON response. Responses in the format they arrived in are not checked.
+func (r *Registry) TranslateNonStreamWithError(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) (string, error) {
+	fn, ok := r.responseTransform(from, to)
+	var out string
+	switch {
+	case ok && fn.NonStreamWithError != nil:
+		var err error
+		if out, err = fn.NonStreamWithError(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param); err != nil {
+			return "", &TranslationError{From: from, To: to, Model: model, Err: err}
+		}
+	case ok && fn.NonStream != nil:
+		out = fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+	default:
+		return string(rawJSON), nil
+	}
+	if from == to {
+		return out, nil
+	}
+	if strings.TrimSpace(out) == "" {
+		return "", &TranslationError{From: from, To: to, Model: model, Err: ErrEmptyOutput}
+	}
+	if err := validateJSON(out); err != nil {
+		return "", &TranslationError{From: from, To: to, Model: model, Err: err}
+	}
+	return out, nil
+}
+
+// responseTransform returns the response translator from the upstream format from to the
+// client format to.
+func (r *Registry) responseTransform(from, to Format) (ResponseTransform, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-
 	if byTarget, ok := r.responses[to]; ok {
-		if fn, isOk := byTarget[from]; isOk && fn.NonStream != nil {
-			return fn.NonStream(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+		if fn, isOk := byTarget[from]; isOk {
+			return fn, true
 		}
 	}
-	return string(rawJSON)
+	return ResponseTransform{}, false
 }
 
-// TranslateNonStream applies the registered non-stream response translator.
+// TranslateTokenCount applies the registered token count translator.
 func (r *Registry) TranslateTokenCount(ctx context.Context, from, to Format, count int64, rawJSON []byte) string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -121,6 +218,11 @@ func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool
 	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)
 }
 
+// HasRequestTransformer inspects the default registry.
+func HasRequestTransformer(from, to Format) bool {
+	return defaultRegistry.HasRequestTransformer(from, to)
+}
+
 // HasResponseTransformer inspects the default registry.
 func HasResponseTransformer(from, to Format) bool {
 	return defaultRegistry.HasResponseTransformer(from, to)
@@ -136,6 +238,16 @@ func TranslateNonStream(ctx context.Context, from, to Format, model string, orig
 	return defaultRegistry.TranslateNonStream(ctx, from, to, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
 }
 
+// TranslateStreamWithError is a helper on the default registry.
+func TranslateStreamWithError(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) ([]string, error) {
+	return defaultRegistry.TranslateStreamWithError(ctx, from, to, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+}
+
+// TranslateNonStreamWithError is a helper on the default registry.
+func TranslateNonStreamWithError(ctx context.Context, from, to Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) (string, error) {
+	return defaultRegistry.TranslateNonStreamWithError(ctx, from, to, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+}
+
 // TranslateTokenCount is a helper on the default registry.
 func TranslateTokenCount(ctx context.Context, from, to Format, count int64, rawJSON []byte) string {
 	return defaultRegistry.TranslateTokenCount(ctx, from, to, count, rawJSON)
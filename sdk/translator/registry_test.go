@@ -0,0 +1,55 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errBrokenChunk = errors.New("broken chunk")
+
+func failingRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("client", "upstream", nil, ResponseTransform{
+		StreamWithError: func(context.Context, string, []byte, []byte, []byte, *any) ([]string, error) {
+			return nil, errBrokenChunk
+		},
+		NonStreamWithError: func(context.Context, string, []byte, []byte, []byte, *any) (string, error) {
+			return "", errBrokenChunk
+		},
+	})
+	return r
+}
+
+func TestLegacyTranslateLogsFailures(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	r := failingRegistry()
+	if chunks := r.TranslateStream(context.Background(), "upstream", "client", "m", nil, nil, []byte(`{}`), nil); chunks != nil {
+		t.Errorf("chunks = %v, want none", chunks)
+	}
+	if body := r.TranslateNonStream(context.Background(), "upstream", "client", "m", nil, nil, []byte(`{}`), nil); body != "" {
+		t.Errorf("body = %q, want empty", body)
+	}
+	if got := strings.Count(buf.String(), "broken chunk"); got != 2 {
+		t.Errorf("logged %d failures, want 2:\n%s", got, buf.String())
+	}
+}
+
+func TestPipelineReturnsTranslationErrors(t *testing.T) {
+	p := NewPipeline(failingRegistry())
+	for _, stream := range []bool{true, false} {
+		_, err := p.TranslateResponse(context.Background(), "upstream", "client", ResponseEnvelope{Model: "m", Stream: stream, Body: []byte(`{}`)}, nil, nil, nil)
+		var translationErr *TranslationError
+		if !errors.As(err, &translationErr) || !errors.Is(err, errBrokenChunk) {
+			t.Errorf("stream=%t: err = %v, want the translation error", stream, err)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 // Package translator provides types and functions for converting chat requests and responses between different schemas.
 package translator
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // RequestTransform is a function type that converts a request payload from a source schema to a target schema.
 // It takes the model name, the raw JSON payload of the request, and a boolean indicating if the request is for a streaming response.
@@ -18,6 +24,14 @@ type ResponseStreamTransform func(ctx context.Context, model string, originalReq
 // It returns the converted response as a single string.
 type ResponseNonStreamTransform func(ctx context.Context, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string
 
+// ResponseStreamTransformWithError is a ResponseStreamTransform that can report a failed
+// conversion instead of returning broken chunks.
+type ResponseStreamTransformWithError func(ctx context.Context, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) ([]string, error)
+
+// ResponseNonStreamTransformWithError is a ResponseNonStreamTransform that can report a failed
+// conversion instead of returning a broken response.
+type ResponseNonStreamTransformWithError func(ctx context.Context, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) (string, error)
+
 // ResponseTokenCountTransform is a function type that transforms a token count from a source format to a target format.
 // It takes a context and the token count as an int64, and returns the transformed token count as a string.
 type ResponseTokenCountTransform func(ctx context.Context, count int64) string
@@ -31,4 +45,64 @@ type ResponseTransform struct {
 	NonStream ResponseNonStreamTransform
 	// TokenCount is the function for transforming token counts.
 	TokenCount ResponseTokenCountTransform
+	// StreamWithError, when set, is used instead of Stream and may report a failure.
+	StreamWithError ResponseStreamTransformWithError
+	// NonStreamWithError, when set, is used instead of NonStream and may report a failure.
+	NonStreamWithError ResponseNonStreamTransformWithError
+}
+
+// Errors wrapped by TranslationError for output the registry rejects.
+var (
+	ErrEmptyOutput   = errors.New("translator produced an empty response")
+	ErrInvalidOutput = errors.New("translator produced invalid JSON")
+)
+
+// TranslationError reports a response conversion that failed or produced output the client
+// could not parse.
+type TranslationError struct {
+	// From is the upstream format and To the client format of the conversion.
+	From  Format
+	To    Format
+	Model string
+	Err   error
+}
+
+// Error implements error.
+func (e *TranslationError) Error() string {
+	return fmt.Sprintf("translate %s response to %s for model %s: %v", e.From, e.To, e.Model, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TranslationError) Unwrap() error { return e.Err }
+
+// validateChunk checks one translated stream chunk: every data line of a server-sent event,
+// or the whole chunk otherwise, must be valid JSON. Empty chunks and "[DONE]" pass.
+func validateChunk(chunk string) error {
+	trimmed := strings.TrimSpace(chunk)
+	if trimmed == "" || trimmed == "[DONE]" {
+		return nil
+	}
+	if !strings.HasPrefix(trimmed, "event:") && !strings.HasPrefix(trimmed, "data:") {
+		return validateJSON(trimmed)
+	}
+	for _, line := range strings.Split(trimmed, "\n") {
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(line[len("data:"):])
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		if err := validateJSON(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateJSON(data string) error {
+	if !json.Valid([]byte(data)) {
+		return ErrInvalidOutput
+	}
+	return nil
 }